@@ -0,0 +1,363 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package agent exports the data collected for views to an agent collecting
+// the stats of several processes, e.g. a sidecar, over gRPC. The agent
+// implements the Agent service of the package statspb, so the hosts can
+// offload the logic specific to the backends to the agent.
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/stats/statspb"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+const (
+	defaultBufferSize = 1024
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// Options are the options of an Exporter.
+type Options struct {
+	// Address is the address of the agent, e.g. "localhost:55678".
+	Address string
+
+	// DialOptions are the options used to dial the agent. The connection is
+	// insecure if no options are set.
+	DialOptions []grpc.DialOption
+
+	// BufferSize is the maximum number of ViewData buffered while the agent
+	// is unreachable. The oldest ViewData are dropped when the buffer is
+	// full. It defaults to 1024.
+	BufferSize int
+
+	// MinBackoff and MaxBackoff bound the delay between the attempts to
+	// reach the agent. The delay doubles after each failed attempt. They
+	// default to 100ms and 30s.
+	MinBackoff, MaxBackoff time.Duration
+
+	// OnError is called with the errors of the stream to the agent. The
+	// errors are logged if it is nil.
+	OnError func(err error)
+
+	// ProcessID identifies the process to the agent. It must be unique among
+	// the processes exporting to the agent. It defaults to the host name, the
+	// pid and the time the exporter was created.
+	ProcessID string
+}
+
+// Exporter streams the definitions of views and their collected data to an
+// agent. The definition of a view is sent once per stream, before its first
+// data. The stream is reopened when it breaks, and the data is buffered
+// until it can be sent. The data sent on a stream that breaks before the
+// agent receives it is lost.
+type Exporter struct {
+	opts   Options
+	conn   *grpc.ClientConn
+	client statspb.AgentClient
+	c      chan *stats.ViewData
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	buf     []*statspb.ViewData
+	views   map[string]*statspb.View
+	dropped uint64
+
+	// stream is the current stream to the agent, defined holds the names of
+	// the views defined on it, and status receives the status of the stream
+	// when it ends. They are only used by the sending goroutine.
+	stream  statspb.Agent_ExportClient
+	defined map[string]bool
+	status  chan error
+
+	// ready is signaled when data is buffered, and done is closed by
+	// Shutdown.
+	ready    chan struct{}
+	done     chan struct{}
+	doneOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewExporter returns an Exporter streaming the data to the agent at
+// opts.Address. The agent doesn't need to be reachable when the exporter is
+// created.
+func NewExporter(opts Options) (*Exporter, error) {
+	if opts.Address == "" {
+		return nil, errors.New("cannot create agent exporter without address")
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultBufferSize
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = defaultMaxBackoff
+		if opts.MaxBackoff < opts.MinBackoff {
+			opts.MaxBackoff = opts.MinBackoff
+		}
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {
+			glog.Warningf("cannot export stats to agent '%v': %v", opts.Address, err)
+		}
+	}
+	if opts.ProcessID == "" {
+		host, _ := os.Hostname()
+		opts.ProcessID = fmt.Sprintf("%v:%v:%v", host, os.Getpid(), time.Now().UnixNano())
+	}
+	dialOpts := opts.DialOptions
+	if len(dialOpts) == 0 {
+		dialOpts = []grpc.DialOption{grpc.WithInsecure()}
+	}
+	conn, err := grpc.Dial(opts.Address, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Exporter{
+		opts:   opts,
+		conn:   conn,
+		client: statspb.NewAgentClient(conn),
+		c:      make(chan *stats.ViewData, opts.BufferSize),
+		views:  make(map[string]*statspb.View),
+		ready:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.wg.Add(2)
+	go e.receive()
+	go e.send()
+	return e, nil
+}
+
+// Subscribe subscribes the exporter to the data collected for v.
+func (e *Exporter) Subscribe(v stats.View) error {
+	return stats.SubscribeToView(v, e.c)
+}
+
+// Unsubscribe unsubscribes the exporter from the data collected for v.
+func (e *Exporter) Unsubscribe(v stats.View) error {
+	return stats.UnsubscribeFromView(v, e.c)
+}
+
+// ExportViewData buffers vd to be sent to the agent, e.g. when vd is
+// retrieved with ReadAll instead of a subscription. vd is not used after
+// ExportViewData returns.
+func (e *Exporter) ExportViewData(vd *stats.ViewData) error {
+	pb, err := statspb.FromViewData(vd)
+	if err != nil {
+		return err
+	}
+	name := pb.View.Name
+	def := pb.View
+	pb.View = &statspb.View{Name: name}
+
+	e.mu.Lock()
+	e.views[name] = def
+	e.buf = append(e.buf, pb)
+	e.trim()
+	e.mu.Unlock()
+
+	select {
+	case e.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Dropped returns the number of ViewData dropped because the buffer was
+// full.
+func (e *Exporter) Dropped() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.dropped
+}
+
+// Shutdown sends the buffered data to the agent, closes the stream and the
+// connection to the agent. The data exported after Shutdown is dropped.
+// Shutdown returns ctx.Err() if ctx is done before the agent acknowledges
+// the data.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.doneOnce.Do(func() { close(e.done) })
+	stopped := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(stopped)
+	}()
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	e.cancel()
+	<-stopped
+	if cerr := e.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// trim drops the oldest buffered data beyond the buffer size. It must be
+// called with mu held.
+func (e *Exporter) trim() {
+	if n := len(e.buf) - e.opts.BufferSize; n > 0 {
+		e.dropped += uint64(n)
+		e.buf = append(e.buf[:0], e.buf[n:]...)
+	}
+}
+
+func (e *Exporter) receive() {
+	defer e.wg.Done()
+	for {
+		select {
+		case vd := <-e.c:
+			if err := e.ExportViewData(vd); err != nil {
+				e.opts.OnError(err)
+			}
+		case <-e.done:
+			return
+		}
+	}
+}
+
+func (e *Exporter) send() {
+	defer e.wg.Done()
+	backoff := e.opts.MinBackoff
+	for {
+		select {
+		case <-e.ready:
+		case err := <-e.status:
+			// the stream ended, it is reopened when data is buffered.
+			e.stream, e.status = nil, nil
+			if err != nil {
+				e.opts.OnError(err)
+			}
+			continue
+		case <-e.done:
+			e.close()
+			return
+		}
+		for {
+			err := e.flush()
+			if err == nil {
+				backoff = e.opts.MinBackoff
+				break
+			}
+			e.opts.OnError(err)
+			select {
+			case <-time.After(backoff):
+			case <-e.done:
+				e.close()
+				return
+			}
+			if backoff *= 2; backoff > e.opts.MaxBackoff {
+				backoff = e.opts.MaxBackoff
+			}
+		}
+	}
+}
+
+// flush sends the buffered data to the agent, opening a new stream if
+// needed. The data is buffered again if it cannot be sent.
+func (e *Exporter) flush() error {
+	e.mu.Lock()
+	batch := e.buf
+	e.buf = nil
+	e.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if e.stream == nil {
+		stream, err := e.client.Export(e.ctx)
+		if err != nil {
+			e.requeue(batch)
+			return err
+		}
+		e.stream = stream
+		e.defined = make(map[string]bool)
+		e.status = make(chan error, 1)
+		go func(status chan<- error) {
+			// the agent only responds when the stream ends.
+			status <- stream.RecvMsg(new(statspb.ExportResponse))
+		}(e.status)
+	}
+	req := &statspb.ExportRequest{
+		ViewData:  batch,
+		ProcessId: e.opts.ProcessID,
+	}
+	e.mu.Lock()
+	for _, vd := range batch {
+		name := vd.View.Name
+		if !e.defined[name] {
+			req.Views = append(req.Views, e.views[name])
+			e.defined[name] = true
+		}
+	}
+	e.mu.Unlock()
+
+	if err := e.stream.Send(req); err != nil {
+		// the stream ended, io.EOF is returned instead of its status.
+		if err == io.EOF {
+			if serr := <-e.status; serr != nil {
+				err = serr
+			}
+		}
+		e.stream, e.status = nil, nil
+		e.requeue(batch)
+		return err
+	}
+	return nil
+}
+
+// requeue buffers batch again, before the data buffered since it was taken.
+func (e *Exporter) requeue(batch []*statspb.ViewData) {
+	e.mu.Lock()
+	e.buf = append(batch, e.buf...)
+	e.trim()
+	e.mu.Unlock()
+}
+
+// close sends the buffered data and closes the stream.
+func (e *Exporter) close() {
+	if err := e.flush(); err != nil {
+		e.opts.OnError(err)
+	}
+	if e.stream == nil {
+		return
+	}
+	if err := e.stream.CloseSend(); err != nil {
+		e.opts.OnError(err)
+	}
+	if err := <-e.status; err != nil {
+		e.opts.OnError(err)
+	}
+	e.stream, e.status = nil, nil
+}
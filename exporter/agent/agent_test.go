@@ -0,0 +1,218 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package agent
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/stats/statspb"
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// fakeAgent records the requests it receives.
+type fakeAgent struct {
+	mu   sync.Mutex
+	reqs []*statspb.ExportRequest
+	got  chan struct{}
+}
+
+func (a *fakeAgent) Export(stream statspb.Agent_ExportServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return stream.SendAndClose(&statspb.ExportResponse{})
+		}
+		a.mu.Lock()
+		a.reqs = append(a.reqs, req)
+		a.mu.Unlock()
+		a.got <- struct{}{}
+	}
+}
+
+func (a *fakeAgent) requests() []*statspb.ExportRequest {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]*statspb.ExportRequest(nil), a.reqs...)
+}
+
+// startAgent starts a fakeAgent listening on addr. It returns the agent, its
+// server and the address it listens on.
+func startAgent(t *testing.T, addr string) (*fakeAgent, *grpc.Server, string) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Listen() got error %v, want no error", err)
+	}
+	a := &fakeAgent{got: make(chan struct{}, 100)}
+	s := grpc.NewServer()
+	statspb.RegisterAgentServer(s, a)
+	go s.Serve(l)
+	return a, s, l.Addr().String()
+}
+
+func waitRequests(t *testing.T, a *fakeAgent, n int) []*statspb.ExportRequest {
+	deadline := time.After(10 * time.Second)
+	for len(a.requests()) < n {
+		select {
+		case <-a.got:
+		case <-deadline:
+			t.Fatalf("got %v requests, want %v", len(a.requests()), n)
+		}
+	}
+	return a.requests()
+}
+
+func newViewData(t *testing.T) *stats.ViewData {
+	m, err := stats.GetMeasureByName("agent/requests")
+	if err != nil {
+		if m, err = stats.NewMeasureInt64("agent/requests", "number of requests", "1"); err != nil {
+			t.Fatalf("NewMeasureInt64() got error %v, want no error", err)
+		}
+	}
+	k, err := tags.CreateKeyString("agent/method")
+	if err != nil {
+		t.Fatalf("CreateKeyString() got error %v, want no error", err)
+	}
+	v := stats.NewView("agent/requests_count", "count of requests", []tags.Key{k}, m, stats.NewAggregationCount(), stats.NewWindowCumulative())
+	return &stats.ViewData{
+		V:     v,
+		Start: time.Unix(100, 0),
+		End:   time.Unix(160, 0),
+		Rows: []*stats.Row{
+			{
+				Tags:             []tags.Tag{{K: k, V: []byte("get")}},
+				AggregationValue: stats.NewAggregationCountValue(3),
+			},
+		},
+	}
+}
+
+func Test_Exporter_Reconnects(t *testing.T) {
+	a, s, addr := startAgent(t, "127.0.0.1:0")
+	e, err := NewExporter(Options{
+		Address:    addr,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 50 * time.Millisecond,
+		OnError:    func(error) {},
+	})
+	if err != nil {
+		t.Fatalf("NewExporter() got error %v, want no error", err)
+	}
+
+	vd := newViewData(t)
+	if err := e.ExportViewData(vd); err != nil {
+		t.Fatalf("ExportViewData() got error %v, want no error", err)
+	}
+	if err := e.ExportViewData(vd); err != nil {
+		t.Fatalf("ExportViewData() got error %v, want no error", err)
+	}
+	reqs := waitRequests(t, a, 1)
+	var views, data int
+	for _, req := range reqs {
+		views += len(req.Views)
+		data += len(req.ViewData)
+	}
+	for data < 2 {
+		reqs = waitRequests(t, a, len(reqs)+1)
+		data += len(reqs[len(reqs)-1].ViewData)
+		views += len(reqs[len(reqs)-1].Views)
+	}
+	if views != 1 {
+		t.Errorf("got %v view definitions on the stream, want 1", views)
+	}
+	def := reqs[0].Views[0]
+	if def.Name != vd.V.Name() || def.Description != vd.V.Description() || def.Measure != "agent/requests" {
+		t.Errorf("got view definition %v, want the definition of %v", def, vd.V.Name())
+	}
+	got := reqs[0].ViewData[0]
+	if got.View.Name != vd.V.Name() || got.View.Aggregation != nil {
+		t.Errorf("got view %v in the view data, want only the name %v", got.View, vd.V.Name())
+	}
+	rows, err := got.ToRows()
+	if err != nil {
+		t.Fatalf("ToRows() got error %v, want no error", err)
+	}
+	if ok, msg := stats.EqualRows(rows, vd.Rows); !ok {
+		t.Errorf("got unexpected rows: %v", msg)
+	}
+
+	// the data exported while the agent is down is sent, with the view
+	// definition, once it is up again.
+	s.Stop()
+	time.Sleep(100 * time.Millisecond)
+	if err := e.ExportViewData(vd); err != nil {
+		t.Fatalf("ExportViewData() got error %v, want no error", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	a2, s2, _ := startAgent(t, addr)
+	defer s2.Stop()
+	reqs = waitRequests(t, a2, 1)
+	if len(reqs[0].Views) != 1 || len(reqs[0].ViewData) != 1 {
+		t.Errorf("got %v views and %v view data after reconnecting, want 1 and 1", len(reqs[0].Views), len(reqs[0].ViewData))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() got error %v, want no error", err)
+	}
+}
+
+func Test_Exporter_BufferSize(t *testing.T) {
+	// nothing listens on the address, so the data is buffered.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() got error %v, want no error", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	e, err := NewExporter(Options{
+		Address:    addr,
+		BufferSize: 2,
+		MinBackoff: time.Hour,
+		OnError:    func(error) {},
+	})
+	if err != nil {
+		t.Fatalf("NewExporter() got error %v, want no error", err)
+	}
+	vd := newViewData(t)
+	for i := 0; i < 5; i++ {
+		if err := e.ExportViewData(vd); err != nil {
+			t.Fatalf("ExportViewData() got error %v, want no error", err)
+		}
+	}
+	// the first flush may take the buffered data before failing.
+	time.Sleep(100 * time.Millisecond)
+	if got := e.Dropped(); got < 3 {
+		t.Errorf("Dropped() got %v, want at least 3", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	e.Shutdown(ctx)
+}
+
+func Test_NewExporter_NoAddress(t *testing.T) {
+	if _, err := NewExporter(Options{}); err == nil {
+		t.Error("NewExporter() got no error, want error without address")
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package azure exports the data collected for views to Azure Monitor, as the
+// metrics telemetry of Application Insights.
+//
+// Azure Monitor aggregates the custom metrics per minute. The exporter
+// aligns the data on the same intervals: the data received for a view during
+// an interval is aggregated, then sent once the interval ended, timestamped
+// with the start of the interval. The data of the cumulative views is
+// converted to the data aggregated since their previous report, so the views
+// can be reported more often than the interval. The tags of the rows are
+// sent as the custom dimensions of the metrics.
+package azure
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/stats/viewdatadiff"
+)
+
+const (
+	defaultEndpoint            = "https://dc.services.visualstudio.com/v2/track"
+	defaultAggregationInterval = time.Minute
+	defaultBufferSize          = 1024
+	defaultTimeout             = 10 * time.Second
+)
+
+// Options are the options of an Exporter.
+type Options struct {
+	// InstrumentationKey is the instrumentation key of the Application
+	// Insights resource the metrics are sent to.
+	InstrumentationKey string
+
+	// Endpoint is the URL of the track endpoint of Application Insights. It
+	// defaults to https://dc.services.visualstudio.com/v2/track.
+	Endpoint string
+
+	// Client is the HTTP client sending the telemetry. It defaults to a
+	// client with a 10s timeout.
+	Client *http.Client
+
+	// AggregationInterval is the interval the data is aggregated over before
+	// being sent. The intervals are aligned on multiples of
+	// AggregationInterval since the zero time. It defaults to one minute, the
+	// resolution of Azure Monitor.
+	AggregationInterval time.Duration
+
+	// BufferSize is the maximum number of ViewData received from the
+	// subscriptions and not aggregated yet. It defaults to 1024.
+	BufferSize int
+
+	// OnError is called with the errors encountered while sending the data.
+	// The errors are logged if it is nil.
+	OnError func(err error)
+}
+
+// Exporter sends the data collected for views to Azure Monitor.
+type Exporter struct {
+	opts Options
+	c    chan *stats.ViewData
+
+	// mu guards prev, the last ViewData of each view, and intervals, the
+	// data aggregated for each interval not sent yet.
+	mu        sync.Mutex
+	prev      map[stats.View]*stats.ViewData
+	intervals map[time.Time]map[string]*series
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// series is the data of a metric with a set of custom dimensions aggregated
+// over an interval.
+type series struct {
+	name  string
+	props map[string]string
+	p     *point
+}
+
+// NewExporter returns an Exporter sending the data to the Application
+// Insights resource of opts.InstrumentationKey.
+func NewExporter(opts Options) (*Exporter, error) {
+	if opts.InstrumentationKey == "" {
+		return nil, errors.New("cannot create azure exporter without instrumentation key")
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = defaultEndpoint
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: defaultTimeout}
+	}
+	if opts.AggregationInterval <= 0 {
+		opts.AggregationInterval = defaultAggregationInterval
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultBufferSize
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {
+			log.Printf("cannot export stats to azure: %v", err)
+		}
+	}
+	e := &Exporter{
+		opts:      opts,
+		c:         make(chan *stats.ViewData, opts.BufferSize),
+		prev:      make(map[stats.View]*stats.ViewData),
+		intervals: make(map[time.Time]map[string]*series),
+		done:      make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.run()
+	return e, nil
+}
+
+// Subscribe subscribes the exporter to the data collected for v.
+func (e *Exporter) Subscribe(v stats.View) error {
+	return stats.SubscribeToView(v, e.c)
+}
+
+// Unsubscribe unsubscribes the exporter from the data collected for v.
+func (e *Exporter) Unsubscribe(v stats.View) error {
+	return stats.UnsubscribeFromView(v, e.c)
+}
+
+// ExportViewData aggregates vd in the interval of vd.End, e.g. when vd is
+// retrieved with ReadAll instead of a subscription. vd must not be modified
+// afterwards, since it is kept to compute the next data of a cumulative view.
+func (e *Exporter) ExportViewData(vd *stats.ViewData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rows := vd.Rows
+	prev := e.prev[vd.V]
+	e.prev[vd.V] = vd
+	if prev != nil && prev.Start.Equal(vd.Start) {
+		// the data of a cumulative view: only the data aggregated since the
+		// previous ViewData is added to the interval.
+		deltas, err := viewdatadiff.Diff(prev, vd)
+		if err != nil {
+			return err
+		}
+		rows = rows[:0:0]
+		for _, d := range deltas {
+			if d.Kind != viewdatadiff.Removed {
+				rows = append(rows, &stats.Row{Tags: d.Tags, AggregationValue: d.Delta})
+			}
+		}
+	}
+
+	start := vd.End.Truncate(e.opts.AggregationInterval)
+	interval, ok := e.intervals[start]
+	if !ok {
+		interval = make(map[string]*series)
+		e.intervals[start] = interval
+	}
+	for _, r := range rows {
+		props := make(map[string]string, len(r.Tags))
+		for _, t := range r.Tags {
+			props[t.K.Name()] = t.K.ValueAsString(t.V)
+		}
+		points := pointsOf(r.AggregationValue)
+		for i, p := range points {
+			if p == nil {
+				continue
+			}
+			name := metricName(vd.V.Name(), i, len(points))
+			key := seriesKey(name, props)
+			if s, ok := interval[key]; ok {
+				s.p.merge(p)
+				continue
+			}
+			interval[key] = &series{name: name, props: props, p: p}
+		}
+	}
+	return nil
+}
+
+// Flush sends the data aggregated for all the intervals, including the
+// current one.
+func (e *Exporter) Flush() error {
+	return e.send(time.Time{})
+}
+
+// Close sends the data received from the subscriptions and the data
+// aggregated for all the intervals. The exporter must be unsubscribed from
+// the views before.
+func (e *Exporter) Close() error {
+	e.closeOnce.Do(func() { close(e.done) })
+	e.wg.Wait()
+	return e.Flush()
+}
+
+func (e *Exporter) run() {
+	defer e.wg.Done()
+	for {
+		// the data of an interval is sent once it ended.
+		now := time.Now()
+		next := now.Truncate(e.opts.AggregationInterval).Add(e.opts.AggregationInterval)
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case vd := <-e.c:
+			timer.Stop()
+			e.export(vd)
+		case <-timer.C:
+			if err := e.send(next); err != nil {
+				e.opts.OnError(err)
+			}
+		case <-e.done:
+			timer.Stop()
+			for {
+				select {
+				case vd := <-e.c:
+					e.export(vd)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *Exporter) export(vd *stats.ViewData) {
+	if err := e.ExportViewData(vd); err != nil {
+		e.opts.OnError(err)
+	}
+}
+
+// send sends the data of the intervals ended at before, or of all the
+// intervals if before is zero.
+func (e *Exporter) send(before time.Time) error {
+	e.mu.Lock()
+	var starts []time.Time
+	for start := range e.intervals {
+		if before.IsZero() || !start.Add(e.opts.AggregationInterval).After(before) {
+			starts = append(starts, start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	var items []*envelope
+	for _, start := range starts {
+		for _, s := range e.intervals[start] {
+			items = append(items, newEnvelope(e.opts.InstrumentationKey, start, s.p.dataPoint(s.name), s.props))
+		}
+		delete(e.intervals, start)
+	}
+	e.mu.Unlock()
+
+	if len(items) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	resp, err := e.opts.Client.Post(e.opts.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("sending %v metrics to '%v' failed with status %v: %s", len(items), e.opts.Endpoint, resp.Status, bytes.TrimSpace(msg))
+}
+
+// seriesKey returns a string identifying the metric name with the custom
+// dimensions props.
+func seriesKey(name string, props map[string]string) string {
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	buf.WriteString(name)
+	for _, k := range keys {
+		buf.WriteByte(0)
+		buf.WriteString(k)
+		buf.WriteByte(0)
+		buf.WriteString(props[k])
+	}
+	return buf.String()
+}
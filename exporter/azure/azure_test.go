@@ -0,0 +1,132 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package azure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+func TestExporter(t *testing.T) {
+	var mu sync.Mutex
+	var got []*envelope
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var items []*envelope
+		if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+			t.Errorf("decoding the telemetry got error '%v', want no error", err)
+		}
+		mu.Lock()
+		got = append(got, items...)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	e, err := NewExporter(Options{
+		InstrumentationKey: "ikey",
+		Endpoint:           srv.URL,
+		OnError:            func(err error) { t.Errorf("got error '%v', want no error", err) },
+	})
+	if err != nil {
+		t.Fatalf("NewExporter got error '%v', want no error", err)
+	}
+	defer e.Close()
+
+	r := stats.NewRegistry()
+	m, _ := r.NewMeasureFloat64("MF1", "desc MF1", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	agg := stats.NewAggregationMulti(stats.NewAggregationCount(), stats.NewAggregationDistribution([]float64{10}))
+	v := stats.NewView("VF1", "desc VF1", []tags.Key{k1}, m, agg, stats.NewWindowCumulative())
+
+	start := time.Unix(6000, 0)
+	newViewData := func(end time.Time, count int64, dist *stats.AggregationDistributionValue) *stats.ViewData {
+		return &stats.ViewData{
+			V:     v,
+			Start: start,
+			End:   end,
+			Rows: []*stats.Row{{
+				Tags:             []tags.Tag{{K: k1, V: []byte("v1")}},
+				AggregationValue: stats.NewAggregationMultiValue(stats.NewAggregationCountValue(count), dist),
+			}},
+		}
+	}
+	// samples 1 and 3, then 1, 3 and 5 in the first interval, then 1, 3, 5
+	// and 7 in the second one.
+	vds := []*stats.ViewData{
+		newViewData(start.Add(10*time.Second), 2, stats.NewAggregationDistributionValue([]float64{10}, []int64{2, 0}, 2, 1, 3, 2, 2, 0, 0)),
+		newViewData(start.Add(20*time.Second), 3, stats.NewAggregationDistributionValue([]float64{10}, []int64{3, 0}, 3, 1, 5, 3, 8, 0, 0)),
+		newViewData(start.Add(70*time.Second), 4, stats.NewAggregationDistributionValue([]float64{10}, []int64{4, 0}, 4, 1, 7, 4, 20, 0, 0)),
+	}
+	for _, vd := range vds {
+		if err := e.ExportViewData(vd); err != nil {
+			t.Fatalf("ExportViewData got error '%v', want no error", err)
+		}
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatalf("Flush got error '%v', want no error", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	sort.SliceStable(got, func(i, j int) bool {
+		if got[i].Time != got[j].Time {
+			return got[i].Time < got[j].Time
+		}
+		return got[i].Data.BaseData.Metrics[0].Name < got[j].Data.BaseData.Metrics[0].Name
+	})
+	type want struct {
+		time   string
+		name   string
+		value  float64
+		count  int64
+		stdDev float64
+	}
+	wants := []want{
+		{"1970-01-01T01:40:00Z", "VF1_0", 3, 0, 0},
+		{"1970-01-01T01:40:00Z", "VF1_1", 9, 3, 1.632993161855452},
+		{"1970-01-01T01:41:00Z", "VF1_0", 1, 0, 0},
+		{"1970-01-01T01:41:00Z", "VF1_1", 7, 1, 0},
+	}
+	if len(got) != len(wants) {
+		t.Fatalf("got %v metrics, want %v", len(got), len(wants))
+	}
+	for i, w := range wants {
+		g := got[i]
+		p := g.Data.BaseData.Metrics[0]
+		if g.Time != w.time || g.IKey != "ikey" || p.Name != w.name || p.Value != w.value {
+			t.Errorf("metric #%v got %v %v %v %v, want %v", i, g.Time, g.IKey, p.Name, p.Value, w)
+		}
+		if g.Data.BaseData.Properties["k1"] != "v1" {
+			t.Errorf("metric #%v got custom dimensions %v, want k1=v1", i, g.Data.BaseData.Properties)
+		}
+		if w.count == 0 {
+			if p.Kind != kindMeasurement || p.Count != nil {
+				t.Errorf("metric #%v got %+v, want a measurement", i, p)
+			}
+			continue
+		}
+		if p.Kind != kindAggregation || p.Count == nil || *p.Count != w.count || *p.StdDev-w.stdDev > 1e-9 || w.stdDev-*p.StdDev > 1e-9 {
+			t.Errorf("metric #%v got %+v, want an aggregation of count %v and stdDev %v", i, p, w.count, w.stdDev)
+		}
+	}
+}
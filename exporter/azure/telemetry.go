@@ -0,0 +1,196 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package azure
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+)
+
+// envelope is the JSON envelope of a telemetry item sent to the track
+// endpoint of Application Insights.
+type envelope struct {
+	Name string       `json:"name"`
+	Time string       `json:"time"`
+	IKey string       `json:"iKey"`
+	Data envelopeData `json:"data"`
+}
+
+type envelopeData struct {
+	BaseType string     `json:"baseType"`
+	BaseData metricData `json:"baseData"`
+}
+
+type metricData struct {
+	Ver        int               `json:"ver"`
+	Metrics    []dataPoint       `json:"metrics"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// DataPointType of the data points.
+const (
+	kindMeasurement = 0
+	kindAggregation = 1
+)
+
+type dataPoint struct {
+	Name   string   `json:"name"`
+	Kind   int      `json:"kind"`
+	Value  float64  `json:"value"`
+	Count  *int64   `json:"count,omitempty"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+	StdDev *float64 `json:"stdDev,omitempty"`
+}
+
+// point is the data aggregated over an interval for a series, i.e. a metric
+// and a set of custom dimensions.
+type point struct {
+	// count is true for the points of count aggregations, which are sent as a
+	// single measurement of the count over the interval. The other points
+	// are sent as the aggregation of the samples of the interval.
+	count bool
+	n     int64
+	sum   float64
+	min   float64
+	max   float64
+	// m2 is the sum of the squared deviations from the mean.
+	m2 float64
+}
+
+// merge adds the data of other, a point of the same metric, to p.
+func (p *point) merge(other *point) {
+	if p.count {
+		p.sum += other.sum
+		return
+	}
+	n := p.n + other.n
+	delta := other.sum/float64(other.n) - p.sum/float64(p.n)
+	p.m2 += other.m2 + delta*delta*float64(p.n)*float64(other.n)/float64(n)
+	p.n = n
+	p.sum += other.sum
+	p.min = math.Min(p.min, other.min)
+	p.max = math.Max(p.max, other.max)
+}
+
+func (p *point) dataPoint(name string) dataPoint {
+	if p.count {
+		return dataPoint{Name: name, Kind: kindMeasurement, Value: p.sum}
+	}
+	n, min, max, stdDev := p.n, p.min, p.max, math.Sqrt(p.m2/float64(p.n))
+	return dataPoint{
+		Name:   name,
+		Kind:   kindAggregation,
+		Value:  p.sum,
+		Count:  &n,
+		Min:    &min,
+		Max:    &max,
+		StdDev: &stdDev,
+	}
+}
+
+// pointsOf returns the points of an aggregation value, one per value of an
+// AggregationMultiValue. The distributions without samples have a nil point.
+func pointsOf(av stats.AggregationValue) []*point {
+	pv := &pointVisitor{}
+	av.Accept(pv)
+	return pv.points
+}
+
+type pointVisitor struct {
+	points []*point
+}
+
+func (pv *pointVisitor) VisitCount(v *stats.AggregationCountValue) {
+	pv.points = append(pv.points, &point{count: true, sum: float64(*v)})
+}
+
+func (pv *pointVisitor) VisitDistribution(v *stats.AggregationDistributionValue) {
+	if v.Count() == 0 {
+		pv.points = append(pv.points, nil)
+		return
+	}
+	pv.points = append(pv.points, &point{
+		n:   v.Count(),
+		sum: v.Sum(),
+		min: v.Min(),
+		max: v.Max(),
+		m2:  v.SumOfSquaredDeviation(),
+	})
+}
+
+// VisitRate adds the rate as a single sample, so that the rates of an interval
+// are averaged when their points are merged.
+func (pv *pointVisitor) VisitRate(v *stats.AggregationRateValue) {
+	r := float64(*v)
+	pv.points = append(pv.points, &point{n: 1, sum: r, min: r, max: r})
+}
+
+// VisitRatio adds the ratio as a single sample, like VisitRate.
+func (pv *pointVisitor) VisitRatio(v *stats.AggregationRatioValue) {
+	r := float64(*v)
+	pv.points = append(pv.points, &point{n: 1, sum: r, min: r, max: r})
+}
+
+// VisitSum sends the sum as a single measurement over the interval, like
+// VisitCount.
+func (pv *pointVisitor) VisitSum(v *stats.AggregationSumValue) {
+	pv.points = append(pv.points, &point{count: true, sum: float64(*v)})
+}
+
+// VisitMean adds the mean as a single sample, like VisitRate.
+func (pv *pointVisitor) VisitMean(v *stats.AggregationMeanValue) {
+	if v.Count() == 0 {
+		pv.points = append(pv.points, nil)
+		return
+	}
+	m := v.Mean()
+	pv.points = append(pv.points, &point{n: 1, sum: m, min: m, max: m})
+}
+
+func (pv *pointVisitor) VisitMulti(v *stats.AggregationMultiValue) {
+	for _, av := range v.Values() {
+		av.Accept(pv)
+	}
+}
+
+// metricName returns the name of the metric of the i-th point of the view
+// named view having n points per row.
+func metricName(view string, i, n int) string {
+	if n == 1 {
+		return view
+	}
+	return fmt.Sprintf("%v_%v", view, i)
+}
+
+func newEnvelope(iKey string, t time.Time, p dataPoint, props map[string]string) *envelope {
+	return &envelope{
+		Name: "Microsoft.ApplicationInsights.Metric",
+		Time: t.UTC().Format(time.RFC3339Nano),
+		IKey: iKey,
+		Data: envelopeData{
+			BaseType: "MetricData",
+			BaseData: metricData{
+				Ver:        2,
+				Metrics:    []dataPoint{p},
+				Properties: props,
+			},
+		},
+	}
+}
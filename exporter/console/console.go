@@ -0,0 +1,284 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package console prints the data collected for views in aligned tables, for
+// the local development before any backend is configured.
+package console
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+const (
+	defaultMaxRows    = 20
+	defaultBufferSize = 16
+)
+
+// RowOrder is the order of the rows printed for a view.
+type RowOrder int
+
+const (
+	// OrderByTags sorts the rows by their tags, see stats.SortRows. It is
+	// the default order.
+	OrderByTags RowOrder = iota
+	// OrderByValue sorts the rows by decreasing value: the count of the
+	// count aggregations and the sum of the distributions, of the first
+	// aggregation of the AggregationMulti, so that the largest rows are
+	// printed when they don't all fit in MaxRows.
+	OrderByValue
+)
+
+// Options are the options of an Exporter.
+type Options struct {
+	// Writer is where the tables are printed. It defaults to os.Stderr.
+	Writer io.Writer
+
+	// Interval is the minimum interval between two tables printed for the
+	// same view. The data of a view reported sooner is not printed. Zero
+	// prints all the data reported.
+	Interval time.Duration
+
+	// MaxRows is the maximum number of rows printed per table. It defaults
+	// to 20. A negative value prints all the rows.
+	MaxRows int
+
+	// Order is the order of the rows.
+	Order RowOrder
+}
+
+// Exporter prints the data collected for views.
+type Exporter struct {
+	opts Options
+	c    chan *stats.ViewData
+
+	// mu guards the writer and printed, the time each view was last
+	// printed at.
+	mu      sync.Mutex
+	printed map[stats.View]time.Time
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewExporter returns an Exporter printing to opts.Writer.
+func NewExporter(opts Options) *Exporter {
+	if opts.Writer == nil {
+		opts.Writer = os.Stderr
+	}
+	if opts.MaxRows == 0 {
+		opts.MaxRows = defaultMaxRows
+	}
+	e := &Exporter{
+		opts:    opts,
+		c:       make(chan *stats.ViewData, defaultBufferSize),
+		printed: make(map[stats.View]time.Time),
+		done:    make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.receive()
+	return e
+}
+
+// Subscribe subscribes the exporter to the data collected for v.
+func (e *Exporter) Subscribe(v stats.View) error {
+	return stats.SubscribeToView(v, e.c)
+}
+
+// Unsubscribe unsubscribes the exporter from the data collected for v.
+func (e *Exporter) Unsubscribe(v stats.View) error {
+	return stats.UnsubscribeFromView(v, e.c)
+}
+
+// ExportViewData prints vd, unless the data of the same view was printed
+// less than Interval before vd.End.
+func (e *Exporter) ExportViewData(vd *stats.ViewData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if last, ok := e.printed[vd.V]; ok && vd.End.Sub(last) < e.opts.Interval {
+		return nil
+	}
+	e.printed[vd.V] = vd.End
+	_, err := e.opts.Writer.Write(e.format(vd))
+	return err
+}
+
+// Close prints the data received from the subscriptions. The exporter must
+// be unsubscribed from the views before.
+func (e *Exporter) Close() {
+	e.closeOnce.Do(func() { close(e.done) })
+	e.wg.Wait()
+}
+
+func (e *Exporter) receive() {
+	defer e.wg.Done()
+	for {
+		select {
+		case vd := <-e.c:
+			e.ExportViewData(vd)
+		case <-e.done:
+			for {
+				select {
+				case vd := <-e.c:
+					e.ExportViewData(vd)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// format returns the table of vd: a header line with the name of the view and
+// the window, then a column per tag key and a column for the value, whose
+// header holds the unit of the view unless it is dimensionless.
+func (e *Exporter) format(vd *stats.ViewData) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%v [%v, %v] %v rows\n", vd.V.Name(), vd.Start.Format(time.RFC3339), vd.End.Format(time.RFC3339), len(vd.Rows))
+
+	rows := append([]*stats.Row(nil), vd.Rows...)
+	stats.SortRows(rows)
+	if e.opts.Order == OrderByValue {
+		sort.SliceStable(rows, func(i, j int) bool {
+			return magnitude(rows[i].AggregationValue) > magnitude(rows[j].AggregationValue)
+		})
+	}
+	more := 0
+	if e.opts.MaxRows > 0 && len(rows) > e.opts.MaxRows {
+		more = len(rows) - e.opts.MaxRows
+		rows = rows[:e.opts.MaxRows]
+	}
+
+	keys := vd.V.TagKeys()
+	tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%v\t", k.Name())
+	}
+	if u := vd.V.Unit(); u != "" && u != stats.UnitDimensionless {
+		fmt.Fprintf(tw, "value (%v)\n", u)
+	} else {
+		fmt.Fprintln(tw, "value")
+	}
+	for _, r := range rows {
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%v\t", tagValue(r.Tags, k))
+		}
+		fmt.Fprintln(tw, formatValue(r.AggregationValue))
+	}
+	tw.Flush()
+	if more > 0 {
+		fmt.Fprintf(&buf, "... %v more rows\n", more)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// tagValue returns the value of the tag of k in ts, or "-" if there is none.
+func tagValue(ts []tags.Tag, k tags.Key) string {
+	for _, t := range ts {
+		if t.K == k {
+			return k.ValueAsString(t.V)
+		}
+	}
+	return "-"
+}
+
+// valueFormatter formats the aggregation values it visits, e.g.
+// "count=3 sum=12 mean=4 min=1 max=7", and computes the magnitude of the
+// first one for OrderByValue.
+type valueFormatter struct {
+	parts     []string
+	magnitude float64
+}
+
+func (f *valueFormatter) VisitCount(v *stats.AggregationCountValue) {
+	if len(f.parts) == 0 {
+		f.magnitude = float64(*v)
+	}
+	f.parts = append(f.parts, "count="+strconv.FormatInt(int64(*v), 10))
+}
+
+func (f *valueFormatter) VisitDistribution(v *stats.AggregationDistributionValue) {
+	s := fmt.Sprintf("count=%v sum=%v", v.Count(), formatFloat(v.Sum()))
+	if v.Count() > 0 {
+		s += fmt.Sprintf(" mean=%v min=%v max=%v", formatFloat(v.Mean()), formatFloat(v.Min()), formatFloat(v.Max()))
+	}
+	if len(f.parts) == 0 {
+		f.magnitude = v.Sum()
+	}
+	f.parts = append(f.parts, s)
+}
+
+func (f *valueFormatter) VisitRate(v *stats.AggregationRateValue) {
+	if len(f.parts) == 0 {
+		f.magnitude = float64(*v)
+	}
+	f.parts = append(f.parts, "rate="+formatFloat(float64(*v))+"/s")
+}
+
+func (f *valueFormatter) VisitRatio(v *stats.AggregationRatioValue) {
+	if len(f.parts) == 0 {
+		f.magnitude = float64(*v)
+	}
+	f.parts = append(f.parts, "ratio="+formatFloat(float64(*v)))
+}
+
+func (f *valueFormatter) VisitSum(v *stats.AggregationSumValue) {
+	if len(f.parts) == 0 {
+		f.magnitude = float64(*v)
+	}
+	f.parts = append(f.parts, "sum="+formatFloat(float64(*v)))
+}
+
+func (f *valueFormatter) VisitMean(v *stats.AggregationMeanValue) {
+	if len(f.parts) == 0 {
+		f.magnitude = v.Mean()
+	}
+	f.parts = append(f.parts, "mean="+formatFloat(v.Mean()))
+}
+
+func (f *valueFormatter) VisitMulti(v *stats.AggregationMultiValue) {
+	for _, av := range v.Values() {
+		av.Accept(f)
+	}
+}
+
+func formatValue(av stats.AggregationValue) string {
+	f := &valueFormatter{}
+	av.Accept(f)
+	return strings.Join(f.parts, " | ")
+}
+
+func magnitude(av stats.AggregationValue) float64 {
+	f := &valueFormatter{}
+	av.Accept(f)
+	return f.magnitude
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
@@ -0,0 +1,67 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package console
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+func TestExporter(t *testing.T) {
+	r := stats.NewRegistry()
+	m, _ := r.NewMeasureFloat64("MF1", "desc MF1", "1")
+	k1, _ := tags.CreateKeyString("method")
+	k2, _ := tags.CreateKeyString("code")
+	v := stats.NewView("VF1", "desc VF1", []tags.Key{k2, k1}, m, stats.NewAggregationCount(), stats.NewWindowCumulative())
+	newViewData := func(end time.Time) *stats.ViewData {
+		return &stats.ViewData{
+			V:     v,
+			Start: time.Unix(0, 0).UTC(),
+			End:   end,
+			Rows: []*stats.Row{
+				{Tags: []tags.Tag{{K: k2, V: []byte("200")}, {K: k1, V: []byte("GET")}}, AggregationValue: stats.NewAggregationCountValue(3)},
+				{Tags: []tags.Tag{{K: k1, V: []byte("PUT")}}, AggregationValue: stats.NewAggregationCountValue(10)},
+				{Tags: []tags.Tag{{K: k2, V: []byte("500")}, {K: k1, V: []byte("GET")}}, AggregationValue: stats.NewAggregationCountValue(1)},
+			},
+		}
+	}
+
+	var buf bytes.Buffer
+	e := NewExporter(Options{Writer: &buf, Interval: time.Minute, MaxRows: 2, Order: OrderByValue})
+	defer e.Close()
+
+	end := time.Unix(60, 0).UTC()
+	for _, d := range []time.Duration{0, 30 * time.Second, time.Minute} {
+		if err := e.ExportViewData(newViewData(end.Add(d))); err != nil {
+			t.Fatalf("ExportViewData got error '%v', want no error", err)
+		}
+	}
+	table := "VF1 [1970-01-01T00:00:00Z, %v] 3 rows\n" +
+		"code  method  value\n" +
+		"-     PUT     count=10\n" +
+		"200   GET     count=3\n" +
+		"... 1 more rows\n\n"
+	// the data reported 30s after the first one is not printed.
+	want := fmt.Sprintf(table, "1970-01-01T00:01:00Z") + fmt.Sprintf(table, "1970-01-01T00:02:00Z")
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+}
@@ -0,0 +1,416 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package influx exports the data collected for views to InfluxDB, or to the
+// agents accepting the InfluxDB line protocol (e.g. Telegraf), over HTTP or
+// UDP. See Lines for the mapping of the data to the line protocol.
+//
+// The lines are batched, and the batches failing to be written over HTTP are
+// retried with an exponential backoff. The exporter should subscribe to
+// cumulative views so that the data of the batches finally dropped is
+// recovered by the next ones.
+package influx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+)
+
+const (
+	defaultBatchSize      = 5000
+	defaultMaxPayloadSize = 1400
+	defaultFlushInterval  = time.Second
+	defaultBufferSize     = 10000
+	defaultMaxRetries     = 3
+	defaultMinBackoff     = 100 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+	defaultTimeout        = 10 * time.Second
+)
+
+// Options are the options of an Exporter.
+type Options struct {
+	// Address is the URL of the InfluxDB server, e.g.
+	// "http://localhost:8086", or of its UDP listener, e.g.
+	// "udp://localhost:8089".
+	Address string
+
+	// Database and RetentionPolicy are the database and the retention policy
+	// the data is written to over HTTP. Database is required over HTTP. The
+	// database of the UDP listener is configured on the server.
+	Database        string
+	RetentionPolicy string
+
+	// Username and Password authenticate the writes over HTTP, if set.
+	Username, Password string
+
+	// Client is the HTTP client writing the data. It defaults to a client
+	// with a 10s timeout.
+	Client *http.Client
+
+	// BatchSize is the maximum number of lines written per HTTP request. It
+	// defaults to 5000.
+	BatchSize int
+
+	// MaxPayloadSize is the maximum size of the UDP datagrams. The lines are
+	// packed in datagrams up to this size, a longer line being sent alone.
+	// It defaults to 1400 bytes, so that the datagrams are not fragmented on
+	// most networks.
+	MaxPayloadSize int
+
+	// FlushInterval is the maximum delay between the export of data and its
+	// write. The buffered lines are written earlier once there is a full
+	// batch. It defaults to 1s.
+	FlushInterval time.Duration
+
+	// BufferSize is the maximum number of lines buffered. The oldest lines
+	// are dropped when the buffer is full. It defaults to 10000.
+	BufferSize int
+
+	// MaxRetries is the number of times a batch failing to be written over
+	// HTTP is retried before being dropped. The batches rejected by the
+	// server as invalid are not retried. It defaults to 3.
+	MaxRetries int
+
+	// MinBackoff and MaxBackoff bound the delay between the attempts to
+	// write a batch. The delay doubles after each failed attempt. They
+	// default to 100ms and 10s.
+	MinBackoff, MaxBackoff time.Duration
+
+	// OnError is called with the errors encountered while writing the data.
+	// The errors are logged if it is nil.
+	OnError func(err error)
+}
+
+// Exporter writes the data collected for views to InfluxDB in the line
+// protocol.
+type Exporter struct {
+	opts  Options
+	udp   bool
+	write string // the URL of the write endpoint, for HTTP.
+	c     chan *stats.ViewData
+
+	mu      sync.Mutex
+	buf     []string
+	dropped uint64
+
+	// conn is the UDP socket. It is dialed on the first write and dialed
+	// again after a failed write. It is only used by the sending goroutine.
+	conn net.Conn
+
+	// ready is signaled when a full batch is buffered, done is closed by
+	// Close and drained is closed once the data left in the subscriptions
+	// is buffered after done is closed.
+	ready     chan struct{}
+	done      chan struct{}
+	drained   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewExporter returns an Exporter writing the data to the server at
+// opts.Address. The server doesn't need to be reachable when the exporter is
+// created.
+func NewExporter(opts Options) (*Exporter, error) {
+	u, err := url.Parse(opts.Address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create influx exporter for address '%v': %v", opts.Address, err)
+	}
+	e := &Exporter{
+		ready:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		drained: make(chan struct{}),
+	}
+	switch u.Scheme {
+	case "udp":
+		if u.Host == "" {
+			return nil, errors.New("cannot create influx exporter without host")
+		}
+		e.udp = true
+	case "http", "https":
+		if opts.Database == "" {
+			return nil, errors.New("cannot create influx exporter writing over HTTP without database")
+		}
+		q := url.Values{}
+		q.Set("db", opts.Database)
+		if opts.RetentionPolicy != "" {
+			q.Set("rp", opts.RetentionPolicy)
+		}
+		q.Set("precision", "ns")
+		e.write = strings.TrimSuffix(opts.Address, "/") + "/write?" + q.Encode()
+	default:
+		return nil, fmt.Errorf("cannot create influx exporter for address '%v'. The scheme must be http, https or udp", opts.Address)
+	}
+
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: defaultTimeout}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.MaxPayloadSize <= 0 {
+		opts.MaxPayloadSize = defaultMaxPayloadSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultBufferSize
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	} else if opts.MaxRetries == 0 {
+		opts.MaxRetries = defaultMaxRetries
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = defaultMaxBackoff
+		if opts.MaxBackoff < opts.MinBackoff {
+			opts.MaxBackoff = opts.MinBackoff
+		}
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {
+			log.Printf("cannot export stats to influx '%v': %v", opts.Address, err)
+		}
+	}
+	e.opts = opts
+	e.c = make(chan *stats.ViewData, opts.BufferSize)
+
+	e.wg.Add(2)
+	go e.receive()
+	go e.send()
+	return e, nil
+}
+
+// Subscribe subscribes the exporter to the data collected for v.
+func (e *Exporter) Subscribe(v stats.View) error {
+	return stats.SubscribeToView(v, e.c)
+}
+
+// Unsubscribe unsubscribes the exporter from the data collected for v.
+func (e *Exporter) Unsubscribe(v stats.View) error {
+	return stats.UnsubscribeFromView(v, e.c)
+}
+
+// ExportViewData buffers the lines of vd to be written, e.g. when vd is
+// retrieved with ReadAll instead of a subscription. vd is not used after
+// ExportViewData returns.
+func (e *Exporter) ExportViewData(vd *stats.ViewData) error {
+	lines := Lines(vd)
+	e.mu.Lock()
+	e.buf = append(e.buf, lines...)
+	if n := len(e.buf) - e.opts.BufferSize; n > 0 {
+		e.dropped += uint64(n)
+		e.buf = append(e.buf[:0], e.buf[n:]...)
+	}
+	full := len(e.buf) >= e.opts.BatchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.ready <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Dropped returns the number of lines dropped because the buffer was full or
+// because they could not be written.
+func (e *Exporter) Dropped() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.dropped
+}
+
+// Close writes the buffered data and closes the UDP socket. The exporter must
+// be unsubscribed from the views before. Close waits for the retries of the
+// failed writes, which are bounded by MaxRetries and MaxBackoff.
+func (e *Exporter) Close() error {
+	e.closeOnce.Do(func() { close(e.done) })
+	e.wg.Wait()
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
+
+func (e *Exporter) receive() {
+	defer e.wg.Done()
+	defer close(e.drained)
+	for {
+		select {
+		case vd := <-e.c:
+			e.ExportViewData(vd)
+		case <-e.done:
+			for {
+				select {
+				case vd := <-e.c:
+					e.ExportViewData(vd)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *Exporter) send() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.ready:
+		case <-ticker.C:
+		case <-e.done:
+			// receive buffers the data left in the subscriptions before
+			// closing drained.
+			<-e.drained
+			e.flush()
+			return
+		}
+		e.flush()
+	}
+}
+
+// flush writes the buffered lines in batches.
+func (e *Exporter) flush() {
+	e.mu.Lock()
+	lines := e.buf
+	e.buf = nil
+	e.mu.Unlock()
+
+	for _, batch := range e.batches(lines) {
+		if err := e.writeWithRetries(batch); err != nil {
+			e.opts.OnError(err)
+			e.mu.Lock()
+			e.dropped += uint64(batch.lines)
+			e.mu.Unlock()
+		}
+	}
+}
+
+// batch is the payload of a write and the number of lines it holds.
+type batch struct {
+	payload []byte
+	lines   int
+}
+
+// batches splits lines in the payloads of the writes: up to BatchSize lines
+// per HTTP request, or up to MaxPayloadSize bytes per UDP datagram.
+func (e *Exporter) batches(lines []string) []batch {
+	var batches []batch
+	var cur batch
+	var buf bytes.Buffer
+	for _, l := range lines {
+		full := cur.lines >= e.opts.BatchSize
+		if e.udp {
+			full = cur.lines > 0 && buf.Len()+1+len(l) > e.opts.MaxPayloadSize
+		}
+		if full {
+			cur.payload = append([]byte(nil), buf.Bytes()...)
+			batches = append(batches, cur)
+			cur = batch{}
+			buf.Reset()
+		}
+		if cur.lines > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(l)
+		cur.lines++
+	}
+	if cur.lines > 0 {
+		cur.payload = append([]byte(nil), buf.Bytes()...)
+		batches = append(batches, cur)
+	}
+	return batches
+}
+
+// writeWithRetries writes b, retrying with an exponential backoff while the
+// error is temporary, up to MaxRetries times.
+func (e *Exporter) writeWithRetries(b batch) error {
+	backoff := e.opts.MinBackoff
+	for i := 0; ; i++ {
+		retry, err := e.writeBatch(b.payload)
+		if err == nil || !retry || i >= e.opts.MaxRetries {
+			return err
+		}
+		e.opts.OnError(err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > e.opts.MaxBackoff {
+			backoff = e.opts.MaxBackoff
+		}
+	}
+}
+
+// writeBatch writes payload, and returns whether the write may succeed if
+// retried when it fails. The UDP datagrams are not retried, since the server
+// doesn't acknowledge them.
+func (e *Exporter) writeBatch(payload []byte) (retry bool, err error) {
+	if e.udp {
+		if e.conn == nil {
+			u, _ := url.Parse(e.opts.Address)
+			if e.conn, err = net.Dial("udp", u.Host); err != nil {
+				return false, err
+			}
+		}
+		if _, err := e.conn.Write(payload); err != nil {
+			e.conn.Close()
+			e.conn = nil
+			return false, err
+		}
+		return false, nil
+	}
+
+	req, err := http.NewRequest("POST", e.write, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if e.opts.Username != "" || e.opts.Password != "" {
+		req.SetBasicAuth(e.opts.Username, e.opts.Password)
+	}
+	resp, err := e.opts.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("write to '%v' failed with status %v: %s", e.opts.Address, resp.Status, bytes.TrimSpace(msg))
+	// the server rejects invalid data with 4xx statuses, except when it
+	// throttles the writes.
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
@@ -0,0 +1,169 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package influx
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+func newTestViewData(t *testing.T) *stats.ViewData {
+	r := stats.NewRegistry()
+	m, err := r.NewMeasureFloat64("MF1", "desc MF1", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureFloat64 got error '%v', want no error", err)
+	}
+	k1, _ := tags.CreateKeyString("k1")
+	k2, _ := tags.CreateKeyString("k2")
+	agg := stats.NewAggregationMulti(stats.NewAggregationCount(), stats.NewAggregationDistribution([]float64{0, 10}))
+	v := stats.NewView("my view,1", "desc", []tags.Key{k1, k2}, m, agg, stats.NewWindowCumulative())
+	return &stats.ViewData{
+		V:     v,
+		Start: time.Unix(100, 0),
+		End:   time.Unix(160, 0),
+		Rows: []*stats.Row{
+			{
+				Tags: []tags.Tag{{K: k1, V: []byte("a b,c=d")}, {K: k2, V: []byte("")}},
+				AggregationValue: stats.NewAggregationMultiValue(
+					stats.NewAggregationCountValue(3),
+					stats.NewAggregationDistributionValue([]float64{0, 10}, []int64{0, 2, 1}, 3, 1, 20, 5, 1.5, 0, 0),
+				),
+			},
+			{
+				AggregationValue: stats.NewAggregationMultiValue(
+					stats.NewAggregationCountValue(0),
+					stats.NewAggregationDistributionValue([]float64{0, 10}, []int64{0, 0, 0}, 0, 0, 0, 0, 0, 0, 0),
+				),
+			},
+		},
+	}
+}
+
+func TestLines(t *testing.T) {
+	got := Lines(newTestViewData(t))
+	want := []string{
+		`my\ view\,1,k1=a\ b\,c\=d agg0_count=3i,agg1_count=3i,agg1_sum=15,agg1_mean=5,agg1_min=1,agg1_max=20,agg1_le_0=0i,agg1_le_10=2i 160000000000`,
+		`my\ view\,1 agg0_count=0i,agg1_count=0i,agg1_sum=0,agg1_le_0=0i,agg1_le_10=0i 160000000000`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines got\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestExporterHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if r.URL.Path != "/write" || r.URL.Query().Get("db") != "stats" {
+			t.Errorf("got request to %v, want a write to the database stats", r.URL)
+		}
+		if calls == 1 {
+			// the first attempt fails and is retried.
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var errs []error
+	e, err := NewExporter(Options{
+		Address:    srv.URL,
+		Database:   "stats",
+		BatchSize:  1,
+		MinBackoff: time.Millisecond,
+		OnError:    func(err error) { errs = append(errs, err) },
+	})
+	if err != nil {
+		t.Fatalf("NewExporter got error '%v', want no error", err)
+	}
+	vd := newTestViewData(t)
+	if err := e.ExportViewData(vd); err != nil {
+		t.Fatalf("ExportViewData got error '%v', want no error", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close got error '%v', want no error", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := Lines(vd); !reflect.DeepEqual(bodies, want) {
+		t.Errorf("got bodies %q, want one line per request %q", bodies, want)
+	}
+	if len(errs) != 1 {
+		t.Errorf("got errors %v, want the error of the first attempt only", errs)
+	}
+	if e.Dropped() != 0 {
+		t.Errorf("Dropped got %v, want 0", e.Dropped())
+	}
+}
+
+func TestExporterUDP(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket got error '%v', want no error", err)
+	}
+	defer l.Close()
+	e, err := NewExporter(Options{Address: "udp://" + l.LocalAddr().String()})
+	if err != nil {
+		t.Fatalf("NewExporter got error '%v', want no error", err)
+	}
+
+	vd := newTestViewData(t)
+	if err := e.ExportViewData(vd); err != nil {
+		t.Fatalf("ExportViewData got error '%v', want no error", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close got error '%v', want no error", err)
+	}
+	l.SetReadDeadline(time.Now().Add(10 * time.Second))
+	b := make([]byte, 2048)
+	n, _, err := l.ReadFrom(b)
+	if err != nil {
+		t.Fatalf("ReadFrom got error '%v', want no error", err)
+	}
+	if got, want := string(b[:n]), strings.Join(Lines(vd), "\n"); got != want {
+		t.Errorf("got datagram %q, want %q", got, want)
+	}
+}
+
+func TestNewExporterErrors(t *testing.T) {
+	for _, opts := range []Options{
+		{Address: "localhost:8086"},
+		{Address: "http://localhost:8086"},
+		{Address: "udp://"},
+	} {
+		if _, err := NewExporter(opts); err == nil {
+			t.Errorf("NewExporter(%+v) got no error, want error", opts)
+		}
+	}
+}
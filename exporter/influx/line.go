@@ -0,0 +1,154 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package influx
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+)
+
+var (
+	// measurementEscaper escapes the characters which have a meaning in the
+	// measurement of a line.
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", " ")
+	// keyEscaper escapes the characters which have a meaning in the tag
+	// keys, tag values and field keys of a line.
+	keyEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", " ")
+)
+
+// Lines returns the data of vd in the InfluxDB line protocol, one line per
+// row. The measurement is the name of the view, the tags of the row are the
+// tags of the line and the aggregated value is mapped to fields:
+//   - count=<n>i for AggregationCountValue.
+//   - count, sum, mean, min, max and, for each bound b of the buckets, the
+//     cumulative count of the values below b as le_<b>, for
+//     AggregationDistributionValue. mean, min and max are omitted while the
+//     count is 0.
+//   - rate for AggregationRateValue and ratio for AggregationRatioValue.
+//   - sum for AggregationSumValue, and sum, count and mean for
+//     AggregationMeanValue. mean is omitted while the count is 0.
+//   - the fields of the i-th value prefixed by agg<i>_ for
+//     AggregationMultiValue.
+//
+// The timestamp of the lines is vd.End in nanoseconds. The tags with an empty
+// value are omitted since InfluxDB rejects them. The lines don't end with a
+// newline.
+func Lines(vd *stats.ViewData) []string {
+	measurement := measurementEscaper.Replace(vd.V.Name())
+	ts := strconv.FormatInt(vd.End.UnixNano(), 10)
+
+	lines := make([]string, 0, len(vd.Rows))
+	var buf bytes.Buffer
+	for _, r := range vd.Rows {
+		buf.Reset()
+		buf.WriteString(measurement)
+		for _, t := range r.Tags {
+			v := t.K.ValueAsString(t.V)
+			if v == "" {
+				continue
+			}
+			buf.WriteByte(',')
+			buf.WriteString(keyEscaper.Replace(t.K.Name()))
+			buf.WriteByte('=')
+			buf.WriteString(keyEscaper.Replace(v))
+		}
+		buf.WriteByte(' ')
+		fw := &fieldWriter{buf: &buf}
+		r.AggregationValue.Accept(fw)
+		buf.WriteByte(' ')
+		buf.WriteString(ts)
+		lines = append(lines, buf.String())
+	}
+	return lines
+}
+
+// fieldWriter writes the fields of the aggregation values it visits.
+type fieldWriter struct {
+	buf    *bytes.Buffer
+	prefix string
+	n      int
+}
+
+func (fw *fieldWriter) writeField(name, value string) {
+	if fw.n > 0 {
+		fw.buf.WriteByte(',')
+	}
+	fw.n++
+	fw.buf.WriteString(keyEscaper.Replace(fw.prefix + name))
+	fw.buf.WriteByte('=')
+	fw.buf.WriteString(value)
+}
+
+func (fw *fieldWriter) writeInt(name string, i int64) {
+	fw.writeField(name, strconv.FormatInt(i, 10)+"i")
+}
+
+func (fw *fieldWriter) writeFloat(name string, f float64) {
+	fw.writeField(name, strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+func (fw *fieldWriter) VisitCount(v *stats.AggregationCountValue) {
+	fw.writeInt("count", int64(*v))
+}
+
+func (fw *fieldWriter) VisitDistribution(v *stats.AggregationDistributionValue) {
+	fw.writeInt("count", v.Count())
+	fw.writeFloat("sum", v.Sum())
+	if v.Count() > 0 {
+		fw.writeFloat("mean", v.Mean())
+		fw.writeFloat("min", v.Min())
+		fw.writeFloat("max", v.Max())
+	}
+	var cumulative int64
+	counts := v.CountPerBucket()
+	for i, b := range v.Bounds() {
+		cumulative += counts[i]
+		fw.writeInt("le_"+strconv.FormatFloat(b, 'g', -1, 64), cumulative)
+	}
+}
+
+func (fw *fieldWriter) VisitRate(v *stats.AggregationRateValue) {
+	fw.writeFloat("rate", float64(*v))
+}
+
+func (fw *fieldWriter) VisitRatio(v *stats.AggregationRatioValue) {
+	fw.writeFloat("ratio", float64(*v))
+}
+
+func (fw *fieldWriter) VisitSum(v *stats.AggregationSumValue) {
+	fw.writeFloat("sum", float64(*v))
+}
+
+func (fw *fieldWriter) VisitMean(v *stats.AggregationMeanValue) {
+	fw.writeFloat("sum", v.Sum())
+	fw.writeFloat("count", v.Count())
+	if v.Count() > 0 {
+		fw.writeFloat("mean", v.Mean())
+	}
+}
+
+func (fw *fieldWriter) VisitMulti(v *stats.AggregationMultiValue) {
+	prefix := fw.prefix
+	for i, av := range v.Values() {
+		fw.prefix = fmt.Sprintf("%vagg%v_", prefix, i)
+		av.Accept(fw)
+	}
+	fw.prefix = prefix
+}
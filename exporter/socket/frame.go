@@ -0,0 +1,374 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package socket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+// frameVersion is the version of the frame format, written in the first byte
+// of each frame.
+const frameVersion = 1
+
+const (
+	kindCount        = 0
+	kindDistribution = 1
+	kindSum          = 2
+	kindMean         = 3
+	kindRate         = 4
+	kindRatio        = 5
+	kindMulti        = 6
+)
+
+// Frame is the data of a view sent in a datagram. The data of a view is
+// split in several frames if it doesn't fit in a datagram: each frame holds
+// some of the rows.
+//
+// The format of a frame is, with the integers encoded as varints and the
+// floats as 8 bytes in little endian:
+//
+//	frame  = version name start end nkeys key* row*
+//	row    = value* aggval
+//	aggval = kind (count | distribution | sum | mean | rate | ratio | multi)
+//	value  = 0 (the tag is not set) | len+1 bytes
+//	count  = int
+//	distribution = count min max mean sumOfSquaredDev nbounds bound* bucketCount* underflows overflows
+//	sum    = float
+//	mean   = sum count
+//	rate   = float
+//	ratio  = float
+//	multi  = nvalues aggval*
+//
+// A row has one value for each key, and one bucket count more than bounds.
+// The rows extend to the end of the frame.
+type Frame struct {
+	View       string
+	TagKeys    []string
+	Start, End time.Time
+	Rows       []*stats.Row
+}
+
+// Encode encodes the data vd in frames of at most maxSize bytes.
+func Encode(vd *stats.ViewData, maxSize int) ([][]byte, error) {
+	keys := vd.V.TagKeys()
+	var header []byte
+	header = append(header, frameVersion)
+	header = appendString(header, vd.V.Name())
+	header = appendVarint(header, unixNanos(vd.Start))
+	header = appendVarint(header, unixNanos(vd.End))
+	header = appendUvarint(header, uint64(len(keys)))
+	for _, k := range keys {
+		header = appendString(header, k.Name())
+	}
+	if len(header) > maxSize {
+		return nil, fmt.Errorf("cannot encode the data of view '%v' in frames of %v bytes", vd.V.Name(), maxSize)
+	}
+
+	var frames [][]byte
+	frame := append([]byte(nil), header...)
+	var row []byte
+	for _, r := range vd.Rows {
+		var err error
+		if row, err = appendRow(row[:0], r, keys); err != nil {
+			return nil, fmt.Errorf("cannot encode the data of view '%v': %v", vd.V.Name(), err)
+		}
+		if len(header)+len(row) > maxSize {
+			return nil, fmt.Errorf("cannot encode a row of view '%v' in frames of %v bytes", vd.V.Name(), maxSize)
+		}
+		if len(frame)+len(row) > maxSize {
+			frames = append(frames, frame)
+			frame = append([]byte(nil), header...)
+		}
+		frame = append(frame, row...)
+	}
+	return append(frames, frame), nil
+}
+
+func appendRow(b []byte, r *stats.Row, keys []tags.Key) ([]byte, error) {
+	for _, k := range keys {
+		b = appendValue(b, r.Tags, k)
+	}
+	return appendAggregationValue(b, r.AggregationValue)
+}
+
+func appendAggregationValue(b []byte, av stats.AggregationValue) ([]byte, error) {
+	switch av := av.(type) {
+	case *stats.AggregationCountValue:
+		b = append(b, kindCount)
+		b = appendVarint(b, int64(*av))
+	case *stats.AggregationDistributionValue:
+		b = append(b, kindDistribution)
+		b = appendVarint(b, av.Count())
+		for _, f := range []float64{av.Min(), av.Max(), av.Mean(), av.SumOfSquaredDeviation()} {
+			b = appendFloat64(b, f)
+		}
+		bounds := av.Bounds()
+		b = appendUvarint(b, uint64(len(bounds)))
+		for _, f := range bounds {
+			b = appendFloat64(b, f)
+		}
+		for _, n := range av.CountPerBucket() {
+			b = appendVarint(b, n)
+		}
+		b = appendVarint(b, av.Underflows())
+		b = appendVarint(b, av.Overflows())
+	case *stats.AggregationSumValue:
+		b = append(b, kindSum)
+		b = appendFloat64(b, float64(*av))
+	case *stats.AggregationMeanValue:
+		b = append(b, kindMean)
+		b = appendFloat64(b, av.Sum())
+		b = appendFloat64(b, av.Count())
+	case *stats.AggregationRateValue:
+		b = append(b, kindRate)
+		b = appendFloat64(b, float64(*av))
+	case *stats.AggregationRatioValue:
+		b = append(b, kindRatio)
+		b = appendFloat64(b, float64(*av))
+	case *stats.AggregationMultiValue:
+		b = append(b, kindMulti)
+		values := av.Values()
+		b = appendUvarint(b, uint64(len(values)))
+		for _, v := range values {
+			var err error
+			if b, err = appendAggregationValue(b, v); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("cannot encode aggregation value of type '%T'", av)
+	}
+	return b, nil
+}
+
+// appendValue appends the value of the tag of key k in ts.
+func appendValue(b []byte, ts []tags.Tag, k tags.Key) []byte {
+	for _, t := range ts {
+		if t.K == k {
+			b = appendUvarint(b, uint64(len(t.V))+1)
+			return append(b, t.V...)
+		}
+	}
+	return appendUvarint(b, 0)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var n [binary.MaxVarintLen64]byte
+	return append(b, n[:binary.PutUvarint(n[:], v)]...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var n [binary.MaxVarintLen64]byte
+	return append(b, n[:binary.PutVarint(n[:], v)]...)
+}
+
+func appendFloat64(b []byte, f float64) []byte {
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], math.Float64bits(f))
+	return append(b, n[:]...)
+}
+
+func unixNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+var errTruncated = errors.New("truncated frame")
+
+// decoder reads the fields of a frame. It records the first error, after
+// which all reads return zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+	d.b = nil
+}
+
+func (d *decoder) byte() byte {
+	if len(d.b) < 1 {
+		d.fail(errTruncated)
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) bytes(n uint64) []byte {
+	if uint64(len(d.b)) < n {
+		d.fail(errTruncated)
+		return nil
+	}
+	b := d.b[:n:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) float64() float64 {
+	b := d.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
+
+func (d *decoder) time() time.Time {
+	if n := d.varint(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+// Decode decodes a frame encoded by Encode. The tag keys of the rows are
+// created as string keys if they don't exist.
+func Decode(b []byte) (*Frame, error) {
+	d := &decoder{b: b}
+	if v := d.byte(); d.err == nil && v != frameVersion {
+		return nil, fmt.Errorf("cannot decode frame of version %v, want version %v", v, frameVersion)
+	}
+	f := &Frame{}
+	f.View = string(d.bytes(d.uvarint()))
+	f.Start = d.time()
+	f.End = d.time()
+	nkeys := d.uvarint()
+	if nkeys > uint64(len(d.b)) {
+		d.fail(errTruncated)
+	}
+	keys := make([]tags.Key, nkeys)
+	for i := range keys {
+		name := string(d.bytes(d.uvarint()))
+		if d.err != nil {
+			break
+		}
+		k, err := tags.CreateKeyString(name)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+		f.TagKeys = append(f.TagKeys, name)
+	}
+	for d.err == nil && len(d.b) > 0 {
+		f.Rows = append(f.Rows, d.row(keys))
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("cannot decode frame: %v", d.err)
+	}
+	return f, nil
+}
+
+func (d *decoder) row(keys []tags.Key) *stats.Row {
+	r := &stats.Row{}
+	for _, k := range keys {
+		n := d.uvarint()
+		if n == 0 {
+			continue
+		}
+		r.Tags = append(r.Tags, tags.Tag{K: k, V: d.bytes(n - 1)})
+	}
+	sort.Slice(r.Tags, func(i, j int) bool { return r.Tags[i].K.Name() < r.Tags[j].K.Name() })
+	r.AggregationValue = d.aggregationValue()
+	return r
+}
+
+func (d *decoder) aggregationValue() stats.AggregationValue {
+	switch kind := d.byte(); kind {
+	case kindCount:
+		return stats.NewAggregationCountValue(d.varint())
+	case kindDistribution:
+		count := d.varint()
+		min, max, mean, ssd := d.float64(), d.float64(), d.float64(), d.float64()
+		nbounds := d.uvarint()
+		if nbounds > uint64(len(d.b)) {
+			d.fail(errTruncated)
+			return nil
+		}
+		bounds := make([]float64, nbounds)
+		for i := range bounds {
+			bounds[i] = d.float64()
+		}
+		buckets := make([]int64, nbounds+1)
+		for i := range buckets {
+			buckets[i] = d.varint()
+		}
+		underflows, overflows := d.varint(), d.varint()
+		return stats.NewAggregationDistributionValue(bounds, buckets, count, min, max, mean, ssd, underflows, overflows)
+	case kindSum:
+		return stats.NewAggregationSumValue(d.float64())
+	case kindMean:
+		sum, count := d.float64(), d.float64()
+		return stats.NewAggregationMeanValue(sum, count)
+	case kindRate:
+		return stats.NewAggregationRateValue(d.float64())
+	case kindRatio:
+		return stats.NewAggregationRatioValue(d.float64())
+	case kindMulti:
+		n := d.uvarint()
+		if n > uint64(len(d.b)) {
+			d.fail(errTruncated)
+			return nil
+		}
+		values := make([]stats.AggregationValue, n)
+		for i := range values {
+			values[i] = d.aggregationValue()
+		}
+		return stats.NewAggregationMultiValue(values...)
+	default:
+		if d.err == nil {
+			d.fail(fmt.Errorf("unknown aggregation value kind %v", kind))
+		}
+	}
+	return nil
+}
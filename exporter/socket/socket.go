@@ -0,0 +1,190 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package socket exports the data collected for views to a local agent, e.g.
+// a sidecar, over UDP or a unix datagram socket. The data is encoded in a
+// compact binary format (see Frame), so the package has no dependency beyond
+// the standard library and the stats package. It is meant for the binaries
+// which cannot link the heavier exporters, e.g. the one of the package
+// exporter/agent.
+//
+// The datagrams are not acknowledged: the data is lost if the agent doesn't
+// read it. The agent should subscribe to cumulative views so that the data
+// lost is recovered by the next datagrams.
+package socket
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+)
+
+const (
+	defaultNetwork      = "udp"
+	defaultMaxFrameSize = 1400
+	defaultBufferSize   = 1024
+)
+
+// Options are the options of an Exporter.
+type Options struct {
+	// Network is the network of the agent, "udp" or "unixgram". It defaults
+	// to "udp".
+	Network string
+
+	// Address is the address of the agent, e.g. "localhost:8125" or the path
+	// of the socket of the agent.
+	Address string
+
+	// MaxFrameSize is the maximum size of the datagrams. The data of a view
+	// is split in several datagrams if needed. It defaults to 1400 bytes, so
+	// that the datagrams are not fragmented on most networks. It can be
+	// raised for the unix sockets.
+	MaxFrameSize int
+
+	// BufferSize is the maximum number of ViewData received from the
+	// subscriptions and not sent yet. It defaults to 1024.
+	BufferSize int
+
+	// OnError is called with the errors encountered while sending the data.
+	// The errors are logged if it is nil.
+	OnError func(err error)
+}
+
+// Exporter sends the data collected for views to an agent, one or more
+// datagrams per ViewData.
+type Exporter struct {
+	opts Options
+	c    chan *stats.ViewData
+
+	// mu guards conn, which is dialed on the first send and dialed again
+	// after a failed send, so the agent doesn't need to be reachable when
+	// the exporter is created.
+	mu   sync.Mutex
+	conn net.Conn
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewExporter returns an Exporter sending the data to the agent at
+// opts.Address.
+func NewExporter(opts Options) (*Exporter, error) {
+	if opts.Address == "" {
+		return nil, errors.New("cannot create socket exporter without address")
+	}
+	if opts.Network == "" {
+		opts.Network = defaultNetwork
+	}
+	if opts.Network != "udp" && opts.Network != "unixgram" {
+		return nil, errors.New("cannot create socket exporter for network " + opts.Network)
+	}
+	if opts.MaxFrameSize <= 0 {
+		opts.MaxFrameSize = defaultMaxFrameSize
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = defaultBufferSize
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {
+			log.Printf("cannot export stats to '%v': %v", opts.Address, err)
+		}
+	}
+	e := &Exporter{
+		opts: opts,
+		c:    make(chan *stats.ViewData, opts.BufferSize),
+		done: make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.receive()
+	return e, nil
+}
+
+// Subscribe subscribes the exporter to the data collected for v.
+func (e *Exporter) Subscribe(v stats.View) error {
+	return stats.SubscribeToView(v, e.c)
+}
+
+// Unsubscribe unsubscribes the exporter from the data collected for v.
+func (e *Exporter) Unsubscribe(v stats.View) error {
+	return stats.UnsubscribeFromView(v, e.c)
+}
+
+// ExportViewData sends vd to the agent, e.g. when vd is retrieved with
+// ReadAll instead of a subscription.
+func (e *Exporter) ExportViewData(vd *stats.ViewData) error {
+	frames, err := Encode(vd, e.opts.MaxFrameSize)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		if e.conn, err = net.Dial(e.opts.Network, e.opts.Address); err != nil {
+			return err
+		}
+	}
+	for _, f := range frames {
+		if _, err := e.conn.Write(f); err != nil {
+			e.conn.Close()
+			e.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// Close sends the data received from the subscriptions and closes the
+// socket. The exporter must be unsubscribed from the views before.
+func (e *Exporter) Close() error {
+	e.closeOnce.Do(func() { close(e.done) })
+	e.wg.Wait()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
+
+func (e *Exporter) receive() {
+	defer e.wg.Done()
+	for {
+		select {
+		case vd := <-e.c:
+			e.export(vd)
+		case <-e.done:
+			for {
+				select {
+				case vd := <-e.c:
+					e.export(vd)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *Exporter) export(vd *stats.ViewData) {
+	if err := e.ExportViewData(vd); err != nil {
+		e.opts.OnError(err)
+	}
+}
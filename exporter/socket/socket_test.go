@@ -0,0 +1,171 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package socket
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+func newTestViewData(t *testing.T, nrows int) *stats.ViewData {
+	r := stats.NewRegistry()
+	m, err := r.NewMeasureFloat64("MF1", "desc MF1", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureFloat64 got error '%v', want no error", err)
+	}
+	k1, _ := tags.CreateKeyString("k1")
+	k2, _ := tags.CreateKeyString("k2")
+	v := stats.NewView("VF1", "desc VF1", []tags.Key{k1, k2}, m, stats.NewAggregationDistribution([]float64{0, 10}), stats.NewWindowCumulative())
+	vd := &stats.ViewData{
+		V:     v,
+		Start: time.Unix(100, 0),
+		End:   time.Unix(160, 0),
+	}
+	for i := 0; i < nrows; i++ {
+		vd.Rows = append(vd.Rows, &stats.Row{
+			Tags:             []tags.Tag{{K: k1, V: []byte{byte('a' + i)}}},
+			AggregationValue: stats.NewAggregationDistributionValue([]float64{0, 10}, []int64{0, 2, int64(i)}, int64(i)+2, 1, float64(20+i), 5, 1.5, 0, int64(i)),
+		})
+	}
+	return vd
+}
+
+func TestEncodeDecode(t *testing.T) {
+	vd := newTestViewData(t, 20)
+	type testCase struct {
+		label   string
+		maxSize int
+		frames  int
+	}
+	for _, tc := range []testCase{{"one frame", 1400, 1}, {"split", 200, 10}} {
+		frames, err := Encode(vd, tc.maxSize)
+		if err != nil {
+			t.Fatalf("%v: Encode got error '%v', want no error", tc.label, err)
+		}
+		if len(frames) != tc.frames {
+			t.Errorf("%v: Encode got %v frames, want %v", tc.label, len(frames), tc.frames)
+		}
+		var rows []*stats.Row
+		for _, b := range frames {
+			if len(b) > tc.maxSize {
+				t.Errorf("%v: Encode got a frame of %v bytes, want at most %v", tc.label, len(b), tc.maxSize)
+			}
+			f, err := Decode(b)
+			if err != nil {
+				t.Fatalf("%v: Decode got error '%v', want no error", tc.label, err)
+			}
+			if f.View != "VF1" || len(f.TagKeys) != 2 || !f.Start.Equal(vd.Start) || !f.End.Equal(vd.End) {
+				t.Errorf("%v: Decode got frame %+v, want the header of the view data", tc.label, f)
+			}
+			rows = append(rows, f.Rows...)
+		}
+		if ok, msg := stats.EqualRows(rows, vd.Rows); !ok {
+			t.Errorf("%v: got rows %v, want %v. %v", tc.label, rows, vd.Rows, msg)
+		}
+	}
+
+	if _, err := Encode(vd, 20); err == nil {
+		t.Errorf("Encode in frames smaller than a row got no error, want an error")
+	}
+	frames, _ := Encode(vd, 1400)
+	if _, err := Decode(frames[0][:len(frames[0])-3]); err == nil {
+		t.Errorf("Decode of a truncated frame got no error, want an error")
+	}
+}
+
+func TestEncodeDecodeValues(t *testing.T) {
+	r := stats.NewRegistry()
+	m, err := r.NewMeasureFloat64("MF2", "desc MF2", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureFloat64 got error '%v', want no error", err)
+	}
+	k1, _ := tags.CreateKeyString("k1")
+	type testCase struct {
+		label string
+		agg   stats.Aggregation
+		av    stats.AggregationValue
+	}
+	tcs := []testCase{
+		{"sum", stats.NewAggregationSum(), stats.NewAggregationSumValue(12.5)},
+		{"mean", stats.NewAggregationMean(), stats.NewAggregationMeanValue(12.5, 2.5)},
+		{"rate", &stats.AggregationRate{}, stats.NewAggregationRateValue(2.5)},
+		{"ratio", &stats.AggregationRatio{}, stats.NewAggregationRatioValue(0.25)},
+		{
+			"multi",
+			stats.NewAggregationMulti(stats.NewAggregationCount(), stats.NewAggregationDistribution([]float64{0, 10}), stats.NewAggregationSum()),
+			stats.NewAggregationMultiValue(
+				stats.NewAggregationCountValue(3),
+				stats.NewAggregationDistributionValue([]float64{0, 10}, []int64{0, 2, 1}, 3, 1, 12, 6, 62, 0, 0),
+				stats.NewAggregationSumValue(18),
+			),
+		},
+	}
+	for _, tc := range tcs {
+		v := stats.NewView("VF2", "desc VF2", []tags.Key{k1}, m, tc.agg, stats.NewWindowCumulative())
+		vd := &stats.ViewData{
+			V:    v,
+			Rows: []*stats.Row{{Tags: []tags.Tag{{K: k1, V: []byte("a")}}, AggregationValue: tc.av}},
+		}
+		frames, err := Encode(vd, 1400)
+		if err != nil {
+			t.Errorf("%v: Encode got error '%v', want no error", tc.label, err)
+			continue
+		}
+		f, err := Decode(frames[0])
+		if err != nil {
+			t.Errorf("%v: Decode got error '%v', want no error", tc.label, err)
+			continue
+		}
+		if ok, msg := stats.EqualRows(f.Rows, vd.Rows); !ok {
+			t.Errorf("%v: got rows %v, want %v. %v", tc.label, f.Rows, vd.Rows, msg)
+		}
+	}
+}
+
+func TestExporter(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket got error '%v', want no error", err)
+	}
+	defer l.Close()
+	e, err := NewExporter(Options{Address: l.LocalAddr().String()})
+	if err != nil {
+		t.Fatalf("NewExporter got error '%v', want no error", err)
+	}
+	defer e.Close()
+
+	vd := newTestViewData(t, 3)
+	if err := e.ExportViewData(vd); err != nil {
+		t.Fatalf("ExportViewData got error '%v', want no error", err)
+	}
+	l.SetReadDeadline(time.Now().Add(10 * time.Second))
+	b := make([]byte, 2048)
+	n, _, err := l.ReadFrom(b)
+	if err != nil {
+		t.Fatalf("ReadFrom got error '%v', want no error", err)
+	}
+	f, err := Decode(b[:n])
+	if err != nil {
+		t.Fatalf("Decode got error '%v', want no error", err)
+	}
+	if ok, msg := stats.EqualRows(f.Rows, vd.Rows); !ok {
+		t.Errorf("got rows %v, want %v. %v", f.Rows, vd.Rows, msg)
+	}
+}
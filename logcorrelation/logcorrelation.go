@@ -0,0 +1,132 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package logcorrelation adds to the logs the fields identifying the trace
+// and the tags of the request being served, so that the logs, the traces and
+// the stats can be joined downstream.
+package logcorrelation
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"github.com/census-instrumentation/opencensus-go/trace"
+	"golang.org/x/net/context"
+)
+
+// The names of the fields holding the span context.
+const (
+	TraceIDField = "trace_id"
+	SpanIDField  = "span_id"
+	SampledField = "trace_sampled"
+)
+
+// Fields returns the fields correlating the logs of ctx: the trace ID, the
+// span ID and the sampling decision of the current span, if any, and the
+// values of the tags of ctx whose keys are in keys, named after their keys.
+// The values of the tags are strings, int64 or bool according to the type of
+// their keys. Fields returns an empty map if ctx carries none of them.
+func Fields(ctx context.Context, keys ...tags.Key) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if sc, ok := trace.FromContext(ctx); ok && sc.IsValid() {
+		fields[TraceIDField] = sc.TraceID.String()
+		fields[SpanIDField] = sc.SpanID.String()
+		fields[SampledField] = sc.IsSampled()
+	}
+	if len(keys) == 0 {
+		return fields
+	}
+	ts := tags.FromContext(ctx)
+	for _, k := range keys {
+		var v interface{}
+		var err error
+		switch k.(type) {
+		case *tags.KeyInt64:
+			v, err = ts.ValueAsInt64(k)
+		case *tags.KeyBool:
+			v, err = ts.ValueAsBool(k)
+		default:
+			v, err = ts.ValueAsString(k)
+		}
+		if err == nil {
+			fields[k.Name()] = v
+		}
+	}
+	return fields
+}
+
+// Format returns the fields as space separated name=value pairs sorted by
+// name, e.g. "span_id=00f067aa0ba902b7 trace_id=4bf92f3577b34da6a3ce929d0e0e4736
+// trace_sampled=true". The values holding spaces or quotes are quoted.
+func Format(fields map[string]interface{}) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b bytes.Buffer
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		v := fmt.Sprint(fields[name])
+		if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+// Logger adapts a *log.Logger to prefix the messages with the fields of
+// their context.
+type Logger struct {
+	l    *log.Logger
+	keys []tags.Key
+}
+
+// NewLogger returns a Logger writing to l the messages prefixed with the
+// fields returned by Fields for keys. The standard logger is used if l is nil.
+func NewLogger(l *log.Logger, keys ...tags.Key) *Logger {
+	return &Logger{l: l, keys: keys}
+}
+
+// Printf is like log.Printf with the fields of ctx.
+func (l *Logger) Printf(ctx context.Context, format string, v ...interface{}) {
+	l.output(ctx, fmt.Sprintf(format, v...))
+}
+
+// Println is like log.Println with the fields of ctx.
+func (l *Logger) Println(ctx context.Context, v ...interface{}) {
+	l.output(ctx, fmt.Sprintln(v...))
+}
+
+func (l *Logger) output(ctx context.Context, msg string) {
+	if f := Format(Fields(ctx, l.keys...)); f != "" {
+		msg = f + " " + msg
+	}
+	// calldepth 3 reports the caller of Printf or Println.
+	if l.l == nil {
+		log.Output(3, msg)
+		return
+	}
+	l.l.Output(3, msg)
+}
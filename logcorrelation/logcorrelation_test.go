@@ -0,0 +1,66 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package logcorrelation
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"github.com/census-instrumentation/opencensus-go/trace"
+	"golang.org/x/net/context"
+)
+
+func TestFields(t *testing.T) {
+	method, _ := tags.CreateKeyString("lc_method")
+	code, _ := tags.CreateKeyInt64("lc_code")
+	other, _ := tags.CreateKeyString("lc_other")
+	ts := tags.NewTagSetBuilder(nil).
+		InsertString(method, "GET /users").
+		InsertInt64(code, 200).
+		Build()
+	sc := trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}}.WithSampled(true)
+	ctx := trace.NewContext(tags.NewContext(context.Background(), ts), sc)
+
+	got := Fields(ctx, method, code, other)
+	want := map[string]interface{}{
+		"trace_id":      "01000000000000000000000000000000",
+		"span_id":       "0200000000000000",
+		"trace_sampled": true,
+		"lc_method":     "GET /users",
+		"lc_code":       int64(200),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields got %v, want %v", got, want)
+	}
+	if got := Fields(context.Background(), method); len(got) != 0 {
+		t.Errorf("Fields of an empty context got %v, want none", got)
+	}
+
+	wantFormat := `lc_code=200 lc_method="GET /users" span_id=0200000000000000 trace_id=01000000000000000000000000000000 trace_sampled=true`
+	if got := Format(got); got != wantFormat {
+		t.Errorf("Format got %v, want %v", got, wantFormat)
+	}
+
+	var buf bytes.Buffer
+	l := NewLogger(log.New(&buf, "", 0), method)
+	l.Printf(ctx, "served in %vms", 12)
+	if got, want := buf.String(), `lc_method="GET /users" span_id=0200000000000000 trace_id=01000000000000000000000000000000 trace_sampled=true served in 12ms`+"\n"; got != want {
+		t.Errorf("Printf wrote %q, want %q", got, want)
+	}
+}
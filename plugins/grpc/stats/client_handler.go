@@ -53,9 +53,15 @@ func (ch clientHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) co
 	return ctx
 }
 
-// HandleConn processes the connection events.
+// HandleConn processes the connection events. It counts the connections
+// opened and closed.
 func (ch clientHandler) HandleConn(ctx context.Context, s stats.ConnStats) {
-	// Do nothing. This is here to satisfy the interface "google.golang.org/grpc/stats.Handler"
+	switch s.(type) {
+	case *stats.ConnBegin:
+		istats.RecordInt64(ctx, RPCClientConnOpenedCount, 1)
+	case *stats.ConnEnd:
+		istats.RecordInt64(ctx, RPCClientConnClosedCount, 1)
+	}
 }
 
 // TagRPC gets the github.com/census-instrumentation/opencensus-go/tags.TagsSet
@@ -91,8 +97,14 @@ func (ch clientHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) cont
 	}
 
 	ts := tags.FromContext(ctx)
-	encoded := tags.EncodeToFullSignature(ts)
-	ctx = stats.SetTags(ctx, encoded)
+	encoded, err := tags.Encode(ts)
+	if err != nil {
+		if glog.V(2) {
+			glog.Infof("clientHandler.TagRPC failed to encode tags, they won't be propagated to the server. %v", err)
+		}
+	} else {
+		ctx = stats.SetTags(ctx, encoded)
+	}
 
 	tsb := tags.NewTagSetBuilder(ts)
 	tsb.UpsertString(keyService, serviceName)
@@ -131,7 +143,8 @@ func (ch clientHandler) handleRPCOutPayload(ctx context.Context, s *stats.OutPay
 		return
 	}
 
-	istats.RecordInt64(ctx, RPCClientRequestBytes, int64(s.Length))
+	istats.Record(ctx, RPCClientRequestBytes.Is(int64(s.Length)), RPCClientRequestWireBytes.Is(int64(s.WireLength)))
+	d.sent(s.SentTime)
 	atomic.AddUint64(&d.reqCount, 1)
 }
 
@@ -144,7 +157,8 @@ func (ch clientHandler) handleRPCInPayload(ctx context.Context, s *stats.InPaylo
 		return
 	}
 
-	istats.RecordInt64(ctx, RPCClientResponseBytes, int64(s.Length))
+	istats.Record(ctx, RPCClientResponseBytes.Is(int64(s.Length)), RPCClientResponseWireBytes.Is(int64(s.WireLength)))
+	d.received(s.RecvTime)
 	atomic.AddUint64(&d.respCount, 1)
 }
 
@@ -163,6 +177,9 @@ func (ch clientHandler) handleRPCEnd(ctx context.Context, s *stats.End) {
 	measurements = append(measurements, RPCClientResponseCount.Is(int64(d.respCount)))
 	measurements = append(measurements, RPCClientFinishedCount.Is(1))
 	measurements = append(measurements, RPCClientRoundTripLatency.Is(float64(elapsedTime)/float64(time.Millisecond)))
+	if sent, recv := d.firstTimes(); !sent.IsZero() && !recv.IsZero() && !recv.Before(sent) {
+		measurements = append(measurements, RPCClientWireLatency.Is(float64(recv.Sub(sent))/float64(time.Millisecond)))
+	}
 
 	if s.Error != nil {
 		errorCode := s.Error.Error()
@@ -20,6 +20,8 @@ import (
 	"log"
 
 	istats "github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/stats/semconv"
+	sviews "github.com/census-instrumentation/opencensus-go/stats/views"
 	"github.com/census-instrumentation/opencensus-go/tags"
 )
 
@@ -30,22 +32,32 @@ import (
 // all supported languages. Likely a serialized protobuf of these defaults.
 var (
 	// Default client measures
-	RPCClientErrorCount       *istats.MeasureInt64
-	RPCClientRoundTripLatency *istats.MeasureFloat64
-	RPCClientRequestBytes     *istats.MeasureInt64
-	RPCClientResponseBytes    *istats.MeasureInt64
-	RPCClientStartedCount     *istats.MeasureInt64
-	RPCClientFinishedCount    *istats.MeasureInt64
-	RPCClientRequestCount     *istats.MeasureInt64
-	RPCClientResponseCount    *istats.MeasureInt64
+	RPCClientErrorCount        *istats.MeasureInt64
+	RPCClientRoundTripLatency  *istats.MeasureFloat64
+	RPCClientRequestBytes      *istats.MeasureInt64
+	RPCClientResponseBytes     *istats.MeasureInt64
+	RPCClientStartedCount      *istats.MeasureInt64
+	RPCClientFinishedCount     *istats.MeasureInt64
+	RPCClientRequestCount      *istats.MeasureInt64
+	RPCClientResponseCount     *istats.MeasureInt64
+	RPCClientRequestWireBytes  *istats.MeasureInt64
+	RPCClientResponseWireBytes *istats.MeasureInt64
+	RPCClientWireLatency       *istats.MeasureFloat64
+	RPCClientConnOpenedCount   *istats.MeasureInt64
+	RPCClientConnClosedCount   *istats.MeasureInt64
 
 	// Default client views
-	RPCClientErrorCountView       istats.View
-	RPCClientRoundTripLatencyView istats.View
-	RPCClientRequestBytesView     istats.View
-	RPCClientResponseBytesView    istats.View
-	RPCClientRequestCountView     istats.View
-	RPCClientResponseCountView    istats.View
+	RPCClientErrorCountView        istats.View
+	RPCClientRoundTripLatencyView  istats.View
+	RPCClientRequestBytesView      istats.View
+	RPCClientResponseBytesView     istats.View
+	RPCClientRequestCountView      istats.View
+	RPCClientRequestWireBytesView  istats.View
+	RPCClientResponseWireBytesView istats.View
+	RPCClientWireLatencyView       istats.View
+	RPCClientConnOpenedCountView   istats.View
+	RPCClientConnClosedCountView   istats.View
+	RPCClientResponseCountView     istats.View
 
 	RPCClientRoundTripLatencyMinuteView istats.View
 	RPCClientRequestBytesMinuteView     istats.View
@@ -70,31 +82,46 @@ func createDefaultMeasuresClient() {
 	var err error
 
 	// Creating client measures
-	if RPCClientErrorCount, err = istats.NewMeasureInt64("/grpc.io/client/error_count", "RPC Errors", unitCount); err != nil {
+	if RPCClientErrorCount, err = semconv.GRPCClientErrorCount.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure grpc.io/client/error_count. %v", err))
 	}
-	if RPCClientRoundTripLatency, err = istats.NewMeasureFloat64("/grpc.io/client/roundtrip_latency", "RPC roundtrip latency in msecs", unitMillisecond); err != nil {
+	if RPCClientRoundTripLatency, err = semconv.GRPCClientRoundTripLatency.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure grpc.io/client/roundtrip_latency. %v", err))
 	}
-	if RPCClientRequestBytes, err = istats.NewMeasureInt64("/grpc.io/client/request_bytes", "Request bytes", unitByte); err != nil {
+	if RPCClientRequestBytes, err = semconv.GRPCClientRequestBytes.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure grpc.io/client/request_bytes. %v", err))
 	}
-	if RPCClientResponseBytes, err = istats.NewMeasureInt64("/grpc.io/client/response_bytes", "Response bytes", unitByte); err != nil {
+	if RPCClientResponseBytes, err = semconv.GRPCClientResponseBytes.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure grpc.io/client/response_bytes. %v", err))
 	}
-	if RPCClientStartedCount, err = istats.NewMeasureInt64("/grpc.io/client/started_count", "Number of client RPCs (streams) started", unitCount); err != nil {
+	if RPCClientStartedCount, err = semconv.GRPCClientStartedCount.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure rpc/client/started_count. %v", err))
 	}
-	if RPCClientFinishedCount, err = istats.NewMeasureInt64("/grpc.io/client/finished_count", "Number of client RPCs (streams) finished", unitCount); err != nil {
+	if RPCClientFinishedCount, err = semconv.GRPCClientFinishedCount.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure /grpc.io/client/finished_count. %v", err))
 	}
 
-	if RPCClientRequestCount, err = istats.NewMeasureInt64("/grpc.io/client/request_count", "Number of client RPC request messages", unitCount); err != nil {
+	if RPCClientRequestCount, err = semconv.GRPCClientRequestCount.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure rpc/client/request_count. %v", err))
 	}
-	if RPCClientResponseCount, err = istats.NewMeasureInt64("/grpc.io/client/response_count", "Number of client RPC response messages", unitCount); err != nil {
+	if RPCClientResponseCount, err = semconv.GRPCClientResponseCount.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure /grpc.io/client/response_count. %v", err))
 	}
+	if RPCClientRequestWireBytes, err = semconv.GRPCClientRequestWireBytes.Register(nil); err != nil {
+		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure /grpc.io/client/request_wire_bytes. %v", err))
+	}
+	if RPCClientResponseWireBytes, err = semconv.GRPCClientResponseWireBytes.Register(nil); err != nil {
+		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure /grpc.io/client/response_wire_bytes. %v", err))
+	}
+	if RPCClientWireLatency, err = semconv.GRPCClientWireLatency.Register(nil); err != nil {
+		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure /grpc.io/client/wire_latency. %v", err))
+	}
+	if RPCClientConnOpenedCount, err = semconv.GRPCClientConnOpenedCount.Register(nil); err != nil {
+		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure /grpc.io/client/connections_opened. %v", err))
+	}
+	if RPCClientConnClosedCount, err = semconv.GRPCClientConnClosedCount.Register(nil); err != nil {
+		panic(fmt.Sprintf("createDefaultMeasuresClient failed for measure /grpc.io/client/connections_closed. %v", err))
+	}
 }
 
 func registerDefaultViewsClient() {
@@ -112,6 +139,16 @@ func registerDefaultViewsClient() {
 	views = append(views, RPCClientRequestCountView)
 	RPCClientResponseCountView = istats.NewView("grpc.io/client/response_count/distribution_cumulative", "Count of response messages per client RPC", []tags.Key{keyService, keyMethod}, RPCClientResponseCount, aggDistCounts, windowCumulative)
 	views = append(views, RPCClientResponseCountView)
+	RPCClientRequestWireBytesView = istats.NewView("grpc.io/client/request_wire_bytes/distribution_cumulative", "Request bytes on the wire", []tags.Key{keyService, keyMethod}, RPCClientRequestWireBytes, aggDistBytes, windowCumulative)
+	views = append(views, RPCClientRequestWireBytesView)
+	RPCClientResponseWireBytesView = istats.NewView("grpc.io/client/response_wire_bytes/distribution_cumulative", "Response bytes on the wire", []tags.Key{keyService, keyMethod}, RPCClientResponseWireBytes, aggDistBytes, windowCumulative)
+	views = append(views, RPCClientResponseWireBytesView)
+	RPCClientWireLatencyView = istats.NewView("grpc.io/client/wire_latency/distribution_cumulative", "Wire latency in msecs", []tags.Key{keyService, keyMethod}, RPCClientWireLatency, aggDistMillis, windowCumulative)
+	views = append(views, RPCClientWireLatencyView)
+	RPCClientConnOpenedCountView = istats.NewView("grpc.io/client/connections_opened/distribution_cumulative", "Number of client connections opened", nil, RPCClientConnOpenedCount, aggCount, windowCumulative)
+	views = append(views, RPCClientConnOpenedCountView)
+	RPCClientConnClosedCountView = istats.NewView("grpc.io/client/connections_closed/distribution_cumulative", "Number of client connections closed", nil, RPCClientConnClosedCount, aggCount, windowCumulative)
+	views = append(views, RPCClientConnClosedCountView)
 
 	RPCClientRoundTripLatencyMinuteView = istats.NewView("grpc.io/client/roundtrip_latency/minute_interval", "Minute stats for latency in msecs", []tags.Key{keyService, keyMethod}, RPCClientRoundTripLatency, aggDistMillis, windowSlidingMinute)
 	views = append(views, RPCClientRoundTripLatencyMinuteView)
@@ -156,6 +193,7 @@ func registerDefaultViewsClient() {
 			log.Fatalf("init() failed to ForceCollection %v.%v\n", v, err)
 		}
 	}
+	sviews.RegisterSet("grpc/client", views...)
 }
 
 // registerDefaultsClient registers the default metrics (measures and views)
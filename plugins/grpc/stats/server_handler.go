@@ -55,9 +55,15 @@ func (sh serverHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) co
 	return ctx
 }
 
-// HandleConn processes the connection events.
+// HandleConn processes the connection events. It counts the connections
+// opened and closed.
 func (sh serverHandler) HandleConn(ctx context.Context, s stats.ConnStats) {
-	// Do nothing. This is here to satisfy the interface "google.golang.org/grpc/stats.Handler"
+	switch s.(type) {
+	case *stats.ConnBegin:
+		istats.RecordInt64(ctx, RPCServerConnOpenedCount, 1)
+	case *stats.ConnEnd:
+		istats.RecordInt64(ctx, RPCServerConnClosedCount, 1)
+	}
 }
 
 // TagRPC gets the metadata from GRPC context, extracts the encoded tags from
@@ -136,7 +142,8 @@ func (sh serverHandler) handleRPCInPayload(ctx context.Context, s *stats.InPaylo
 		return
 	}
 
-	istats.RecordInt64(ctx, RPCServerRequestBytes, int64(s.Length))
+	istats.Record(ctx, RPCServerRequestBytes.Is(int64(s.Length)), RPCServerRequestWireBytes.Is(int64(s.WireLength)))
+	d.received(s.RecvTime)
 	atomic.AddUint64(&d.reqCount, 1)
 }
 
@@ -149,7 +156,8 @@ func (sh serverHandler) handleRPCOutPayload(ctx context.Context, s *stats.OutPay
 		return
 	}
 
-	istats.RecordInt64(ctx, RPCServerResponseBytes, int64(s.Length))
+	istats.Record(ctx, RPCServerResponseBytes.Is(int64(s.Length)), RPCServerResponseWireBytes.Is(int64(s.WireLength)))
+	d.sent(s.SentTime)
 	atomic.AddUint64(&d.respCount, 1)
 }
 
@@ -168,6 +176,9 @@ func (sh serverHandler) handleRPCEnd(ctx context.Context, s *stats.End) {
 	measurements = append(measurements, RPCServerResponseCount.Is(int64(d.respCount)))
 	measurements = append(measurements, RPCServerFinishedCount.Is(1))
 	measurements = append(measurements, RPCServerServerElapsedTime.Is(float64(elapsedTime)/float64(time.Millisecond)))
+	if sent, recv := d.firstTimes(); !sent.IsZero() && !recv.IsZero() && !sent.Before(recv) {
+		measurements = append(measurements, RPCServerWireLatency.Is(float64(sent.Sub(recv))/float64(time.Millisecond)))
+	}
 	if s.Error != nil {
 		errorCode := s.Error.Error()
 		ts := tags.FromContext(ctx)
@@ -190,7 +201,7 @@ func (sh serverHandler) createTagSet(ctx context.Context, serviceName, methodNam
 	if tagsBin := stats.Tags(ctx); tagsBin == nil {
 		tsb = tags.NewTagSetBuilder(nil)
 	} else {
-		ts, err := tags.DecodeFromFullSignature([]byte(tagsBin))
+		ts, err := tags.Decode([]byte(tagsBin))
 		if err != nil {
 			return nil, fmt.Errorf("serverHandler.createTagSet failed to decode tagsBin: %v. %v", tagsBin, err)
 		}
@@ -18,6 +18,7 @@ package stats
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"golang.org/x/net/context"
 
@@ -74,11 +75,11 @@ func TestServerDefaultCollections(t *testing.T) {
 					func() istats.View { return RPCServerRequestCountView },
 					[]*istats.Row{
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewDoNotUseTestingAggregationDistributionValue(rpcCountBucketBoundaries, []int64{0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 1, 1, 1, 1, 0),
+							AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcCountBucketBoundaries, []int64{0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 1, 1, 1, 1, 0),
 						},
 					},
 				},
@@ -86,11 +87,11 @@ func TestServerDefaultCollections(t *testing.T) {
 					func() istats.View { return RPCServerResponseCountView },
 					[]*istats.Row{
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewDoNotUseTestingAggregationDistributionValue(rpcCountBucketBoundaries, []int64{0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 1, 1, 1, 1, 0),
+							AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcCountBucketBoundaries, []int64{0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 1, 1, 1, 1, 0),
 						},
 					},
 				},
@@ -98,11 +99,11 @@ func TestServerDefaultCollections(t *testing.T) {
 					func() istats.View { return RPCServerRequestBytesView },
 					[]*istats.Row{
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewDoNotUseTestingAggregationDistributionValue(rpcBytesBucketBoundaries, []int64{0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 1, 10, 10, 10, 0),
+							AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcBytesBucketBoundaries, []int64{0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 1, 10, 10, 10, 0),
 						},
 					},
 				},
@@ -110,11 +111,11 @@ func TestServerDefaultCollections(t *testing.T) {
 					func() istats.View { return RPCServerResponseBytesView },
 					[]*istats.Row{
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewDoNotUseTestingAggregationDistributionValue(rpcBytesBucketBoundaries, []int64{0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 1, 10, 10, 10, 0),
+							AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcBytesBucketBoundaries, []int64{0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 1, 10, 10, 10, 0),
 						},
 					},
 				},
@@ -155,12 +156,12 @@ func TestServerDefaultCollections(t *testing.T) {
 					func() istats.View { return RPCServerErrorCountView },
 					[]*istats.Row{
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyOpStatus, []byte("someError")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewTestingAggregationCountValue(1),
+							AggregationValue: istats.NewTestingAggregationCountValue(1),
 						},
 					},
 				},
@@ -168,11 +169,11 @@ func TestServerDefaultCollections(t *testing.T) {
 					func() istats.View { return RPCServerRequestCountView },
 					[]*istats.Row{
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewDoNotUseTestingAggregationDistributionValue(rpcCountBucketBoundaries, []int64{0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 2, 1, 2, 1.5, 0.5),
+							AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcCountBucketBoundaries, []int64{0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 2, 1, 2, 1.5, 0.5),
 						},
 					},
 				},
@@ -180,11 +181,11 @@ func TestServerDefaultCollections(t *testing.T) {
 					func() istats.View { return RPCServerResponseCountView },
 					[]*istats.Row{
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewDoNotUseTestingAggregationDistributionValue(rpcCountBucketBoundaries, []int64{0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 2, 2, 3, 2.5, 0.5),
+							AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcCountBucketBoundaries, []int64{0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 2, 2, 3, 2.5, 0.5),
 						},
 					},
 				},
@@ -238,20 +239,20 @@ func TestServerDefaultCollections(t *testing.T) {
 					func() istats.View { return RPCServerErrorCountView },
 					[]*istats.Row{
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyOpStatus, []byte("someError1")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewTestingAggregationCountValue(1),
+							AggregationValue: istats.NewTestingAggregationCountValue(1),
 						},
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyOpStatus, []byte("someError2")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewTestingAggregationCountValue(1),
+							AggregationValue: istats.NewTestingAggregationCountValue(1),
 						},
 					},
 				},
@@ -259,11 +260,11 @@ func TestServerDefaultCollections(t *testing.T) {
 					func() istats.View { return RPCServerRequestCountView },
 					[]*istats.Row{
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewDoNotUseTestingAggregationDistributionValue(rpcCountBucketBoundaries, []int64{0, 0, 2, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 3, 1, 2, 1.333333333, 0.333333333*2),
+							AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcCountBucketBoundaries, []int64{0, 0, 2, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 3, 1, 2, 1.333333333, 0.333333333*2),
 						},
 					},
 				},
@@ -271,11 +272,11 @@ func TestServerDefaultCollections(t *testing.T) {
 					func() istats.View { return RPCServerResponseCountView },
 					[]*istats.Row{
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewDoNotUseTestingAggregationDistributionValue(rpcCountBucketBoundaries, []int64{0, 0, 0, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 3, 2, 3, 2.666666666, 0.333333333*2),
+							AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcCountBucketBoundaries, []int64{0, 0, 0, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 3, 2, 3, 2.666666666, 0.333333333*2),
 						},
 					},
 				},
@@ -283,11 +284,11 @@ func TestServerDefaultCollections(t *testing.T) {
 					func() istats.View { return RPCServerRequestBytesView },
 					[]*istats.Row{
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewDoNotUseTestingAggregationDistributionValue(rpcBytesBucketBoundaries, []int64{0, 1, 1, 1, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 4, 1, 16384, 4864.25, 59678208.25*3),
+							AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcBytesBucketBoundaries, []int64{0, 1, 1, 1, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 4, 1, 16384, 4864.25, 59678208.25*3),
 						},
 					},
 				},
@@ -295,11 +296,11 @@ func TestServerDefaultCollections(t *testing.T) {
 					func() istats.View { return RPCServerResponseBytesView },
 					[]*istats.Row{
 						{
-							[]tags.Tag{
+							Tags: []tags.Tag{
 								{keyMethod, []byte("method")},
 								{keyService, []byte("package.service")},
 							},
-							istats.NewDoNotUseTestingAggregationDistributionValue(rpcBytesBucketBoundaries, []int64{0, 1, 1, 1, 2, 2, 1, 0, 0, 0, 0, 0, 0, 0, 0}, 8, 1, 65536, 13696.125, 481423542.982143*7),
+							AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcBytesBucketBoundaries, []int64{0, 1, 1, 1, 2, 2, 1, 0, 0, 0, 0, 0, 0, 0, 0}, 8, 1, 65536, 13696.125, 481423542.982143*7),
 						},
 					},
 				},
@@ -357,3 +358,72 @@ func TestServerDefaultCollections(t *testing.T) {
 		}
 	}
 }
+
+func TestServerHandlerWireAndConnections(t *testing.T) {
+	istats.RestartWorker()
+	registerDefaultsServer()
+
+	h := NewServerHandler()
+	ctx := h.TagConn(context.Background(), &stats.ConnTagInfo{})
+	h.HandleConn(ctx, &stats.ConnBegin{})
+	h.HandleConn(ctx, &stats.ConnBegin{})
+	h.HandleConn(ctx, &stats.ConnEnd{})
+
+	start := time.Now()
+	ctx = h.TagRPC(ctx, &stats.RPCTagInfo{FullMethodName: "/package.service/method"})
+	h.HandleRPC(ctx, &stats.InPayload{Length: 10, WireLength: 6, RecvTime: start})
+	h.HandleRPC(ctx, &stats.OutPayload{Length: 2000, WireLength: 1500, SentTime: start.Add(5 * time.Millisecond)})
+	h.HandleRPC(ctx, &stats.OutPayload{Length: 10, WireLength: 6, SentTime: start.Add(8 * time.Millisecond)})
+	h.HandleRPC(ctx, &stats.End{})
+
+	rpcTags := []tags.Tag{
+		{keyMethod, []byte("method")},
+		{keyService, []byte("package.service")},
+	}
+	type wantData struct {
+		v    istats.View
+		rows []*istats.Row
+	}
+	wants := []wantData{
+		{
+			RPCServerConnOpenedCountView,
+			[]*istats.Row{
+				{Tags: nil, AggregationValue: istats.NewTestingAggregationCountValue(2)},
+			},
+		},
+		{
+			RPCServerConnClosedCountView,
+			[]*istats.Row{
+				{Tags: nil, AggregationValue: istats.NewTestingAggregationCountValue(1)},
+			},
+		},
+		{
+			RPCServerRequestWireBytesView,
+			[]*istats.Row{
+				{Tags: rpcTags, AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcBytesBucketBoundaries, []int64{0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 1, 6, 6, 6, 0)},
+			},
+		},
+		{
+			RPCServerResponseWireBytesView,
+			[]*istats.Row{
+				{Tags: rpcTags, AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcBytesBucketBoundaries, []int64{0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 2, 6, 1500, 753, 1116018)},
+			},
+		},
+		{
+			RPCServerWireLatencyView,
+			[]*istats.Row{
+				{Tags: rpcTags, AggregationValue: istats.NewDoNotUseTestingAggregationDistributionValue(rpcMillisBucketBoundaries, []int64{0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 1, 5, 5, 5, 0)},
+			},
+		},
+	}
+	for _, want := range wants {
+		gotRows, err := istats.RetrieveData(want.v)
+		if err != nil {
+			t.Errorf("RetrieveData for %v failed. %v", want.v.Name(), err)
+			continue
+		}
+		if ok, msg := istats.EqualRows(gotRows, want.rows); !ok {
+			t.Errorf("View '%v' got unexpected rows. %v", want.v.Name(), msg)
+		}
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"log"
 
 	istats "github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/stats/semconv"
+	sviews "github.com/census-instrumentation/opencensus-go/stats/views"
 	"github.com/census-instrumentation/opencensus-go/tags"
 )
 
@@ -38,6 +40,11 @@ var (
 	RPCServerFinishedCount     *istats.MeasureInt64
 	RPCServerRequestCount      *istats.MeasureInt64
 	RPCServerResponseCount     *istats.MeasureInt64
+	RPCServerRequestWireBytes  *istats.MeasureInt64
+	RPCServerResponseWireBytes *istats.MeasureInt64
+	RPCServerWireLatency       *istats.MeasureFloat64
+	RPCServerConnOpenedCount   *istats.MeasureInt64
+	RPCServerConnClosedCount   *istats.MeasureInt64
 
 	// Default server views
 	RPCServerErrorCountView        istats.View
@@ -45,6 +52,11 @@ var (
 	RPCServerRequestBytesView      istats.View
 	RPCServerResponseBytesView     istats.View
 	RPCServerRequestCountView      istats.View
+	RPCServerRequestWireBytesView  istats.View
+	RPCServerResponseWireBytesView istats.View
+	RPCServerWireLatencyView       istats.View
+	RPCServerConnOpenedCountView   istats.View
+	RPCServerConnClosedCountView   istats.View
 	RPCServerResponseCountView     istats.View
 
 	RPCServerServerElapsedTimeMinuteView istats.View
@@ -70,31 +82,46 @@ func createDefaultMeasuresServer() {
 	var err error
 
 	// Creating server measures
-	if RPCServerErrorCount, err = istats.NewMeasureInt64("/grpc.io/server/error_count", "RPC Errors", unitCount); err != nil {
+	if RPCServerErrorCount, err = semconv.GRPCServerErrorCount.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure /grpc.io/server/error_count. %v", err))
 	}
-	if RPCServerServerElapsedTime, err = istats.NewMeasureFloat64("/grpc.io/server/server_elapsed_time", "Server elapsed time in msecs", unitMillisecond); err != nil {
+	if RPCServerServerElapsedTime, err = semconv.GRPCServerServerElapsedTime.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure /grpc.io/server/server_elapsed_time. %v", err))
 	}
-	if RPCServerRequestBytes, err = istats.NewMeasureInt64("/grpc.io/server/request_bytes", "Request bytes", unitByte); err != nil {
+	if RPCServerRequestBytes, err = semconv.GRPCServerRequestBytes.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure /grpc.io/server/request_bytes. %v", err))
 	}
-	if RPCServerResponseBytes, err = istats.NewMeasureInt64("/grpc.io/server/response_bytes", "Response bytes", unitByte); err != nil {
+	if RPCServerResponseBytes, err = semconv.GRPCServerResponseBytes.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure /grpc.io/server/response_bytes. %v", err))
 	}
-	if RPCServerStartedCount, err = istats.NewMeasureInt64("/grpc.io/server/started_count", "Number of server RPCs (streams) started", unitCount); err != nil {
+	if RPCServerStartedCount, err = semconv.GRPCServerStartedCount.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure rpc/server/started_count. %v", err))
 	}
-	if RPCServerFinishedCount, err = istats.NewMeasureInt64("/grpc.io/server/finished_count", "Number of server RPCs (streams) finished", unitCount); err != nil {
+	if RPCServerFinishedCount, err = semconv.GRPCServerFinishedCount.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure /grpc.io/server/finished_count. %v", err))
 	}
 
-	if RPCServerRequestCount, err = istats.NewMeasureInt64("/grpc.io/server/request_count", "Number of server RPC request messages", unitCount); err != nil {
+	if RPCServerRequestCount, err = semconv.GRPCServerRequestCount.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure rpc/server/request_count. %v", err))
 	}
-	if RPCServerResponseCount, err = istats.NewMeasureInt64("/grpc.io/server/response_count", "Number of server RPC response messages", unitCount); err != nil {
+	if RPCServerResponseCount, err = semconv.GRPCServerResponseCount.Register(nil); err != nil {
 		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure /grpc.io/server/response_count. %v", err))
 	}
+	if RPCServerRequestWireBytes, err = semconv.GRPCServerRequestWireBytes.Register(nil); err != nil {
+		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure /grpc.io/server/request_wire_bytes. %v", err))
+	}
+	if RPCServerResponseWireBytes, err = semconv.GRPCServerResponseWireBytes.Register(nil); err != nil {
+		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure /grpc.io/server/response_wire_bytes. %v", err))
+	}
+	if RPCServerWireLatency, err = semconv.GRPCServerWireLatency.Register(nil); err != nil {
+		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure /grpc.io/server/wire_latency. %v", err))
+	}
+	if RPCServerConnOpenedCount, err = semconv.GRPCServerConnOpenedCount.Register(nil); err != nil {
+		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure /grpc.io/server/connections_opened. %v", err))
+	}
+	if RPCServerConnClosedCount, err = semconv.GRPCServerConnClosedCount.Register(nil); err != nil {
+		panic(fmt.Sprintf("createDefaultMeasuresServer failed for measure /grpc.io/server/connections_closed. %v", err))
+	}
 }
 
 func registerDefaultViewsServer() {
@@ -112,6 +139,16 @@ func registerDefaultViewsServer() {
 	views = append(views, RPCServerRequestCountView)
 	RPCServerResponseCountView = istats.NewView("grpc.io/server/response_count/distribution_cumulative", "Count of response messages per server RPC", []tags.Key{keyService, keyMethod}, RPCServerResponseCount, aggDistCounts, windowCumulative)
 	views = append(views, RPCServerResponseCountView)
+	RPCServerRequestWireBytesView = istats.NewView("grpc.io/server/request_wire_bytes/distribution_cumulative", "Request bytes on the wire", []tags.Key{keyService, keyMethod}, RPCServerRequestWireBytes, aggDistBytes, windowCumulative)
+	views = append(views, RPCServerRequestWireBytesView)
+	RPCServerResponseWireBytesView = istats.NewView("grpc.io/server/response_wire_bytes/distribution_cumulative", "Response bytes on the wire", []tags.Key{keyService, keyMethod}, RPCServerResponseWireBytes, aggDistBytes, windowCumulative)
+	views = append(views, RPCServerResponseWireBytesView)
+	RPCServerWireLatencyView = istats.NewView("grpc.io/server/wire_latency/distribution_cumulative", "Wire latency in msecs", []tags.Key{keyService, keyMethod}, RPCServerWireLatency, aggDistMillis, windowCumulative)
+	views = append(views, RPCServerWireLatencyView)
+	RPCServerConnOpenedCountView = istats.NewView("grpc.io/server/connections_opened/distribution_cumulative", "Number of server connections opened", nil, RPCServerConnOpenedCount, aggCount, windowCumulative)
+	views = append(views, RPCServerConnOpenedCountView)
+	RPCServerConnClosedCountView = istats.NewView("grpc.io/server/connections_closed/distribution_cumulative", "Number of server connections closed", nil, RPCServerConnClosedCount, aggCount, windowCumulative)
+	views = append(views, RPCServerConnClosedCountView)
 
 	RPCServerServerElapsedTimeMinuteView = istats.NewView("grpc.io/server/server_elapsed_time/minute_interval", "Minute stats for server elapsed time in msecs", []tags.Key{keyService, keyMethod}, RPCServerServerElapsedTime, aggDistMillis, windowSlidingMinute)
 	views = append(views, RPCServerServerElapsedTimeMinuteView)
@@ -156,6 +193,7 @@ func registerDefaultViewsServer() {
 			log.Fatalf("init() failed to ForceCollection %v.%v\n", v, err)
 		}
 	}
+	sviews.RegisterSet("grpc/server", views...)
 }
 
 // registerDefaultsServer registers the default metrics (measures and views)
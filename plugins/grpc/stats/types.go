@@ -19,6 +19,7 @@ package stats
 
 import (
 	"log"
+	"sync"
 	"time"
 
 	istats "github.com/census-instrumentation/opencensus-go/stats"
@@ -36,6 +37,39 @@ type rpcData struct {
 	// application code invoked GRPC code.
 	startTime           time.Time
 	reqCount, respCount uint64
+
+	// firstSent and firstRecv are the times at which the first message of
+	// the RPC was sent and received. They are used to compute the latency
+	// of the RPC on the wire, and are guarded by mu as the messages of
+	// streams can be sent and received concurrently.
+	mu                   sync.Mutex
+	firstSent, firstRecv time.Time
+}
+
+// sent records that a message was sent at t.
+func (d *rpcData) sent(t time.Time) {
+	d.mu.Lock()
+	if d.firstSent.IsZero() {
+		d.firstSent = t
+	}
+	d.mu.Unlock()
+}
+
+// received records that a message was received at t.
+func (d *rpcData) received(t time.Time) {
+	d.mu.Lock()
+	if d.firstRecv.IsZero() {
+		d.firstRecv = t
+	}
+	d.mu.Unlock()
+}
+
+// firstTimes returns the times at which the first message of the RPC was
+// sent and received. They are zero if no message was sent or received.
+func (d *rpcData) firstTimes() (sent, recv time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.firstSent, d.firstRecv
 }
 
 // The following variables define the default hard-coded auxiliary data used by
@@ -50,13 +84,10 @@ var (
 	// C is the channel where the client code can access the collected views.
 	C chan *istats.ViewData
 
-	unitByte             = "By"
-	unitCount            = "1"
-	unitMillisecond      = "ms"
 	slidingTimeSubuckets = 6
 
 	rpcBytesBucketBoundaries  = []float64{0, 1024, 2048, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216, 67108864, 268435456, 1073741824, 4294967296}
-	rpcMillisBucketBoundaries = []float64{0, 1, 2, 3, 4, 5, 6, 8, 10, 13, 16, 20, 25, 30, 40, 50, 65, 80, 100, 130, 160, 200, 250, 300, 400, 500, 650, 800, 1000, 2000, 5000, 10000, 20000, 50000, 100000}
+	rpcMillisBucketBoundaries = istats.DistributionBoundsLatencyDefaults()
 	rpcCountBucketBoundaries  = []float64{0, 1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768, 65536}
 
 	aggCount      = istats.NewAggregationCount()
@@ -0,0 +1,153 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package resource
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// The types of the resources detected by the package.
+const (
+	TypeHost         = "host"
+	TypeK8SContainer = "k8s.io/container"
+	TypeGCEInstance  = "cloud.google.com/gce/instance"
+)
+
+// The labels of the resources detected by the package.
+const (
+	LabelHostName      = "host.name"
+	LabelK8SNamespace  = "k8s.namespace.name"
+	LabelK8SPod        = "k8s.pod.name"
+	LabelK8SContainer  = "k8s.container.name"
+	LabelCloudProject  = "cloud.project.id"
+	LabelCloudZone     = "cloud.zone"
+	LabelGCEInstanceID = "gce.instance.id"
+)
+
+// Detect detects the resource from the environment variables (see FromEnv),
+// Kubernetes, the GCE metadata server and the host, in this order of
+// precedence.
+func Detect(ctx context.Context) (*Resource, error) {
+	return MultiDetector(FromEnv, DetectKubernetes, DetectGCE, DetectHost)(ctx)
+}
+
+// DetectHost detects the host the process runs on.
+func DetectHost(ctx context.Context) (*Resource, error) {
+	name, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get the host name: %v", err)
+	}
+	return &Resource{
+		Type:   TypeHost,
+		Labels: map[string]string{LabelHostName: name},
+	}, nil
+}
+
+// DetectKubernetes detects the container the process runs in when it runs in
+// a Kubernetes pod. The namespace and the container name are read from the
+// NAMESPACE and CONTAINER_NAME environment variables, which must be set with
+// the downward API. It returns nil outside of Kubernetes.
+func DetectKubernetes(ctx context.Context) (*Resource, error) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return nil, nil
+	}
+	labels := make(map[string]string)
+	pod, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get the pod name: %v", err)
+	}
+	labels[LabelK8SPod] = pod
+	if ns := os.Getenv("NAMESPACE"); ns != "" {
+		labels[LabelK8SNamespace] = ns
+	}
+	if c := os.Getenv("CONTAINER_NAME"); c != "" {
+		labels[LabelK8SContainer] = c
+	}
+	return &Resource{Type: TypeK8SContainer, Labels: labels}, nil
+}
+
+// gceMetadataHost is the host of the GCE metadata server. It is overridden by
+// the GCE_METADATA_HOST environment variable.
+var gceMetadataHost = "metadata.google.internal"
+
+// gceMetadataTimeout bounds the requests to the GCE metadata server, which
+// isn't reachable outside of GCE.
+var gceMetadataTimeout = 2 * time.Second
+
+// DetectGCE detects the GCE instance the process runs on by querying the
+// metadata server. It returns nil if the metadata server isn't reachable.
+func DetectGCE(ctx context.Context) (*Resource, error) {
+	host := gceMetadataHost
+	if h := os.Getenv("GCE_METADATA_HOST"); h != "" {
+		host = h
+	}
+	ctx, cancel := context.WithTimeout(ctx, gceMetadataTimeout)
+	defer cancel()
+
+	id, err := gceMetadata(ctx, host, "instance/id")
+	if err != nil {
+		// not running on GCE.
+		return nil, nil
+	}
+	project, err := gceMetadata(ctx, host, "project/project-id")
+	if err != nil {
+		return nil, err
+	}
+	zone, err := gceMetadata(ctx, host, "instance/zone")
+	if err != nil {
+		return nil, err
+	}
+	// the zone is of the form projects/<number>/zones/<zone>.
+	zone = zone[strings.LastIndex(zone, "/")+1:]
+	return &Resource{
+		Type: TypeGCEInstance,
+		Labels: map[string]string{
+			LabelGCEInstanceID: id,
+			LabelCloudProject:  project,
+			LabelCloudZone:     zone,
+		},
+	}, nil
+}
+
+// gceMetadata returns the value of the metadata at path.
+func gceMetadata(ctx context.Context, host, path string) (string, error) {
+	req, err := http.NewRequest("GET", "http://"+host+"/computeMetadata/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cannot get GCE metadata '%v': %v", path, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
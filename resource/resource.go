@@ -0,0 +1,150 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package resource describes the entity (e.g. the host, the container or the
+// cluster) the stats are recorded in, so that the exporters label the time
+// series of all the processes consistently.
+package resource
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// The environment variables FromEnv reads the resource from. EnvVarLabels
+// holds a comma separated list of key=value pairs, in which the values may be
+// quoted, e.g. `zone="us-east1-b",cluster=prod`.
+const (
+	EnvVarType   = "OC_RESOURCE_TYPE"
+	EnvVarLabels = "OC_RESOURCE_LABELS"
+)
+
+// Resource is the entity the stats are recorded in. Type identifies the kind
+// of entity, e.g. "k8s.io/container" or "host", and Labels its identity, e.g.
+// the name of the host or of the pod.
+type Resource struct {
+	Type   string
+	Labels map[string]string
+}
+
+// String returns the type and the labels of r sorted by key.
+func (r *Resource) String() string {
+	if r == nil {
+		return "<nil>"
+	}
+	keys := make([]string, 0, len(r.Labels))
+	for k := range r.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%v=%q", k, r.Labels[k]))
+	}
+	return fmt.Sprintf("%v{%v}", r.Type, strings.Join(pairs, ","))
+}
+
+// Merge returns a new Resource holding the labels of a and of b. The type and
+// the labels of a take precedence over those of b. The type of b is used if a
+// has no type. a and b may be nil.
+func Merge(a, b *Resource) *Resource {
+	if a == nil {
+		a = &Resource{}
+	}
+	if b == nil {
+		b = &Resource{}
+	}
+	res := &Resource{
+		Type:   a.Type,
+		Labels: make(map[string]string, len(a.Labels)+len(b.Labels)),
+	}
+	if res.Type == "" {
+		res.Type = b.Type
+	}
+	for k, v := range b.Labels {
+		res.Labels[k] = v
+	}
+	for k, v := range a.Labels {
+		res.Labels[k] = v
+	}
+	return res
+}
+
+// Detector detects the resource the process runs in. It returns nil if the
+// resource isn't detected, e.g. if the process doesn't run in the environment
+// the detector is for.
+type Detector func(ctx context.Context) (*Resource, error)
+
+// MultiDetector returns a Detector merging the resources detected by ds. The
+// resources detected by the first detectors take precedence. It returns the
+// error of the first detector failing.
+func MultiDetector(ds ...Detector) Detector {
+	return func(ctx context.Context) (*Resource, error) {
+		var res *Resource
+		for _, d := range ds {
+			r, err := d(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if r != nil {
+				res = Merge(res, r)
+			}
+		}
+		return res, nil
+	}
+}
+
+// FromEnv detects the resource from the environment variables EnvVarType and
+// EnvVarLabels. It returns nil if neither is set.
+func FromEnv(ctx context.Context) (*Resource, error) {
+	typ := strings.TrimSpace(os.Getenv(EnvVarType))
+	s := strings.TrimSpace(os.Getenv(EnvVarLabels))
+	if typ == "" && s == "" {
+		return nil, nil
+	}
+	labels, err := ParseLabels(s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %v: %v", EnvVarLabels, err)
+	}
+	return &Resource{Type: typ, Labels: labels}, nil
+}
+
+// ParseLabels parses a comma separated list of key=value pairs, in which the
+// values may be quoted.
+func ParseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return labels, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("label '%v' is not of the form key=value", pair)
+		}
+		k, v := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if k == "" {
+			return nil, fmt.Errorf("label '%v' has no key", pair)
+		}
+		if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+			v = v[1 : len(v)-1]
+		}
+		labels[k] = v
+	}
+	return labels, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package resource
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func Test_ParseLabels(t *testing.T) {
+	type testCase struct {
+		label   string
+		s       string
+		want    map[string]string
+		wantErr bool
+	}
+	tcs := []testCase{
+		{"empty", "", map[string]string{}, false},
+		{"single", "k1=v1", map[string]string{"k1": "v1"}, false},
+		{"quoted and spaces", ` k1 = "v 1" ,k2=v2`, map[string]string{"k1": "v 1", "k2": "v2"}, false},
+		{"empty value", "k1=", map[string]string{"k1": ""}, false},
+		{"no value", "k1", nil, true},
+		{"no key", "=v1", nil, true},
+	}
+	for _, tc := range tcs {
+		got, err := ParseLabels(tc.s)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%v: ParseLabels got error %v, want error %v", tc.label, err, tc.wantErr)
+			continue
+		}
+		if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: ParseLabels got %v, want %v", tc.label, got, tc.want)
+		}
+	}
+}
+
+func Test_FromEnv(t *testing.T) {
+	defer os.Unsetenv(EnvVarType)
+	defer os.Unsetenv(EnvVarLabels)
+
+	os.Unsetenv(EnvVarType)
+	os.Unsetenv(EnvVarLabels)
+	if r, err := FromEnv(context.Background()); r != nil || err != nil {
+		t.Errorf("FromEnv without environment variables got %v, %v, want nil, nil", r, err)
+	}
+
+	os.Setenv(EnvVarType, "t")
+	os.Setenv(EnvVarLabels, `k1="v1",k2=v2`)
+	r, err := FromEnv(context.Background())
+	if err != nil {
+		t.Fatalf("FromEnv got error %v, want no error", err)
+	}
+	if want := (&Resource{Type: "t", Labels: map[string]string{"k1": "v1", "k2": "v2"}}); !reflect.DeepEqual(r, want) {
+		t.Errorf("FromEnv got %v, want %v", r, want)
+	}
+
+	os.Setenv(EnvVarLabels, "k1")
+	if _, err := FromEnv(context.Background()); err == nil {
+		t.Errorf("FromEnv with invalid labels got no error, want error")
+	}
+}
+
+func Test_MultiDetector(t *testing.T) {
+	d1 := func(ctx context.Context) (*Resource, error) {
+		return &Resource{Labels: map[string]string{"k1": "d1"}}, nil
+	}
+	d2 := func(ctx context.Context) (*Resource, error) {
+		return nil, nil
+	}
+	d3 := func(ctx context.Context) (*Resource, error) {
+		return &Resource{Type: "t3", Labels: map[string]string{"k1": "d3", "k2": "d3"}}, nil
+	}
+	r, err := MultiDetector(d1, d2, d3)(context.Background())
+	if err != nil {
+		t.Fatalf("MultiDetector got error %v, want no error", err)
+	}
+	want := &Resource{Type: "t3", Labels: map[string]string{"k1": "d1", "k2": "d3"}}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("MultiDetector got %v, want %v", r, want)
+	}
+	if got, want := r.String(), `t3{k1="d1",k2="d3"}`; got != want {
+		t.Errorf("String got %v, want %v", got, want)
+	}
+}
+
+func Test_DetectGCE(t *testing.T) {
+	metadata := map[string]string{
+		"/computeMetadata/v1/instance/id":        "123",
+		"/computeMetadata/v1/project/project-id": "p",
+		"/computeMetadata/v1/instance/zone":      "projects/456/zones/us-east1-b",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, ok := metadata[r.URL.Path]
+		if !ok || r.Header.Get("Metadata-Flavor") != "Google" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(v))
+	}))
+	defer srv.Close()
+	defer func(host string) { gceMetadataHost = host }(gceMetadataHost)
+	gceMetadataHost = strings.TrimPrefix(srv.URL, "http://")
+
+	r, err := DetectGCE(context.Background())
+	if err != nil {
+		t.Fatalf("DetectGCE got error %v, want no error", err)
+	}
+	want := &Resource{
+		Type: TypeGCEInstance,
+		Labels: map[string]string{
+			LabelGCEInstanceID: "123",
+			LabelCloudProject:  "p",
+			LabelCloudZone:     "us-east1-b",
+		},
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("DetectGCE got %v, want %v", r, want)
+	}
+
+	delete(metadata, "/computeMetadata/v1/instance/id")
+	if r, err := DetectGCE(context.Background()); r != nil || err != nil {
+		t.Errorf("DetectGCE outside of GCE got %v, %v, want nil, nil", r, err)
+	}
+}
@@ -15,8 +15,20 @@
 
 package stats
 
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
 // Aggregation is the generic interface for all aggregtion types.
 type Aggregation interface {
+	// SupportsFractionalEviction returns true if the values of the
+	// aggregation are scaled by the fraction of the oldest sub-interval (or
+	// sub-set) still covered by a sliding window, so that the data of the
+	// window approximates the data of exactly its duration. The values of
+	// the other aggregations include the whole oldest sub-interval.
+	SupportsFractionalEviction() bool
 	isAggregation() bool
 	aggregationValueConstructor() func() AggregationValue
 }
@@ -29,6 +41,10 @@ func NewAggregationCount() *AggregationCount {
 	return &AggregationCount{}
 }
 
+// SupportsFractionalEviction returns true: the count of the oldest
+// sub-interval is scaled, and rounded to the nearest integer.
+func (a *AggregationCount) SupportsFractionalEviction() bool { return true }
+
 func (a *AggregationCount) isAggregation() bool { return true }
 
 func (a *AggregationCount) aggregationValueConstructor() func() AggregationValue {
@@ -54,8 +70,33 @@ type AggregationDistribution struct {
 	// if len(Bounds) == 1 then there is no finite buckets, and that single
 	// element is the common boundary of the overflow and underflow buckets.
 	bounds []float64
+
+	// underflow and overflow select how the samples below the first bound
+	// and at or above the last bound are handled. See WithOutOfRange.
+	underflow, overflow OutOfRange
+
+	// isInt64 is true for the distributions created with
+	// NewAggregationDistributionInt64.
+	isInt64 bool
 }
 
+// OutOfRange selects how a distribution handles the samples outside of its
+// bounds.
+type OutOfRange int
+
+const (
+	// OutOfRangeBucket counts the samples below the first bound in the
+	// underflow bucket (-infinity, bounds[0]), and the samples at or above the
+	// last bound in the overflow bucket [bounds[len(bounds)-1], +infinity).
+	// It is the default.
+	OutOfRangeBucket OutOfRange = iota
+	// OutOfRangeCount doesn't aggregate the samples outside of the bounds.
+	// They are only counted by the Underflows or Overflows of the
+	// distribution value, and the underflow or overflow bucket stays empty.
+	// It is meant for the systems whose histograms must have finite buckets.
+	OutOfRangeCount
+)
+
 // NewAggregationDistribution creates a new aggregation of type distribution
 // a.k.a histogram. The buckets boundaries for that histogram are defined by
 // bounds. It defines len(Bounds)+1 buckets.
@@ -71,19 +112,288 @@ type AggregationDistribution struct {
 // [-infinity, bounds[i]) for i = 0
 // [bounds[i-1], bounds[i]) for 0 < i < len(Bounds)
 // [bounds[i-1], +infinity) for i = len(Bounds)
+//
+// The bounds are normalized: they are sorted, and the duplicates and the NaN
+// and infinite values are dropped. ValidateDistributionBounds reports the
+// bounds that need to be normalized.
 func NewAggregationDistribution(bounds []float64) *AggregationDistribution {
 	var copyBounds []float64
 	for _, b := range bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) {
+			continue
+		}
 		copyBounds = append(copyBounds, b)
 	}
+	sort.Float64s(copyBounds)
+	var normalized []float64
+	for i, b := range copyBounds {
+		if i == 0 || b != copyBounds[i-1] {
+			normalized = append(normalized, b)
+		}
+	}
 
 	return &AggregationDistribution{
-		bounds: copyBounds,
+		bounds: normalized,
+	}
+}
+
+// NewAggregationDistributionInt64 is like NewAggregationDistribution, but the
+// sum, min and max of the samples are kept as int64, so they are exact for
+// measures like byte counts which would be rounded by float64 arithmetic.
+// They are only converted to float64 when read with Sum, Min and Max, e.g. by
+// exporters. SumInt64, MinInt64 and MaxInt64 return their exact values. The
+// samples of float64 measures are rounded to the nearest int64.
+func NewAggregationDistributionInt64(bounds []int64) *AggregationDistribution {
+	fbounds := make([]float64, len(bounds))
+	for i, b := range bounds {
+		fbounds[i] = float64(b)
+	}
+	a := NewAggregationDistribution(fbounds)
+	a.isInt64 = true
+	return a
+}
+
+// IsInt64 returns true if the distribution was created with
+// NewAggregationDistributionInt64.
+func (a *AggregationDistribution) IsInt64() bool {
+	return a.isInt64
+}
+
+// ValidateDistributionBounds returns an error if bounds are not strictly
+// increasing finite values, i.e. if NewAggregationDistribution would need to
+// normalize them. Negative bounds are valid.
+func ValidateDistributionBounds(bounds []float64) error {
+	for i, b := range bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) {
+			return fmt.Errorf("cannot use '%v' as a distribution bound because it is not finite", b)
+		}
+		if i > 0 && b <= bounds[i-1] {
+			return fmt.Errorf("cannot use the distribution bounds '%v' because they are not strictly increasing", bounds)
+		}
 	}
+	return nil
 }
 
+// latencyDefaultsBounds are the bounds returned by
+// DistributionBoundsLatencyDefaults.
+var latencyDefaultsBounds = []float64{0, 1, 2, 3, 4, 5, 6, 8, 10, 13, 16, 20, 25, 30, 40, 50, 65, 80, 100, 130, 160, 200, 250, 300, 400, 500, 650, 800, 1000, 2000, 5000, 10000, 20000, 50000, 100000}
+
+// DistributionBoundsLatencyDefaults returns the standard bounds of the
+// distributions of latencies in milliseconds, from 1ms to 100s. Using the
+// same bounds across services allows merging their distributions.
+func DistributionBoundsLatencyDefaults() []float64 {
+	return append([]float64(nil), latencyDefaultsBounds...)
+}
+
+// BoundsExponential returns distribution bounds growing exponentially, like
+// the buckets of an HDR histogram: min, min*growthFactor,
+// min*growthFactor^2, and so on while the bounds are below max, followed by
+// max. The relative error of the distribution is then the same for small and
+// large values, e.g. BoundsExponential(0.1, 60000, 1.25) covers latencies
+// from 100µs to a minute in milliseconds with 61 bounds. It panics unless
+// 0 < min < max and growthFactor > 1.
+func BoundsExponential(min, max, growthFactor float64) []float64 {
+	if !(min > 0 && min < max && growthFactor > 1) || math.IsInf(max, 0) {
+		panic(fmt.Sprintf("stats: invalid exponential bounds with min %v, max %v and growth factor %v", min, max, growthFactor))
+	}
+	var bounds []float64
+	for i := 0; ; i++ {
+		b := min * math.Pow(growthFactor, float64(i))
+		if b >= max {
+			break
+		}
+		bounds = append(bounds, b)
+	}
+	return append(bounds, max)
+}
+
+// Underflow returns how the distribution handles the samples below its first
+// bound.
+func (a *AggregationDistribution) Underflow() OutOfRange {
+	return a.underflow
+}
+
+// Overflow returns how the distribution handles the samples at or above its
+// last bound.
+func (a *AggregationDistribution) Overflow() OutOfRange {
+	return a.overflow
+}
+
+// Bounds returns a copy of the bucket boundaries of the distribution.
+func (a *AggregationDistribution) Bounds() []float64 {
+	var copyBounds []float64
+	for _, b := range a.bounds {
+		copyBounds = append(copyBounds, b)
+	}
+	return copyBounds
+}
+
+// SupportsFractionalEviction returns false: the buckets, min and max of the
+// oldest sub-interval can't be scaled, so the distribution includes all its
+// samples.
+func (a *AggregationDistribution) SupportsFractionalEviction() bool { return false }
+
 func (a *AggregationDistribution) isAggregation() bool { return true }
 
 func (a *AggregationDistribution) aggregationValueConstructor() func() AggregationValue {
-	return func() AggregationValue { return newAggregationDistributionValue(a.bounds) }
+	return func() AggregationValue {
+		av := newAggregationDistributionValue(a.bounds)
+		av.underflow = a.underflow
+		av.overflow = a.overflow
+		if a.isInt64 {
+			av.setInt64()
+		}
+		return av
+	}
+}
+
+// AggregationMulti indicates that several aggregations are computed over the
+// same samples, e.g. a count and a distribution. A view with an
+// AggregationMulti aggregates each sample once per aggregation, but it is
+// registered, collected and reported like a single view. Its values are
+// AggregationMultiValue.
+type AggregationMulti struct {
+	aggs []Aggregation
+}
+
+// NewAggregationMulti creates a new aggregation computing all the aggregations
+// aggs. The nested multi aggregations are flattened.
+func NewAggregationMulti(aggs ...Aggregation) *AggregationMulti {
+	a := &AggregationMulti{}
+	for _, agg := range aggs {
+		if m, ok := agg.(*AggregationMulti); ok {
+			a.aggs = append(a.aggs, m.aggs...)
+			continue
+		}
+		a.aggs = append(a.aggs, agg)
+	}
+	return a
+}
+
+// Aggregations returns a copy of the aggregations computed, in the order of
+// the values of the AggregationMultiValue.
+func (a *AggregationMulti) Aggregations() []Aggregation {
+	var aggs []Aggregation
+	for _, agg := range a.aggs {
+		aggs = append(aggs, agg)
+	}
+	return aggs
+}
+
+// SupportsFractionalEviction returns true if all the aggregations computed
+// support it. Each aggregation is scaled according to its own semantics.
+func (a *AggregationMulti) SupportsFractionalEviction() bool {
+	for _, agg := range a.aggs {
+		if !agg.SupportsFractionalEviction() {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *AggregationMulti) isAggregation() bool { return true }
+
+func (a *AggregationMulti) aggregationValueConstructor() func() AggregationValue {
+	constructors := make([]func() AggregationValue, len(a.aggs))
+	for i, agg := range a.aggs {
+		constructors[i] = agg.aggregationValueConstructor()
+	}
+	return func() AggregationValue {
+		av := &AggregationMultiValue{values: make([]AggregationValue, len(constructors))}
+		for i, c := range constructors {
+			av.values[i] = c()
+		}
+		return av
+	}
+}
+
+// AggregationRate is the aggregation of the views created with NewRateView.
+// Their rows hold an AggregationRateValue, the number of samples per second
+// recorded between two collections.
+type AggregationRate struct{}
+
+// SupportsFractionalEviction returns false: the rate views don't have sliding
+// windows.
+func (a *AggregationRate) SupportsFractionalEviction() bool { return false }
+
+func (a *AggregationRate) isAggregation() bool { return true }
+
+func (a *AggregationRate) aggregationValueConstructor() func() AggregationValue {
+	return func() AggregationValue { return newAggregationRateValue(0) }
+}
+
+// AggregationRatio is the aggregation of the views created with
+// NewRatioView. Their rows hold an AggregationRatioValue, the ratio of the
+// rows of two views.
+type AggregationRatio struct{}
+
+// SupportsFractionalEviction returns false: the ratios are computed from the
+// values of the numerator and denominator views, scaled according to their
+// own aggregations.
+func (a *AggregationRatio) SupportsFractionalEviction() bool { return false }
+
+func (a *AggregationRatio) isAggregation() bool { return true }
+
+func (a *AggregationRatio) aggregationValueConstructor() func() AggregationValue {
+	return func() AggregationValue { return newAggregationRatioValue(0) }
+}
+
+// AggregationSum indicates that the desired aggregation is the sum of the
+// samples. Its values are AggregationSumValue.
+type AggregationSum struct{}
+
+// NewAggregationSum creates a new aggregation of type sum.
+func NewAggregationSum() *AggregationSum {
+	return &AggregationSum{}
+}
+
+// SupportsFractionalEviction returns true: the sum of the oldest sub-interval
+// is scaled, as if its samples were spread evenly over the sub-interval.
+func (a *AggregationSum) SupportsFractionalEviction() bool { return true }
+
+func (a *AggregationSum) isAggregation() bool { return true }
+
+func (a *AggregationSum) aggregationValueConstructor() func() AggregationValue {
+	return func() AggregationValue { return newAggregationSumValue(0) }
+}
+
+// AggregationMean indicates that the desired aggregation is the mean of the
+// samples. Its values are AggregationMeanValue.
+type AggregationMean struct{}
+
+// NewAggregationMean creates a new aggregation of type mean.
+func NewAggregationMean() *AggregationMean {
+	return &AggregationMean{}
+}
+
+// SupportsFractionalEviction returns true: the samples of the oldest
+// sub-interval are weighted by the fraction of the sub-interval covered, so
+// the mean of the window is the weighted mean of its samples.
+func (a *AggregationMean) SupportsFractionalEviction() bool { return true }
+
+func (a *AggregationMean) isAggregation() bool { return true }
+
+func (a *AggregationMean) aggregationValueConstructor() func() AggregationValue {
+	return func() AggregationValue { return &AggregationMeanValue{} }
+}
+
+// withOutOfRange returns a with its distributions handling the samples out of
+// range according to the modes set with WithOutOfRange.
+func withOutOfRange(a Aggregation, underflow, overflow OutOfRange) Aggregation {
+	switch a := a.(type) {
+	case *AggregationDistribution:
+		return &AggregationDistribution{
+			bounds:    a.bounds,
+			underflow: underflow,
+			overflow:  overflow,
+			isInt64:   a.isInt64,
+		}
+	case *AggregationMulti:
+		m := &AggregationMulti{}
+		for _, agg := range a.aggs {
+			m.aggs = append(m.aggs, withOutOfRange(agg, underflow, overflow))
+		}
+		return m
+	}
+	return a
 }
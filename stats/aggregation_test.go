@@ -0,0 +1,334 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_AggregationDistribution_Bounds(t *testing.T) {
+	type testCase struct {
+		label   string
+		bounds  []float64
+		want    []float64
+		wantErr bool
+	}
+	tcs := []testCase{
+		{"no bounds", nil, nil, false},
+		{"sorted", []float64{-10, 0, 10}, []float64{-10, 0, 10}, false},
+		{"unsorted", []float64{10, -10, 0}, []float64{-10, 0, 10}, true},
+		{"duplicates", []float64{0, 10, 10}, []float64{0, 10}, true},
+		{"NaN", []float64{0, math.NaN(), 10}, []float64{0, 10}, true},
+		{"infinite", []float64{math.Inf(-1), 0, math.Inf(1)}, []float64{0}, true},
+	}
+	for _, tc := range tcs {
+		if got := NewAggregationDistribution(tc.bounds).Bounds(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: Bounds got %v, want %v", tc.label, got, tc.want)
+		}
+		if err := ValidateDistributionBounds(tc.bounds); (err != nil) != tc.wantErr {
+			t.Errorf("%v: ValidateDistributionBounds got error '%v', want error %v", tc.label, err, tc.wantErr)
+		}
+	}
+}
+
+func Test_AggregationDistributionValue_Merge(t *testing.T) {
+	// the distributions of the samples {1, 3} and {5, 7} with the bounds
+	// {4}, and of {5, 7} with the bounds {4, 6}.
+	dist1 := NewDoNotUseTestingAggregationDistributionValue([]float64{4}, []int64{2, 0}, 2, 1, 3, 2, 2)
+	dist2 := NewDoNotUseTestingAggregationDistributionValue([]float64{4}, []int64{0, 2}, 2, 5, 7, 6, 2)
+	fine := NewDoNotUseTestingAggregationDistributionValue([]float64{4, 6}, []int64{0, 1, 1}, 2, 5, 7, 6, 2)
+	other := NewDoNotUseTestingAggregationDistributionValue([]float64{5}, []int64{0, 2}, 2, 5, 7, 6, 2)
+	want := NewDoNotUseTestingAggregationDistributionValue([]float64{4}, []int64{2, 2}, 4, 1, 7, 4, 20)
+
+	type testCase struct {
+		label     string
+		other     *AggregationDistributionValue
+		rebucket  bool
+		wantError bool
+	}
+	tcs := []testCase{
+		{"same bounds", dist2, false, false},
+		{"same bounds rebucketed", dist2, true, false},
+		{"finer bounds", fine, false, true},
+		{"finer bounds rebucketed", fine, true, false},
+		{"incompatible bounds rebucketed", other, true, true},
+	}
+	for _, tc := range tcs {
+		merge := dist1.Merge
+		if tc.rebucket {
+			merge = dist1.MergeRebucketed
+		}
+		got, err := merge(tc.other)
+		if tc.wantError {
+			if err == nil {
+				t.Errorf("%v: got no error, want error", tc.label)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: got error '%v', want no error", tc.label, err)
+		}
+		if !got.equal(want, DefaultEqualityEpsilon) {
+			t.Errorf("%v: got %v, want %v", tc.label, got, want)
+		}
+	}
+	if dist1.Count() != 2 {
+		t.Errorf("Merge modified the merged distribution, got count %v, want 2", dist1.Count())
+	}
+}
+
+func Test_BoundsExponential(t *testing.T) {
+	type testCase struct {
+		label                  string
+		min, max, growthFactor float64
+		want                   []float64
+	}
+	tcs := []testCase{
+		{"max is a power", 1, 100, 10, []float64{1, 10, 100}},
+		{"max isn't a power", 1, 50, 10, []float64{1, 10, 50}},
+		{"fractional", 0.5, 4, 2, []float64{0.5, 1, 2, 4}},
+	}
+	for _, tc := range tcs {
+		got := BoundsExponential(tc.min, tc.max, tc.growthFactor)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: BoundsExponential got %v, want %v", tc.label, got, tc.want)
+		}
+		if err := ValidateDistributionBounds(got); err != nil {
+			t.Errorf("%v: ValidateDistributionBounds got error '%v', want no error", tc.label, err)
+		}
+	}
+	if got := len(BoundsExponential(0.1, 60000, 1.25)); got != 61 {
+		t.Errorf("BoundsExponential(0.1, 60000, 1.25) got %v bounds, want 61", got)
+	}
+
+	for _, args := range [][3]float64{{0, 10, 2}, {10, 1, 2}, {1, 10, 1}, {1, math.Inf(1), 2}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("BoundsExponential%v didn't panic", args)
+				}
+			}()
+			BoundsExponential(args[0], args[1], args[2])
+		}()
+	}
+}
+
+func Test_DistributionBoundsLatencyDefaults(t *testing.T) {
+	bounds := DistributionBoundsLatencyDefaults()
+	if err := ValidateDistributionBounds(bounds); err != nil {
+		t.Errorf("ValidateDistributionBounds got error '%v', want no error", err)
+	}
+	bounds[0] = -1
+	if got := DistributionBoundsLatencyDefaults()[0]; got != 0 {
+		t.Errorf("modifying the returned bounds modified the defaults, got first bound %v, want 0", got)
+	}
+}
+
+func Test_AggregationDistributionInt64(t *testing.T) {
+	a := NewAggregationDistributionInt64([]int64{10, 1 << 40})
+	if !a.IsInt64() || !reflect.DeepEqual(a.Bounds(), []float64{10, 1 << 40}) {
+		t.Fatalf("got distribution %+v, want an int64 distribution with bounds [10 2^40]", a)
+	}
+	newValue := a.aggregationValueConstructor()
+
+	// 2^53+1 can't be represented as a float64.
+	big := int64(1<<53) + 1
+	av := newValue().(*AggregationDistributionValue)
+	av.addSample(big)
+	av.addSample(int64(1))
+	if got, want := av.SumInt64(), big+1; got != want {
+		t.Errorf("SumInt64 got %v, want %v", got, want)
+	}
+	if av.MinInt64() != 1 || av.MaxInt64() != big {
+		t.Errorf("got min %v and max %v, want 1 and %v", av.MinInt64(), av.MaxInt64(), big)
+	}
+	if got, want := av.Sum(), float64(big+1); got != want {
+		t.Errorf("Sum got %v, want %v", got, want)
+	}
+
+	other := newValue().(*AggregationDistributionValue)
+	other.addSample(2.6)
+	merged, err := av.Merge(other)
+	if err != nil {
+		t.Fatalf("Merge got error '%v', want no error", err)
+	}
+	if got, want := merged.SumInt64(), big+4; got != want {
+		t.Errorf("merged SumInt64 got %v, want %v", got, want)
+	}
+	delta, err := AggregationValueDelta(merged, av)
+	if err != nil {
+		t.Fatalf("AggregationValueDelta got error '%v', want no error", err)
+	}
+	if d := delta.(*AggregationDistributionValue); !d.IsInt64() || d.Count() != 1 || d.SumInt64() != 3 {
+		t.Errorf("got delta %v with sum %v, want an int64 delta of count 1 and sum 3", d, d.SumInt64())
+	}
+	if _, err := av.Merge(NewAggregationDistribution([]float64{10, 1 << 40}).aggregationValueConstructor()().(*AggregationDistributionValue)); err == nil {
+		t.Error("Merge with a float64 distribution got no error, want error")
+	}
+
+	av.clear()
+	if av.Count() != 0 || av.SumInt64() != 0 || av.MinInt64() != math.MaxInt64 || !av.IsInt64() {
+		t.Errorf("got %v after clear, want an empty int64 distribution", av)
+	}
+}
+
+func Test_EqualWithTolerance(t *testing.T) {
+	// the distributions of the samples {1, 3} with means differing by 1e-12
+	// and 1e-6.
+	dist := NewDoNotUseTestingAggregationDistributionValue([]float64{4}, []int64{2, 0}, 2, 1, 3, 2, 2)
+	near := NewDoNotUseTestingAggregationDistributionValue([]float64{4}, []int64{2, 0}, 2, 1, 3, 2+1e-12, 2)
+	far := NewDoNotUseTestingAggregationDistributionValue([]float64{4}, []int64{2, 0}, 2, 1, 3, 2+1e-6, 2)
+	// a large mean only differing by a relative 1e-12.
+	large := NewDoNotUseTestingAggregationDistributionValue([]float64{4}, []int64{0, 2}, 2, 1e9, 1e9, 1e9, 0)
+	largeClose := NewDoNotUseTestingAggregationDistributionValue([]float64{4}, []int64{0, 2}, 2, 1e9, 1e9, 1e9*(1+1e-12), 0)
+
+	type testCase struct {
+		label string
+		a, b  AggregationValue
+		eps   float64
+		want  bool
+	}
+	tcs := []testCase{
+		{"exact", dist, near, 0, false},
+		{"within the default epsilon", dist, near, DefaultEqualityEpsilon, true},
+		{"beyond the default epsilon", dist, far, DefaultEqualityEpsilon, false},
+		{"within a larger epsilon", dist, far, 1e-5, true},
+		{"relative to the magnitude", large, largeClose, DefaultEqualityEpsilon, true},
+		{"counts", newAggregationCountValue(2), newAggregationCountValue(3), 1, false},
+		{"nil", nil, nil, 0, true},
+		{"nil and non nil", nil, dist, 0, false},
+	}
+	for _, tc := range tcs {
+		if got := EqualWithTolerance(tc.a, tc.b, tc.eps); got != tc.want {
+			t.Errorf("%v: EqualWithTolerance got %v, want %v", tc.label, got, tc.want)
+		}
+	}
+
+	rows1 := []*Row{{Tags: nil, AggregationValue: dist}}
+	rows2 := []*Row{{Tags: nil, AggregationValue: far}}
+	if ok, _ := EqualRows(rows1, rows2); ok {
+		t.Errorf("EqualRows with the default epsilon got true, want false")
+	}
+	SetEqualityEpsilon(1e-5)
+	defer SetEqualityEpsilon(-1)
+	if ok, msg := EqualRows(rows1, rows2); !ok {
+		t.Errorf("EqualRows with an epsilon of 1e-5 got false, want true. %v", msg)
+	}
+}
+
+func Test_AggregationValue_BinaryRoundTrip(t *testing.T) {
+	dist := NewAggregationDistributionValue([]float64{1, 10}, []int64{1, 2, 3}, 6, 0.5, 12, 5.25, 80.5, 1, 2)
+	distInt64 := NewAggregationDistributionInt64([]int64{5}).aggregationValueConstructor()().(*AggregationDistributionValue)
+	for _, v := range []int64{3, 7, 9} {
+		distInt64.addSample(v)
+	}
+	values := []AggregationValue{
+		NewAggregationCountValue(42),
+		dist,
+		distInt64,
+		newAggregationDistributionValue(nil),
+		NewAggregationMultiValue(NewAggregationCountValue(3), dist),
+		NewAggregationRateValue(2.5),
+		NewAggregationRatioValue(0.25),
+		NewAggregationSumValue(-7.5),
+		NewAggregationMeanValue(12, 3),
+	}
+	for _, av := range values {
+		b, err := av.(interface {
+			MarshalBinary() ([]byte, error)
+		}).MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(%v) got error '%v', want no error", av, err)
+		}
+		got, err := UnmarshalAggregationValue(b)
+		if err != nil {
+			t.Fatalf("UnmarshalAggregationValue(%v) got error '%v', want no error", av, err)
+		}
+		if !reflect.DeepEqual(got, av) {
+			t.Errorf("round trip of %#v got %#v", av, got)
+		}
+		if _, err := UnmarshalAggregationValue(b[:len(b)-1]); err == nil {
+			t.Errorf("UnmarshalAggregationValue of truncated %v got no error, want error", av)
+		}
+	}
+
+	if err := new(AggregationCountValue).UnmarshalBinary([]byte{1, 5, 0, 0, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Error("UnmarshalBinary of a ratio into a count got no error, want error")
+	}
+
+	// gob encodes the values held by the interface AggregationValue through
+	// their binary encoding.
+	rows := []*Row{{AggregationValue: values[4]}, {AggregationValue: values[2]}}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(rows); err != nil {
+		t.Fatalf("gob Encode got error '%v', want no error", err)
+	}
+	var decoded []*Row
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatalf("gob Decode got error '%v', want no error", err)
+	}
+	if !reflect.DeepEqual(decoded, rows) {
+		t.Errorf("gob round trip of %v got %v", rows, decoded)
+	}
+}
+
+func Test_AggregationSlidingTime_FractionalEviction(t *testing.T) {
+	// The window of 10s has 2 sub-intervals of 5s. The data is collected 2s
+	// into the third sub-interval, so 3/5 of the oldest one is still covered.
+	type testCase struct {
+		label          string
+		agg            Aggregation
+		wantFractional bool
+		want           AggregationValue
+	}
+	dist := newAggregationDistributionValue([]float64{15})
+	for _, v := range []float64{10, 20, 40} {
+		dist.addSample(v)
+	}
+	tcs := []testCase{
+		{"count", NewAggregationCount(), true, newAggregationCountValue(3)},
+		{"sum", NewAggregationSum(), true, newAggregationSumValue(10*0.6 + 20 + 40)},
+		{"mean", NewAggregationMean(), true, NewAggregationMeanValue(10*0.6+20+40, 0.6+2)},
+		// the distribution includes all the samples of the oldest
+		// sub-interval.
+		{"distribution", NewAggregationDistribution([]float64{15}), false, dist},
+		{"multi", NewAggregationMulti(NewAggregationSum(), NewAggregationCount()), true, NewAggregationMultiValue(newAggregationSumValue(66), newAggregationCountValue(3))},
+		{"multi with distribution", NewAggregationMulti(NewAggregationSum(), NewAggregationDistribution(nil)), false, nil},
+	}
+
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range tcs {
+		if got := tc.agg.SupportsFractionalEviction(); got != tc.wantFractional {
+			t.Errorf("%v: SupportsFractionalEviction() = %v, want %v", tc.label, got, tc.wantFractional)
+		}
+		if tc.want == nil {
+			continue
+		}
+		a := newAggregatorSlidingTime(now, 10*time.Second, 2, false, tc.agg.aggregationValueConstructor())
+		a.addSample(float64(10), now)
+		a.addSample(float64(20), now.Add(11*time.Second))
+		a.addSample(float64(40), now.Add(11*time.Second))
+		got := a.retrieveCollected(now.Add(12 * time.Second))
+		if !got.equal(tc.want, DefaultEqualityEpsilon) {
+			t.Errorf("%v: retrieveCollected got %v, want %v", tc.label, got, tc.want)
+		}
+	}
+}
@@ -18,12 +18,17 @@ package stats
 import (
 	"fmt"
 	"math"
+	"sync/atomic"
 )
 
 // AggregationValue is the interface for all types of aggregations values.
 type AggregationValue interface {
 	String() string
-	equal(other AggregationValue) bool
+	// Accept calls the method of v for the type of the value.
+	Accept(v AggregationValueVisitor)
+	// equal compares the floating-point values with the tolerance eps, see
+	// EqualWithTolerance.
+	equal(other AggregationValue, eps float64) bool
 	isAggregate() bool
 	addSample(v interface{})
 	multiplyByFraction(fraction float64) AggregationValue
@@ -31,6 +36,51 @@ type AggregationValue interface {
 	clear()
 }
 
+// DefaultEqualityEpsilon is the default tolerance of the comparisons of the
+// floating-point values of the aggregated data by Row.Equal, ContainsRow and
+// EqualRows. See SetEqualityEpsilon.
+const DefaultEqualityEpsilon = 1e-9
+
+var epsilonBits = math.Float64bits(DefaultEqualityEpsilon)
+
+// SetEqualityEpsilon sets the tolerance of the comparisons of the
+// floating-point values of the aggregated data by Row.Equal, ContainsRow and
+// EqualRows, e.g. to compare distributions merged in different orders on
+// different machines, whose means and variances differ by rounding errors.
+// Zero makes the comparisons exact, and a negative eps restores
+// DefaultEqualityEpsilon.
+func SetEqualityEpsilon(eps float64) {
+	if eps < 0 {
+		eps = DefaultEqualityEpsilon
+	}
+	atomic.StoreUint64(&epsilonBits, math.Float64bits(eps))
+}
+
+func equalityEpsilon() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&epsilonBits))
+}
+
+// EqualWithTolerance returns true if a and b hold the same aggregated data,
+// the floating-point values (e.g. the mean, variance, min and max of the
+// distributions) being equal if they differ by at most eps, relatively to
+// their magnitude when it is larger than 1. The counts are compared exactly.
+func EqualWithTolerance(a, b AggregationValue, eps float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.equal(b, eps)
+}
+
+// floatsEqual returns true if x and y differ by at most eps, relatively to
+// their magnitude when it is larger than 1. See EqualWithTolerance.
+func floatsEqual(x, y, eps float64) bool {
+	if x == y {
+		return true
+	}
+	scale := math.Max(1, math.Max(math.Abs(x), math.Abs(y)))
+	return math.Abs(x-y) <= eps*scale
+}
+
 // AggregationCountValue is the aggregated data for an AggregationCountInt64.
 type AggregationCountValue int64
 
@@ -40,6 +90,12 @@ func NewTestingAggregationCountValue(v int64) *AggregationCountValue {
 	return newAggregationCountValue(v)
 }
 
+// NewAggregationCountValue returns an AggregationCountValue holding the count
+// v, e.g. to decode a value exported by another process.
+func NewAggregationCountValue(v int64) *AggregationCountValue {
+	return newAggregationCountValue(v)
+}
+
 func newAggregationCountValue(v int64) *AggregationCountValue {
 	tmp := AggregationCountValue(v)
 	return &tmp
@@ -68,7 +124,7 @@ func (a *AggregationCountValue) clear() {
 	*a = 0
 }
 
-func (a *AggregationCountValue) equal(other AggregationValue) bool {
+func (a *AggregationCountValue) equal(other AggregationValue, eps float64) bool {
 	a2, ok := other.(*AggregationCountValue)
 	if !ok {
 		return false
@@ -106,6 +162,18 @@ type AggregationDistributionValue struct {
 	// bounds are the same as the ones setup in AggregationDistribution.
 	countPerBucket []int64
 	bounds         []float64
+
+	// underflow and overflow are the modes of the distribution, and
+	// underflows and overflows count the samples that weren't aggregated
+	// because of these modes.
+	underflow, overflow   OutOfRange
+	underflows, overflows int64
+
+	// isInt64 is true for the values of the distributions created with
+	// NewAggregationDistributionInt64, which keep the exact sum, min and max
+	// of their samples in sumInt64, minInt64 and maxInt64.
+	isInt64                      bool
+	sumInt64, minInt64, maxInt64 int64
 }
 
 // NewDoNotUseTestingAggregationDistributionValue allows to initialize a new
@@ -123,6 +191,23 @@ func NewDoNotUseTestingAggregationDistributionValue(bounds []float64, countPerBu
 	}
 }
 
+// NewAggregationDistributionValue returns an AggregationDistributionValue
+// holding the given data, e.g. to decode a value exported by another process.
+// underflows and overflows are the numbers of samples that weren't aggregated
+// because of the option WithOutOfRange.
+func NewAggregationDistributionValue(bounds []float64, countPerBucket []int64, count int64, min, max, mean, sumOfSquaredDev float64, underflows, overflows int64) *AggregationDistributionValue {
+	a := NewDoNotUseTestingAggregationDistributionValue(bounds, countPerBucket, count, min, max, mean, sumOfSquaredDev)
+	if underflows != 0 {
+		a.underflow = OutOfRangeCount
+		a.underflows = underflows
+	}
+	if overflows != 0 {
+		a.overflow = OutOfRangeCount
+		a.overflows = overflows
+	}
+	return a
+}
+
 func newAggregationDistributionValue(bounds []float64) *AggregationDistributionValue {
 	return &AggregationDistributionValue{
 		countPerBucket: make([]int64, len(bounds)+1),
@@ -132,20 +217,70 @@ func newAggregationDistributionValue(bounds []float64) *AggregationDistributionV
 	}
 }
 
+// setInt64 makes a, which must be empty, keep the exact sum, min and max of
+// its samples as int64.
+func (a *AggregationDistributionValue) setInt64() {
+	a.isInt64 = true
+	a.minInt64 = math.MaxInt64
+	a.maxInt64 = math.MinInt64
+}
+
+// copyInt64 copies the int64 sum, min and max of other to a.
+func (a *AggregationDistributionValue) copyInt64(other *AggregationDistributionValue) {
+	a.isInt64 = other.isInt64
+	a.sumInt64 = other.sumInt64
+	a.minInt64 = other.minInt64
+	a.maxInt64 = other.maxInt64
+}
+
 // Count returns the count of all samples collected.
 func (a *AggregationDistributionValue) Count() int64 { return a.count }
 
 // Min returns the min of all samples collected.
-func (a *AggregationDistributionValue) Min() float64 { return a.min }
+func (a *AggregationDistributionValue) Min() float64 {
+	if a.isInt64 {
+		return float64(a.minInt64)
+	}
+	return a.min
+}
 
 // Mean returns the mean of all samples collected.
-func (a *AggregationDistributionValue) Mean() float64 { return a.mean }
+func (a *AggregationDistributionValue) Mean() float64 {
+	if a.isInt64 && a.count > 0 {
+		return float64(a.sumInt64) / float64(a.count)
+	}
+	return a.mean
+}
 
 // Max returns the max of all samples collected.
-func (a *AggregationDistributionValue) Max() float64 { return a.max }
+func (a *AggregationDistributionValue) Max() float64 {
+	if a.isInt64 {
+		return float64(a.maxInt64)
+	}
+	return a.max
+}
 
 // Sum returns the sum of all samples collected.
-func (a *AggregationDistributionValue) Sum() float64 { return a.mean * float64(a.count) }
+func (a *AggregationDistributionValue) Sum() float64 {
+	if a.isInt64 {
+		return float64(a.sumInt64)
+	}
+	return a.mean * float64(a.count)
+}
+
+// IsInt64 returns true if the value is the value of a distribution created
+// with NewAggregationDistributionInt64. SumInt64, MinInt64 and MaxInt64 are
+// only meaningful for such values.
+func (a *AggregationDistributionValue) IsInt64() bool { return a.isInt64 }
+
+// SumInt64 returns the exact sum of the samples of an int64 distribution.
+func (a *AggregationDistributionValue) SumInt64() int64 { return a.sumInt64 }
+
+// MinInt64 returns the exact min of the samples of an int64 distribution.
+func (a *AggregationDistributionValue) MinInt64() int64 { return a.minInt64 }
+
+// MaxInt64 returns the exact max of the samples of an int64 distribution.
+func (a *AggregationDistributionValue) MaxInt64() int64 { return a.maxInt64 }
 
 func (a *AggregationDistributionValue) variance() float64 {
 	if a.count <= 1 {
@@ -173,27 +308,70 @@ func (a *AggregationDistributionValue) CountPerBucket() []int64 {
 	return ret
 }
 
+// Bounds returns a copy of the bucket boundaries of the distribution.
+func (a *AggregationDistributionValue) Bounds() []float64 {
+	var copyBounds []float64
+	for _, b := range a.bounds {
+		copyBounds = append(copyBounds, b)
+	}
+	return copyBounds
+}
+
+// Underflows returns the number of samples below the first bound that weren't
+// aggregated because of the option WithOutOfRange.
+func (a *AggregationDistributionValue) Underflows() int64 { return a.underflows }
+
+// Overflows returns the number of samples at or above the last bound that
+// weren't aggregated because of the option WithOutOfRange.
+func (a *AggregationDistributionValue) Overflows() int64 { return a.overflows }
+
 func (a *AggregationDistributionValue) isAggregate() bool { return true }
 
 func (a *AggregationDistributionValue) addSample(v interface{}) {
 	var f float64
+	var i int64
 	switch x := v.(type) {
 	case int64:
 		f = float64(x)
+		i = x
 		break
 	case float64:
 		f = x
+		if a.isInt64 {
+			i = int64(math.Floor(x + 0.5))
+			f = float64(i)
+		}
 		break
 	default:
 		return
 	}
 
+	if n := len(a.bounds); n != 0 {
+		if f < a.bounds[0] && a.underflow == OutOfRangeCount {
+			a.underflows++
+			return
+		}
+		if f >= a.bounds[n-1] && a.overflow == OutOfRangeCount {
+			a.overflows++
+			return
+		}
+	}
+
 	if f < a.min {
 		a.min = f
 	}
 	if f > a.max {
 		a.max = f
 	}
+	if a.isInt64 {
+		a.sumInt64 += i
+		if i < a.minInt64 {
+			a.minInt64 = i
+		}
+		if i > a.maxInt64 {
+			a.maxInt64 = i
+		}
+	}
 	a.count++
 	a.incrementBucketCount(f)
 
@@ -207,6 +385,15 @@ func (a *AggregationDistributionValue) addSample(v interface{}) {
 	a.sumOfSquaredDev = a.sumOfSquaredDev + (f-oldMean)*(f-a.mean)
 }
 
+// copyOutOfRange copies the modes of other to a, and its counters of samples
+// out of range multiplied by factor.
+func (a *AggregationDistributionValue) copyOutOfRange(other *AggregationDistributionValue, factor float64) {
+	a.underflow = other.underflow
+	a.overflow = other.overflow
+	a.underflows = int64(math.Floor(float64(other.underflows)*factor + 0.5))
+	a.overflows = int64(math.Floor(float64(other.overflows)*factor + 0.5))
+}
+
 func (a *AggregationDistributionValue) incrementBucketCount(f float64) {
 	if len(a.bounds) == 0 {
 		a.countPerBucket[0]++
@@ -239,6 +426,8 @@ func (a *AggregationDistributionValue) multiplyByFraction(fraction float64) Aggr
 	ret.max = a.max
 	ret.mean = a.mean
 	ret.sumOfSquaredDev = a.sumOfSquaredDev
+	ret.copyOutOfRange(a, 1)
+	ret.copyInt64(a)
 
 	return ret
 
@@ -250,6 +439,8 @@ func (a *AggregationDistributionValue) addToIt(av AggregationValue) {
 		return
 	}
 
+	a.underflows += other.underflows
+	a.overflows += other.overflows
 	if other.count == 0 {
 		return
 	}
@@ -266,6 +457,15 @@ func (a *AggregationDistributionValue) addToIt(av AggregationValue) {
 
 	a.mean = (a.Sum() + other.Sum()) / float64(a.count+other.count)
 	a.count = a.count + other.count
+	if a.isInt64 {
+		a.sumInt64 += other.sumInt64
+		if other.minInt64 < a.minInt64 {
+			a.minInt64 = other.minInt64
+		}
+		if other.maxInt64 > a.maxInt64 {
+			a.maxInt64 = other.maxInt64
+		}
+	}
 	for i := range other.countPerBucket {
 		a.countPerBucket[i] = a.countPerBucket[i] + other.countPerBucket[i]
 	}
@@ -277,12 +477,18 @@ func (a *AggregationDistributionValue) clear() {
 	a.max = math.SmallestNonzeroFloat64
 	a.mean = 0
 	a.sumOfSquaredDev = 0
+	a.underflows = 0
+	a.overflows = 0
+	if a.isInt64 {
+		a.sumInt64 = 0
+		a.setInt64()
+	}
 	for i := range a.countPerBucket {
 		a.countPerBucket[i] = 0
 	}
 }
 
-func (a *AggregationDistributionValue) equal(other AggregationValue) bool {
+func (a *AggregationDistributionValue) equal(other AggregationValue, eps float64) bool {
 	a2, ok := other.(*AggregationDistributionValue)
 	if !ok {
 		return false
@@ -302,6 +508,305 @@ func (a *AggregationDistributionValue) equal(other AggregationValue) bool {
 		}
 	}
 
-	epsilon := math.Pow10(-9)
-	return a.Count() == a2.Count() && a.Min() == a2.Min() && a.Max() == a2.Max() && math.Pow(a.Mean()-a2.Mean(), 2) < epsilon && math.Pow(a.variance()-a2.variance(), 2) < epsilon
+	if a.isInt64 != a2.isInt64 || (a.isInt64 && a.count > 0 && (a.sumInt64 != a2.sumInt64 || a.minInt64 != a2.minInt64 || a.maxInt64 != a2.maxInt64)) {
+		return false
+	}
+	return a.Count() == a2.Count() && a.underflows == a2.underflows && a.overflows == a2.overflows && floatsEqual(a.Min(), a2.Min(), eps) && floatsEqual(a.Max(), a2.Max(), eps) && floatsEqual(a.Mean(), a2.Mean(), eps) && floatsEqual(a.variance(), a2.variance(), eps)
+}
+
+// AggregationMultiValue is the aggregated data for an AggregationMulti. It
+// holds a value per aggregation, in the order of AggregationMulti.Aggregations.
+type AggregationMultiValue struct {
+	values []AggregationValue
+}
+
+// NewAggregationMultiValue returns an AggregationMultiValue holding values,
+// e.g. to decode a value exported by another process.
+func NewAggregationMultiValue(values ...AggregationValue) *AggregationMultiValue {
+	return &AggregationMultiValue{values: values}
+}
+
+// Values returns the values of the aggregations, in the order of
+// AggregationMulti.Aggregations.
+func (a *AggregationMultiValue) Values() []AggregationValue {
+	var values []AggregationValue
+	for _, v := range a.values {
+		values = append(values, v)
+	}
+	return values
+}
+
+func (a *AggregationMultiValue) isAggregate() bool { return true }
+
+func (a *AggregationMultiValue) addSample(v interface{}) {
+	for _, av := range a.values {
+		av.addSample(v)
+	}
+}
+
+func (a *AggregationMultiValue) multiplyByFraction(fraction float64) AggregationValue {
+	ret := &AggregationMultiValue{values: make([]AggregationValue, len(a.values))}
+	for i, av := range a.values {
+		ret.values[i] = av.multiplyByFraction(fraction)
+	}
+	return ret
+}
+
+func (a *AggregationMultiValue) addToIt(av AggregationValue) {
+	other, ok := av.(*AggregationMultiValue)
+	if !ok || len(other.values) != len(a.values) {
+		return
+	}
+	for i, v := range a.values {
+		v.addToIt(other.values[i])
+	}
+}
+
+func (a *AggregationMultiValue) clear() {
+	for _, av := range a.values {
+		av.clear()
+	}
+}
+
+func (a *AggregationMultiValue) equal(other AggregationValue, eps float64) bool {
+	a2, ok := other.(*AggregationMultiValue)
+	if !ok || a2 == nil || len(a.values) != len(a2.values) {
+		return false
+	}
+	for i, av := range a.values {
+		if !av.equal(a2.values[i], eps) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *AggregationMultiValue) String() string {
+	return fmt.Sprintf("%v", a.values)
+}
+
+// AggregationRateValue is the aggregated data for an AggregationRate: the
+// number of samples per second.
+type AggregationRateValue float64
+
+// NewAggregationRateValue returns an AggregationRateValue holding the rate v,
+// e.g. to decode a value exported by another process.
+func NewAggregationRateValue(v float64) *AggregationRateValue {
+	return newAggregationRateValue(v)
+}
+
+func newAggregationRateValue(v float64) *AggregationRateValue {
+	tmp := AggregationRateValue(v)
+	return &tmp
+}
+
+func (a *AggregationRateValue) isAggregate() bool { return true }
+
+// addSample does nothing: the rates are derived from the counts of the view
+// at collection time.
+func (a *AggregationRateValue) addSample(v interface{}) {}
+
+func (a *AggregationRateValue) multiplyByFraction(fraction float64) AggregationValue {
+	// a rate doesn't depend on the length of the window it was measured
+	// over.
+	return newAggregationRateValue(float64(*a))
+}
+
+func (a *AggregationRateValue) addToIt(av AggregationValue) {
+	other, ok := av.(*AggregationRateValue)
+	if !ok {
+		return
+	}
+	*a = *a + *other
+}
+
+func (a *AggregationRateValue) clear() {
+	*a = 0
+}
+
+func (a *AggregationRateValue) equal(other AggregationValue, eps float64) bool {
+	a2, ok := other.(*AggregationRateValue)
+	if !ok {
+		return false
+	}
+	return floatsEqual(float64(*a), float64(*a2), eps)
+}
+
+func (a *AggregationRateValue) String() string {
+	return fmt.Sprintf("{%v/s}", float64(*a))
+}
+
+// AggregationRatioValue is the aggregated data for an AggregationRatio: the
+// value of a row of the numerator view divided by the value of the row of the
+// denominator view with the same tags.
+type AggregationRatioValue float64
+
+// NewAggregationRatioValue returns an AggregationRatioValue holding the ratio
+// v, e.g. to decode a value exported by another process.
+func NewAggregationRatioValue(v float64) *AggregationRatioValue {
+	return newAggregationRatioValue(v)
+}
+
+func newAggregationRatioValue(v float64) *AggregationRatioValue {
+	tmp := AggregationRatioValue(v)
+	return &tmp
+}
+
+func (a *AggregationRatioValue) isAggregate() bool { return true }
+
+// addSample does nothing: the ratios are computed from the rows of the
+// numerator and denominator views at collection time.
+func (a *AggregationRatioValue) addSample(v interface{}) {}
+
+func (a *AggregationRatioValue) multiplyByFraction(fraction float64) AggregationValue {
+	return newAggregationRatioValue(float64(*a))
+}
+
+// addToIt does nothing: the ratios of several rows can't be combined without
+// their numerators and denominators.
+func (a *AggregationRatioValue) addToIt(av AggregationValue) {}
+
+func (a *AggregationRatioValue) clear() {
+	*a = 0
+}
+
+func (a *AggregationRatioValue) equal(other AggregationValue, eps float64) bool {
+	a2, ok := other.(*AggregationRatioValue)
+	if !ok {
+		return false
+	}
+	return floatsEqual(float64(*a), float64(*a2), eps)
+}
+
+func (a *AggregationRatioValue) String() string {
+	return fmt.Sprintf("{%v}", float64(*a))
+}
+
+// AggregationSumValue is the aggregated data for an AggregationSum: the sum of
+// the samples.
+type AggregationSumValue float64
+
+// NewAggregationSumValue returns an AggregationSumValue holding the sum v,
+// e.g. to decode a value exported by another process.
+func NewAggregationSumValue(v float64) *AggregationSumValue {
+	return newAggregationSumValue(v)
+}
+
+func newAggregationSumValue(v float64) *AggregationSumValue {
+	tmp := AggregationSumValue(v)
+	return &tmp
+}
+
+func (a *AggregationSumValue) isAggregate() bool { return true }
+
+func (a *AggregationSumValue) addSample(v interface{}) {
+	switch x := v.(type) {
+	case int64:
+		*a += AggregationSumValue(x)
+	case float64:
+		*a += AggregationSumValue(x)
+	}
+}
+
+func (a *AggregationSumValue) multiplyByFraction(fraction float64) AggregationValue {
+	return newAggregationSumValue(float64(*a) * fraction)
+}
+
+func (a *AggregationSumValue) addToIt(av AggregationValue) {
+	other, ok := av.(*AggregationSumValue)
+	if !ok {
+		return
+	}
+	*a = *a + *other
+}
+
+func (a *AggregationSumValue) clear() {
+	*a = 0
+}
+
+func (a *AggregationSumValue) equal(other AggregationValue, eps float64) bool {
+	a2, ok := other.(*AggregationSumValue)
+	if !ok {
+		return false
+	}
+	return floatsEqual(float64(*a), float64(*a2), eps)
+}
+
+func (a *AggregationSumValue) String() string {
+	return fmt.Sprintf("{%v}", float64(*a))
+}
+
+// AggregationMeanValue is the aggregated data for an AggregationMean: the sum
+// and the number of the samples, whose ratio is the mean.
+type AggregationMeanValue struct {
+	sum, count float64
+}
+
+// NewAggregationMeanValue returns an AggregationMeanValue of count samples
+// summing to sum, e.g. to decode a value exported by another process.
+func NewAggregationMeanValue(sum, count float64) *AggregationMeanValue {
+	return &AggregationMeanValue{sum: sum, count: count}
+}
+
+// Mean returns the mean of the samples, or 0 if there are none.
+func (a *AggregationMeanValue) Mean() float64 {
+	if a.count == 0 {
+		return 0
+	}
+	return a.sum / a.count
+}
+
+// Sum returns the sum of the samples.
+func (a *AggregationMeanValue) Sum() float64 {
+	return a.sum
+}
+
+// Count returns the number of samples. It is fractional for the sliding
+// windows, whose oldest sub-interval is weighted by the fraction of it still
+// covered.
+func (a *AggregationMeanValue) Count() float64 {
+	return a.count
+}
+
+func (a *AggregationMeanValue) isAggregate() bool { return true }
+
+func (a *AggregationMeanValue) addSample(v interface{}) {
+	switch x := v.(type) {
+	case int64:
+		a.sum += float64(x)
+	case float64:
+		a.sum += x
+	default:
+		return
+	}
+	a.count++
+}
+
+func (a *AggregationMeanValue) multiplyByFraction(fraction float64) AggregationValue {
+	return &AggregationMeanValue{sum: a.sum * fraction, count: a.count * fraction}
+}
+
+func (a *AggregationMeanValue) addToIt(av AggregationValue) {
+	other, ok := av.(*AggregationMeanValue)
+	if !ok {
+		return
+	}
+	a.sum += other.sum
+	a.count += other.count
+}
+
+func (a *AggregationMeanValue) clear() {
+	a.sum, a.count = 0, 0
+}
+
+func (a *AggregationMeanValue) equal(other AggregationValue, eps float64) bool {
+	a2, ok := other.(*AggregationMeanValue)
+	if !ok {
+		return false
+	}
+	return floatsEqual(a.sum, a2.sum, eps) && floatsEqual(a.count, a2.count, eps)
+}
+
+func (a *AggregationMeanValue) String() string {
+	return fmt.Sprintf("{%v %v}", a.Mean(), a.count)
 }
@@ -0,0 +1,379 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The aggregation values implement encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler, so they can be stored in caches, sent over RPC
+// or checkpointed, and are encoded by encoding/gob without reflection on
+// their unexported fields. The encoding starts with its version and the kind
+// of the value, followed by the data of the value:
+//
+//   - count: the count as a varint.
+//   - distribution: the count as a varint, the min, max, mean and sum of
+//     squared deviations as little endian float64, the number of bounds as an
+//     uvarint followed by the bounds, the count of each bucket as a varint,
+//     the underflow and overflow modes, the underflows and overflows as
+//     varints, and whether the distribution is an int64 one, followed by its
+//     exact sum, min and max as varints if it is.
+//   - multi: the number of values as an uvarint, followed by the length of the
+//     encoding of each value as an uvarint and the encoding.
+//   - rate, ratio and sum: the value as a little endian float64.
+//   - mean: the sum and the count of the samples as little endian float64.
+const binaryVersion = 1
+
+const (
+	kindCount byte = iota + 1
+	kindDistribution
+	kindMulti
+	kindRate
+	kindRatio
+	kindSum
+	kindMean
+)
+
+func init() {
+	gob.Register(new(AggregationCountValue))
+	gob.Register(new(AggregationDistributionValue))
+	gob.Register(new(AggregationMultiValue))
+	gob.Register(new(AggregationRateValue))
+	gob.Register(new(AggregationRatioValue))
+	gob.Register(new(AggregationSumValue))
+	gob.Register(new(AggregationMeanValue))
+}
+
+// UnmarshalAggregationValue decodes an aggregation value of any type encoded
+// by its MarshalBinary method.
+func UnmarshalAggregationValue(b []byte) (AggregationValue, error) {
+	if len(b) < 2 {
+		return nil, errors.New("cannot decode aggregation value: data too short")
+	}
+	var av interface {
+		AggregationValue
+		UnmarshalBinary(b []byte) error
+	}
+	switch b[1] {
+	case kindCount:
+		av = new(AggregationCountValue)
+	case kindDistribution:
+		av = new(AggregationDistributionValue)
+	case kindMulti:
+		av = new(AggregationMultiValue)
+	case kindRate:
+		av = new(AggregationRateValue)
+	case kindRatio:
+		av = new(AggregationRatioValue)
+	case kindSum:
+		av = new(AggregationSumValue)
+	case kindMean:
+		av = new(AggregationMeanValue)
+	default:
+		return nil, fmt.Errorf("cannot decode aggregation value of unknown kind %v", b[1])
+	}
+	if err := av.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return av, nil
+}
+
+// MarshalBinary encodes a.
+func (a *AggregationCountValue) MarshalBinary() ([]byte, error) {
+	return appendVarint([]byte{binaryVersion, kindCount}, int64(*a)), nil
+}
+
+// UnmarshalBinary decodes a.
+func (a *AggregationCountValue) UnmarshalBinary(b []byte) error {
+	d := newBinaryDecoder(b, kindCount)
+	n := d.varint()
+	if err := d.done(); err != nil {
+		return err
+	}
+	*a = AggregationCountValue(n)
+	return nil
+}
+
+// MarshalBinary encodes a.
+func (a *AggregationDistributionValue) MarshalBinary() ([]byte, error) {
+	b := []byte{binaryVersion, kindDistribution}
+	b = appendVarint(b, a.count)
+	for _, f := range []float64{a.min, a.max, a.mean, a.sumOfSquaredDev} {
+		b = appendFloat64(b, f)
+	}
+	b = appendUvarint(b, uint64(len(a.bounds)))
+	for _, f := range a.bounds {
+		b = appendFloat64(b, f)
+	}
+	for _, n := range a.countPerBucket {
+		b = appendVarint(b, n)
+	}
+	b = append(b, byte(a.underflow), byte(a.overflow))
+	b = appendVarint(b, a.underflows)
+	b = appendVarint(b, a.overflows)
+	if !a.isInt64 {
+		return append(b, 0), nil
+	}
+	b = append(b, 1)
+	b = appendVarint(b, a.sumInt64)
+	b = appendVarint(b, a.minInt64)
+	return appendVarint(b, a.maxInt64), nil
+}
+
+// UnmarshalBinary decodes a.
+func (a *AggregationDistributionValue) UnmarshalBinary(b []byte) error {
+	d := newBinaryDecoder(b, kindDistribution)
+	v := &AggregationDistributionValue{}
+	v.count = d.varint()
+	v.min, v.max, v.mean, v.sumOfSquaredDev = d.float64(), d.float64(), d.float64(), d.float64()
+	n := d.length()
+	if n > 0 {
+		v.bounds = make([]float64, n)
+	}
+	for i := range v.bounds {
+		v.bounds[i] = d.float64()
+	}
+	v.countPerBucket = make([]int64, n+1)
+	for i := range v.countPerBucket {
+		v.countPerBucket[i] = d.varint()
+	}
+	v.underflow, v.overflow = OutOfRange(d.byte()), OutOfRange(d.byte())
+	v.underflows, v.overflows = d.varint(), d.varint()
+	if d.byte() == 1 {
+		v.isInt64 = true
+		v.sumInt64, v.minInt64, v.maxInt64 = d.varint(), d.varint(), d.varint()
+	}
+	if err := d.done(); err != nil {
+		return err
+	}
+	*a = *v
+	return nil
+}
+
+// MarshalBinary encodes a and its values.
+func (a *AggregationMultiValue) MarshalBinary() ([]byte, error) {
+	b := appendUvarint([]byte{binaryVersion, kindMulti}, uint64(len(a.values)))
+	for _, av := range a.values {
+		m, ok := av.(interface {
+			MarshalBinary() ([]byte, error)
+		})
+		if !ok {
+			return nil, fmt.Errorf("cannot encode aggregation value of type %T", av)
+		}
+		e, err := m.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		b = appendUvarint(b, uint64(len(e)))
+		b = append(b, e...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a and its values.
+func (a *AggregationMultiValue) UnmarshalBinary(b []byte) error {
+	d := newBinaryDecoder(b, kindMulti)
+	values := make([]AggregationValue, d.length())
+	for i := range values {
+		e := d.bytes(d.length())
+		if d.err != nil {
+			return d.err
+		}
+		av, err := UnmarshalAggregationValue(e)
+		if err != nil {
+			return err
+		}
+		values[i] = av
+	}
+	if err := d.done(); err != nil {
+		return err
+	}
+	a.values = values
+	return nil
+}
+
+// MarshalBinary encodes a.
+func (a *AggregationRateValue) MarshalBinary() ([]byte, error) {
+	return appendFloat64([]byte{binaryVersion, kindRate}, float64(*a)), nil
+}
+
+// UnmarshalBinary decodes a.
+func (a *AggregationRateValue) UnmarshalBinary(b []byte) error {
+	d := newBinaryDecoder(b, kindRate)
+	f := d.float64()
+	if err := d.done(); err != nil {
+		return err
+	}
+	*a = AggregationRateValue(f)
+	return nil
+}
+
+// MarshalBinary encodes a.
+func (a *AggregationRatioValue) MarshalBinary() ([]byte, error) {
+	return appendFloat64([]byte{binaryVersion, kindRatio}, float64(*a)), nil
+}
+
+// UnmarshalBinary decodes a.
+func (a *AggregationRatioValue) UnmarshalBinary(b []byte) error {
+	d := newBinaryDecoder(b, kindRatio)
+	f := d.float64()
+	if err := d.done(); err != nil {
+		return err
+	}
+	*a = AggregationRatioValue(f)
+	return nil
+}
+
+// MarshalBinary encodes a.
+func (a *AggregationSumValue) MarshalBinary() ([]byte, error) {
+	return appendFloat64([]byte{binaryVersion, kindSum}, float64(*a)), nil
+}
+
+// UnmarshalBinary decodes a.
+func (a *AggregationSumValue) UnmarshalBinary(b []byte) error {
+	d := newBinaryDecoder(b, kindSum)
+	f := d.float64()
+	if err := d.done(); err != nil {
+		return err
+	}
+	*a = AggregationSumValue(f)
+	return nil
+}
+
+// MarshalBinary encodes a.
+func (a *AggregationMeanValue) MarshalBinary() ([]byte, error) {
+	b := appendFloat64([]byte{binaryVersion, kindMean}, a.sum)
+	return appendFloat64(b, a.count), nil
+}
+
+// UnmarshalBinary decodes a.
+func (a *AggregationMeanValue) UnmarshalBinary(b []byte) error {
+	d := newBinaryDecoder(b, kindMean)
+	sum := d.float64()
+	count := d.float64()
+	if err := d.done(); err != nil {
+		return err
+	}
+	a.sum, a.count = sum, count
+	return nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var n [binary.MaxVarintLen64]byte
+	return append(b, n[:binary.PutUvarint(n[:], v)]...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var n [binary.MaxVarintLen64]byte
+	return append(b, n[:binary.PutVarint(n[:], v)]...)
+}
+
+func appendFloat64(b []byte, f float64) []byte {
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], math.Float64bits(f))
+	return append(b, n[:]...)
+}
+
+// binaryDecoder reads the encoding of an aggregation value. The first error
+// is kept in err and the reads after it return zero values.
+type binaryDecoder struct {
+	b   []byte
+	err error
+}
+
+// newBinaryDecoder returns a decoder of the data of b, whose version and kind
+// are checked.
+func newBinaryDecoder(b []byte, kind byte) *binaryDecoder {
+	d := &binaryDecoder{b: b}
+	if v := d.byte(); d.err == nil && v != binaryVersion {
+		d.err = fmt.Errorf("cannot decode aggregation value of encoding version %v, want %v", v, binaryVersion)
+	}
+	if k := d.byte(); d.err == nil && k != kind {
+		d.err = fmt.Errorf("cannot decode aggregation value of kind %v, want %v", k, kind)
+	}
+	return d
+}
+
+func (d *binaryDecoder) fail() {
+	if d.err == nil {
+		d.err = errors.New("cannot decode aggregation value: data truncated")
+	}
+	d.b = nil
+}
+
+func (d *binaryDecoder) byte() byte {
+	if len(d.b) < 1 {
+		d.fail()
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *binaryDecoder) bytes(n int) []byte {
+	if len(d.b) < n {
+		d.fail()
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *binaryDecoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+// length reads an uvarint which must not exceed the length of the remaining
+// data, so that corrupted data doesn't make the decoding allocate huge
+// slices.
+func (d *binaryDecoder) length() int {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 || v > uint64(len(d.b)) {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return int(v)
+}
+
+func (d *binaryDecoder) float64() float64 {
+	b := d.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
+
+// done returns the first error of the decoding, or an error if data remains.
+func (d *binaryDecoder) done() error {
+	if d.err == nil && len(d.b) != 0 {
+		d.err = fmt.Errorf("cannot decode aggregation value: %v trailing bytes", len(d.b))
+	}
+	return d.err
+}
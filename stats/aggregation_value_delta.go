@@ -0,0 +1,123 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"fmt"
+	"math"
+)
+
+// AggregationValueDelta returns the aggregated data of the samples aggregated
+// by cur but not by prev, where prev and cur are successive values of the
+// same cumulative row. The min and max of the samples of a distribution delta
+// can't be recovered, so they are those of cur. It returns an error if cur
+// doesn't follow prev, e.g. because the row was reset in between.
+func AggregationValueDelta(cur, prev AggregationValue) (AggregationValue, error) {
+	switch cur := cur.(type) {
+	case *AggregationCountValue:
+		prev, ok := prev.(*AggregationCountValue)
+		if !ok {
+			return nil, fmt.Errorf("cannot compute the delta between '%v' and '%v' because they have different types", cur, prev)
+		}
+		if *cur < *prev {
+			return nil, fmt.Errorf("cannot compute the delta between '%v' and '%v' because the count decreased", cur, prev)
+		}
+		return newAggregationCountValue(int64(*cur - *prev)), nil
+	case *AggregationDistributionValue:
+		prev, ok := prev.(*AggregationDistributionValue)
+		if !ok {
+			return nil, fmt.Errorf("cannot compute the delta between '%v' and '%v' because they have different types", cur, prev)
+		}
+		return distributionDelta(cur, prev)
+	case *AggregationSumValue:
+		prev, ok := prev.(*AggregationSumValue)
+		if !ok {
+			return nil, fmt.Errorf("cannot compute the delta between '%v' and '%v' because they have different types", cur, prev)
+		}
+		// the sum may decrease with negative samples: a reset can't be
+		// detected.
+		return newAggregationSumValue(float64(*cur - *prev)), nil
+	case *AggregationMeanValue:
+		prev, ok := prev.(*AggregationMeanValue)
+		if !ok {
+			return nil, fmt.Errorf("cannot compute the delta between '%v' and '%v' because they have different types", cur, prev)
+		}
+		if cur.count < prev.count {
+			return nil, fmt.Errorf("cannot compute the delta between '%v' and '%v' because the count decreased", cur, prev)
+		}
+		return &AggregationMeanValue{sum: cur.sum - prev.sum, count: cur.count - prev.count}, nil
+	case *AggregationMultiValue:
+		prev, ok := prev.(*AggregationMultiValue)
+		if !ok || len(cur.values) != len(prev.values) {
+			return nil, fmt.Errorf("cannot compute the delta between '%v' and '%v' because they have different types", cur, prev)
+		}
+		delta := &AggregationMultiValue{values: make([]AggregationValue, len(cur.values))}
+		for i, av := range cur.values {
+			d, err := AggregationValueDelta(av, prev.values[i])
+			if err != nil {
+				return nil, err
+			}
+			delta.values[i] = d
+		}
+		return delta, nil
+	}
+	return nil, fmt.Errorf("cannot compute the delta of '%v' because its type is not supported", cur)
+}
+
+func distributionDelta(cur, prev *AggregationDistributionValue) (AggregationValue, error) {
+	if len(cur.countPerBucket) != len(prev.countPerBucket) {
+		return nil, fmt.Errorf("cannot compute the delta between '%v' and '%v' because they have different buckets", cur, prev)
+	}
+	delta := newAggregationDistributionValue(cur.bounds)
+	delta.underflow = cur.underflow
+	delta.overflow = cur.overflow
+	delta.underflows = cur.underflows - prev.underflows
+	delta.overflows = cur.overflows - prev.overflows
+	if delta.underflows < 0 || delta.overflows < 0 {
+		return nil, fmt.Errorf("cannot compute the delta between '%v' and '%v' because the count of samples out of range decreased", cur, prev)
+	}
+	for i := range cur.countPerBucket {
+		delta.countPerBucket[i] = cur.countPerBucket[i] - prev.countPerBucket[i]
+		if delta.countPerBucket[i] < 0 {
+			return nil, fmt.Errorf("cannot compute the delta between '%v' and '%v' because the count of bucket %v decreased", cur, prev, i)
+		}
+	}
+	if cur.isInt64 {
+		delta.setInt64()
+	}
+	delta.count = cur.count - prev.count
+	if delta.count == 0 {
+		return delta, nil
+	}
+	delta.min = cur.min
+	delta.max = cur.max
+	if cur.isInt64 {
+		delta.sumInt64 = cur.sumInt64 - prev.sumInt64
+		delta.minInt64 = cur.minInt64
+		delta.maxInt64 = cur.maxInt64
+	}
+	delta.mean = (cur.Sum() - prev.Sum()) / float64(delta.count)
+
+	// inverts the computation of the sum of squared deviations of the union of
+	// two sets of samples done by addToIt.
+	d := delta.mean - prev.mean
+	delta.sumOfSquaredDev = cur.sumOfSquaredDev - prev.sumOfSquaredDev - math.Pow(d, 2)*float64(prev.count*delta.count)/float64(cur.count)
+	if delta.sumOfSquaredDev < 0 {
+		// rounding errors.
+		delta.sumOfSquaredDev = 0
+	}
+	return delta, nil
+}
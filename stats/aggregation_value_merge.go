@@ -0,0 +1,84 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Merge returns the distribution of the samples aggregated by a and other,
+// e.g. to combine the distributions of the same view coming from several
+// processes. a and other are not modified. Their bounds and their
+// WithOutOfRange modes must be the same.
+func (a *AggregationDistributionValue) Merge(other *AggregationDistributionValue) (*AggregationDistributionValue, error) {
+	if !reflect.DeepEqual(a.bounds, other.bounds) {
+		return nil, fmt.Errorf("cannot merge distributions with the bounds '%v' and '%v'. Use MergeRebucketed if the bounds are compatible", a.bounds, other.bounds)
+	}
+	if a.underflow != other.underflow || a.overflow != other.overflow {
+		return nil, fmt.Errorf("cannot merge distributions handling the samples out of range differently")
+	}
+	if a.isInt64 != other.isInt64 {
+		return nil, fmt.Errorf("cannot merge an int64 distribution with a float64 distribution")
+	}
+	ret := a.multiplyByFraction(1).(*AggregationDistributionValue)
+	ret.addToIt(other)
+	return ret, nil
+}
+
+// MergeRebucketed is like Merge but the bounds of other may be finer than
+// those of a: each bound of a must also be a bound of other. The buckets of
+// other are added to the buckets of a containing them, so the result has the
+// bounds of a. If a counts the samples out of range with WithOutOfRange, its
+// first or last bound must also be the first or last bound of other.
+func (a *AggregationDistributionValue) MergeRebucketed(other *AggregationDistributionValue) (*AggregationDistributionValue, error) {
+	if reflect.DeepEqual(a.bounds, other.bounds) {
+		return a.Merge(other)
+	}
+	if a.underflow != other.underflow || a.overflow != other.overflow {
+		return nil, fmt.Errorf("cannot merge distributions handling the samples out of range differently")
+	}
+	if len(a.bounds) == 0 || len(other.bounds) == 0 {
+		return nil, fmt.Errorf("cannot rebucket the distribution with the bounds '%v' into the bounds '%v'", other.bounds, a.bounds)
+	}
+	for _, b := range a.bounds {
+		i := sort.SearchFloat64s(other.bounds, b)
+		if i == len(other.bounds) || other.bounds[i] != b {
+			return nil, fmt.Errorf("cannot rebucket the distribution with the bounds '%v' into the bounds '%v' because '%v' is not one of its bounds", other.bounds, a.bounds, b)
+		}
+	}
+	if a.underflow == OutOfRangeCount && a.bounds[0] != other.bounds[0] {
+		return nil, fmt.Errorf("cannot rebucket the distribution with the bounds '%v' into the bounds '%v' because they have different underflow bounds", other.bounds, a.bounds)
+	}
+	if a.overflow == OutOfRangeCount && a.bounds[len(a.bounds)-1] != other.bounds[len(other.bounds)-1] {
+		return nil, fmt.Errorf("cannot rebucket the distribution with the bounds '%v' into the bounds '%v' because they have different overflow bounds", other.bounds, a.bounds)
+	}
+
+	rebucketed := other.multiplyByFraction(1).(*AggregationDistributionValue)
+	rebucketed.bounds = a.bounds
+	rebucketed.countPerBucket = make([]int64, len(a.bounds)+1)
+	for j, c := range other.countPerBucket {
+		// the bucket j of other starts at other.bounds[j-1], which is at or
+		// above the bounds of the buckets of a before the bucket i.
+		i := 0
+		if j > 0 {
+			i = sort.Search(len(a.bounds), func(k int) bool { return a.bounds[k] > other.bounds[j-1] })
+		}
+		rebucketed.countPerBucket[i] += c
+	}
+	return a.Merge(rebucketed)
+}
@@ -0,0 +1,67 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+// AggregationValueVisitor processes the aggregation values according to their
+// type. Code implementing it, e.g. an exporter, gets compile-time safety: when
+// a new type of aggregation is added, the visitor gets a new method and the
+// code stops compiling, instead of silently ignoring the values of the new
+// type as a type switch would.
+type AggregationValueVisitor interface {
+	VisitCount(v *AggregationCountValue)
+	VisitDistribution(v *AggregationDistributionValue)
+	VisitMulti(v *AggregationMultiValue)
+	VisitRate(v *AggregationRateValue)
+	VisitRatio(v *AggregationRatioValue)
+	VisitSum(v *AggregationSumValue)
+	VisitMean(v *AggregationMeanValue)
+}
+
+// Accept calls v.VisitCount.
+func (a *AggregationCountValue) Accept(v AggregationValueVisitor) {
+	v.VisitCount(a)
+}
+
+// Accept calls v.VisitDistribution.
+func (a *AggregationDistributionValue) Accept(v AggregationValueVisitor) {
+	v.VisitDistribution(a)
+}
+
+// Accept calls v.VisitMulti. The visitor can visit the values of a with
+// a.Values.
+func (a *AggregationMultiValue) Accept(v AggregationValueVisitor) {
+	v.VisitMulti(a)
+}
+
+// Accept calls v.VisitRate.
+func (a *AggregationRateValue) Accept(v AggregationValueVisitor) {
+	v.VisitRate(a)
+}
+
+// Accept calls v.VisitRatio.
+func (a *AggregationRatioValue) Accept(v AggregationValueVisitor) {
+	v.VisitRatio(a)
+}
+
+// Accept calls v.VisitSum.
+func (a *AggregationSumValue) Accept(v AggregationValueVisitor) {
+	v.VisitSum(a)
+}
+
+// Accept calls v.VisitMean.
+func (a *AggregationMeanValue) Accept(v AggregationValueVisitor) {
+	v.VisitMean(a)
+}
@@ -0,0 +1,71 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testVisitor struct {
+	visited []string
+}
+
+func (v *testVisitor) VisitCount(a *AggregationCountValue) {
+	v.visited = append(v.visited, "count "+a.String())
+}
+
+func (v *testVisitor) VisitDistribution(a *AggregationDistributionValue) {
+	v.visited = append(v.visited, "distribution "+a.String())
+}
+
+func (v *testVisitor) VisitRate(a *AggregationRateValue) {
+	v.visited = append(v.visited, "rate "+a.String())
+}
+
+func (v *testVisitor) VisitRatio(a *AggregationRatioValue) {
+	v.visited = append(v.visited, "ratio "+a.String())
+}
+
+func (v *testVisitor) VisitSum(a *AggregationSumValue) {
+	v.visited = append(v.visited, "sum "+a.String())
+}
+
+func (v *testVisitor) VisitMean(a *AggregationMeanValue) {
+	v.visited = append(v.visited, "mean "+a.String())
+}
+
+func (v *testVisitor) VisitMulti(a *AggregationMultiValue) {
+	for _, av := range a.Values() {
+		av.Accept(v)
+	}
+}
+
+func Test_AggregationValue_Accept(t *testing.T) {
+	count := newAggregationCountValue(3)
+	dist := newAggregationDistributionValue([]float64{1})
+	multi := NewAggregationMultiValue(count, dist)
+	rate := NewAggregationRateValue(2.5)
+	ratio := NewAggregationRatioValue(0.1)
+	v := &testVisitor{}
+	for _, av := range []AggregationValue{count, dist, multi, rate, ratio} {
+		av.Accept(v)
+	}
+	want := []string{"count " + count.String(), "distribution " + dist.String(), "count " + count.String(), "distribution " + dist.String(), "rate " + rate.String(), "ratio " + ratio.String()}
+	if !reflect.DeepEqual(v.visited, want) {
+		t.Errorf("Accept got visits %v, want %v", v.visited, want)
+	}
+}
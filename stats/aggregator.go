@@ -25,3 +25,13 @@ type aggregator interface {
 	addSample(v interface{}, now time.Time)
 	retrieveCollected(now time.Time) AggregationValue
 }
+
+// recyclableAggregator is implemented by the aggregators which can be reused
+// for a new row once the rows of their collector are reset, so that the
+// aggregation values of their buckets are not allocated again. See
+// collector.resetAt.
+type recyclableAggregator interface {
+	aggregator
+	// reset clears the aggregator and restarts its window at now.
+	reset(now time.Time)
+}
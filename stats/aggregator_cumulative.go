@@ -16,14 +16,22 @@
 package stats
 
 import (
+	"fmt"
 	"time"
 )
 
 // aggregatorCumulative indicates that the aggregation occurs over all samples
 // seen since the view collection started.
 type aggregatorCumulative struct {
+	// started is the time of the first sample of the row, reported as the
+	// Start of the row.
 	started time.Time
 	av      AggregationValue
+
+	// checksum of av. It is only maintained when the package is built with
+	// the censusaudit build tag to detect av being mutated outside of the
+	// aggregator, e.g. by a caller it leaked to.
+	checksum uint64
 }
 
 // newAggregatorCumulative creates an aggregatorCumulative.
@@ -34,14 +42,51 @@ func newAggregatorCumulative(now time.Time, newAggregationValue func() Aggregati
 	}
 }
 
+// startAt moves the start of the row back to start, if it is earlier, e.g.
+// when the data of a row collected earlier is added to it. A zero start is
+// ignored.
+func (a *aggregatorCumulative) startAt(start time.Time) {
+	if !start.IsZero() && start.Before(a.started) {
+		a.started = start
+	}
+}
+
 func (a *aggregatorCumulative) isAggregator() bool {
 	return true
 }
 
 func (a *aggregatorCumulative) addSample(v interface{}, now time.Time) {
+	a.audit()
 	a.av.addSample(v)
+	a.updateChecksum()
 }
 
+// add adds av to the aggregated value, e.g. the value of a row restored or
+// received from another process.
+func (a *aggregatorCumulative) add(av AggregationValue) {
+	a.audit()
+	a.av.addToIt(av)
+	a.updateChecksum()
+}
+
+// retrieveCollected returns a copy of the aggregated value, so that the value
+// delivered to the callers isn't modified by the samples added later.
 func (a *aggregatorCumulative) retrieveCollected(now time.Time) AggregationValue {
-	return a.av
+	a.audit()
+	return a.av.multiplyByFraction(1)
+}
+
+func (a *aggregatorCumulative) updateChecksum() {
+	if auditEnabled {
+		a.checksum = checksumAggregationValue(a.av)
+	}
+}
+
+func (a *aggregatorCumulative) audit() {
+	if !auditEnabled || a.checksum == 0 {
+		return
+	}
+	if a.checksum != checksumAggregationValue(a.av) {
+		panic(fmt.Sprintf("stats: cumulative aggregation value %v was mutated outside of the library. AggregationValues returned by the library must not be modified", a.av))
+	}
 }
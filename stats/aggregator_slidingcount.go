@@ -63,12 +63,22 @@ func (a *aggregatorSlidingCount) addSample(v interface{}, now time.Time) {
 	if e.count == a.itemsPerBucket {
 		a.idx = (a.idx + 1) % len(a.entries)
 		e = a.entries[a.idx]
+		e.count = 0
 		e.av.clear()
 	}
 	e.count++
 	e.av.addSample(v)
 }
 
+// reset clears the buckets. The aggregation values of the buckets are reused.
+func (a *aggregatorSlidingCount) reset(now time.Time) {
+	for _, e := range a.entries {
+		e.count = 0
+		e.av.clear()
+	}
+	a.idx = 0
+}
+
 func (a *aggregatorSlidingCount) retrieveCollected(now time.Time) AggregationValue {
 	e := a.entries[a.idx]
 	remaining := float64(a.itemsPerBucket-e.count) / float64(a.itemsPerBucket)
@@ -33,40 +33,74 @@ type aggregatorSlidingTime struct {
 	subDuration     time.Duration
 	entries         []*timeSerieEntry
 	idx             int
+	aligned         bool
 }
 
-// newAggregatorSlidingTime creates an aggregatorSlidingTime.
-func newAggregatorSlidingTime(now time.Time, d time.Duration, subIntervalsCount int, newAggregationValue func() AggregationValue) *aggregatorSlidingTime {
+// newAggregatorSlidingTime creates an aggregatorSlidingTime. If aligned is
+// true, the boundaries of the entries are multiples of their duration instead
+// of being relative to now.
+func newAggregatorSlidingTime(now time.Time, d time.Duration, subIntervalsCount int, aligned bool, newAggregationValue func() AggregationValue) *aggregatorSlidingTime {
 	subDuration := d / time.Duration(subIntervalsCount)
-	start := now.Add(-subDuration * time.Duration(subIntervalsCount))
 	var entries []*timeSerieEntry
 	// Keeps track of subIntervalsCount+1 entries in order to approximate the
 	// collected stats without storing every instance with its timestamp.
 	for i := 0; i <= subIntervalsCount; i++ {
 		entries = append(entries, &timeSerieEntry{
-			endTime: start.Add(subDuration),
-			av:      newAggregationValue(),
+			av: newAggregationValue(),
 		})
-		start = start.Add(subDuration)
 	}
 
-	return &aggregatorSlidingTime{
+	a := &aggregatorSlidingTime{
 		keptDuration:    subDuration * time.Duration(len(entries)),
 		desiredDuration: subDuration * time.Duration(len(entries)-1), // this is equal to d
 		subDuration:     subDuration,
 		entries:         entries,
-		idx:             subIntervalsCount,
+		aligned:         aligned,
 	}
+	a.startAt(now)
+	return a
+}
+
+// startAt sets the end times of the entries so that the current entry covers
+// now.
+func (a *aggregatorSlidingTime) startAt(now time.Time) {
+	if a.aligned {
+		now = now.Truncate(a.subDuration)
+	}
+	start := now.Add(-a.subDuration * time.Duration(len(a.entries)-1))
+	for _, e := range a.entries {
+		start = start.Add(a.subDuration)
+		e.endTime = start
+	}
+	a.idx = len(a.entries) - 1
+}
+
+// reset clears the entries and restarts the window at now. The aggregation
+// values of the entries are reused.
+func (a *aggregatorSlidingTime) reset(now time.Time) {
+	for _, e := range a.entries {
+		e.av.clear()
+	}
+	a.startAt(now)
 }
 
 func (a *aggregatorSlidingTime) isAggregator() bool {
 	return true
 }
 
+// addSample adds v to the entry covering now. The window moves forward if now
+// is after the current entry. If now is before the current entry, i.e. the
+// sample is backfilled, v is added to the older entry covering now, or dropped
+// if now is before the oldest entry.
 func (a *aggregatorSlidingTime) addSample(v interface{}, now time.Time) {
 	a.moveToCurrentEntry(now)
-	e := a.entries[a.idx]
-	e.av.addSample(v)
+	for j := 0; j < len(a.entries); j++ {
+		e := a.entries[(a.idx-j+len(a.entries))%len(a.entries)]
+		if !now.Before(e.endTime.Add(-a.subDuration)) {
+			e.av.addSample(v)
+			return
+		}
+	}
 }
 
 func (a *aggregatorSlidingTime) retrieveCollected(now time.Time) AggregationValue {
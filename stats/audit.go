@@ -0,0 +1,60 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// The helpers in this file maintain checksums of the data shared between the
+// library and its callers. They are no-ops unless the package is built with
+// the censusaudit build tag.
+
+// auditedViewData is a ViewData delivered to the subscribers along with the
+// checksum of its rows at the time it was delivered.
+type auditedViewData struct {
+	vd       *ViewData
+	checksum uint64
+}
+
+func newAuditedViewData(vd *ViewData) *auditedViewData {
+	return &auditedViewData{
+		vd:       vd,
+		checksum: checksumRows(vd.Rows),
+	}
+}
+
+// audit panics if the rows of the delivered ViewData were mutated.
+func (a *auditedViewData) audit() {
+	if a.checksum != checksumRows(a.vd.Rows) {
+		panic(fmt.Sprintf("stats: rows of the ViewData delivered for view '%v' were mutated by a subscriber. ViewData is shared by all subscribers and must not be modified", a.vd.V.Name()))
+	}
+}
+
+func checksumRows(rows []*Row) uint64 {
+	h := fnv.New64a()
+	for _, r := range rows {
+		h.Write([]byte(r.String()))
+	}
+	return h.Sum64()
+}
+
+func checksumAggregationValue(av AggregationValue) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(av.String()))
+	return h.Sum64()
+}
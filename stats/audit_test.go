@@ -0,0 +1,84 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build censusaudit
+// +build censusaudit
+
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+func Test_Audit_MutatedCumulativeValue(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VC1", "desc VC1", []tags.Key{k1}, nil, NewAggregationCount(), NewWindowCumulative(), WithResetOnCollect())
+	v.startForcedCollection()
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+	now := time.Now()
+
+	// the collected rows are copies: mutating them doesn't affect the
+	// aggregator, before or after a reset.
+	for i := 0; i < 2; i++ {
+		v.addSample(ts, int64(1), now)
+		rows := v.collectedRows(now)
+		*(rows[0].AggregationValue.(*AggregationCountValue)) = 10
+		v.addSample(ts, int64(1), now)
+		if err := v.(*view).addRows([]*Row{{Tags: rows[0].Tags, AggregationValue: newAggregationCountValue(1)}}, now); err != nil {
+			t.Fatalf("addRows() got error %v, want no error", err)
+		}
+		rows = v.collectedRows(now)
+		if got := *(rows[0].AggregationValue.(*AggregationCountValue)); got != 3 {
+			t.Errorf("collectedRows() got count %v, want 3", got)
+		}
+		v.collector().resetAt(now)
+	}
+
+	// the value of the aggregator is mutated as if it leaked to a caller.
+	v.addSample(ts, int64(1), now)
+	for _, a := range v.collector().signatures {
+		*(a.(*aggregatorCumulative).av.(*AggregationCountValue)) = 10
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("addSample() after the aggregated value was mutated didn't panic, want panic")
+		}
+	}()
+	v.addSample(ts, int64(1), now)
+}
+
+func Test_Audit_MutatedViewData(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	vd := &ViewData{
+		V: NewView("VC2", "desc VC2", []tags.Key{k1}, nil, NewAggregationCount(), NewWindowCumulative()),
+		Rows: []*Row{
+			{Tags: []tags.Tag{{k1, []byte("v1")}}, AggregationValue: newAggregationCountValue(1)},
+		},
+	}
+	a := newAuditedViewData(vd)
+	a.audit()
+
+	vd.Rows[0].Tags[0].V[0] = 'x'
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("audit() after the ViewData was mutated didn't panic, want panic")
+		}
+	}()
+	a.audit()
+}
@@ -0,0 +1,255 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"github.com/census-instrumentation/opencensus-go/trace"
+	"golang.org/x/net/context"
+)
+
+// raceEnabled is set when the tests are built with the race detector.
+var raceEnabled = false
+
+// recordBudgets are the maximum numbers of allocations per call to
+// RecordInt64, including those made by the worker goroutine, once the rows of
+// the views exist. The commands sent to the worker are pooled, and the
+// signatures of the tags for the views are cached with the interned TagSets,
+// so recording the same tags repeatedly doesn't allocate.
+var recordBudgets = []struct {
+	tags, views int
+	w           Window
+	allocs      float64
+}{
+	{1, 1, NewWindowCumulative(), 0},
+	{4, 10, NewWindowCumulative(), 0},
+	{16, 100, NewWindowCumulative(), 0},
+	{4, 10, NewWindowSlidingTime(time.Hour, 4), 0},
+	{4, 10, NewWindowSlidingCount(1000, 4), 0},
+}
+
+// recorderBudgets are the maximum numbers of allocations per call to
+// RecorderInt64.Record. The recorders cache the signatures of their tags, so
+// they don't allocate.
+var recorderBudgets = []struct {
+	agg    Aggregation
+	allocs float64
+}{
+	{NewAggregationCount(), 0},
+	{NewAggregationDistribution([]float64{0, 10, 100}), 0},
+}
+
+// setupRecordBenchmark restarts the worker and registers views collecting
+// data for a new measure. Each view aggregates all the tags of the returned
+// context.
+func setupRecordBenchmark(nTags, nViews int, w Window) (context.Context, *MeasureInt64) {
+	RestartWorker()
+
+	m, _ := NewMeasureInt64("MBench", "desc MBench", "1")
+	var keys []tags.Key
+	tsb := tags.NewTagSetBuilder(nil)
+	for i := 0; i < nTags; i++ {
+		k, _ := tags.CreateKeyString(fmt.Sprintf("kbench%d", i))
+		keys = append(keys, k)
+		tsb.InsertString(k, fmt.Sprintf("v%d", i))
+	}
+	for i := 0; i < nViews; i++ {
+		v := NewView(fmt.Sprintf("VBench%d", i), "desc VBench", keys, m, NewAggregationCount(), w)
+		if err := ForceCollection(v); err != nil {
+			panic(err)
+		}
+	}
+	return tags.NewContext(context.Background(), tsb.Build()), m
+}
+
+// setupRecorderBenchmark restarts the worker and returns a recorder for a new
+// measure with a cumulative view collecting data with the aggregation agg.
+func setupRecorderBenchmark(agg Aggregation) *RecorderInt64 {
+	RestartWorker()
+
+	m, _ := NewMeasureInt64("MBench", "desc MBench", "1")
+	k, _ := tags.CreateKeyString("kbench0")
+	v := NewView("VBench", "desc VBench", []tags.Key{k}, m, agg, NewWindowCumulative())
+	if err := ForceCollection(v); err != nil {
+		panic(err)
+	}
+	rec := m.RecorderFor(tags.NewTagSetBuilder(nil).InsertString(k, "v").Build())
+	// creates the row.
+	rec.Record(1)
+	return rec
+}
+
+// warmUpPools calls record as many times as the channel of the worker holds,
+// then waits for the worker to handle the records. The channel is buffered, so
+// the commands in flight are only reused from the pools once the pools hold
+// as many commands.
+func warmUpPools(record func()) {
+	for i := 0; i < 2*maxBatchSize; i++ {
+		record()
+	}
+	Flush()
+}
+
+func windowName(w Window) string {
+	switch w.(type) {
+	case *WindowCumulative:
+		return "cumulative"
+	case *WindowSlidingTime:
+		return "slidingtime"
+	case *WindowSlidingCount:
+		return "slidingcount"
+	}
+	return "unknown"
+}
+
+func Test_Record_AllocationBudget(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops objects randomly with the race detector")
+	}
+	for _, tc := range recordBudgets {
+		ctx, m := setupRecordBenchmark(tc.tags, tc.views, tc.w)
+		// creates the rows.
+		RecordInt64(ctx, m, 1)
+
+		record := func() { RecordInt64(ctx, m, 1) }
+		warmUpPools(record)
+		got := testing.AllocsPerRun(1000, record)
+		if got > tc.allocs {
+			t.Errorf("RecordInt64 with %v tags, %v %v views got %v allocations, want at most %v", tc.tags, tc.views, windowName(tc.w), got, tc.allocs)
+		}
+	}
+}
+
+func Test_Recorder_AllocationBudget(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops objects randomly with the race detector")
+	}
+	for _, tc := range recorderBudgets {
+		rec := setupRecorderBenchmark(tc.agg)
+		record := func() { rec.Record(1) }
+		warmUpPools(record)
+		got := testing.AllocsPerRun(1000, record)
+		rec.Close()
+		if got > tc.allocs {
+			t.Errorf("RecorderInt64.Record with aggregation %T got %v allocations, want at most %v", tc.agg, got, tc.allocs)
+		}
+	}
+}
+
+func BenchmarkRecordInt64(b *testing.B) {
+	for _, nTags := range []int{1, 4, 16} {
+		for _, nViews := range []int{1, 10, 100} {
+			for _, w := range []Window{NewWindowCumulative(), NewWindowSlidingTime(time.Hour, 4), NewWindowSlidingCount(1000, 4)} {
+				b.Run(fmt.Sprintf("tags=%d/views=%d/%v", nTags, nViews, windowName(w)), func(b *testing.B) {
+					ctx, m := setupRecordBenchmark(nTags, nViews, w)
+					b.ReportAllocs()
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						RecordInt64(ctx, m, 1)
+					}
+				})
+			}
+		}
+	}
+}
+
+func BenchmarkRecord(b *testing.B) {
+	ctx, m := setupRecordBenchmark(4, 10, NewWindowCumulative())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Record(ctx, m.Is(1))
+	}
+}
+
+func BenchmarkRecorderInt64(b *testing.B) {
+	for _, agg := range []Aggregation{NewAggregationCount(), NewAggregationDistribution([]float64{0, 10, 100})} {
+		b.Run(fmt.Sprintf("%T", agg), func(b *testing.B) {
+			rec := setupRecorderBenchmark(agg)
+			defer rec.Close()
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					rec.Record(1)
+				}
+			})
+		})
+	}
+}
+
+// setupResetSlidingRows returns a view over w, forcibly collected, and a
+// function adding a sample to 100 of its rows.
+func setupResetSlidingRows(w Window) (View, func(now time.Time)) {
+	k, _ := tags.CreateKeyString("kbench0")
+	v := NewView("VBench", "desc VBench", []tags.Key{k}, nil, NewAggregationDistribution([]float64{0, 10, 100}), w)
+	v.startForcedCollection()
+	var tss []*tags.TagSet
+	var sigs []map[View]string
+	for i := 0; i < 100; i++ {
+		tss = append(tss, tags.NewTagSetBuilder(nil).InsertString(k, fmt.Sprintf("v%d", i)).Build())
+		sigs = append(sigs, make(map[View]string))
+	}
+	return v, func(now time.Time) {
+		for j, ts := range tss {
+			v.addSampleWithCache(ts, sigs[j], 1.0, nil, trace.SpanContext{}, now)
+		}
+	}
+}
+
+func Test_ResetSlidingRows_AllocationBudget(t *testing.T) {
+	for _, w := range []Window{NewWindowSlidingTime(time.Minute, 60), NewWindowSlidingCount(1000, 60)} {
+		v, addSamples := setupResetSlidingRows(w)
+		now := time.Now()
+		recycled := testing.AllocsPerRun(10, func() {
+			addSamples(now)
+			v.collector().resetAt(now)
+		})
+		// clearRows drops the aggregators, like the resets did before they
+		// were recycled.
+		dropped := testing.AllocsPerRun(10, func() {
+			addSamples(now)
+			v.collector().clearRows()
+		})
+		// the rows reusing the aggregators of the previous reset don't
+		// allocate their buckets again.
+		if recycled > 100 {
+			t.Errorf("%v: 100 rows reset on each collection got %v allocations, want at most 100 (%v without recycling)", windowName(w), recycled, dropped)
+		}
+	}
+}
+
+// BenchmarkResetSlidingRows measures the collection of 100 rows of a view over
+// a sliding window reset on each collection, once the aggregators dropped by
+// the previous reset are recycled.
+func BenchmarkResetSlidingRows(b *testing.B) {
+	for _, w := range []Window{NewWindowSlidingTime(time.Minute, 60), NewWindowSlidingCount(1000, 60)} {
+		b.Run(windowName(w), func(b *testing.B) {
+			v, addSamples := setupResetSlidingRows(w)
+			now := time.Now()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				addSamples(now)
+				v.collector().resetAt(now)
+			}
+		})
+	}
+}
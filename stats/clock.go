@@ -0,0 +1,132 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the time to the library: the time of the measurements, of
+// the collection of the views and their sliding windows, and the tickers
+// reporting the collected data periodically. It allows tests to advance the
+// time deterministically instead of sleeping. See SetClock and ManualClock.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks at intervals, like a time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// systemClock is the Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// clockHolder holds the Clock of a worker in an atomic.Value, which requires
+// the values it stores to have the same concrete type.
+type clockHolder struct {
+	c Clock
+}
+
+// ManualClock is a Clock whose time only changes when Advance is called. Its
+// tickers tick when the time is advanced past their next tick. It is safe for
+// concurrent use.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers map[*manualTicker]bool
+}
+
+// NewManualClock returns a ManualClock set to now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{
+		now:     now,
+		tickers: make(map[*manualTicker]bool),
+	}
+}
+
+// Now returns the current time of the clock.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a Ticker ticking every d of the clock's time. It panics
+// if d is not positive, like time.NewTicker.
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for ManualClock.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &manualTicker{
+		clock: c,
+		d:     d,
+		next:  c.now.Add(d),
+		c:     make(chan time.Time, 1),
+	}
+	c.tickers[t] = true
+	return t
+}
+
+// Advance advances the time of the clock by d, and ticks the tickers whose
+// next tick is reached. Like a time.Ticker, a ticker drops the ticks its
+// reader doesn't keep up with.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for t := range c.tickers {
+		for !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.d)
+		}
+	}
+}
+
+type manualTicker struct {
+	clock *ManualClock
+	d     time.Duration
+	next  time.Time
+	c     chan time.Time
+}
+
+func (t *manualTicker) C() <-chan time.Time { return t.c }
+
+func (t *manualTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	delete(t.clock.tickers, t)
+}
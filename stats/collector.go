@@ -16,11 +16,17 @@
 package stats
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/census-instrumentation/opencensus-go/tags"
 )
 
+// resetEpochs is the last reset epoch of all the collectors. The epochs are
+// unique across the views, so that the subscriptions moved to another view
+// (see ReplaceView) see the change.
+var resetEpochs uint64
+
 type collector struct {
 	// signatures holds the aggregations values for each unique tag signature
 	// (values for all keys) to its Window.
@@ -31,29 +37,276 @@ type collector struct {
 
 	// window is the window under which the aggregation is performed.
 	w Window
+
+	// start is the time from which the data currently held was collected. It
+	// is zero until the collection starts.
+	start time.Time
+
+	// resets is the epoch of the last restart of the collection, or of the
+	// last clearing or restoration of the data. The consumers record the
+	// epoch of the last ViewData they got, and the next one is marked as
+	// Reset if it changed since.
+	resets uint64
+
+	// scale is the factor the collected values are scaled by when only a
+	// fraction of the samples is aggregated. Zero means no scaling.
+	scale float64
+
+	// spare holds the aggregators of the rows dropped by the last reset,
+	// which are reused for the new rows rather than allocating new ones. See
+	// resetAt.
+	spare []recyclableAggregator
+
+	// maxRows is the maximum number of signatures collected. Zero means no
+	// limit.
+	maxRows int
+	// dropped is the number of samples dropped because maxRows was reached.
+	dropped uint64
+
+	// exemplars holds the last measurement recorded with attachments for each
+	// tag signature.
+	exemplars map[string]*Exemplar
+	// spanExemplars holds, for each signature, the last sample recorded in a
+	// sampled span in each bucket of the distribution, or in the row for the
+	// other aggregations.
+	spanExemplars map[string][]*Exemplar
+
+	// rate derives the rates reported by the views created with NewRateView
+	// from the counts collected. It is nil for the other views.
+	rate *rateState
+	// ratio computes the rows of the views created with NewRatioView from
+	// the rows of their numerator and denominator views. It is nil for the
+	// other views.
+	ratio *ratioState
+}
+
+func newCollector(a Aggregation, w Window) *collector {
+	return &collector{
+		signatures: make(map[string]aggregator),
+		a:          a,
+		w:          w,
+	}
 }
 
 func (c *collector) addSample(s string, v interface{}, now time.Time) {
+	if aggregator := c.aggregator(s, now); aggregator != nil {
+		aggregator.addSample(v, now)
+	}
+}
+
+// addCount adds n samples to the row s. It only supports the counts over
+// cumulative windows, where the values of the samples don't matter.
+func (c *collector) addCount(s string, n int64, now time.Time) {
+	if a, ok := c.aggregator(s, now).(*aggregatorCumulative); ok {
+		a.add(newAggregationCountValue(n))
+	}
+}
+
+// aggregator returns the aggregator of the row s, creating it if needed. It
+// returns nil if the row limit of the collector is reached.
+func (c *collector) aggregator(s string, now time.Time) aggregator {
+	if c.start.IsZero() {
+		c.start = now
+	}
 	aggregator, ok := c.signatures[s]
 	if !ok {
-		aggregator = c.w.newAggregator(now, c.a.aggregationValueConstructor())
+		if c.maxRows > 0 && len(c.signatures) >= c.maxRows {
+			c.dropped++
+			return nil
+		}
+		if n := len(c.spare); n > 0 {
+			a := c.spare[n-1]
+			c.spare[n-1] = nil
+			c.spare = c.spare[:n-1]
+			a.reset(now)
+			aggregator = a
+		} else {
+			aggregator = c.w.newAggregator(now, c.a.aggregationValueConstructor())
+		}
 		c.signatures[s] = aggregator
 	}
-	aggregator.addSample(v, now)
+	return aggregator
 }
 
 func (c *collector) collectedRows(keys []tags.Key, now time.Time) []*Row {
-	var rows []*Row
+	return c.appendCollectedRows(nil, keys, now)
+}
+
+// appendCollectedRows appends the collected rows to rows. The Row structs
+// between the length and the capacity of rows are reused.
+func (c *collector) appendCollectedRows(rows []*Row, keys []tags.Key, now time.Time) []*Row {
+	return c.appendFilteredRows(rows, keys, nil, now)
+}
+
+// appendFilteredRows is like appendCollectedRows but only the rows whose tags
+// satisfy pred are retrieved and appended, unless pred is nil.
+func (c *collector) appendFilteredRows(rows []*Row, keys []tags.Key, pred func([]tags.Tag) bool, now time.Time) []*Row {
+	if c.ratio != nil {
+		return c.ratio.appendRows(rows, keys, pred, now)
+	}
 	for sig, aggregator := range c.signatures {
 		ts := tags.ToOrderedTagsSlice(sig, keys)
-		rows = append(rows, &Row{
-			ts,
-			aggregator.retrieveCollected(now),
-		})
+		if pred != nil && !pred(ts) {
+			continue
+		}
+		av := aggregator.retrieveCollected(now)
+		if c.scale != 0 {
+			av = scaleAggregationValue(av, c.scale)
+		}
+		var start time.Time
+		if a, ok := aggregator.(*aggregatorCumulative); ok {
+			start = a.started
+		}
+		if c.rate != nil {
+			av = c.rate.derive(sig, av, c.windowStart(now), now)
+			start = time.Time{}
+		}
+		n := len(rows)
+		if n == cap(rows) {
+			rows = append(rows, &Row{ts, av, start})
+			continue
+		}
+		rows = rows[:n+1]
+		if rows[n] == nil {
+			rows[n] = &Row{}
+		}
+		*rows[n] = Row{ts, av, start}
 	}
 	return rows
 }
 
+func (c *collector) addExemplar(s string, e *Exemplar) {
+	if c.exemplars == nil {
+		c.exemplars = make(map[string]*Exemplar)
+	}
+	c.exemplars[s] = e
+}
+
+// addSpanExemplar records e as the exemplar of its bucket in the row s.
+func (c *collector) addSpanExemplar(s string, e *Exemplar) {
+	bounds := distributionBounds(c.a)
+	if c.spanExemplars == nil {
+		c.spanExemplars = make(map[string][]*Exemplar)
+	}
+	es := c.spanExemplars[s]
+	if es == nil {
+		es = make([]*Exemplar, len(bounds)+1)
+		c.spanExemplars[s] = es
+	}
+	var f float64
+	switch x := e.Value.(type) {
+	case int64:
+		f = float64(x)
+	case float64:
+		f = x
+	}
+	i := 0
+	for i < len(bounds) && f >= bounds[i] {
+		i++
+	}
+	es[i] = e
+}
+
+// distributionBounds returns the bounds of the distribution aggregated by a,
+// or of the first distribution of a multi aggregation. It returns nil if a
+// doesn't aggregate a distribution.
+func distributionBounds(a Aggregation) []float64 {
+	switch a := a.(type) {
+	case *AggregationDistribution:
+		return a.bounds
+	case *AggregationMulti:
+		for _, agg := range a.aggs {
+			if d, ok := agg.(*AggregationDistribution); ok {
+				return d.bounds
+			}
+		}
+	}
+	return nil
+}
+
+func (c *collector) collectedExemplars(keys []tags.Key) []*Exemplar {
+	var exemplars []*Exemplar
+	for sig, e := range c.exemplars {
+		exemplars = append(exemplars, &Exemplar{
+			Tags:        tags.ToOrderedTagsSlice(sig, keys),
+			Value:       e.Value,
+			Time:        e.Time,
+			Attachments: e.Attachments,
+		})
+	}
+	for sig, es := range c.spanExemplars {
+		for _, e := range es {
+			if e == nil {
+				continue
+			}
+			exemplars = append(exemplars, &Exemplar{
+				Tags:        tags.ToOrderedTagsSlice(sig, keys),
+				Value:       e.Value,
+				Time:        e.Time,
+				Attachments: e.Attachments,
+				SpanContext: e.SpanContext,
+			})
+		}
+	}
+	return exemplars
+}
+
+// markReset starts a new reset epoch for c, so that the next ViewData of
+// all the consumers is marked as Reset.
+func (c *collector) markReset() {
+	c.resets = atomic.AddUint64(&resetEpochs, 1)
+}
+
 func (c *collector) clearRows() {
 	c.signatures = make(map[string]aggregator)
+	c.exemplars = nil
+	c.spanExemplars = nil
+	c.start = time.Time{}
+	c.spare = nil
+	if c.rate != nil {
+		c.rate.clear()
+	}
+}
+
+// resetAt clears the data collected and starts a new collection at now. The
+// aggregators of the sliding windows are kept to be reused by the next rows,
+// which avoids allocating the aggregation values of all their buckets again
+// for the views reset on each collection. The spare aggregators not reused
+// until the next reset are released. The map of the rows is reused too.
+func (c *collector) resetAt(now time.Time) {
+	spare := c.spare[:0]
+	for _, a := range c.signatures {
+		if r, ok := a.(recyclableAggregator); ok {
+			spare = append(spare, r)
+		}
+	}
+	for i := len(spare); i < len(c.spare); i++ {
+		c.spare[i] = nil
+	}
+	signatures := c.signatures
+	for s := range signatures {
+		delete(signatures, s)
+	}
+	c.clearRows()
+	c.signatures = signatures
+	c.spare = spare
+	c.start = now
+}
+
+// windowStart returns the start of the window the data collected at now
+// covers.
+func (c *collector) windowStart(now time.Time) time.Time {
+	if c.ratio != nil {
+		return c.ratio.num.collector().windowStart(now)
+	}
+	start := c.start
+	if start.IsZero() {
+		start = now
+	}
+	if w, ok := c.w.(*WindowSlidingTime); ok {
+		if s := now.Add(-w.duration); s.After(start) {
+			start = s
+		}
+	}
+	return start
 }
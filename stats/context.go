@@ -0,0 +1,56 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"sync/atomic"
+
+	"github.com/census-instrumentation/opencensus-go/trace"
+	"golang.org/x/net/context"
+)
+
+type recordingDisabledKey struct{}
+
+// WithRecordingDisabled returns a copy of ctx in which recording is disabled:
+// the Record functions called with the returned context, or any context
+// derived from it, don't record anything. It allows frameworks to exclude
+// some requests (e.g. health checks or internal probes) from the stats.
+func WithRecordingDisabled(ctx context.Context) context.Context {
+	return context.WithValue(ctx, recordingDisabledKey{}, true)
+}
+
+// recordingDisabled returns true if recording is disabled in ctx.
+func recordingDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(recordingDisabledKey{}).(bool)
+	return disabled
+}
+
+// spanExemplarViews is the number of views created with WithSpanExemplars. The
+// span context of the measurements is only looked up once there is one.
+var spanExemplarViews int32
+
+// spanOf returns the span context of the current span of ctx if it is
+// sampled and some views record span exemplars. It returns the zero span
+// context otherwise.
+func spanOf(ctx context.Context) trace.SpanContext {
+	if atomic.LoadInt32(&spanExemplarViews) == 0 {
+		return trace.SpanContext{}
+	}
+	if sc, ok := trace.FromContext(ctx); ok && sc.IsSampled() {
+		return sc
+	}
+	return trace.SpanContext{}
+}
@@ -0,0 +1,54 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// NewDebugHandler returns an http.Handler serving a plain text debug page. The
+// page lists the raw samples retained for the view whose name is passed as the
+// "view" query parameter. See KeepRecentSamples.
+func NewDebugHandler() http.Handler {
+	return http.HandlerFunc(serveRecentSamples)
+}
+
+func serveRecentSamples(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("view")
+	if name == "" {
+		http.Error(w, "missing 'view' query parameter", http.StatusBadRequest)
+		return
+	}
+
+	v, err := GetViewByName(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	samples, err := RetrieveRecentSamples(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "view %v: %v recent sample(s)\n", v.Name(), len(samples))
+	for _, s := range samples {
+		fmt.Fprintf(w, "%v\n", s)
+	}
+}
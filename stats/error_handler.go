@@ -0,0 +1,87 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"fmt"
+	"log"
+)
+
+// ErrorHandler handles the errors the library cannot return to a caller. See
+// SetErrorHandler.
+type ErrorHandler func(err error)
+
+// errorHandlerHolder holds the ErrorHandler of a worker in an atomic.Value,
+// which requires values of the same concrete type.
+type errorHandlerHolder struct {
+	h ErrorHandler
+}
+
+// SetErrorHandler sets the handler of the errors the library cannot return to
+// a caller. The library recovers the panics of the code it calls on behalf of
+// the user, i.e. the record hooks, the tag extractors, the predicates and the
+// callbacks of the watchers, as well as the delivery of the ViewData to the
+// channels of the subscribers, e.g. closed by mistake, and reports them as
+// errors of kind ErrPanic, so that a buggy exporter cannot stop the library's
+// goroutine and silently stop the collection of all the metrics. The errors
+// are logged with the log package until a handler is set. h is called by the
+// goroutine the error occurred on, possibly the library's goroutine, so it
+// must be quick and must not call the functions of the registry. A nil h
+// restores the default handler.
+func (r *Registry) SetErrorHandler(h ErrorHandler) {
+	r.w.errorHandler.Store(errorHandlerHolder{h})
+}
+
+// SetErrorHandler is like Registry.SetErrorHandler for the default registry.
+func SetErrorHandler(h ErrorHandler) {
+	defaultRegistry.SetErrorHandler(h)
+}
+
+// HandleError reports err to the error handler of the registry. It lets the
+// exporters and the packages built on the registry report the errors they
+// cannot return either. See SetErrorHandler.
+func (r *Registry) HandleError(err error) {
+	r.w.handleError(err)
+}
+
+// HandleError is like Registry.HandleError for the default registry.
+func HandleError(err error) {
+	defaultRegistry.HandleError(err)
+}
+
+func (w *worker) handleError(err error) {
+	if h, _ := w.errorHandler.Load().(errorHandlerHolder); h.h != nil {
+		h.h(err)
+		return
+	}
+	log.Printf("opencensus: %v", err)
+}
+
+// handlePanic reports the value p recovered from a panic as an error of kind
+// ErrPanic. format and args describe the code that panicked.
+func (w *worker) handlePanic(p interface{}, format string, args ...interface{}) {
+	w.handleError(newError(ErrPanic, "%v panicked: %v", fmt.Sprintf(format, args...), p))
+}
+
+// protect calls f, and reports its panic, if any, with handlePanic.
+func (w *worker) protect(f func(), format string, args ...interface{}) {
+	defer func() {
+		if p := recover(); p != nil {
+			w.handlePanic(p, format, args...)
+		}
+	}()
+	f()
+}
@@ -0,0 +1,108 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// The kinds of the errors returned by the library. The errors returned carry
+// a detailed message and unwrap to one of these errors, so callers can branch
+// on the failure reason with errors.Is(err, ErrDuplicateMeasure).
+var (
+	// ErrDuplicateMeasure is the reason of failure when a different measure
+	// with the same name is already registered.
+	ErrDuplicateMeasure = errors.New("duplicate measure")
+	// ErrMeasureNotRegistered is the reason of failure when no measure with
+	// the requested name is registered.
+	ErrMeasureNotRegistered = errors.New("measure not registered")
+	// ErrMeasureInUse is the reason of failure when deleting a measure still
+	// referred to by registered views.
+	ErrMeasureInUse = errors.New("measure in use")
+	// ErrDuplicateView is the reason of failure when a different view with
+	// the same name is already registered.
+	ErrDuplicateView = errors.New("duplicate view")
+	// ErrViewNotRegistered is the reason of failure when the view is not
+	// registered.
+	ErrViewNotRegistered = errors.New("view not registered")
+	// ErrViewCollecting is the reason of failure when unregistering a view
+	// which still has subscribers or whose collection is forced.
+	ErrViewCollecting = errors.New("view collecting")
+	// ErrViewNotCollecting is the reason of failure when retrieving the data
+	// of a view which isn't collecting data.
+	ErrViewNotCollecting = errors.New("view not collecting")
+	// ErrInvalidUnit is the reason of failure when a unit isn't in the
+	// supported subset of UCUM.
+	ErrInvalidUnit = errors.New("invalid unit")
+	// ErrIncompatibleUnits is the reason of failure when converting values
+	// between units of different dimensions.
+	ErrIncompatibleUnits = errors.New("incompatible units")
+	// ErrNegativeValue is the reason of failure when recording a negative
+	// value for a measure constrained to ConstraintNonNegative.
+	ErrNegativeValue = errors.New("negative value")
+	// ErrPanic is the kind of the errors reported to the error handler when
+	// the code called by the library on behalf of the user, e.g. a record
+	// hook, panicked. See SetErrorHandler.
+	ErrPanic = errors.New("panic")
+)
+
+// statsError is an error with a detailed message unwrapping to its kind.
+type statsError struct {
+	kind error
+	msg  string
+}
+
+func newError(kind error, format string, args ...interface{}) error {
+	return &statsError{
+		kind: kind,
+		msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+// wrapError returns a new error of the same kind as err with the message
+// built from format and args.
+func wrapError(err error, format string, args ...interface{}) error {
+	var kind error
+	if se, ok := err.(*statsError); ok {
+		kind = se.kind
+	}
+	return newError(kind, format, args...)
+}
+
+func (e *statsError) Error() string { return e.msg }
+
+// Unwrap returns the kind of the error, e.g. ErrDuplicateMeasure.
+func (e *statsError) Unwrap() error { return e.kind }
+
+// MultiError is returned by the bulk operations (e.g. RegisterViews) to
+// report all the errors that caused the operation to fail.
+type MultiError []error
+
+func (me MultiError) Error() string {
+	var buf bytes.Buffer
+	for i, err := range me {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		buf.WriteString(err.Error())
+	}
+	return buf.String()
+}
+
+// Unwrap returns the errors reported by me.
+func (me MultiError) Unwrap() []error { return me }
@@ -0,0 +1,89 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"golang.org/x/net/context"
+)
+
+func Test_Errors_Kinds(t *testing.T) {
+	RestartWorker()
+
+	m := MustNewMeasureInt64("MI1", "desc MI1", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VI1", "desc VI1", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	MustRegisterView(v)
+	unregistered := NewView("VI2", "desc VI2", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+
+	_, errNewMeasure := NewMeasureInt64("MI1", "desc MI1", "1")
+	_, errInvalidUnit := NewMeasureFloat64("MF1", "desc MF1", "furlong")
+	_, errConvert := UnitBytes.Convert(1, UnitSeconds)
+	_, errGetMeasure := GetMeasureByName("unknown")
+	_, errGetView := GetViewByName("unknown")
+	_, errRetrieveUnregistered := RetrieveData(unregistered)
+	_, errRetrieveNotCollecting := RetrieveData(v)
+	errRegisterDuplicate := RegisterView(NewView("VI1", "desc VI1", nil, m, NewAggregationCount(), NewWindowCumulative()))
+	errSubscribeDuplicate := SubscribeToView(NewView("VI1", "desc VI1", nil, m, NewAggregationCount(), NewWindowCumulative()), make(chan *ViewData))
+	errDeleteMeasure := DeleteMeasure(m)
+	ForceCollection(v)
+	errUnregister := UnregisterView(v)
+	constrained := MustNewMeasureInt64("MI2", "desc MI2", "1")
+	constrained.SetConstraint(ConstraintNonNegative)
+	errRecordNegative := RecordChecked(context.Background(), constrained.Is(-1))
+
+	type testCase struct {
+		label string
+		err   error
+		want  error
+	}
+	tcs := []testCase{
+		{"NewMeasureInt64 duplicate", errNewMeasure, ErrDuplicateMeasure},
+		{"NewMeasureFloat64 invalid unit", errInvalidUnit, ErrInvalidUnit},
+		{"Unit.Convert incompatible", errConvert, ErrIncompatibleUnits},
+		{"GetMeasureByName", errGetMeasure, ErrMeasureNotRegistered},
+		{"GetViewByName", errGetView, ErrViewNotRegistered},
+		{"RetrieveData unregistered", errRetrieveUnregistered, ErrViewNotRegistered},
+		{"RetrieveData not collecting", errRetrieveNotCollecting, ErrViewNotCollecting},
+		{"RegisterView duplicate", errRegisterDuplicate, ErrDuplicateView},
+		{"SubscribeToView duplicate", errSubscribeDuplicate, ErrDuplicateView},
+		{"DeleteMeasure in use", errDeleteMeasure, ErrMeasureInUse},
+		{"UnregisterView collecting", errUnregister, ErrViewCollecting},
+		{"RecordChecked negative", errRecordNegative, ErrNegativeValue},
+	}
+	for _, tc := range tcs {
+		if !errors.Is(tc.err, tc.want) {
+			t.Errorf("Test case '%v': got error '%v', want an error of kind '%v'", tc.label, tc.err, tc.want)
+		}
+	}
+}
+
+func Test_Errors_Must(t *testing.T) {
+	RestartWorker()
+
+	MustNewMeasureFloat64("MF1", "desc MF1", "1")
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if !ok || !errors.Is(err, ErrDuplicateMeasure) {
+			t.Errorf("MustNewMeasureFloat64 with a duplicate name panicked with %v, want an ErrDuplicateMeasure error", r)
+		}
+	}()
+	MustNewMeasureFloat64("MF1", "desc MF1", "1")
+}
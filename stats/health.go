@@ -0,0 +1,247 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+// queueDelaySampling is the number of record commands handled by the worker
+// for each one whose queue delay is measured.
+const queueDelaySampling = 64
+
+var healthMillisBucketBoundaries = []float64{0, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 50, 100, 500, 1000, 5000}
+
+// HealthViews are the views the library reports its own health with. They
+// allow to detect when the instrumentation is unhealthy, e.g. when the worker
+// goroutine falls behind or subscribers don't keep up. Like any other views,
+// they only collect data once subscribed to or forced to collect.
+type HealthViews struct {
+	// RecordsProcessed counts the measurements aggregated by the library.
+	RecordsProcessed View
+	// RecordsDropped counts the measurements dropped by the library, e.g.
+	// after Shutdown or because their measure was deleted.
+	RecordsDropped View
+	// RecordsRejected counts, for each measure, the NaN and infinite
+	// measurements rejected according to the NonFinitePolicy of the measure,
+	// and the measurements violating its ValueConstraint.
+	RecordsRejected View
+	// ViewDataDropped counts, for each view, the ViewData that couldn't be
+	// delivered to a subscriber because its channel was full, according to
+	// the backpressure policy of the subscription.
+	ViewDataDropped View
+	// QueueDelay is the distribution of the time in milliseconds a
+	// measurement waits for the library's goroutine before being aggregated.
+	// It is measured for one measurement out of 64.
+	QueueDelay View
+	// CollectionLatency is the distribution, for each view, of the time in
+	// milliseconds spent collecting and delivering its data to its
+	// subscribers.
+	CollectionLatency View
+}
+
+// health holds the measures of the health views of a worker and the counts
+// of measurements pending until the next report. It is only used by the
+// worker goroutine. Its methods do nothing on a nil health so the worker can
+// call them whether the health views are enabled or not.
+type health struct {
+	views *HealthViews
+
+	processed, dropped, rejected, viewDataDropped *MeasureInt64
+	queueDelay, collectionLatency                 *MeasureFloat64
+
+	// key is the key of the name of the view in the views reported for
+	// each view, and tagSets holds the TagSet of each view. empty is the
+	// TagSet of the other views.
+	key     *tags.KeyString
+	tagSets map[string]*tags.TagSet
+	empty   *tags.TagSet
+
+	// measureKey is the key of the name of the measure in the views
+	// reported for each measure, and measureTagSets holds the TagSet of each
+	// measure.
+	measureKey     *tags.KeyString
+	measureTagSets map[string]*tags.TagSet
+
+	pendingProcessed, pendingDropped int64
+	records                          uint64
+
+	// now returns the current time of the clock of the worker.
+	now func() time.Time
+}
+
+func newHealth(w *worker) (*health, error) {
+	key, err := tags.CreateKeyString("opencensus.io/view")
+	if err != nil {
+		return nil, err
+	}
+	measureKey, err := tags.CreateKeyString("opencensus.io/measure")
+	if err != nil {
+		return nil, err
+	}
+	h := &health{
+		key:            key,
+		tagSets:        make(map[string]*tags.TagSet),
+		empty:          tags.NewTagSetBuilder(nil).Build(),
+		measureKey:     measureKey,
+		measureTagSets: make(map[string]*tags.TagSet),
+		now:            w.now,
+	}
+	newInt64 := func(name, description string) *MeasureInt64 {
+		return &MeasureInt64{name: name, description: description, unit: "1", views: make(map[View]bool), r: w.r}
+	}
+	newFloat64 := func(name, description string) *MeasureFloat64 {
+		return &MeasureFloat64{name: name, description: description, unit: "ms", views: make(map[View]bool), r: w.r}
+	}
+	h.processed = newInt64("opencensus.io/stats/records_processed", "Number of measurements aggregated")
+	h.dropped = newInt64("opencensus.io/stats/records_dropped", "Number of measurements dropped")
+	h.rejected = newInt64("opencensus.io/stats/records_rejected", "Number of NaN, infinite and constraint violating measurements rejected")
+	h.viewDataDropped = newInt64("opencensus.io/stats/viewdata_dropped", "Number of ViewData not delivered to a subscriber")
+	h.queueDelay = newFloat64("opencensus.io/stats/queue_delay", "Time a measurement waits before being aggregated in msecs")
+	h.collectionLatency = newFloat64("opencensus.io/stats/collection_latency", "Time spent collecting and delivering the data of a view in msecs")
+
+	millis := NewAggregationDistribution(healthMillisBucketBoundaries)
+	h.views = &HealthViews{
+		RecordsProcessed:  NewView("opencensus.io/stats/records_processed/cumulative", "Number of measurements aggregated", nil, h.processed, NewAggregationCount(), NewWindowCumulative()),
+		RecordsDropped:    NewView("opencensus.io/stats/records_dropped/cumulative", "Number of measurements dropped", nil, h.dropped, NewAggregationCount(), NewWindowCumulative()),
+		RecordsRejected:   NewView("opencensus.io/stats/records_rejected/cumulative", "Number of NaN, infinite and constraint violating measurements rejected", []tags.Key{measureKey}, h.rejected, NewAggregationCount(), NewWindowCumulative()),
+		ViewDataDropped:   NewView("opencensus.io/stats/viewdata_dropped/cumulative", "Number of ViewData not delivered to a subscriber", []tags.Key{key}, h.viewDataDropped, NewAggregationCount(), NewWindowCumulative()),
+		QueueDelay:        NewView("opencensus.io/stats/queue_delay/distribution_cumulative", "Time a measurement waits before being aggregated in msecs", nil, h.queueDelay, millis, NewWindowCumulative()),
+		CollectionLatency: NewView("opencensus.io/stats/collection_latency/distribution_cumulative", "Time spent collecting and delivering the data of a view in msecs", []tags.Key{key}, h.collectionLatency, millis, NewWindowCumulative()),
+	}
+	for _, v := range []View{h.views.RecordsProcessed, h.views.RecordsDropped, h.views.RecordsRejected, h.views.ViewDataDropped, h.views.QueueDelay, h.views.CollectionLatency} {
+		if err := w.tryRegisterView(v); err != nil {
+			return nil, fmt.Errorf("cannot enable the health views: %v", err)
+		}
+	}
+	return h, nil
+}
+
+// process counts n measurements aggregated.
+func (h *health) process(n int64) {
+	if h != nil {
+		h.pendingProcessed += n
+	}
+}
+
+// drop counts n measurements dropped.
+func (h *health) drop(n int64) {
+	if h != nil {
+		h.pendingDropped += n
+	}
+}
+
+// sampleQueueDelay measures the queue delay of one record command out of
+// queueDelaySampling.
+func (h *health) sampleQueueDelay(cmd command) {
+	if h == nil {
+		return
+	}
+	h.records++
+	if h.records%queueDelaySampling != 1 {
+		return
+	}
+	var recorded time.Time
+	switch cmd := cmd.(type) {
+	case *recordFloat64Req:
+		recorded = cmd.now
+	case *recordInt64Req:
+		recorded = cmd.now
+	case *recordDurationReq:
+		recorded = cmd.now
+	case *recordReq:
+		recorded = cmd.now
+	case *recordWithRecorderReq:
+		recorded = cmd.now
+	default:
+		return
+	}
+	now := h.now()
+	h.add(h.queueDelay, h.empty, float64(now.Sub(recorded))/float64(time.Millisecond), now)
+}
+
+// reject counts a measurement of m rejected according to its NonFinitePolicy
+// or its ValueConstraint.
+func (h *health) reject(m Measure, now time.Time) {
+	if h == nil {
+		return
+	}
+	ts, ok := h.measureTagSets[m.Name()]
+	if !ok {
+		ts = tags.NewTagSetBuilder(nil).UpsertString(h.measureKey, m.Name()).Build()
+		h.measureTagSets[m.Name()] = ts
+	}
+	h.addCount(h.rejected, ts, 1, now)
+}
+
+// dropViewData counts n ViewData of v not delivered to a subscriber.
+func (h *health) dropViewData(v View, n int64, now time.Time) {
+	if h != nil {
+		h.addCount(h.viewDataDropped, h.tagSet(v), n, now)
+	}
+}
+
+// collected reports that the data of v was collected and delivered from start
+// to now.
+func (h *health) collected(v View, start, now time.Time) {
+	if h != nil {
+		h.add(h.collectionLatency, h.tagSet(v), float64(now.Sub(start))/float64(time.Millisecond), now)
+	}
+}
+
+// report adds the pending counts of measurements to the views.
+func (h *health) report(now time.Time) {
+	if h == nil {
+		return
+	}
+	h.addCount(h.processed, h.empty, h.pendingProcessed, now)
+	h.addCount(h.dropped, h.empty, h.pendingDropped, now)
+	h.pendingProcessed, h.pendingDropped = 0, 0
+}
+
+func (h *health) tagSet(v View) *tags.TagSet {
+	ts, ok := h.tagSets[v.Name()]
+	if !ok {
+		ts = tags.NewTagSetBuilder(nil).UpsertString(h.key, v.Name()).Build()
+		h.tagSets[v.Name()] = ts
+	}
+	return ts
+}
+
+// addCount adds n samples to the views of m counting them, and the sample n
+// to its other views.
+func (h *health) addCount(m *MeasureInt64, ts *tags.TagSet, n int64, now time.Time) {
+	if n == 0 {
+		return
+	}
+	for v := range m.views {
+		if isCountingView(v) {
+			v.addCountWithCache(ts, nil, n, now)
+		} else {
+			v.addSample(ts, n, now)
+		}
+	}
+}
+
+// add adds the sample val to the views of m.
+func (h *health) add(m *MeasureFloat64, ts *tags.TagSet, val float64, now time.Time) {
+	for v := range m.views {
+		v.addSample(ts, val, now)
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package loadshed tells a service when to shed load, i.e. reject requests
+// early, from the latency and the errors of the requests served by a named
+// resource over a sliding window:
+//
+//	s, err := loadshed.New("db", loadshed.Config{
+//		MaxErrorRatio:  0.5,
+//		MaxMeanLatency: 200 * time.Millisecond,
+//	})
+//	if err != nil {
+//		// handle error
+//	}
+//	defer s.Close()
+//
+//	if s.ShouldShed() {
+//		return errOverloaded
+//	}
+//	start := time.Now()
+//	err = query(ctx)
+//	s.Observe(time.Since(start), err)
+//
+// The requests are aggregated by a view of the stats package over a
+// WindowSlidingTime, which can be subscribed to like any other view.
+package loadshed
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"golang.org/x/net/context"
+)
+
+// Config holds the thresholds of a Shedder and the window they are evaluated
+// over. The zero values select the defaults.
+type Config struct {
+	// Window is the duration of the sliding window the requests are
+	// aggregated over, and SubIntervals the number of its sub intervals. They
+	// default to 10s and 10.
+	Window       time.Duration
+	SubIntervals int
+
+	// MinRequests is the number of requests in the window below which load is
+	// never shed, so that a few failures of an idle resource don't make it
+	// reject the next requests. It defaults to 20.
+	MinRequests int64
+
+	// MaxErrorRatio is the ratio of failed requests in the window above which
+	// load is shed. Zero disables it.
+	MaxErrorRatio float64
+	// MaxMeanLatency is the mean latency of the requests in the window above
+	// which load is shed. Zero disables it.
+	MaxMeanLatency time.Duration
+
+	// RefreshInterval is the time during which ShouldShed returns the same
+	// answer before evaluating the window again, which keeps it cheap enough
+	// to be called for each request. It defaults to 100ms, and a negative
+	// interval evaluates the window at each call.
+	RefreshInterval time.Duration
+
+	// Registry is the registry of the measure and the view of the Shedder.
+	// It defaults to the default registry.
+	Registry *stats.Registry
+}
+
+// Shedder decides whether to shed load from the requests observed for a
+// resource. It is safe for concurrent use.
+type Shedder struct {
+	cfg      Config
+	r        registry
+	m        *stats.MeasureFloat64
+	v        stats.View
+	ok, fail context.Context
+
+	mu          sync.Mutex
+	shed        bool
+	evaluatedAt time.Time
+}
+
+var outcomeKey *tags.KeyString
+
+func init() {
+	var err error
+	if outcomeKey, err = tags.CreateKeyString("opencensus.io/loadshed/outcome"); err != nil {
+		panic(err)
+	}
+}
+
+// New returns a Shedder for the resource named name, whose requests are
+// aggregated by the view "opencensus.io/loadshed/<name>/latency" of the
+// measure with the same name. The view is collected until Close is called.
+func New(name string, cfg Config) (*Shedder, error) {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.SubIntervals <= 0 {
+		cfg.SubIntervals = 10
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 20
+	}
+	if cfg.RefreshInterval == 0 {
+		cfg.RefreshInterval = 100 * time.Millisecond
+	}
+	r := registry(defaultRegistry{})
+	if cfg.Registry != nil {
+		r = cfg.Registry
+	}
+
+	mname := fmt.Sprintf("opencensus.io/loadshed/%v/latency", name)
+	m, err := r.NewMeasureFloat64(mname, fmt.Sprintf("Latency of the requests of %v", name), "ms")
+	if err != nil {
+		return nil, err
+	}
+	v := stats.NewView(mname, fmt.Sprintf("Latency of the requests of %v by outcome", name), []tags.Key{outcomeKey}, m, stats.NewAggregationDistribution(nil), stats.NewWindowSlidingTime(cfg.Window, cfg.SubIntervals))
+	if err := r.RegisterView(v); err != nil {
+		r.DeleteMeasure(m)
+		return nil, err
+	}
+	if err := r.ForceCollection(v); err != nil {
+		r.UnregisterView(v)
+		r.DeleteMeasure(m)
+		return nil, err
+	}
+
+	return &Shedder{
+		cfg:  cfg,
+		r:    r,
+		m:    m,
+		v:    v,
+		ok:   outcomeContext("ok"),
+		fail: outcomeContext("error"),
+	}, nil
+}
+
+func outcomeContext(outcome string) context.Context {
+	return tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(outcomeKey, outcome).Build())
+}
+
+// View returns the view aggregating the requests of s.
+func (s *Shedder) View() stats.View {
+	return s.v
+}
+
+// Observe records a request served in latency, which failed if err isn't nil.
+func (s *Shedder) Observe(latency time.Duration, err error) {
+	ctx := s.ok
+	if err != nil {
+		ctx = s.fail
+	}
+	stats.RecordFloat64(ctx, s.m, float64(latency)/float64(time.Millisecond))
+}
+
+// ShouldShed returns true if the requests of the window exceed one of the
+// thresholds of s. The window is evaluated at most once per RefreshInterval.
+func (s *Shedder) ShouldShed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.cfg.RefreshInterval > 0 && !s.evaluatedAt.IsZero() && now.Sub(s.evaluatedAt) < s.cfg.RefreshInterval {
+		return s.shed
+	}
+	s.shed = s.evaluate()
+	s.evaluatedAt = now
+	return s.shed
+}
+
+// evaluate returns true if the requests currently in the window exceed one of
+// the thresholds of s.
+func (s *Shedder) evaluate() bool {
+	rows, err := s.r.RetrieveData(s.v)
+	if err != nil {
+		return false
+	}
+	var count, failed int64
+	var sum float64
+	for _, r := range rows {
+		d, ok := r.AggregationValue.(*stats.AggregationDistributionValue)
+		if !ok {
+			continue
+		}
+		count += d.Count()
+		sum += d.Sum()
+		for _, t := range r.Tags {
+			if t.K == outcomeKey && string(t.V) == "error" {
+				failed += d.Count()
+			}
+		}
+	}
+	if count == 0 || count < s.cfg.MinRequests {
+		return false
+	}
+	if s.cfg.MaxErrorRatio > 0 && float64(failed)/float64(count) > s.cfg.MaxErrorRatio {
+		return true
+	}
+	maxMean := float64(s.cfg.MaxMeanLatency) / float64(time.Millisecond)
+	return maxMean > 0 && sum/float64(count) > maxMean
+}
+
+// Close stops collecting the view of s and unregisters it and its measure.
+// The requests observed after Close are dropped.
+func (s *Shedder) Close() error {
+	if err := s.r.StopForcedCollection(s.v); err != nil {
+		return err
+	}
+	if err := s.r.UnregisterView(s.v); err != nil {
+		return err
+	}
+	return s.r.DeleteMeasure(s.m)
+}
+
+// registry is implemented by *stats.Registry and defaultRegistry.
+type registry interface {
+	NewMeasureFloat64(name, description string, unit stats.Unit) (*stats.MeasureFloat64, error)
+	DeleteMeasure(m stats.Measure) error
+	RegisterView(v stats.View) error
+	UnregisterView(v stats.View) error
+	ForceCollection(v stats.View) error
+	StopForcedCollection(v stats.View) error
+	RetrieveData(v stats.View) ([]*stats.Row, error)
+}
+
+// defaultRegistry is the default registry of the stats package.
+type defaultRegistry struct{}
+
+func (defaultRegistry) NewMeasureFloat64(name, description string, unit stats.Unit) (*stats.MeasureFloat64, error) {
+	return stats.NewMeasureFloat64(name, description, unit)
+}
+
+func (defaultRegistry) DeleteMeasure(m stats.Measure) error { return stats.DeleteMeasure(m) }
+
+func (defaultRegistry) RegisterView(v stats.View) error { return stats.RegisterView(v) }
+
+func (defaultRegistry) UnregisterView(v stats.View) error { return stats.UnregisterView(v) }
+
+func (defaultRegistry) ForceCollection(v stats.View) error { return stats.ForceCollection(v) }
+
+func (defaultRegistry) StopForcedCollection(v stats.View) error { return stats.StopForcedCollection(v) }
+
+func (defaultRegistry) RetrieveData(v stats.View) ([]*stats.Row, error) { return stats.RetrieveData(v) }
@@ -0,0 +1,105 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package loadshed
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+)
+
+func TestShedder(t *testing.T) {
+	s, err := New("test", Config{
+		MinRequests:     10,
+		MaxErrorRatio:   0.5,
+		MaxMeanLatency:  100 * time.Millisecond,
+		RefreshInterval: -1,
+		Registry:        stats.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New got error '%v', want no error", err)
+	}
+	defer s.Close()
+
+	failure := errors.New("failure")
+	for i := 0; i < 9; i++ {
+		s.Observe(time.Second, failure)
+	}
+	if s.ShouldShed() {
+		t.Error("ShouldShed below MinRequests got true, want false")
+	}
+
+	for i := 0; i < 11; i++ {
+		s.Observe(10*time.Millisecond, nil)
+	}
+	// 9 failures out of 20 requests with a mean latency of 455.5ms.
+	if !s.ShouldShed() {
+		t.Error("ShouldShed with a mean latency above the threshold got false, want true")
+	}
+
+	s.cfg.MaxMeanLatency = 0
+	if s.ShouldShed() {
+		t.Error("ShouldShed with an error ratio below the threshold got true, want false")
+	}
+	for i := 0; i < 3; i++ {
+		s.Observe(10*time.Millisecond, failure)
+	}
+	if !s.ShouldShed() {
+		t.Error("ShouldShed with an error ratio above the threshold got false, want true")
+	}
+}
+
+func TestShedderRefreshInterval(t *testing.T) {
+	s, err := New("refresh", Config{
+		MinRequests:     1,
+		MaxErrorRatio:   0.5,
+		RefreshInterval: time.Hour,
+		Registry:        stats.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New got error '%v', want no error", err)
+	}
+	defer s.Close()
+
+	if s.ShouldShed() {
+		t.Error("ShouldShed without requests got true, want false")
+	}
+	s.Observe(time.Millisecond, errors.New("failure"))
+	if s.ShouldShed() {
+		t.Error("ShouldShed within the refresh interval got true, want the previous answer false")
+	}
+}
+
+func TestNewDuplicate(t *testing.T) {
+	r := stats.NewRegistry()
+	s, err := New("dup", Config{Registry: r})
+	if err != nil {
+		t.Fatalf("New got error '%v', want no error", err)
+	}
+	if _, err := New("dup", Config{Registry: r}); err == nil {
+		t.Error("New for the same resource twice got no error, want error")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close got error '%v', want no error", err)
+	}
+	s, err = New("dup", Config{Registry: r})
+	if err != nil {
+		t.Fatalf("New after Close got error '%v', want no error", err)
+	}
+	s.Close()
+}
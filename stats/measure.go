@@ -22,6 +22,7 @@ type Measure interface {
 	addView(v View)
 	removeView(v View)
 	viewsCount() int
+	registry() *Registry
 }
 
 // Measurement is the interface for all measurement types. Measurements are
@@ -29,3 +30,64 @@ type Measure interface {
 type Measurement interface {
 	isMeasurement() bool
 }
+
+// measureOf returns the measure of the measurement m.
+func measureOf(m Measurement) Measure {
+	switch m := m.(type) {
+	case *measurementFloat64:
+		return m.m
+	case *measurementInt64:
+		return m.m
+	case *measurementDuration:
+		return m.m
+	}
+	return nil
+}
+
+// valueOf returns the value of the measurement m.
+func valueOf(m Measurement) interface{} {
+	switch m := m.(type) {
+	case *measurementFloat64:
+		return m.v
+	case *measurementInt64:
+		return m.v
+	case *measurementDuration:
+		return m.v
+	}
+	return nil
+}
+
+// unitOf returns the unit of the measure m.
+func unitOf(m Measure) Unit {
+	switch m := m.(type) {
+	case *MeasureFloat64:
+		return m.unit
+	case *MeasureInt64:
+		return m.unit
+	case *MeasureDuration:
+		return m.Unit()
+	}
+	return ""
+}
+
+// sameMeasureDefinition returns whether the measures m1 and m2 have the same
+// type and unit. Their descriptions are not compared.
+func sameMeasureDefinition(m1, m2 Measure) bool {
+	switch m1.(type) {
+	case *MeasureFloat64:
+		if _, ok := m2.(*MeasureFloat64); !ok {
+			return false
+		}
+	case *MeasureInt64:
+		if _, ok := m2.(*MeasureInt64); !ok {
+			return false
+		}
+	case *MeasureDuration:
+		if _, ok := m2.(*MeasureDuration); !ok {
+			return false
+		}
+	default:
+		return false
+	}
+	return unitOf(m1) == unitOf(m2)
+}
@@ -0,0 +1,108 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ValueConstraint defines the values a measure accepts. The values violating
+// the constraint of their measure are dropped when recorded, and counted for
+// each measure by the RecordsRejected health view. RecordChecked reports them
+// to the caller.
+type ValueConstraint int32
+
+const (
+	// ConstraintAny accepts all the values.
+	ConstraintAny ValueConstraint = iota
+	// ConstraintNonNegative only accepts the values greater than or equal to
+	// zero, e.g. for latencies, sizes or counts, so that the bugs recording
+	// negative values are caught at the source rather than producing
+	// nonsensical minimums and sums.
+	ConstraintNonNegative
+)
+
+// constraintOf returns the ValueConstraint of the measure m.
+func constraintOf(m Measure) ValueConstraint {
+	switch m := m.(type) {
+	case *MeasureFloat64:
+		return m.Constraint()
+	case *MeasureInt64:
+		return m.Constraint()
+	case *MeasureDuration:
+		return m.Constraint()
+	}
+	return ConstraintAny
+}
+
+// violatesConstraint returns true if a value recorded for m violates its
+// constraint. negative is whether the value is negative.
+func violatesConstraint(m Measure, negative bool) bool {
+	return negative && constraintOf(m) == ConstraintNonNegative
+}
+
+// isNegative returns true if the value v of a measurement is negative.
+func isNegative(v interface{}) bool {
+	switch v := v.(type) {
+	case float64:
+		return v < 0
+	case int64:
+		return v < 0
+	case time.Duration:
+		return v < 0
+	}
+	return false
+}
+
+// checkMeasurement returns an error of kind ErrNegativeValue if the value of m
+// violates the constraint of its measure.
+func checkMeasurement(m Measurement) error {
+	measure, v := measureOf(m), valueOf(m)
+	if violatesConstraint(measure, isNegative(v)) {
+		return newError(ErrNegativeValue, "measure %q only accepts non-negative values, got %v", measure.Name(), v)
+	}
+	return nil
+}
+
+// RecordChecked is like Record but checks the measurements against the
+// ValueConstraint of their measures before recording them. The measurements
+// violating the constraints are not recorded, and a MultiError holding an
+// error of kind ErrNegativeValue for each of them is returned. The other
+// measurements are recorded.
+func RecordChecked(ctx context.Context, ms ...Measurement) error {
+	var errs MultiError
+	valid := ms
+	for i, m := range ms {
+		if err := checkMeasurement(m); err != nil {
+			if errs == nil {
+				// ms is copied rather than modified.
+				valid = append([]Measurement(nil), ms[:i]...)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if errs != nil {
+			valid = append(valid, m)
+		}
+	}
+	Record(ctx, valid...)
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
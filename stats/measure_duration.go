@@ -0,0 +1,94 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// MeasureDuration is a measure of type time.Duration. The views of a
+// MeasureDuration aggregate the durations converted to the unit selected with
+// the WithDurationUnit option, milliseconds by default.
+type MeasureDuration struct {
+	name        string
+	description string
+	views       map[View]bool
+
+	// constraint is the ValueConstraint of the measure. It is accessed
+	// atomically since it is read by the worker goroutine.
+	constraint int32
+
+	// r is the registry the measure belongs to. It is nil for the default
+	// registry.
+	r *Registry
+}
+
+// Name returns the name of the measure.
+func (m *MeasureDuration) Name() string {
+	return m.name
+}
+
+// Description returns the description of the measure.
+func (m *MeasureDuration) Description() string {
+	return m.description
+}
+
+// Unit returns the unit of the measure. Durations are recorded with a
+// nanosecond precision, but the views aggregate them in the unit returned by
+// View.Unit.
+func (m *MeasureDuration) Unit() Unit {
+	return UnitNanoseconds
+}
+
+// SetConstraint sets the constraint enforced on the values recorded for the
+// measure from now on. The default is ConstraintAny.
+func (m *MeasureDuration) SetConstraint(c ValueConstraint) {
+	atomic.StoreInt32(&m.constraint, int32(c))
+}
+
+// Constraint returns the constraint enforced on the values recorded for the
+// measure.
+func (m *MeasureDuration) Constraint() ValueConstraint {
+	return ValueConstraint(atomic.LoadInt32(&m.constraint))
+}
+
+func (m *MeasureDuration) addView(v View) {
+	m.views[v] = true
+}
+
+func (m *MeasureDuration) removeView(v View) {
+	delete(m.views, v)
+}
+
+func (m *MeasureDuration) viewsCount() int { return len(m.views) }
+
+func (m *MeasureDuration) registry() *Registry { return m.r }
+
+// Is creates a new measurement/datapoint of type measurementDuration.
+func (m *MeasureDuration) Is(d time.Duration) Measurement {
+	return &measurementDuration{
+		m: m,
+		v: d,
+	}
+}
+
+type measurementDuration struct {
+	m *MeasureDuration
+	v time.Duration
+}
+
+func (md *measurementDuration) isMeasurement() bool { return true }
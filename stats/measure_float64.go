@@ -15,12 +15,29 @@
 
 package stats
 
+import (
+	"math"
+	"sync/atomic"
+)
+
 // MeasureFloat64 is a measure of type float64.
 type MeasureFloat64 struct {
 	name        string
-	unit        string
+	unit        Unit
 	description string
 	views       map[View]bool
+
+	// nonFinite is the NonFinitePolicy of the measure. It is accessed
+	// atomically since it is read by the worker goroutine.
+	nonFinite int32
+
+	// constraint is the ValueConstraint of the measure. It is accessed
+	// atomically since it is read by the worker goroutine.
+	constraint int32
+
+	// r is the registry the measure belongs to. It is nil for the default
+	// registry.
+	r *Registry
 }
 
 // Name returns the name of the measure.
@@ -28,11 +45,40 @@ func (m *MeasureFloat64) Name() string {
 	return m.name
 }
 
+// Description returns the description of the measure.
+func (m *MeasureFloat64) Description() string {
+	return m.description
+}
+
 // Unit returns the unit of the measure.
-func (m *MeasureFloat64) Unit() string {
+func (m *MeasureFloat64) Unit() Unit {
 	return m.unit
 }
 
+// SetNonFinitePolicy sets how the NaN and infinite values recorded for the
+// measure are handled from now on. The default is NonFiniteReject.
+func (m *MeasureFloat64) SetNonFinitePolicy(p NonFinitePolicy) {
+	atomic.StoreInt32(&m.nonFinite, int32(p))
+}
+
+// NonFinitePolicy returns how the NaN and infinite values recorded for the
+// measure are handled.
+func (m *MeasureFloat64) NonFinitePolicy() NonFinitePolicy {
+	return NonFinitePolicy(atomic.LoadInt32(&m.nonFinite))
+}
+
+// SetConstraint sets the constraint enforced on the values recorded for the
+// measure from now on. The default is ConstraintAny.
+func (m *MeasureFloat64) SetConstraint(c ValueConstraint) {
+	atomic.StoreInt32(&m.constraint, int32(c))
+}
+
+// Constraint returns the constraint enforced on the values recorded for the
+// measure.
+func (m *MeasureFloat64) Constraint() ValueConstraint {
+	return ValueConstraint(atomic.LoadInt32(&m.constraint))
+}
+
 func (m *MeasureFloat64) addView(v View) {
 	m.views[v] = true
 }
@@ -43,6 +89,8 @@ func (m *MeasureFloat64) removeView(v View) {
 
 func (m *MeasureFloat64) viewsCount() int { return len(m.views) }
 
+func (m *MeasureFloat64) registry() *Registry { return m.r }
+
 // Is creates a new measurement/datapoint of type measurementFloat64.
 func (m *MeasureFloat64) Is(v float64) Measurement {
 	return &measurementFloat64{
@@ -57,3 +105,39 @@ type measurementFloat64 struct {
 }
 
 func (mf *measurementFloat64) isMeasurement() bool { return true }
+
+// NonFinitePolicy defines how the NaN and infinite values recorded for a
+// MeasureFloat64 are handled. They would otherwise make the mean and the
+// variance of the distributions NaN or infinite for good.
+type NonFinitePolicy int32
+
+const (
+	// NonFiniteReject drops the NaN and infinite values. They are counted
+	// by the RecordsRejected health view.
+	NonFiniteReject NonFinitePolicy = iota
+	// NonFiniteClamp replaces the infinite values with the largest finite
+	// value of the same sign, and drops the NaN values like NonFiniteReject.
+	NonFiniteClamp
+	// NonFiniteAllow aggregates the NaN and infinite values as is.
+	NonFiniteAllow
+)
+
+// applyNonFinitePolicy returns the value to aggregate for the value v recorded
+// for m, and false if it must be rejected.
+func applyNonFinitePolicy(m *MeasureFloat64, v float64) (float64, bool) {
+	if !math.IsNaN(v) && !math.IsInf(v, 0) {
+		return v, true
+	}
+	switch m.NonFinitePolicy() {
+	case NonFiniteAllow:
+		return v, true
+	case NonFiniteClamp:
+		if math.IsInf(v, 1) {
+			return math.MaxFloat64, true
+		}
+		if math.IsInf(v, -1) {
+			return -math.MaxFloat64, true
+		}
+	}
+	return v, false
+}
@@ -15,12 +15,22 @@
 
 package stats
 
+import "sync/atomic"
+
 // MeasureInt64 is a measure of type int64.
 type MeasureInt64 struct {
 	name        string
-	unit        string
+	unit        Unit
 	description string
 	views       map[View]bool
+
+	// constraint is the ValueConstraint of the measure. It is accessed
+	// atomically since it is read by the worker goroutine.
+	constraint int32
+
+	// r is the registry the measure belongs to. It is nil for the default
+	// registry.
+	r *Registry
 }
 
 // Name returns the name of the measure.
@@ -28,11 +38,28 @@ func (m *MeasureInt64) Name() string {
 	return m.name
 }
 
+// Description returns the description of the measure.
+func (m *MeasureInt64) Description() string {
+	return m.description
+}
+
 // Unit returns the unit of the measure.
-func (m *MeasureInt64) Unit() string {
+func (m *MeasureInt64) Unit() Unit {
 	return m.unit
 }
 
+// SetConstraint sets the constraint enforced on the values recorded for the
+// measure from now on. The default is ConstraintAny.
+func (m *MeasureInt64) SetConstraint(c ValueConstraint) {
+	atomic.StoreInt32(&m.constraint, int32(c))
+}
+
+// Constraint returns the constraint enforced on the values recorded for the
+// measure.
+func (m *MeasureInt64) Constraint() ValueConstraint {
+	return ValueConstraint(atomic.LoadInt32(&m.constraint))
+}
+
 func (m *MeasureInt64) addView(v View) {
 	m.views[v] = true
 }
@@ -43,6 +70,8 @@ func (m *MeasureInt64) removeView(v View) {
 
 func (m *MeasureInt64) viewsCount() int { return len(m.views) }
 
+func (m *MeasureInt64) registry() *Registry { return m.r }
+
 // Is creates a new measurement/datapoint of type measurementInt64.
 func (m *MeasureInt64) Is(v int64) Measurement {
 	return &measurementInt64{
@@ -0,0 +1,454 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MediaTypeOpenMetrics is the media type of the OpenMetrics text format.
+	// It is the format served by the metrics handler when the request
+	// accepts any format.
+	MediaTypeOpenMetrics = "application/openmetrics-text"
+	// MediaTypeJSON is the media type of the JSON format of the metrics
+	// handler.
+	MediaTypeJSON = "application/json"
+)
+
+// MetricsEncoder writes the data of the views in a format served by the
+// handlers returned by NewMetricsHandler.
+type MetricsEncoder func(w io.Writer, vds []*ViewData) error
+
+var metricsEncoders = struct {
+	sync.RWMutex
+	m map[string]MetricsEncoder
+}{
+	m: map[string]MetricsEncoder{
+		MediaTypeOpenMetrics: encodeOpenMetrics,
+		MediaTypeJSON:        encodeJSON,
+	},
+}
+
+// RegisterMetricsEncoder makes the handlers returned by NewMetricsHandler
+// serve the format of mediaType with enc, e.g. the package statspb registers
+// the protocol buffer format "application/x-protobuf". It replaces the
+// encoder already registered for mediaType, if any.
+func RegisterMetricsEncoder(mediaType string, enc MetricsEncoder) {
+	metricsEncoders.Lock()
+	defer metricsEncoders.Unlock()
+	metricsEncoders.m[mediaType] = enc
+}
+
+// NewMetricsHandler returns an http.Handler serving the data of all the views
+// collecting data in r, as returned by ReadAll, so that the process can be
+// scraped without any exporter. The format is negotiated with the Accept
+// header of the requests: OpenMetrics (the default), JSON, or the formats
+// registered with RegisterMetricsEncoder. Like ReadAll, each request clears
+// the data of the views created with WithResetOnCollect. The requests whose
+// data cannot be encoded fail with status 500, and the error is reported to
+// the error handler of r.
+func (r *Registry) NewMetricsHandler() http.Handler {
+	// the handler is a reader of its own, marking the data as Reset
+	// regardless of the other readers.
+	resets := make(map[View]uint64)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mediaType, enc := negotiateMetricsEncoder(req.Header.Get("Accept"))
+		if enc == nil {
+			http.Error(w, "none of the accepted media types is supported", http.StatusNotAcceptable)
+			return
+		}
+		vds, err := r.readAll(resets)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		contentType := mediaType
+		if mediaType == MediaTypeOpenMetrics {
+			contentType += "; version=1.0.0; charset=utf-8"
+		}
+		// the data is encoded before the status is sent, so that the
+		// encoding errors are reported to the client.
+		var buf bytes.Buffer
+		if err := enc(&buf, vds); err != nil {
+			err = fmt.Errorf("cannot encode the metrics in '%v': %v", mediaType, err)
+			r.w.handleError(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		buf.WriteTo(w)
+	})
+}
+
+// NewMetricsHandler is like Registry.NewMetricsHandler for the default
+// registry.
+func NewMetricsHandler() http.Handler {
+	return defaultRegistry.NewMetricsHandler()
+}
+
+// negotiateMetricsEncoder returns the encoder of the supported media type
+// preferred by the Accept header accept, or nil if none is accepted.
+func negotiateMetricsEncoder(accept string) (string, MetricsEncoder) {
+	metricsEncoders.RLock()
+	defer metricsEncoders.RUnlock()
+	if strings.TrimSpace(accept) == "" {
+		return MediaTypeOpenMetrics, metricsEncoders.m[MediaTypeOpenMetrics]
+	}
+
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+	var as []accepted
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		a := accepted{mediaType: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if q, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					a.q = q
+				}
+			}
+		}
+		if a.q > 0 {
+			as = append(as, a)
+		}
+	}
+	sort.SliceStable(as, func(i, j int) bool { return as[i].q > as[j].q })
+
+	for _, a := range as {
+		switch {
+		case a.mediaType == "*/*":
+			return MediaTypeOpenMetrics, metricsEncoders.m[MediaTypeOpenMetrics]
+		case strings.HasSuffix(a.mediaType, "/*"):
+			// the default format first, then the others in a stable order.
+			prefix := strings.TrimSuffix(a.mediaType, "*")
+			if strings.HasPrefix(MediaTypeOpenMetrics, prefix) {
+				return MediaTypeOpenMetrics, metricsEncoders.m[MediaTypeOpenMetrics]
+			}
+			var types []string
+			for t := range metricsEncoders.m {
+				if strings.HasPrefix(t, prefix) {
+					types = append(types, t)
+				}
+			}
+			if len(types) > 0 {
+				sort.Strings(types)
+				return types[0], metricsEncoders.m[types[0]]
+			}
+		default:
+			if enc, ok := metricsEncoders.m[a.mediaType]; ok {
+				return a.mediaType, enc
+			}
+		}
+	}
+	return "", nil
+}
+
+// metricFamily is the name of the OpenMetrics family of the i-th value of
+// the rows of v, which has n values per row.
+func metricFamily(v View, i, n int) string {
+	name := sanitizeMetricName(v.Name())
+	if n > 1 {
+		name += fmt.Sprintf("_agg%v", i)
+	}
+	return name
+}
+
+// sanitizeMetricName replaces the characters not allowed in the OpenMetrics
+// metric names and label names by '_'.
+func sanitizeMetricName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9' && i > 0)) {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// encodeOpenMetrics writes vds in the OpenMetrics text format. The count
+// aggregations are counters and the distributions are histograms for the
+// cumulative views. They are gauges and gauge histograms for the other
+// views, whose data isn't cumulative.
+func encodeOpenMetrics(w io.Writer, vds []*ViewData) error {
+	for _, vd := range vds {
+		cumulative := false
+		if _, ok := vd.V.Window().(*WindowCumulative); ok {
+			cumulative = !vd.V.isResetOnCollect()
+		}
+		like := aggregationValues(vd.V.Aggregation().aggregationValueConstructor()())
+		for i := range like {
+			if err := writeOpenMetricsFamily(w, vd, i, like, cumulative); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(w, "# EOF\n")
+	return err
+}
+
+// aggregationValues returns the values of av, which are flattened for the
+// AggregationMultiValue.
+func aggregationValues(av AggregationValue) []AggregationValue {
+	if m, ok := av.(*AggregationMultiValue); ok {
+		return m.values
+	}
+	return []AggregationValue{av}
+}
+
+// isCounter returns whether av is a count, which is a counter in the cumulative
+// views. The rates and ratios are always gauges.
+func isCounter(av AggregationValue) bool {
+	_, ok := av.(*AggregationCountValue)
+	return ok
+}
+
+// writeOpenMetricsFamily writes the family of the i-th value of the rows of
+// vd, like being the values of a new row of the view.
+func writeOpenMetricsFamily(w io.Writer, vd *ViewData, i int, like []AggregationValue, cumulative bool) error {
+	name := metricFamily(vd.V, i, len(like))
+	typ := "gauge"
+	if _, ok := like[i].(*AggregationDistributionValue); ok {
+		typ = "gaugehistogram"
+		if cumulative {
+			typ = "histogram"
+		}
+	} else if isCounter(like[i]) && cumulative {
+		typ = "counter"
+	}
+	if _, err := fmt.Fprintf(w, "# TYPE %v %v\n# HELP %v %v\n", name, typ, name, labelValueEscaper.Replace(vd.V.Description())); err != nil {
+		return err
+	}
+
+	for _, r := range vd.Rows {
+		av := aggregationValues(r.AggregationValue)[i]
+		var labels []string
+		for _, t := range r.Tags {
+			labels = append(labels, fmt.Sprintf(`%v="%v"`, sanitizeMetricName(t.K.Name()), labelValueEscaper.Replace(t.K.ValueAsString(t.V))))
+		}
+		var err error
+		switch av := av.(type) {
+		case *AggregationCountValue:
+			suffix := ""
+			if cumulative {
+				suffix = "_total"
+			}
+			err = writeSample(w, name+suffix, labels, "", float64(*av))
+		case *AggregationRateValue:
+			err = writeSample(w, name, labels, "", float64(*av))
+		case *AggregationRatioValue:
+			err = writeSample(w, name, labels, "", float64(*av))
+		case *AggregationSumValue:
+			// a sum is a gauge: it decreases with negative samples.
+			err = writeSample(w, name, labels, "", float64(*av))
+		case *AggregationMeanValue:
+			err = writeSample(w, name, labels, "", av.Mean())
+		case *AggregationDistributionValue:
+			var cum int64
+			for b, bound := range av.bounds {
+				cum += av.countPerBucket[b]
+				if err = writeSample(w, name+"_bucket", labels, `le="`+formatOpenMetricsFloat(bound)+`"`, float64(cum)); err != nil {
+					return err
+				}
+			}
+			if err = writeSample(w, name+"_bucket", labels, `le="+Inf"`, float64(av.count)); err != nil {
+				return err
+			}
+			countName, sumName := name+"_count", name+"_sum"
+			if !cumulative {
+				countName, sumName = name+"_gcount", name+"_gsum"
+			}
+			if err = writeSample(w, countName, labels, "", float64(av.count)); err != nil {
+				return err
+			}
+			err = writeSample(w, sumName, labels, "", av.Sum())
+		}
+		if err != nil {
+			return err
+		}
+		if typ == "counter" || typ == "histogram" {
+			if err := writeCreated(w, name, labels, r.Start); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeCreated writes the _created sample of the counters and histograms,
+// which tells the backends when the series was (re)started. Nothing is
+// written if start is zero.
+func writeCreated(w io.Writer, name string, labels []string, start time.Time) error {
+	if start.IsZero() {
+		return nil
+	}
+	return writeSample(w, name+"_created", labels, "", float64(start.UnixNano())/1e9)
+}
+
+func writeSample(w io.Writer, name string, labels []string, extra string, v float64) error {
+	if extra != "" {
+		labels = append(labels[:len(labels):len(labels)], extra)
+	}
+	var err error
+	if len(labels) == 0 {
+		_, err = fmt.Fprintf(w, "%v %v\n", name, formatOpenMetricsFloat(v))
+	} else {
+		_, err = fmt.Fprintf(w, "%v{%v} %v\n", name, strings.Join(labels, ","), formatOpenMetricsFloat(v))
+	}
+	return err
+}
+
+func formatOpenMetricsFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// jsonViewData is the JSON format of the metrics handler.
+type jsonViewData struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	TagKeys     []string  `json:"tagKeys"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Rows        []jsonRow `json:"rows"`
+}
+
+// jsonRow holds Start for the rows of the cumulative windows.
+type jsonRow struct {
+	Tags  map[string]string `json:"tags"`
+	Value jsonValue         `json:"value"`
+	Start *time.Time        `json:"start,omitempty"`
+}
+
+// jsonValue holds Count for the count aggregations, Rate for the rate views,
+// Ratio for the ratio views, Sum and Mean for the sum and mean aggregations,
+// Distribution for the distributions, and Multi for the multiple
+// aggregations.
+type jsonValue struct {
+	Count        *int64            `json:"count,omitempty"`
+	Rate         *float64          `json:"rate,omitempty"`
+	Ratio        *float64          `json:"ratio,omitempty"`
+	Sum          *float64          `json:"sum,omitempty"`
+	Mean         *float64          `json:"mean,omitempty"`
+	Distribution *jsonDistribution `json:"distribution,omitempty"`
+	Multi        []jsonValue       `json:"multi,omitempty"`
+}
+
+type jsonDistribution struct {
+	Count          int64     `json:"count"`
+	Sum            float64   `json:"sum"`
+	Mean           float64   `json:"mean"`
+	Min            float64   `json:"min"`
+	Max            float64   `json:"max"`
+	Bounds         []float64 `json:"bounds"`
+	CountPerBucket []int64   `json:"countPerBucket"`
+}
+
+func newJSONValue(av AggregationValue) jsonValue {
+	switch av := av.(type) {
+	case *AggregationCountValue:
+		c := int64(*av)
+		return jsonValue{Count: &c}
+	case *AggregationRateValue:
+		r := float64(*av)
+		return jsonValue{Rate: &r}
+	case *AggregationRatioValue:
+		r := float64(*av)
+		return jsonValue{Ratio: &r}
+	case *AggregationSumValue:
+		s := float64(*av)
+		return jsonValue{Sum: &s}
+	case *AggregationMeanValue:
+		m := av.Mean()
+		return jsonValue{Mean: &m}
+	case *AggregationDistributionValue:
+		d := &jsonDistribution{
+			Count:          av.count,
+			Sum:            av.Sum(),
+			Bounds:         av.Bounds(),
+			CountPerBucket: av.CountPerBucket(),
+		}
+		if av.count > 0 {
+			d.Mean, d.Min, d.Max = av.mean, av.min, av.max
+		}
+		return jsonValue{Distribution: d}
+	case *AggregationMultiValue:
+		jv := jsonValue{Multi: []jsonValue{}}
+		for _, v := range av.values {
+			jv.Multi = append(jv.Multi, newJSONValue(v))
+		}
+		return jv
+	}
+	return jsonValue{}
+}
+
+func encodeJSON(w io.Writer, vds []*ViewData) error {
+	out := make([]jsonViewData, 0, len(vds))
+	for _, vd := range vds {
+		jvd := jsonViewData{
+			Name:        vd.V.Name(),
+			Description: vd.V.Description(),
+			TagKeys:     []string{},
+			Start:       vd.Start,
+			End:         vd.End,
+			Rows:        []jsonRow{},
+		}
+		for _, k := range vd.V.TagKeys() {
+			jvd.TagKeys = append(jvd.TagKeys, k.Name())
+		}
+		for _, r := range vd.Rows {
+			jr := jsonRow{
+				Tags:  make(map[string]string, len(r.Tags)),
+				Value: newJSONValue(r.AggregationValue),
+			}
+			if !r.Start.IsZero() {
+				start := r.Start
+				jr.Start = &start
+			}
+			for _, t := range r.Tags {
+				jr.Tags[t.K.Name()] = t.K.ValueAsString(t.V)
+			}
+			jvd.Rows = append(jvd.Rows, jr)
+		}
+		out = append(out, jvd)
+	}
+	return json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,62 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+// MustNewMeasureFloat64 is like NewMeasureFloat64 but panics if the measure
+// cannot be created. It simplifies the creation of measures at init time.
+func MustNewMeasureFloat64(name, description string, unit Unit) *MeasureFloat64 {
+	m, err := NewMeasureFloat64(name, description, unit)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// MustNewMeasureInt64 is like NewMeasureInt64 but panics if the measure
+// cannot be created.
+func MustNewMeasureInt64(name, description string, unit Unit) *MeasureInt64 {
+	m, err := NewMeasureInt64(name, description, unit)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// MustNewMeasureDuration is like NewMeasureDuration but panics if the measure
+// cannot be created.
+func MustNewMeasureDuration(name, description string) *MeasureDuration {
+	m, err := NewMeasureDuration(name, description)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// MustRegisterView is like RegisterView but panics if the view cannot be
+// registered.
+func MustRegisterView(v View) {
+	if err := RegisterView(v); err != nil {
+		panic(err)
+	}
+}
+
+// MustRegisterViews is like RegisterViews but panics if the views cannot be
+// registered.
+func MustRegisterViews(vs ...View) {
+	if err := RegisterViews(vs...); err != nil {
+		panic(err)
+	}
+}
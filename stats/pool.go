@@ -0,0 +1,91 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"sync"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/resource"
+)
+
+// The commands recording measurements are pooled since one is sent for each
+// call to a Record function. A command is owned by the worker goroutine once
+// it is sent: the sender must not use it afterwards, and the worker returns it
+// to its pool once handled.
+var (
+	recordFloat64ReqPool = sync.Pool{
+		New: func() interface{} { return &recordFloat64Req{} },
+	}
+	recordInt64ReqPool = sync.Pool{
+		New: func() interface{} { return &recordInt64Req{} },
+	}
+	recordDurationReqPool = sync.Pool{
+		New: func() interface{} { return &recordDurationReq{} },
+	}
+	recordReqPool = sync.Pool{
+		New: func() interface{} { return &recordReq{} },
+	}
+	recordWithRecorderReqPool = sync.Pool{
+		New: func() interface{} { return &recordWithRecorderReq{} },
+	}
+)
+
+// releaseRecordCommand clears cmd so that the pool doesn't retain the
+// recorded data, and returns it to its pool. cmd.ms keeps its capacity.
+func releaseRecordCommand(cmd command) {
+	switch cmd := cmd.(type) {
+	case *recordFloat64Req:
+		*cmd = recordFloat64Req{}
+		recordFloat64ReqPool.Put(cmd)
+	case *recordInt64Req:
+		*cmd = recordInt64Req{}
+		recordInt64ReqPool.Put(cmd)
+	case *recordDurationReq:
+		*cmd = recordDurationReq{}
+		recordDurationReqPool.Put(cmd)
+	case *recordReq:
+		for i := range cmd.ms {
+			cmd.ms[i] = nil
+		}
+		*cmd = recordReq{ms: cmd.ms[:0]}
+		recordReqPool.Put(cmd)
+	case *recordWithRecorderReq:
+		*cmd = recordWithRecorderReq{}
+		recordWithRecorderReqPool.Put(cmd)
+	}
+}
+
+// viewDataPool holds the ViewData released by the subscribers of
+// SubscribeToViewBorrowed. Their Rows are reused for the next reports.
+var viewDataPool = sync.Pool{
+	New: func() interface{} { return &ViewData{} },
+}
+
+// newBorrowedViewData is like newViewData but reuses a released ViewData and
+// its rows.
+func newBorrowedViewData(v View, res *resource.Resource, now time.Time) *ViewData {
+	vd := viewDataPool.Get().(*ViewData)
+	vd.V = v
+	vd.Resource = res
+	vd.Start = v.collector().windowStart(now)
+	vd.End = now
+	vd.Reset = false
+	vd.Rows = v.appendCollectedRows(vd.Rows[:0], now)
+	vd.Exemplars = v.collectedExemplars()
+	vd.borrowed = true
+	return vd
+}
@@ -0,0 +1,99 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+)
+
+// RecordHook intercepts a measurement before it is sent to the library, e.g.
+// to log it, to sample it dynamically or to add tags to it. m is the measure
+// of the measurement and value its float64, int64 or time.Duration value. The
+// hook returns the context whose tags are recorded with the measurement, and
+// false if the measurement must be dropped. Hooks are called by the goroutines
+// recording the measurements, so they must be safe for concurrent use and
+// cheap. The values recorded with a recorder (see RecorderFor) are not
+// intercepted.
+type RecordHook func(ctx context.Context, m Measure, value interface{}) (context.Context, bool)
+
+// recordHooks is the chain of the hooks registered with RegisterRecordHook. It
+// is copied on write so that the recording goroutines read it without locking.
+type recordHooks struct {
+	mu sync.Mutex
+	hs atomic.Value // []RecordHook
+}
+
+func (h *recordHooks) add(hook RecordHook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	old, _ := h.hs.Load().([]RecordHook)
+	hs := make([]RecordHook, len(old), len(old)+1)
+	copy(hs, old)
+	h.hs.Store(append(hs, hook))
+}
+
+// enabled returns true if at least one hook is registered. It allows the
+// callers not to convert the values to interface{} when there is no hook.
+func (h *recordHooks) enabled() bool {
+	hs, _ := h.hs.Load().([]RecordHook)
+	return len(hs) != 0
+}
+
+// intercept calls the hooks in the order they were registered, each with the
+// context returned by the previous one, until one of them drops the
+// measurement.
+func (h *recordHooks) intercept(ctx context.Context, m Measure, value interface{}) (context.Context, bool) {
+	hs, _ := h.hs.Load().([]RecordHook)
+	for _, hook := range hs {
+		var proceed bool
+		if ctx, proceed = callHook(hook, ctx, m, value); !proceed {
+			return ctx, false
+		}
+	}
+	return ctx, true
+}
+
+// callHook calls hook. If it panics, the panic is reported to the error
+// handler of the registry of m, and the measurement proceeds with ctx as if
+// the hook wasn't registered.
+func callHook(hook RecordHook, ctx context.Context, m Measure, value interface{}) (hctx context.Context, proceed bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			registryOf(m).w.handlePanic(p, "a record hook of measure '%v'", m.Name())
+			hctx, proceed = ctx, true
+		}
+	}()
+	return hook(ctx, m, value)
+}
+
+// RegisterRecordHook adds h to the hooks intercepting the measurements of the
+// measures of the registry. The hooks are called in the order they were
+// registered. A nil hook is ignored.
+func (r *Registry) RegisterRecordHook(h RecordHook) {
+	if h == nil {
+		return
+	}
+	r.w.hooks.add(h)
+}
+
+// RegisterRecordHook is like Registry.RegisterRecordHook for the default
+// registry.
+func RegisterRecordHook(h RecordHook) {
+	defaultRegistry.RegisterRecordHook(h)
+}
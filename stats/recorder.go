@@ -0,0 +1,245 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+// recorder holds the state shared by the typed recorders. ts is the TagSet
+// the recorder was created with, own, merged with the global tags by the
+// worker goroutine. It only changes with the global tags, so the signature of
+// ts for each view of the measure is computed once, and cached in sigs by the
+// worker goroutine.
+//
+// When all the views of the measure only count the samples over a cumulative
+// window, the worker sets fast and the recorder counts the samples in pending
+// instead of sending them to the worker. The worker adds the pending counts
+// to the views before collecting their data and before the views of the
+// measure change.
+type recorder struct {
+	m     Measure
+	views map[View]bool
+	own   *tags.TagSet
+	ts    *tags.TagSet
+	sigs  map[View]string
+
+	fast    int32
+	pending *stripedCounter
+
+	// closed is set by the worker goroutine when the recorder is closed.
+	closed bool
+}
+
+func newRecorder(m Measure, views map[View]bool, ts *tags.TagSet) *recorder {
+	if ts == nil {
+		// the views of the measure registered later may have tag keys.
+		ts = tags.NewTagSetBuilder(nil).Build()
+	}
+	r := &recorder{
+		m:       m,
+		views:   views,
+		own:     ts,
+		ts:      ts,
+		sigs:    make(map[View]string),
+		pending: newStripedCounter(),
+	}
+	req := &registerRecorderReq{
+		r: r,
+		c: make(chan bool),
+	}
+	registryOf(m).w.ctl <- req
+	<-req.c
+	return r
+}
+
+func (r *recorder) record(v interface{}) {
+	if atomic.LoadInt32(&r.fast) == 1 {
+		r.pending.add(1)
+		return
+	}
+	r.send(v)
+}
+
+// send sends v to the worker goroutine, bypassing the fast path.
+func (r *recorder) send(v interface{}) {
+	req := recordWithRecorderReqPool.Get().(*recordWithRecorderReq)
+	w := registryOf(r.m).w
+	req.now = w.now()
+	req.r = r
+	req.v = v
+	w.c <- req
+}
+
+func (r *recorder) close() {
+	req := &unregisterRecorderReq{
+		r: r,
+		c: make(chan bool),
+	}
+	registryOf(r.m).w.ctl <- req
+	<-req.c
+}
+
+// drain adds the pending counts to the views counting the samples of the
+// measure, and returns the number of samples drained. It must only be called
+// by the worker goroutine.
+func (r *recorder) drain(now time.Time) int64 {
+	n := r.pending.swap()
+	if n == 0 {
+		return 0
+	}
+	for v := range r.views {
+		if isCountingView(v) {
+			v.addCountWithCache(r.ts, r.sigs, n, now)
+		}
+	}
+	return n
+}
+
+// update enables the fast path of the recorder if all the views of the
+// measure can be updated from the pending counts. It must only be called by
+// the worker goroutine.
+func (r *recorder) update() {
+	fast := int32(1)
+	for v := range r.views {
+		if !isCountingView(v) {
+			fast = 0
+			break
+		}
+	}
+	atomic.StoreInt32(&r.fast, fast)
+}
+
+// isCountingView returns true if v only counts the samples it aggregates over
+// cumulative windows, i.e. if the values of the samples don't matter.
+func isCountingView(v View) bool {
+	if _, ok := v.Aggregation().(*AggregationCount); !ok {
+		return false
+	}
+	for _, w := range v.Windows() {
+		if _, ok := w.(*WindowCumulative); !ok {
+			return false
+		}
+	}
+	return !v.isSampled() && v.recentSamples() == nil
+}
+
+// RecorderFloat64 records values of a MeasureFloat64 with a fixed TagSet.
+// It avoids computing the tag signatures for each value recorded in tight
+// loops, and when all the views of the measure are counts over cumulative
+// windows, it avoids the round-trip to the library's goroutine. It is safe for
+// concurrent use. A recorder is meant to be long lived, and must be closed
+// when it is no longer used.
+type RecorderFloat64 struct {
+	r *recorder
+}
+
+// RecorderFor returns a RecorderFloat64 recording values of m with the tags
+// ts. A nil ts records the values without tags.
+func (m *MeasureFloat64) RecorderFor(ts *tags.TagSet) *RecorderFloat64 {
+	return &RecorderFloat64{newRecorder(m, m.views, ts)}
+}
+
+// Record records v. The NaN and infinite values are handled according to the
+// NonFinitePolicy of the measure, and the negative values according to its
+// ValueConstraint.
+func (rec *RecorderFloat64) Record(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+		// the worker applies the policy and the constraint, even if the
+		// views only count the samples.
+		rec.r.send(v)
+		return
+	}
+	rec.r.record(v)
+}
+
+// Close releases the resources held by the recorder. The values recorded
+// after Close are dropped.
+func (rec *RecorderFloat64) Close() {
+	rec.r.close()
+}
+
+// RecorderInt64 records values of a MeasureInt64 with a fixed TagSet. It
+// avoids computing the tag signatures for each value recorded in tight loops,
+// and when all the views of the measure are counts over cumulative windows,
+// it avoids the round-trip to the library's goroutine. It is safe for
+// concurrent use. A recorder is meant to be long lived, and must be closed
+// when it is no longer used.
+type RecorderInt64 struct {
+	r *recorder
+}
+
+// RecorderFor returns a RecorderInt64 recording values of m with the tags ts.
+// A nil ts records the values without tags.
+func (m *MeasureInt64) RecorderFor(ts *tags.TagSet) *RecorderInt64 {
+	return &RecorderInt64{newRecorder(m, m.views, ts)}
+}
+
+// Record records v. The negative values are handled according to the
+// ValueConstraint of the measure.
+func (rec *RecorderInt64) Record(v int64) {
+	if v < 0 {
+		// the worker applies the constraint, even if the views only count
+		// the samples.
+		rec.r.send(v)
+		return
+	}
+	rec.r.record(v)
+}
+
+// Close releases the resources held by the recorder. The values recorded
+// after Close are dropped.
+func (rec *RecorderInt64) Close() {
+	rec.r.close()
+}
+
+// RecorderDuration records values of a MeasureDuration with a fixed TagSet.
+// It avoids computing the tag signatures for each value recorded in tight
+// loops, and when all the views of the measure are counts over cumulative
+// windows, it avoids the round-trip to the library's goroutine. It is safe for
+// concurrent use. A recorder is meant to be long lived, and must be closed
+// when it is no longer used.
+type RecorderDuration struct {
+	r *recorder
+}
+
+// RecorderFor returns a RecorderDuration recording values of m with the tags
+// ts. A nil ts records the values without tags.
+func (m *MeasureDuration) RecorderFor(ts *tags.TagSet) *RecorderDuration {
+	return &RecorderDuration{newRecorder(m, m.views, ts)}
+}
+
+// Record records d. The negative durations are handled according to the
+// ValueConstraint of the measure.
+func (rec *RecorderDuration) Record(d time.Duration) {
+	if d < 0 {
+		// the worker applies the constraint, even if the views only count
+		// the samples.
+		rec.r.send(d)
+		return
+	}
+	rec.r.record(d)
+}
+
+// Close releases the resources held by the recorder. The values recorded
+// after Close are dropped.
+func (rec *RecorderDuration) Close() {
+	rec.r.close()
+}
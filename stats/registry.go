@@ -0,0 +1,878 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/resource"
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"golang.org/x/net/context"
+)
+
+// Registry holds a set of measures and views, and the goroutine aggregating
+// and reporting their data, isolated from the other registries. It allows
+// libraries, tests and multi-tenant servers to have independent
+// instrumentation. The package-level functions operate on the default
+// registry.
+//
+// A measure belongs to the registry it was created in, and can only be used in
+// the views of this registry. The Record functions record the measurements
+// against the registry of their measure.
+type Registry struct {
+	w *worker
+}
+
+var defaultRegistry *Registry
+
+// NewRegistry creates a new Registry.
+func NewRegistry() *Registry {
+	r := &Registry{}
+	r.w = newWorker()
+	r.w.r = r
+	go r.w.start()
+	return r
+}
+
+// registryOf returns the registry the measure m belongs to. The measures not
+// created by a registry belong to the default registry.
+func registryOf(m Measure) *Registry {
+	if m == nil {
+		return defaultRegistry
+	}
+	if r := m.registry(); r != nil {
+		return r
+	}
+	return defaultRegistry
+}
+
+// NewMeasureFloat64 creates a new measure of type MeasureFloat64. It returns
+// an error if a measure with the same name already exists or if unit is
+// invalid.
+func (r *Registry) NewMeasureFloat64(name, description string, unit Unit) (*MeasureFloat64, error) {
+	if _, err := unit.value(); err != nil {
+		return nil, wrapError(err, "cannot create measure %q: %v", name, err)
+	}
+	m := &MeasureFloat64{
+		name:        name,
+		description: description,
+		unit:        unit,
+		views:       make(map[View]bool),
+		r:           r,
+	}
+
+	req := &registerMeasureReq{
+		m:   m,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	if err := <-req.err; err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// NewMeasureInt64 creates a new measure of type MeasureInt64. It returns an
+// error if a measure with the same name already exists or if unit is invalid.
+func (r *Registry) NewMeasureInt64(name, description string, unit Unit) (*MeasureInt64, error) {
+	if _, err := unit.value(); err != nil {
+		return nil, wrapError(err, "cannot create measure %q: %v", name, err)
+	}
+	m := &MeasureInt64{
+		name:        name,
+		description: description,
+		unit:        unit,
+		views:       make(map[View]bool),
+		r:           r,
+	}
+
+	req := &registerMeasureReq{
+		m:   m,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	if err := <-req.err; err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// NewMeasureDuration creates a new measure of type MeasureDuration. It returns
+// an error if a measure with the same name already exists.
+func (r *Registry) NewMeasureDuration(name, description string) (*MeasureDuration, error) {
+	m := &MeasureDuration{
+		name:        name,
+		description: description,
+		views:       make(map[View]bool),
+		r:           r,
+	}
+
+	req := &registerMeasureReq{
+		m:   m,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	if err := <-req.err; err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// GetOrCreateMeasureFloat64 returns the measure registered with name if it
+// is a MeasureFloat64 with the same unit, and creates it otherwise. It allows
+// several libraries to instrument the same measure without depending on the
+// order of their initialization. It returns an error of kind
+// ErrDuplicateMeasure if the measure registered with name has another type or
+// unit. The description of an existing measure is kept.
+func (r *Registry) GetOrCreateMeasureFloat64(name, description string, unit Unit) (*MeasureFloat64, error) {
+	if _, err := unit.value(); err != nil {
+		return nil, wrapError(err, "cannot create measure %q: %v", name, err)
+	}
+	m, err := r.getOrRegisterMeasure(&MeasureFloat64{
+		name:        name,
+		description: description,
+		unit:        unit,
+		views:       make(map[View]bool),
+		r:           r,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.(*MeasureFloat64), nil
+}
+
+// GetOrCreateMeasureInt64 is like GetOrCreateMeasureFloat64 for a measure of
+// type MeasureInt64.
+func (r *Registry) GetOrCreateMeasureInt64(name, description string, unit Unit) (*MeasureInt64, error) {
+	if _, err := unit.value(); err != nil {
+		return nil, wrapError(err, "cannot create measure %q: %v", name, err)
+	}
+	m, err := r.getOrRegisterMeasure(&MeasureInt64{
+		name:        name,
+		description: description,
+		unit:        unit,
+		views:       make(map[View]bool),
+		r:           r,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.(*MeasureInt64), nil
+}
+
+// GetOrCreateMeasureDuration is like GetOrCreateMeasureFloat64 for a measure
+// of type MeasureDuration.
+func (r *Registry) GetOrCreateMeasureDuration(name, description string) (*MeasureDuration, error) {
+	m, err := r.getOrRegisterMeasure(&MeasureDuration{
+		name:        name,
+		description: description,
+		views:       make(map[View]bool),
+		r:           r,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.(*MeasureDuration), nil
+}
+
+func (r *Registry) getOrRegisterMeasure(m Measure) (Measure, error) {
+	req := &getOrRegisterMeasureReq{
+		m: m,
+		c: make(chan *getOrRegisterMeasureResp),
+	}
+	r.w.ctl <- req
+	resp := <-req.c
+	return resp.m, resp.err
+}
+
+// GetMeasureByName returns the registered measure associated with name.
+func (r *Registry) GetMeasureByName(name string) (Measure, error) {
+	req := &getMeasureByNameReq{
+		name: name,
+		c:    make(chan *getMeasureByNameResp),
+	}
+	r.w.ctl <- req
+	resp := <-req.c
+	return resp.m, resp.err
+}
+
+// DeleteMeasure deletes an existing measure to allow for creation of a new
+// measure with the same name. It returns an error if the measure cannot be
+// deleted (if one or multiple registered views refer to it).
+func (r *Registry) DeleteMeasure(m Measure) error {
+	req := &deleteMeasureReq{
+		m:   m,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// GetViewByName returns the registered view associated with this name.
+func (r *Registry) GetViewByName(name string) (View, error) {
+	req := &getViewByNameReq{
+		name: name,
+		c:    make(chan *getViewByNameResp),
+	}
+	r.w.ctl <- req
+	resp := <-req.c
+	return resp.v, resp.err
+}
+
+// Measures returns the measures currently registered, sorted by name.
+func (r *Registry) Measures() []Measure {
+	req := &listMeasuresReq{
+		c: make(chan []Measure),
+	}
+	r.w.ctl <- req
+	return <-req.c
+}
+
+// Views returns the views currently registered, sorted by name.
+func (r *Registry) Views() []View {
+	req := &listViewsReq{
+		c: make(chan []View),
+	}
+	r.w.ctl <- req
+	return <-req.c
+}
+
+// GetViewInfo returns the collection state of the registered view v. It
+// returns an error if v is not registered.
+func (r *Registry) GetViewInfo(v View) (*ViewInfo, error) {
+	if v == nil {
+		return nil, errors.New("cannot GetViewInfo for nil view")
+	}
+
+	req := &getViewInfoReq{
+		v: v,
+		c: make(chan *getViewInfoResp),
+	}
+	r.w.ctl <- req
+	resp := <-req.c
+	return resp.vi, resp.err
+}
+
+// RegisterView registers view. It returns an error if the view cannot be
+// registered. Subsequent calls to Record with the same measure as the one in
+// the view will NOT cause the usage to be recorded unless a consumer is
+// subscribed to the view or ForceCollection for this view is called.
+func (r *Registry) RegisterView(v View) error {
+	if v == nil {
+		return errors.New("cannot RegisterView for nil view")
+	}
+
+	req := &registerViewReq{
+		v:   v,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// RegisterViews registers all the views vs or none of them. If any view cannot
+// be registered, no view is registered and a MultiError reporting the reason
+// of each failure is returned.
+func (r *Registry) RegisterViews(vs ...View) error {
+	for _, v := range vs {
+		if v == nil {
+			return errors.New("cannot RegisterViews for nil view")
+		}
+	}
+
+	req := &registerViewsReq{
+		vs:  vs,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// UnregisterNamespace unregisters all the registered views whose name starts
+// with prefix (see WithNamespace), or none of them. If any of them is still
+// collecting data, no view is unregistered and a MultiError listing the views
+// still collecting is returned.
+func (r *Registry) UnregisterNamespace(prefix string) error {
+	req := &unregisterNamespaceReq{
+		prefix: prefix,
+		err:    make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// UnregisterView deletes the previously registered view. It returns an error
+// if the view wasn't registered. All data collected and not reported for the
+// corresponding view will be lost. All clients subscribed to this view are
+// unsubscribed automatically and their subscriptions channels closed.
+func (r *Registry) UnregisterView(v View) error {
+	if v == nil {
+		return errors.New("cannot UnregisterView for nil view")
+	}
+
+	req := &unregisterViewReq{
+		v:   v,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// ReplaceView replaces the registered view old by v, which must have the same
+// name. It is used to change the definition of a view (e.g. its window) at
+// runtime. The subscriptions to old and its forced collection are moved to v.
+// The data collected for old is not carried over. A view is renamed by
+// replacing it with a view having its name as alias, see WithAliases.
+func (r *Registry) ReplaceView(old, v View) error {
+	if old == nil || v == nil {
+		return errors.New("cannot ReplaceView for nil view")
+	}
+
+	req := &replaceViewReq{
+		old: old,
+		v:   v,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// SubscribeToView subscribes a client to a View. If the view wasn't already
+// registered, it will be automatically registered. It allows for many clients
+// to consume the same ViewData with a single registration. -i.e. the aggregate
+// of the collected measurements will be reported to the calling code through
+// channel c. To avoid data loss, clients must ensure that channel sends
+// proceed in a timely manner. The calling code is responsible for using a
+// buffered channel or blocking on the channel waiting for the collected data.
+// When c is full, the ViewData being reported is dropped unless another
+// policy is set with WithBackpressure.
+func (r *Registry) SubscribeToView(v View, c chan *ViewData, opts ...SubscriptionOption) error {
+	if v == nil {
+		return errors.New("cannot SubscribeToView for nil view")
+	}
+
+	req := &subscribeToViewReq{
+		v:    v,
+		c:    c,
+		opts: opts,
+		err:  make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// SubscribeToViewBorrowed is like SubscribeToView, but the ViewData delivered
+// to c are borrowed from the library to reduce the allocations of services
+// reporting many rows: c receives its own ViewData, which must be released
+// with ViewData.Release once processed so that its rows are reused.
+func (r *Registry) SubscribeToViewBorrowed(v View, c chan *ViewData, opts ...SubscriptionOption) error {
+	if v == nil {
+		return errors.New("cannot SubscribeToViewBorrowed for nil view")
+	}
+
+	req := &subscribeToViewReq{
+		v:        v,
+		c:        c,
+		borrowed: true,
+		opts:     opts,
+		err:      make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// UnsubscribeFromView unsubscribes a previously subscribed channel from the
+// View subscriptions. If no more subscriber for v exists and the the ad hoc
+// collection for this view isn't active, data stops being collected for this
+// view.
+func (r *Registry) UnsubscribeFromView(v View, c chan *ViewData) error {
+	if v == nil {
+		return errors.New("cannot UnsubscribeFromView for nil view")
+	}
+
+	req := &unsubscribeFromViewReq{
+		v:   v,
+		c:   c,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// SubscribeMatching subscribes c to all the views whose name matches pattern,
+// those registered now and those registered later, e.g. by plugins, so that
+// exporters don't need to know the views in advance. In pattern, '*' matches
+// any sequence of characters, including '/', and '?' any single character,
+// e.g. "grpc.io/*". The options apply to each view subscribed to; the views
+// lacking the tag keys they refer to are skipped. Data is collected for all
+// the matching views, but these subscriptions don't prevent the views from
+// being unregistered.
+func (r *Registry) SubscribeMatching(pattern string, c chan *ViewData, opts ...SubscriptionOption) error {
+	req := &subscribeMatchingReq{
+		pattern: pattern,
+		c:       c,
+		opts:    opts,
+		err:     make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// UnsubscribeMatching stops subscribing c to the views whose name matches
+// pattern, and unsubscribes it from the views it was subscribed to by
+// SubscribeMatching with pattern. The subscriptions made with SubscribeToView
+// are kept.
+func (r *Registry) UnsubscribeMatching(pattern string, c chan *ViewData) error {
+	req := &unsubscribeMatchingReq{
+		pattern: pattern,
+		c:       c,
+		err:     make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// ForceCollection starts data collection for this view even if no
+// listeners are subscribed to it.
+func (r *Registry) ForceCollection(v View) error {
+	if v == nil {
+		return errors.New("cannot ForceCollection for nil view")
+	}
+
+	req := &startForcedCollectionReq{
+		v:   v,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// ForceCollectionFor is like ForceCollection, but the forced collection
+// expires after d: the view then stops collecting data unless at least 1
+// listener is subscribed to it. It doesn't shorten a forced collection started
+// before that expires later or never. StopForcedCollection stops it at once.
+func (r *Registry) ForceCollectionFor(v View, d time.Duration) error {
+	if v == nil {
+		return errors.New("cannot ForceCollectionFor for nil view")
+	}
+	if d <= 0 {
+		return fmt.Errorf("cannot ForceCollectionFor view '%v' for non-positive duration %v", v.Name(), d)
+	}
+
+	req := &startForcedCollectionReq{
+		v:   v,
+		d:   d,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// IsCollecting returns true if v is registered and collecting data, i.e. at
+// least 1 listener is subscribed to it or its collection is forced and hasn't
+// expired.
+func (r *Registry) IsCollecting(v View) bool {
+	if v == nil {
+		return false
+	}
+	req := &isCollectingReq{
+		v: v,
+		c: make(chan bool),
+	}
+	r.w.ctl <- req
+	return <-req.c
+}
+
+// StopForcedCollection stops data collection for this view unless at least
+// 1 listener is subscribed to it.
+func (r *Registry) StopForcedCollection(v View) error {
+	if v == nil {
+		return errors.New("cannot StopForcedCollection for nil view")
+	}
+
+	req := &stopForcedCollectionReq{
+		v:   v,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// RetrieveData returns the current collected data for the view.
+func (r *Registry) RetrieveData(v View) ([]*Row, error) {
+	if v == nil {
+		return nil, errors.New("cannot retrieve data for nil view")
+	}
+	req := &retrieveDataReq{
+		now: r.w.now(),
+		v:   v,
+		c:   make(chan *retrieveDataResp),
+	}
+	r.w.ctl <- req
+	resp := <-req.c
+	return resp.rows, resp.err
+}
+
+// RetrieveDataFiltered is like RetrieveData but only returns the rows whose
+// tags satisfy pred, e.g. MatchTag(method, "GET"). The other rows aren't
+// copied. Unlike RetrieveData, it doesn't clear the data of the views created
+// with WithResetOnCollect, since the rows not returned would be lost.
+func (r *Registry) RetrieveDataFiltered(v View, pred func([]tags.Tag) bool) ([]*Row, error) {
+	if v == nil {
+		return nil, errors.New("cannot retrieve data for nil view")
+	}
+	if pred == nil {
+		return nil, fmt.Errorf("cannot retrieve data for view '%v' with nil predicate", v.Name())
+	}
+	req := &retrieveDataReq{
+		now:  r.w.now(),
+		v:    v,
+		pred: pred,
+		c:    make(chan *retrieveDataResp),
+	}
+	r.w.ctl <- req
+	resp := <-req.c
+	return resp.rows, resp.err
+}
+
+// RetrieveDataForWindow returns the current collected data for one of the
+// windows of a view created with NewMultiWindowView. The window is selected by
+// type and parameters, e.g. NewWindowSlidingTime(time.Hour, 6) selects the
+// window created with the same arguments. It returns an error if no window of
+// the view matches w.
+func (r *Registry) RetrieveDataForWindow(v View, w Window) ([]*Row, error) {
+	if v == nil {
+		return nil, errors.New("cannot retrieve data for nil view")
+	}
+	req := &retrieveDataReq{
+		now: r.w.now(),
+		v:   v,
+		w:   w,
+		c:   make(chan *retrieveDataResp),
+	}
+	r.w.ctl <- req
+	resp := <-req.c
+	return resp.rows, resp.err
+}
+
+// AddRows adds the aggregation values of rows to the data collected for the
+// rows with the same tags in the cumulative windows of v, e.g. to merge the
+// data collected by other processes. The view must be collecting data and
+// the values must be of its aggregation. The start of a row moves back to the
+// Start of the row added if it is earlier.
+func (r *Registry) AddRows(v View, rows []*Row) error {
+	if v == nil {
+		return errors.New("cannot add rows to nil view")
+	}
+	req := &addRowsReq{
+		v:    v,
+		rows: rows,
+		now:  r.w.now(),
+		err:  make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// ReadAll returns the data collected for all the views collecting data (i.e.
+// subscribed to or whose collection is forced), sorted by view name. All the
+// views are collected at the same time, so the returned data is a consistent
+// snapshot. It is meant for pull exporters. Like RetrieveData, it clears the
+// data of the views created with WithResetOnCollect.
+func (r *Registry) ReadAll() ([]*ViewData, error) {
+	return r.readAll(nil)
+}
+
+// readAll is like ReadAll for the reader whose reset epochs are resets. nil
+// means the reader of ReadAll. See ViewData.Reset.
+func (r *Registry) readAll(resets map[View]uint64) ([]*ViewData, error) {
+	req := &readAllReq{
+		now:    r.w.now(),
+		resets: resets,
+		c:      make(chan []*ViewData),
+	}
+	r.w.ctl <- req
+	return <-req.c, nil
+}
+
+// KeepRecentSamples instructs the library to retain the last n raw samples
+// (timestamp, tags, value) recorded for the view, before they are aggregated.
+// It is meant for debugging what fed a suspicious aggregate. Calling it with n
+// less than or equal to zero stops retaining samples. Samples are only
+// retained while the view is collecting data.
+func (r *Registry) KeepRecentSamples(v View, n int) error {
+	if v == nil {
+		return errors.New("cannot KeepRecentSamples for nil view")
+	}
+
+	req := &keepRecentSamplesReq{
+		v:   v,
+		n:   n,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// RetrieveRecentSamples returns the raw samples retained for the view ordered
+// from the oldest to the most recent.
+func (r *Registry) RetrieveRecentSamples(v View) ([]*Sample, error) {
+	if v == nil {
+		return nil, errors.New("cannot retrieve recent samples for nil view")
+	}
+	req := &retrieveRecentSamplesReq{
+		v: v,
+		c: make(chan *retrieveRecentSamplesResp),
+	}
+	r.w.ctl <- req
+	resp := <-req.c
+	return resp.samples, resp.err
+}
+
+// KeepHistory instructs the library to retain in memory the last n ViewData
+// collected for the view at each reporting period, so that debug tooling can
+// graph the last few minutes of a view without a backend. The view must be
+// collecting data, i.e. have subscribers or be forcibly collected. Calling it
+// with n less than or equal to zero stops retaining the ViewData and drops
+// those retained.
+func (r *Registry) KeepHistory(v View, n int) error {
+	if v == nil {
+		return errors.New("cannot KeepHistory for nil view")
+	}
+
+	req := &keepHistoryReq{
+		v:   v,
+		n:   n,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// RetrieveHistory returns at most the n most recent ViewData retained for the
+// view ordered from the oldest to the most recent, or all of them if n is
+// less than or equal to zero. See KeepHistory. The returned ViewData may be
+// shared with the subscribers of the view and must not be modified.
+func (r *Registry) RetrieveHistory(v View, n int) ([]*ViewData, error) {
+	if v == nil {
+		return nil, errors.New("cannot retrieve history for nil view")
+	}
+	req := &retrieveHistoryReq{
+		v: v,
+		n: n,
+		c: make(chan *retrieveHistoryResp),
+	}
+	r.w.ctl <- req
+	resp := <-req.c
+	return resp.vds, resp.err
+}
+
+// Watch evaluates predicate on each row of the view at each collection, i.e.
+// each reporting period, and calls cb for the rows satisfying it, e.g. to trip
+// a circuit breaker or log a warning when the latency of a row exceeds a
+// threshold without exporting the data. The view must be collecting data,
+// i.e. have subscribers or be forcibly collected, for the watcher to be
+// evaluated. predicate is called by the goroutine of the library: it must be
+// quick and must not call the functions of the registry. The library waits
+// for cb to return before reporting the next view, and cb can call the
+// functions of the registry, e.g. to register a more detailed view. Neither
+// must modify the rows, which are shared with the subscribers of the view.
+// Unwatch stops evaluating the watcher.
+func (r *Registry) Watch(v View, predicate func(*Row) bool, cb func(*Row)) (*Watcher, error) {
+	if v == nil {
+		return nil, errors.New("cannot Watch nil view")
+	}
+	if predicate == nil || cb == nil {
+		return nil, fmt.Errorf("cannot Watch view '%v' with a nil predicate or callback", v.Name())
+	}
+
+	wr := &Watcher{
+		v:         v,
+		predicate: predicate,
+		cb:        cb,
+	}
+	req := &watchReq{
+		wr:  wr,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	if err := <-req.err; err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+// Unwatch stops evaluating the watcher wr returned by Watch. It returns an
+// error if wr isn't watching its view anymore.
+func (r *Registry) Unwatch(wr *Watcher) error {
+	if wr == nil {
+		return errors.New("cannot Unwatch nil watcher")
+	}
+
+	req := &unwatchReq{
+		wr:  wr,
+		err: make(chan error),
+	}
+	r.w.ctl <- req
+	return <-req.err
+}
+
+// RetrieveExemplars returns the exemplars currently collected for the view.
+func (r *Registry) RetrieveExemplars(v View) ([]*Exemplar, error) {
+	if v == nil {
+		return nil, errors.New("cannot retrieve exemplars for nil view")
+	}
+	req := &retrieveExemplarsReq{
+		v: v,
+		c: make(chan *retrieveExemplarsResp),
+	}
+	r.w.ctl <- req
+	resp := <-req.c
+	return resp.exemplars, resp.err
+}
+
+// SetReportingPeriod sets the interval between reporting aggregated views in
+// the program. Calling SetReportingPeriod with duration argument less than or
+// equal to zero enables the default behavior.
+func (r *Registry) SetReportingPeriod(d time.Duration) {
+	// TODO(acetechnologist): ensure that the duration d is more than a certain
+	// value. e.g. 1s
+	req := &setReportingPeriodReq{
+		d: d,
+		c: make(chan bool),
+	}
+	r.w.ctl <- req
+	<-req.c // don't return until the timer is set to the new duration.
+}
+
+// SetShards spreads the views of the registry over n goroutines adding the
+// samples recorded to them, each view being owned by the goroutine selected
+// by the hash of its name. It allows the registry to keep up with recording
+// rates a single goroutine cannot aggregate, at the cost of dispatching each
+// sample to the goroutines owning the views of its measure. Calling SetShards
+// with n less than 2 adds the samples from a single goroutine again, which is
+// the default. n is capped to 64.
+//
+// Sharding doesn't change the registration, subscription, retrieval and
+// reporting APIs, nor their ordering guarantees: the samples are added to
+// each view in the order they were recorded, and every other operation on
+// the registry (e.g. RetrieveData, Flush or UnregisterView), as well as every
+// report to the subscribers, observes all the samples recorded before it.
+// Only the samples of distinct views are added concurrently. The records are
+// not batched while the views are sharded, see the Benchmarks section of the
+// README.
+func (r *Registry) SetShards(n int) {
+	req := &setShardsReq{
+		n: n,
+		c: make(chan bool),
+	}
+	r.w.ctl <- req
+	<-req.c
+}
+
+// SetGlobalTags sets the tags (e.g. the service name, version or zone) merged
+// into every TagSet recorded with the measures of the registry, so that every
+// row of the views carries them without each call site inserting the same
+// keys. The recorded tags take precedence over the global tags with the same
+// keys. The tags are merged when the measurements are collected, hence
+// SetGlobalTags applies to the recorders already created too. Calling it with
+// nil removes the global tags.
+func (r *Registry) SetGlobalTags(ts *tags.TagSet) {
+	req := &setGlobalTagsReq{
+		ts: ts,
+		c:  make(chan bool),
+	}
+	r.w.ctl <- req
+	<-req.c
+}
+
+// SetClock replaces the clock providing the time of the measurements, of the
+// collection of the views and of the periodic reporting, e.g. by a
+// ManualClock in tests advancing the time deterministically instead of
+// sleeping. The data collected for the sliding windows before the call is
+// kept, so the clock should be set before recording.
+func (r *Registry) SetClock(c Clock) {
+	req := &setClockReq{
+		clock: c,
+		c:     make(chan bool),
+	}
+	r.w.ctl <- req
+	<-req.c
+}
+
+// SetResource sets the resource (e.g. the host, the container or the cluster)
+// attached to the ViewData reported and read afterwards, so that the exporters
+// label the data with the identity of the entity it was recorded in. See
+// resource.Detect. Calling it with nil removes the resource.
+func (r *Registry) SetResource(res *resource.Resource) {
+	req := &setResourceReq{
+		res: res,
+		c:   make(chan bool),
+	}
+	r.w.ctl <- req
+	<-req.c
+}
+
+// EnableHealthViews registers the views the library reports its own health
+// with, and returns them. The views are registered once, and the same views
+// are returned by the subsequent calls.
+func (r *Registry) EnableHealthViews() (*HealthViews, error) {
+	req := &enableHealthReq{
+		c: make(chan *enableHealthResp),
+	}
+	r.w.ctl <- req
+	resp := <-req.c
+	return resp.views, resp.err
+}
+
+// Flush reports immediately the data collected so far to the subscribers of
+// the views, as if the reporting period had elapsed. The measurements recorded
+// before the call are included. Flush does nothing after Shutdown.
+func (r *Registry) Flush() {
+	req := &flushReq{
+		c: make(chan bool),
+	}
+	r.w.ctl <- req
+	<-req.c
+}
+
+// Shutdown reports the data collected so far to the subscribers of the views
+// and stops the periodic reporting, e.g. when the process receives SIGTERM.
+// The measurements recorded after Shutdown are dropped. The other functions
+// of the package remain usable. Shutdown returns ctx.Err() if ctx is done
+// before the data is reported.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	req := &flushReq{
+		shutdown: true,
+		c:        make(chan bool, 1),
+	}
+	select {
+	case r.w.ctl <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-req.c:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
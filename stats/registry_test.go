@@ -0,0 +1,506 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"golang.org/x/net/context"
+)
+
+func Test_Registry_Isolation(t *testing.T) {
+	RestartWorker()
+	r := NewRegistry()
+	defer r.w.stop()
+
+	mDefault, err := NewMeasureInt64("MI1", "desc MI1", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64 got error '%v', want no error", err)
+	}
+	mRegistry, err := r.NewMeasureInt64("MI1", "desc MI1", "1")
+	if err != nil {
+		t.Fatalf("Registry.NewMeasureInt64 with a name used by the default registry got error '%v', want no error", err)
+	}
+
+	k1, _ := tags.CreateKeyString("k1")
+	vDefault := NewView("VI1", "desc VI1", []tags.Key{k1}, mDefault, NewAggregationCount(), NewWindowCumulative())
+	vRegistry := NewView("VI1", "desc VI1", []tags.Key{k1}, mRegistry, NewAggregationCount(), NewWindowCumulative())
+	if err := ForceCollection(vDefault); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	if err := r.ForceCollection(vRegistry); err != nil {
+		t.Fatalf("Registry.ForceCollection got error '%v', want no error", err)
+	}
+	if err := RegisterView(NewView("VI2", "desc VI2", nil, mRegistry, NewAggregationCount(), NewWindowCumulative())); err == nil {
+		t.Errorf("RegisterView with a measure of another registry got no error, want error")
+	}
+
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+	RecordInt64(ctx, mDefault, 1)
+	RecordInt64(ctx, mRegistry, 1)
+	Record(ctx, mDefault.Is(1), mRegistry.Is(1), mRegistry.Is(1))
+
+	type testCase struct {
+		label    string
+		retrieve func(View) ([]*Row, error)
+		v        View
+		want     AggregationCountValue
+	}
+	tcs := []testCase{
+		{"default registry", RetrieveData, vDefault, 2},
+		{"new registry", r.RetrieveData, vRegistry, 3},
+	}
+	for _, tc := range tcs {
+		rows, err := tc.retrieve(tc.v)
+		if err != nil {
+			t.Fatalf("Test case '%v': RetrieveData got error '%v', want no error", tc.label, err)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("Test case '%v': RetrieveData got %v rows, want 1", tc.label, len(rows))
+		}
+		if got := *(rows[0].AggregationValue.(*AggregationCountValue)); got != tc.want {
+			t.Errorf("Test case '%v': got count %v, want %v", tc.label, got, tc.want)
+		}
+	}
+
+	if got := r.Views(); len(got) != 1 || got[0] != vRegistry {
+		t.Errorf("Registry.Views() got %v, want only the view of the registry", got)
+	}
+}
+
+func Test_TestEnvironment(t *testing.T) {
+	t.Parallel()
+	e := NewTestEnvironment()
+	defer e.Close()
+	e.SetReportingPeriod(time.Minute)
+
+	m, err := e.NewMeasureInt64("MI1", "desc MI1", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64 got error '%v', want no error", err)
+	}
+	v := NewView("VI1", "desc VI1", nil, m, NewAggregationCount(), NewWindowCumulative())
+	c := make(chan *ViewData, 1)
+	if err := e.SubscribeToView(v, c); err != nil {
+		t.Fatalf("SubscribeToView got error '%v', want no error", err)
+	}
+	RecordInt64(context.Background(), m, 1)
+
+	e.Advance(59 * time.Second)
+	select {
+	case vd := <-c:
+		t.Fatalf("got ViewData %v before the reporting period elapsed, want none", vd)
+	default:
+	}
+	e.Advance(time.Second)
+	select {
+	case vd := <-c:
+		if want := testEnvironmentStart.Add(time.Minute); !vd.End.Equal(want) {
+			t.Errorf("ViewData ends at %v, want %v", vd.End, want)
+		}
+		if len(vd.Rows) != 1 || *(vd.Rows[0].AggregationValue.(*AggregationCountValue)) != 1 {
+			t.Errorf("ViewData got rows %v, want a count of 1", vd.Rows)
+		}
+	default:
+		t.Fatalf("got no ViewData once the reporting period elapsed, want one")
+	}
+}
+
+func Test_View_SnapshotRestoreState(t *testing.T) {
+	t.Parallel()
+	k1, _ := tags.CreateKeyString("k1")
+	ctx1 := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+	ctx2 := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v2").Build())
+	agg := NewAggregationDistribution([]float64{0, 5, 10})
+
+	// newView registers the same view in a new environment, as a restarted
+	// process would.
+	newView := func(e *TestEnvironment) (*MeasureFloat64, View) {
+		m, err := e.NewMeasureFloat64("MF1", "desc MF1", "1")
+		if err != nil {
+			t.Fatalf("NewMeasureFloat64 got error '%v', want no error", err)
+		}
+		v := NewView("VF1", "desc VF1", []tags.Key{k1}, m, agg, NewWindowCumulative())
+		if err := e.ForceCollection(v); err != nil {
+			t.Fatalf("ForceCollection got error '%v', want no error", err)
+		}
+		return m, v
+	}
+
+	e1 := NewTestEnvironment()
+	defer e1.Close()
+	m1, v1 := newView(e1)
+	RecordFloat64(ctx1, m1, 1)
+	RecordFloat64(ctx1, m1, 7)
+	RecordFloat64(ctx2, m1, 3)
+	b, err := v1.SnapshotState()
+	if err != nil {
+		t.Fatalf("SnapshotState got error '%v', want no error", err)
+	}
+
+	e2 := NewTestEnvironment()
+	defer e2.Close()
+	e2.Advance(time.Hour)
+	m2, v2 := newView(e2)
+	RecordFloat64(ctx2, m2, 12)
+	if err := v2.RestoreState(b); err != nil {
+		t.Fatalf("RestoreState got error '%v', want no error", err)
+	}
+
+	rows, err := e2.RetrieveData(v2)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	want := []*Row{
+		{
+			Tags:             []tags.Tag{{K: k1, V: []byte("v1")}},
+			AggregationValue: newTestDistributionValue(t, []float64{0, 5, 10}, []float64{1, 7}),
+		},
+		{
+			Tags:             []tags.Tag{{K: k1, V: []byte("v2")}},
+			AggregationValue: newTestDistributionValue(t, []float64{0, 5, 10}, []float64{3, 12}),
+		},
+	}
+	if ok, msg := EqualRows(rows, want); !ok {
+		t.Errorf("RetrieveData after RestoreState got rows %v, want %v. %v", rows, want, msg)
+	}
+	vd, err := e2.ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll got error '%v', want no error", err)
+	}
+	if len(vd) != 1 || !vd[0].Start.Equal(testEnvironmentStart) {
+		t.Errorf("ReadAll got %v, want a collection started at %v", vd, testEnvironmentStart)
+	}
+
+	other := NewView("VF2", "desc VF2", []tags.Key{k1}, m2, agg, NewWindowCumulative())
+	if err := e2.RegisterView(other); err != nil {
+		t.Fatalf("RegisterView got error '%v', want no error", err)
+	}
+	if err := other.RestoreState(b); !errors.Is(err, ErrViewNotCollecting) {
+		t.Errorf("RestoreState got error '%v', want an error of kind '%v'", err, ErrViewNotCollecting)
+	}
+	if err := e2.ForceCollection(other); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	if err := other.RestoreState(b); err == nil {
+		t.Errorf("RestoreState of the state of another view got no error, want an error")
+	}
+}
+
+func newTestDistributionValue(t *testing.T, bounds []float64, samples []float64) *AggregationDistributionValue {
+	av := newAggregationDistributionValue(bounds)
+	for _, s := range samples {
+		av.addSample(s)
+	}
+	return av
+}
+
+func Test_Registry_MetricsHandler(t *testing.T) {
+	t.Parallel()
+	e := NewTestEnvironment()
+	defer e.Close()
+
+	m, _ := e.NewMeasureFloat64("MF1", "desc MF1", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	vCount := NewView("my.org/views/count", "count of MF1", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	vDist := NewView("my.org/views/dist", "dist of MF1", nil, m, NewAggregationDistribution([]float64{2}), NewWindowSlidingTime(time.Minute, 6))
+	for _, v := range []View{vCount, vDist} {
+		if err := e.ForceCollection(v); err != nil {
+			t.Fatalf("ForceCollection got error '%v', want no error", err)
+		}
+	}
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v\"1").Build())
+	RecordFloat64(ctx, m, 1)
+	RecordFloat64(ctx, m, 3)
+	h := e.NewMetricsHandler()
+	RegisterMetricsEncoder("application/x-failing", func(io.Writer, []*ViewData) error {
+		return errors.New("failing encoder")
+	})
+	var (
+		mu       sync.Mutex
+		reported []error
+	)
+	e.SetErrorHandler(func(err error) {
+		mu.Lock()
+		reported = append(reported, err)
+		mu.Unlock()
+	})
+
+	type testCase struct {
+		accept          string
+		wantStatus      int
+		wantContentType string
+		wantBody        []string
+	}
+	tcs := []testCase{
+		{"", http.StatusOK, "application/openmetrics-text; version=1.0.0; charset=utf-8", []string{
+			"# TYPE my_org_views_count counter\n# HELP my_org_views_count count of MF1\nmy_org_views_count_total{k1=\"v\\\"1\"} 2\n",
+			"my_org_views_count_created{k1=\"v\\\"1\"} ",
+			"# TYPE my_org_views_dist gaugehistogram\n",
+			"my_org_views_dist_bucket{le=\"2\"} 1\nmy_org_views_dist_bucket{le=\"+Inf\"} 2\nmy_org_views_dist_gcount 2\nmy_org_views_dist_gsum 4\n",
+			"# EOF\n",
+		}},
+		{"text/html;q=0.9, application/json", http.StatusOK, "application/json", []string{
+			`"name":"my.org/views/count"`,
+			`"tags":{"k1":"v\"1"},"value":{"count":2},"start":"`,
+			`"distribution":{"count":2,"sum":4,"mean":2,"min":1,"max":3,"bounds":[2],"countPerBucket":[1,1]}`,
+		}},
+		{"application/*", http.StatusOK, "application/openmetrics-text; version=1.0.0; charset=utf-8", []string{"# EOF\n"}},
+		{"text/html, application/json;q=0", http.StatusNotAcceptable, "", nil},
+		{"application/x-failing", http.StatusInternalServerError, "", nil},
+	}
+	for _, tc := range tcs {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.wantStatus {
+			t.Errorf("Accept '%v': got status %v, want %v", tc.accept, rec.Code, tc.wantStatus)
+			continue
+		}
+		if tc.wantStatus != http.StatusOK {
+			continue
+		}
+		if got := rec.Header().Get("Content-Type"); got != tc.wantContentType {
+			t.Errorf("Accept '%v': got content type '%v', want '%v'", tc.accept, got, tc.wantContentType)
+		}
+		body := rec.Body.String()
+		for _, want := range tc.wantBody {
+			if !strings.Contains(body, want) {
+				t.Errorf("Accept '%v': got body\n%v\nwant it to contain\n%v", tc.accept, body, want)
+			}
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 1 || !strings.Contains(reported[0].Error(), "failing encoder") {
+		t.Errorf("got errors %v reported to the error handler, want the error of the failing encoder", reported)
+	}
+}
+
+func Test_Registry_Shards(t *testing.T) {
+	r := NewRegistry()
+	defer r.w.stop()
+	r.SetShards(4)
+
+	m, err := r.NewMeasureInt64("MI2", "desc MI2", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64 got error '%v', want no error", err)
+	}
+	k1, _ := tags.CreateKeyString("k1")
+	var views []View
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("VS%v", i)
+		agg := Aggregation(NewAggregationCount())
+		if i%2 == 1 {
+			agg = NewAggregationDistribution([]float64{2})
+		}
+		v := NewView(name, "desc "+name, []tags.Key{k1}, m, agg, NewWindowCumulative())
+		if err := r.ForceCollection(v); err != nil {
+			t.Fatalf("ForceCollection '%v' got error '%v', want no error", name, err)
+		}
+		views = append(views, v)
+	}
+
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+	ctx := tags.NewContext(context.Background(), ts)
+	rec := m.RecorderFor(ts)
+	defer rec.Close()
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				RecordInt64(ctx, m, 1)
+				Record(ctx, m.Is(1))
+				rec.Record(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	check := func(label string, vs []View, want int64) {
+		for _, v := range vs {
+			rows, err := r.RetrieveData(v)
+			if err != nil {
+				t.Fatalf("%v: RetrieveData '%v' got error '%v', want no error", label, v.Name(), err)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("%v: RetrieveData '%v' got %v rows, want 1", label, v.Name(), len(rows))
+			}
+			var got int64
+			switch av := rows[0].AggregationValue.(type) {
+			case *AggregationCountValue:
+				got = int64(*av)
+			case *AggregationDistributionValue:
+				got = av.Count()
+			}
+			if got != want {
+				t.Errorf("%v: view '%v' got %v samples, want %v", label, v.Name(), got, want)
+			}
+		}
+	}
+	check("sharded", views, 1200)
+
+	if err := r.StopForcedCollection(views[0]); err != nil {
+		t.Fatalf("StopForcedCollection got error '%v', want no error", err)
+	}
+	if err := r.UnregisterView(views[0]); err != nil {
+		t.Fatalf("UnregisterView got error '%v', want no error", err)
+	}
+	RecordInt64(ctx, m, 1)
+	r.SetShards(1)
+	RecordInt64(ctx, m, 1)
+	r.SetShards(3)
+	rec.Record(1)
+	check("resharded", views[1:], 1203)
+}
+
+// Test_Registry_ConcurrentSoak records, registers, subscribes to and
+// unregisters views concurrently, and checks that no registration is lost and
+// that the counts are conserved, with and without sharding. It is meant to be
+// run with -race.
+func Test_Registry_ConcurrentSoak(t *testing.T) {
+	for _, shards := range []int{0, 4} {
+		testConcurrentSoak(t, shards)
+	}
+}
+
+func testConcurrentSoak(t *testing.T, shards int) {
+	r := NewRegistry()
+	defer r.w.stop()
+	r.SetShards(shards)
+
+	k, _ := tags.CreateKeyString("soak_worker")
+	m, err := r.NewMeasureInt64("MI2", "desc MI2", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64 got error '%v', want no error", err)
+	}
+	total := NewView("VS8", "desc VS8", nil, m, NewAggregationCount(), NewWindowCumulative())
+	if err := r.RegisterView(total); err != nil {
+		t.Fatalf("RegisterView got error '%v', want no error", err)
+	}
+	if err := r.ForceCollection(total); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+
+	const (
+		recorders = 8
+		records   = 500
+		churners  = 4
+		rounds    = 50
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < recorders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k, fmt.Sprint(i)).Build())
+			rec := m.RecorderFor(nil)
+			defer rec.Close()
+			for j := 0; j < records; j++ {
+				switch j % 3 {
+				case 0:
+					RecordInt64(ctx, m, 1)
+				case 1:
+					Record(ctx, m.Is(1))
+				default:
+					rec.Record(1)
+				}
+			}
+		}(i)
+	}
+
+	errs := make(chan error, churners*rounds)
+	for i := 0; i < churners; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				name := fmt.Sprintf("soak/%v/%v", i, j)
+				v := NewView(name, "soak", []tags.Key{k}, m, NewAggregationDistribution([]float64{1}), NewWindowSlidingTime(time.Minute, 6))
+				if err := r.RegisterView(v); err != nil {
+					errs <- err
+					continue
+				}
+				if got, err := r.GetViewByName(name); err != nil || got != v {
+					errs <- fmt.Errorf("GetViewByName(%q) got %v, %v, want the view registered", name, got, err)
+				}
+				c := make(chan *ViewData, 1)
+				if err := r.SubscribeToView(v, c); err != nil {
+					errs <- err
+				}
+				if _, err := r.RetrieveData(v); err != nil {
+					errs <- err
+				}
+				if j%2 == 0 {
+					// the odd views stay registered.
+					if err := r.UnsubscribeFromView(v, c); err != nil {
+						errs <- err
+					}
+					if err := r.UnregisterView(v); err != nil {
+						errs <- err
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			r.Views()
+			r.Flush()
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	registered := make(map[string]bool)
+	for _, v := range r.Views() {
+		registered[v.Name()] = true
+	}
+	for i := 0; i < churners; i++ {
+		for j := 0; j < rounds; j++ {
+			name := fmt.Sprintf("soak/%v/%v", i, j)
+			if want := j%2 == 1; registered[name] != want {
+				t.Errorf("shards %v: view %v registered: %v, want %v", shards, name, registered[name], want)
+			}
+		}
+	}
+
+	rows, err := r.RetrieveData(total)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if got, want := int64(*rows[0].AggregationValue.(*AggregationCountValue)), int64(recorders*records); got != want {
+		t.Errorf("shards %v: total count got %v, want %v", shards, got, want)
+	}
+}
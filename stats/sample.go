@@ -0,0 +1,78 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+// Sample is a raw value recorded against the measure of a view, before it was
+// aggregated. It is only retained for views for which KeepRecentSamples was
+// called and is meant for debugging purposes.
+type Sample struct {
+	Time        time.Time
+	Tags        *tags.TagSet
+	Value       interface{}
+	Attachments map[string]string
+}
+
+func (s *Sample) String() string {
+	if len(s.Attachments) == 0 {
+		return fmt.Sprintf("{%v %v %v}", s.Time.Format(time.RFC3339Nano), s.Tags, s.Value)
+	}
+	return fmt.Sprintf("{%v %v %v %v}", s.Time.Format(time.RFC3339Nano), s.Tags, s.Value, s.Attachments)
+}
+
+// samplesRing is a fixed size circular buffer holding the most recent samples
+// recorded for a view.
+type samplesRing struct {
+	entries []*Sample
+	// idx is the position where the next sample will be written.
+	idx  int
+	full bool
+}
+
+func newSamplesRing(n int) *samplesRing {
+	return &samplesRing{
+		entries: make([]*Sample, n),
+	}
+}
+
+func (r *samplesRing) add(s *Sample) {
+	r.entries[r.idx] = s
+	r.idx = (r.idx + 1) % len(r.entries)
+	if r.idx == 0 {
+		r.full = true
+	}
+}
+
+// samples returns the retained samples ordered from the oldest to the most
+// recent.
+func (r *samplesRing) samples() []*Sample {
+	if !r.full {
+		ret := make([]*Sample, r.idx)
+		copy(ret, r.entries[:r.idx])
+		return ret
+	}
+
+	ret := make([]*Sample, 0, len(r.entries))
+	ret = append(ret, r.entries[r.idx:]...)
+	ret = append(ret, r.entries[:r.idx]...)
+	return ret
+}
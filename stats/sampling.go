@@ -0,0 +1,77 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// sampler decides which of the samples recorded for a view are aggregated.
+// It is only used by the worker goroutine.
+type sampler struct {
+	rate float64
+	rand *rand.Rand
+}
+
+func newSampler(rate float64) *sampler {
+	return &sampler{
+		rate: rate,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// sample returns true if the next sample must be aggregated.
+func (s *sampler) sample() bool {
+	return s.rand.Float64() < s.rate
+}
+
+// scaleAggregationValue returns a copy of av estimating the value aggregated
+// from all the samples, when only 1/factor of them were aggregated. The counts
+// and the sums, including the exact sum of the int64 distributions, are scaled
+// by factor. The mean, min and max are unchanged.
+func scaleAggregationValue(av AggregationValue, factor float64) AggregationValue {
+	switch av := av.(type) {
+	case *AggregationCountValue:
+		return newAggregationCountValue(int64(math.Floor(float64(*av)*factor + 0.5)))
+	case *AggregationDistributionValue:
+		ret := newAggregationDistributionValue(av.bounds)
+		for i, c := range av.countPerBucket {
+			ret.countPerBucket[i] = int64(math.Floor(float64(c)*factor + 0.5))
+		}
+		ret.count = int64(math.Floor(float64(av.count)*factor + 0.5))
+		ret.min = av.min
+		ret.max = av.max
+		ret.mean = av.mean
+		ret.sumOfSquaredDev = av.sumOfSquaredDev * factor
+		ret.copyOutOfRange(av, factor)
+		ret.copyInt64(av)
+		ret.sumInt64 = int64(math.Floor(float64(av.sumInt64)*factor + 0.5))
+		return ret
+	case *AggregationSumValue:
+		return newAggregationSumValue(float64(*av) * factor)
+	case *AggregationMeanValue:
+		return &AggregationMeanValue{sum: av.sum * factor, count: av.count * factor}
+	case *AggregationMultiValue:
+		ret := &AggregationMultiValue{values: make([]AggregationValue, len(av.values))}
+		for i, v := range av.values {
+			ret.values[i] = scaleAggregationValue(v, factor)
+		}
+		return ret
+	}
+	return av
+}
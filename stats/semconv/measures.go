@@ -0,0 +1,76 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package semconv
+
+import "github.com/census-instrumentation/opencensus-go/stats"
+
+// The canonical measures of the HTTP servers.
+var (
+	HTTPServerLatency       = Float64{"http/server/latency", "End-to-end latency of the HTTP requests served", stats.UnitMilliseconds}
+	HTTPServerRequestCount  = Int64{"http/server/request_count", "Number of HTTP requests started", "{requests}"}
+	HTTPServerRequestBytes  = Int64{"http/server/request_bytes", "Size of the bodies of the HTTP requests received", stats.UnitBytes}
+	HTTPServerResponseBytes = Int64{"http/server/response_bytes", "Size of the bodies of the HTTP responses sent", stats.UnitBytes}
+)
+
+// The canonical measures of the HTTP clients.
+var (
+	HTTPClientLatency       = Float64{"http/client/latency", "End-to-end latency of the HTTP requests sent, until the response body is read", stats.UnitMilliseconds}
+	HTTPClientRequestCount  = Int64{"http/client/request_count", "Number of HTTP requests sent", "{requests}"}
+	HTTPClientRequestBytes  = Int64{"http/client/request_bytes", "Size of the bodies of the HTTP requests sent", stats.UnitBytes}
+	HTTPClientResponseBytes = Int64{"http/client/response_bytes", "Size of the bodies of the HTTP responses received", stats.UnitBytes}
+)
+
+// The canonical measures of the gRPC servers. Their names are the ones used by
+// the gRPC plugin since its first release.
+var (
+	GRPCServerErrorCount        = Int64{"/grpc.io/server/error_count", "RPC Errors", stats.UnitDimensionless}
+	GRPCServerServerElapsedTime = Float64{"/grpc.io/server/server_elapsed_time", "Server elapsed time in msecs", stats.UnitMilliseconds}
+	GRPCServerRequestBytes      = Int64{"/grpc.io/server/request_bytes", "Request bytes", stats.UnitBytes}
+	GRPCServerResponseBytes     = Int64{"/grpc.io/server/response_bytes", "Response bytes", stats.UnitBytes}
+	GRPCServerStartedCount      = Int64{"/grpc.io/server/started_count", "Number of server RPCs (streams) started", stats.UnitDimensionless}
+	GRPCServerFinishedCount     = Int64{"/grpc.io/server/finished_count", "Number of server RPCs (streams) finished", stats.UnitDimensionless}
+	GRPCServerRequestCount      = Int64{"/grpc.io/server/request_count", "Number of server RPC request messages", stats.UnitDimensionless}
+	GRPCServerResponseCount     = Int64{"/grpc.io/server/response_count", "Number of server RPC response messages", stats.UnitDimensionless}
+	GRPCServerRequestWireBytes  = Int64{"/grpc.io/server/request_wire_bytes", "Request bytes on the wire, after compression", stats.UnitBytes}
+	GRPCServerResponseWireBytes = Int64{"/grpc.io/server/response_wire_bytes", "Response bytes on the wire, after compression", stats.UnitBytes}
+	GRPCServerWireLatency       = Float64{"/grpc.io/server/wire_latency", "Time between the first request message received and the first response message sent in msecs", stats.UnitMilliseconds}
+	GRPCServerConnOpenedCount   = Int64{"/grpc.io/server/connections_opened", "Number of server connections opened", stats.UnitDimensionless}
+	GRPCServerConnClosedCount   = Int64{"/grpc.io/server/connections_closed", "Number of server connections closed", stats.UnitDimensionless}
+)
+
+// The canonical measures of the gRPC clients.
+var (
+	GRPCClientErrorCount        = Int64{"/grpc.io/client/error_count", "RPC Errors", stats.UnitDimensionless}
+	GRPCClientRoundTripLatency  = Float64{"/grpc.io/client/roundtrip_latency", "RPC roundtrip latency in msecs", stats.UnitMilliseconds}
+	GRPCClientRequestBytes      = Int64{"/grpc.io/client/request_bytes", "Request bytes", stats.UnitBytes}
+	GRPCClientResponseBytes     = Int64{"/grpc.io/client/response_bytes", "Response bytes", stats.UnitBytes}
+	GRPCClientStartedCount      = Int64{"/grpc.io/client/started_count", "Number of client RPCs (streams) started", stats.UnitDimensionless}
+	GRPCClientFinishedCount     = Int64{"/grpc.io/client/finished_count", "Number of client RPCs (streams) finished", stats.UnitDimensionless}
+	GRPCClientRequestCount      = Int64{"/grpc.io/client/request_count", "Number of client RPC request messages", stats.UnitDimensionless}
+	GRPCClientResponseCount     = Int64{"/grpc.io/client/response_count", "Number of client RPC response messages", stats.UnitDimensionless}
+	GRPCClientRequestWireBytes  = Int64{"/grpc.io/client/request_wire_bytes", "Request bytes on the wire, after compression", stats.UnitBytes}
+	GRPCClientResponseWireBytes = Int64{"/grpc.io/client/response_wire_bytes", "Response bytes on the wire, after compression", stats.UnitBytes}
+	GRPCClientWireLatency       = Float64{"/grpc.io/client/wire_latency", "Time between the first request message sent and the first response message received in msecs", stats.UnitMilliseconds}
+	GRPCClientConnOpenedCount   = Int64{"/grpc.io/client/connections_opened", "Number of client connections opened", stats.UnitDimensionless}
+	GRPCClientConnClosedCount   = Int64{"/grpc.io/client/connections_closed", "Number of client connections closed", stats.UnitDimensionless}
+)
+
+// The canonical measures of the database clients.
+var (
+	DBClientLatency = Float64{"db/client/latency", "Latency of the database calls, until the result is received", stats.UnitMilliseconds}
+	DBClientCalls   = Int64{"db/client/calls", "Number of database calls", "{calls}"}
+	DBClientRows    = Int64{"db/client/rows", "Number of rows read or written by the database calls", "{rows}"}
+)
@@ -0,0 +1,57 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package semconv defines the canonical measures of the common kinds of
+// instrumentation (HTTP, gRPC, database clients): their names, units and
+// descriptions. Plugins and user code create the measures through these
+// definitions so that they all agree on naming, whichever registers a measure
+// first.
+package semconv
+
+import "github.com/census-instrumentation/opencensus-go/stats"
+
+// Float64 is the definition of a canonical measure of type float64.
+type Float64 struct {
+	Name        string
+	Description string
+	Unit        stats.Unit
+}
+
+// Int64 is the definition of a canonical measure of type int64.
+type Int64 struct {
+	Name        string
+	Description string
+	Unit        stats.Unit
+}
+
+// Register returns the measure d of the registry r, or of the default
+// registry if r is nil, creating it if it doesn't exist yet. It returns an
+// error if a measure with the name of d exists with another type or unit.
+func (d Float64) Register(r *stats.Registry) (*stats.MeasureFloat64, error) {
+	if r == nil {
+		return stats.GetOrCreateMeasureFloat64(d.Name, d.Description, d.Unit)
+	}
+	return r.GetOrCreateMeasureFloat64(d.Name, d.Description, d.Unit)
+}
+
+// Register returns the measure d of the registry r, or of the default
+// registry if r is nil, creating it if it doesn't exist yet. It returns an
+// error if a measure with the name of d exists with another type or unit.
+func (d Int64) Register(r *stats.Registry) (*stats.MeasureInt64, error) {
+	if r == nil {
+		return stats.GetOrCreateMeasureInt64(d.Name, d.Description, d.Unit)
+	}
+	return r.GetOrCreateMeasureInt64(d.Name, d.Description, d.Unit)
+}
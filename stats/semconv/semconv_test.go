@@ -0,0 +1,51 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package semconv
+
+import (
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+)
+
+func TestRegister(t *testing.T) {
+	r := stats.NewRegistry()
+	m1, err := HTTPServerLatency.Register(r)
+	if err != nil {
+		t.Fatalf("Register() got error %v, want no error", err)
+	}
+	m2, err := HTTPServerLatency.Register(r)
+	if err != nil {
+		t.Fatalf("Register() twice got error %v, want no error", err)
+	}
+	if m1 != m2 {
+		t.Errorf("Register() twice got different measures %p and %p, want the same", m1, m2)
+	}
+	if m1.Name() != "http/server/latency" || m1.Unit() != stats.UnitMilliseconds {
+		t.Errorf("Register() got measure %v with unit %v, want http/server/latency with unit ms", m1.Name(), m1.Unit())
+	}
+
+	if _, err := r.NewMeasureInt64(DBClientRows.Name, "rows", stats.UnitDimensionless); err != nil {
+		t.Fatalf("NewMeasureInt64() got error %v, want no error", err)
+	}
+	if _, err := DBClientRows.Register(r); err == nil {
+		t.Error("Register() over a measure with another unit got no error, want error")
+	}
+	asFloat64 := Float64{Name: DBClientRows.Name, Unit: stats.UnitDimensionless}
+	if _, err := asFloat64.Register(r); err == nil {
+		t.Error("Register() over a measure of another type got no error, want error")
+	}
+}
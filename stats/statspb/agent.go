@@ -0,0 +1,122 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package statspb
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// AgentClient is the client API of the Agent service.
+type AgentClient interface {
+	// Export opens a stream to send the views and their data to the agent.
+	Export(ctx context.Context, opts ...grpc.CallOption) (Agent_ExportClient, error)
+}
+
+type agentClient struct {
+	cc *grpc.ClientConn
+}
+
+// NewAgentClient returns a client of the Agent service using cc.
+func NewAgentClient(cc *grpc.ClientConn) AgentClient {
+	return &agentClient{cc}
+}
+
+func (c *agentClient) Export(ctx context.Context, opts ...grpc.CallOption) (Agent_ExportClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Agent_serviceDesc.Streams[0], c.cc, "/statspb.Agent/Export", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &agentExportClient{stream}, nil
+}
+
+// Agent_ExportClient is the client side of an Export stream.
+type Agent_ExportClient interface {
+	Send(*ExportRequest) error
+	CloseAndRecv() (*ExportResponse, error)
+	grpc.ClientStream
+}
+
+type agentExportClient struct {
+	grpc.ClientStream
+}
+
+func (x *agentExportClient) Send(m *ExportRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *agentExportClient) CloseAndRecv() (*ExportResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ExportResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AgentServer is the server API of the Agent service.
+type AgentServer interface {
+	Export(Agent_ExportServer) error
+}
+
+// RegisterAgentServer registers srv as the implementation of the Agent
+// service of s.
+func RegisterAgentServer(s *grpc.Server, srv AgentServer) {
+	s.RegisterService(&_Agent_serviceDesc, srv)
+}
+
+func _Agent_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AgentServer).Export(&agentExportServer{stream})
+}
+
+// Agent_ExportServer is the server side of an Export stream.
+type Agent_ExportServer interface {
+	SendAndClose(*ExportResponse) error
+	Recv() (*ExportRequest, error)
+	grpc.ServerStream
+}
+
+type agentExportServer struct {
+	grpc.ServerStream
+}
+
+func (x *agentExportServer) SendAndClose(m *ExportResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *agentExportServer) Recv() (*ExportRequest, error) {
+	m := new(ExportRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _Agent_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "statspb.Agent",
+	HandlerType: (*AgentServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Export",
+			Handler:       _Agent_Export_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "stats.proto",
+}
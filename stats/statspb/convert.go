@@ -0,0 +1,460 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package statspb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+// FromMeasure returns the message describing m.
+func FromMeasure(m stats.Measure) (*Measure, error) {
+	switch m := m.(type) {
+	case *stats.MeasureFloat64:
+		return &Measure{Name: m.Name(), Description: m.Description(), Unit: string(m.Unit()), Type: Measure_Type_FLOAT64}, nil
+	case *stats.MeasureInt64:
+		return &Measure{Name: m.Name(), Description: m.Description(), Unit: string(m.Unit()), Type: Measure_Type_INT64}, nil
+	case *stats.MeasureDuration:
+		return &Measure{Name: m.Name(), Description: m.Description(), Unit: string(m.Unit()), Type: Measure_Type_DURATION}, nil
+	default:
+		return nil, fmt.Errorf("cannot convert measure of type '%T'", m)
+	}
+}
+
+// ToMeasure returns the measure described by m. The measure registered with
+// the name of m is returned if it exists, otherwise a new measure is created.
+// It returns an error if the existing measure has a different unit, as the
+// values recorded by the processes couldn't be aggregated together. A missing
+// unit is taken as dimensionless.
+func (m *Measure) ToMeasure() (stats.Measure, error) {
+	unit := stats.Unit(m.Unit)
+	if unit == "" {
+		unit = stats.UnitDimensionless
+	}
+	if existing, err := stats.GetMeasureByName(m.Name); err == nil && existing != nil {
+		if u, ok := existing.(interface {
+			Unit() stats.Unit
+		}); ok && m.Unit != "" && u.Unit() != unit {
+			return nil, fmt.Errorf("measure '%v' has unit '%v', cannot use it with unit '%v'", m.Name, u.Unit(), unit)
+		}
+		return existing, nil
+	}
+	switch m.Type {
+	case Measure_Type_FLOAT64:
+		return stats.NewMeasureFloat64(m.Name, m.Description, unit)
+	case Measure_Type_INT64:
+		return stats.NewMeasureInt64(m.Name, m.Description, unit)
+	case Measure_Type_DURATION:
+		return stats.NewMeasureDuration(m.Name, m.Description)
+	default:
+		return nil, fmt.Errorf("cannot convert measure of type '%v'", m.Type)
+	}
+}
+
+// FromAggregation returns the message describing a.
+func FromAggregation(a stats.Aggregation) (*Aggregation, error) {
+	switch a := a.(type) {
+	case *stats.AggregationCount:
+		return &Aggregation{Type: Aggregation_Type_COUNT}, nil
+	case *stats.AggregationDistribution:
+		return &Aggregation{Type: Aggregation_Type_DISTRIBUTION, Bounds: a.Bounds()}, nil
+	case *stats.AggregationSum:
+		return &Aggregation{Type: Aggregation_Type_SUM}, nil
+	case *stats.AggregationMean:
+		return &Aggregation{Type: Aggregation_Type_MEAN}, nil
+	case *stats.AggregationRate:
+		return &Aggregation{Type: Aggregation_Type_RATE}, nil
+	case *stats.AggregationRatio:
+		return &Aggregation{Type: Aggregation_Type_RATIO}, nil
+	case *stats.AggregationMulti:
+		pb := &Aggregation{Type: Aggregation_Type_MULTI}
+		for _, agg := range a.Aggregations() {
+			sub, err := FromAggregation(agg)
+			if err != nil {
+				return nil, err
+			}
+			pb.Aggregations = append(pb.Aggregations, sub)
+		}
+		return pb, nil
+	default:
+		return nil, fmt.Errorf("cannot convert aggregation of type '%T'", a)
+	}
+}
+
+// ToAggregation returns the aggregation described by a. The aggregations of
+// the rate and ratio views only describe their data: the views created with
+// them aren't derived from other views.
+func (a *Aggregation) ToAggregation() (stats.Aggregation, error) {
+	switch a.Type {
+	case Aggregation_Type_COUNT:
+		return stats.NewAggregationCount(), nil
+	case Aggregation_Type_DISTRIBUTION:
+		if err := stats.ValidateDistributionBounds(a.Bounds); err != nil {
+			return nil, err
+		}
+		return stats.NewAggregationDistribution(a.Bounds), nil
+	case Aggregation_Type_SUM:
+		return stats.NewAggregationSum(), nil
+	case Aggregation_Type_MEAN:
+		return stats.NewAggregationMean(), nil
+	case Aggregation_Type_RATE:
+		return &stats.AggregationRate{}, nil
+	case Aggregation_Type_RATIO:
+		return &stats.AggregationRatio{}, nil
+	case Aggregation_Type_MULTI:
+		var aggs []stats.Aggregation
+		for _, sub := range a.Aggregations {
+			agg, err := sub.ToAggregation()
+			if err != nil {
+				return nil, err
+			}
+			aggs = append(aggs, agg)
+		}
+		return stats.NewAggregationMulti(aggs...), nil
+	default:
+		return nil, fmt.Errorf("cannot convert aggregation of type '%v'", a.Type)
+	}
+}
+
+// FromWindow returns the message describing w.
+func FromWindow(w stats.Window) (*Window, error) {
+	switch w := w.(type) {
+	case *stats.WindowCumulative:
+		return &Window{Type: Window_Type_CUMULATIVE}, nil
+	case *stats.WindowSlidingTime:
+		return &Window{
+			Type:          Window_Type_SLIDING_TIME,
+			DurationNanos: int64(w.Duration()),
+			SubIntervals:  int32(w.SubIntervals()),
+		}, nil
+	case *stats.WindowSlidingCount:
+		return &Window{
+			Type:    Window_Type_SLIDING_COUNT,
+			Count:   w.Count(),
+			SubSets: int32(w.SubSets()),
+		}, nil
+	default:
+		return nil, fmt.Errorf("cannot convert window of type '%T'", w)
+	}
+}
+
+// ToWindow returns the window described by w.
+func (w *Window) ToWindow() (stats.Window, error) {
+	switch w.Type {
+	case Window_Type_CUMULATIVE:
+		return stats.NewWindowCumulative(), nil
+	case Window_Type_SLIDING_TIME:
+		return stats.NewWindowSlidingTime(time.Duration(w.DurationNanos), int(w.SubIntervals)), nil
+	case Window_Type_SLIDING_COUNT:
+		return stats.NewWindowSlidingCount(w.Count, int(w.SubSets)), nil
+	default:
+		return nil, fmt.Errorf("cannot convert window of type '%v'", w.Type)
+	}
+}
+
+// FromView returns the message describing v. Only the name, description,
+// tag keys, measure, aggregation and primary window of the view are
+// described.
+func FromView(v stats.View) (*View, error) {
+	pb := &View{
+		Name:        v.Name(),
+		Description: v.Description(),
+		Unit:        string(v.Unit()),
+	}
+	for _, k := range v.TagKeys() {
+		pb.TagKeys = append(pb.TagKeys, k.Name())
+	}
+	if m := v.Measure(); m != nil {
+		pb.Measure = m.Name()
+	}
+	var err error
+	if pb.Aggregation, err = FromAggregation(v.Aggregation()); err != nil {
+		return nil, err
+	}
+	if pb.Window, err = FromWindow(v.Window()); err != nil {
+		return nil, err
+	}
+	return pb, nil
+}
+
+// ToView returns a new view described by v. The measure of the view must be
+// registered, and the tag keys of the view are created as string keys if
+// they don't exist. The unit of v, if set, must be the unit of the measure,
+// or for a duration measure one of the units of stats.WithDurationUnit.
+func (v *View) ToView() (stats.View, error) {
+	m, err := stats.GetMeasureByName(v.Measure)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := toKeys(v.TagKeys)
+	if err != nil {
+		return nil, err
+	}
+	if v.Aggregation == nil || v.Window == nil {
+		return nil, fmt.Errorf("cannot convert view '%v' without aggregation or window", v.Name)
+	}
+	agg, err := v.Aggregation.ToAggregation()
+	if err != nil {
+		return nil, err
+	}
+	wnd, err := v.Window.ToWindow()
+	if err != nil {
+		return nil, err
+	}
+	var opts []stats.ViewOption
+	if _, ok := m.(*stats.MeasureDuration); ok && v.Unit != "" {
+		opts = append(opts, stats.WithDurationUnit(stats.Unit(v.Unit)))
+	}
+	view := stats.NewView(v.Name, v.Description, keys, m, agg, wnd, opts...)
+	if v.Unit != "" && view.Unit() != stats.Unit(v.Unit) {
+		return nil, fmt.Errorf("cannot convert view '%v' with unit '%v' of measure '%v'", v.Name, v.Unit, m.Name())
+	}
+	return view, nil
+}
+
+func toKeys(names []string) ([]tags.Key, error) {
+	var keys []tags.Key
+	for _, name := range names {
+		k, err := tags.CreateKeyString(name)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// FromAggregationValue returns the message holding av.
+func FromAggregationValue(av stats.AggregationValue) (*AggregationValue, error) {
+	switch av := av.(type) {
+	case *stats.AggregationCountValue:
+		return &AggregationValue{Count: int64(*av)}, nil
+	case *stats.AggregationDistributionValue:
+		return &AggregationValue{Type: Aggregation_Type_DISTRIBUTION, Distribution: &DistributionValue{
+			Count:                 av.Count(),
+			Min:                   av.Min(),
+			Max:                   av.Max(),
+			Mean:                  av.Mean(),
+			SumOfSquaredDeviation: av.SumOfSquaredDeviation(),
+			CountPerBucket:        av.CountPerBucket(),
+			Bounds:                av.Bounds(),
+			Underflows:            av.Underflows(),
+			Overflows:             av.Overflows(),
+		}}, nil
+	case *stats.AggregationSumValue:
+		return &AggregationValue{Type: Aggregation_Type_SUM, Sum: float64(*av)}, nil
+	case *stats.AggregationMeanValue:
+		return &AggregationValue{Type: Aggregation_Type_MEAN, Mean: &MeanValue{Sum: av.Sum(), Count: av.Count()}}, nil
+	case *stats.AggregationRateValue:
+		return &AggregationValue{Type: Aggregation_Type_RATE, Rate: float64(*av)}, nil
+	case *stats.AggregationRatioValue:
+		return &AggregationValue{Type: Aggregation_Type_RATIO, Ratio: float64(*av)}, nil
+	case *stats.AggregationMultiValue:
+		pb := &AggregationValue{Type: Aggregation_Type_MULTI}
+		for _, v := range av.Values() {
+			sub, err := FromAggregationValue(v)
+			if err != nil {
+				return nil, err
+			}
+			pb.Values = append(pb.Values, sub)
+		}
+		return pb, nil
+	default:
+		return nil, fmt.Errorf("cannot convert aggregation value of type '%T'", av)
+	}
+}
+
+// ToAggregationValue returns the aggregation value held by av. The count and
+// distribution values, whose type the messages of the schema versions before
+// 4 don't set, are a distribution value if av.Distribution is set, a count
+// value otherwise.
+func (av *AggregationValue) ToAggregationValue() (stats.AggregationValue, error) {
+	switch av.Type {
+	case Aggregation_Type_COUNT, Aggregation_Type_DISTRIBUTION:
+		d := av.Distribution
+		if d == nil {
+			return stats.NewAggregationCountValue(av.Count), nil
+		}
+		if len(d.CountPerBucket) != len(d.Bounds)+1 {
+			return nil, fmt.Errorf("cannot convert distribution value with %v buckets and %v bounds", len(d.CountPerBucket), len(d.Bounds))
+		}
+		return stats.NewAggregationDistributionValue(d.Bounds, d.CountPerBucket, d.Count, d.Min, d.Max, d.Mean, d.SumOfSquaredDeviation, d.Underflows, d.Overflows), nil
+	case Aggregation_Type_SUM:
+		return stats.NewAggregationSumValue(av.Sum), nil
+	case Aggregation_Type_MEAN:
+		if av.Mean == nil {
+			return stats.NewAggregationMeanValue(0, 0), nil
+		}
+		return stats.NewAggregationMeanValue(av.Mean.Sum, av.Mean.Count), nil
+	case Aggregation_Type_RATE:
+		return stats.NewAggregationRateValue(av.Rate), nil
+	case Aggregation_Type_RATIO:
+		return stats.NewAggregationRatioValue(av.Ratio), nil
+	case Aggregation_Type_MULTI:
+		var values []stats.AggregationValue
+		for _, sub := range av.Values {
+			v, err := sub.ToAggregationValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return stats.NewAggregationMultiValue(values...), nil
+	default:
+		return nil, fmt.Errorf("cannot convert aggregation value of type '%v'", av.Type)
+	}
+}
+
+// FromRow returns the message holding r.
+func FromRow(r *stats.Row) (*Row, error) {
+	av, err := FromAggregationValue(r.AggregationValue)
+	if err != nil {
+		return nil, err
+	}
+	pb := &Row{Value: av}
+	if !r.Start.IsZero() {
+		pb.StartUnixNanos = r.Start.UnixNano()
+	}
+	for _, t := range r.Tags {
+		pb.Tags = append(pb.Tags, &Tag{Key: t.K.Name(), Value: t.V})
+	}
+	return pb, nil
+}
+
+// ToRow returns the row held by r. The tag keys of the row are created as
+// string keys if they don't exist.
+func (r *Row) ToRow() (*stats.Row, error) {
+	if r.Value == nil {
+		return nil, fmt.Errorf("cannot convert row without value")
+	}
+	av, err := r.Value.ToAggregationValue()
+	if err != nil {
+		return nil, err
+	}
+	row := &stats.Row{AggregationValue: av}
+	if r.StartUnixNanos != 0 {
+		row.Start = time.Unix(0, r.StartUnixNanos)
+	}
+	for _, t := range r.Tags {
+		k, err := tags.CreateKeyString(t.Key)
+		if err != nil {
+			return nil, err
+		}
+		row.Tags = append(row.Tags, tags.Tag{K: k, V: t.Value})
+	}
+	return row, nil
+}
+
+// FromExemplar returns the message holding e. The span context of e is not
+// converted.
+func FromExemplar(e *stats.Exemplar) (*Exemplar, error) {
+	pb := &Exemplar{
+		TimeUnixNanos: e.Time.UnixNano(),
+		Attachments:   e.Attachments,
+	}
+	switch v := e.Value.(type) {
+	case float64:
+		pb.Value = v
+	case int64:
+		pb.Value = float64(v)
+	case time.Duration:
+		pb.Value = float64(v)
+	default:
+		return nil, fmt.Errorf("cannot convert exemplar value of type '%T'", v)
+	}
+	for _, t := range e.Tags {
+		pb.Tags = append(pb.Tags, &Tag{Key: t.K.Name(), Value: t.V})
+	}
+	return pb, nil
+}
+
+// ToExemplar returns the exemplar held by e. Its value is a float64. The tag
+// keys of the exemplar are created as string keys if they don't exist.
+func (e *Exemplar) ToExemplar() (*stats.Exemplar, error) {
+	ex := &stats.Exemplar{
+		Value:       e.Value,
+		Time:        time.Unix(0, e.TimeUnixNanos),
+		Attachments: e.Attachments,
+	}
+	for _, t := range e.Tags {
+		k, err := tags.CreateKeyString(t.Key)
+		if err != nil {
+			return nil, err
+		}
+		ex.Tags = append(ex.Tags, tags.Tag{K: k, V: t.Value})
+	}
+	return ex, nil
+}
+
+// FromViewData returns the message holding vd, stamped with SchemaVersion.
+func FromViewData(vd *stats.ViewData) (*ViewData, error) {
+	v, err := FromView(vd.V)
+	if err != nil {
+		return nil, err
+	}
+	pb := &ViewData{
+		View:           v,
+		StartUnixNanos: vd.Start.UnixNano(),
+		EndUnixNanos:   vd.End.UnixNano(),
+		SchemaVersion:  SchemaVersion,
+		Reset_:         vd.Reset,
+	}
+	for _, r := range vd.Rows {
+		row, err := FromRow(r)
+		if err != nil {
+			return nil, err
+		}
+		pb.Rows = append(pb.Rows, row)
+	}
+	for _, e := range vd.Exemplars {
+		ex, err := FromExemplar(e)
+		if err != nil {
+			return nil, err
+		}
+		pb.Exemplars = append(pb.Exemplars, ex)
+	}
+	return pb, nil
+}
+
+// ToRows returns the rows held by vd.
+func (vd *ViewData) ToRows() ([]*stats.Row, error) {
+	var rows []*stats.Row
+	for _, r := range vd.Rows {
+		row, err := r.ToRow()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ToExemplars returns the exemplars held by vd. The messages of schema
+// version 0 have none.
+func (vd *ViewData) ToExemplars() ([]*stats.Exemplar, error) {
+	var exemplars []*stats.Exemplar
+	for _, e := range vd.Exemplars {
+		ex, err := e.ToExemplar()
+		if err != nil {
+			return nil, err
+		}
+		exemplars = append(exemplars, ex)
+	}
+	return exemplars, nil
+}
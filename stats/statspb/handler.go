@@ -0,0 +1,50 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package statspb
+
+import (
+	"io"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/golang/protobuf/proto"
+)
+
+// MediaTypeProtobuf is the media type of the protocol buffer format served by
+// the handlers returned by stats.NewMetricsHandler once this package is
+// imported: an ExportRequest holding a ViewData per view, each with the
+// definition of its view.
+const MediaTypeProtobuf = "application/x-protobuf"
+
+func encodeExportRequest(w io.Writer, vds []*stats.ViewData) error {
+	req := &ExportRequest{}
+	for _, vd := range vds {
+		pb, err := FromViewData(vd)
+		if err != nil {
+			return err
+		}
+		req.ViewData = append(req.ViewData, pb)
+	}
+	b, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func init() {
+	stats.RegisterMetricsEncoder(MediaTypeProtobuf, encodeExportRequest)
+}
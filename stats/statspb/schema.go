@@ -0,0 +1,92 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package statspb
+
+import "fmt"
+
+// SchemaVersion is the version of the schema of the ViewData messages
+// produced by FromViewData. It is incremented when fields are added to the
+// messages, so that the collectors, which usually outlive the processes
+// exporting the data, can tell which fields the producer knew about, and
+// convert the messages of the older versions with Upgrade. The versions are:
+//
+//	0: the messages produced before the version was introduced.
+//	1: the version and the exemplars of the data are set.
+//	2: the start of the rows of the cumulative windows is set.
+//	3: the reset marker of the data is set.
+//	4: the type of the aggregation values is set, and the values of the sum,
+//	   mean and multi aggregations and of the rate and ratio views are
+//	   supported.
+//	5: the unit of the aggregated values of the views is set.
+const SchemaVersion = 5
+
+// upgraders convert the messages of a schema version to the next version.
+// The versions whose messages only miss the fields added by the next
+// version, which are left unset, don't need an upgrader.
+var upgraders = map[uint32]func(vd *ViewData) error{
+	1: upgradeRowStarts,
+}
+
+// upgradeRowStarts sets the start of the rows of the cumulative windows to
+// the start of the data, which is the best approximation the older versions
+// carry.
+func upgradeRowStarts(vd *ViewData) error {
+	if vd.View != nil && vd.View.Window != nil && vd.View.Window.Type != Window_Type_CUMULATIVE {
+		return nil
+	}
+	for _, r := range vd.Rows {
+		if r.StartUnixNanos == 0 {
+			r.StartUnixNanos = vd.StartUnixNanos
+		}
+	}
+	return nil
+}
+
+// CompatibilityMode defines how the collectors handle the ViewData messages
+// of a schema version newer than SchemaVersion, i.e. produced by a newer
+// version of the library.
+type CompatibilityMode int
+
+const (
+	// CompatibilityStrict rejects the messages of the newer versions. It is
+	// the default mode.
+	CompatibilityStrict CompatibilityMode = iota
+	// CompatibilityLenient accepts the messages of the newer versions,
+	// ignoring the fields added by these versions.
+	CompatibilityLenient
+)
+
+// Upgrade converts vd, produced with an older schema version, to
+// SchemaVersion. It returns an error if vd was produced with a newer schema
+// version and mode is CompatibilityStrict. The messages of the newer
+// versions are left as is in CompatibilityLenient mode: their unknown fields
+// were already dropped when they were unmarshaled.
+func (vd *ViewData) Upgrade(mode CompatibilityMode) error {
+	if vd.SchemaVersion > SchemaVersion {
+		if mode == CompatibilityStrict {
+			return fmt.Errorf("cannot convert view data of schema version %v, newer than version %v", vd.SchemaVersion, SchemaVersion)
+		}
+		return nil
+	}
+	for ; vd.SchemaVersion < SchemaVersion; vd.SchemaVersion++ {
+		if up := upgraders[vd.SchemaVersion]; up != nil {
+			if err := up(vd); err != nil {
+				return fmt.Errorf("cannot convert view data of schema version %v to version %v: %v", vd.SchemaVersion, vd.SchemaVersion+1, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package statspb defines protocol buffer messages for the measures, views and
+// collected data of the stats package, and converters from and to the stats
+// types. They allow services to ship their collected stats to a central
+// collector, e.g. over gRPC.
+//
+// The messages are defined in stats.proto. They are written in the style of
+// the code generated by protoc-gen-go, so they can be marshaled with
+// github.com/golang/protobuf/proto.
+package statspb
+
+import "github.com/golang/protobuf/proto"
+
+type Measure_Type int32
+
+const (
+	Measure_Type_FLOAT64  Measure_Type = 0
+	Measure_Type_INT64    Measure_Type = 1
+	Measure_Type_DURATION Measure_Type = 2
+)
+
+var Measure_Type_name = map[int32]string{
+	0: "FLOAT64",
+	1: "INT64",
+	2: "DURATION",
+}
+
+var Measure_Type_value = map[string]int32{
+	"FLOAT64":  0,
+	"INT64":    1,
+	"DURATION": 2,
+}
+
+func (x Measure_Type) String() string {
+	return proto.EnumName(Measure_Type_name, int32(x))
+}
+
+type Aggregation_Type int32
+
+const (
+	Aggregation_Type_COUNT        Aggregation_Type = 0
+	Aggregation_Type_DISTRIBUTION Aggregation_Type = 1
+	Aggregation_Type_SUM          Aggregation_Type = 2
+	Aggregation_Type_MEAN         Aggregation_Type = 3
+	Aggregation_Type_RATE         Aggregation_Type = 4
+	Aggregation_Type_RATIO        Aggregation_Type = 5
+	Aggregation_Type_MULTI        Aggregation_Type = 6
+)
+
+var Aggregation_Type_name = map[int32]string{
+	0: "COUNT",
+	1: "DISTRIBUTION",
+	2: "SUM",
+	3: "MEAN",
+	4: "RATE",
+	5: "RATIO",
+	6: "MULTI",
+}
+
+var Aggregation_Type_value = map[string]int32{
+	"COUNT":        0,
+	"DISTRIBUTION": 1,
+	"SUM":          2,
+	"MEAN":         3,
+	"RATE":         4,
+	"RATIO":        5,
+	"MULTI":        6,
+}
+
+func (x Aggregation_Type) String() string {
+	return proto.EnumName(Aggregation_Type_name, int32(x))
+}
+
+type Window_Type int32
+
+const (
+	Window_Type_CUMULATIVE    Window_Type = 0
+	Window_Type_SLIDING_TIME  Window_Type = 1
+	Window_Type_SLIDING_COUNT Window_Type = 2
+)
+
+var Window_Type_name = map[int32]string{
+	0: "CUMULATIVE",
+	1: "SLIDING_TIME",
+	2: "SLIDING_COUNT",
+}
+
+var Window_Type_value = map[string]int32{
+	"CUMULATIVE":    0,
+	"SLIDING_TIME":  1,
+	"SLIDING_COUNT": 2,
+}
+
+func (x Window_Type) String() string {
+	return proto.EnumName(Window_Type_name, int32(x))
+}
+
+type Measure struct {
+	Name        string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Description string       `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	Unit        string       `protobuf:"bytes,3,opt,name=unit,proto3" json:"unit,omitempty"`
+	Type        Measure_Type `protobuf:"varint,4,opt,name=type,enum=statspb.Measure_Type,proto3" json:"type,omitempty"`
+}
+
+func (m *Measure) Reset()         { *m = Measure{} }
+func (m *Measure) String() string { return proto.CompactTextString(m) }
+func (*Measure) ProtoMessage()    {}
+
+type Aggregation struct {
+	Type         Aggregation_Type `protobuf:"varint,1,opt,name=type,enum=statspb.Aggregation_Type,proto3" json:"type,omitempty"`
+	Bounds       []float64        `protobuf:"fixed64,2,rep,packed,name=bounds" json:"bounds,omitempty"`
+	Aggregations []*Aggregation   `protobuf:"bytes,3,rep,name=aggregations" json:"aggregations,omitempty"`
+}
+
+func (m *Aggregation) Reset()         { *m = Aggregation{} }
+func (m *Aggregation) String() string { return proto.CompactTextString(m) }
+func (*Aggregation) ProtoMessage()    {}
+
+type Window struct {
+	Type          Window_Type `protobuf:"varint,1,opt,name=type,enum=statspb.Window_Type,proto3" json:"type,omitempty"`
+	DurationNanos int64       `protobuf:"varint,2,opt,name=duration_nanos,proto3" json:"durationNanos,omitempty"`
+	SubIntervals  int32       `protobuf:"varint,3,opt,name=sub_intervals,proto3" json:"subIntervals,omitempty"`
+	Count         uint64      `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`
+	SubSets       int32       `protobuf:"varint,5,opt,name=sub_sets,proto3" json:"subSets,omitempty"`
+}
+
+func (m *Window) Reset()         { *m = Window{} }
+func (m *Window) String() string { return proto.CompactTextString(m) }
+func (*Window) ProtoMessage()    {}
+
+type View struct {
+	Name        string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Description string       `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	TagKeys     []string     `protobuf:"bytes,3,rep,name=tag_keys" json:"tagKeys,omitempty"`
+	Measure     string       `protobuf:"bytes,4,opt,name=measure,proto3" json:"measure,omitempty"`
+	Aggregation *Aggregation `protobuf:"bytes,5,opt,name=aggregation" json:"aggregation,omitempty"`
+	Window      *Window      `protobuf:"bytes,6,opt,name=window" json:"window,omitempty"`
+	Unit        string       `protobuf:"bytes,7,opt,name=unit,proto3" json:"unit,omitempty"`
+}
+
+func (m *View) Reset()         { *m = View{} }
+func (m *View) String() string { return proto.CompactTextString(m) }
+func (*View) ProtoMessage()    {}
+
+type Tag struct {
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *Tag) Reset()         { *m = Tag{} }
+func (m *Tag) String() string { return proto.CompactTextString(m) }
+func (*Tag) ProtoMessage()    {}
+
+type DistributionValue struct {
+	Count                 int64     `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Min                   float64   `protobuf:"fixed64,2,opt,name=min,proto3" json:"min,omitempty"`
+	Max                   float64   `protobuf:"fixed64,3,opt,name=max,proto3" json:"max,omitempty"`
+	Mean                  float64   `protobuf:"fixed64,4,opt,name=mean,proto3" json:"mean,omitempty"`
+	SumOfSquaredDeviation float64   `protobuf:"fixed64,5,opt,name=sum_of_squared_deviation,proto3" json:"sumOfSquaredDeviation,omitempty"`
+	CountPerBucket        []int64   `protobuf:"varint,6,rep,packed,name=count_per_bucket" json:"countPerBucket,omitempty"`
+	Bounds                []float64 `protobuf:"fixed64,7,rep,packed,name=bounds" json:"bounds,omitempty"`
+	Underflows            int64     `protobuf:"varint,8,opt,name=underflows,proto3" json:"underflows,omitempty"`
+	Overflows             int64     `protobuf:"varint,9,opt,name=overflows,proto3" json:"overflows,omitempty"`
+}
+
+func (m *DistributionValue) Reset()         { *m = DistributionValue{} }
+func (m *DistributionValue) String() string { return proto.CompactTextString(m) }
+func (*DistributionValue) ProtoMessage()    {}
+
+type MeanValue struct {
+	Sum   float64 `protobuf:"fixed64,1,opt,name=sum,proto3" json:"sum,omitempty"`
+	Count float64 `protobuf:"fixed64,2,opt,name=count,proto3" json:"count,omitempty"`
+}
+
+func (m *MeanValue) Reset()         { *m = MeanValue{} }
+func (m *MeanValue) String() string { return proto.CompactTextString(m) }
+func (*MeanValue) ProtoMessage()    {}
+
+type AggregationValue struct {
+	Count        int64               `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Distribution *DistributionValue  `protobuf:"bytes,2,opt,name=distribution" json:"distribution,omitempty"`
+	Type         Aggregation_Type    `protobuf:"varint,3,opt,name=type,enum=statspb.Aggregation_Type,proto3" json:"type,omitempty"`
+	Sum          float64             `protobuf:"fixed64,4,opt,name=sum,proto3" json:"sum,omitempty"`
+	Mean         *MeanValue          `protobuf:"bytes,5,opt,name=mean" json:"mean,omitempty"`
+	Rate         float64             `protobuf:"fixed64,6,opt,name=rate,proto3" json:"rate,omitempty"`
+	Ratio        float64             `protobuf:"fixed64,7,opt,name=ratio,proto3" json:"ratio,omitempty"`
+	Values       []*AggregationValue `protobuf:"bytes,8,rep,name=values" json:"values,omitempty"`
+}
+
+func (m *AggregationValue) Reset()         { *m = AggregationValue{} }
+func (m *AggregationValue) String() string { return proto.CompactTextString(m) }
+func (*AggregationValue) ProtoMessage()    {}
+
+type Row struct {
+	Tags           []*Tag            `protobuf:"bytes,1,rep,name=tags" json:"tags,omitempty"`
+	Value          *AggregationValue `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	StartUnixNanos int64             `protobuf:"varint,3,opt,name=start_unix_nanos,proto3" json:"startUnixNanos,omitempty"`
+}
+
+func (m *Row) Reset()         { *m = Row{} }
+func (m *Row) String() string { return proto.CompactTextString(m) }
+func (*Row) ProtoMessage()    {}
+
+type Exemplar struct {
+	Tags          []*Tag            `protobuf:"bytes,1,rep,name=tags" json:"tags,omitempty"`
+	Value         float64           `protobuf:"fixed64,2,opt,name=value,proto3" json:"value,omitempty"`
+	TimeUnixNanos int64             `protobuf:"varint,3,opt,name=time_unix_nanos,proto3" json:"timeUnixNanos,omitempty"`
+	Attachments   map[string]string `protobuf:"bytes,4,rep,name=attachments" json:"attachments,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *Exemplar) Reset()         { *m = Exemplar{} }
+func (m *Exemplar) String() string { return proto.CompactTextString(m) }
+func (*Exemplar) ProtoMessage()    {}
+
+type ViewData struct {
+	View           *View       `protobuf:"bytes,1,opt,name=view" json:"view,omitempty"`
+	StartUnixNanos int64       `protobuf:"varint,2,opt,name=start_unix_nanos,proto3" json:"startUnixNanos,omitempty"`
+	EndUnixNanos   int64       `protobuf:"varint,3,opt,name=end_unix_nanos,proto3" json:"endUnixNanos,omitempty"`
+	Rows           []*Row      `protobuf:"bytes,4,rep,name=rows" json:"rows,omitempty"`
+	SchemaVersion  uint32      `protobuf:"varint,5,opt,name=schema_version,proto3" json:"schemaVersion,omitempty"`
+	Exemplars      []*Exemplar `protobuf:"bytes,6,rep,name=exemplars" json:"exemplars,omitempty"`
+	Reset_         bool        `protobuf:"varint,7,opt,name=reset,proto3" json:"reset,omitempty"`
+}
+
+func (m *ViewData) Reset()         { *m = ViewData{} }
+func (m *ViewData) String() string { return proto.CompactTextString(m) }
+func (*ViewData) ProtoMessage()    {}
+
+type ExportRequest struct {
+	Views     []*View     `protobuf:"bytes,1,rep,name=views" json:"views,omitempty"`
+	ViewData  []*ViewData `protobuf:"bytes,2,rep,name=view_data" json:"viewData,omitempty"`
+	ProcessId string      `protobuf:"bytes,3,opt,name=process_id" json:"processId,omitempty"`
+}
+
+func (m *ExportRequest) Reset()         { *m = ExportRequest{} }
+func (m *ExportRequest) String() string { return proto.CompactTextString(m) }
+func (*ExportRequest) ProtoMessage()    {}
+
+type ExportResponse struct {
+}
+
+func (m *ExportResponse) Reset()         { *m = ExportResponse{} }
+func (m *ExportResponse) String() string { return proto.CompactTextString(m) }
+func (*ExportResponse) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterType((*Measure)(nil), "statspb.Measure")
+	proto.RegisterType((*Aggregation)(nil), "statspb.Aggregation")
+	proto.RegisterType((*Window)(nil), "statspb.Window")
+	proto.RegisterType((*View)(nil), "statspb.View")
+	proto.RegisterType((*Tag)(nil), "statspb.Tag")
+	proto.RegisterType((*DistributionValue)(nil), "statspb.DistributionValue")
+	proto.RegisterType((*MeanValue)(nil), "statspb.MeanValue")
+	proto.RegisterType((*AggregationValue)(nil), "statspb.AggregationValue")
+	proto.RegisterType((*Row)(nil), "statspb.Row")
+	proto.RegisterType((*Exemplar)(nil), "statspb.Exemplar")
+	proto.RegisterType((*ViewData)(nil), "statspb.ViewData")
+	proto.RegisterType((*ExportRequest)(nil), "statspb.ExportRequest")
+	proto.RegisterType((*ExportResponse)(nil), "statspb.ExportResponse")
+	proto.RegisterEnum("statspb.Measure_Type", Measure_Type_name, Measure_Type_value)
+	proto.RegisterEnum("statspb.Aggregation_Type", Aggregation_Type_name, Aggregation_Type_value)
+	proto.RegisterEnum("statspb.Window_Type", Window_Type_name, Window_Type_value)
+}
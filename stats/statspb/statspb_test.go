@@ -0,0 +1,351 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package statspb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+func Test_ViewData_RoundTrip(t *testing.T) {
+	m, err := stats.NewMeasureFloat64("statspb/latency", "the latency", "ms")
+	if err != nil {
+		t.Fatalf("NewMeasureFloat64() got error %v, want no error", err)
+	}
+	defer stats.DeleteMeasure(m)
+	k, err := tags.CreateKeyString("statspb/method")
+	if err != nil {
+		t.Fatalf("CreateKeyString() got error %v, want no error", err)
+	}
+
+	type testCase struct {
+		label string
+		agg   stats.Aggregation
+		wnd   stats.Window
+		rows  []*stats.Row
+	}
+	tcs := []testCase{
+		{
+			"count",
+			stats.NewAggregationCount(),
+			stats.NewWindowCumulative(),
+			[]*stats.Row{
+				{
+					Tags:             []tags.Tag{{K: k, V: []byte("get")}},
+					AggregationValue: stats.NewAggregationCountValue(3),
+					Start:            time.Unix(110, 0),
+				},
+			},
+		},
+		{
+			"distribution",
+			stats.NewAggregationDistribution([]float64{0, 10}),
+			stats.NewWindowSlidingTime(time.Minute, 6),
+			[]*stats.Row{
+				{
+					Tags:             []tags.Tag{{K: k, V: []byte("get")}},
+					AggregationValue: stats.NewAggregationDistributionValue([]float64{0, 10}, []int64{0, 2, 1}, 3, 1, 12, 6, 62, 0, 0),
+				},
+				{
+					Tags:             nil,
+					AggregationValue: stats.NewAggregationDistributionValue([]float64{0, 10}, []int64{0, 1, 0}, 1, 5, 5, 5, 0, 2, 1),
+				},
+			},
+		},
+		{
+			"sum",
+			stats.NewAggregationSum(),
+			stats.NewWindowSlidingTime(time.Minute, 6),
+			[]*stats.Row{
+				{
+					Tags:             []tags.Tag{{K: k, V: []byte("get")}},
+					AggregationValue: stats.NewAggregationSumValue(12.5),
+				},
+				{
+					Tags:             nil,
+					AggregationValue: stats.NewAggregationSumValue(0),
+				},
+			},
+		},
+		{
+			"mean",
+			stats.NewAggregationMean(),
+			stats.NewWindowCumulative(),
+			[]*stats.Row{
+				{
+					Tags:             []tags.Tag{{K: k, V: []byte("get")}},
+					AggregationValue: stats.NewAggregationMeanValue(12.5, 2.5),
+					Start:            time.Unix(110, 0),
+				},
+			},
+		},
+		{
+			"rate",
+			&stats.AggregationRate{},
+			stats.NewWindowCumulative(),
+			[]*stats.Row{
+				{
+					Tags:             []tags.Tag{{K: k, V: []byte("get")}},
+					AggregationValue: stats.NewAggregationRateValue(2.5),
+				},
+			},
+		},
+		{
+			"ratio",
+			&stats.AggregationRatio{},
+			stats.NewWindowCumulative(),
+			[]*stats.Row{
+				{
+					Tags:             []tags.Tag{{K: k, V: []byte("get")}},
+					AggregationValue: stats.NewAggregationRatioValue(0.25),
+				},
+			},
+		},
+		{
+			"multi",
+			stats.NewAggregationMulti(stats.NewAggregationCount(), stats.NewAggregationDistribution([]float64{0, 10}), stats.NewAggregationMean()),
+			stats.NewWindowCumulative(),
+			[]*stats.Row{
+				{
+					Tags: []tags.Tag{{K: k, V: []byte("get")}},
+					AggregationValue: stats.NewAggregationMultiValue(
+						stats.NewAggregationCountValue(3),
+						stats.NewAggregationDistributionValue([]float64{0, 10}, []int64{0, 2, 1}, 3, 1, 12, 6, 62, 0, 0),
+						stats.NewAggregationMeanValue(18, 3),
+					),
+					Start: time.Unix(110, 0),
+				},
+			},
+		},
+		{
+			"sliding count",
+			stats.NewAggregationCount(),
+			stats.NewWindowSlidingCount(100, 10),
+			nil,
+		},
+	}
+
+	for _, tc := range tcs {
+		v := stats.NewView("statspb/view", "the view", []tags.Key{k}, m, tc.agg, tc.wnd)
+		start := time.Unix(100, 0)
+		end := time.Unix(160, 0)
+		pb, err := FromViewData(&stats.ViewData{V: v, Start: start, End: end, Rows: tc.rows})
+		if err != nil {
+			t.Errorf("%v: FromViewData() got error %v, want no error", tc.label, err)
+			continue
+		}
+
+		b, err := proto.Marshal(pb)
+		if err != nil {
+			t.Errorf("%v: Marshal() got error %v, want no error", tc.label, err)
+			continue
+		}
+		got := &ViewData{}
+		if err := proto.Unmarshal(b, got); err != nil {
+			t.Errorf("%v: Unmarshal() got error %v, want no error", tc.label, err)
+			continue
+		}
+		if !proto.Equal(got, pb) {
+			t.Errorf("%v: Unmarshal() got %v, want %v", tc.label, got, pb)
+		}
+
+		if got.StartUnixNanos != start.UnixNano() || got.EndUnixNanos != end.UnixNano() {
+			t.Errorf("%v: got start %v and end %v, want %v and %v", tc.label, got.StartUnixNanos, got.EndUnixNanos, start.UnixNano(), end.UnixNano())
+		}
+		rows, err := got.ToRows()
+		if err != nil {
+			t.Errorf("%v: ToRows() got error %v, want no error", tc.label, err)
+			continue
+		}
+		if ok, msg := stats.EqualRows(rows, tc.rows); !ok {
+			t.Errorf("%v: ToRows() got unexpected rows: %v", tc.label, msg)
+		}
+		for i, r := range rows {
+			if !r.Start.Equal(tc.rows[i].Start) {
+				t.Errorf("%v: ToRows() got start %v for row %v, want %v", tc.label, r.Start, i, tc.rows[i].Start)
+			}
+		}
+
+		gotView, err := got.View.ToView()
+		if err != nil {
+			t.Errorf("%v: ToView() got error %v, want no error", tc.label, err)
+			continue
+		}
+		if gotView.Name() != v.Name() || gotView.Description() != v.Description() || gotView.Measure() != m {
+			t.Errorf("%v: ToView() got %v %v %v, want %v %v %v", tc.label, gotView.Name(), gotView.Description(), gotView.Measure(), v.Name(), v.Description(), m)
+		}
+		if !reflect.DeepEqual(gotView.TagKeys(), v.TagKeys()) {
+			t.Errorf("%v: ToView() got keys %v, want %v", tc.label, gotView.TagKeys(), v.TagKeys())
+		}
+		if !reflect.DeepEqual(gotView.Aggregation(), v.Aggregation()) {
+			t.Errorf("%v: ToView() got aggregation %v, want %v", tc.label, gotView.Aggregation(), v.Aggregation())
+		}
+		if !reflect.DeepEqual(gotView.Window(), v.Window()) {
+			t.Errorf("%v: ToView() got window %v, want %v", tc.label, gotView.Window(), v.Window())
+		}
+	}
+}
+
+func Test_Measure_ToMeasure(t *testing.T) {
+	pb := &Measure{Name: "statspb/bytes", Description: "the size", Unit: "By", Type: Measure_Type_INT64}
+	m, err := pb.ToMeasure()
+	if err != nil {
+		t.Fatalf("ToMeasure() got error %v, want no error", err)
+	}
+	defer stats.DeleteMeasure(m)
+	mi, ok := m.(*stats.MeasureInt64)
+	if !ok {
+		t.Fatalf("ToMeasure() got %T, want *stats.MeasureInt64", m)
+	}
+	got, err := FromMeasure(mi)
+	if err != nil {
+		t.Fatalf("FromMeasure() got error %v, want no error", err)
+	}
+	if !proto.Equal(got, pb) {
+		t.Errorf("FromMeasure() got %v, want %v", got, pb)
+	}
+
+	// the registered measure is returned for the same name.
+	m2, err := pb.ToMeasure()
+	if err != nil {
+		t.Fatalf("ToMeasure() got error %v, want no error", err)
+	}
+	if m2 != m {
+		t.Errorf("ToMeasure() got %v, want the registered measure %v", m2, m)
+	}
+}
+
+func Test_View_DurationUnit(t *testing.T) {
+	m, err := stats.NewMeasureDuration("statspb/duration", "the duration")
+	if err != nil {
+		t.Fatalf("NewMeasureDuration() got error %v, want no error", err)
+	}
+	defer stats.DeleteMeasure(m)
+	v := stats.NewView("statspb/duration/sum", "the total duration", nil, m, stats.NewAggregationSum(), stats.NewWindowCumulative(), stats.WithDurationUnit("s"))
+	if err := stats.ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection() got error %v, want no error", err)
+	}
+	defer stats.UnregisterView(v)
+	stats.RecordDuration(context.Background(), m, 1500*time.Millisecond)
+	rows, err := stats.RetrieveData(v)
+	if err != nil {
+		t.Fatalf("RetrieveData() got error %v, want no error", err)
+	}
+
+	// the unit exported with the data is the one the values are aggregated
+	// in, not the nanoseconds of the measure.
+	pb, err := FromViewData(&stats.ViewData{V: v, Rows: rows})
+	if err != nil {
+		t.Fatalf("FromViewData() got error %v, want no error", err)
+	}
+	if pb.View.Unit != "s" {
+		t.Errorf("FromViewData() got unit %q, want \"s\"", pb.View.Unit)
+	}
+	if len(pb.Rows) != 1 || pb.Rows[0].Value.Sum != 1.5 {
+		t.Errorf("FromViewData() got rows %v, want a sum of 1.5", pb.Rows)
+	}
+
+	got, err := pb.View.ToView()
+	if err != nil {
+		t.Fatalf("ToView() got error %v, want no error", err)
+	}
+	if got.Unit() != "s" {
+		t.Errorf("ToView() got a view of unit %q, want \"s\"", got.Unit())
+	}
+	pb.View.Unit = "fortnight"
+	if _, err := pb.View.ToView(); err == nil {
+		t.Error("ToView() got no error, want error for an unsupported duration unit")
+	}
+}
+
+func Test_AggregationValue_ToAggregationValue_Invalid(t *testing.T) {
+	av := &AggregationValue{Distribution: &DistributionValue{
+		CountPerBucket: []int64{1, 2},
+		Bounds:         []float64{0, 10},
+	}}
+	if _, err := av.ToAggregationValue(); err == nil {
+		t.Error("ToAggregationValue() got no error, want error for mismatched buckets")
+	}
+}
+
+func Test_ViewData_Upgrade(t *testing.T) {
+	old := &ViewData{View: &View{Name: "statspb/old"}, StartUnixNanos: 100, Rows: []*Row{{}}}
+	if err := old.Upgrade(CompatibilityStrict); err != nil {
+		t.Errorf("Upgrade() of version 0 got error %v, want no error", err)
+	}
+	if old.SchemaVersion != SchemaVersion {
+		t.Errorf("Upgrade() of version 0 got version %v, want %v", old.SchemaVersion, SchemaVersion)
+	}
+	if exemplars, err := old.ToExemplars(); err != nil || exemplars != nil {
+		t.Errorf("ToExemplars() of version 0 got %v, %v, want no exemplars", exemplars, err)
+	}
+	if got := old.Rows[0].StartUnixNanos; got != 100 {
+		t.Errorf("Upgrade() of version 0 got row start %v, want the start of the data 100", got)
+	}
+
+	sliding := &ViewData{
+		View:           &View{Name: "statspb/sliding", Window: &Window{Type: Window_Type_SLIDING_TIME}},
+		SchemaVersion:  1,
+		StartUnixNanos: 100,
+		Rows:           []*Row{{}},
+	}
+	if err := sliding.Upgrade(CompatibilityStrict); err != nil {
+		t.Errorf("Upgrade() of version 1 got error %v, want no error", err)
+	}
+	if got := sliding.Rows[0].StartUnixNanos; got != 0 {
+		t.Errorf("Upgrade() of version 1 of a sliding window got row start %v, want 0", got)
+	}
+
+	newer := &ViewData{View: &View{Name: "statspb/newer"}, SchemaVersion: SchemaVersion + 1}
+	if err := newer.Upgrade(CompatibilityStrict); err == nil {
+		t.Errorf("Upgrade() of version %v in strict mode got no error, want error", newer.SchemaVersion)
+	}
+	if err := newer.Upgrade(CompatibilityLenient); err != nil {
+		t.Errorf("Upgrade() of version %v in lenient mode got error %v, want no error", newer.SchemaVersion, err)
+	}
+}
+
+func Test_Exemplar_RoundTrip(t *testing.T) {
+	k, err := tags.CreateKeyString("statspb/route")
+	if err != nil {
+		t.Fatalf("CreateKeyString() got error %v, want no error", err)
+	}
+	e := &stats.Exemplar{
+		Tags:        []tags.Tag{{K: k, V: []byte("/a")}},
+		Value:       int64(12),
+		Time:        time.Unix(100, 0),
+		Attachments: map[string]string{"trace": "abc"},
+	}
+	pb, err := FromExemplar(e)
+	if err != nil {
+		t.Fatalf("FromExemplar() got error %v, want no error", err)
+	}
+	got, err := pb.ToExemplar()
+	if err != nil {
+		t.Fatalf("ToExemplar() got error %v, want no error", err)
+	}
+	want := *e
+	want.Value = float64(12)
+	if !reflect.DeepEqual(got, &want) {
+		t.Errorf("ToExemplar() got %+v, want %+v", got, &want)
+	}
+}
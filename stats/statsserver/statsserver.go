@@ -0,0 +1,253 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package statsserver merges the data collected by several processes. Its
+// Server implements the Agent service of the package statspb: the processes
+// export their data with the package exporter/agent, and the merged data is
+// collected in a registry, from which it is retrieved or exported like the
+// data recorded locally.
+package statsserver
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/stats/statspb"
+	"github.com/census-instrumentation/opencensus-go/stats/viewdatadiff"
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"github.com/golang/protobuf/proto"
+)
+
+// Server merges the rows of the views exported by the processes into views
+// of the same name collecting data in its registry. The data of a view is
+// the sum of the data collected by all the processes since the server
+// received their first data, as if it was recorded by a single process.
+//
+// Only the data of the views with a cumulative window is merged. The data of
+// the views with other windows is ignored since it cannot be summed across
+// processes, and so is the data of the rate and ratio views, which isn't
+// cumulative. The views exported under the same name by the processes must
+// have the same tag keys and aggregation.
+//
+// The data produced by the processes running older versions of the library
+// is upgraded to the current schema version, see statspb.SchemaVersion. The
+// data of newer versions is rejected unless SetCompatibilityMode is called
+// with statspb.CompatibilityLenient.
+type Server struct {
+	r *stats.Registry
+
+	mu    sync.Mutex
+	mode  statspb.CompatibilityMode
+	views map[string]*serverView
+	// processes holds the last data received from each process, by process
+	// id and view name.
+	processes map[string]map[string]*stats.ViewData
+}
+
+// serverView is a view merging the data of the processes, and the definition
+// it was created from.
+type serverView struct {
+	pb *statspb.View
+	v  stats.View
+}
+
+// NewServer returns a Server merging the data into views registered in r. r
+// should be dedicated to the server so that the merged views don't collide
+// with the views of the server process. The merged views are retrieved with
+// r.GetViewByName.
+func NewServer(r *stats.Registry) *Server {
+	return &Server{
+		r:         r,
+		views:     make(map[string]*serverView),
+		processes: make(map[string]map[string]*stats.ViewData),
+	}
+}
+
+// SetCompatibilityMode sets how the data of schema versions newer than
+// statspb.SchemaVersion is handled. See statspb.CompatibilityMode.
+func (s *Server) SetCompatibilityMode(mode statspb.CompatibilityMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mode = mode
+}
+
+// Export implements statspb.AgentServer. It merges the data received on the
+// stream until the stream ends. The data of each process is diffed with the
+// last data received from the process, on this stream or a previous one, so
+// that the cumulative data is only counted once. The data of the streams
+// that don't identify their process is diffed per stream.
+func (s *Server) Export(stream statspb.Agent_ExportServer) error {
+	defined := make(map[string]stats.View)
+	last := make(map[string]*stats.ViewData)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&statspb.ExportResponse{})
+		}
+		if err != nil {
+			return err
+		}
+		for _, pb := range req.Views {
+			if pb == nil {
+				continue
+			}
+			v, err := s.view(pb)
+			if err != nil {
+				return err
+			}
+			defined[pb.Name] = v
+		}
+		for _, pb := range req.ViewData {
+			if pb == nil || pb.View == nil {
+				return fmt.Errorf("cannot merge view data without view")
+			}
+			s.mu.Lock()
+			mode := s.mode
+			s.mu.Unlock()
+			if err := pb.Upgrade(mode); err != nil {
+				return fmt.Errorf("cannot merge the data of view '%v': %v", pb.View.Name, err)
+			}
+			v, ok := defined[pb.View.Name]
+			if !ok {
+				return fmt.Errorf("cannot merge the data of view '%v' which is not defined on the stream", pb.View.Name)
+			}
+			if v == nil {
+				// the view doesn't have a cumulative window or is a rate
+				// or ratio view.
+				continue
+			}
+			if err := s.merge(req.ProcessId, last, v, pb); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// view returns the view merging the data of the view described by pb,
+// creating it if needed. It returns nil if the view doesn't have a cumulative
+// window or is a rate or ratio view.
+func (s *Server) view(pb *statspb.View) (stats.View, error) {
+	if pb.Window == nil || pb.Window.Type != statspb.Window_Type_CUMULATIVE {
+		return nil, nil
+	}
+	if pb.Aggregation != nil {
+		switch pb.Aggregation.Type {
+		case statspb.Aggregation_Type_RATE, statspb.Aggregation_Type_RATIO:
+			return nil, nil
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sv, ok := s.views[pb.Name]; ok {
+		if !sameKeys(sv.pb.TagKeys, pb.TagKeys) || !proto.Equal(sv.pb.Aggregation, pb.Aggregation) {
+			return nil, fmt.Errorf("cannot merge the data of view '%v' whose tag keys or aggregation differ from the ones of the other processes", pb.Name)
+		}
+		return sv.v, nil
+	}
+
+	// the measure of the view only identifies it: the server doesn't record
+	// measurements.
+	m, err := s.r.GetMeasureByName(pb.Measure)
+	if err != nil {
+		if m, err = s.r.NewMeasureFloat64(pb.Measure, "", stats.UnitDimensionless); err != nil {
+			return nil, err
+		}
+	}
+	var keys []tags.Key
+	for _, name := range pb.TagKeys {
+		k, err := tags.CreateKeyString(name)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if pb.Aggregation == nil {
+		return nil, fmt.Errorf("cannot merge the data of view '%v' without aggregation", pb.Name)
+	}
+	agg, err := pb.Aggregation.ToAggregation()
+	if err != nil {
+		return nil, err
+	}
+	v := stats.NewView(pb.Name, pb.Description, keys, m, agg, stats.NewWindowCumulative())
+	if err := s.r.ForceCollection(v); err != nil {
+		return nil, err
+	}
+	s.views[pb.Name] = &serverView{pb, v}
+	return v, nil
+}
+
+// merge adds the data of pb received since the last data of the view to the
+// data of v. The last data is looked up in the data of the process id if it
+// is set, in last otherwise.
+func (s *Server) merge(id string, last map[string]*stats.ViewData, v stats.View, pb *statspb.ViewData) error {
+	rows, err := pb.ToRows()
+	if err != nil {
+		return err
+	}
+	cur := &stats.ViewData{
+		V:     v,
+		Start: time.Unix(0, pb.StartUnixNanos),
+		End:   time.Unix(0, pb.EndUnixNanos),
+		Reset: pb.Reset_,
+		Rows:  rows,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id != "" {
+		if last = s.processes[id]; last == nil {
+			last = make(map[string]*stats.ViewData)
+			s.processes[id] = last
+		}
+	}
+	prev := last[v.Name()]
+	if prev != nil && (cur.Reset || !prev.Start.Equal(cur.Start)) {
+		// the process started a new collection, e.g. because the view was
+		// replaced, or cleared its data: all its data is new.
+		prev = nil
+	}
+	deltas, err := viewdatadiff.Diff(prev, cur)
+	if err != nil {
+		return err
+	}
+	var added []*stats.Row
+	for _, d := range deltas {
+		if d.Kind == viewdatadiff.Removed {
+			continue
+		}
+		added = append(added, &stats.Row{Tags: d.Tags, AggregationValue: d.Delta})
+	}
+	if err := s.r.AddRows(v, added); err != nil {
+		return err
+	}
+	last[v.Name()] = cur
+	return nil
+}
+
+func sameKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package statsserver
+
+import (
+	"io"
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/stats/statspb"
+	"google.golang.org/grpc"
+)
+
+// fakeStream replays reqs as an Export stream.
+type fakeStream struct {
+	grpc.ServerStream
+	reqs   []*statspb.ExportRequest
+	closed bool
+}
+
+func (s *fakeStream) Recv() (*statspb.ExportRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *fakeStream) SendAndClose(*statspb.ExportResponse) error {
+	s.closed = true
+	return nil
+}
+
+func TestServer(t *testing.T) {
+	r := stats.NewRegistry()
+	s := NewServer(r)
+
+	view := func(name string, w statspb.Window_Type) *statspb.View {
+		return &statspb.View{
+			Name:        name,
+			TagKeys:     []string{"method"},
+			Measure:     "latency",
+			Aggregation: &statspb.Aggregation{Type: statspb.Aggregation_Type_COUNT},
+			Window:      &statspb.Window{Type: w},
+		}
+	}
+	data := func(name string, start int64, counts map[string]int64) *statspb.ViewData {
+		vd := &statspb.ViewData{
+			View:           &statspb.View{Name: name},
+			StartUnixNanos: start,
+		}
+		for method, n := range counts {
+			vd.Rows = append(vd.Rows, &statspb.Row{
+				Tags:  []*statspb.Tag{{Key: "method", Value: []byte(method)}},
+				Value: &statspb.AggregationValue{Count: n},
+			})
+		}
+		return vd
+	}
+	export := func(reqs ...*statspb.ExportRequest) {
+		stream := &fakeStream{reqs: reqs}
+		if err := s.Export(stream); err != nil {
+			t.Fatalf("Export got error '%v', want no error", err)
+		}
+		if !stream.closed {
+			t.Errorf("Export didn't close the stream")
+		}
+	}
+
+	rate := view("rate", statspb.Window_Type_CUMULATIVE)
+	rate.Aggregation.Type = statspb.Aggregation_Type_RATE
+	rateData := data("rate", 1, nil)
+	rateData.Rows = []*statspb.Row{{Value: &statspb.AggregationValue{Type: statspb.Aggregation_Type_RATE, Rate: 2.5}}}
+	ratio := view("ratio", statspb.Window_Type_CUMULATIVE)
+	ratio.Aggregation.Type = statspb.Aggregation_Type_RATIO
+	ratioData := data("ratio", 1, nil)
+	ratioData.Rows = []*statspb.Row{{Value: &statspb.AggregationValue{Type: statspb.Aggregation_Type_RATIO, Ratio: 0.25}}}
+	views := []*statspb.View{view("count", statspb.Window_Type_CUMULATIVE), view("sliding", statspb.Window_Type_SLIDING_TIME), rate, ratio}
+	export(
+		&statspb.ExportRequest{
+			ProcessId: "p1",
+			Views:     views,
+			ViewData:  []*statspb.ViewData{data("count", 1, map[string]int64{"GET": 2}), data("sliding", 1, map[string]int64{"GET": 5}), rateData, ratioData},
+		},
+		&statspb.ExportRequest{
+			ProcessId: "p1",
+			ViewData:  []*statspb.ViewData{data("count", 1, map[string]int64{"GET": 3, "PUT": 1})},
+		},
+	)
+	// p1 reconnects: its cumulative data is only counted once.
+	export(&statspb.ExportRequest{
+		ProcessId: "p1",
+		Views:     views[:1],
+		ViewData:  []*statspb.ViewData{data("count", 1, map[string]int64{"GET": 4, "PUT": 1})},
+	})
+	export(&statspb.ExportRequest{
+		ProcessId: "p2",
+		Views:     views[:1],
+		ViewData:  []*statspb.ViewData{data("count", 2, map[string]int64{"GET": 10})},
+	})
+
+	v, err := r.GetViewByName("count")
+	if err != nil {
+		t.Fatalf("GetViewByName got error '%v', want no error", err)
+	}
+	rows, err := r.RetrieveData(v)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	got := make(map[string]int64)
+	for _, row := range rows {
+		got[string(row.Tags[0].V)] = int64(*row.AggregationValue.(*stats.AggregationCountValue))
+	}
+	if got["GET"] != 14 || got["PUT"] != 1 || len(got) != 2 {
+		t.Errorf("RetrieveData got counts %v, want GET:14 PUT:1", got)
+	}
+	if _, err := r.GetViewByName("sliding"); err == nil {
+		t.Errorf("GetViewByName(sliding) got no error, want the data of the sliding view to be ignored")
+	}
+	if _, err := r.GetViewByName("rate"); err == nil {
+		t.Errorf("GetViewByName(rate) got no error, want the data of the rate view to be ignored")
+	}
+	if _, err := r.GetViewByName("ratio"); err == nil {
+		t.Errorf("GetViewByName(ratio) got no error, want the data of the ratio view to be ignored")
+	}
+
+	// the data of a newer version of the library is only merged in lenient
+	// mode.
+	newer := data("count", 2, map[string]int64{"GET": 11})
+	newer.SchemaVersion = statspb.SchemaVersion + 1
+	newer.Rows[0].StartUnixNanos = 2
+	stream := &fakeStream{reqs: []*statspb.ExportRequest{{ProcessId: "p2", Views: views[:1], ViewData: []*statspb.ViewData{newer}}}}
+	if err := s.Export(stream); err == nil {
+		t.Errorf("Export of view data of schema version %v got no error, want an error", newer.SchemaVersion)
+	}
+	s.SetCompatibilityMode(statspb.CompatibilityLenient)
+	export(&statspb.ExportRequest{ProcessId: "p2", Views: views[:1], ViewData: []*statspb.ViewData{newer}})
+	if rows, err = r.RetrieveData(v); err != nil || len(rows) != 2 {
+		t.Fatalf("RetrieveData got %v, %v, want 2 rows", rows, err)
+	}
+	for _, row := range rows {
+		if string(row.Tags[0].V) == "GET" && *row.AggregationValue.(*stats.AggregationCountValue) != 15 {
+			t.Errorf("RetrieveData got GET count %v, want 15", *row.AggregationValue.(*stats.AggregationCountValue))
+		}
+	}
+
+	// p1 cleared its data, which grew back past the previous data: all of it
+	// is new.
+	reset := data("count", 1, map[string]int64{"GET": 5})
+	reset.Reset_ = true
+	export(&statspb.ExportRequest{ProcessId: "p1", Views: views[:1], ViewData: []*statspb.ViewData{reset}})
+	if rows, err = r.RetrieveData(v); err != nil || len(rows) != 2 {
+		t.Fatalf("RetrieveData got %v, %v, want 2 rows", rows, err)
+	}
+	for _, row := range rows {
+		if string(row.Tags[0].V) == "GET" && *row.AggregationValue.(*stats.AggregationCountValue) != 20 {
+			t.Errorf("RetrieveData after a reset got GET count %v, want 20", *row.AggregationValue.(*stats.AggregationCountValue))
+		}
+	}
+
+	conflicting := view("count", statspb.Window_Type_CUMULATIVE)
+	conflicting.TagKeys = []string{"host"}
+	stream = &fakeStream{reqs: []*statspb.ExportRequest{{Views: []*statspb.View{conflicting}}}}
+	if err := s.Export(stream); err == nil {
+		t.Errorf("Export of a view with other tag keys got no error, want an error")
+	}
+}
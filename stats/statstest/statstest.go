@@ -0,0 +1,205 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package statstest builds the data reported by the stats package, so that
+// the exporters can be tested against realistic ViewData without recording
+// measurements, and compares their output with golden files:
+//
+//	vd := statstest.NewViewData(latencyView).
+//		Row(statstest.Distribution([]float64{10, 100}, 5, 42, 250), statstest.StringTag(method, "GET")).
+//		Build()
+//	statstest.CompareGolden(t, "testdata/latency.golden", encode(vd))
+//
+// The golden files are rewritten with the output of the tests when they are
+// run with the flag -statstest.update.
+package statstest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/resource"
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+var update = flag.Bool("statstest.update", false, "rewrite the golden files compared by statstest")
+
+// DefaultStart and DefaultEnd are the boundaries of the window of the ViewData
+// built by NewViewData unless Window is called.
+var (
+	DefaultStart = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	DefaultEnd   = DefaultStart.Add(time.Minute)
+)
+
+// StringTag returns the tag of the key k with the value v.
+func StringTag(k *tags.KeyString, v string) tags.Tag {
+	return tags.Tag{K: k, V: []byte(v)}
+}
+
+// Tags returns the tags of ts, e.g. built with a tags.TagSetBuilder for the
+// keys of all the types, sorted like the tags of the rows collected by the
+// library.
+func Tags(ts *tags.TagSet) []tags.Tag {
+	var ret []tags.Tag
+	if ts == nil {
+		return ret
+	}
+	ts.Foreach(func(k tags.Key, v []byte) bool {
+		ret = append(ret, tags.Tag{K: k, V: append([]byte(nil), v...)})
+		return true
+	})
+	sortTags(ret)
+	return ret
+}
+
+func sortTags(ts []tags.Tag) {
+	sort.Slice(ts, func(i, j int) bool { return ts[i].K.Name() < ts[j].K.Name() })
+}
+
+// Row returns the row holding av for the tags ts, which are sorted like the
+// tags of the rows collected by the library.
+func Row(av stats.AggregationValue, ts ...tags.Tag) *stats.Row {
+	sorted := append([]tags.Tag(nil), ts...)
+	sortTags(sorted)
+	return &stats.Row{
+		Tags:             sorted,
+		AggregationValue: av,
+	}
+}
+
+// Count returns the value of a count aggregation of n samples.
+func Count(n int64) *stats.AggregationCountValue {
+	return stats.NewAggregationCountValue(n)
+}
+
+// Distribution returns the value of a distribution with bounds aggregating
+// samples, as the library would aggregate them.
+func Distribution(bounds []float64, samples ...float64) *stats.AggregationDistributionValue {
+	counts := make([]int64, len(bounds)+1)
+	min, max := math.MaxFloat64, math.SmallestNonzeroFloat64
+	var mean, sumOfSquaredDev float64
+	for i, x := range samples {
+		counts[sort.Search(len(bounds), func(b int) bool { return x < bounds[b] })]++
+		min, max = math.Min(min, x), math.Max(max, x)
+		// Knuth's online algorithm, like the library.
+		delta := x - mean
+		mean += delta / float64(i+1)
+		sumOfSquaredDev += delta * (x - mean)
+	}
+	return stats.NewAggregationDistributionValue(bounds, counts, int64(len(samples)), min, max, mean, sumOfSquaredDev, 0, 0)
+}
+
+// ViewDataBuilder builds a ViewData. See NewViewData.
+type ViewDataBuilder struct {
+	vd *stats.ViewData
+}
+
+// NewViewData returns a builder of a ViewData of the view v, collected from
+// DefaultStart to DefaultEnd.
+func NewViewData(v stats.View) *ViewDataBuilder {
+	return &ViewDataBuilder{
+		vd: &stats.ViewData{
+			V:     v,
+			Start: DefaultStart,
+			End:   DefaultEnd,
+		},
+	}
+}
+
+// Window sets the boundaries of the window the rows were collected over.
+func (b *ViewDataBuilder) Window(start, end time.Time) *ViewDataBuilder {
+	b.vd.Start, b.vd.End = start, end
+	return b
+}
+
+// Resource sets the resource the data was recorded in.
+func (b *ViewDataBuilder) Resource(res *resource.Resource) *ViewDataBuilder {
+	b.vd.Resource = res
+	return b
+}
+
+// Row adds the row holding av for the tags ts. See Row.
+func (b *ViewDataBuilder) Row(av stats.AggregationValue, ts ...tags.Tag) *ViewDataBuilder {
+	b.vd.Rows = append(b.vd.Rows, Row(av, ts...))
+	return b
+}
+
+// Exemplar adds an exemplar.
+func (b *ViewDataBuilder) Exemplar(e *stats.Exemplar) *ViewDataBuilder {
+	b.vd.Exemplars = append(b.vd.Exemplars, e)
+	return b
+}
+
+// Build returns the ViewData built. The builder must not be used afterwards.
+func (b *ViewDataBuilder) Build() *stats.ViewData {
+	return b.vd
+}
+
+// Format returns a text representation of vds independent of the order of
+// their rows, meant to be compared with golden files.
+func Format(vds ...*stats.ViewData) string {
+	var buf bytes.Buffer
+	for _, vd := range vds {
+		fmt.Fprintf(&buf, "view %v [%v, %v]\n", vd.V.Name(), vd.Start.UTC().Format(time.RFC3339Nano), vd.End.UTC().Format(time.RFC3339Nano))
+		var lines []string
+		for _, r := range vd.Rows {
+			var ts []string
+			for _, t := range r.Tags {
+				ts = append(ts, t.K.Name()+"="+t.K.ValueAsString(t.V))
+			}
+			lines = append(lines, fmt.Sprintf("  {%v} %v\n", strings.Join(ts, " "), r.AggregationValue))
+		}
+		sort.Strings(lines)
+		for _, l := range lines {
+			buf.WriteString(l)
+		}
+	}
+	return buf.String()
+}
+
+// CompareGolden reports an error to t unless got is the content of the golden
+// file at path. The file is rewritten with got instead when the tests are run
+// with the flag -statstest.update.
+func CompareGolden(t testing.TB, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("cannot update golden file %v: %v", path, err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read golden file %v: %v (run the tests with -statstest.update to create it)", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got output differing from golden file %v:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// CompareGoldenViewData is like CompareGolden for the text representation of
+// vds returned by Format.
+func CompareGoldenViewData(t testing.TB, path string, vds ...*stats.ViewData) {
+	t.Helper()
+	CompareGolden(t, path, []byte(Format(vds...)))
+}
@@ -0,0 +1,68 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package statstest
+
+import (
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"golang.org/x/net/context"
+)
+
+func TestDistributionLikeLibrary(t *testing.T) {
+	r := stats.NewRegistry()
+	m, err := r.NewMeasureFloat64("statstest/latency", "latency", "ms")
+	if err != nil {
+		t.Fatalf("NewMeasureFloat64 got error '%v', want no error", err)
+	}
+	k, _ := tags.CreateKeyString("statstest_method")
+	bounds := []float64{10, 100}
+	v := stats.NewView("statstest/latency", "latency", []tags.Key{k}, m, stats.NewAggregationDistribution(bounds), stats.NewWindowCumulative())
+	if err := r.RegisterView(v); err != nil {
+		t.Fatalf("RegisterView got error '%v', want no error", err)
+	}
+	if err := r.ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	samples := []float64{5, 42, 250, 10, 99.5}
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k, "GET").Build())
+	for _, s := range samples {
+		stats.RecordFloat64(ctx, m, s)
+	}
+
+	got, err := r.RetrieveData(v)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	want := NewViewData(v).Row(Distribution(bounds, samples...), StringTag(k, "GET")).Build()
+	if ok, msg := stats.EqualRows(got, want.Rows); !ok {
+		t.Errorf("rows built differ from the rows collected: %v", msg)
+	}
+}
+
+func TestCompareGoldenViewData(t *testing.T) {
+	method, _ := tags.CreateKeyString("statstest_golden_method")
+	code, _ := tags.CreateKeyInt64("statstest_golden_code")
+	m, _ := stats.NewMeasureInt64("statstest/requests", "requests", "1")
+	v := stats.NewView("statstest/requests", "requests", []tags.Key{method, code}, m, stats.NewAggregationCount(), stats.NewWindowCumulative())
+
+	vd := NewViewData(v).
+		Row(Count(3), Tags(tags.NewTagSetBuilder(nil).InsertString(method, "PUT").InsertInt64(code, 500).Build())...).
+		Row(Count(12), StringTag(method, "GET")).
+		Build()
+	CompareGoldenViewData(t, "testdata/requests.golden", vd)
+}
@@ -0,0 +1,79 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// stripedCounter is a counter incremented concurrently with low contention.
+// The increments are spread over up to GOMAXPROCS stripes. The stripes are
+// handed out by a sync.Pool, which keeps them mostly local to each P.
+type stripedCounter struct {
+	mu      sync.Mutex
+	stripes []*counterStripe
+	next    int
+	pool    sync.Pool
+}
+
+// counterStripe is padded to its own cache line to avoid false sharing.
+type counterStripe struct {
+	n int64
+	_ [56]byte
+}
+
+func newStripedCounter() *stripedCounter {
+	c := &stripedCounter{}
+	c.pool.New = c.newStripe
+	return c
+}
+
+// newStripe returns a new stripe until there are GOMAXPROCS of them, and then
+// the existing stripes in turn. The stripes dropped by the pool are never
+// lost since they are also held by c.stripes.
+func (c *stripedCounter) newStripe() interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.stripes) < runtime.GOMAXPROCS(0) {
+		s := &counterStripe{}
+		c.stripes = append(c.stripes, s)
+		return s
+	}
+	s := c.stripes[c.next%len(c.stripes)]
+	c.next++
+	return s
+}
+
+func (c *stripedCounter) add(n int64) {
+	s := c.pool.Get().(*counterStripe)
+	atomic.AddInt64(&s.n, n)
+	c.pool.Put(s)
+}
+
+// swap returns the value of the counter and resets it to zero.
+func (c *stripedCounter) swap() int64 {
+	c.mu.Lock()
+	stripes := c.stripes
+	c.mu.Unlock()
+
+	var sum int64
+	for _, s := range stripes {
+		sum += atomic.SwapInt64(&s.n, 0)
+	}
+	return sum
+}
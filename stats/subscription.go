@@ -15,6 +15,354 @@
 
 package stats
 
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
 type subscription struct {
 	droppedViewData uint64
+
+	// resets is the reset epoch of the view when the last ViewData was sent
+	// to the subscriber. It is zero until the first one is sent, which is
+	// therefore marked as Reset.
+	resets uint64
+
+	// borrowed is true if the ViewData delivered to the subscriber must be
+	// released. See SubscribeToViewBorrowed.
+	borrowed bool
+
+	// policy and timeout define what happens when the channel of the
+	// subscriber is full. See WithBackpressure and WithSendTimeout.
+	policy  BackpressurePolicy
+	timeout time.Duration
+
+	// keys are the tag keys the view is projected onto for the subscriber.
+	// nil means all the tag keys of the view. See WithProjection.
+	keys []tags.Key
+
+	// filters are the tag values the rows delivered to the subscriber must
+	// have. See WithTagEquals.
+	filters []tags.Tag
+
+	// pattern is the pattern of the view names the subscription was made
+	// for by SubscribeMatching. It is empty for the subscriptions to a view.
+	pattern string
+}
+
+// BackpressurePolicy defines what the library does when the channel of a
+// subscriber is full when the data of the view is reported.
+type BackpressurePolicy int
+
+const (
+	// BackpressureDropNewest drops the ViewData being reported. It is the
+	// default policy.
+	BackpressureDropNewest BackpressurePolicy = iota
+	// BackpressureDropOldest drops the oldest ViewData buffered in the
+	// channel to make room for the ViewData being reported, so that the
+	// buffered channel acts as a ring of the most recent ViewData. It
+	// behaves like BackpressureDropNewest for unbuffered channels.
+	BackpressureDropOldest
+	// BackpressureBlock waits until the subscriber receives the ViewData.
+	// The library's goroutine doesn't aggregate any measurement while it
+	// waits, so it should be combined with WithSendTimeout unless the
+	// subscriber is guaranteed to keep up. The subscriber can still call the
+	// functions of the registry, e.g. to register views, before receiving
+	// the ViewData.
+	BackpressureBlock
+)
+
+// SubscriptionOption customizes a subscription to a view.
+type SubscriptionOption func(s *subscription)
+
+// WithBackpressure sets the policy applied when the channel of the
+// subscriber is full. See BackpressurePolicy.
+func WithBackpressure(p BackpressurePolicy) SubscriptionOption {
+	return func(s *subscription) {
+		s.policy = p
+	}
+}
+
+// WithSendTimeout makes the library wait up to d for room in the channel of
+// the subscriber before applying the backpressure policy. With
+// BackpressureBlock, the ViewData is dropped once d elapsed.
+func WithSendTimeout(d time.Duration) SubscriptionOption {
+	return func(s *subscription) {
+		s.timeout = d
+	}
+}
+
+// WithProjection projects the view onto a subset of its tag keys for the
+// subscriber: the rows of the ViewData it receives only hold the tags of keys,
+// and the rows of the view having the same values for keys are aggregated
+// into one. It lets an exporter that cannot handle the cardinality of a view
+// receive e.g. "method only" data while another receives "method+user". All
+// keys must be tag keys of the view.
+func WithProjection(keys ...tags.Key) SubscriptionOption {
+	return func(s *subscription) {
+		s.keys = append([]tags.Key{}, keys...)
+	}
+}
+
+// WithTagEquals only delivers to the subscriber the rows whose tag k has the
+// value value. k must be a tag key of the view. Several filters can be set,
+// the rows must match all of them. With SubscribeMatching, the views without
+// the tag key k are not subscribed to.
+func WithTagEquals(k *tags.KeyString, value string) SubscriptionOption {
+	return func(s *subscription) {
+		s.filters = append(s.filters, tags.Tag{K: k, V: []byte(value)})
+	}
+}
+
+// checkKeys returns an error if the keys of the projection or of the filters
+// of s aren't all tag keys of v.
+func (s *subscription) checkKeys(v View) error {
+	for _, k := range s.keys {
+		if !hasTagKey(v, k) {
+			return fmt.Errorf("cannot project view '%v' onto tag key '%v' which is not one of its tag keys", v.Name(), k.Name())
+		}
+	}
+	for _, f := range s.filters {
+		if !hasTagKey(v, f.K) {
+			return fmt.Errorf("cannot filter the rows of view '%v' on tag key '%v' which is not one of its tag keys", v.Name(), f.K.Name())
+		}
+	}
+	return nil
+}
+
+func hasTagKey(v View, k tags.Key) bool {
+	for _, vk := range v.TagKeys() {
+		if vk == k {
+			return true
+		}
+	}
+	return false
+}
+
+// transform returns the data of vd the subscriber receives: the rows matching
+// its filters, projected onto its keys. It returns vd itself if s has neither
+// filters nor projection.
+func (s *subscription) transform(v View, vd *ViewData) *ViewData {
+	if s.filters != nil {
+		vd = s.filter(vd)
+	}
+	if s.keys != nil {
+		vd = s.project(v, vd)
+	}
+	return vd
+}
+
+// filter returns a copy of vd with only the rows and the exemplars matching
+// the filters of s.
+func (s *subscription) filter(vd *ViewData) *ViewData {
+	filtered := &ViewData{
+		V:        vd.V,
+		Start:    vd.Start,
+		End:      vd.End,
+		Reset:    vd.Reset,
+		Resource: vd.Resource,
+	}
+	for _, r := range vd.Rows {
+		if s.matchTags(r.Tags) {
+			filtered.Rows = append(filtered.Rows, r)
+		}
+	}
+	for _, e := range vd.Exemplars {
+		if s.matchTags(e.Tags) {
+			filtered.Exemplars = append(filtered.Exemplars, e)
+		}
+	}
+	return filtered
+}
+
+// matchTags returns whether ts has the values of all the filters of s.
+func (s *subscription) matchTags(ts []tags.Tag) bool {
+	for _, f := range s.filters {
+		found := false
+		for _, t := range ts {
+			if t.K == f.K {
+				found = bytes.Equal(t.V, f.V)
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// project returns a copy of vd whose rows and exemplars only hold the tags of
+// the keys of the projection of s, the rows having the same tags being
+// aggregated into one.
+func (s *subscription) project(v View, vd *ViewData) *ViewData {
+	newValue := v.Aggregation().aggregationValueConstructor()
+	projected := &ViewData{
+		V:        vd.V,
+		Start:    vd.Start,
+		End:      vd.End,
+		Reset:    vd.Reset,
+		Resource: vd.Resource,
+	}
+	bySig := make(map[string]*Row)
+	for _, r := range vd.Rows {
+		ts := s.projectTags(r.Tags)
+		sig := tags.SliceToValuesString(ts, s.keys)
+		pr, ok := bySig[sig]
+		if !ok {
+			pr = &Row{
+				Tags:             ts,
+				AggregationValue: newValue(),
+			}
+			bySig[sig] = pr
+			projected.Rows = append(projected.Rows, pr)
+		}
+		pr.AggregationValue.addToIt(r.AggregationValue)
+	}
+	for _, e := range vd.Exemplars {
+		pe := *e
+		pe.Tags = s.projectTags(e.Tags)
+		projected.Exemplars = append(projected.Exemplars, &pe)
+	}
+	return projected
+}
+
+// projectTags returns the tags of ts whose keys are keys of the projection of
+// s, in the order of ts.
+func (s *subscription) projectTags(ts []tags.Tag) []tags.Tag {
+	var projected []tags.Tag
+	for _, t := range ts {
+		for _, k := range s.keys {
+			if t.K == k {
+				projected = append(projected, t)
+				break
+			}
+		}
+	}
+	return projected
+}
+
+// deliver sends vd to c according to the backpressure policy of the
+// subscription, and returns the number of ViewData dropped and whether vd was
+// sent. The dropped ViewData are released. While w waits for room in c, it
+// handles the control commands, e.g. those sent by the subscriber itself. If c
+// is closed, vd is dropped and the panic is reported to the error handler of
+// w.
+func (s *subscription) deliver(w *worker, c chan *ViewData, vd *ViewData) (dropped int, sent bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			w.handlePanic(p, "the delivery of view '%v' to a subscriber", vd.V.Name())
+			vd.Release()
+			dropped, sent = 1, false
+		}
+	}()
+	select {
+	case c <- vd:
+		return 0, true
+	default:
+	}
+
+	if s.policy == BackpressureBlock && s.timeout <= 0 {
+		w.sendViewData(c, vd, nil)
+		return 0, true
+	}
+	if s.timeout > 0 {
+		t := time.NewTimer(s.timeout)
+		defer t.Stop()
+		if w.sendViewData(c, vd, t.C) {
+			return 0, true
+		}
+	}
+
+	if s.policy == BackpressureDropOldest {
+		select {
+		case old := <-c:
+			if old.Reset {
+				// vd replaces old, so it carries its reset marker, unless
+				// old was reported for another view.
+				if old.V.Name() == vd.V.Name() {
+					vd = withReset(vd, true)
+				} else {
+					w.restoreReset(c, old)
+				}
+			}
+			old.Release()
+			dropped++
+		default:
+		}
+	}
+	select {
+	case c <- vd:
+		sent = true
+	default:
+		vd.Release()
+		dropped++
+	}
+	return dropped, sent
+}
+
+// restoreReset marks the next ViewData of the view of vd sent to c as Reset.
+// It is called when vd, marked as Reset, is dropped from c.
+func (w *worker) restoreReset(c chan *ViewData, vd *ViewData) {
+	v := vd.V
+	if a, ok := v.(*aliasView); ok {
+		v = a.View
+	}
+	if s, ok := v.subscriptions()[c]; ok {
+		s.resets = 0
+		v.addSubscription(c, s)
+	}
+}
+
+// matchingSubscription subscribes a channel to all the views whose name
+// matches a pattern, including the views registered later. See
+// SubscribeMatching.
+type matchingSubscription struct {
+	pattern string
+	c       chan *ViewData
+	opts    []SubscriptionOption
+}
+
+// validatePattern returns an error if pattern isn't a valid pattern of view
+// names.
+func validatePattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("pattern of view names is empty")
+	}
+	if strings.ContainsAny(pattern, "[]\\") {
+		return fmt.Errorf("pattern of view names %q is invalid: only the wildcards '*' and '?' are supported", pattern)
+	}
+	return nil
+}
+
+// matchPattern returns whether name matches pattern, where '*' matches any
+// sequence of characters, including '/', and '?' matches any single
+// character.
+func matchPattern(pattern, name string) bool {
+	// star and next are the positions to backtrack to after the last '*'.
+	star, next := -1, 0
+	p, n := 0, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == name[n]):
+			p++
+			n++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, n
+			p++
+		case star >= 0:
+			next++
+			p, n = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
 }
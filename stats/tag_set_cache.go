@@ -0,0 +1,127 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"container/list"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+// defaultTagSetCacheSize is the number of distinct TagSets interned by each
+// worker.
+const defaultTagSetCacheSize = 1024
+
+// tagSetCache interns the TagSets recorded by the worker. The TagSets holding
+// the same tags map to a canonical TagSet, along with the signatures of the
+// canonical TagSet for the views, so that in the steady state the signatures
+// aren't computed for each measurement. The least recently used TagSets are
+// evicted once the cache is full. When global tags are set, the canonical
+// TagSet of an entry holds the global tags merged with the recorded ones. It
+// must only be used by the worker goroutine.
+type tagSetCache struct {
+	size   int
+	ll     *list.List
+	global *tags.TagSet
+
+	// bySig indexes the entries by the signature of their TagSet, and byPtr
+	// by their canonical TagSet, which avoids computing the signature when
+	// the same TagSet is recorded repeatedly.
+	bySig map[string]*list.Element
+	byPtr map[*tags.TagSet]*list.Element
+
+	// buf is reused to compute the signatures.
+	buf []byte
+}
+
+// tagSetCacheEntry is the entry of the recorded TagSet key. sig is the
+// signature of key, and ts the canonical TagSet recorded for it.
+type tagSetCacheEntry struct {
+	sig  string
+	key  *tags.TagSet
+	ts   *tags.TagSet
+	sigs map[View]string
+}
+
+func newTagSetCache(size int) *tagSetCache {
+	return &tagSetCache{
+		size:  size,
+		ll:    list.New(),
+		bySig: make(map[string]*list.Element),
+		byPtr: make(map[*tags.TagSet]*list.Element),
+	}
+}
+
+// lookup returns the entry of the TagSet holding the same tags as ts. ts,
+// merged with the global tags if any, becomes the canonical TagSet of a new
+// entry if there is none.
+func (c *tagSetCache) lookup(ts *tags.TagSet) *tagSetCacheEntry {
+	if e, ok := c.byPtr[ts]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*tagSetCacheEntry)
+	}
+	c.buf = tags.AppendSignature(c.buf[:0], ts)
+	if e, ok := c.bySig[string(c.buf)]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*tagSetCacheEntry)
+	}
+
+	entry := &tagSetCacheEntry{
+		sig:  string(c.buf),
+		key:  ts,
+		ts:   c.withGlobal(ts),
+		sigs: make(map[View]string),
+	}
+	e := c.ll.PushFront(entry)
+	c.bySig[entry.sig] = e
+	c.byPtr[ts] = e
+	if c.ll.Len() > c.size {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		old := last.Value.(*tagSetCacheEntry)
+		delete(c.bySig, old.sig)
+		delete(c.byPtr, old.key)
+	}
+	return entry
+}
+
+// withGlobal returns ts merged with the global tags of the cache. The tags of
+// ts take precedence.
+func (c *tagSetCache) withGlobal(ts *tags.TagSet) *tags.TagSet {
+	if c.global == nil {
+		return ts
+	}
+	return tags.Merge(c.global, ts)
+}
+
+// setGlobal sets the tags merged into the recorded TagSets. The entries are
+// evicted since their canonical TagSets hold the previous global tags.
+func (c *tagSetCache) setGlobal(global *tags.TagSet) {
+	if global != nil && global.Len() == 0 {
+		global = nil
+	}
+	c.global = global
+	c.ll.Init()
+	c.bySig = make(map[string]*list.Element)
+	c.byPtr = make(map[*tags.TagSet]*list.Element)
+}
+
+// forgetView deletes the signatures cached for v, which was unregistered.
+func (c *tagSetCache) forgetView(v View) {
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		delete(e.Value.(*tagSetCacheEntry).sigs, v)
+	}
+}
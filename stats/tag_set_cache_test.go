@@ -0,0 +1,51 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+func Test_TagSetCache(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	k2, _ := tags.CreateKeyString("k2")
+	ts1 := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").InsertString(k2, "v2").Build()
+	ts2 := tags.NewTagSetBuilder(nil).InsertString(k2, "v2").InsertString(k1, "v1").Build()
+	ts3 := tags.NewTagSetBuilder(nil).InsertString(k1, "v3").Build()
+	ts4 := tags.NewTagSetBuilder(nil).InsertString(k1, "v4").Build()
+
+	c := newTagSetCache(2)
+	e1 := c.lookup(ts1)
+	if e := c.lookup(ts2); e != e1 || e.ts != ts1 {
+		t.Errorf("lookup of a TagSet holding the same tags got a different entry, want the entry of the canonical TagSet")
+	}
+	e3 := c.lookup(ts3)
+	if e3 == e1 {
+		t.Errorf("lookup of a TagSet holding different tags got the entry of another TagSet")
+	}
+
+	// ts1 was used more recently than ts3, so ts3 is evicted.
+	c.lookup(ts1)
+	c.lookup(ts4)
+	if e := c.lookup(ts1); e != e1 {
+		t.Errorf("lookup got a new entry for the most recently used TagSet, want it kept in the cache")
+	}
+	if e := c.lookup(ts3); e == e3 {
+		t.Errorf("lookup got the entry of the least recently used TagSet, want it evicted")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import "time"
+
+// TestEnvironment is an isolated registry driven by a ManualClock, for tests.
+// Unlike RestartWorker, it doesn't touch the default registry, so the tests
+// using it can run in parallel. The data is only reported when the tests
+// advance the clock past the reporting period, or call Flush.
+//
+// The measures created with the methods of the embedded Registry are recorded
+// against the environment by the Record functions of the package.
+type TestEnvironment struct {
+	*Registry
+	Clock *ManualClock
+}
+
+// testEnvironmentStart is the initial time of the clock of the
+// TestEnvironments, so that the tests are reproducible.
+var testEnvironmentStart = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// NewTestEnvironment returns a new TestEnvironment whose clock is set to
+// 2017-01-01T00:00:00Z. It must be closed once the test completes.
+func NewTestEnvironment() *TestEnvironment {
+	e := &TestEnvironment{
+		Registry: NewRegistry(),
+		Clock:    NewManualClock(testEnvironmentStart),
+	}
+	e.SetClock(e.Clock)
+	return e
+}
+
+// Advance advances the clock of the environment by d. If the reporting period
+// elapses, Advance returns once the data is delivered to the subscribers.
+func (e *TestEnvironment) Advance(d time.Duration) {
+	e.Clock.Advance(d)
+	req := &syncReq{
+		c: make(chan bool),
+	}
+	e.w.ctl <- req
+	<-req.c
+}
+
+// Close stops the goroutine of the environment. The environment and its
+// measures must not be used afterwards.
+func (e *TestEnvironment) Close() {
+	e.w.stop()
+}
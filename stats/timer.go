@@ -0,0 +1,82 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Timer measures the time elapsed between its creation by StartTimer and the
+// call to its Stop method.
+type Timer struct {
+	ctx   context.Context
+	m     Measure
+	start time.Time
+}
+
+// StartTimer starts a Timer for the measure m. The measure must be a
+// *MeasureDuration, or a *MeasureFloat64 or a *MeasureInt64 with a unit that
+// is one of "ns", "us", "ms", "s", "min" or "h". The elapsed time is recorded
+// by Stop, converted to the unit of the measure, with the tags of ctx.
+func StartTimer(ctx context.Context, m Measure) *Timer {
+	return &Timer{
+		ctx:   ctx,
+		m:     m,
+		start: registryOf(m).w.now(),
+	}
+}
+
+// Stop records the time elapsed since the Timer was started and returns it.
+// Nothing is recorded if the unit or the type of the measure of the Timer are
+// not supported.
+func (t *Timer) Stop() time.Duration {
+	d := registryOf(t.m).w.now().Sub(t.start)
+	switch m := t.m.(type) {
+	case *MeasureDuration:
+		RecordDuration(t.ctx, m, d)
+	case *MeasureFloat64:
+		if v, ok := durationToUnit(d, m.unit); ok {
+			RecordFloat64(t.ctx, m, v)
+		}
+	case *MeasureInt64:
+		if v, ok := durationToUnit(d, m.unit); ok {
+			RecordInt64(t.ctx, m, int64(v+0.5))
+		}
+	}
+	return d
+}
+
+// durationUnits maps the supported time units to their duration.
+var durationUnits = map[Unit]time.Duration{
+	"ns":  time.Nanosecond,
+	"us":  time.Microsecond,
+	"ms":  time.Millisecond,
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+}
+
+// durationToUnit converts d to a number of unit. It returns false if unit is
+// not a supported time unit.
+func durationToUnit(d time.Duration, unit Unit) (float64, bool) {
+	u, ok := durationUnits[unit]
+	if !ok {
+		return 0, false
+	}
+	return float64(d) / float64(u), true
+}
@@ -0,0 +1,213 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import "strings"
+
+// Unit is the unit of the values of a measure. It is written with the case
+// sensitive syntax of the Unified Code for Units of Measure (UCUM), restricted
+// to the subset commonly used by metrics:
+//
+//   - the dimensionless unit "1" and the percentage "%",
+//   - the time units "s", "min", "h" and "d", with the prefixes "n", "u" and
+//     "m" for the second,
+//   - the information units "By" and "bit", with the decimal prefixes "k",
+//     "M", "G" and "T" and the binary prefixes "Ki", "Mi", "Gi" and "Ti",
+//   - annotations in curly braces, e.g. "{requests}" or "By{compressed}",
+//     which don't change the meaning of the unit,
+//   - the quotient of two such units, e.g. "By/s" or "{requests}/min".
+//
+// Units are validated when the measures are created; ParseUnit validates the
+// units coming from configuration or from other processes.
+type Unit string
+
+// The units used by the standard measures.
+const (
+	UnitDimensionless Unit = "1"
+	UnitPercent       Unit = "%"
+	UnitBytes         Unit = "By"
+	UnitKilobytes     Unit = "kBy"
+	UnitMegabytes     Unit = "MBy"
+	UnitNanoseconds   Unit = "ns"
+	UnitMicroseconds  Unit = "us"
+	UnitMilliseconds  Unit = "ms"
+	UnitSeconds       Unit = "s"
+)
+
+// The dimensions of the units.
+const (
+	dimTime = iota
+	dimInformation
+	numDims
+)
+
+// unitAtom is a unit symbol without prefix.
+type unitAtom struct {
+	dim   int // -1 for the dimensionless atoms
+	scale float64
+	// prefixes are the prefixes allowed for the atom.
+	prefixes map[string]float64
+}
+
+var (
+	timePrefixes = map[string]float64{"n": 1e-9, "u": 1e-6, "m": 1e-3}
+	infoPrefixes = map[string]float64{
+		"k": 1e3, "M": 1e6, "G": 1e9, "T": 1e12,
+		"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40,
+	}
+
+	unitAtoms = map[string]unitAtom{
+		"1":   {dim: -1, scale: 1},
+		"%":   {dim: -1, scale: 0.01},
+		"s":   {dim: dimTime, scale: 1, prefixes: timePrefixes},
+		"min": {dim: dimTime, scale: 60},
+		"h":   {dim: dimTime, scale: 3600},
+		"d":   {dim: dimTime, scale: 86400},
+		"By":  {dim: dimInformation, scale: 1, prefixes: infoPrefixes},
+		"bit": {dim: dimInformation, scale: 0.125, prefixes: infoPrefixes},
+	}
+)
+
+// unitValue is the meaning of a unit: a scale relative to the base units of
+// its dimensions, the second and the byte.
+type unitValue struct {
+	scale float64
+	dims  [numDims]int
+}
+
+// ParseUnit validates s and returns it as a Unit. It returns an error of kind
+// ErrInvalidUnit if s isn't in the supported subset of UCUM.
+func ParseUnit(s string) (Unit, error) {
+	if _, err := Unit(s).value(); err != nil {
+		return "", err
+	}
+	return Unit(s), nil
+}
+
+// String returns the UCUM representation of u.
+func (u Unit) String() string {
+	return string(u)
+}
+
+// Compatible returns whether u and v measure the same dimensions, e.g. "ms"
+// and "h", "By/s" and "kBy/s", so that values of one can be converted to the
+// other. It returns false if either unit is invalid.
+func (u Unit) Compatible(v Unit) bool {
+	uv, err := u.value()
+	if err != nil {
+		return false
+	}
+	vv, err := v.value()
+	if err != nil {
+		return false
+	}
+	return uv.dims == vv.dims
+}
+
+// Convert converts x from the unit u to the unit to, e.g. 1500 "ms" to 1.5
+// "s". It returns an error of kind ErrInvalidUnit if either unit is invalid,
+// and an error of kind ErrIncompatibleUnits if they don't measure the same
+// dimensions.
+func (u Unit) Convert(x float64, to Unit) (float64, error) {
+	from, err := u.value()
+	if err != nil {
+		return 0, err
+	}
+	tv, err := to.value()
+	if err != nil {
+		return 0, err
+	}
+	if from.dims != tv.dims {
+		return 0, newError(ErrIncompatibleUnits, "cannot convert values from unit %q to unit %q", u, to)
+	}
+	if from.scale == tv.scale {
+		return x, nil
+	}
+	return x * from.scale / tv.scale, nil
+}
+
+// value parses u.
+func (u Unit) value() (unitValue, error) {
+	s := string(u)
+	num, den := s, ""
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		num, den = s[:i], s[i+1:]
+	}
+	nv, ok := parseUnitTerm(num)
+	if !ok {
+		return unitValue{}, newError(ErrInvalidUnit, "unit %q is invalid", s)
+	}
+	if den == "" && len(num) < len(s) {
+		return unitValue{}, newError(ErrInvalidUnit, "unit %q is invalid", s)
+	}
+	if den != "" {
+		dv, ok := parseUnitTerm(den)
+		if !ok {
+			return unitValue{}, newError(ErrInvalidUnit, "unit %q is invalid", s)
+		}
+		nv.scale /= dv.scale
+		for i := range nv.dims {
+			nv.dims[i] -= dv.dims[i]
+		}
+	}
+	return nv, nil
+}
+
+// parseUnitTerm parses a unit without quotient: an atom with an optional
+// prefix, followed by an optional annotation, or an annotation alone.
+func parseUnitTerm(s string) (unitValue, bool) {
+	v := unitValue{scale: 1}
+	if i := strings.IndexByte(s, '{'); i >= 0 {
+		if !validAnnotation(s[i:]) {
+			return v, false
+		}
+		if s = s[:i]; s == "" {
+			return v, true
+		}
+	}
+	if a, ok := unitAtoms[s]; ok {
+		if a.dim >= 0 {
+			v.dims[a.dim] = 1
+		}
+		v.scale = a.scale
+		return v, true
+	}
+	for name, a := range unitAtoms {
+		if !strings.HasSuffix(s, name) {
+			continue
+		}
+		if p, ok := a.prefixes[s[:len(s)-len(name)]]; ok {
+			v.dims[a.dim] = 1
+			v.scale = a.scale * p
+			return v, true
+		}
+	}
+	return v, false
+}
+
+// validAnnotation returns whether s is a single annotation in curly braces
+// made of printable ASCII characters.
+func validAnnotation(s string) bool {
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return false
+	}
+	for i := 1; i < len(s)-1; i++ {
+		if c := s[i]; c < 0x21 || c > 0x7e || c == '{' || c == '}' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import "testing"
+
+func Test_Unit_Parse(t *testing.T) {
+	valid := []string{"1", "%", "ns", "us", "ms", "s", "min", "h", "d", "By", "kBy", "MiBy", "Gbit", "{requests}", "By{compressed}", "By/s", "{requests}/min", "1/s", "ms/{request}"}
+	for _, s := range valid {
+		if u, err := ParseUnit(s); err != nil || u != Unit(s) {
+			t.Errorf("ParseUnit(%q) got %q, %v, want no error", s, u, err)
+		}
+	}
+	invalid := []string{"", "unit", "ks", "mmin", "KBy", "By/", "/s", "By/s/s", "{requests", "{re quests}", "{a}{b}", "By {x}"}
+	for _, s := range invalid {
+		if _, err := ParseUnit(s); err == nil {
+			t.Errorf("ParseUnit(%q) got no error, want error", s)
+		}
+	}
+}
+
+func Test_Unit_Convert(t *testing.T) {
+	type testCase struct {
+		from, to Unit
+		x, want  float64
+	}
+	tcs := []testCase{
+		{UnitMilliseconds, UnitSeconds, 1500, 1.5},
+		{"h", "min", 2, 120},
+		{"us", "ns", 3, 3000},
+		{"KiBy", UnitBytes, 2, 2048},
+		{"bit", UnitBytes, 16, 2},
+		{"MBy/s", "kBy/s", 1, 1000},
+		{"{requests}/min", "1/s", 120, 2},
+		{UnitPercent, UnitDimensionless, 50, 0.5},
+		{"By{compressed}", UnitBytes, 10, 10},
+	}
+	for _, tc := range tcs {
+		got, err := tc.from.Convert(tc.x, tc.to)
+		if err != nil {
+			t.Errorf("%q.Convert(%v, %q) got error %v, want no error", tc.from, tc.x, tc.to, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q.Convert(%v, %q) got %v, want %v", tc.from, tc.x, tc.to, got, tc.want)
+		}
+		if !tc.from.Compatible(tc.to) {
+			t.Errorf("%q.Compatible(%q) got false, want true", tc.from, tc.to)
+		}
+	}
+
+	for _, pair := range [][2]Unit{{UnitBytes, UnitSeconds}, {"By/s", UnitBytes}, {UnitDimensionless, UnitMilliseconds}, {"unit", UnitDimensionless}} {
+		if pair[0].Compatible(pair[1]) {
+			t.Errorf("%q.Compatible(%q) got true, want false", pair[0], pair[1])
+		}
+		if _, err := pair[0].Convert(1, pair[1]); err == nil {
+			t.Errorf("%q.Convert(1, %q) got no error, want error", pair[0], pair[1])
+		}
+	}
+}
@@ -19,36 +19,87 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"sort"
 	"time"
 
+	"github.com/census-instrumentation/opencensus-go/resource"
 	"github.com/census-instrumentation/opencensus-go/tags"
+	"github.com/census-instrumentation/opencensus-go/trace"
 )
 
 // View is the generic interface defining the various type of views.
 type View interface {
 	Name() string        // Name returns the name of a View.
 	Description() string // Description returns the description of a View.
+	TagKeys() []tags.Key
 	Window() Window
+	Windows() []Window
 	Aggregation() Aggregation
 	Measure() Measure
-
-	addSubscription(c chan *ViewData)
+	// Unit returns the unit of the aggregated values. It is the unit of the
+	// measure, except for a MeasureDuration whose samples are converted to
+	// the unit set with WithDurationUnit.
+	Unit() Unit
+
+	// WithWindow returns a new view like this one, but whose primary window
+	// is w.
+	WithWindow(w Window) View
+	// WithName returns a new view like this one, but named name.
+	WithName(name string) View
+	// Aliases returns the alias names of the view. See WithAliases.
+	Aliases() []string
+
+	// SnapshotState serializes the data collected for the cumulative windows
+	// of the view.
+	SnapshotState() ([]byte, error)
+	// RestoreState adds the data serialized by SnapshotState to the data
+	// collected for the cumulative windows of the view.
+	RestoreState(b []byte) error
+
+	addSubscription(c chan *ViewData, s subscription)
 	deleteSubscription(c chan *ViewData)
 	subscriptionExists(c chan *ViewData) bool
 	subscriptionsCount() int
 	subscriptions() map[chan *ViewData]subscription
 
 	startForcedCollection()
+	startForcedCollectionUntil(t time.Time)
 	stopForcedCollection()
+	forcedCollection() bool
+	forcedCollectionExpiry() time.Time
 
 	isCollecting() bool
+	isResetOnCollect() bool
 
 	clearRows()
+	clearIntervalRows(now time.Time)
+	startCollection(now time.Time)
 
 	collector() *collector
+	rowsCount() int
 	collectedRows(now time.Time) []*Row
+	appendCollectedRows(rows []*Row, now time.Time) []*Row
+	collectedRowsFiltered(pred func([]tags.Tag) bool, now time.Time) []*Row
+	collectedRowsForWindow(w Window, now time.Time) ([]*Row, error)
 
 	addSample(ts *tags.TagSet, val interface{}, now time.Time)
+	addSampleWithAttachments(ts *tags.TagSet, val interface{}, attachments map[string]string, now time.Time)
+	addSampleWithCache(ts *tags.TagSet, sigs map[View]string, val interface{}, attachments map[string]string, span trace.SpanContext, now time.Time)
+	addCountWithCache(ts *tags.TagSet, sigs map[View]string, n int64, now time.Time)
+	isSampled() bool
+	collectedExemplars() []*Exemplar
+
+	keepRecentSamples(n int)
+	recentSamples() []*Sample
+
+	addWatcher(wr *Watcher)
+	removeWatcher(wr *Watcher) bool
+	watchers() []*Watcher
+
+	keepHistory(n int)
+	keepsHistory() bool
+	addHistory(vd *ViewData)
+	retrieveHistory(n int) []*ViewData
 }
 
 // view is the data structure that holds the info describing the view as well
@@ -75,31 +126,115 @@ type view struct {
 	// client is subscribed to it. This is necessary for supporting a pull
 	// model.
 	isForcedCollection bool
+	// forcedUntil is the time the forced collection expires. It is zero if
+	// the forced collection doesn't expire.
+	forcedUntil time.Time
 
 	c *collector
+
+	// extra are the collectors for the additional windows of a multi
+	// resolution view. They share the record path with c.
+	extra []*collector
+
+	// recent holds the last raw samples recorded for this view. It is nil
+	// unless KeepRecentSamples was called for this view.
+	recent *samplesRing
+
+	// history holds the last ViewData collected for this view. It is nil
+	// unless KeepHistory was called for this view.
+	history *viewDataRing
+	// historyResets is the reset epoch of the last ViewData added to
+	// history.
+	historyResets uint64
+
+	// watches are the watchers evaluated on the rows of this view at each
+	// collection. See Watch.
+	watches []*Watcher
+
+	// extractors compute at record time the values of some of the tagKeys
+	// from the TagSet of the measurement.
+	extractors []*tagExtractor
+
+	// sampler selects the samples aggregated by the view. It is nil unless
+	// WithSampleRate was used.
+	sampler *sampler
+
+	// resetOnCollect indicates that the data collected for the primary window
+	// is cleared each time it is collected. See WithResetOnCollect.
+	resetOnCollect bool
+
+	// outOfRange holds the underflow and overflow modes set with
+	// WithOutOfRange, if any.
+	outOfRange *[2]OutOfRange
+
+	// sortRows indicates that the collected rows are sorted with SortRows.
+	sortRows bool
+
+	// durationUnit is the unit to which the time.Duration samples are
+	// converted before being aggregated.
+	durationUnit time.Duration
+
+	// spanExemplars indicates that the samples recorded in sampled spans are
+	// reported as exemplars. See WithSpanExemplars.
+	spanExemplars bool
+
+	// aliases are the other names of the view. See WithAliases.
+	aliases []string
+}
+
+// NewView creates a new View. Its behavior can be customized with opts. It is
+// equivalent to calling NewViewWithOptions with the options WithDescription,
+// WithTagKeys, WithMeasure, WithAggregation and WithWindow followed by opts.
+func NewView(name, description string, keys []tags.Key, measure Measure, agg Aggregation, wnd Window, opts ...ViewOption) View {
+	return NewViewWithOptions(name, append([]ViewOption{
+		WithDescription(description),
+		WithTagKeys(keys...),
+		WithMeasure(measure),
+		WithAggregation(agg),
+		WithWindow(wnd),
+	}, opts...)...)
 }
 
-// NewView creates a new View.
-func NewView(name, description string, keys []tags.Key, measure Measure, agg Aggregation, wnd Window) View {
-	var keysCopy []tags.Key
-	for _, k := range keys {
-		keysCopy = append(keysCopy, k)
+// NewViewWithOptions creates a new View named name and defined by opts. A
+// measure must be set with WithMeasure for the view to be registered. The
+// view aggregates its samples with an AggregationCount over a
+// WindowCumulative unless WithAggregation and WithWindow are used. The
+// options are applied in order by the calling goroutine, before the view is
+// shared with the library.
+func NewViewWithOptions(name string, opts ...ViewOption) View {
+	v := &view{
+		name:         name,
+		start:        time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+		ss:           make(map[chan *ViewData]subscription),
+		c:            newCollector(NewAggregationCount(), NewWindowCumulative()),
+		durationUnit: time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	if v.outOfRange != nil {
+		v.c.a = withOutOfRange(v.c.a, v.outOfRange[0], v.outOfRange[1])
 	}
 
-	return &view{
-		name,
-		description,
-		keysCopy,
-		measure,
-		time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
-		make(map[chan *ViewData]subscription),
-		false,
-		&collector{
-			make(map[string]aggregator),
-			agg,
-			wnd,
-		},
+	// the additional windows aggregate their samples like the primary window
+	// and share its cardinality limit regardless of the order of the options.
+	for _, c := range v.extra {
+		c.a = v.c.a
+		c.maxRows = v.c.maxRows
+		c.scale = v.c.scale
 	}
+	return v
+}
+
+// NewMultiWindowView creates a new View aggregating the same samples over
+// several windows simultaneously (e.g. 1m sliding, 1h sliding, cumulative).
+// The first window is the primary window of the view: it is the one returned
+// by Window(), reported to the subscribers and retrieved by RetrieveData. The
+// data collected for the other windows is retrieved by RetrieveDataForWindow.
+// The windows of a view are expected to be distinct. It is equivalent to
+// calling NewView with the option WithAdditionalWindows(extraWnds...).
+func NewMultiWindowView(name, description string, keys []tags.Key, measure Measure, agg Aggregation, wnd Window, extraWnds ...Window) View {
+	return NewView(name, description, keys, measure, agg, wnd, WithAdditionalWindows(extraWnds...))
 }
 
 // Name returns the name of view.
@@ -112,8 +247,78 @@ func (v *view) Description() string {
 	return v.description
 }
 
-func (v *view) addSubscription(c chan *ViewData) {
-	v.ss[c] = subscription{}
+// TagKeys returns a copy of the keys the data of view is aggregated on.
+func (v *view) TagKeys() []tags.Key {
+	var keys []tags.Key
+	for _, k := range v.tagKeys {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// WithWindow returns a new view with the same measure, keys, aggregation and
+// options as v, but whose primary window is w. The additional windows of v are
+// kept. The new view has the name of v, so WithName must be used too for both
+// views to be registered.
+func (v *view) WithWindow(w Window) View {
+	nv := v.clone()
+	nv.c.w = w
+	return nv
+}
+
+// WithName returns a new view with the same measure, keys, aggregation,
+// windows and options as v, but named name.
+func (v *view) WithName(name string) View {
+	nv := v.clone()
+	nv.name = name
+	return nv
+}
+
+// clone returns a new view defined like v. The data collected, the
+// subscriptions and the collection state of v aren't copied.
+func (v *view) clone() *view {
+	nv := &view{
+		name:           v.name,
+		description:    v.description,
+		tagKeys:        v.TagKeys(),
+		m:              v.m,
+		start:          time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+		ss:             make(map[chan *ViewData]subscription),
+		c:              cloneCollector(v.c),
+		resetOnCollect: v.resetOnCollect,
+		outOfRange:     v.outOfRange,
+		sortRows:       v.sortRows,
+		durationUnit:   v.durationUnit,
+		spanExemplars:  v.spanExemplars,
+	}
+	for _, c := range v.extra {
+		nv.extra = append(nv.extra, cloneCollector(c))
+	}
+	for _, e := range v.extractors {
+		nv.extractors = append(nv.extractors, e)
+	}
+	if v.sampler != nil {
+		// the views don't share their sampler since they may be used by the
+		// workers of different registries.
+		nv.sampler = newSampler(v.sampler.rate)
+	}
+	return nv
+}
+
+// cloneCollector returns a new collector with the configuration of c.
+func cloneCollector(c *collector) *collector {
+	nc := newCollector(c.a, c.w)
+	nc.scale = c.scale
+	nc.maxRows = c.maxRows
+	if c.rate != nil {
+		nc.rate = newRateState()
+	}
+	nc.ratio = c.ratio
+	return nc
+}
+
+func (v *view) addSubscription(c chan *ViewData, s subscription) {
+	v.ss[c] = s
 }
 
 func (v *view) deleteSubscription(c chan *ViewData) {
@@ -135,29 +340,136 @@ func (v *view) subscriptions() map[chan *ViewData]subscription {
 
 func (v *view) startForcedCollection() {
 	v.isForcedCollection = true
+	v.forcedUntil = time.Time{}
+}
+
+// startForcedCollectionUntil forces the collection until t. It doesn't
+// shorten a forced collection that expires later or never.
+func (v *view) startForcedCollectionUntil(t time.Time) {
+	if v.isForcedCollection && (v.forcedUntil.IsZero() || v.forcedUntil.After(t)) {
+		return
+	}
+	v.isForcedCollection = true
+	v.forcedUntil = t
 }
 
 func (v *view) stopForcedCollection() {
 	v.isForcedCollection = false
+	v.forcedUntil = time.Time{}
+}
+
+func (v *view) forcedCollection() bool {
+	return v.isForcedCollection
+}
+
+func (v *view) forcedCollectionExpiry() time.Time {
+	return v.forcedUntil
 }
 
 func (v *view) isCollecting() bool {
 	return v.subscriptionsCount() > 0 || v.isForcedCollection
 }
 
+// startCollection records now as the start of the collection of all the
+// windows of the view.
+func (v *view) startCollection(now time.Time) {
+	v.c.start = now
+	v.c.markReset()
+	for _, c := range v.extra {
+		c.start = now
+	}
+}
+
+func (v *view) isResetOnCollect() bool {
+	return v.resetOnCollect
+}
+
 func (v *view) clearRows() {
 	v.c.clearRows()
+	v.c.markReset()
+	for _, c := range v.extra {
+		c.clearRows()
+	}
+}
+
+// clearIntervalRows clears the data collected for all windows of the view
+// except the cumulative ones. The collection of the cleared windows restarts
+// at now.
+func (v *view) clearIntervalRows(now time.Time) {
+	if _, ok := v.c.w.(*WindowCumulative); !ok {
+		v.c.resetAt(now)
+	}
+	for _, c := range v.extra {
+		if _, ok := c.w.(*WindowCumulative); !ok {
+			c.resetAt(now)
+		}
+	}
 }
 
 func (v *view) collector() *collector {
 	return v.c
 }
 
+// rowsCount returns the number of rows currently collected for the primary
+// window of the view.
+func (v *view) rowsCount() int {
+	return len(v.c.signatures)
+}
+
+// Window returns the primary window of the view.
 func (v *view) Window() Window {
 	return v.c.w
 }
 
+// Aliases returns a copy of the alias names of the view.
+func (v *view) Aliases() []string {
+	if len(v.aliases) == 0 {
+		return nil
+	}
+	return append([]string(nil), v.aliases...)
+}
+
+// hasName returns whether name is the name or one of the aliases of v.
+func hasName(v View, name string) bool {
+	if v.Name() == name {
+		return true
+	}
+	for _, a := range v.Aliases() {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// aliasView is a view reported under one of its aliases. It is only used as
+// the View of the ViewData delivered for the alias.
+type aliasView struct {
+	View
+	name string
+}
+
+// Name returns the alias.
+func (v *aliasView) Name() string {
+	return v.name
+}
+
+// Windows returns all the windows of the view starting with the primary one.
+func (v *view) Windows() []Window {
+	ret := []Window{v.c.w}
+	for _, c := range v.extra {
+		ret = append(ret, c.w)
+	}
+	return ret
+}
+
 func (v *view) Aggregation() Aggregation {
+	switch {
+	case v.c.rate != nil:
+		return &AggregationRate{}
+	case v.c.ratio != nil:
+		return &AggregationRatio{}
+	}
 	return v.c.a
 }
 
@@ -165,31 +477,298 @@ func (v *view) Measure() Measure {
 	return v.m
 }
 
+// Unit returns the unit of the values aggregated by v.
+func (v *view) Unit() Unit {
+	if _, ok := v.m.(*MeasureDuration); ok {
+		for u, d := range durationUnits {
+			if d == v.durationUnit {
+				return u
+			}
+		}
+	}
+	return unitOf(v.m)
+}
+
 func (v *view) collectedRows(now time.Time) []*Row {
-	return v.c.collectedRows(v.tagKeys, now)
+	return v.sorted(v.c.collectedRows(v.tagKeys, now))
+}
+
+func (v *view) appendCollectedRows(rows []*Row, now time.Time) []*Row {
+	n := len(rows)
+	rows = v.c.appendCollectedRows(rows, v.tagKeys, now)
+	v.sorted(rows[n:])
+	return rows
+}
+
+func (v *view) collectedRowsFiltered(pred func([]tags.Tag) bool, now time.Time) []*Row {
+	return v.sorted(v.c.appendFilteredRows(nil, v.tagKeys, pred, now))
+}
+
+// sorted sorts rows if the view was created with WithSortedRows.
+func (v *view) sorted(rows []*Row) []*Row {
+	if v.sortRows {
+		SortRows(rows)
+	}
+	return rows
+}
+
+// collectedRowsForWindow returns the rows collected for the window of the view
+// equal to w. Windows are compared by type and parameters.
+func (v *view) collectedRowsForWindow(w Window, now time.Time) ([]*Row, error) {
+	if reflect.DeepEqual(w, v.c.w) {
+		return v.sorted(v.c.collectedRows(v.tagKeys, now)), nil
+	}
+	for _, c := range v.extra {
+		if reflect.DeepEqual(w, c.w) {
+			return v.sorted(c.collectedRows(v.tagKeys, now)), nil
+		}
+	}
+	return nil, fmt.Errorf("view '%v' doesn't collect data for window %v", v.name, w)
 }
 
 func (v *view) addSample(ts *tags.TagSet, val interface{}, now time.Time) {
+	v.addSampleWithAttachments(ts, val, nil, now)
+}
+
+func (v *view) addSampleWithAttachments(ts *tags.TagSet, val interface{}, attachments map[string]string, now time.Time) {
+	v.addSampleWithCache(ts, nil, val, attachments, trace.SpanContext{}, now)
+}
+
+// addSampleWithCache is like addSampleWithAttachments but looks up the
+// signature of ts for the view in sigs before computing it, and stores it in
+// sigs once computed. sigs may be nil. The signatures of the views with tag
+// extractors are never cached. span is the span context of the sample if it
+// was recorded in a sampled span, zero otherwise.
+func (v *view) addSampleWithCache(ts *tags.TagSet, sigs map[View]string, val interface{}, attachments map[string]string, span trace.SpanContext, now time.Time) {
 	if !v.isCollecting() {
 		return
 	}
-	sig := tags.ToValuesString(ts, v.tagKeys)
+	if v.sampler != nil && !v.sampler.sample() {
+		return
+	}
+	if v.recent != nil {
+		v.recent.add(&Sample{now, ts, val, attachments})
+	}
+	sig := v.signature(ts, sigs)
+	if d, ok := val.(time.Duration); ok {
+		val = float64(d) / float64(v.durationUnit)
+	}
 	v.c.addSample(sig, val, now)
+	for _, c := range v.extra {
+		c.addSample(sig, val, now)
+	}
+	if attachments != nil {
+		v.c.addExemplar(sig, &Exemplar{
+			Value:       val,
+			Time:        now,
+			Attachments: attachments,
+		})
+	}
+	if v.spanExemplars && span.IsValid() {
+		v.c.addSpanExemplar(sig, &Exemplar{
+			Value:       val,
+			Time:        now,
+			Attachments: attachments,
+			SpanContext: span,
+		})
+	}
+}
+
+// addCountWithCache adds n samples to the row of ts without looking at their
+// values. It is only used for the views counting the samples over cumulative
+// windows, see isCountingView.
+func (v *view) addCountWithCache(ts *tags.TagSet, sigs map[View]string, n int64, now time.Time) {
+	if !v.isCollecting() {
+		return
+	}
+	sig := v.signature(ts, sigs)
+	v.c.addCount(sig, n, now)
+	for _, c := range v.extra {
+		c.addCount(sig, n, now)
+	}
+}
+
+// signature returns the signature of ts for the view, looking it up in sigs
+// first. See addSampleWithCache.
+func (v *view) signature(ts *tags.TagSet, sigs map[View]string) string {
+	if sig, ok := sigs[v]; ok {
+		return sig
+	}
+	if len(v.extractors) != 0 {
+		return tags.ToValuesString(v.extractTags(ts), v.tagKeys)
+	}
+	sig := tags.ToValuesString(ts, v.tagKeys)
+	if sigs != nil {
+		sigs[v] = sig
+	}
+	return sig
+}
+
+func (v *view) isSampled() bool {
+	return v.sampler != nil
+}
+
+func (v *view) collectedExemplars() []*Exemplar {
+	return v.c.collectedExemplars(v.tagKeys)
+}
+
+func (v *view) keepRecentSamples(n int) {
+	if n <= 0 {
+		v.recent = nil
+		return
+	}
+	v.recent = newSamplesRing(n)
+}
+
+func (v *view) recentSamples() []*Sample {
+	if v.recent == nil {
+		return nil
+	}
+	return v.recent.samples()
+}
+
+func (v *view) addWatcher(wr *Watcher) {
+	v.watches = append(v.watches, wr)
+}
+
+// removeWatcher removes wr from the watchers of v. It returns false if wr
+// wasn't watching v.
+func (v *view) removeWatcher(wr *Watcher) bool {
+	for i, x := range v.watches {
+		if x == wr {
+			v.watches = append(v.watches[:i:i], v.watches[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (v *view) watchers() []*Watcher {
+	return v.watches
+}
+
+func (v *view) keepHistory(n int) {
+	if n <= 0 {
+		v.history = nil
+		return
+	}
+	v.history = newViewDataRing(n)
+}
+
+func (v *view) keepsHistory() bool {
+	return v.history != nil
+}
+
+// addHistory adds vd to the history of v, marked as Reset if the data was
+// reset since the last ViewData added.
+func (v *view) addHistory(vd *ViewData) {
+	vd = withReset(vd, v.historyResets != v.c.resets)
+	v.historyResets = v.c.resets
+	v.history.add(vd)
+}
+
+func (v *view) retrieveHistory(n int) []*ViewData {
+	if v.history == nil {
+		return nil
+	}
+	return v.history.last(n)
 }
 
 // A ViewData is a set of rows about usage of the single measure associated
 // with the given view during a particular window. Each row is specific to a
 // unique set of tags.
 type ViewData struct {
-	V          View
+	V View
+	// Start and End are the boundaries of the window the rows were collected
+	// over: the start of the collection (or of the interval for views reset
+	// on collection) for cumulative and sliding count windows, and the start
+	// of the time span for sliding time windows. End is the collection time.
 	Start, End time.Time
-	Rows       []*Row
+	// Reset is true if the data doesn't follow the data previously reported
+	// for the view, because its collection restarted (e.g. the view was
+	// registered again, replaced, or the worker restarted), or its data was
+	// cleared or restored. The consumers computing rates or deltas from
+	// successive ViewData must start over instead of reading a drop of the
+	// values as a negative rate. Each subscriber and each reader of ReadAll
+	// or of a metrics handler is told of a reset, whatever the order they
+	// get the data in, and the first ViewData they get is marked too. The
+	// data of the views reset on each collection isn't marked.
+	Reset bool
+	Rows  []*Row
+	// Exemplars holds, for each set of tags, the last measurement recorded
+	// with attachments during the window.
+	Exemplars []*Exemplar
+	// Resource is the entity the data was recorded in. It is nil unless
+	// SetResource was called.
+	Resource *resource.Resource
+
+	// borrowed is true if the ViewData must be released. See
+	// SubscribeToViewBorrowed.
+	borrowed bool
+}
+
+// Release returns a ViewData delivered to a channel subscribed with
+// SubscribeToViewBorrowed to the library, which reuses its rows for the next
+// reports. It must be called once, after which neither the ViewData nor its
+// rows may be used. It does nothing for the other ViewData.
+func (vd *ViewData) Release() {
+	if !vd.borrowed {
+		return
+	}
+	for _, r := range vd.Rows {
+		*r = Row{}
+	}
+	*vd = ViewData{Rows: vd.Rows[:0]}
+	viewDataPool.Put(vd)
+}
+
+// ViewInfo describes the collection state of a registered view. It is a
+// snapshot taken when GetViewInfo is called.
+type ViewInfo struct {
+	V View
+	// Subscriptions is the number of channels subscribed to the view.
+	Subscriptions int
+	// ForcedCollection is true if ForceCollection or ForceCollectionFor was
+	// called for the view.
+	ForcedCollection bool
+	// ForcedCollectionExpiry is the time the forced collection expires. It is
+	// zero if the forced collection doesn't expire. See ForceCollectionFor.
+	ForcedCollectionExpiry time.Time
+	// Rows is the number of rows currently collected for the primary window
+	// of the view.
+	Rows int
+	// DroppedSamples is the number of samples dropped by the primary window
+	// of the view because its row limit was reached. See WithMaxRows.
+	DroppedSamples uint64
+	// DroppedViewData is the number of ViewData not delivered to the current
+	// subscribers because their channel was full, or closed. See
+	// WithBackpressure.
+	DroppedViewData uint64
+}
+
+// Exemplar is a single measurement recorded with attachments, e.g. with
+// RecordFloat64WithAttachments. It is reported alongside the aggregated data to
+// give an example of the measurements that contributed to a row.
+type Exemplar struct {
+	Tags        []tags.Tag
+	Value       interface{}
+	Time        time.Time
+	Attachments map[string]string
+	// SpanContext is the span the measurement was recorded in, for the
+	// exemplars of the views created with WithSpanExemplars. It is zero for
+	// the other exemplars.
+	SpanContext trace.SpanContext
 }
 
 // Row is the collected value for a specific set of key value pairs a.k.a tags.
 type Row struct {
 	Tags             []tags.Tag
 	AggregationValue AggregationValue
+	// Start is the time of the first sample aggregated in the row of a
+	// cumulative window since the row was created or reset, which the
+	// exporters report as the start time of the cumulative series. It is
+	// zero for the rows of the other windows and of the rate and ratio views.
+	Start time.Time
 }
 
 func (r *Row) String() string {
@@ -207,13 +786,56 @@ func (r *Row) String() string {
 
 // Equal returns true if both Rows are equal. Tags are expected to be ordered
 // by the key name. Even both rows have the same tags but the tags appear in
-// different orders it will return false.
+// different orders it will return false. The floating-point values are
+// compared with the tolerance set by SetEqualityEpsilon. Start isn't
+// compared.
 func (r *Row) Equal(other *Row) bool {
 	if r == other {
 		return true
 	}
 
-	return reflect.DeepEqual(r.Tags, other.Tags) && r.AggregationValue.equal(other.AggregationValue)
+	return reflect.DeepEqual(r.Tags, other.Tags) && EqualWithTolerance(r.AggregationValue, other.AggregationValue, equalityEpsilon())
+}
+
+// SortRows sorts rows in a deterministic order: by the name of the key of
+// their first tag, then by its value, then by their second tag, and so on.
+// The tags of each row are expected to be ordered by key name, as they are in
+// the rows returned by the library. A row whose tags are a prefix of the tags
+// of another row comes first.
+func SortRows(rows []*Row) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return compareTags(rows[i].Tags, rows[j].Tags) < 0
+	})
+}
+
+// compareTags compares the tags a and b in the order of SortRows.
+func compareTags(a, b []tags.Tag) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].K.Name() != b[i].K.Name() {
+			if a[i].K.Name() < b[i].K.Name() {
+				return -1
+			}
+			return 1
+		}
+		if c := bytes.Compare(a[i].V, b[i].V); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// MatchTag returns a predicate for RetrieveDataFiltered selecting the rows
+// whose value for the key k is value. The values are compared as formatted by
+// k.ValueAsString, e.g. "42" or "true" for the keys of type int64 and bool.
+func MatchTag(k tags.Key, value string) func([]tags.Tag) bool {
+	return func(ts []tags.Tag) bool {
+		for _, t := range ts {
+			if t.K == k {
+				return k.ValueAsString(t.V) == value
+			}
+		}
+		return false
+	}
 }
 
 // ContainsRow returns true if rows contain r.
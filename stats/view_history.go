@@ -0,0 +1,58 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+// viewDataRing is a fixed size circular buffer holding the most recent
+// ViewData collected for a view.
+type viewDataRing struct {
+	entries []*ViewData
+	// idx is the position where the next ViewData will be written.
+	idx  int
+	full bool
+}
+
+func newViewDataRing(n int) *viewDataRing {
+	return &viewDataRing{
+		entries: make([]*ViewData, n),
+	}
+}
+
+func (r *viewDataRing) add(vd *ViewData) {
+	r.entries[r.idx] = vd
+	r.idx = (r.idx + 1) % len(r.entries)
+	if r.idx == 0 {
+		r.full = true
+	}
+}
+
+// last returns at most the n most recent ViewData ordered from the oldest to
+// the most recent. It returns all the retained ViewData if n is less than or
+// equal to zero.
+func (r *viewDataRing) last(n int) []*ViewData {
+	size := r.idx
+	if r.full {
+		size = len(r.entries)
+	}
+	if n <= 0 || n > size {
+		n = size
+	}
+
+	ret := make([]*ViewData, n)
+	for i := range ret {
+		ret[i] = r.entries[(r.idx-n+i+len(r.entries))%len(r.entries)]
+	}
+	return ret
+}
@@ -0,0 +1,267 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"sync/atomic"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+// ViewOption customizes a view created with NewView.
+type ViewOption func(v *view)
+
+// WithDescription sets the description of the view.
+func WithDescription(description string) ViewOption {
+	return func(v *view) {
+		v.description = description
+	}
+}
+
+// WithMeasure sets the measure whose samples are aggregated by the view.
+func WithMeasure(m Measure) ViewOption {
+	return func(v *view) {
+		v.m = m
+	}
+}
+
+// WithTagKeys adds keys to the keys the data of the view is aggregated on.
+func WithTagKeys(keys ...tags.Key) ViewOption {
+	return func(v *view) {
+		for _, k := range keys {
+			v.tagKeys = append(v.tagKeys, k)
+		}
+	}
+}
+
+// WithAggregation sets the aggregation of the view. The default aggregation is
+// an AggregationCount.
+func WithAggregation(agg Aggregation) ViewOption {
+	return func(v *view) {
+		v.c.a = agg
+	}
+}
+
+// WithWindow sets the primary window of the view. The default window is a
+// WindowCumulative.
+func WithWindow(wnd Window) ViewOption {
+	return func(v *view) {
+		v.c.w = wnd
+	}
+}
+
+// WithSampleRate makes the view aggregate only a random fraction rate of the
+// samples recorded, e.g. 0.01 for 1%, to reduce the cost of aggregating the
+// measures recorded on hot paths. The counts collected are scaled by 1/rate to
+// estimate the counts of all the samples. Rates outside of (0, 1) are ignored.
+func WithSampleRate(rate float64) ViewOption {
+	return func(v *view) {
+		if rate <= 0 || rate >= 1 {
+			return
+		}
+		v.sampler = newSampler(rate)
+		v.c.scale = 1 / rate
+	}
+}
+
+// WithSpanExemplars makes the view report the samples recorded in sampled
+// spans, i.e. with a context carrying a sampled trace.SpanContext, as
+// exemplars holding their span context. The last such sample of each bucket
+// of the distributions is kept, so that the backends can link the points of
+// a bucket, e.g. of the slow requests, to concrete traces. The span context
+// is not looked up when recording until a view is created with this option.
+func WithSpanExemplars() ViewOption {
+	return func(v *view) {
+		if !v.spanExemplars {
+			atomic.AddInt32(&spanExemplarViews, 1)
+		}
+		v.spanExemplars = true
+	}
+}
+
+// WithMaxRows limits to n the number of rows, i.e. distinct sets of tag
+// values, collected by the view for each window. Once the limit is reached,
+// the samples with new tag values are dropped until the rows are cleared. A
+// value of n less than or equal to zero means no limit, which is the default.
+func WithMaxRows(n int) ViewOption {
+	return func(v *view) {
+		v.c.maxRows = n
+	}
+}
+
+// WithResetOnCollect makes the data collected for the primary window of the
+// view be cleared each time it is collected, i.e. reported to the subscribers
+// or retrieved with RetrieveData. Each collection then returns the delta since
+// the previous collection, which is what push exporters (e.g. statsd) expect.
+// It is meant for views with a WindowCumulative. Since every collection
+// consumes the delta, such a view should have a single consumer.
+func WithResetOnCollect() ViewOption {
+	return func(v *view) {
+		v.resetOnCollect = true
+	}
+}
+
+// WithOutOfRange selects how the distribution of the view handles the samples
+// below its first bound (underflow) and at or above its last bound (overflow),
+// e.g. to match the schema of the histograms of an exporter. It has no effect
+// on the views whose aggregation isn't an AggregationDistribution.
+func WithOutOfRange(underflow, overflow OutOfRange) ViewOption {
+	return func(v *view) {
+		v.outOfRange = &[2]OutOfRange{underflow, overflow}
+	}
+}
+
+// WithSortedRows makes the rows collected for the view be sorted with
+// SortRows, whether they are reported to the subscribers or retrieved with
+// RetrieveData, so that exporters and tests can compare them positionally.
+func WithSortedRows() ViewOption {
+	return func(v *view) {
+		v.sortRows = true
+	}
+}
+
+// WithAdditionalWindows makes the view aggregate its samples over the windows
+// wnds in addition to its primary window. See NewMultiWindowView.
+func WithAdditionalWindows(wnds ...Window) ViewOption {
+	return func(v *view) {
+		for _, w := range wnds {
+			v.extra = append(v.extra, newCollector(nil, w))
+		}
+	}
+}
+
+// WithNamespace registers the view under the namespace prefix (e.g.
+// "myservice/http/") by prepending prefix to its name. All the views of a
+// namespace can be unregistered at once with UnregisterNamespace.
+func WithNamespace(prefix string) ViewOption {
+	return func(v *view) {
+		v.name = prefix + v.name
+	}
+}
+
+// WithAliases gives the view alias names, e.g. its former names while the
+// dashboards and alerts migrate to a new name. The view is retrieved by
+// GetViewByName under each alias, and its subscribers receive its data once
+// under its name and once under each alias, with the same rows, without the
+// cost of a duplicate view. The aliases aren't kept by WithName and
+// WithWindow.
+func WithAliases(names ...string) ViewOption {
+	return func(v *view) {
+		v.aliases = append(v.aliases, names...)
+	}
+}
+
+// WithDurationUnit sets the unit to which the samples of a MeasureDuration
+// are converted before being aggregated by the view. unit must be one of "ns",
+// "us", "ms", "s", "min" or "h". The default unit is "ms". Other units are
+// ignored, the view keeping the unit it had: check View.Unit to validate a
+// unit coming from configuration.
+func WithDurationUnit(unit Unit) ViewOption {
+	return func(v *view) {
+		if u, ok := durationUnits[unit]; ok {
+			v.durationUnit = u
+		}
+	}
+}
+
+// tagExtractor computes the value of the tag k from the TagSet of a
+// measurement.
+type tagExtractor struct {
+	k *tags.KeyString
+	f func(ts *tags.TagSet) []byte
+}
+
+// WithTagExtractor makes the view compute at record time the value of the tag
+// k by calling f with the TagSet of the measurement, e.g. to bucketize a value
+// or to derive a tag from another one. The value returned by f replaces any
+// value associated with k in the TagSet. If f returns nil the measurement is
+// aggregated as if it had no tag for k. k is added to the keys of the view if
+// it isn't already one of them. f is called by the library's worker goroutine
+// and must not block.
+func WithTagExtractor(k *tags.KeyString, f func(ts *tags.TagSet) []byte) ViewOption {
+	return func(v *view) {
+		v.extractors = append(v.extractors, &tagExtractor{k, f})
+		for _, x := range v.tagKeys {
+			if x == tags.Key(k) {
+				return
+			}
+		}
+		v.tagKeys = append(v.tagKeys, k)
+	}
+}
+
+// extractTags returns the TagSet ts updated with the values computed by the
+// extractors of the view.
+func (v *view) extractTags(ts *tags.TagSet) *tags.TagSet {
+	tsb := tags.NewTagSetBuilder(ts)
+	for _, e := range v.extractors {
+		if b := v.extract(e, ts); b != nil {
+			tsb.UpsertString(e.k, string(b))
+		} else {
+			tsb.Delete(e.k)
+		}
+	}
+	return tsb.Build()
+}
+
+// extract calls the function of e. If it panics, the panic is reported to the
+// error handler of the registry of the view, and the measurement is
+// aggregated as if it had no tag for the key of e.
+func (v *view) extract(e *tagExtractor, ts *tags.TagSet) (b []byte) {
+	defer func() {
+		if p := recover(); p != nil {
+			registryOf(v.m).w.handlePanic(p, "the tag extractor of key '%v' of view '%v'", e.k.Name(), v.Name())
+			b = nil
+		}
+	}()
+	return e.f(ts)
+}
+
+// OtherTagValue is the value replacing the tag values filtered out by
+// WithTagValueAllowlist and WithTagValueDenylist.
+const OtherTagValue = "other"
+
+// WithTagValueAllowlist makes the view aggregate the measurements having for
+// the key k a value that is not in values as if their value was
+// OtherTagValue. It allows controlling the cardinality of a view without
+// changing the instrumented code.
+func WithTagValueAllowlist(k *tags.KeyString, values []string) ViewOption {
+	return withTagValueFilter(k, values, true)
+}
+
+// WithTagValueDenylist makes the view aggregate the measurements having for
+// the key k a value that is in values as if their value was OtherTagValue.
+func WithTagValueDenylist(k *tags.KeyString, values []string) ViewOption {
+	return withTagValueFilter(k, values, false)
+}
+
+func withTagValueFilter(k *tags.KeyString, values []string, allow bool) ViewOption {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	other := []byte(OtherTagValue)
+	return WithTagExtractor(k, func(ts *tags.TagSet) []byte {
+		v, ok := ts.Value(k)
+		if !ok {
+			return nil
+		}
+		if set[v] != allow {
+			return other
+		}
+		return []byte(v)
+	})
+}
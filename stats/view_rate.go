@@ -0,0 +1,96 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import "time"
+
+// NewRateView creates a new View reporting the rate, in samples per second,
+// of the samples aggregated by counter, e.g. the requests per second of a view
+// counting the requests or a distribution of their latencies. The rate of
+// each row is the number of samples recorded between two collections of the
+// view divided by the time elapsed, or since the start of the collection for
+// the first one. The view is registered, subscribed to and retrieved like any
+// other view, independently of counter, and its rows hold an
+// AggregationRateValue. It is meant for the dashboards and backends without
+// rate() support.
+func NewRateView(name, description string, counter View) View {
+	src, ok := counter.(*view)
+	if !ok {
+		src = NewView(name, description, counter.TagKeys(), counter.Measure(), NewAggregationCount(), NewWindowCumulative()).(*view)
+	}
+	v := src.clone()
+	v.name = name
+	v.description = description
+	v.aliases = nil
+	v.extra = nil
+	v.resetOnCollect = false
+	v.spanExemplars = false
+	v.c = newCollector(NewAggregationCount(), NewWindowCumulative())
+	v.c.maxRows = src.c.maxRows
+	v.c.scale = src.c.scale
+	v.c.rate = newRateState()
+	return v
+}
+
+// rateState derives the rates of the rows of a view from their counts.
+type rateState struct {
+	rows map[string]*rateRow
+}
+
+// rateRow holds the count of a row at its previous collection.
+type rateRow struct {
+	count int64
+	at    time.Time
+	rate  float64
+}
+
+func newRateState() *rateState {
+	return &rateState{
+		rows: make(map[string]*rateRow),
+	}
+}
+
+// derive returns the rate of the row s whose count is av at now, start being
+// the start of the collection. The rate is computed since the previous call
+// for s, and the rate of that call is returned if no time elapsed since.
+func (r *rateState) derive(s string, av AggregationValue, start, now time.Time) AggregationValue {
+	count := int64(0)
+	if c, ok := av.(*AggregationCountValue); ok {
+		count = int64(*c)
+	}
+
+	row, ok := r.rows[s]
+	if !ok {
+		row = &rateRow{at: start}
+		r.rows[s] = row
+	}
+	if elapsed := now.Sub(row.at); elapsed > 0 {
+		delta := count - row.count
+		if delta < 0 {
+			// the count was reset since the previous collection.
+			delta = count
+		}
+		row.rate = float64(delta) / elapsed.Seconds()
+		row.count = count
+		row.at = now
+	}
+	return newAggregationRateValue(row.rate)
+}
+
+// clear forgets the rows.
+func (r *rateState) clear() {
+	r.rows = make(map[string]*rateRow)
+}
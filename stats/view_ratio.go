@@ -0,0 +1,123 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+// NewRatioView creates a new View whose rows are the ratios of the rows of
+// two views, e.g. the ratio of the failed requests to all the requests, a
+// common SLI. The rows of numerator and denominator are aggregated onto keys,
+// and each row of the view is the value of the numerator row divided by the
+// value of the denominator row with the same tags. The value of a row is its
+// count for the counts and the distributions, and its rate for the rate
+// views. The rows whose denominator is zero are omitted, and the numerator of
+// a missing row is zero. The ratios are computed when the view is collected:
+// numerator and denominator must be registered and collecting data, e.g. be
+// forcibly collected, for the view to have rows. The view is registered and
+// subscribed to like any other view, and its rows hold an
+// AggregationRatioValue.
+func NewRatioView(name, description string, keys []tags.Key, numerator, denominator View) View {
+	v := NewViewWithOptions(name,
+		WithDescription(description),
+		WithTagKeys(keys...),
+		WithMeasure(numerator.Measure()),
+	).(*view)
+	v.c.ratio = &ratioState{
+		num: numerator,
+		den: denominator,
+	}
+	return v
+}
+
+// isComposite returns whether v is computed from the rows of other views
+// rather than from the samples of its measure, which it doesn't aggregate.
+func isComposite(v View) bool {
+	return v.collector().ratio != nil
+}
+
+// ratioState computes the rows of a view created with NewRatioView.
+type ratioState struct {
+	num, den View
+}
+
+// appendRows appends to rows the ratios of the rows of num and den aggregated
+// onto keys, whose tags satisfy pred unless it is nil.
+func (r *ratioState) appendRows(rows []*Row, keys []tags.Key, pred func([]tags.Tag) bool, now time.Time) []*Row {
+	nums, _ := sumRows(r.num.collectedRows(now), keys)
+	dens, ts := sumRows(r.den.collectedRows(now), keys)
+	for sig, d := range dens {
+		if d == 0 {
+			continue
+		}
+		if pred != nil && !pred(ts[sig]) {
+			continue
+		}
+		rows = append(rows, &Row{Tags: ts[sig], AggregationValue: newAggregationRatioValue(nums[sig] / d)})
+	}
+	return rows
+}
+
+// sumRows aggregates the values of rows onto keys. It returns the sums and the
+// tags of the aggregated rows by signature.
+func sumRows(rows []*Row, keys []tags.Key) (map[string]float64, map[string][]tags.Tag) {
+	sums := make(map[string]float64)
+	ts := make(map[string][]tags.Tag)
+	for _, row := range rows {
+		var projected []tags.Tag
+		for _, t := range row.Tags {
+			for _, k := range keys {
+				if t.K == k {
+					projected = append(projected, t)
+					break
+				}
+			}
+		}
+		sig := tags.SliceToValuesString(projected, keys)
+		if _, ok := ts[sig]; !ok {
+			ts[sig] = projected
+		}
+		sums[sig] += scalarValue(row.AggregationValue)
+	}
+	return sums, ts
+}
+
+// scalarValue returns the value of av used to compute ratios. It is the value
+// of the first aggregation of an AggregationMultiValue.
+func scalarValue(av AggregationValue) float64 {
+	switch av := av.(type) {
+	case *AggregationCountValue:
+		return float64(*av)
+	case *AggregationDistributionValue:
+		return float64(av.Count())
+	case *AggregationRateValue:
+		return float64(*av)
+	case *AggregationRatioValue:
+		return float64(*av)
+	case *AggregationSumValue:
+		return float64(*av)
+	case *AggregationMeanValue:
+		return av.Mean()
+	case *AggregationMultiValue:
+		if len(av.values) > 0 {
+			return scalarValue(av.values[0])
+		}
+	}
+	return 0
+}
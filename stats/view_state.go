@@ -0,0 +1,347 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+// viewStateVersion is the version of the encoding of the view states. It is
+// incremented when the encoding changes incompatibly.
+const viewStateVersion = 1
+
+// viewState is the data collected for the cumulative windows of a view, as
+// serialized by SnapshotState. The rows are identified by their signatures,
+// which only depend on the values of the tags in the order of the keys of the
+// view, so the state can be restored in another process.
+type viewState struct {
+	Version int
+	Name    string
+	TagKeys []string
+	Windows []windowState
+}
+
+// windowState is the data collected for the window of index Index in the
+// windows of the view.
+type windowState struct {
+	Index int
+	Start time.Time
+	Rows  []rowState
+}
+
+type rowState struct {
+	Signature string
+	Value     valueState
+	// Start is the start of the row. It is zero in the states encoded before
+	// the rows had a start.
+	Start time.Time
+}
+
+// valueState is an AggregationValue. Exactly one of the fields is set,
+// according to the type of the value.
+type valueState struct {
+	Count        *int64
+	Distribution *distributionState
+	Multi        []valueState
+	Sum          *float64
+	Mean         *meanState
+}
+
+type meanState struct {
+	Sum, Count float64
+}
+
+type distributionState struct {
+	Count                 int64
+	Min, Max              float64
+	Mean, SumOfSquaredDev float64
+	CountPerBucket        []int64
+	Bounds                []float64
+	Underflows, Overflows int64
+	// Int64 is true for the values of int64 distributions, whose exact sum,
+	// min and max are SumInt64, MinInt64 and MaxInt64.
+	Int64                        bool
+	SumInt64, MinInt64, MaxInt64 int64
+}
+
+func newValueState(av AggregationValue) valueState {
+	switch av := av.(type) {
+	case *AggregationCountValue:
+		n := int64(*av)
+		return valueState{Count: &n}
+	case *AggregationDistributionValue:
+		return valueState{Distribution: &distributionState{
+			Count:           av.count,
+			Min:             av.min,
+			Max:             av.max,
+			Mean:            av.mean,
+			SumOfSquaredDev: av.sumOfSquaredDev,
+			CountPerBucket:  av.countPerBucket,
+			Bounds:          av.bounds,
+			Underflows:      av.underflows,
+			Overflows:       av.overflows,
+			Int64:           av.isInt64,
+			SumInt64:        av.sumInt64,
+			MinInt64:        av.minInt64,
+			MaxInt64:        av.maxInt64,
+		}}
+	case *AggregationSumValue:
+		sum := float64(*av)
+		return valueState{Sum: &sum}
+	case *AggregationMeanValue:
+		return valueState{Mean: &meanState{Sum: av.sum, Count: av.count}}
+	case *AggregationMultiValue:
+		vs := valueState{Multi: make([]valueState, len(av.values))}
+		for i, v := range av.values {
+			vs.Multi[i] = newValueState(v)
+		}
+		return vs
+	}
+	return valueState{}
+}
+
+// toAggregationValue returns the value held by s. It returns an error unless
+// the value has the type and the bounds of like.
+func (s valueState) toAggregationValue(like AggregationValue) (AggregationValue, error) {
+	switch like := like.(type) {
+	case *AggregationCountValue:
+		if s.Count == nil {
+			return nil, fmt.Errorf("got a value of another type than the count of the view")
+		}
+		return newAggregationCountValue(*s.Count), nil
+	case *AggregationDistributionValue:
+		d := s.Distribution
+		if d == nil {
+			return nil, fmt.Errorf("got a value of another type than the distribution of the view")
+		}
+		if !equalBounds(d.Bounds, like.bounds) || len(d.CountPerBucket) != len(like.countPerBucket) {
+			return nil, fmt.Errorf("got a distribution with bounds %v, want the bounds of the view %v", d.Bounds, like.bounds)
+		}
+		av := NewAggregationDistributionValue(d.Bounds, d.CountPerBucket, d.Count, d.Min, d.Max, d.Mean, d.SumOfSquaredDev, d.Underflows, d.Overflows)
+		if like.isInt64 {
+			av.setInt64()
+			switch {
+			case d.Int64:
+				av.sumInt64, av.minInt64, av.maxInt64 = d.SumInt64, d.MinInt64, d.MaxInt64
+			case d.Count > 0:
+				// the value of a float64 distribution, e.g. received from
+				// another process.
+				av.sumInt64 = int64(math.Floor(d.Mean*float64(d.Count) + 0.5))
+				av.minInt64 = int64(math.Floor(d.Min + 0.5))
+				av.maxInt64 = int64(math.Floor(d.Max + 0.5))
+			}
+		}
+		return av, nil
+	case *AggregationSumValue:
+		if s.Sum == nil {
+			return nil, fmt.Errorf("got a value of another type than the sum of the view")
+		}
+		return newAggregationSumValue(*s.Sum), nil
+	case *AggregationMeanValue:
+		if s.Mean == nil {
+			return nil, fmt.Errorf("got a value of another type than the mean of the view")
+		}
+		return &AggregationMeanValue{sum: s.Mean.Sum, count: s.Mean.Count}, nil
+	case *AggregationMultiValue:
+		if len(s.Multi) != len(like.values) {
+			return nil, fmt.Errorf("got %v aggregation values, want the %v aggregations of the view", len(s.Multi), len(like.values))
+		}
+		av := &AggregationMultiValue{values: make([]AggregationValue, len(s.Multi))}
+		for i, vs := range s.Multi {
+			v, err := vs.toAggregationValue(like.values[i])
+			if err != nil {
+				return nil, err
+			}
+			av.values[i] = v
+		}
+		return av, nil
+	}
+	return nil, fmt.Errorf("unsupported aggregation value %T", like)
+}
+
+func equalBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// cumulativeCollectors returns the collectors of the cumulative windows of v
+// indexed by the position of their window in v.Windows().
+func (v *view) cumulativeCollectors() map[int]*collector {
+	cs := make(map[int]*collector)
+	for i, c := range append([]*collector{v.c}, v.extra...) {
+		if _, ok := c.w.(*WindowCumulative); ok {
+			cs[i] = c
+		}
+	}
+	return cs
+}
+
+// snapshotState serializes the data collected for the cumulative windows of
+// v. It must only be called by the worker goroutine.
+func (v *view) snapshotState() ([]byte, error) {
+	s := &viewState{
+		Version: viewStateVersion,
+		Name:    v.name,
+	}
+	for _, k := range v.tagKeys {
+		s.TagKeys = append(s.TagKeys, k.Name())
+	}
+	for i, c := range v.cumulativeCollectors() {
+		ws := windowState{Index: i, Start: c.start}
+		for sig, a := range c.signatures {
+			ws.Rows = append(ws.Rows, rowState{
+				Signature: sig,
+				Value:     newValueState(a.(*aggregatorCumulative).av),
+				Start:     a.(*aggregatorCumulative).started,
+			})
+		}
+		s.Windows = append(s.Windows, ws)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		return nil, fmt.Errorf("cannot encode the state of view '%v': %v", v.name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// restoreState adds the data serialized in b by snapshotState to the data
+// collected for the cumulative windows of v. The state is checked before any
+// data is added. It must only be called by the worker goroutine.
+func (v *view) restoreState(b []byte, now time.Time) error {
+	var s viewState
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&s); err != nil {
+		return fmt.Errorf("cannot decode the state of view '%v': %v", v.name, err)
+	}
+	if s.Version != viewStateVersion {
+		return fmt.Errorf("cannot restore the state of view '%v' encoded with version %v, want version %v", v.name, s.Version, viewStateVersion)
+	}
+	if s.Name != v.name {
+		return fmt.Errorf("cannot restore the state of view '%v' into view '%v'", s.Name, v.name)
+	}
+	if len(s.TagKeys) != len(v.tagKeys) {
+		return fmt.Errorf("cannot restore the state of view '%v' with tag keys %v", v.name, s.TagKeys)
+	}
+	for i, k := range v.tagKeys {
+		if s.TagKeys[i] != k.Name() {
+			return fmt.Errorf("cannot restore the state of view '%v' with tag keys %v", v.name, s.TagKeys)
+		}
+	}
+
+	cs := v.cumulativeCollectors()
+	values := make([][]AggregationValue, len(s.Windows))
+	for i, ws := range s.Windows {
+		c, ok := cs[ws.Index]
+		if !ok {
+			return fmt.Errorf("cannot restore the state of view '%v' because its window %v is not cumulative", v.name, ws.Index)
+		}
+		like := c.a.aggregationValueConstructor()()
+		for _, r := range ws.Rows {
+			av, err := r.Value.toAggregationValue(like)
+			if err != nil {
+				return fmt.Errorf("cannot restore the state of view '%v': %v", v.name, err)
+			}
+			values[i] = append(values[i], av)
+		}
+	}
+
+	for i, ws := range s.Windows {
+		c := cs[ws.Index]
+		for j, r := range ws.Rows {
+			if a, ok := c.aggregator(r.Signature, now).(*aggregatorCumulative); ok {
+				a.add(values[i][j])
+				a.startAt(r.Start)
+			}
+		}
+		if !ws.Start.IsZero() && ws.Start.Before(c.start) {
+			c.start = ws.Start
+		}
+	}
+	v.c.markReset()
+	return nil
+}
+
+// addRows adds the aggregation values of rows to the data collected for the
+// rows with the same tags in the cumulative windows of v. The rows are checked
+// before any data is added. It must only be called by the worker goroutine.
+func (v *view) addRows(rows []*Row, now time.Time) error {
+	cs := v.cumulativeCollectors()
+	if len(cs) == 0 {
+		return fmt.Errorf("cannot add rows to view '%v' because it has no cumulative window", v.name)
+	}
+	like := v.c.a.aggregationValueConstructor()()
+	values := make([]AggregationValue, len(rows))
+	for i, r := range rows {
+		av, err := newValueState(r.AggregationValue).toAggregationValue(like)
+		if err != nil {
+			return fmt.Errorf("cannot add rows to view '%v': %v", v.name, err)
+		}
+		values[i] = av
+	}
+	for i, r := range rows {
+		sig := tags.SliceToValuesString(r.Tags, v.tagKeys)
+		for _, c := range cs {
+			if a, ok := c.aggregator(sig, now).(*aggregatorCumulative); ok {
+				a.add(values[i])
+				a.startAt(r.Start)
+			}
+		}
+	}
+	return nil
+}
+
+// SnapshotState serializes the data collected for the cumulative windows of
+// the view, e.g. to persist it across the restarts of short-lived processes.
+// The view must be collecting data.
+func (v *view) SnapshotState() ([]byte, error) {
+	req := &snapshotStateReq{
+		v: v,
+		c: make(chan *snapshotStateResp),
+	}
+	registryOf(v.m).w.ctl <- req
+	resp := <-req.c
+	return resp.b, resp.err
+}
+
+// RestoreState adds the data serialized by SnapshotState, possibly in another
+// process, to the data collected for the cumulative windows of the view. The
+// view must be collecting data, and must have the name, the tag keys, the
+// aggregation and the cumulative windows of the view the state was taken
+// from. The start of the collection, and of each row, becomes the start of
+// the restored one if it is earlier, so that the backends don't interpret the
+// restart as a reset. The next ViewData reported for the view is marked as
+// Reset, since its data jumps to include the restored data.
+func (v *view) RestoreState(b []byte) error {
+	req := &restoreStateReq{
+		v:   v,
+		b:   b,
+		err: make(chan error),
+	}
+	registryOf(v.m).w.ctl <- req
+	return <-req.err
+}
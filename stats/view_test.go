@@ -16,6 +16,8 @@
 package stats
 
 import (
+	"math/rand"
+	"reflect"
 	"testing"
 	"time"
 
@@ -53,10 +55,8 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowCumulative(t *testin
 			},
 			[]*Row{
 				{
-					[]tags.Tag{{k1, []byte("v1")}},
-					&AggregationDistributionValue{
-						2, 1, 5, 3, 8, []int64{1, 1}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k1, []byte("v1")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{1, 1}, 2, 1, 5, 3, 8),
 				},
 			},
 		},
@@ -68,16 +68,12 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowCumulative(t *testin
 			},
 			[]*Row{
 				{
-					[]tags.Tag{{k1, []byte("v1")}},
-					&AggregationDistributionValue{
-						1, 1, 1, 1, 0, []int64{1, 0}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k1, []byte("v1")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{1, 0}, 1, 1, 1, 1, 0),
 				},
 				{
-					[]tags.Tag{{k2, []byte("v2")}},
-					&AggregationDistributionValue{
-						1, 5, 5, 5, 0, []int64{0, 1}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k2, []byte("v2")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{0, 1}, 1, 5, 5, 5, 0),
 				},
 			},
 		},
@@ -92,28 +88,20 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowCumulative(t *testin
 			},
 			[]*Row{
 				{
-					[]tags.Tag{{k1, []byte("v1")}},
-					&AggregationDistributionValue{
-						2, 1, 5, 3, 8, []int64{1, 1}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k1, []byte("v1")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{1, 1}, 2, 1, 5, 3, 8),
 				},
 				{
-					[]tags.Tag{{k1, []byte("v1 other")}},
-					&AggregationDistributionValue{
-						1, 1, 1, 1, 0, []int64{1, 0}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k1, []byte("v1 other")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{1, 0}, 1, 1, 1, 1, 0),
 				},
 				{
-					[]tags.Tag{{k2, []byte("v2")}},
-					&AggregationDistributionValue{
-						1, 5, 5, 5, 0, []int64{0, 1}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k2, []byte("v2")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{0, 1}, 1, 5, 5, 5, 0),
 				},
 				{
-					[]tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
-					&AggregationDistributionValue{
-						1, 5, 5, 5, 0, []int64{0, 1}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{0, 1}, 1, 5, 5, 5, 0),
 				},
 			},
 		},
@@ -130,22 +118,16 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowCumulative(t *testin
 			},
 			[]*Row{
 				{
-					[]tags.Tag{{k1, []byte("v1 is a very long value key")}},
-					&AggregationDistributionValue{
-						2, 1, 5, 3, 8, []int64{1, 1}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k1, []byte("v1 is a very long value key")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{1, 1}, 2, 1, 5, 3, 8),
 				},
 				{
-					[]tags.Tag{{k1, []byte("v1 is another very long value key")}},
-					&AggregationDistributionValue{
-						1, 1, 1, 1, 0, []int64{1, 0}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k1, []byte("v1 is another very long value key")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{1, 0}, 1, 1, 1, 1, 0),
 				},
 				{
-					[]tags.Tag{{k1, []byte("v1 is a very long value key")}, {k2, []byte("v2 is a very long value key")}},
-					&AggregationDistributionValue{
-						4, 1, 5, 3, 2.66666666666667 * 3, []int64{1, 3}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k1, []byte("v1 is a very long value key")}, {k2, []byte("v2 is a very long value key")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{1, 3}, 4, 1, 5, 3, 2.66666666666667*3),
 				},
 			},
 		},
@@ -228,10 +210,8 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowSlidingTime(t *testi
 					startTime.Add(14 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							&AggregationDistributionValue{
-								6, 2, 5, 3.8333333333, 1.3666666667 * 5, []int64{0, 6}, agg1.bounds,
-							},
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{0, 6}, 6, 2, 5, 3.8333333333, 1.3666666667*5),
 						},
 					},
 				},
@@ -240,10 +220,8 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowSlidingTime(t *testi
 					startTime.Add(18 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							&AggregationDistributionValue{
-								4, 3, 5, 4, 0.6666666667 * 3, []int64{0, 4}, agg1.bounds,
-							},
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{0, 4}, 4, 3, 5, 4, 0.6666666667*3),
 						},
 					},
 				},
@@ -252,10 +230,8 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowSlidingTime(t *testi
 					startTime.Add(22 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							&AggregationDistributionValue{
-								2, 3, 4, 3.5, 0.5, []int64{0, 2}, agg1.bounds,
-							},
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{0, 2}, 2, 3, 4, 3.5, 0.5),
 						},
 					},
 				},
@@ -278,10 +254,8 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowSlidingTime(t *testi
 					startTime.Add(10 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							&AggregationDistributionValue{
-								7, 1, 5, 3.57142857142857, 2.61904761904762 * 6, []int64{1, 6}, agg1.bounds,
-							},
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{1, 6}, 7, 1, 5, 3.57142857142857, 2.61904761904762*6),
 						},
 					},
 				},
@@ -290,10 +264,8 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowSlidingTime(t *testi
 					startTime.Add(12 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							&AggregationDistributionValue{
-								7, 1, 5, 3.57142857142857, 2.61904761904762 * 6, []int64{1, 6}, agg1.bounds,
-							},
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{1, 6}, 7, 1, 5, 3.57142857142857, 2.61904761904762*6),
 						},
 					},
 				},
@@ -302,10 +274,8 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowSlidingTime(t *testi
 					startTime.Add(15 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							&AggregationDistributionValue{
-								6, 2, 5, 4, 1.6 * 5, []int64{0, 6}, agg1.bounds,
-							},
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{0, 6}, 6, 2, 5, 4, 1.6*5),
 						},
 					},
 				},
@@ -314,10 +284,8 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowSlidingTime(t *testi
 					startTime.Add(17*time.Second - 1*time.Millisecond),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							&AggregationDistributionValue{
-								6, 2, 5, 4, 1.6 * 5, []int64{0, 6}, agg1.bounds,
-							},
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{0, 6}, 6, 2, 5, 4, 1.6*5),
 						},
 					},
 				},
@@ -326,10 +294,8 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowSlidingTime(t *testi
 					startTime.Add(18 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							&AggregationDistributionValue{
-								4, 4, 5, 4.75, 0.25 * 3, []int64{0, 4}, agg1.bounds,
-							},
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{0, 4}, 4, 4, 5, 4.75, 0.25*3),
 						},
 					},
 				},
@@ -417,8 +383,8 @@ func Test_View_MeasureFloat64_AggregationCount_WindowSlidingTime(t *testing.T) {
 					startTime.Add(14 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							newAggregationCountValue(6),
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: newAggregationCountValue(6),
 						},
 					},
 				},
@@ -427,8 +393,8 @@ func Test_View_MeasureFloat64_AggregationCount_WindowSlidingTime(t *testing.T) {
 					startTime.Add(18 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							newAggregationCountValue(4),
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: newAggregationCountValue(4),
 						},
 					},
 				},
@@ -437,8 +403,8 @@ func Test_View_MeasureFloat64_AggregationCount_WindowSlidingTime(t *testing.T) {
 					startTime.Add(22 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							newAggregationCountValue(2),
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: newAggregationCountValue(2),
 						},
 					},
 				},
@@ -461,8 +427,8 @@ func Test_View_MeasureFloat64_AggregationCount_WindowSlidingTime(t *testing.T) {
 					startTime.Add(10 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							newAggregationCountValue(7),
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: newAggregationCountValue(7),
 						},
 					},
 				},
@@ -471,8 +437,8 @@ func Test_View_MeasureFloat64_AggregationCount_WindowSlidingTime(t *testing.T) {
 					startTime.Add(12 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							newAggregationCountValue(7),
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: newAggregationCountValue(7),
 						},
 					},
 				},
@@ -481,8 +447,8 @@ func Test_View_MeasureFloat64_AggregationCount_WindowSlidingTime(t *testing.T) {
 					startTime.Add(12 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							newAggregationCountValue(7),
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: newAggregationCountValue(7),
 						},
 					},
 				},
@@ -491,8 +457,8 @@ func Test_View_MeasureFloat64_AggregationCount_WindowSlidingTime(t *testing.T) {
 					startTime.Add(15*time.Second + 400*time.Millisecond),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							newAggregationCountValue(6),
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: newAggregationCountValue(6),
 						},
 					},
 				},
@@ -501,8 +467,8 @@ func Test_View_MeasureFloat64_AggregationCount_WindowSlidingTime(t *testing.T) {
 					startTime.Add(16 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							newAggregationCountValue(5),
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: newAggregationCountValue(5),
 						},
 					},
 				},
@@ -511,8 +477,8 @@ func Test_View_MeasureFloat64_AggregationCount_WindowSlidingTime(t *testing.T) {
 					startTime.Add(17*time.Second + 200*time.Millisecond),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							newAggregationCountValue(4),
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: newAggregationCountValue(4),
 						},
 					},
 				},
@@ -521,8 +487,8 @@ func Test_View_MeasureFloat64_AggregationCount_WindowSlidingTime(t *testing.T) {
 					startTime.Add(18 * time.Second),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							newAggregationCountValue(3),
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: newAggregationCountValue(3),
 						},
 					},
 				},
@@ -531,8 +497,8 @@ func Test_View_MeasureFloat64_AggregationCount_WindowSlidingTime(t *testing.T) {
 					startTime.Add(18*time.Second + 600*time.Millisecond),
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}},
-							newAggregationCountValue(2),
+							Tags:             []tags.Tag{{k1, []byte("v1")}},
+							AggregationValue: newAggregationCountValue(2),
 						},
 					},
 				},
@@ -604,10 +570,8 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowSlidingCount(t *test
 			},
 			[]*Row{
 				{
-					[]tags.Tag{{k1, []byte("v1")}},
-					&AggregationDistributionValue{
-						4, 1, 4, 2.5, 1.6666666667 * 3, []int64{1, 3}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k1, []byte("v1")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{1, 3}, 4, 1, 4, 2.5, 1.6666666667*3),
 				},
 			},
 		},
@@ -632,10 +596,8 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowSlidingCount(t *test
 			},
 			[]*Row{
 				{
-					[]tags.Tag{{k1, []byte("v1")}},
-					&AggregationDistributionValue{
-						15, 1, 15, 8, 20 * 14, []int64{1, 14}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k1, []byte("v1")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{1, 14}, 15, 1, 15, 8, 20*14),
 				},
 			},
 		},
@@ -658,10 +620,8 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowSlidingCount(t *test
 			},
 			[]*Row{
 				{
-					[]tags.Tag{{k1, []byte("v1")}},
-					&AggregationDistributionValue{
-						13, 1, 13, 7, 15.1666666667 * 12, []int64{1, 12}, agg1.bounds,
-					},
+					Tags:             []tags.Tag{{k1, []byte("v1")}},
+					AggregationValue: NewDoNotUseTestingAggregationDistributionValue(agg1.bounds, []int64{1, 12}, 13, 1, 13, 7, 15.1666666667*12),
 				},
 			},
 		},
@@ -695,3 +655,517 @@ func Test_View_MeasureFloat64_AggregationDistribution_WindowSlidingCount(t *test
 		}
 	}
 }
+
+func Test_View_WindowSlidingCountWrapsAround(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	// 2 samples per bucket, with an extra bucket for the oldest samples.
+	v := NewView("VF35", "desc VF35", []tags.Key{k1}, nil, NewAggregationCount(), NewWindowSlidingCount(4, 2))
+	v.startForcedCollection()
+
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+	now := time.Now()
+	// the buckets are reused several times: each reused bucket must start
+	// counting its samples from zero again.
+	for i := 1; i <= 20; i++ {
+		v.addSample(ts, float64(i), now)
+		if i < 4 {
+			continue
+		}
+		rows := v.collectedRows(now)
+		if len(rows) != 1 {
+			t.Fatalf("after %v samples: collectedRows got %v rows, want 1", i, len(rows))
+		}
+		// the oldest bucket is counted for the fraction of its samples that
+		// still are in the window.
+		if got := *rows[0].AggregationValue.(*AggregationCountValue); got < 4 || got > 5 {
+			t.Errorf("after %v samples: got count %v, want the 4 or 5 last samples", i, got)
+		}
+	}
+}
+
+func Test_View_TagExtractor(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	kSpeed, _ := tags.CreateKeyString("speed")
+	speed := func(ts *tags.TagSet) []byte {
+		v, ok := ts.Value(k1)
+		if !ok {
+			return nil
+		}
+		if v == "fast-path" {
+			return []byte("fast")
+		}
+		return []byte("slow")
+	}
+	vw := NewView("VE1", "desc VE1", nil, nil, NewAggregationCount(), NewWindowCumulative(), WithTagExtractor(kSpeed, speed))
+	vw.startForcedCollection()
+
+	now := time.Now()
+	for _, v := range []string{"fast-path", "fast-path", "other"} {
+		ts := tags.NewTagSetBuilder(nil).InsertString(k1, v).Build()
+		vw.addSample(ts, int64(1), now)
+	}
+	vw.addSample(tags.NewTagSetBuilder(nil).Build(), int64(1), now)
+
+	want := []*Row{
+		{Tags: []tags.Tag{{kSpeed, []byte("fast")}}, AggregationValue: newAggregationCountValue(2)},
+		{Tags: []tags.Tag{{kSpeed, []byte("slow")}}, AggregationValue: newAggregationCountValue(1)},
+		{Tags: nil, AggregationValue: newAggregationCountValue(1)},
+	}
+	if ok, msg := EqualRows(vw.collectedRows(now), want); !ok {
+		t.Errorf("got unexpected rows for view with tag extractor. %v", msg)
+	}
+}
+
+func Test_View_TagValueFilters(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	allow := NewView("VA1", "desc VA1", []tags.Key{k1}, nil, NewAggregationCount(), NewWindowCumulative(), WithTagValueAllowlist(k1, []string{"GET", "POST"}))
+	deny := NewView("VD1", "desc VD1", []tags.Key{k1}, nil, NewAggregationCount(), NewWindowCumulative(), WithTagValueDenylist(k1, []string{"GET"}))
+
+	now := time.Now()
+	for _, vw := range []View{allow, deny} {
+		vw.startForcedCollection()
+		for _, v := range []string{"GET", "POST", "PUT", "DELETE"} {
+			ts := tags.NewTagSetBuilder(nil).InsertString(k1, v).Build()
+			vw.addSample(ts, int64(1), now)
+		}
+	}
+
+	type testCase struct {
+		v    View
+		want []*Row
+	}
+	tcs := []testCase{
+		{
+			allow,
+			[]*Row{
+				{Tags: []tags.Tag{{k1, []byte("GET")}}, AggregationValue: newAggregationCountValue(1)},
+				{Tags: []tags.Tag{{k1, []byte("POST")}}, AggregationValue: newAggregationCountValue(1)},
+				{Tags: []tags.Tag{{k1, []byte(OtherTagValue)}}, AggregationValue: newAggregationCountValue(2)},
+			},
+		},
+		{
+			deny,
+			[]*Row{
+				{Tags: []tags.Tag{{k1, []byte(OtherTagValue)}}, AggregationValue: newAggregationCountValue(1)},
+				{Tags: []tags.Tag{{k1, []byte("POST")}}, AggregationValue: newAggregationCountValue(1)},
+				{Tags: []tags.Tag{{k1, []byte("PUT")}}, AggregationValue: newAggregationCountValue(1)},
+				{Tags: []tags.Tag{{k1, []byte("DELETE")}}, AggregationValue: newAggregationCountValue(1)},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		if ok, msg := EqualRows(tc.v.collectedRows(now), tc.want); !ok {
+			t.Errorf("View '%v': got unexpected rows. %v", tc.v.Name(), msg)
+		}
+	}
+}
+
+func Test_View_NewViewWithOptions(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	m := &MeasureInt64{name: "MI1", views: make(map[View]bool)}
+	wndCount := NewWindowSlidingCount(10, 2)
+	v := NewViewWithOptions("VO1",
+		WithAdditionalWindows(wndCount),
+		WithDescription("desc VO1"),
+		WithMeasure(m),
+		WithTagKeys(k1),
+		WithAggregation(NewAggregationDistribution([]float64{0})),
+		WithMaxRows(2),
+	)
+
+	if got, want := v.Description(), "desc VO1"; got != want {
+		t.Errorf("Description() got %v, want %v", got, want)
+	}
+	if got := v.Measure(); got != m {
+		t.Errorf("Measure() got %v, want %v", got, m)
+	}
+	if _, ok := v.Window().(*WindowCumulative); !ok {
+		t.Errorf("Window() got %T, want the default *WindowCumulative", v.Window())
+	}
+
+	v.startForcedCollection()
+	now := time.Now()
+	for _, val := range []string{"a", "b", "c", "a"} {
+		ts := tags.NewTagSetBuilder(nil).InsertString(k1, val).Build()
+		v.addSample(ts, int64(1), now)
+	}
+	for _, w := range []Window{NewWindowCumulative(), wndCount} {
+		rows, err := v.collectedRowsForWindow(w, now)
+		if err != nil {
+			t.Fatalf("collectedRowsForWindow(%T) got error %v, want no error", w, err)
+		}
+		if len(rows) != 2 {
+			t.Errorf("collectedRowsForWindow(%T) got %v rows, want 2", w, len(rows))
+		}
+		for _, r := range rows {
+			if _, ok := r.AggregationValue.(*AggregationDistributionValue); !ok {
+				t.Errorf("collectedRowsForWindow(%T) got %T, want *AggregationDistributionValue", w, r.AggregationValue)
+			}
+		}
+	}
+	if got := v.collector().dropped; got != 1 {
+		t.Errorf("dropped samples got %v, want 1", got)
+	}
+
+	if err := RegisterView(NewViewWithOptions("VO2")); err == nil {
+		t.Errorf("RegisterView for a view without measure got no error, want error")
+	}
+}
+
+func Test_View_WithWindowAndName(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	m := &MeasureInt64{name: "MI1", views: make(map[View]bool)}
+	v := NewView("VW1", "desc VW1", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative(), WithMaxRows(1))
+	v.startForcedCollection()
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "a").Build()
+	v.addSample(ts, int64(1), time.Now())
+
+	wnd := NewWindowSlidingTime(time.Minute, 6)
+	v2 := v.WithWindow(wnd).WithName("VW2")
+	if got, want := v2.Name(), "VW2"; got != want {
+		t.Errorf("Name() got %v, want %v", got, want)
+	}
+	if got, want := v2.Description(), v.Description(); got != want {
+		t.Errorf("Description() got %v, want %v", got, want)
+	}
+	if got := v2.Measure(); got != m {
+		t.Errorf("Measure() got %v, want %v", got, m)
+	}
+	if got := v2.Window(); got != wnd {
+		t.Errorf("Window() got %v, want %v", got, wnd)
+	}
+	if _, ok := v.Window().(*WindowCumulative); !ok {
+		t.Errorf("Window() of the original view got %T, want *WindowCumulative", v.Window())
+	}
+	if got := v2.TagKeys(); len(got) != 1 || got[0] != tags.Key(k1) {
+		t.Errorf("TagKeys() got %v, want [%v]", got, k1)
+	}
+	if v2.isCollecting() {
+		t.Errorf("isCollecting() of the new view got true, want false")
+	}
+	if got := v2.rowsCount(); got != 0 {
+		t.Errorf("rowsCount() of the new view got %v, want 0", got)
+	}
+
+	// the options are kept.
+	v2.startForcedCollection()
+	now := time.Now()
+	for _, val := range []string{"a", "b"} {
+		v2.addSample(tags.NewTagSetBuilder(nil).InsertString(k1, val).Build(), int64(1), now)
+	}
+	if got := v2.collector().dropped; got != 1 {
+		t.Errorf("dropped samples of the new view got %v, want 1", got)
+	}
+}
+
+func Test_View_AggregationMulti(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	agg := NewAggregationMulti(NewAggregationCount(), NewAggregationMulti(NewAggregationDistribution([]float64{2, 4})))
+	if got := len(agg.Aggregations()); got != 2 {
+		t.Fatalf("Aggregations() got %v aggregations, want 2 after flattening", got)
+	}
+	v := NewView("VM1", "desc VM1", []tags.Key{k1}, nil, agg, NewWindowSlidingCount(10, 2), WithOutOfRange(OutOfRangeCount, OutOfRangeBucket))
+	v.startForcedCollection()
+
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+	now := time.Now()
+	for _, val := range []float64{1, 3, 5} {
+		v.addSample(ts, val, now)
+	}
+	rows := v.collectedRows(now)
+	if len(rows) != 1 {
+		t.Fatalf("collectedRows got %v rows, want 1", len(rows))
+	}
+	mv, ok := rows[0].AggregationValue.(*AggregationMultiValue)
+	if !ok {
+		t.Fatalf("collectedRows got %T, want *AggregationMultiValue", rows[0].AggregationValue)
+	}
+	want := NewAggregationMultiValue(
+		newAggregationCountValue(3),
+		NewAggregationDistributionValue([]float64{2, 4}, []int64{0, 1, 1}, 2, 3, 5, 4, 2, 1, 0),
+	)
+	if !mv.equal(want, DefaultEqualityEpsilon) {
+		t.Errorf("collectedRows got %v, want %v", mv, want)
+	}
+
+	prev := NewAggregationMultiValue(newAggregationCountValue(1), NewAggregationDistributionValue([]float64{2, 4}, []int64{0, 1, 0}, 1, 3, 3, 3, 0, 1, 0))
+	delta, err := AggregationValueDelta(mv, prev)
+	if err != nil {
+		t.Fatalf("AggregationValueDelta got error %v, want no error", err)
+	}
+	if got := delta.(*AggregationMultiValue).Values()[0]; !got.equal(newAggregationCountValue(2), DefaultEqualityEpsilon) {
+		t.Errorf("AggregationValueDelta got count %v, want 2", got)
+	}
+}
+
+func Test_View_CollectedRowsAreCopies(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VC1", "desc VC1", []tags.Key{k1}, nil, NewAggregationCount(), NewWindowCumulative())
+	v.startForcedCollection()
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+	now := time.Now()
+
+	v.addSample(ts, int64(1), now)
+	collected := v.collectedRows(now)[0].AggregationValue.(*AggregationCountValue)
+	v.addSample(ts, int64(1), now)
+
+	if got, want := *collected, AggregationCountValue(1); got != want {
+		t.Errorf("previously collected value got %v after a new sample, want %v", got, want)
+	}
+	if got, want := *(v.collectedRows(now)[0].AggregationValue.(*AggregationCountValue)), AggregationCountValue(2); got != want {
+		t.Errorf("collected value got %v, want %v", got, want)
+	}
+}
+
+func Test_View_SampleRate(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VS1", "desc VS1", []tags.Key{k1}, nil, NewAggregationDistribution([]float64{10}), NewWindowCumulative(), WithSampleRate(0.1))
+	v.(*view).sampler.rand = rand.New(rand.NewSource(1))
+	v.startForcedCollection()
+
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+	now := time.Now()
+	for i := 0; i < 10000; i++ {
+		v.addSample(ts, float64(5), now)
+		v.addSample(ts, float64(15), now)
+	}
+
+	rows := v.collectedRows(now)
+	if len(rows) != 1 {
+		t.Fatalf("collectedRows got %v rows, want 1", len(rows))
+	}
+	dv := rows[0].AggregationValue.(*AggregationDistributionValue)
+	if got := dv.Count(); got < 18000 || got > 22000 {
+		t.Errorf("scaled count got %v, want about 20000", got)
+	}
+	if got := dv.Mean(); got < 9 || got > 11 {
+		t.Errorf("mean got %v, want about 10", got)
+	}
+	if got := dv.CountPerBucket(); got[0]+got[1] < 18000 || got[0]+got[1] > 22000 {
+		t.Errorf("scaled counts per bucket got %v, want about 10000 each", got)
+	}
+}
+
+func Test_View_SampleRateInt64(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VS2", "desc VS2", []tags.Key{k1}, nil, NewAggregationDistributionInt64([]int64{10}), NewWindowCumulative(), WithSampleRate(0.1))
+	v.(*view).sampler.rand = rand.New(rand.NewSource(1))
+	v.startForcedCollection()
+
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+	now := time.Now()
+	for i := 0; i < 10000; i++ {
+		v.addSample(ts, int64(5), now)
+		v.addSample(ts, int64(15), now)
+	}
+
+	rows := v.collectedRows(now)
+	if len(rows) != 1 {
+		t.Fatalf("collectedRows got %v rows, want 1", len(rows))
+	}
+	dv := rows[0].AggregationValue.(*AggregationDistributionValue)
+	if !dv.IsInt64() {
+		t.Fatalf("IsInt64 got false for the scaled value, want true")
+	}
+	if got := dv.SumInt64(); got < 180000 || got > 220000 {
+		t.Errorf("scaled SumInt64 got %v, want about 200000", got)
+	}
+	if got := dv.Mean(); got < 9 || got > 11 {
+		t.Errorf("mean got %v, want about 10", got)
+	}
+	if dv.MinInt64() != 5 || dv.MaxInt64() != 15 {
+		t.Errorf("got MinInt64 %v and MaxInt64 %v, want 5 and 15", dv.MinInt64(), dv.MaxInt64())
+	}
+}
+
+func Test_SortRows(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	k2, _ := tags.CreateKeyString("k2")
+	count := newAggregationCountValue(1)
+	r1 := &Row{Tags: []tags.Tag{{k1, []byte("a")}}, AggregationValue: count}
+	r2 := &Row{Tags: []tags.Tag{{k1, []byte("a")}, {k2, []byte("a")}}, AggregationValue: count}
+	r3 := &Row{Tags: []tags.Tag{{k1, []byte("a")}, {k2, []byte("b")}}, AggregationValue: count}
+	r4 := &Row{Tags: []tags.Tag{{k1, []byte("b")}}, AggregationValue: count}
+	r5 := &Row{Tags: []tags.Tag{{k2, []byte("a")}}, AggregationValue: count}
+
+	type testCase struct {
+		label string
+		rows  []*Row
+		want  []*Row
+	}
+	tcs := []testCase{
+		{"empty", nil, nil},
+		{"sorted", []*Row{r1, r2, r3, r4, r5}, []*Row{r1, r2, r3, r4, r5}},
+		{"reversed", []*Row{r5, r4, r3, r2, r1}, []*Row{r1, r2, r3, r4, r5}},
+		{"shuffled", []*Row{r3, r5, r1, r4, r2}, []*Row{r1, r2, r3, r4, r5}},
+	}
+	for _, tc := range tcs {
+		SortRows(tc.rows)
+		if !reflect.DeepEqual(tc.rows, tc.want) {
+			t.Errorf("%v: SortRows got %v, want %v", tc.label, tc.rows, tc.want)
+		}
+	}
+}
+
+func Test_View_SortedRows(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VSR1", "desc VSR1", []tags.Key{k1}, nil, NewAggregationCount(), NewWindowCumulative(), WithSortedRows())
+	v.startForcedCollection()
+	now := time.Now()
+	for _, s := range []string{"d", "b", "e", "a", "c"} {
+		v.addSample(tags.NewTagSetBuilder(nil).InsertString(k1, s).Build(), int64(1), now)
+	}
+
+	var got []string
+	for _, r := range v.collectedRows(now) {
+		got = append(got, string(r.Tags[0].V))
+	}
+	if want := []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("collectedRows got rows with values %v, want %v", got, want)
+	}
+}
+
+func Test_View_OutOfRange(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+
+	type testCase struct {
+		label                 string
+		underflow, overflow   OutOfRange
+		wantCountPerBucket    []int64
+		underflows, overflows int64
+	}
+	tcs := []testCase{
+		{"buckets", OutOfRangeBucket, OutOfRangeBucket, []int64{1, 1, 2}, 0, 0},
+		{"counted underflow", OutOfRangeCount, OutOfRangeBucket, []int64{0, 1, 2}, 1, 0},
+		{"counted underflow and overflow", OutOfRangeCount, OutOfRangeCount, []int64{0, 1, 0}, 1, 2},
+	}
+	for _, tc := range tcs {
+		v := NewView("VOR1", "desc VOR1", []tags.Key{k1}, nil, NewAggregationDistribution([]float64{0, 10}), NewWindowCumulative(), WithOutOfRange(tc.underflow, tc.overflow))
+		v.startForcedCollection()
+		now := time.Now()
+		for _, f := range []float64{-5, 5, 15, 20} {
+			v.addSample(ts, f, now)
+		}
+
+		dv := v.collectedRows(now)[0].AggregationValue.(*AggregationDistributionValue)
+		if got := dv.CountPerBucket(); !reflect.DeepEqual(got, tc.wantCountPerBucket) {
+			t.Errorf("%v: CountPerBucket got %v, want %v", tc.label, got, tc.wantCountPerBucket)
+		}
+		if dv.Underflows() != tc.underflows || dv.Overflows() != tc.overflows {
+			t.Errorf("%v: got %v underflows and %v overflows, want %v and %v", tc.label, dv.Underflows(), dv.Overflows(), tc.underflows, tc.overflows)
+		}
+	}
+}
+
+func Test_View_Unit(t *testing.T) {
+	mf := &MeasureFloat64{name: "MF26", unit: UnitBytes}
+	md := &MeasureDuration{name: "MD4"}
+
+	type testCase struct {
+		label string
+		m     Measure
+		opts  []ViewOption
+		want  Unit
+	}
+	tcs := []testCase{
+		{"float64 measure", mf, nil, UnitBytes},
+		{"duration measure", md, nil, UnitMilliseconds},
+		{"duration unit", md, []ViewOption{WithDurationUnit("us")}, UnitMicroseconds},
+		{"unsupported duration unit", md, []ViewOption{WithDurationUnit("s"), WithDurationUnit("fortnight")}, UnitSeconds},
+	}
+	for _, tc := range tcs {
+		v := NewView("VI50", "desc VI50", nil, tc.m, NewAggregationSum(), NewWindowCumulative(), tc.opts...)
+		if got := v.Unit(); got != tc.want {
+			t.Errorf("%v: Unit got %q, want %q", tc.label, got, tc.want)
+		}
+	}
+}
+
+func Test_View_WindowSlidingTimeWallClockAlignment(t *testing.T) {
+	startTime := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	k1, _ := tags.CreateKeyString("k1")
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+
+	type testCase struct {
+		label string
+		w     *WindowSlidingTime
+		want  AggregationCountValue
+	}
+	tcs := []testCase{
+		// the sub intervals are [1s, 3s), ..., [11s, 13s): half of the oldest
+		// one is still in the window at 12s.
+		{"not aligned", NewWindowSlidingTime(10*time.Second, 5), 2},
+		// the sub intervals are [0s, 2s), ..., [12s, 14s): the oldest one left
+		// the window at 12s.
+		{"aligned", NewWindowSlidingTime(10*time.Second, 5).WithWallClockAlignment(), 0},
+	}
+	for _, tc := range tcs {
+		v := NewView("VA1", "desc VA1", []tags.Key{k1}, nil, NewAggregationCount(), tc.w)
+		v.startForcedCollection()
+		for i := 0; i < 4; i++ {
+			v.addSample(ts, float64(i), startTime.Add(time.Second))
+		}
+
+		rows := v.collectedRows(startTime.Add(12 * time.Second))
+		if len(rows) != 1 {
+			t.Fatalf("%v: got %v rows, want 1", tc.label, len(rows))
+		}
+		if got := *(rows[0].AggregationValue.(*AggregationCountValue)); got != tc.want {
+			t.Errorf("%v: got count %v, want %v", tc.label, got, tc.want)
+		}
+	}
+	if w := NewWindowSlidingTime(time.Minute, 6); w.Aligned() || !w.WithWallClockAlignment().Aligned() {
+		t.Errorf("WithWallClockAlignment didn't return an aligned copy of the window")
+	}
+}
+
+func Test_View_ResetRecyclesSlidingAggregators(t *testing.T) {
+	startTime := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	k1, _ := tags.CreateKeyString("k1")
+	ts1 := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+	ts2 := tags.NewTagSetBuilder(nil).InsertString(k1, "v2").Build()
+	ts3 := tags.NewTagSetBuilder(nil).InsertString(k1, "v3").Build()
+
+	for _, w := range []Window{NewWindowSlidingTime(10*time.Second, 5), NewWindowSlidingCount(10, 5)} {
+		v := NewView("VR1", "desc VR1", []tags.Key{k1}, nil, NewAggregationCount(), w)
+		v.startForcedCollection()
+		for i := 0; i < 20; i++ {
+			v.addSample(ts1, float64(i), startTime.Add(time.Duration(i)*time.Second))
+			v.addSample(ts2, float64(i), startTime.Add(time.Duration(i)*time.Second))
+		}
+		c := v.collector()
+		old := make(map[aggregator]bool)
+		for _, a := range c.signatures {
+			old[a] = true
+		}
+
+		now := startTime.Add(time.Hour)
+		c.resetAt(now)
+		if got := len(c.spare); got != 2 {
+			t.Fatalf("%v: resetAt kept %v spare aggregators, want 2", windowName(w), got)
+		}
+		v.addSample(ts3, 1, now)
+		for _, a := range c.signatures {
+			if !old[a] {
+				t.Errorf("%v: the new row got a new aggregator, want a recycled one", windowName(w))
+			}
+		}
+		rows := v.collectedRows(now)
+		if len(rows) != 1 {
+			t.Fatalf("%v: got %v rows, want 1", windowName(w), len(rows))
+		}
+		if got := *(rows[0].AggregationValue.(*AggregationCountValue)); got != 1 {
+			t.Errorf("%v: recycled aggregator got count %v, want 1", windowName(w), got)
+		}
+
+		c.resetAt(now)
+		if got := len(c.spare); got != 1 {
+			t.Errorf("%v: second resetAt kept %v spare aggregators, want 1 since the unused ones are released", windowName(w), got)
+		}
+		c.clearRows()
+		if c.spare != nil {
+			t.Errorf("%v: clearRows kept spare aggregators, want none", windowName(w))
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package viewconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+)
+
+// NewAdminHandler returns an http.Handler to manage the views at runtime. It
+// serves the following paths, relative to where the handler is mounted (e.g.
+// with http.StripPrefix):
+//
+//	GET    /measures                  lists the registered measures.
+//	GET    /views                     lists the registered views with their
+//	                                  definition and collection state. The
+//	                                  views FromView can't describe only have
+//	                                  their name and the error.
+//	POST   /views                     registers the view defined by the JSON
+//	                                  ViewConfig in the body. If a view with
+//	                                  the same name is registered, it is
+//	                                  replaced (see stats.ReplaceView).
+//	DELETE /views?name=N              unregisters the view N.
+//	POST   /views/collection?name=N   forces the collection of the view N.
+//	DELETE /views/collection?name=N   stops the forced collection of the view N.
+//
+// The handler doesn't authenticate its callers. It must only be exposed to
+// trusted clients.
+func NewAdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/measures", serveMeasures)
+	mux.HandleFunc("/views", serveViews)
+	mux.HandleFunc("/views/collection", serveCollection)
+	return mux
+}
+
+type measureInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Unit string `json:"unit"`
+}
+
+type viewInfo struct {
+	*ViewConfig
+	Error            string `json:"error,omitempty"`
+	Subscriptions    int    `json:"subscriptions"`
+	ForcedCollection bool   `json:"forced_collection"`
+	Rows             int    `json:"rows"`
+}
+
+func serveMeasures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, fmt.Sprintf("method %v not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	infos := []*measureInfo{}
+	for _, m := range stats.Measures() {
+		info := &measureInfo{Name: m.Name()}
+		switch m.(type) {
+		case *stats.MeasureFloat64:
+			info.Type = "float64"
+		case *stats.MeasureInt64:
+			info.Type = "int64"
+		case *stats.MeasureDuration:
+			info.Type = "duration"
+		}
+		if u, ok := m.(interface {
+			Unit() stats.Unit
+		}); ok {
+			info.Unit = string(u.Unit())
+		}
+		infos = append(infos, info)
+	}
+	writeJSON(w, infos)
+}
+
+func serveViews(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		infos := []*viewInfo{}
+		for _, v := range stats.Views() {
+			vi, err := stats.GetViewInfo(v)
+			if err != nil {
+				// the view was unregistered since it was listed.
+				continue
+			}
+			info := &viewInfo{
+				Subscriptions:    vi.Subscriptions,
+				ForcedCollection: vi.ForcedCollection,
+				Rows:             vi.Rows,
+			}
+			if info.ViewConfig, err = FromView(v); err != nil {
+				info.ViewConfig = &ViewConfig{Name: v.Name()}
+				info.Error = err.Error()
+			}
+			infos = append(infos, info)
+		}
+		writeJSON(w, infos)
+	case http.MethodPost:
+		vc := &ViewConfig{}
+		if err := json.NewDecoder(r.Body).Decode(vc); err != nil {
+			http.Error(w, fmt.Sprintf("cannot parse view config. %v", err), http.StatusBadRequest)
+			return
+		}
+		v, err := vc.NewView()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if old, err := stats.GetViewByName(v.Name()); err == nil {
+			err = stats.ReplaceView(old, v)
+		} else {
+			err = stats.RegisterView(v)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		v, ok := viewFromQuery(w, r)
+		if !ok {
+			return
+		}
+		if err := stats.UnregisterView(v); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, fmt.Sprintf("method %v not allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+func serveCollection(w http.ResponseWriter, r *http.Request) {
+	var f func(stats.View) error
+	switch r.Method {
+	case http.MethodPost:
+		f = stats.ForceCollection
+	case http.MethodDelete:
+		f = stats.StopForcedCollection
+	default:
+		http.Error(w, fmt.Sprintf("method %v not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	v, ok := viewFromQuery(w, r)
+	if !ok {
+		return
+	}
+	if err := f(v); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// viewFromQuery returns the registered view named by the "name" query
+// parameter. It replies with an error and returns false if there is none.
+func viewFromQuery(w http.ResponseWriter, r *http.Request) (stats.View, bool) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "missing 'name' query parameter", http.StatusBadRequest)
+		return nil, false
+	}
+	v, err := stats.GetViewByName(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	return v, true
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package viewconfig
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+)
+
+func TestAdminHandler(t *testing.T) {
+	stats.RestartWorker()
+	if _, err := stats.NewMeasureInt64("/admin/requests", "requests", "1"); err != nil {
+		t.Fatalf("NewMeasureInt64() got error %v, want no error", err)
+	}
+	h := NewAdminHandler()
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	cumulative := `{"name": "/admin/requests/count", "measure": "/admin/requests", "aggregation": {"type": "count"}, "window": {"type": "cumulative"}}`
+	sliding := `{"name": "/admin/requests/count", "measure": "/admin/requests", "aggregation": {"type": "count"}, "window": {"type": "sliding_count", "count": 10, "sub_sets": 2}}`
+
+	type testCase struct {
+		label  string
+		method string
+		target string
+		body   string
+		want   int
+	}
+	tcs := []testCase{
+		{"register", "POST", "/views", cumulative, http.StatusCreated},
+		{"force collection", "POST", "/views/collection?name=/admin/requests/count", "", http.StatusNoContent},
+		{"change window", "POST", "/views", sliding, http.StatusCreated},
+		{"unregister collecting view", "DELETE", "/views?name=/admin/requests/count", "", http.StatusConflict},
+		{"stop forced collection", "DELETE", "/views/collection?name=/admin/requests/count", "", http.StatusNoContent},
+		{"unregister", "DELETE", "/views?name=/admin/requests/count", "", http.StatusNoContent},
+		{"unregister unknown view", "DELETE", "/views?name=/admin/requests/count", "", http.StatusNotFound},
+		{"invalid config", "POST", "/views", `{"name": "/admin/bad", "measure": "/unknown"}`, http.StatusBadRequest},
+		{"bad method", "PUT", "/measures", "", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range tcs {
+		if got := do(tc.method, tc.target, tc.body).Code; got != tc.want {
+			t.Errorf("Test case '%v': %v %v got status %v, want %v", tc.label, tc.method, tc.target, got, tc.want)
+		}
+		if tc.label == "change window" {
+			v, err := stats.GetViewByName("/admin/requests/count")
+			if err != nil {
+				t.Fatalf("GetViewByName() got error %v, want no error", err)
+			}
+			if _, ok := v.Window().(*stats.WindowSlidingCount); !ok {
+				t.Errorf("got window %T after change, want *stats.WindowSlidingCount", v.Window())
+			}
+		}
+	}
+
+	rec := do("GET", "/measures", "")
+	var measures []*measureInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &measures); err != nil {
+		t.Fatalf("GET /measures got invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	if len(measures) != 1 || measures[0].Name != "/admin/requests" || measures[0].Type != "int64" {
+		t.Errorf("GET /measures got %q, want the int64 measure /admin/requests", rec.Body.String())
+	}
+
+	do("POST", "/views", sliding)
+	do("POST", "/views/collection?name=/admin/requests/count", "")
+	rec = do("GET", "/views", "")
+	var views []*viewInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &views); err != nil {
+		t.Fatalf("GET /views got invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	if len(views) != 1 || views[0].Window.Type != "sliding_count" || !views[0].ForcedCollection {
+		t.Fatalf("GET /views got %q, want the forcibly collected sliding_count view", rec.Body.String())
+	}
+
+	// the listed definitions can be posted back.
+	b, err := json.Marshal(views[0].ViewConfig)
+	if err != nil {
+		t.Fatalf("json.Marshal() got error %v, want no error", err)
+	}
+	if got := do("POST", "/views", string(b)).Code; got != http.StatusCreated {
+		t.Errorf("POST /views of the listed definition %s got status %v, want %v", b, got, http.StatusCreated)
+	}
+
+	// the views which can't be described are listed with the error.
+	m, err := stats.GetMeasureByName("/admin/requests")
+	if err != nil {
+		t.Fatalf("GetMeasureByName() got error %v, want no error", err)
+	}
+	multi := stats.NewView("/admin/requests/multi", "", nil, m, stats.NewAggregationMulti(stats.NewAggregationCount(), stats.NewAggregationSum()), stats.NewWindowCumulative())
+	if err := stats.RegisterView(multi); err != nil {
+		t.Fatalf("RegisterView() got error %v, want no error", err)
+	}
+	rec = do("GET", "/views", "")
+	views = nil
+	if err := json.Unmarshal(rec.Body.Bytes(), &views); err != nil {
+		t.Fatalf("GET /views got invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	found := false
+	for _, vi := range views {
+		if vi.Name == multi.Name() {
+			found = vi.Error != "" && vi.Aggregation.Type == ""
+		}
+	}
+	if !found {
+		t.Errorf("GET /views got %q, want the multi view listed with an error", rec.Body.String())
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package viewconfig loads view definitions from a JSON document and
+// registers them with the stats library. It allows adding or changing views
+// without changing the instrumented code. Only JSON is supported: the YAML
+// documents must be converted to JSON first.
+//
+// A document looks like:
+//
+//	{
+//	  "views": [
+//	    {
+//	      "name": "/my/view",
+//	      "description": "latency distribution over the last minute",
+//	      "measure": "/my/measure",
+//	      "tag_keys": ["method"],
+//	      "aggregation": {"type": "distribution", "bounds": [0, 10, 100]},
+//	      "window": {"type": "sliding_time", "duration": "1m", "sub_intervals": 6}
+//	    }
+//	  ]
+//	}
+//
+// The supported aggregation types are "count", "distribution", "sum" and
+// "mean". The views of a duration measure can set "duration_unit" to one of
+// the units of stats.WithDurationUnit. The supported window types are "cumulative", "sliding_time" (with
+// "duration", "sub_intervals" and optionally "aligned" to align the sub
+// intervals to the wall clock) and "sliding_count" (with "count" and
+// "sub_sets"). The measures must be created before the views referring to
+// them are loaded. The tag keys are created as string keys.
+package viewconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+// Config is the set of views defined in a document.
+type Config struct {
+	Views []*ViewConfig `json:"views"`
+}
+
+// ViewConfig is the definition of a single view.
+type ViewConfig struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Measure     string            `json:"measure"`
+	TagKeys     []string          `json:"tag_keys"`
+	Aggregation AggregationConfig `json:"aggregation"`
+	Window      WindowConfig      `json:"window"`
+	// DurationUnit is the unit the samples of a duration measure are
+	// aggregated in. It defaults to "ms". See stats.WithDurationUnit.
+	DurationUnit string `json:"duration_unit,omitempty"`
+}
+
+// AggregationConfig is the definition of the aggregation of a view.
+type AggregationConfig struct {
+	Type   string    `json:"type"`
+	Bounds []float64 `json:"bounds,omitempty"`
+}
+
+// WindowConfig is the definition of the window of a view. Duration is parsed
+// with time.ParseDuration.
+type WindowConfig struct {
+	Type         string `json:"type"`
+	Duration     string `json:"duration,omitempty"`
+	SubIntervals int    `json:"sub_intervals,omitempty"`
+	Aligned      bool   `json:"aligned,omitempty"`
+	Count        uint64 `json:"count,omitempty"`
+	SubSets      int    `json:"sub_sets,omitempty"`
+}
+
+// Parse parses a JSON document defining views.
+func Parse(data []byte) (*Config, error) {
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("cannot parse views config. %v", err)
+	}
+	return c, nil
+}
+
+// ParseFile parses the JSON document defining views stored in the file at
+// path.
+func ParseFile(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// NewViews creates the views defined by the Config. It returns an error if a
+// definition is invalid or refers to a measure that doesn't exist.
+func (c *Config) NewViews() ([]stats.View, error) {
+	var views []stats.View
+	for _, vc := range c.Views {
+		v, err := vc.NewView()
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, v)
+	}
+	return views, nil
+}
+
+// Register creates and registers the views defined by the Config. Either all
+// the views are registered or none of them is. See stats.RegisterViews.
+func (c *Config) Register() ([]stats.View, error) {
+	views, err := c.NewViews()
+	if err != nil {
+		return nil, err
+	}
+	if err := stats.RegisterViews(views...); err != nil {
+		return nil, err
+	}
+	return views, nil
+}
+
+// NewView creates the view defined by the ViewConfig.
+func (vc *ViewConfig) NewView() (stats.View, error) {
+	if vc.Name == "" {
+		return nil, fmt.Errorf("view with description '%v' has no name", vc.Description)
+	}
+
+	m, err := stats.GetMeasureByName(vc.Measure)
+	if err != nil {
+		return nil, fmt.Errorf("view '%v': %v", vc.Name, err)
+	}
+
+	var keys []tags.Key
+	for _, name := range vc.TagKeys {
+		k, err := tags.CreateKeyString(name)
+		if err != nil {
+			return nil, fmt.Errorf("view '%v': %v", vc.Name, err)
+		}
+		keys = append(keys, k)
+	}
+
+	agg, err := vc.Aggregation.newAggregation()
+	if err != nil {
+		return nil, fmt.Errorf("view '%v': %v", vc.Name, err)
+	}
+
+	wnd, err := vc.Window.newWindow()
+	if err != nil {
+		return nil, fmt.Errorf("view '%v': %v", vc.Name, err)
+	}
+
+	var opts []stats.ViewOption
+	if vc.DurationUnit != "" {
+		if _, ok := m.(*stats.MeasureDuration); !ok {
+			return nil, fmt.Errorf("view '%v': duration_unit is only supported for duration measures", vc.Name)
+		}
+		opts = append(opts, stats.WithDurationUnit(stats.Unit(vc.DurationUnit)))
+	}
+	v := stats.NewView(vc.Name, vc.Description, keys, m, agg, wnd, opts...)
+	if vc.DurationUnit != "" && v.Unit() != stats.Unit(vc.DurationUnit) {
+		return nil, fmt.Errorf("view '%v': unknown duration_unit '%v'", vc.Name, vc.DurationUnit)
+	}
+	return v, nil
+}
+
+// FromView returns the definition of v. Only the primary window of v is
+// described, and the options of v other than the unit of a duration measure
+// aren't. It returns an error if the aggregation or the window of v can't be
+// expressed by a ViewConfig: multi, rate and ratio aggregations, int64
+// distributions and distributions counting the samples out of range.
+func FromView(v stats.View) (*ViewConfig, error) {
+	vc := &ViewConfig{
+		Name:        v.Name(),
+		Description: v.Description(),
+		Measure:     v.Measure().Name(),
+	}
+	for _, k := range v.TagKeys() {
+		vc.TagKeys = append(vc.TagKeys, k.Name())
+	}
+	if _, ok := v.Measure().(*stats.MeasureDuration); ok {
+		vc.DurationUnit = string(v.Unit())
+	}
+
+	switch a := v.Aggregation().(type) {
+	case *stats.AggregationCount:
+		vc.Aggregation.Type = "count"
+	case *stats.AggregationDistribution:
+		if a.IsInt64() || a.Underflow() != stats.OutOfRangeBucket || a.Overflow() != stats.OutOfRangeBucket {
+			return nil, fmt.Errorf("view '%v': cannot describe an int64 distribution or a distribution counting the samples out of range", v.Name())
+		}
+		vc.Aggregation.Type = "distribution"
+		vc.Aggregation.Bounds = a.Bounds()
+	case *stats.AggregationSum:
+		vc.Aggregation.Type = "sum"
+	case *stats.AggregationMean:
+		vc.Aggregation.Type = "mean"
+	default:
+		return nil, fmt.Errorf("view '%v': cannot describe aggregation of type '%T'", v.Name(), a)
+	}
+
+	switch w := v.Window().(type) {
+	case *stats.WindowCumulative:
+		vc.Window.Type = "cumulative"
+	case *stats.WindowSlidingTime:
+		vc.Window.Type = "sliding_time"
+		vc.Window.Duration = w.Duration().String()
+		vc.Window.SubIntervals = w.SubIntervals()
+		vc.Window.Aligned = w.Aligned()
+	case *stats.WindowSlidingCount:
+		vc.Window.Type = "sliding_count"
+		vc.Window.Count = w.Count()
+		vc.Window.SubSets = w.SubSets()
+	default:
+		return nil, fmt.Errorf("view '%v': cannot describe window of type '%T'", v.Name(), w)
+	}
+	return vc, nil
+}
+
+func (ac *AggregationConfig) newAggregation() (stats.Aggregation, error) {
+	switch ac.Type {
+	case "count":
+		return stats.NewAggregationCount(), nil
+	case "distribution":
+		if err := stats.ValidateDistributionBounds(ac.Bounds); err != nil {
+			return nil, err
+		}
+		return stats.NewAggregationDistribution(ac.Bounds), nil
+	case "sum":
+		return stats.NewAggregationSum(), nil
+	case "mean":
+		return stats.NewAggregationMean(), nil
+	default:
+		return nil, fmt.Errorf("unknown aggregation type '%v'", ac.Type)
+	}
+}
+
+func (wc *WindowConfig) newWindow() (stats.Window, error) {
+	switch wc.Type {
+	case "cumulative":
+		return stats.NewWindowCumulative(), nil
+	case "sliding_time":
+		d, err := time.ParseDuration(wc.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sliding_time window duration. %v", err)
+		}
+		if d <= 0 || wc.SubIntervals <= 0 {
+			return nil, fmt.Errorf("sliding_time window requires a positive duration and sub_intervals, got %v and %v", wc.Duration, wc.SubIntervals)
+		}
+		w := stats.NewWindowSlidingTime(d, wc.SubIntervals)
+		if wc.Aligned {
+			w = w.WithWallClockAlignment()
+		}
+		return w, nil
+	case "sliding_count":
+		if wc.Count == 0 || wc.SubSets <= 0 {
+			return nil, fmt.Errorf("sliding_count window requires a positive count and sub_sets, got %v and %v", wc.Count, wc.SubSets)
+		}
+		return stats.NewWindowSlidingCount(wc.Count, wc.SubSets), nil
+	default:
+		return nil, fmt.Errorf("unknown window type '%v'", wc.Type)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package viewconfig
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+)
+
+func TestRegister(t *testing.T) {
+	stats.RestartWorker()
+	if _, err := stats.NewMeasureFloat64("/viewconfig/latency", "latency", "ms"); err != nil {
+		t.Fatalf("NewMeasureFloat64() got error %v, want no error", err)
+	}
+
+	doc := []byte(`{
+  "views": [
+    {
+      "name": "/viewconfig/latency/minute",
+      "description": "latency distribution over the last minute",
+      "measure": "/viewconfig/latency",
+      "tag_keys": ["method"],
+      "aggregation": {"type": "distribution", "bounds": [0, 10, 100]},
+      "window": {"type": "sliding_time", "duration": "1m", "sub_intervals": 6}
+    },
+    {
+      "name": "/viewconfig/latency/count",
+      "measure": "/viewconfig/latency",
+      "aggregation": {"type": "count"},
+      "window": {"type": "cumulative"}
+    }
+  ]
+}`)
+	c, err := Parse(doc)
+	if err != nil {
+		t.Fatalf("Parse() got error %v, want no error", err)
+	}
+	views, err := c.Register()
+	if err != nil {
+		t.Fatalf("Register() got error %v, want no error", err)
+	}
+	if len(views) != 2 {
+		t.Fatalf("Register() got %v views, want 2", len(views))
+	}
+	for _, name := range []string{"/viewconfig/latency/minute", "/viewconfig/latency/count"} {
+		if _, err := stats.GetViewByName(name); err != nil {
+			t.Errorf("GetViewByName(%q) got error %v, want no error", name, err)
+		}
+	}
+	if _, ok := views[0].Window().(*stats.WindowSlidingTime); !ok {
+		t.Errorf("got window %T, want *stats.WindowSlidingTime", views[0].Window())
+	}
+}
+
+func TestNewView_Errors(t *testing.T) {
+	stats.RestartWorker()
+	if _, err := stats.NewMeasureInt64("/viewconfig/count", "count", "1"); err != nil {
+		t.Fatalf("NewMeasureInt64() got error %v, want no error", err)
+	}
+
+	valid := func() *ViewConfig {
+		return &ViewConfig{
+			Name:        "/viewconfig/count/view",
+			Measure:     "/viewconfig/count",
+			Aggregation: AggregationConfig{Type: "count"},
+			Window:      WindowConfig{Type: "cumulative"},
+		}
+	}
+
+	type testCase struct {
+		label  string
+		modify func(vc *ViewConfig)
+	}
+	tcs := []testCase{
+		{"no name", func(vc *ViewConfig) { vc.Name = "" }},
+		{"unknown measure", func(vc *ViewConfig) { vc.Measure = "/unknown" }},
+		{"unknown aggregation", func(vc *ViewConfig) { vc.Aggregation.Type = "median" }},
+		{"unknown window", func(vc *ViewConfig) { vc.Window.Type = "tumbling" }},
+		{"bad duration", func(vc *ViewConfig) {
+			vc.Window = WindowConfig{Type: "sliding_time", Duration: "1 minute", SubIntervals: 6}
+		}},
+		{"no sub sets", func(vc *ViewConfig) { vc.Window = WindowConfig{Type: "sliding_count", Count: 10} }},
+		{"unsorted bounds", func(vc *ViewConfig) {
+			vc.Aggregation = AggregationConfig{Type: "distribution", Bounds: []float64{10, 0}}
+		}},
+		{"duration unit of an int64 measure", func(vc *ViewConfig) { vc.DurationUnit = "s" }},
+	}
+
+	if _, err := valid().NewView(); err != nil {
+		t.Fatalf("NewView() got error %v, want no error", err)
+	}
+	for _, tc := range tcs {
+		vc := valid()
+		tc.modify(vc)
+		if _, err := vc.NewView(); err == nil {
+			t.Errorf("Test case '%v': NewView() got no error, want error", tc.label)
+		}
+	}
+}
+
+func TestFromView(t *testing.T) {
+	stats.RestartWorker()
+	if _, err := stats.NewMeasureFloat64("/viewconfig/size", "size", "By"); err != nil {
+		t.Fatalf("NewMeasureFloat64() got error %v, want no error", err)
+	}
+
+	want := &ViewConfig{
+		Name:        "/viewconfig/size/view",
+		Description: "size distribution",
+		Measure:     "/viewconfig/size",
+		TagKeys:     []string{"method", "status"},
+		Aggregation: AggregationConfig{Type: "distribution", Bounds: []float64{0, 1024}},
+		Window:      WindowConfig{Type: "sliding_time", Duration: "1m0s", SubIntervals: 6, Aligned: true},
+	}
+	v, err := want.NewView()
+	if err != nil {
+		t.Fatalf("NewView() got error %v, want no error", err)
+	}
+	got, err := FromView(v)
+	if err != nil {
+		t.Fatalf("FromView() got error %v, want no error", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromView() got %+v, want %+v", got, want)
+	}
+}
+
+func TestFromView_DurationUnit(t *testing.T) {
+	stats.RestartWorker()
+	if _, err := stats.NewMeasureDuration("/viewconfig/latency", "latency"); err != nil {
+		t.Fatalf("NewMeasureDuration() got error %v, want no error", err)
+	}
+
+	want := &ViewConfig{
+		Name:         "/viewconfig/latency/view",
+		Measure:      "/viewconfig/latency",
+		Aggregation:  AggregationConfig{Type: "distribution", Bounds: []float64{0, 1, 10}},
+		Window:       WindowConfig{Type: "cumulative"},
+		DurationUnit: "s",
+	}
+	v, err := want.NewView()
+	if err != nil {
+		t.Fatalf("NewView() got error %v, want no error", err)
+	}
+	if v.Unit() != "s" {
+		t.Errorf("NewView() got a view of unit %q, want \"s\"", v.Unit())
+	}
+	got, err := FromView(v)
+	if err != nil {
+		t.Fatalf("FromView() got error %v, want no error", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromView() got %+v, want %+v", got, want)
+	}
+
+	want.DurationUnit = "fortnight"
+	if _, err := want.NewView(); err == nil {
+		t.Error("NewView() got no error, want error for an unknown duration unit")
+	}
+}
+
+func TestFromView_Errors(t *testing.T) {
+	stats.RestartWorker()
+	m, err := stats.NewMeasureInt64("/viewconfig/requests", "requests", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64() got error %v, want no error", err)
+	}
+	count := stats.NewView("/viewconfig/requests/count", "", nil, m, stats.NewAggregationCount(), stats.NewWindowCumulative())
+	newView := func(agg stats.Aggregation, opts ...stats.ViewOption) stats.View {
+		return stats.NewView("/viewconfig/requests/view", "", nil, m, agg, stats.NewWindowCumulative(), opts...)
+	}
+
+	type testCase struct {
+		label string
+		v     stats.View
+	}
+	tcs := []testCase{
+		{"multi", newView(stats.NewAggregationMulti(stats.NewAggregationCount(), stats.NewAggregationSum()))},
+		{"int64 distribution", newView(stats.NewAggregationDistributionInt64([]int64{0, 10}))},
+		{"counted out of range", newView(stats.NewAggregationDistribution([]float64{0, 10}), stats.WithOutOfRange(stats.OutOfRangeCount, stats.OutOfRangeBucket))},
+		{"rate", stats.NewRateView("/viewconfig/requests/rate", "", count)},
+		{"ratio", stats.NewRatioView("/viewconfig/requests/ratio", "", nil, count, count)},
+	}
+	for _, tc := range tcs {
+		if vc, err := FromView(tc.v); err == nil {
+			t.Errorf("Test case '%v': FromView() got %+v, want error", tc.label, vc)
+		}
+	}
+}
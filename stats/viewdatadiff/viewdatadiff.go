@@ -0,0 +1,130 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package viewdatadiff computes the changes between successive ViewData of a
+// cumulative view. It is meant for the exporters to backends expecting deltas
+// (e.g. statsd), which need to know which series appeared and disappeared.
+package viewdatadiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+// Kind is the kind of change of a row.
+type Kind int
+
+const (
+	// Updated indicates that the row is in both ViewData. Delta holds the data
+	// aggregated in between.
+	Updated Kind = iota
+	// Added indicates that the row is only in the current ViewData. Delta
+	// holds its data.
+	Added
+	// Removed indicates that the row is only in the previous ViewData, e.g.
+	// because it was dropped by the view. Delta is nil.
+	Removed
+	// Reset indicates that the row is in both ViewData but its data doesn't
+	// follow the previous data, e.g. because the current ViewData is marked
+	// as Reset or the row restarted with another Start. Delta holds the
+	// current data of the row.
+	Reset
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Updated:
+		return "updated"
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Reset:
+		return "reset"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// RowDelta is the change of the row with the tags Tags.
+type RowDelta struct {
+	Tags  []tags.Tag
+	Kind  Kind
+	Delta stats.AggregationValue
+}
+
+// Diff returns the changes of the rows from prev to cur, which must be
+// successive ViewData of the same view. prev is nil for the first ViewData of
+// the view, in which case all its rows are added. The changes of the rows of
+// cur come first, in the order of cur, then the removed rows in the order of
+// prev.
+func Diff(prev, cur *stats.ViewData) ([]*RowDelta, error) {
+	if prev == nil {
+		prev = &stats.ViewData{V: cur.V}
+	}
+	if prev.V != cur.V {
+		return nil, fmt.Errorf("cannot diff the data of view '%v' with the data of view '%v'", cur.V.Name(), prev.V.Name())
+	}
+
+	prevRows := make(map[string]*stats.Row, len(prev.Rows))
+	for _, r := range prev.Rows {
+		prevRows[rowKey(r.Tags)] = r
+	}
+
+	var deltas []*RowDelta
+	seen := make(map[string]bool, len(cur.Rows))
+	for _, r := range cur.Rows {
+		k := rowKey(r.Tags)
+		seen[k] = true
+		p, ok := prevRows[k]
+		if !ok {
+			deltas = append(deltas, &RowDelta{r.Tags, Added, r.AggregationValue})
+			continue
+		}
+		if cur.Reset || !r.Start.Equal(p.Start) {
+			deltas = append(deltas, &RowDelta{r.Tags, Reset, r.AggregationValue})
+			continue
+		}
+		d, err := stats.AggregationValueDelta(r.AggregationValue, p.AggregationValue)
+		if err != nil {
+			deltas = append(deltas, &RowDelta{r.Tags, Reset, r.AggregationValue})
+			continue
+		}
+		deltas = append(deltas, &RowDelta{r.Tags, Updated, d})
+	}
+	for _, r := range prev.Rows {
+		if !seen[rowKey(r.Tags)] {
+			deltas = append(deltas, &RowDelta{r.Tags, Removed, nil})
+		}
+	}
+	return deltas, nil
+}
+
+// rowKey returns a string identifying the tags ts. The tags of the rows are
+// ordered by key name.
+func rowKey(ts []tags.Tag) string {
+	var buf bytes.Buffer
+	var n [binary.MaxVarintLen64]byte
+	for _, t := range ts {
+		buf.Write(n[:binary.PutUvarint(n[:], uint64(len(t.K.Name())))])
+		buf.WriteString(t.K.Name())
+		buf.Write(n[:binary.PutUvarint(n[:], uint64(len(t.V)))])
+		buf.Write(t.V)
+	}
+	return buf.String()
+}
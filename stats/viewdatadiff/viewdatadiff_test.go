@@ -0,0 +1,146 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package viewdatadiff
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"github.com/census-instrumentation/opencensus-go/tags"
+)
+
+func TestDiff(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	m, _ := stats.NewMeasureFloat64("/viewdatadiff/m", "desc", "1")
+	v := stats.NewView("/viewdatadiff/v", "desc", []tags.Key{k1}, m, stats.NewAggregationCount(), stats.NewWindowCumulative())
+	a := []tags.Tag{{K: k1, V: []byte("a")}}
+	b := []tags.Tag{{K: k1, V: []byte("b")}}
+	c := []tags.Tag{{K: k1, V: []byte("c")}}
+	count := stats.NewTestingAggregationCountValue
+	// the distributions of the samples {1, 3} and {1, 3, 5, 7}, whose delta
+	// is the distribution of {5, 7}.
+	dist1 := stats.NewDoNotUseTestingAggregationDistributionValue([]float64{4}, []int64{2, 0}, 2, 1, 3, 2, 2)
+	dist2 := stats.NewDoNotUseTestingAggregationDistributionValue([]float64{4}, []int64{2, 2}, 4, 1, 7, 4, 20)
+	distDelta := stats.NewDoNotUseTestingAggregationDistributionValue([]float64{4}, []int64{0, 2}, 2, 1, 7, 6, 2)
+
+	type testCase struct {
+		label     string
+		prev, cur []*stats.Row
+		want      []*RowDelta
+	}
+	tcs := []testCase{
+		{
+			"first ViewData",
+			nil,
+			[]*stats.Row{{Tags: a, AggregationValue: count(2)}},
+			[]*RowDelta{{a, Added, count(2)}},
+		},
+		{
+			"updated, added and removed rows",
+			[]*stats.Row{{Tags: a, AggregationValue: count(2)}, {Tags: b, AggregationValue: count(3)}},
+			[]*stats.Row{{Tags: a, AggregationValue: count(5)}, {Tags: c, AggregationValue: count(1)}},
+			[]*RowDelta{{a, Updated, count(3)}, {c, Added, count(1)}, {b, Removed, nil}},
+		},
+		{
+			"reset row",
+			[]*stats.Row{{Tags: a, AggregationValue: count(5)}},
+			[]*stats.Row{{Tags: a, AggregationValue: count(2)}},
+			[]*RowDelta{{a, Reset, count(2)}},
+		},
+		{
+			// the row was reset and grew back past its previous count.
+			"restarted row",
+			[]*stats.Row{{Tags: a, AggregationValue: count(2), Start: time.Unix(100, 0)}},
+			[]*stats.Row{{Tags: a, AggregationValue: count(3), Start: time.Unix(150, 0)}},
+			[]*RowDelta{{a, Reset, count(3)}},
+		},
+		{
+			"distribution",
+			[]*stats.Row{{Tags: a, AggregationValue: dist1}},
+			[]*stats.Row{{Tags: a, AggregationValue: dist2}},
+			[]*RowDelta{{a, Updated, distDelta}},
+		},
+	}
+	for _, tc := range tcs {
+		var prev *stats.ViewData
+		if tc.prev != nil {
+			prev = &stats.ViewData{V: v, Rows: tc.prev}
+		}
+		got, err := Diff(prev, &stats.ViewData{V: v, Rows: tc.cur})
+		if err != nil {
+			t.Fatalf("%v: Diff got error '%v', want no error", tc.label, err)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("%v: Diff got %v deltas, want %v", tc.label, len(got), len(tc.want))
+		}
+		for i, d := range got {
+			w := tc.want[i]
+			if d.Kind != w.Kind || !reflect.DeepEqual(d.Tags, w.Tags) {
+				t.Errorf("%v: delta #%v got %v %v, want %v %v", tc.label, i, d.Kind, d.Tags, w.Kind, w.Tags)
+				continue
+			}
+			if d.Kind == Removed {
+				if d.Delta != nil {
+					t.Errorf("%v: delta #%v of a removed row got %v, want nil", tc.label, i, d.Delta)
+				}
+				continue
+			}
+			if !(&stats.Row{Tags: d.Tags, AggregationValue: d.Delta}).Equal(&stats.Row{Tags: w.Tags, AggregationValue: w.Delta}) {
+				t.Errorf("%v: delta #%v got %v %v %v, want %v %v %v", tc.label, i, d.Kind, d.Tags, d.Delta, w.Kind, w.Tags, w.Delta)
+			}
+		}
+	}
+}
+
+func TestDiff_ResetViewData(t *testing.T) {
+	k1, _ := tags.CreateKeyString("k1")
+	m, _ := stats.NewMeasureFloat64("/viewdatadiff/m3", "desc", "1")
+	v := stats.NewView("/viewdatadiff/v3", "desc", []tags.Key{k1}, m, stats.NewAggregationCount(), stats.NewWindowCumulative())
+	a := []tags.Tag{{K: k1, V: []byte("a")}}
+	b := []tags.Tag{{K: k1, V: []byte("b")}}
+
+	// the data grew back past the previous data after a reset: it must not
+	// be read as an update.
+	prev := &stats.ViewData{V: v, Rows: []*stats.Row{{Tags: a, AggregationValue: stats.NewTestingAggregationCountValue(2)}}}
+	cur := &stats.ViewData{V: v, Reset: true, Rows: []*stats.Row{
+		{Tags: a, AggregationValue: stats.NewTestingAggregationCountValue(3)},
+		{Tags: b, AggregationValue: stats.NewTestingAggregationCountValue(1)},
+	}}
+	got, err := Diff(prev, cur)
+	if err != nil {
+		t.Fatalf("Diff got error '%v', want no error", err)
+	}
+	want := []Kind{Reset, Added}
+	if len(got) != len(want) {
+		t.Fatalf("Diff got %v deltas, want %v", len(got), len(want))
+	}
+	for i, d := range got {
+		if d.Kind != want[i] || d.Delta != cur.Rows[i].AggregationValue {
+			t.Errorf("delta #%v got %v %v, want %v %v", i, d.Kind, d.Delta, want[i], cur.Rows[i].AggregationValue)
+		}
+	}
+}
+
+func TestDiff_DifferentViews(t *testing.T) {
+	m, _ := stats.NewMeasureFloat64("/viewdatadiff/m2", "desc", "1")
+	v1 := stats.NewView("/viewdatadiff/v1", "desc", nil, m, stats.NewAggregationCount(), stats.NewWindowCumulative())
+	v2 := stats.NewView("/viewdatadiff/v2", "desc", nil, m, stats.NewAggregationCount(), stats.NewWindowCumulative())
+	if _, err := Diff(&stats.ViewData{V: v1}, &stats.ViewData{V: v2}); err == nil {
+		t.Errorf("Diff of the data of different views got no error, want error")
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package viewdatamux fans the ViewData of several views into a single
+// channel, and dispatches the ViewData of a channel to handlers per view. It
+// replaces the goroutine per view the exporters otherwise run around
+// stats.SubscribeToView.
+package viewdatamux
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+)
+
+// Mux subscribes a single channel to several views. The ViewData of the views
+// are identified by their field V. It is safe for concurrent use.
+type Mux struct {
+	subscribe   func(stats.View, chan *stats.ViewData, ...stats.SubscriptionOption) error
+	unsubscribe func(stats.View, chan *stats.ViewData) error
+	c           chan *stats.ViewData
+
+	mu    sync.Mutex
+	views map[stats.View]bool
+}
+
+// NewMux returns a Mux delivering the ViewData of the views of the default
+// registry to c.
+func NewMux(c chan *stats.ViewData) *Mux {
+	return &Mux{
+		subscribe:   stats.SubscribeToView,
+		unsubscribe: stats.UnsubscribeFromView,
+		c:           c,
+		views:       make(map[stats.View]bool),
+	}
+}
+
+// NewMuxForRegistry is like NewMux for the views of the registry r.
+func NewMuxForRegistry(r *stats.Registry, c chan *stats.ViewData) *Mux {
+	return &Mux{
+		subscribe:   r.SubscribeToView,
+		unsubscribe: r.UnsubscribeFromView,
+		c:           c,
+		views:       make(map[stats.View]bool),
+	}
+}
+
+// C returns the channel the ViewData are delivered to.
+func (m *Mux) C() <-chan *stats.ViewData {
+	return m.c
+}
+
+// Subscribe subscribes the channel of the Mux to v with the options opts.
+func (m *Mux) Subscribe(v stats.View, opts ...stats.SubscriptionOption) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.subscribe(v, m.c, opts...); err != nil {
+		return err
+	}
+	m.views[v] = true
+	return nil
+}
+
+// SubscribeAll subscribes the channel of the Mux to all the views vs or none
+// of them. If a subscription fails, the views subscribed by the call are
+// unsubscribed and the error is returned.
+func (m *Mux) SubscribeAll(vs ...stats.View) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var subscribed []stats.View
+	for _, v := range vs {
+		if m.views[v] {
+			continue
+		}
+		if err := m.subscribe(v, m.c); err != nil {
+			for _, s := range subscribed {
+				m.unsubscribe(s, m.c)
+				delete(m.views, s)
+			}
+			return err
+		}
+		m.views[v] = true
+		subscribed = append(subscribed, v)
+	}
+	return nil
+}
+
+// Unsubscribe unsubscribes the channel of the Mux from v.
+func (m *Mux) Unsubscribe(v stats.View) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.unsubscribe(v, m.c); err != nil {
+		return err
+	}
+	delete(m.views, v)
+	return nil
+}
+
+// Views returns the views the Mux is subscribed to, sorted by name.
+func (m *Mux) Views() []stats.View {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var vs []stats.View
+	for v := range m.views {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i].Name() < vs[j].Name() })
+	return vs
+}
+
+// Close unsubscribes the channel of the Mux from all its views. It returns the
+// first error, after attempting to unsubscribe from all the views.
+func (m *Mux) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var first error
+	for v := range m.views {
+		if err := m.unsubscribe(v, m.c); err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		delete(m.views, v)
+	}
+	return first
+}
+
+// Demux dispatches ViewData to handlers per view. It is safe for concurrent
+// use.
+type Demux struct {
+	mu       sync.RWMutex
+	handlers map[stats.View]func(*stats.ViewData)
+	fallback func(*stats.ViewData)
+}
+
+// NewDemux returns a Demux without handlers.
+func NewDemux() *Demux {
+	return &Demux{
+		handlers: make(map[stats.View]func(*stats.ViewData)),
+	}
+}
+
+// HandleFunc registers f as the handler of the ViewData of v, replacing the
+// previous handler of v. A nil f removes the handler of v.
+func (d *Demux) HandleFunc(v stats.View, f func(*stats.ViewData)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if f == nil {
+		delete(d.handlers, v)
+		return
+	}
+	d.handlers[v] = f
+}
+
+// Handle registers a handler sending the ViewData of v to c. The handler
+// blocks until c is ready to receive.
+func (d *Demux) Handle(v stats.View, c chan<- *stats.ViewData) {
+	d.HandleFunc(v, func(vd *stats.ViewData) { c <- vd })
+}
+
+// HandleOther registers f as the handler of the ViewData of the views without
+// handler. They are dropped if f is nil.
+func (d *Demux) HandleOther(f func(*stats.ViewData)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fallback = f
+}
+
+// Dispatch calls the handler of the view of vd. It returns false if vd is
+// dropped because no handler is registered for its view. If the handler
+// panics, the panic is reported to stats.HandleError as an error of kind
+// stats.ErrPanic, so that Run keeps dispatching the ViewData of the other
+// views.
+func (d *Demux) Dispatch(vd *stats.ViewData) (handled bool) {
+	d.mu.RLock()
+	f, ok := d.handlers[vd.V]
+	if !ok {
+		f = d.fallback
+	}
+	d.mu.RUnlock()
+	if f == nil {
+		return false
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			stats.HandleError(fmt.Errorf("%w: the handler of view '%v' panicked: %v", stats.ErrPanic, vd.V.Name(), p))
+			handled = true
+		}
+	}()
+	f(vd)
+	return true
+}
+
+// Run dispatches the ViewData received from c until c is closed.
+func (d *Demux) Run(c <-chan *stats.ViewData) {
+	for vd := range c {
+		d.Dispatch(vd)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package viewdatamux
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+	"golang.org/x/net/context"
+)
+
+func TestMuxDemux(t *testing.T) {
+	e := stats.NewTestEnvironment()
+	defer e.Close()
+	e.SetReportingPeriod(time.Second)
+
+	m, err := e.NewMeasureInt64("/viewdatamux/m", "desc", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64() got error %v, want no error", err)
+	}
+	v1 := stats.NewView("/viewdatamux/v1", "desc", nil, m, stats.NewAggregationCount(), stats.NewWindowCumulative())
+	v2 := stats.NewView("/viewdatamux/v2", "desc", nil, m, stats.NewAggregationCount(), stats.NewWindowCumulative())
+	invalid := stats.NewView("/viewdatamux/invalid", "desc", nil, nil, stats.NewAggregationCount(), stats.NewWindowCumulative())
+
+	mux := NewMuxForRegistry(e.Registry, make(chan *stats.ViewData, 2))
+	if err := mux.SubscribeAll(v1, v2, invalid); err == nil {
+		t.Fatalf("SubscribeAll() with a view without measure got no error, want error")
+	}
+	if got := mux.Views(); len(got) != 0 {
+		t.Fatalf("Views() after a failed SubscribeAll() got %v, want none", got)
+	}
+	if err := mux.SubscribeAll(v1, v2); err != nil {
+		t.Fatalf("SubscribeAll() got error %v, want no error", err)
+	}
+	stats.RecordInt64(context.Background(), m, 1)
+	e.Advance(time.Second)
+
+	c1 := make(chan *stats.ViewData, 1)
+	var others []*stats.ViewData
+	d := NewDemux()
+	d.Handle(v1, c1)
+	d.HandleOther(func(vd *stats.ViewData) { others = append(others, vd) })
+	for i := 0; i < 2; i++ {
+		d.Dispatch(<-mux.C())
+	}
+	if vd := <-c1; vd.V != v1 {
+		t.Errorf("Demux delivered the ViewData of %v to the channel of %v", vd.V.Name(), v1.Name())
+	}
+	if len(others) != 1 || others[0].V != v2 {
+		t.Errorf("Demux delivered %v to the other handler, want the ViewData of %v", others, v2.Name())
+	}
+
+	if err := mux.Close(); err != nil {
+		t.Fatalf("Close() got error %v, want no error", err)
+	}
+	if e.IsCollecting(v1) || e.IsCollecting(v2) {
+		t.Errorf("views still collecting after Close(), want the Mux unsubscribed")
+	}
+	d.HandleOther(nil)
+	if d.Dispatch(&stats.ViewData{V: v2}) {
+		t.Errorf("Dispatch() without handler got true, want false")
+	}
+}
+
+func TestDemuxPanic(t *testing.T) {
+	var errs []error
+	stats.SetErrorHandler(func(err error) { errs = append(errs, err) })
+	defer stats.SetErrorHandler(nil)
+
+	v := stats.NewView("/viewdatamux/panic", "desc", nil, nil, stats.NewAggregationCount(), stats.NewWindowCumulative())
+	d := NewDemux()
+	d.HandleFunc(v, func(*stats.ViewData) { panic("handler") })
+	if !d.Dispatch(&stats.ViewData{V: v}) {
+		t.Errorf("Dispatch() with a panicking handler got false, want true")
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], stats.ErrPanic) {
+		t.Errorf("error handler got %v, want an ErrPanic", errs)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package views collects the default view sets of the plugins, so that a
+// service subscribes its exporter to all of them in one call:
+//
+//	if err := views.SubscribeAllDefault(exporter); err != nil {
+//		// handle error
+//	}
+//
+// The plugins add their default views with RegisterSet when they are
+// initialized, e.g. the gRPC plugin adds the sets "grpc/client" and
+// "grpc/server".
+package views
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+)
+
+// Subscriber is the interface of the exporters the default views are
+// subscribed to.
+type Subscriber interface {
+	Subscribe(v stats.View) error
+}
+
+var (
+	mu   sync.Mutex
+	sets = make(map[string][]stats.View)
+)
+
+// RegisterSet adds the views vs to the default view set name. It is meant to
+// be called by the plugins when they are initialized. The views are
+// registered with the default registry by SubscribeAllDefault.
+func RegisterSet(name string, vs ...stats.View) {
+	mu.Lock()
+	defer mu.Unlock()
+	sets[name] = append(sets[name], vs...)
+}
+
+// Sets returns the names of the default view sets, sorted.
+func Sets() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	var names []string
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set returns the views of the default view set name.
+func Set(name string) []stats.View {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]stats.View(nil), sets[name]...)
+}
+
+// Option customizes SubscribeAllDefault.
+type Option func(excluded map[string]bool)
+
+// Except excludes the view sets or the views named names from
+// SubscribeAllDefault.
+func Except(names ...string) Option {
+	return func(excluded map[string]bool) {
+		for _, name := range names {
+			excluded[name] = true
+		}
+	}
+}
+
+// SubscribeAllDefault registers the views of all the default view sets with
+// the default registry and subscribes s to them, except the sets and views
+// excluded with Except. It subscribes s to as many views as possible, and
+// returns a stats.MultiError listing the views that failed.
+func SubscribeAllDefault(s Subscriber, opts ...Option) error {
+	excluded := make(map[string]bool)
+	for _, opt := range opts {
+		opt(excluded)
+	}
+	var errs stats.MultiError
+	for _, name := range Sets() {
+		if excluded[name] {
+			continue
+		}
+		for _, v := range Set(name) {
+			if excluded[v.Name()] {
+				continue
+			}
+			if err := stats.RegisterView(v); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err := s.Subscribe(v); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
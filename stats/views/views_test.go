@@ -0,0 +1,79 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package views
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-go/stats"
+)
+
+type subscriber struct {
+	names []string
+}
+
+func (s *subscriber) Subscribe(v stats.View) error {
+	if v.Name() == "/views/failing" {
+		return errors.New("cannot subscribe")
+	}
+	s.names = append(s.names, v.Name())
+	return nil
+}
+
+func TestSubscribeAllDefault(t *testing.T) {
+	m, err := stats.NewMeasureInt64("/views/m", "desc", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64() got error %v, want no error", err)
+	}
+	newView := func(name string) stats.View {
+		return stats.NewView(name, "desc", nil, m, stats.NewAggregationCount(), stats.NewWindowCumulative())
+	}
+	// the sets and the views are global: they are removed so that the test
+	// can run again in the same process.
+	defer func() {
+		for _, name := range []string{"views/a", "views/b", "views/c", "views/d"} {
+			for _, v := range Set(name) {
+				stats.UnregisterView(v)
+			}
+			mu.Lock()
+			delete(sets, name)
+			mu.Unlock()
+		}
+		stats.DeleteMeasure(m)
+	}()
+	RegisterSet("views/a", newView("/views/a1"), newView("/views/a2"))
+	RegisterSet("views/b", newView("/views/b1"))
+	RegisterSet("views/c", newView("/views/c1"))
+
+	s := &subscriber{}
+	if err := SubscribeAllDefault(s, Except("views/c", "/views/a2")); err != nil {
+		t.Fatalf("SubscribeAllDefault() got error %v, want no error", err)
+	}
+	want := []string{"/views/a1", "/views/b1"}
+	if len(s.names) != len(want) || s.names[0] != want[0] || s.names[1] != want[1] {
+		t.Errorf("SubscribeAllDefault() subscribed to %v, want %v", s.names, want)
+	}
+	if _, err := stats.GetViewByName("/views/a1"); err != nil {
+		t.Errorf("GetViewByName() got error %v, want the view registered", err)
+	}
+
+	RegisterSet("views/d", newView("/views/failing"))
+	err = SubscribeAllDefault(&subscriber{}, Except("views/a", "views/b", "views/c"))
+	if errs, ok := err.(stats.MultiError); !ok || len(errs) != 1 {
+		t.Errorf("SubscribeAllDefault() got error %v, want one failure", err)
+	}
+}
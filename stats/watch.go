@@ -0,0 +1,53 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+// Watcher is a predicate evaluated on the rows of a view at each collection,
+// with a callback called for the rows satisfying it. See Watch.
+type Watcher struct {
+	v         View
+	predicate func(*Row) bool
+	cb        func(*Row)
+}
+
+// View returns the view watched.
+func (wr *Watcher) View() View {
+	return wr.v
+}
+
+// evaluate calls the callback of wr for the rows of vd satisfying its
+// predicate. The callback is called through w.call, so that it can call the
+// functions of the registry. The panics of the predicate and of the callback
+// are reported to the error handler of w, a row whose predicate panicked
+// being considered not satisfying it.
+func (wr *Watcher) evaluate(w *worker, vd *ViewData) {
+	var matched []*Row
+	for _, r := range vd.Rows {
+		var ok bool
+		w.protect(func() { ok = wr.predicate(r) }, "the predicate of a watcher of view '%v'", wr.v.Name())
+		if ok {
+			matched = append(matched, r)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+	w.call(func() {
+		for _, r := range matched {
+			w.protect(func() { wr.cb(r) }, "the callback of a watcher of view '%v'", wr.v.Name())
+		}
+	})
+}
@@ -44,6 +44,7 @@ func (w *WindowCumulative) newAggregator(now time.Time, aggregationValueConstruc
 type WindowSlidingTime struct {
 	duration     time.Duration
 	subIntervals int
+	aligned      bool
 }
 
 // NewWindowSlidingTime creates a new aggregation window of type sliding time
@@ -55,10 +56,31 @@ func NewWindowSlidingTime(duration time.Duration, subIntervals int) *WindowSlidi
 	}
 }
 
+// Duration returns the duration of the sliding time window.
+func (w *WindowSlidingTime) Duration() time.Duration { return w.duration }
+
+// SubIntervals returns the number of sub intervals the window is split into.
+func (w *WindowSlidingTime) SubIntervals() int { return w.subIntervals }
+
+// WithWallClockAlignment returns a copy of w whose sub intervals are aligned
+// to the wall clock: their boundaries are multiples of their duration, e.g.
+// minute boundaries for NewWindowSlidingTime(time.Hour, 60), instead of
+// depending on the time the collection started. It lines up the data of many
+// hosts aggregated downstream.
+func (w *WindowSlidingTime) WithWallClockAlignment() *WindowSlidingTime {
+	aligned := *w
+	aligned.aligned = true
+	return &aligned
+}
+
+// Aligned returns true if the sub intervals of the window are aligned to the
+// wall clock. See WithWallClockAlignment.
+func (w *WindowSlidingTime) Aligned() bool { return w.aligned }
+
 func (w *WindowSlidingTime) isWindow() bool { return true }
 
 func (w *WindowSlidingTime) newAggregator(now time.Time, aggregationValueConstructor func() AggregationValue) aggregator {
-	return newAggregatorSlidingTime(now, w.duration, w.subIntervals, aggregationValueConstructor)
+	return newAggregatorSlidingTime(now, w.duration, w.subIntervals, w.aligned, aggregationValueConstructor)
 }
 
 // WindowSlidingCount indicates that the aggregation occurs over a sliding
@@ -77,6 +99,12 @@ func NewWindowSlidingCount(count uint64, subSets int) *WindowSlidingCount {
 	}
 }
 
+// Count returns the number of samples of the sliding count window.
+func (w *WindowSlidingCount) Count() uint64 { return w.n }
+
+// SubSets returns the number of sub sets the window is split into.
+func (w *WindowSlidingCount) SubSets() int { return w.subSets }
+
 func (w *WindowSlidingCount) isWindow() bool { return true }
 
 func (w *WindowSlidingCount) newAggregator(now time.Time, aggregationValueConstructor func() AggregationValue) aggregator {
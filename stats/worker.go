@@ -16,10 +16,11 @@
 package stats
 
 import (
-	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
+	"github.com/census-instrumentation/opencensus-go/resource"
 	"github.com/census-instrumentation/opencensus-go/tags"
 	"golang.org/x/net/context"
 )
@@ -29,305 +30,650 @@ type worker struct {
 	measures       map[Measure]bool
 	viewsByName    map[string]View
 	views          map[View]bool
-
-	timer      *time.Ticker
+	// viewAliases maps the aliases of the registered views to the views.
+	viewAliases map[string]View
+	// matching are the subscriptions to the views whose name matches a
+	// pattern. See SubscribeMatching.
+	matching []*matchingSubscription
+	// readResets are the reset epochs of the views when they were last read
+	// by ReadAll. The metrics handlers hold their own.
+	readResets map[View]uint64
+
+	timer      Ticker
+	period     time.Duration
 	c          chan command
+	ctl        chan command
 	quit, done chan bool
-}
 
-var defaultWorker *worker
+	// deferred are the commands received while the data was being reported,
+	// to handle once the report completes. See handleReentrant.
+	deferred []command
+
+	// r is the registry the worker serves. It is nil for the workers created
+	// directly by the tests.
+	r *Registry
+
+	// stopped is true once Shutdown was called. The worker keeps serving the
+	// commands so that callers never block, but it doesn't report data
+	// anymore and drops the measurements.
+	stopped bool
+
+	// tagSets interns the TagSets of the measurements.
+	tagSets *tagSetCache
+
+	// batch holds the commands drained at once when the channel backs up,
+	// and recordGroups and recordGroupsOrder the records of the batch
+	// coalesced by measure and tags. See handleBatch.
+	batch             []command
+	recordGroups      map[recordGroupKey]*recordGroup
+	recordGroupsOrder []*recordGroup
+	// coalesced is the number of records applied together with an earlier
+	// record of their group.
+	coalesced uint64
+
+	// shards are the goroutines adding the samples to the views when they
+	// are sharded, shardOf the shard owning each registered view, and
+	// barrier the channel the shards acknowledge the synchronizations on.
+	// See SetShards.
+	shards  []*shard
+	shardOf map[View]int
+	barrier chan bool
+
+	// recorders are the recorders created for the measures of the worker.
+	recorders map[*recorder]bool
+
+	// forcedExpiries are the views whose forced collection expires. See
+	// ForceCollectionFor.
+	forcedExpiries map[View]bool
+
+	// hooks intercept the measurements before they are sent to the worker.
+	// See RegisterRecordHook.
+	hooks recordHooks
+
+	// errorHandler holds the ErrorHandler of the worker. It is read by the
+	// goroutines recording measurements too. See SetErrorHandler.
+	errorHandler atomic.Value // errorHandlerHolder
+
+	// clock holds the Clock of the worker. It is read by the goroutines
+	// recording measurements too. See SetClock.
+	clock atomic.Value // clockHolder
+
+	// resource is the resource attached to the ViewData. See SetResource.
+	resource *resource.Resource
+
+	// health reports the health of the worker once EnableHealthViews is
+	// called. It is nil otherwise.
+	health *health
+
+	// delivered holds the ViewData delivered during the last reporting when
+	// the package is built with the censusaudit build tag.
+	delivered []*auditedViewData
+}
 
 var defaultReportingDuration = 10 * time.Second
 
-// NewMeasureFloat64 creates a new measure of type MeasureFloat64. It returns
-// an error if a measure with the same name already exists.
-func NewMeasureFloat64(name, description, unit string) (*MeasureFloat64, error) {
-	m := &MeasureFloat64{
-		name:        name,
-		description: description,
-		unit:        unit,
-		views:       make(map[View]bool),
-	}
+// NewMeasureFloat64 is like Registry.NewMeasureFloat64 for the default registry.
+func NewMeasureFloat64(name, description string, unit Unit) (*MeasureFloat64, error) {
+	return defaultRegistry.NewMeasureFloat64(name, description, unit)
+}
 
-	req := &registerMeasureReq{
-		m:   m,
-		err: make(chan error),
-	}
-	defaultWorker.c <- req
-	if err := <-req.err; err != nil {
-		return nil, err
-	}
+// NewMeasureInt64 is like Registry.NewMeasureInt64 for the default registry.
+func NewMeasureInt64(name, description string, unit Unit) (*MeasureInt64, error) {
+	return defaultRegistry.NewMeasureInt64(name, description, unit)
+}
 
-	return m, nil
+// NewMeasureDuration is like Registry.NewMeasureDuration for the default registry.
+func NewMeasureDuration(name, description string) (*MeasureDuration, error) {
+	return defaultRegistry.NewMeasureDuration(name, description)
 }
 
-// NewMeasureInt64 creates a new measure of type MeasureInt64. It returns an
-// error if a measure with the same name already exists.
-func NewMeasureInt64(name, description, unit string) (*MeasureInt64, error) {
-	m := &MeasureInt64{
-		name:        name,
-		description: description,
-		unit:        unit,
-		views:       make(map[View]bool),
-	}
+// GetOrCreateMeasureFloat64 is like Registry.GetOrCreateMeasureFloat64 for
+// the default registry.
+func GetOrCreateMeasureFloat64(name, description string, unit Unit) (*MeasureFloat64, error) {
+	return defaultRegistry.GetOrCreateMeasureFloat64(name, description, unit)
+}
 
-	req := &registerMeasureReq{
-		m:   m,
-		err: make(chan error),
-	}
-	defaultWorker.c <- req
-	if err := <-req.err; err != nil {
-		return nil, err
-	}
+// GetOrCreateMeasureInt64 is like Registry.GetOrCreateMeasureInt64 for the
+// default registry.
+func GetOrCreateMeasureInt64(name, description string, unit Unit) (*MeasureInt64, error) {
+	return defaultRegistry.GetOrCreateMeasureInt64(name, description, unit)
+}
 
-	return m, nil
+// GetOrCreateMeasureDuration is like Registry.GetOrCreateMeasureDuration for
+// the default registry.
+func GetOrCreateMeasureDuration(name, description string) (*MeasureDuration, error) {
+	return defaultRegistry.GetOrCreateMeasureDuration(name, description)
 }
 
-// GetMeasureByName returns the registered measure associated with name.
+// GetMeasureByName is like Registry.GetMeasureByName for the default registry.
 func GetMeasureByName(name string) (Measure, error) {
-	req := &getMeasureByNameReq{
-		name: name,
-		c:    make(chan *getMeasureByNameResp),
-	}
-	defaultWorker.c <- req
-	resp := <-req.c
-	return resp.m, resp.err
+	return defaultRegistry.GetMeasureByName(name)
 }
 
-// DeleteMeasure deletes an existing measure to allow for creation of a new
-// measure with the same name. It returns an error if the measure cannot be
-// deleted (if one or multiple registered views refer to it).
+// DeleteMeasure is like Registry.DeleteMeasure for the default registry.
 func DeleteMeasure(m Measure) error {
-	req := &deleteMeasureReq{
-		m:   m,
-		err: make(chan error),
-	}
-	defaultWorker.c <- req
-	return <-req.err
+	return defaultRegistry.DeleteMeasure(m)
 }
 
-// GetViewByName returns the registered view associated with this name.
+// GetViewByName is like Registry.GetViewByName for the default registry.
 func GetViewByName(name string) (View, error) {
-	req := &getViewByNameReq{
-		name: name,
-		c:    make(chan *getViewByNameResp),
-	}
-	defaultWorker.c <- req
-	resp := <-req.c
-	return resp.v, resp.err
+	return defaultRegistry.GetViewByName(name)
+}
+
+// Measures is like Registry.Measures for the default registry.
+func Measures() []Measure {
+	return defaultRegistry.Measures()
+}
+
+// Views is like Registry.Views for the default registry.
+func Views() []View {
+	return defaultRegistry.Views()
 }
 
-// RegisterView registers view. It returns an error if the view cannot be
-// registered. Subsequent calls to Record with the same measure as the one in
-// the view will NOT cause the usage to be recorded unless a consumer is
-// subscribed to the view or ForceCollection for this view is called.
+// GetViewInfo is like Registry.GetViewInfo for the default registry.
+func GetViewInfo(v View) (*ViewInfo, error) {
+	return defaultRegistry.GetViewInfo(v)
+}
+
+// RegisterView is like Registry.RegisterView for the default registry.
 func RegisterView(v View) error {
-	if v == nil {
-		return errors.New("cannot RegisterView for nil view")
-	}
+	return defaultRegistry.RegisterView(v)
+}
 
-	req := &registerViewReq{
-		v:   v,
-		err: make(chan error),
-	}
-	defaultWorker.c <- req
-	return <-req.err
+// RegisterViews is like Registry.RegisterViews for the default registry.
+func RegisterViews(vs ...View) error {
+	return defaultRegistry.RegisterViews(vs...)
+}
+
+// UnregisterNamespace is like Registry.UnregisterNamespace for the default registry.
+func UnregisterNamespace(prefix string) error {
+	return defaultRegistry.UnregisterNamespace(prefix)
 }
 
-// UnregisterView deletes the previously registered view. It returns an error
-// if the view wasn't registered. All data collected and not reported for the
-// corresponding view will be lost. All clients subscribed to this view are
-// unsubscribed automatically and their subscriptions channels closed.
+// UnregisterView is like Registry.UnregisterView for the default registry.
 func UnregisterView(v View) error {
-	if v == nil {
-		return errors.New("cannot UnregisterView for nil view")
-	}
+	return defaultRegistry.UnregisterView(v)
+}
 
-	req := &unregisterViewReq{
-		v:   v,
-		err: make(chan error),
-	}
-	defaultWorker.c <- req
-	return <-req.err
+// ReplaceView is like Registry.ReplaceView for the default registry.
+func ReplaceView(old, v View) error {
+	return defaultRegistry.ReplaceView(old, v)
 }
 
-// SubscribeToView subscribes a client to a View. If the view wasn't already
-// registered, it will be automatically registered. It allows for many clients
-// to consume the same ViewData with a single registration. -i.e. the aggregate
-// of the collected measurements will be reported to the calling code through
-// channel c. To avoid data loss, clients must ensure that channel sends
-// proceed in a timely manner. The calling code is responsible for using a
-// buffered channel or blocking on the channel waiting for the collected data.
-func SubscribeToView(v View, c chan *ViewData) error {
-	if v == nil {
-		return errors.New("cannot SubscribeToView for nil view")
-	}
+// SubscribeToView is like Registry.SubscribeToView for the default registry.
+func SubscribeToView(v View, c chan *ViewData, opts ...SubscriptionOption) error {
+	return defaultRegistry.SubscribeToView(v, c, opts...)
+}
 
-	req := &subscribeToViewReq{
-		v:   v,
-		c:   c,
-		err: make(chan error),
-	}
-	defaultWorker.c <- req
-	return <-req.err
+// SubscribeToViewBorrowed is like Registry.SubscribeToViewBorrowed for the default registry.
+func SubscribeToViewBorrowed(v View, c chan *ViewData, opts ...SubscriptionOption) error {
+	return defaultRegistry.SubscribeToViewBorrowed(v, c, opts...)
 }
 
-// UnsubscribeFromView unsubscribes a previously subscribed channel from the
-// View subscriptions. If no more subscriber for v exists and the the ad hoc
-// collection for this view isn't active, data stops being collected for this
-// view.
+// UnsubscribeFromView is like Registry.UnsubscribeFromView for the default registry.
 func UnsubscribeFromView(v View, c chan *ViewData) error {
-	if v == nil {
-		return errors.New("cannot UnsubscribeFromView for nil view")
-	}
+	return defaultRegistry.UnsubscribeFromView(v, c)
+}
 
-	req := &unsubscribeFromViewReq{
-		v:   v,
-		c:   c,
-		err: make(chan error),
-	}
-	defaultWorker.c <- req
-	return <-req.err
+// SubscribeMatching is like Registry.SubscribeMatching for the default registry.
+func SubscribeMatching(pattern string, c chan *ViewData, opts ...SubscriptionOption) error {
+	return defaultRegistry.SubscribeMatching(pattern, c, opts...)
 }
 
-// ForceCollection starts data collection for this view even if no
-// listeners are subscribed to it.
+// UnsubscribeMatching is like Registry.UnsubscribeMatching for the default registry.
+func UnsubscribeMatching(pattern string, c chan *ViewData) error {
+	return defaultRegistry.UnsubscribeMatching(pattern, c)
+}
+
+// ForceCollection is like Registry.ForceCollection for the default registry.
 func ForceCollection(v View) error {
-	if v == nil {
-		return errors.New("cannot ForceCollection for nil view")
-	}
+	return defaultRegistry.ForceCollection(v)
+}
 
-	req := &startForcedCollectionReq{
-		v:   v,
-		err: make(chan error),
-	}
-	defaultWorker.c <- req
-	return <-req.err
+// ForceCollectionFor is like Registry.ForceCollectionFor for the default registry.
+func ForceCollectionFor(v View, d time.Duration) error {
+	return defaultRegistry.ForceCollectionFor(v, d)
+}
+
+// IsCollecting is like Registry.IsCollecting for the default registry.
+func IsCollecting(v View) bool {
+	return defaultRegistry.IsCollecting(v)
 }
 
-// StopForcedCollection stops data collection for this view unless at least
-// 1 listener is subscribed to it.
+// StopForcedCollection is like Registry.StopForcedCollection for the default registry.
 func StopForcedCollection(v View) error {
-	if v == nil {
-		return errors.New("cannot StopForcedCollection for nil view")
-	}
+	return defaultRegistry.StopForcedCollection(v)
+}
 
-	req := &stopForcedCollectionReq{
-		v:   v,
-		err: make(chan error),
-	}
-	defaultWorker.c <- req
-	return <-req.err
+// AddRows is like Registry.AddRows for the default registry.
+func AddRows(v View, rows []*Row) error {
+	return defaultRegistry.AddRows(v, rows)
 }
 
-// RetrieveData returns the current collected data for the view.
+// RetrieveData is like Registry.RetrieveData for the default registry.
 func RetrieveData(v View) ([]*Row, error) {
-	if v == nil {
-		return nil, errors.New("cannot retrieve data for nil view")
-	}
-	req := &retrieveDataReq{
-		now: time.Now(),
-		v:   v,
-		c:   make(chan *retrieveDataResp),
-	}
-	defaultWorker.c <- req
-	resp := <-req.c
-	return resp.rows, resp.err
+	return defaultRegistry.RetrieveData(v)
+}
+
+// RetrieveDataFiltered is like Registry.RetrieveDataFiltered for the default registry.
+func RetrieveDataFiltered(v View, pred func([]tags.Tag) bool) ([]*Row, error) {
+	return defaultRegistry.RetrieveDataFiltered(v, pred)
+}
+
+// RetrieveDataForWindow is like Registry.RetrieveDataForWindow for the default registry.
+func RetrieveDataForWindow(v View, w Window) ([]*Row, error) {
+	return defaultRegistry.RetrieveDataForWindow(v, w)
+}
+
+// ReadAll is like Registry.ReadAll for the default registry.
+func ReadAll() ([]*ViewData, error) {
+	return defaultRegistry.ReadAll()
+}
+
+// KeepRecentSamples is like Registry.KeepRecentSamples for the default registry.
+func KeepRecentSamples(v View, n int) error {
+	return defaultRegistry.KeepRecentSamples(v, n)
+}
+
+// RetrieveRecentSamples is like Registry.RetrieveRecentSamples for the default registry.
+func RetrieveRecentSamples(v View) ([]*Sample, error) {
+	return defaultRegistry.RetrieveRecentSamples(v)
+}
+
+// Watch is like Registry.Watch for the default registry.
+func Watch(v View, predicate func(*Row) bool, cb func(*Row)) (*Watcher, error) {
+	return defaultRegistry.Watch(v, predicate, cb)
+}
+
+// Unwatch is like Registry.Unwatch for the default registry.
+func Unwatch(wr *Watcher) error {
+	return defaultRegistry.Unwatch(wr)
+}
+
+// KeepHistory is like Registry.KeepHistory for the default registry.
+func KeepHistory(v View, n int) error {
+	return defaultRegistry.KeepHistory(v, n)
+}
+
+// RetrieveHistory is like Registry.RetrieveHistory for the default registry.
+func RetrieveHistory(v View, n int) ([]*ViewData, error) {
+	return defaultRegistry.RetrieveHistory(v, n)
 }
 
 // RecordFloat64 records a float64 value against a measure and the tags passed
 // as part of the context.
 func RecordFloat64(ctx context.Context, mf *MeasureFloat64, v float64) {
-	req := &recordFloat64Req{
-		now: time.Now(),
-		ts:  tags.FromContext(ctx),
-		mf:  mf,
-		v:   v,
+	if recordingDisabled(ctx) {
+		return
+	}
+	w := registryOf(mf).w
+	if w.hooks.enabled() {
+		var proceed bool
+		if ctx, proceed = w.hooks.intercept(ctx, mf, v); !proceed {
+			return
+		}
 	}
-	defaultWorker.c <- req
+	req := recordFloat64ReqPool.Get().(*recordFloat64Req)
+	req.now = w.now()
+	req.ts = tags.FromContext(ctx)
+	req.span = spanOf(ctx)
+	req.mf = mf
+	req.v = v
+	w.c <- req
 }
 
 // RecordInt64 records an int64 value against a measure and the tags passed as
 // part of the context.
 func RecordInt64(ctx context.Context, mi *MeasureInt64, v int64) {
-	req := &recordInt64Req{
-		now: time.Now(),
-		ts:  tags.FromContext(ctx),
-		mi:  mi,
-		v:   v,
+	if recordingDisabled(ctx) {
+		return
+	}
+	w := registryOf(mi).w
+	if w.hooks.enabled() {
+		var proceed bool
+		if ctx, proceed = w.hooks.intercept(ctx, mi, v); !proceed {
+			return
+		}
 	}
-	defaultWorker.c <- req
+	req := recordInt64ReqPool.Get().(*recordInt64Req)
+	req.now = w.now()
+	req.ts = tags.FromContext(ctx)
+	req.span = spanOf(ctx)
+	req.mi = mi
+	req.v = v
+	w.c <- req
+}
+
+// RecordFloat64At records a float64 value against a measure and the tags
+// passed as part of the context, as if it was recorded at t. It allows batch
+// processors and log replayers to feed historical samples into the views at
+// their true time:
+//   - a t after the current time is replaced by the current time;
+//   - a sliding time window adds the sample to the sub interval covering t,
+//     and drops it if t is before the oldest sub interval it still keeps;
+//   - the cumulative and the sliding count windows aggregate the sample
+//     regardless of t, the latter in the order the samples are recorded.
+//
+// The sample is reported with the data collected when it is recorded, even if
+// t is before the start of the reported window.
+func RecordFloat64At(ctx context.Context, mf *MeasureFloat64, v float64, t time.Time) {
+	if recordingDisabled(ctx) {
+		return
+	}
+	w := registryOf(mf).w
+	if w.hooks.enabled() {
+		var proceed bool
+		if ctx, proceed = w.hooks.intercept(ctx, mf, v); !proceed {
+			return
+		}
+	}
+	req := recordFloat64ReqPool.Get().(*recordFloat64Req)
+	req.now = w.now()
+	if t.Before(req.now) {
+		req.at = t
+	}
+	req.ts = tags.FromContext(ctx)
+	req.span = spanOf(ctx)
+	req.mf = mf
+	req.v = v
+	w.c <- req
+}
+
+// RecordInt64At is like RecordFloat64At for an int64 value.
+func RecordInt64At(ctx context.Context, mi *MeasureInt64, v int64, t time.Time) {
+	if recordingDisabled(ctx) {
+		return
+	}
+	w := registryOf(mi).w
+	if w.hooks.enabled() {
+		var proceed bool
+		if ctx, proceed = w.hooks.intercept(ctx, mi, v); !proceed {
+			return
+		}
+	}
+	req := recordInt64ReqPool.Get().(*recordInt64Req)
+	req.now = w.now()
+	if t.Before(req.now) {
+		req.at = t
+	}
+	req.ts = tags.FromContext(ctx)
+	req.span = spanOf(ctx)
+	req.mi = mi
+	req.v = v
+	w.c <- req
+}
+
+// RecordFloat64WithAttachments records a float64 value against a measure and
+// the tags passed as part of the context. The attachments (e.g. a trace ID or
+// a request ID) are not aggregated. They are reported as exemplars alongside
+// the aggregated data of the views of the measure.
+func RecordFloat64WithAttachments(ctx context.Context, mf *MeasureFloat64, v float64, attachments map[string]string) {
+	if recordingDisabled(ctx) {
+		return
+	}
+	w := registryOf(mf).w
+	if w.hooks.enabled() {
+		var proceed bool
+		if ctx, proceed = w.hooks.intercept(ctx, mf, v); !proceed {
+			return
+		}
+	}
+	req := recordFloat64ReqPool.Get().(*recordFloat64Req)
+	req.now = w.now()
+	req.ts = tags.FromContext(ctx)
+	req.span = spanOf(ctx)
+	req.mf = mf
+	req.v = v
+	req.attachments = attachments
+	w.c <- req
+}
+
+// RecordInt64WithAttachments records an int64 value against a measure and the
+// tags passed as part of the context. The attachments (e.g. a trace ID or a
+// request ID) are not aggregated. They are reported as exemplars alongside the
+// aggregated data of the views of the measure.
+func RecordInt64WithAttachments(ctx context.Context, mi *MeasureInt64, v int64, attachments map[string]string) {
+	if recordingDisabled(ctx) {
+		return
+	}
+	w := registryOf(mi).w
+	if w.hooks.enabled() {
+		var proceed bool
+		if ctx, proceed = w.hooks.intercept(ctx, mi, v); !proceed {
+			return
+		}
+	}
+	req := recordInt64ReqPool.Get().(*recordInt64Req)
+	req.now = w.now()
+	req.ts = tags.FromContext(ctx)
+	req.span = spanOf(ctx)
+	req.mi = mi
+	req.v = v
+	req.attachments = attachments
+	w.c <- req
+}
+
+// RetrieveExemplars is like Registry.RetrieveExemplars for the default registry.
+func RetrieveExemplars(v View) ([]*Exemplar, error) {
+	return defaultRegistry.RetrieveExemplars(v)
+}
+
+// RecordDuration records a time.Duration value against a measure and the tags
+// passed as part of the context.
+func RecordDuration(ctx context.Context, md *MeasureDuration, d time.Duration) {
+	if recordingDisabled(ctx) {
+		return
+	}
+	w := registryOf(md).w
+	if w.hooks.enabled() {
+		var proceed bool
+		if ctx, proceed = w.hooks.intercept(ctx, md, d); !proceed {
+			return
+		}
+	}
+	req := recordDurationReqPool.Get().(*recordDurationReq)
+	req.now = w.now()
+	req.ts = tags.FromContext(ctx)
+	req.span = spanOf(ctx)
+	req.md = md
+	req.v = d
+	w.c <- req
 }
 
 // Record records one or multiple measurements with the same tags at once.
 func Record(ctx context.Context, ms ...Measurement) {
-	req := &recordReq{
-		now: time.Now(),
-		ts:  tags.FromContext(ctx),
-		ms:  ms,
+	if len(ms) == 0 || recordingDisabled(ctx) {
+		return
+	}
+	ts := tags.FromContext(ctx)
+	span := spanOf(ctx)
+
+	// the measurements are recorded against the registries of their measures,
+	// which are usually all the same, with the tags of ctx unless a record
+	// hook changed them. The measurements are copied to the
+	// commands since the worker may handle them after Record returns.
+	var now time.Time
+	var rsBuf [1]*Registry
+	var reqsBuf [1]*recordReq
+	rs, reqs := rsBuf[:0], reqsBuf[:0]
+	for _, m := range ms {
+		r := registryOf(measureOf(m))
+		if now.IsZero() {
+			now = r.w.now()
+		}
+		mts := ts
+		if r.w.hooks.enabled() {
+			mctx, proceed := r.w.hooks.intercept(ctx, measureOf(m), valueOf(m))
+			if !proceed {
+				continue
+			}
+			mts = tags.FromContext(mctx)
+		}
+		i := 0
+		for i < len(rs) && (rs[i] != r || reqs[i].ts != mts) {
+			i++
+		}
+		if i == len(rs) {
+			req := recordReqPool.Get().(*recordReq)
+			req.now = now
+			req.ts = mts
+			req.span = span
+			rs = append(rs, r)
+			reqs = append(reqs, req)
+		}
+		reqs[i].ms = append(reqs[i].ms, m)
+	}
+	for i, r := range rs {
+		r.w.c <- reqs[i]
 	}
-	defaultWorker.c <- req
 }
 
-// SetReportingPeriod sets the interval between reporting aggregated views in
-// the program. Calling SetReportingPeriod with duration argument less than or
-// equal to zero enables the default behavior.
+// SetReportingPeriod is like Registry.SetReportingPeriod for the default registry.
 func SetReportingPeriod(d time.Duration) {
-	// TODO(acetechnologist): ensure that the duration d is more than a certain
-	// value. e.g. 1s
-	req := &setReportingPeriodReq{
-		d: d,
-		c: make(chan bool),
-	}
-	defaultWorker.c <- req
-	<-req.c // don't return until the timer is set to the new duration.
+	defaultRegistry.SetReportingPeriod(d)
+}
+
+// SetShards is like Registry.SetShards for the default registry.
+func SetShards(n int) {
+	defaultRegistry.SetShards(n)
+}
+
+// SetGlobalTags is like Registry.SetGlobalTags for the default registry.
+func SetGlobalTags(ts *tags.TagSet) {
+	defaultRegistry.SetGlobalTags(ts)
+}
+
+// SetClock is like Registry.SetClock for the default registry.
+func SetClock(c Clock) {
+	defaultRegistry.SetClock(c)
+}
+
+// SetResource is like Registry.SetResource for the default registry.
+func SetResource(res *resource.Resource) {
+	defaultRegistry.SetResource(res)
 }
 
 func init() {
-	defaultWorker = newWorker()
-	go defaultWorker.start()
+	defaultRegistry = NewRegistry()
 }
 
 func newWorker() *worker {
-	return &worker{
+	w := &worker{
 		measuresByName: make(map[string]Measure),
 		measures:       make(map[Measure]bool),
 		viewsByName:    make(map[string]View),
+		viewAliases:    make(map[string]View),
 		views:          make(map[View]bool),
-		timer:          time.NewTicker(defaultReportingDuration),
-		c:              make(chan command),
+		readResets:     make(map[View]uint64),
+		recorders:      make(map[*recorder]bool),
+		forcedExpiries: make(map[View]bool),
+		tagSets:        newTagSetCache(defaultTagSetCacheSize),
+		recordGroups:   make(map[recordGroupKey]*recordGroup),
+		timer:          systemClock{}.NewTicker(defaultReportingDuration),
+		period:         defaultReportingDuration,
+		c:              make(chan command, maxBatchSize),
+		ctl:            make(chan command),
 		quit:           make(chan bool),
 		done:           make(chan bool),
 	}
+	w.clock.Store(clockHolder{systemClock{}})
+	return w
+}
+
+// now returns the current time of the clock of the worker.
+func (w *worker) now() time.Time {
+	return w.clock.Load().(clockHolder).c.Now()
 }
 
 func (w *worker) start() {
 	for {
 		select {
 		case cmd := <-w.c:
-			if cmd != nil {
-				cmd.handleCommand(w)
-			}
-		case <-w.timer.C:
-			w.reportUsage(time.Now())
+			w.handleRecords(cmd)
+		case cmd := <-w.ctl:
+			w.drainPending()
+			w.handle(cmd)
+		case <-w.timer.C():
+			w.drainPending()
+			w.reportUsage(w.now())
 		case <-w.quit:
 			w.timer.Stop()
+			w.stopShards()
 			close(w.c)
+			close(w.ctl)
 			w.done <- true
 			return
 		}
 	}
 }
 
+// handle handles cmd. Unless cmd only records measurements, the counts pending
+// in the recorders and in the health of the worker are added to the views
+// before cmd is handled, and the recorders are updated afterwards in case the
+// views changed.
+func (w *worker) handle(cmd command) {
+	if isRecordCommand(cmd) {
+		w.health.sampleQueueDelay(cmd)
+		cmd.handleCommand(w)
+		releaseRecordCommand(cmd)
+		return
+	}
+	w.syncShards()
+	now := w.now()
+	w.drainRecorders(now)
+	w.health.report(now)
+	w.expireForcedCollections(now)
+	cmd.handleCommand(w)
+	for r := range w.recorders {
+		r.update()
+	}
+}
+
+// drainRecorders adds the counts pending in the recorders to the views. The
+// counts are dropped if the worker is stopped or if the measure of the
+// recorder was deleted.
+func (w *worker) drainRecorders(now time.Time) {
+	for r := range w.recorders {
+		if w.stopped || !w.measures[r.m] {
+			w.health.drop(r.pending.swap())
+			continue
+		}
+		w.health.process(r.drain(now))
+	}
+}
+
+// expireForcedCollections stops the forced collections that expired at now.
+// The data collected is cleared if the view isn't collecting anymore.
+func (w *worker) expireForcedCollections(now time.Time) {
+	for v := range w.forcedExpiries {
+		exp := v.forcedCollectionExpiry()
+		if !exp.IsZero() && now.Before(exp) {
+			continue
+		}
+		delete(w.forcedExpiries, v)
+		if exp.IsZero() {
+			// the forced collection was stopped or doesn't expire anymore.
+			continue
+		}
+		v.stopForcedCollection()
+		if !v.isCollecting() {
+			v.clearRows()
+		}
+	}
+}
+
 func (w *worker) stop() {
 	w.quit <- true
 	_ = <-w.done
 }
 
 func (w *worker) tryRegisterMeasure(m Measure) error {
+	if w.r != nil && registryOf(m) != w.r {
+		return fmt.Errorf("cannot register the measure with name '%v' because it belongs to another registry", m.Name())
+	}
 	if x, ok := w.measuresByName[m.Name()]; ok {
 		if x != m {
-			return fmt.Errorf("cannot register the measure with name '%v' because a different measure with the same name is already registered", m.Name())
+			return newError(ErrDuplicateMeasure, "cannot register the measure with name '%v' because a different measure with the same name is already registered", m.Name())
 		}
 
 		// the measure is already registered so there is nothing to do and the
@@ -341,58 +687,285 @@ func (w *worker) tryRegisterMeasure(m Measure) error {
 }
 
 func (w *worker) tryRegisterView(v View) error {
+	if v.Measure() == nil {
+		return fmt.Errorf("cannot register view '%v' because it has no measure", v.Name())
+	}
 	if x, ok := w.viewsByName[v.Name()]; ok {
 		if x != v {
-			return fmt.Errorf("cannot register the view with name '%v' because a different view with the same name is already registered", v.Name())
+			return newError(ErrDuplicateView, "cannot register the view with name '%v' because a different view with the same name is already registered", v.Name())
 		}
 
 		// the view is already registered so there is nothing to do and the
 		// command is considered successful.
 		return nil
 	}
+	if err := w.checkViewAliases(v, nil); err != nil {
+		return err
+	}
 
 	// view is not registered and needs to be registered, but first its measure
 	// needs to be registered.
 	if err := w.tryRegisterMeasure(v.Measure()); err != nil {
-		return fmt.Errorf("%v. Hence cannot register view '%v,", err, v.Name())
+		return wrapError(err, "%v. Hence cannot register view '%v,", err, v.Name())
 	}
 
 	w.viewsByName[v.Name()] = v
 	w.views[v] = true
-	v.Measure().addView(v)
+	for _, a := range v.Aliases() {
+		w.viewAliases[a] = v
+	}
+	if !isComposite(v) {
+		v.Measure().addView(v)
+		w.addShardedView(v)
+	}
+	w.subscribeMatching(v)
 	return nil
 }
 
-func (w *worker) reportUsage(now time.Time) {
-	for v := range w.views {
-		if v.subscriptionsCount() == 0 {
+// subscribe subscribes c to the registered view v with the subscription s.
+func (w *worker) subscribe(v View, c chan *ViewData, s subscription) {
+	if !v.isCollecting() {
+		v.startCollection(w.now())
+	}
+	v.addSubscription(c, s)
+}
+
+// collectingExplicitly returns whether v has subscriptions made for it or a
+// forced collection. The subscriptions made by SubscribeMatching don't
+// prevent the view from being unregistered.
+func (w *worker) collectingExplicitly(v View) bool {
+	if v.forcedCollection() {
+		return true
+	}
+	for _, s := range v.subscriptions() {
+		if s.pattern == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribeMatching subscribes the channels of the matching subscriptions
+// whose pattern matches the name of the registered view v. The views lacking
+// the tag keys of the options of a subscription are skipped.
+func (w *worker) subscribeMatching(v View) {
+	for _, ms := range w.matching {
+		if !matchPattern(ms.pattern, v.Name()) || v.subscriptionExists(ms.c) {
 			continue
 		}
+		s := subscription{pattern: ms.pattern}
+		for _, opt := range ms.opts {
+			opt(&s)
+		}
+		if s.checkKeys(v) != nil {
+			continue
+		}
+		w.subscribe(v, ms.c, s)
+	}
+}
 
-		viewData := &ViewData{
-			V:    v,
-			Rows: v.collectedRows(now),
+// checkViewAliases returns an error if the name or one of the aliases of v is
+// already the name or an alias of a registered view other than replaced.
+func (w *worker) checkViewAliases(v, replaced View) error {
+	if x, ok := w.viewAliases[v.Name()]; ok && x != replaced {
+		return newError(ErrDuplicateView, "cannot register the view with name '%v' because it is an alias of view '%v'", v.Name(), x.Name())
+	}
+	for _, a := range v.Aliases() {
+		if a == v.Name() {
+			return fmt.Errorf("cannot register view '%v' because it is its own alias", v.Name())
+		}
+		if x, ok := w.viewsByName[a]; ok && x != replaced {
+			return newError(ErrDuplicateView, "cannot register view '%v' with alias '%v' because a different view with this name is already registered", v.Name(), a)
 		}
+		if x, ok := w.viewAliases[a]; ok && x != replaced {
+			return newError(ErrDuplicateView, "cannot register view '%v' with alias '%v' because it is already an alias of view '%v'", v.Name(), a, x.Name())
+		}
+	}
+	return nil
+}
+
+func (w *worker) unregisterView(v View) {
+	delete(w.viewsByName, v.Name())
+	for _, a := range v.Aliases() {
+		delete(w.viewAliases, a)
+	}
+	delete(w.views, v)
+	delete(w.forcedExpiries, v)
+	v.Measure().removeView(v)
+	w.tagSets.forgetView(v)
+	w.removeShardedView(v)
+}
+
+// applyNonFinitePolicy applies the NonFinitePolicy and the ValueConstraint of
+// mf to the value v recorded for it. It returns the value to aggregate, and
+// false if v is rejected, in which case it is counted by the health views.
+func (w *worker) applyNonFinitePolicy(mf *MeasureFloat64, v float64) (float64, bool) {
+	v, ok := applyNonFinitePolicy(mf, v)
+	if !ok || violatesConstraint(mf, v < 0) {
+		w.health.reject(mf, w.now())
+		return v, false
+	}
+	return v, true
+}
+
+// checkConstraint returns false if a value recorded for m violates the
+// ValueConstraint of m, in which case it is counted by the health views.
+// negative is whether the value is negative.
+func (w *worker) checkConstraint(m Measure, negative bool) bool {
+	if violatesConstraint(m, negative) {
+		w.health.reject(m, w.now())
+		return false
+	}
+	return true
+}
 
+// newViewData returns the data collected at now for the primary window of v,
+// in the resource res. It isn't marked as Reset, which depends on the
+// consumer: see withReset.
+func newViewData(v View, res *resource.Resource, now time.Time) *ViewData {
+	return &ViewData{
+		V:         v,
+		Resource:  res,
+		Start:     v.collector().windowStart(now),
+		End:       now,
+		Rows:      v.collectedRows(now),
+		Exemplars: v.collectedExemplars(),
+	}
+}
+
+// withReset returns vd marked as Reset if reset is true. The ViewData which
+// aren't borrowed may be shared by several consumers, so they are copied
+// rather than marked in place.
+func withReset(vd *ViewData, reset bool) *ViewData {
+	if vd.Reset == reset {
+		return vd
+	}
+	if !vd.borrowed {
+		cp := *vd
+		vd = &cp
+	}
+	vd.Reset = reset
+	return vd
+}
+
+func (w *worker) reportUsage(now time.Time) {
+	w.syncShards()
+	w.drainRecorders(now)
+	w.expireForcedCollections(now)
+	if auditEnabled {
+		for _, a := range w.delivered {
+			a.audit()
+		}
+		w.delivered = nil
+	}
+
+	for v := range w.views {
+		observed := v.keepsHistory() || len(v.watchers()) > 0
+		if v.subscriptionsCount() == 0 && !(observed && v.isCollecting()) {
+			continue
+		}
+		start := w.now()
+
+		// the subscribers share viewData, except those borrowing the data
+		// and those filtering its rows or projecting the view onto a subset
+		// of its tag keys which each get their own.
+		var viewData *ViewData
+		shared := func() *ViewData {
+			if viewData == nil {
+				viewData = newViewData(v, w.resource, now)
+				if auditEnabled {
+					w.delivered = append(w.delivered, newAuditedViewData(viewData))
+				}
+			}
+			return viewData
+		}
+		aliases := v.Aliases()
+		epoch := v.collector().resets
 		for c, s := range v.subscriptions() {
-			select {
-			case c <- viewData:
-				return
-			default:
-				s.droppedViewData++
+			var vd *ViewData
+			if s.borrowed && s.keys == nil && s.filters == nil {
+				vd = newBorrowedViewData(v, w.resource, now)
+			} else {
+				vd = s.transform(v, shared())
+			}
+			vd = withReset(vd, s.resets != epoch)
+			n, sent := s.deliver(w, c, vd)
+			if len(aliases) > 0 && v.subscriptionExists(c) {
+				// the data reported under the aliases shares the rows of vd,
+				// which mustn't be released and reused.
+				if vd.borrowed {
+					vd = withReset(shared(), vd.Reset)
+				}
+				for _, a := range aliases {
+					avd := *vd
+					avd.V = &aliasView{View: v, name: a}
+					an, asent := s.deliver(w, c, &avd)
+					n += an
+					sent = sent && asent
+				}
+			}
+			if n > 0 {
+				w.health.dropViewData(v, int64(n), now)
+			}
+			// the commands handled while delivering may have unsubscribed
+			// c, or subscribed it again with other options. The epoch of the
+			// subscription only moves once the data is sent and unless a
+			// reset was dropped meanwhile, so that a dropped reset is
+			// reported with the next ViewData.
+			if cur, ok := v.subscriptions()[c]; ok && (n > 0 || sent && cur.resets != epoch) {
+				cur.droppedViewData += uint64(n)
+				if sent && cur.resets == s.resets {
+					cur.resets = epoch
+				}
+				v.addSubscription(c, cur)
 			}
 		}
+		if !w.views[v] || !v.isCollecting() {
+			// the view was unregistered, replaced or stopped collecting by
+			// a command handled while delivering its data.
+			continue
+		}
+		if v.keepsHistory() {
+			v.addHistory(shared())
+		}
+		for _, wr := range v.watchers() {
+			wr.evaluate(w, shared())
+		}
 
-		if _, ok := v.Window().(*WindowCumulative); !ok {
-			v.clearRows()
+		if v.isResetOnCollect() {
+			v.collector().resetAt(now)
 		}
+		v.clearIntervalRows(now)
+		w.health.collected(v, start, w.now())
 	}
+	w.health.report(now)
+	w.handleDeferred()
+}
+
+// EnableHealthViews is like Registry.EnableHealthViews for the default
+// registry.
+func EnableHealthViews() (*HealthViews, error) {
+	return defaultRegistry.EnableHealthViews()
+}
+
+// Flush is like Registry.Flush for the default registry.
+func Flush() {
+	defaultRegistry.Flush()
+}
+
+// Shutdown is like Registry.Shutdown for the default registry.
+func Shutdown(ctx context.Context) error {
+	return defaultRegistry.Shutdown(ctx)
 }
 
 // RestartWorker is used for testing only. It stops the old worker and creates
 // a new worker. It should never be called by production code.
+//
+// Deprecated: RestartWorker races with the goroutines still using the default
+// registry, e.g. in parallel tests. Use NewTestEnvironment instead.
 func RestartWorker() {
-	defaultWorker.stop()
-	defaultWorker = newWorker()
-	go defaultWorker.start()
+	defaultRegistry.w.stop()
+	defaultRegistry.w = newWorker()
+	defaultRegistry.w.r = defaultRegistry
+	go defaultRegistry.w.start()
 }
@@ -0,0 +1,213 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"math"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"github.com/census-instrumentation/opencensus-go/trace"
+)
+
+// maxBatchSize is the maximum number of commands the worker drains from its
+// channel at once when the channel backs up. It is also the capacity of the
+// channel, so that the records don't block while the worker is busy.
+const maxBatchSize = 1024
+
+// recordGroup holds the records of a batch with the same measure and the same
+// tags. They are coalesced into a single addCount for the views counting the
+// samples over cumulative windows.
+type recordGroup struct {
+	m    Measure
+	e    *tagSetCacheEntry
+	cmds []command
+}
+
+// handleRecords handles cmd, received from the channel of the worker. If more
+// records are pending in the channel, they are drained and handled in a
+// batch.
+func (w *worker) handleRecords(cmd command) {
+	if cmd == nil {
+		return
+	}
+	if !isRecordCommand(cmd) || len(w.c) == 0 || w.shards != nil {
+		w.handle(cmd)
+		return
+	}
+	w.batch = w.drainBatch(append(w.batch[:0], cmd))
+	w.handleBatch(w.batch)
+	for i := range w.batch {
+		w.batch[i] = nil
+	}
+}
+
+// drainPending handles the records buffered in the channel of the worker, so
+// that the control command or the report handled next observes all the
+// measurements recorded before it.
+// The records sent afterwards are left for the main loop, so that a steady
+// flow of records doesn't starve the control commands.
+func (w *worker) drainPending() {
+	for n := len(w.c); n > 0 && len(w.c) > 0; n-- {
+		w.handleRecords(<-w.c)
+	}
+}
+
+// drainBatch appends to batch the commands immediately available in the
+// channel of the worker, up to maxBatchSize commands. It stops after the first
+// command not recording measurements, so that the records drained don't
+// overtake it.
+func (w *worker) drainBatch(batch []command) []command {
+	for len(batch) < maxBatchSize {
+		select {
+		case cmd := <-w.c:
+			if cmd == nil {
+				continue
+			}
+			batch = append(batch, cmd)
+			if !isRecordCommand(cmd) {
+				return batch
+			}
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// handleBatch handles the commands of batch in order, except the records of
+// a single measure with the same tags, without attachments nor span, which are
+// grouped and applied together before the next command that isn't a record.
+// The tags of each group are looked up once and the samples counted by the
+// counting views are added at once.
+func (w *worker) handleBatch(batch []command) {
+	for _, cmd := range batch {
+		if !isRecordCommand(cmd) {
+			w.flushRecordGroups()
+			w.handle(cmd)
+			continue
+		}
+		m, ts, ok := coalescibleRecord(cmd)
+		if !ok || w.stopped || !w.measures[m] {
+			w.handle(cmd)
+			continue
+		}
+		w.health.sampleQueueDelay(cmd)
+		e := w.tagSets.lookup(ts)
+		key := recordGroupKey{m, e}
+		g, ok := w.recordGroups[key]
+		if !ok {
+			g = &recordGroup{m: m, e: e}
+			w.recordGroups[key] = g
+			w.recordGroupsOrder = append(w.recordGroupsOrder, g)
+		}
+		g.cmds = append(g.cmds, cmd)
+	}
+	w.flushRecordGroups()
+}
+
+type recordGroupKey struct {
+	m Measure
+	e *tagSetCacheEntry
+}
+
+// flushRecordGroups applies the records grouped by handleBatch to the views.
+func (w *worker) flushRecordGroups() {
+	for _, g := range w.recordGroupsOrder {
+		w.health.process(int64(len(g.cmds)))
+		w.coalesced += uint64(len(g.cmds) - 1)
+		last := recordTime(g.cmds[len(g.cmds)-1])
+		for v := range viewsOf(g.m) {
+			if isCountingView(v) {
+				v.addCountWithCache(g.e.ts, g.e.sigs, int64(len(g.cmds)), last)
+				continue
+			}
+			for _, cmd := range g.cmds {
+				v.addSampleWithCache(g.e.ts, g.e.sigs, valueOfRecord(cmd), nil, trace.SpanContext{}, recordTime(cmd))
+			}
+		}
+		for _, cmd := range g.cmds {
+			releaseRecordCommand(cmd)
+		}
+	}
+	for k := range w.recordGroups {
+		delete(w.recordGroups, k)
+	}
+	for i := range w.recordGroupsOrder {
+		w.recordGroupsOrder[i] = nil
+	}
+	w.recordGroupsOrder = w.recordGroupsOrder[:0]
+}
+
+// isRecordCommand returns whether cmd only records measurements.
+func isRecordCommand(cmd command) bool {
+	switch cmd.(type) {
+	case *recordFloat64Req, *recordInt64Req, *recordDurationReq, *recordReq, *recordWithRecorderReq:
+		return true
+	}
+	return false
+}
+
+// coalescibleRecord returns the measure and the tags of cmd if it records a
+// single finite and non-negative float64 or int64 value at the time it was
+// sent, without attachments nor span. The other values are handled by the
+// command, which applies the NonFinitePolicy and the ValueConstraint of the
+// measure.
+func coalescibleRecord(cmd command) (Measure, *tags.TagSet, bool) {
+	switch cmd := cmd.(type) {
+	case *recordFloat64Req:
+		finite := !math.IsNaN(cmd.v) && !math.IsInf(cmd.v, 0)
+		if cmd.at.IsZero() && cmd.attachments == nil && !cmd.span.IsValid() && finite && cmd.v >= 0 {
+			return cmd.mf, cmd.ts, true
+		}
+	case *recordInt64Req:
+		if cmd.at.IsZero() && cmd.attachments == nil && !cmd.span.IsValid() && cmd.v >= 0 {
+			return cmd.mi, cmd.ts, true
+		}
+	}
+	return nil, nil, false
+}
+
+// viewsOf returns the views of the measure m.
+func viewsOf(m Measure) map[View]bool {
+	switch m := m.(type) {
+	case *MeasureFloat64:
+		return m.views
+	case *MeasureInt64:
+		return m.views
+	}
+	return nil
+}
+
+func recordTime(cmd command) time.Time {
+	switch cmd := cmd.(type) {
+	case *recordFloat64Req:
+		return cmd.now
+	case *recordInt64Req:
+		return cmd.now
+	}
+	return time.Time{}
+}
+
+func valueOfRecord(cmd command) interface{} {
+	switch cmd := cmd.(type) {
+	case *recordFloat64Req:
+		return cmd.v
+	case *recordInt64Req:
+		return cmd.v
+	}
+	return nil
+}
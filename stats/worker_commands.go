@@ -17,9 +17,14 @@ package stats
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/census-instrumentation/opencensus-go/resource"
 	"github.com/census-instrumentation/opencensus-go/tags"
+	"github.com/census-instrumentation/opencensus-go/trace"
 )
 
 type command interface {
@@ -47,7 +52,7 @@ func (cmd *getMeasureByNameReq) handleCommand(w *worker) {
 	}
 	cmd.c <- &getMeasureByNameResp{
 		nil,
-		fmt.Errorf("no measure named '%v' is registered", cmd.name),
+		newError(ErrMeasureNotRegistered, "no measure named '%v' is registered", cmd.name),
 	}
 }
 
@@ -61,6 +66,35 @@ func (cmd *registerMeasureReq) handleCommand(w *worker) {
 	cmd.err <- w.tryRegisterMeasure(cmd.m)
 }
 
+// getOrRegisterMeasureReq is the command to retrieve the measure registered
+// with the name of m, registering m if there is none.
+type getOrRegisterMeasureReq struct {
+	m Measure
+	c chan *getOrRegisterMeasureResp
+}
+
+type getOrRegisterMeasureResp struct {
+	m   Measure
+	err error
+}
+
+func (cmd *getOrRegisterMeasureReq) handleCommand(w *worker) {
+	x, ok := w.measuresByName[cmd.m.Name()]
+	if !ok {
+		if err := w.tryRegisterMeasure(cmd.m); err != nil {
+			cmd.c <- &getOrRegisterMeasureResp{nil, err}
+			return
+		}
+		cmd.c <- &getOrRegisterMeasureResp{cmd.m, nil}
+		return
+	}
+	if !sameMeasureDefinition(x, cmd.m) {
+		cmd.c <- &getOrRegisterMeasureResp{nil, newError(ErrDuplicateMeasure, "cannot get or create the measure with name '%v' as %T with unit '%v' because it is already registered as %T with unit '%v'", cmd.m.Name(), cmd.m, unitOf(cmd.m), x, unitOf(x))}
+		return
+	}
+	cmd.c <- &getOrRegisterMeasureResp{x, nil}
+}
+
 // deleteMeasureReq is the command to delete a measure from the library.
 type deleteMeasureReq struct {
 	m   Measure
@@ -80,7 +114,7 @@ func (cmd *deleteMeasureReq) handleCommand(w *worker) {
 	}
 
 	if m.viewsCount() != 0 {
-		cmd.err <- fmt.Errorf("cannot delete measure '%v'. All views referring to it must be unregistered first", cmd.m.Name())
+		cmd.err <- newError(ErrMeasureInUse, "cannot delete measure '%v'. All views referring to it must be unregistered first", cmd.m.Name())
 		return
 	}
 
@@ -101,7 +135,11 @@ type getViewByNameResp struct {
 }
 
 func (cmd *getViewByNameReq) handleCommand(w *worker) {
-	if v, ok := w.viewsByName[cmd.name]; ok {
+	v, ok := w.viewsByName[cmd.name]
+	if !ok {
+		v, ok = w.viewAliases[cmd.name]
+	}
+	if ok {
 		cmd.c <- &getViewByNameResp{
 			v,
 			nil,
@@ -110,8 +148,79 @@ func (cmd *getViewByNameReq) handleCommand(w *worker) {
 	}
 	cmd.c <- &getViewByNameResp{
 		nil,
-		fmt.Errorf("no view named '%v' is registered", cmd.name),
+		newError(ErrViewNotRegistered, "no view named '%v' is registered", cmd.name),
+	}
+}
+
+// listMeasuresReq is the command to list the registered measures.
+type listMeasuresReq struct {
+	c chan []Measure
+}
+
+func (cmd *listMeasuresReq) handleCommand(w *worker) {
+	var ms []Measure
+	for _, m := range w.measuresByName {
+		ms = append(ms, m)
 	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Name() < ms[j].Name() })
+	cmd.c <- ms
+}
+
+// listViewsReq is the command to list the registered views.
+type listViewsReq struct {
+	c chan []View
+}
+
+func (cmd *listViewsReq) handleCommand(w *worker) {
+	var vs []View
+	for _, v := range w.viewsByName {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i].Name() < vs[j].Name() })
+	cmd.c <- vs
+}
+
+// getViewInfoReq is the command to get the collection state of a view.
+type getViewInfoReq struct {
+	v View
+	c chan *getViewInfoResp
+}
+
+type getViewInfoResp struct {
+	vi  *ViewInfo
+	err error
+}
+
+func (cmd *getViewInfoReq) handleCommand(w *worker) {
+	if _, ok := w.views[cmd.v]; !ok {
+		cmd.c <- &getViewInfoResp{
+			nil,
+			newError(ErrViewNotRegistered, "cannot get info for view with name '%v' because it is not registered", cmd.v.Name()),
+		}
+		return
+	}
+	cmd.c <- &getViewInfoResp{
+		&ViewInfo{
+			V:                      cmd.v,
+			Subscriptions:          cmd.v.subscriptionsCount(),
+			ForcedCollection:       cmd.v.forcedCollection(),
+			ForcedCollectionExpiry: cmd.v.forcedCollectionExpiry(),
+			Rows:                   cmd.v.rowsCount(),
+			DroppedSamples:         cmd.v.collector().dropped,
+			DroppedViewData:        droppedViewData(cmd.v),
+		},
+		nil,
+	}
+}
+
+// droppedViewData returns the number of ViewData of v dropped for its
+// subscribers.
+func droppedViewData(v View) uint64 {
+	var n uint64
+	for _, s := range v.subscriptions() {
+		n += s.droppedViewData
+	}
+	return n
 }
 
 // registerViewReq is the command to register a view with the library.
@@ -142,22 +251,172 @@ func (cmd *unregisterViewReq) handleCommand(w *worker) {
 		return
 	}
 
-	if v.isCollecting() {
-		cmd.err <- fmt.Errorf("cannot unregister view '%v'. All subscriptions to it must be unsubscribed and its forced collection must be stopped first", cmd.v.Name())
+	if w.collectingExplicitly(v) {
+		cmd.err <- newError(ErrViewCollecting, "cannot unregister view '%v'. All subscriptions to it must be unsubscribed and its forced collection must be stopped first", cmd.v.Name())
+		return
+	}
+
+	w.unregisterView(v)
+	cmd.err <- nil
+}
+
+// registerViewsReq is the command to register several views atomically.
+type registerViewsReq struct {
+	vs  []View
+	err chan error
+}
+
+func (cmd *registerViewsReq) handleCommand(w *worker) {
+	var registered []View
+	var newMeasures []Measure
+	var errs MultiError
+	for _, v := range cmd.vs {
+		if v.Measure() == nil {
+			errs = append(errs, fmt.Errorf("cannot register view '%v' because it has no measure", v.Name()))
+			continue
+		}
+		_, measureExists := w.measuresByName[v.Measure().Name()]
+		_, viewExists := w.views[v]
+		if err := w.tryRegisterView(v); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !viewExists {
+			registered = append(registered, v)
+		}
+		if !measureExists {
+			newMeasures = append(newMeasures, v.Measure())
+		}
+	}
+	if len(errs) == 0 {
+		cmd.err <- nil
+		return
+	}
+
+	for _, v := range registered {
+		w.unregisterView(v)
+	}
+	for _, m := range newMeasures {
+		delete(w.measuresByName, m.Name())
+		delete(w.measures, m)
+	}
+	cmd.err <- errs
+}
+
+// unregisterNamespaceReq is the command to unregister atomically all the
+// views whose name starts with a prefix.
+type unregisterNamespaceReq struct {
+	prefix string
+	err    chan error
+}
+
+func (cmd *unregisterNamespaceReq) handleCommand(w *worker) {
+	var vs []View
+	var errs MultiError
+	for name, v := range w.viewsByName {
+		if !strings.HasPrefix(name, cmd.prefix) {
+			continue
+		}
+		if w.collectingExplicitly(v) {
+			errs = append(errs, newError(ErrViewCollecting, "cannot unregister view '%v'. All subscriptions to it must be unsubscribed and its forced collection must be stopped first", name))
+			continue
+		}
+		vs = append(vs, v)
+	}
+	if len(errs) != 0 {
+		cmd.err <- errs
 		return
 	}
 
-	delete(w.viewsByName, cmd.v.Name())
-	delete(w.views, cmd.v)
-	cmd.v.Measure().removeView(v)
+	for _, v := range vs {
+		w.unregisterView(v)
+	}
+	cmd.err <- nil
+}
+
+// replaceViewReq is the command to replace a registered view by another view
+// with the same name.
+type replaceViewReq struct {
+	old, v View
+	err    chan error
+}
+
+func (cmd *replaceViewReq) handleCommand(w *worker) {
+	if x, ok := w.viewsByName[cmd.old.Name()]; !ok || x != cmd.old {
+		cmd.err <- newError(ErrViewNotRegistered, "cannot replace view '%v' because it is not registered", cmd.old.Name())
+		return
+	}
+	if !hasName(cmd.v, cmd.old.Name()) {
+		cmd.err <- fmt.Errorf("cannot replace view '%v' by view '%v'. The view must have the same name, or have the name of the replaced view as alias", cmd.old.Name(), cmd.v.Name())
+		return
+	}
+	if cmd.v == cmd.old {
+		cmd.err <- nil
+		return
+	}
+	if cmd.v.Measure() == nil {
+		cmd.err <- fmt.Errorf("cannot replace view '%v' by a view without measure", cmd.old.Name())
+		return
+	}
+	if x, ok := w.viewsByName[cmd.v.Name()]; ok && x != cmd.old {
+		cmd.err <- newError(ErrDuplicateView, "cannot replace view '%v' by view '%v' because a different view with this name is already registered", cmd.old.Name(), cmd.v.Name())
+		return
+	}
+	if err := w.checkViewAliases(cmd.v, cmd.old); err != nil {
+		cmd.err <- err
+		return
+	}
+	if err := w.tryRegisterMeasure(cmd.v.Measure()); err != nil {
+		cmd.err <- wrapError(err, "%v. Hence cannot replace view '%v'", err, cmd.v.Name())
+		return
+	}
+
+	if cmd.old.isCollecting() && !cmd.v.isCollecting() {
+		cmd.v.startCollection(w.now())
+	}
+	for c, s := range cmd.old.subscriptions() {
+		cmd.v.addSubscription(c, s)
+		cmd.old.deleteSubscription(c)
+	}
+	if cmd.old.forcedCollection() {
+		if exp := cmd.old.forcedCollectionExpiry(); exp.IsZero() {
+			cmd.v.startForcedCollection()
+		} else {
+			cmd.v.startForcedCollectionUntil(exp)
+			delete(w.forcedExpiries, cmd.old)
+			w.forcedExpiries[cmd.v] = true
+		}
+		cmd.old.stopForcedCollection()
+	}
+
+	delete(w.views, cmd.old)
+	delete(w.viewsByName, cmd.old.Name())
+	for _, a := range cmd.old.Aliases() {
+		delete(w.viewAliases, a)
+	}
+	cmd.old.Measure().removeView(cmd.old)
+	w.tagSets.forgetView(cmd.old)
+	w.removeShardedView(cmd.old)
+	w.viewsByName[cmd.v.Name()] = cmd.v
+	for _, a := range cmd.v.Aliases() {
+		w.viewAliases[a] = cmd.v
+	}
+	w.views[cmd.v] = true
+	if !isComposite(cmd.v) {
+		cmd.v.Measure().addView(cmd.v)
+		w.addShardedView(cmd.v)
+	}
+	w.subscribeMatching(cmd.v)
 	cmd.err <- nil
 }
 
 // subscribeToViewReq is the command to subscribe to a view.
 type subscribeToViewReq struct {
-	v   View
-	c   chan *ViewData
-	err chan error
+	v        View
+	c        chan *ViewData
+	borrowed bool
+	opts     []SubscriptionOption
+	err      chan error
 }
 
 func (cmd *subscribeToViewReq) handleCommand(w *worker) {
@@ -165,13 +424,77 @@ func (cmd *subscribeToViewReq) handleCommand(w *worker) {
 		cmd.err <- nil
 		return
 	}
+	s := subscription{borrowed: cmd.borrowed}
+	for _, opt := range cmd.opts {
+		opt(&s)
+	}
+	if err := s.checkKeys(cmd.v); err != nil {
+		cmd.err <- err
+		return
+	}
 	if err := w.tryRegisterView(cmd.v); err != nil {
-		cmd.err <- fmt.Errorf("%v. Hence cannot subscribe to channel", err)
+		cmd.err <- wrapError(err, "%v. Hence cannot subscribe to channel", err)
 		return
 	}
+	w.subscribe(cmd.v, cmd.c, s)
+	cmd.err <- nil
+}
 
-	cmd.v.addSubscription(cmd.c)
+// subscribeMatchingReq is the command to subscribe to the views whose name
+// matches a pattern.
+type subscribeMatchingReq struct {
+	pattern string
+	c       chan *ViewData
+	opts    []SubscriptionOption
+	err     chan error
+}
 
+func (cmd *subscribeMatchingReq) handleCommand(w *worker) {
+	if err := validatePattern(cmd.pattern); err != nil {
+		cmd.err <- err
+		return
+	}
+	for _, ms := range w.matching {
+		if ms.pattern == cmd.pattern && ms.c == cmd.c {
+			cmd.err <- nil
+			return
+		}
+	}
+	w.matching = append(w.matching, &matchingSubscription{
+		pattern: cmd.pattern,
+		c:       cmd.c,
+		opts:    cmd.opts,
+	})
+	for v := range w.views {
+		w.subscribeMatching(v)
+	}
+	cmd.err <- nil
+}
+
+// unsubscribeMatchingReq is the command to unsubscribe from the views whose
+// name matches a pattern.
+type unsubscribeMatchingReq struct {
+	pattern string
+	c       chan *ViewData
+	err     chan error
+}
+
+func (cmd *unsubscribeMatchingReq) handleCommand(w *worker) {
+	for i, ms := range w.matching {
+		if ms.pattern == cmd.pattern && ms.c == cmd.c {
+			w.matching = append(w.matching[:i], w.matching[i+1:]...)
+			break
+		}
+	}
+	for v := range w.views {
+		if s, ok := v.subscriptions()[cmd.c]; !ok || s.pattern != cmd.pattern {
+			continue
+		}
+		v.deleteSubscription(cmd.c)
+		if !v.isCollecting() {
+			v.clearRows()
+		}
+	}
 	cmd.err <- nil
 }
 
@@ -200,19 +523,30 @@ func (cmd *unsubscribeFromViewReq) handleCommand(w *worker) {
 }
 
 // startForcedCollection is the command to start collecting data for a view
-// without subscribing to it.
+// without subscribing to it. The forced collection expires after d, unless d
+// is zero.
 type startForcedCollectionReq struct {
 	v   View
+	d   time.Duration
 	err chan error
 }
 
 func (cmd *startForcedCollectionReq) handleCommand(w *worker) {
 	if err := w.tryRegisterView(cmd.v); err != nil {
-		cmd.err <- fmt.Errorf("%v. Hence cannot start forced collection", err)
+		cmd.err <- wrapError(err, "%v. Hence cannot start forced collection", err)
 		return
 	}
 
-	cmd.v.startForcedCollection()
+	now := w.now()
+	if !cmd.v.isCollecting() {
+		cmd.v.startCollection(now)
+	}
+	if cmd.d == 0 {
+		cmd.v.startForcedCollection()
+	} else {
+		cmd.v.startForcedCollectionUntil(now.Add(cmd.d))
+		w.forcedExpiries[cmd.v] = true
+	}
 
 	// we always return nil because this operation never fails. However we
 	// still need to return something on the channel to signal to the waiting
@@ -230,6 +564,7 @@ type stopForcedCollectionReq struct {
 
 func (cmd *stopForcedCollectionReq) handleCommand(w *worker) {
 	cmd.v.stopForcedCollection()
+	delete(w.forcedExpiries, cmd.v)
 
 	if !cmd.v.isCollecting() {
 		cmd.v.clearRows()
@@ -241,11 +576,15 @@ func (cmd *stopForcedCollectionReq) handleCommand(w *worker) {
 	cmd.err <- nil
 }
 
-// retrieveDataReq is the command to retrieve data for a view.
+// retrieveDataReq is the command to retrieve data for a view. If w is nil the
+// data collected for the primary window of the view is retrieved. If pred is
+// not nil only the rows of the primary window satisfying it are retrieved.
 type retrieveDataReq struct {
-	now time.Time
-	v   View
-	c   chan *retrieveDataResp
+	now  time.Time
+	v    View
+	w    Window
+	pred func([]tags.Tag) bool
+	c    chan *retrieveDataResp
 }
 
 type retrieveDataResp struct {
@@ -257,7 +596,7 @@ func (cmd *retrieveDataReq) handleCommand(w *worker) {
 	if _, ok := w.views[cmd.v]; !ok {
 		cmd.c <- &retrieveDataResp{
 			nil,
-			fmt.Errorf("cannot retrieve data for view with name '%v' because it is not registered", cmd.v.Name()),
+			newError(ErrViewNotRegistered, "cannot retrieve data for view with name '%v' because it is not registered", cmd.v.Name()),
 		}
 		return
 	}
@@ -265,74 +604,453 @@ func (cmd *retrieveDataReq) handleCommand(w *worker) {
 	if !cmd.v.isCollecting() {
 		cmd.c <- &retrieveDataResp{
 			nil,
-			fmt.Errorf("cannot retrieve data for view with name '%v' because no client is subscribed to it and its collection was not forcibly started", cmd.v.Name()),
+			newError(ErrViewNotCollecting, "cannot retrieve data for view with name '%v' because no client is subscribed to it and its collection was not forcibly started", cmd.v.Name()),
 		}
 		return
 	}
+	if cmd.pred != nil {
+		cmd.c <- &retrieveDataResp{
+			cmd.v.collectedRowsFiltered(cmd.pred, cmd.now),
+			nil,
+		}
+		return
+	}
+	if cmd.w == nil {
+		rows := cmd.v.collectedRows(cmd.now)
+		if cmd.v.isResetOnCollect() {
+			cmd.v.collector().resetAt(cmd.now)
+		}
+		cmd.c <- &retrieveDataResp{
+			rows,
+			nil,
+		}
+		return
+	}
+
+	rows, err := cmd.v.collectedRowsForWindow(cmd.w, cmd.now)
 	cmd.c <- &retrieveDataResp{
-		cmd.v.collectedRows(cmd.now),
+		rows,
+		err,
+	}
+}
+
+// readAllReq is the command to retrieve the data of all the views collecting
+// data.
+type readAllReq struct {
+	now time.Time
+	// resets are the reset epochs of the views when they were last read by
+	// the reader, which are updated by the command.
+	resets map[View]uint64
+	c      chan []*ViewData
+}
+
+func (cmd *readAllReq) handleCommand(w *worker) {
+	if cmd.resets == nil {
+		cmd.resets = w.readResets
+	}
+	var vds []*ViewData
+	for v := range cmd.resets {
+		if !w.views[v] {
+			delete(cmd.resets, v)
+		}
+	}
+	for v := range w.views {
+		if !v.isCollecting() {
+			continue
+		}
+		vd := newViewData(v, w.resource, cmd.now)
+		epoch := v.collector().resets
+		vd.Reset = cmd.resets[v] != epoch
+		cmd.resets[v] = epoch
+		vds = append(vds, vd)
+		if v.isResetOnCollect() {
+			v.collector().resetAt(cmd.now)
+		}
+	}
+	sort.Slice(vds, func(i, j int) bool { return vds[i].V.Name() < vds[j].V.Name() })
+	cmd.c <- vds
+}
+
+// keepRecentSamplesReq is the command to start or stop retaining the raw
+// samples recorded for a view.
+type keepRecentSamplesReq struct {
+	v   View
+	n   int
+	err chan error
+}
+
+func (cmd *keepRecentSamplesReq) handleCommand(w *worker) {
+	if _, ok := w.views[cmd.v]; !ok {
+		cmd.err <- newError(ErrViewNotRegistered, "cannot keep recent samples for view with name '%v' because it is not registered", cmd.v.Name())
+		return
+	}
+
+	cmd.v.keepRecentSamples(cmd.n)
+	cmd.err <- nil
+}
+
+// retrieveRecentSamplesReq is the command to retrieve the raw samples
+// retained for a view.
+type retrieveRecentSamplesReq struct {
+	v View
+	c chan *retrieveRecentSamplesResp
+}
+
+type retrieveRecentSamplesResp struct {
+	samples []*Sample
+	err     error
+}
+
+func (cmd *retrieveRecentSamplesReq) handleCommand(w *worker) {
+	if _, ok := w.views[cmd.v]; !ok {
+		cmd.c <- &retrieveRecentSamplesResp{
+			nil,
+			newError(ErrViewNotRegistered, "cannot retrieve recent samples for view with name '%v' because it is not registered", cmd.v.Name()),
+		}
+		return
+	}
+
+	cmd.c <- &retrieveRecentSamplesResp{
+		cmd.v.recentSamples(),
+		nil,
+	}
+}
+
+// watchReq is the command to start evaluating a watcher on a view.
+type watchReq struct {
+	wr  *Watcher
+	err chan error
+}
+
+func (cmd *watchReq) handleCommand(w *worker) {
+	if _, ok := w.views[cmd.wr.v]; !ok {
+		cmd.err <- newError(ErrViewNotRegistered, "cannot watch view with name '%v' because it is not registered", cmd.wr.v.Name())
+		return
+	}
+
+	cmd.wr.v.addWatcher(cmd.wr)
+	cmd.err <- nil
+}
+
+// unwatchReq is the command to stop evaluating a watcher.
+type unwatchReq struct {
+	wr  *Watcher
+	err chan error
+}
+
+func (cmd *unwatchReq) handleCommand(w *worker) {
+	if !cmd.wr.v.removeWatcher(cmd.wr) {
+		cmd.err <- fmt.Errorf("cannot unwatch view with name '%v' because the watcher isn't watching it", cmd.wr.v.Name())
+		return
+	}
+	cmd.err <- nil
+}
+
+// keepHistoryReq is the command to start or stop retaining the ViewData
+// collected for a view.
+type keepHistoryReq struct {
+	v   View
+	n   int
+	err chan error
+}
+
+func (cmd *keepHistoryReq) handleCommand(w *worker) {
+	if _, ok := w.views[cmd.v]; !ok {
+		cmd.err <- newError(ErrViewNotRegistered, "cannot keep history for view with name '%v' because it is not registered", cmd.v.Name())
+		return
+	}
+
+	cmd.v.keepHistory(cmd.n)
+	cmd.err <- nil
+}
+
+// retrieveHistoryReq is the command to retrieve the ViewData retained for a
+// view.
+type retrieveHistoryReq struct {
+	v View
+	n int
+	c chan *retrieveHistoryResp
+}
+
+type retrieveHistoryResp struct {
+	vds []*ViewData
+	err error
+}
+
+func (cmd *retrieveHistoryReq) handleCommand(w *worker) {
+	if _, ok := w.views[cmd.v]; !ok {
+		cmd.c <- &retrieveHistoryResp{
+			nil,
+			newError(ErrViewNotRegistered, "cannot retrieve history for view with name '%v' because it is not registered", cmd.v.Name()),
+		}
+		return
+	}
+
+	cmd.c <- &retrieveHistoryResp{
+		cmd.v.retrieveHistory(cmd.n),
 		nil,
 	}
 }
 
-// recordFloat64Req is the command to record data related to a measure.
+// retrieveExemplarsReq is the command to retrieve the exemplars collected for
+// a view.
+type retrieveExemplarsReq struct {
+	v View
+	c chan *retrieveExemplarsResp
+}
+
+type retrieveExemplarsResp struct {
+	exemplars []*Exemplar
+	err       error
+}
+
+func (cmd *retrieveExemplarsReq) handleCommand(w *worker) {
+	if _, ok := w.views[cmd.v]; !ok {
+		cmd.c <- &retrieveExemplarsResp{
+			nil,
+			newError(ErrViewNotRegistered, "cannot retrieve exemplars for view with name '%v' because it is not registered", cmd.v.Name()),
+		}
+		return
+	}
+
+	cmd.c <- &retrieveExemplarsResp{
+		cmd.v.collectedExemplars(),
+		nil,
+	}
+}
+
+// snapshotStateReq is the command to serialize the data collected for the
+// cumulative windows of a view.
+type snapshotStateReq struct {
+	v View
+	c chan *snapshotStateResp
+}
+
+type snapshotStateResp struct {
+	b   []byte
+	err error
+}
+
+func (cmd *snapshotStateReq) handleCommand(w *worker) {
+	if _, ok := w.views[cmd.v]; !ok {
+		cmd.c <- &snapshotStateResp{
+			nil,
+			newError(ErrViewNotRegistered, "cannot snapshot the state of view with name '%v' because it is not registered", cmd.v.Name()),
+		}
+		return
+	}
+	if !cmd.v.isCollecting() {
+		cmd.c <- &snapshotStateResp{
+			nil,
+			newError(ErrViewNotCollecting, "cannot snapshot the state of view with name '%v' because it is not collecting data", cmd.v.Name()),
+		}
+		return
+	}
+	b, err := cmd.v.(*view).snapshotState()
+	cmd.c <- &snapshotStateResp{
+		b,
+		err,
+	}
+}
+
+// restoreStateReq is the command to restore the data collected for the
+// cumulative windows of a view.
+type restoreStateReq struct {
+	v   View
+	b   []byte
+	err chan error
+}
+
+func (cmd *restoreStateReq) handleCommand(w *worker) {
+	if _, ok := w.views[cmd.v]; !ok {
+		cmd.err <- newError(ErrViewNotRegistered, "cannot restore the state of view with name '%v' because it is not registered", cmd.v.Name())
+		return
+	}
+	if !cmd.v.isCollecting() {
+		cmd.err <- newError(ErrViewNotCollecting, "cannot restore the state of view with name '%v' because it is not collecting data", cmd.v.Name())
+		return
+	}
+	cmd.err <- cmd.v.(*view).restoreState(cmd.b, w.now())
+}
+
+// addRowsReq is the command to add rows to the data collected for a view.
+type addRowsReq struct {
+	v    View
+	rows []*Row
+	now  time.Time
+	err  chan error
+}
+
+func (cmd *addRowsReq) handleCommand(w *worker) {
+	if _, ok := w.views[cmd.v]; !ok {
+		cmd.err <- newError(ErrViewNotRegistered, "cannot add rows to view with name '%v' because it is not registered", cmd.v.Name())
+		return
+	}
+	if !cmd.v.isCollecting() {
+		cmd.err <- newError(ErrViewNotCollecting, "cannot add rows to view with name '%v' because it is not collecting data", cmd.v.Name())
+		return
+	}
+	cmd.err <- cmd.v.(*view).addRows(cmd.rows, cmd.now)
+}
+
+// recordFloat64Req is the command to record data related to a measure. now is
+// the time the command was sent, and at the time of the sample if it differs
+// from now. See RecordFloat64At.
 type recordFloat64Req struct {
-	now time.Time
-	ts  *tags.TagSet
-	mf  *MeasureFloat64
-	v   float64
+	now         time.Time
+	at          time.Time
+	ts          *tags.TagSet
+	mf          *MeasureFloat64
+	v           float64
+	attachments map[string]string
+	span        trace.SpanContext
 }
 
 func (cmd *recordFloat64Req) handleCommand(w *worker) {
-	if _, ok := w.measures[cmd.mf]; !ok {
+	if w.stopped || !w.measures[cmd.mf] {
+		w.health.drop(1)
 		return
 	}
-	for v := range cmd.mf.views {
-		v.addSample(cmd.ts, cmd.v, cmd.now)
+	v, ok := w.applyNonFinitePolicy(cmd.mf, cmd.v)
+	if !ok {
+		return
+	}
+	w.health.process(1)
+	if len(cmd.mf.views) == 0 {
+		return
 	}
+	at := cmd.now
+	if !cmd.at.IsZero() {
+		at = cmd.at
+	}
+	e := w.tagSets.lookup(cmd.ts)
+	w.addSample(cmd.mf.views, e.ts, e.sigs, v, cmd.attachments, cmd.span, at)
 }
 
-// recordInt64Req is the command to record data related to a measure.
+// recordInt64Req is the command to record data related to a measure. now is
+// the time the command was sent, and at the time of the sample if it differs
+// from now. See RecordInt64At.
 type recordInt64Req struct {
-	now time.Time
-	ts  *tags.TagSet
-	mi  *MeasureInt64
-	v   int64
+	now         time.Time
+	at          time.Time
+	ts          *tags.TagSet
+	mi          *MeasureInt64
+	v           int64
+	attachments map[string]string
+	span        trace.SpanContext
 }
 
 func (cmd *recordInt64Req) handleCommand(w *worker) {
-	if _, ok := w.measures[cmd.mi]; !ok {
+	if w.stopped || !w.measures[cmd.mi] {
+		w.health.drop(1)
 		return
 	}
-	for v := range cmd.mi.views {
-		v.addSample(cmd.ts, cmd.v, cmd.now)
+	if !w.checkConstraint(cmd.mi, cmd.v < 0) {
+		return
+	}
+	w.health.process(1)
+	if len(cmd.mi.views) == 0 {
+		return
+	}
+	at := cmd.now
+	if !cmd.at.IsZero() {
+		at = cmd.at
+	}
+	e := w.tagSets.lookup(cmd.ts)
+	w.addSample(cmd.mi.views, e.ts, e.sigs, cmd.v, cmd.attachments, cmd.span, at)
+}
+
+// recordDurationReq is the command to record data related to a measure.
+type recordDurationReq struct {
+	now  time.Time
+	ts   *tags.TagSet
+	md   *MeasureDuration
+	v    time.Duration
+	span trace.SpanContext
+}
+
+func (cmd *recordDurationReq) handleCommand(w *worker) {
+	if w.stopped || !w.measures[cmd.md] {
+		w.health.drop(1)
+		return
+	}
+	if !w.checkConstraint(cmd.md, cmd.v < 0) {
+		return
 	}
+	w.health.process(1)
+	if len(cmd.md.views) == 0 {
+		return
+	}
+	e := w.tagSets.lookup(cmd.ts)
+	w.addSample(cmd.md.views, e.ts, e.sigs, cmd.v, nil, cmd.span, cmd.now)
 }
 
 // recordReq is the command to record data related to multiple measures
 // at once.
 type recordReq struct {
-	now time.Time
-	ts  *tags.TagSet
-	ms  []Measurement
+	now  time.Time
+	ts   *tags.TagSet
+	ms   []Measurement
+	span trace.SpanContext
 }
 
 func (cmd *recordReq) handleCommand(w *worker) {
+	if w.stopped {
+		w.health.drop(int64(len(cmd.ms)))
+		return
+	}
+	e := w.tagSets.lookup(cmd.ts)
 	for _, m := range cmd.ms {
 		switch measurement := m.(type) {
 		case *measurementFloat64:
-			for v := range measurement.m.views {
-				v.addSample(cmd.ts, measurement.v, cmd.now)
+			v, ok := w.applyNonFinitePolicy(measurement.m, measurement.v)
+			if !ok {
+				continue
 			}
+			w.addSample(measurement.m.views, e.ts, e.sigs, v, nil, cmd.span, cmd.now)
 		case *measurementInt64:
-			for v := range measurement.m.views {
-				v.addSample(cmd.ts, measurement.v, cmd.now)
+			if !w.checkConstraint(measurement.m, measurement.v < 0) {
+				continue
+			}
+			w.addSample(measurement.m.views, e.ts, e.sigs, measurement.v, nil, cmd.span, cmd.now)
+		case *measurementDuration:
+			if !w.checkConstraint(measurement.m, measurement.v < 0) {
+				continue
 			}
+			w.addSample(measurement.m.views, e.ts, e.sigs, measurement.v, nil, cmd.span, cmd.now)
 		default:
+			continue
 		}
+		w.health.process(1)
 	}
 }
 
+// recordWithRecorderReq is the command to record a value with a recorder.
+type recordWithRecorderReq struct {
+	now time.Time
+	r   *recorder
+	v   interface{}
+}
+
+func (cmd *recordWithRecorderReq) handleCommand(w *worker) {
+	if w.stopped || !w.measures[cmd.r.m] || cmd.r.closed {
+		w.health.drop(1)
+		return
+	}
+	v := cmd.v
+	if mf, ok := cmd.r.m.(*MeasureFloat64); ok {
+		f, ok := w.applyNonFinitePolicy(mf, v.(float64))
+		if !ok {
+			return
+		}
+		v = f
+	} else if !w.checkConstraint(cmd.r.m, isNegative(v)) {
+		return
+	}
+	w.health.process(1)
+	w.addSample(cmd.r.views, cmd.r.ts, cmd.r.sigs, v, nil, trace.SpanContext{}, cmd.now)
+}
+
 // setReportingPeriodReq is the command to modify the duration between
 // reporting the collected data to the subscribed clients.
 type setReportingPeriodReq struct {
@@ -341,11 +1059,162 @@ type setReportingPeriodReq struct {
 }
 
 func (cmd *setReportingPeriodReq) handleCommand(w *worker) {
+	if w.stopped {
+		cmd.c <- true
+		return
+	}
 	w.timer.Stop()
+	w.period = cmd.d
 	if cmd.d <= 0*time.Second {
-		w.timer = time.NewTicker(defaultReportingDuration)
-		return
+		w.period = defaultReportingDuration
 	}
-	w.timer = time.NewTicker(cmd.d)
+	w.timer = w.clock.Load().(clockHolder).c.NewTicker(w.period)
+	cmd.c <- true
+}
+
+// setClockReq is the command to replace the clock of the worker.
+type setClockReq struct {
+	clock Clock
+	c     chan bool
+}
+
+func (cmd *setClockReq) handleCommand(w *worker) {
+	w.clock.Store(clockHolder{cmd.clock})
+	if !w.stopped {
+		w.timer.Stop()
+		w.timer = cmd.clock.NewTicker(w.period)
+	}
+	cmd.c <- true
+}
+
+// flushReq is the command to report the data collected so far to the
+// subscribers. If shutdown is true, the periodic reporting is stopped and the
+// measurements recorded afterwards are dropped.
+type flushReq struct {
+	shutdown bool
+	c        chan bool
+}
+
+func (cmd *flushReq) handleCommand(w *worker) {
+	if !w.stopped {
+		w.reportUsage(w.now())
+	}
+	if cmd.shutdown && !w.stopped {
+		w.timer.Stop()
+		w.stopped = true
+	}
+	cmd.c <- true
+}
+
+// syncReq is the command to wait for the worker to report the data if the
+// reporting period elapsed. It makes the reporting deterministic with a
+// ManualClock, whose tick may not be handled yet when the command is sent.
+type syncReq struct {
+	c chan bool
+}
+
+func (cmd *syncReq) handleCommand(w *worker) {
+	select {
+	case <-w.timer.C():
+		w.reportUsage(w.now())
+	default:
+	}
+	cmd.c <- true
+}
+
+// registerRecorderReq is the command to register a recorder with the worker
+// so that its pending counts are added to the views.
+type registerRecorderReq struct {
+	r *recorder
+	c chan bool
+}
+
+func (cmd *registerRecorderReq) handleCommand(w *worker) {
+	cmd.r.ts = w.tagSets.withGlobal(cmd.r.own)
+	w.recorders[cmd.r] = true
+	cmd.c <- true
+}
+
+// unregisterRecorderReq is the command to unregister a closed recorder.
+type unregisterRecorderReq struct {
+	r *recorder
+	c chan bool
+}
+
+func (cmd *unregisterRecorderReq) handleCommand(w *worker) {
+	delete(w.recorders, cmd.r)
+	atomic.StoreInt32(&cmd.r.fast, 0)
+	cmd.r.closed = true
+	cmd.c <- true
+}
+
+// setGlobalTagsReq is the command to set the tags merged into the recorded
+// TagSets.
+type setGlobalTagsReq struct {
+	ts *tags.TagSet
+	c  chan bool
+}
+
+func (cmd *setGlobalTagsReq) handleCommand(w *worker) {
+	w.tagSets.setGlobal(cmd.ts)
+	for r := range w.recorders {
+		r.ts = w.tagSets.withGlobal(r.own)
+		r.sigs = make(map[View]string)
+	}
+	cmd.c <- true
+}
+
+// setResourceReq is the command to set the resource attached to the ViewData.
+type setResourceReq struct {
+	res *resource.Resource
+	c   chan bool
+}
+
+func (cmd *setResourceReq) handleCommand(w *worker) {
+	w.resource = cmd.res
+	cmd.c <- true
+}
+
+// enableHealthReq is the command to register the health views of the worker.
+type enableHealthReq struct {
+	c chan *enableHealthResp
+}
+
+type enableHealthResp struct {
+	views *HealthViews
+	err   error
+}
+
+func (cmd *enableHealthReq) handleCommand(w *worker) {
+	if w.health == nil {
+		h, err := newHealth(w)
+		if err != nil {
+			cmd.c <- &enableHealthResp{nil, err}
+			return
+		}
+		w.health = h
+	}
+	cmd.c <- &enableHealthResp{w.health.views, nil}
+}
+
+// isCollectingReq is the command to find out whether a view is collecting
+// data.
+type isCollectingReq struct {
+	v View
+	c chan bool
+}
+
+func (cmd *isCollectingReq) handleCommand(w *worker) {
+	cmd.c <- w.views[cmd.v] && cmd.v.isCollecting()
+}
+
+// setShardsReq is the command to spread the views over several shards.
+type setShardsReq struct {
+	n int
+	c chan bool
+}
+
+func (cmd *setShardsReq) handleCommand(w *worker) {
+	w.setShards(cmd.n)
 	cmd.c <- true
 }
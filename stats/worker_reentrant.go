@@ -0,0 +1,96 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import "time"
+
+// The commands of the public API other than the records (e.g. RegisterView,
+// SubscribeToView or RetrieveData) are sent to the worker on its control
+// channel, ctl, and the records on its data channel, c. While the worker
+// waits for a subscriber to receive a ViewData (see BackpressureBlock) or for
+// the callback of a watcher to return, it keeps handling the control commands
+// but not the records, so that the subscribers and the callbacks can manage
+// the views in response to the data they receive without deadlocking against
+// the worker, and without the samples recorded in the meantime being added
+// to the data being reported.
+
+// sendViewData sends vd to c. While c is full, the control commands are
+// handled. It returns false if timeout fires before vd is sent. A nil timeout
+// never fires.
+func (w *worker) sendViewData(c chan *ViewData, vd *ViewData, timeout <-chan time.Time) bool {
+	for {
+		select {
+		case c <- vd:
+			return true
+		case cmd := <-w.ctl:
+			w.handleReentrant(cmd)
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+// call calls f on another goroutine, and handles the control commands until f
+// returns, so that f can call the functions of the registry.
+func (w *worker) call(f func()) {
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		f()
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		case cmd := <-w.ctl:
+			w.handleReentrant(cmd)
+		}
+	}
+}
+
+// handleReentrant handles cmd while the data is being reported. The commands
+// reporting the data (e.g. Flush) are acknowledged at once, and handled once
+// the current report completes: handling them now would deliver the data to
+// the subscriber or the callback waiting for the command. The counts pending
+// in the recorders aren't added to the views, since the views being reported
+// may be reset at the end of the report.
+func (w *worker) handleReentrant(cmd command) {
+	switch cmd := cmd.(type) {
+	case *flushReq:
+		w.deferred = append(w.deferred, &flushReq{shutdown: cmd.shutdown, c: make(chan bool, 1)})
+		cmd.c <- true
+		return
+	case *syncReq:
+		w.deferred = append(w.deferred, &syncReq{c: make(chan bool, 1)})
+		cmd.c <- true
+		return
+	}
+	w.syncShards()
+	cmd.handleCommand(w)
+	for r := range w.recorders {
+		r.update()
+	}
+}
+
+// handleDeferred handles the commands deferred by handleReentrant during the
+// last report.
+func (w *worker) handleDeferred() {
+	for len(w.deferred) > 0 {
+		cmd := w.deferred[0]
+		w.deferred = w.deferred[1:]
+		w.handle(cmd)
+	}
+}
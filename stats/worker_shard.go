@@ -0,0 +1,178 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package stats
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"github.com/census-instrumentation/opencensus-go/trace"
+)
+
+const (
+	// maxShards is the maximum number of shards the views can be spread
+	// over.
+	maxShards = 64
+	// shardBufferSize is the size of the channels of the shards.
+	shardBufferSize = 1024
+)
+
+// shard is a goroutine adding the samples to the views it owns. The worker
+// sends it the samples of the measures having views it owns, and
+// synchronizes with all the shards before handling any other command, so
+// that the views are only accessed by the worker while the shards are idle.
+type shard struct {
+	idx     int
+	c       chan shardSample
+	done    chan bool
+	tagSets *tagSetCache
+}
+
+// shardSample is a sample sent by the worker to a shard. A sample with a
+// barrier is only acknowledged on barrier.
+type shardSample struct {
+	views       map[View]bool
+	ts          *tags.TagSet
+	val         interface{}
+	attachments map[string]string
+	span        trace.SpanContext
+	at          time.Time
+	barrier     chan bool
+}
+
+func (s *shard) run(w *worker) {
+	for r := range s.c {
+		if r.barrier != nil {
+			r.barrier <- true
+			continue
+		}
+		// the signatures are cached by shard since the views of a TagSet
+		// are updated concurrently by the shards.
+		e := s.tagSets.lookup(r.ts)
+		for v := range r.views {
+			if w.shardOf[v] == s.idx {
+				v.addSampleWithCache(e.ts, e.sigs, r.val, r.attachments, r.span, r.at)
+			}
+		}
+	}
+	close(s.done)
+}
+
+// shardIndex returns the shard owning the view named name among n shards.
+func shardIndex(name string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % uint32(n))
+}
+
+// setShards spreads the views over n shards, or stops sharding them if n is
+// less than 2.
+func (w *worker) setShards(n int) {
+	if n > maxShards {
+		n = maxShards
+	}
+	w.stopShards()
+	if n < 2 {
+		return
+	}
+	w.shardOf = make(map[View]int)
+	for v := range w.views {
+		w.shardOf[v] = shardIndex(v.Name(), n)
+	}
+	w.barrier = make(chan bool, n)
+	for i := 0; i < n; i++ {
+		s := &shard{
+			idx:     i,
+			c:       make(chan shardSample, shardBufferSize),
+			done:    make(chan bool),
+			tagSets: newTagSetCache(defaultTagSetCacheSize),
+		}
+		w.shards = append(w.shards, s)
+		go s.run(w)
+	}
+}
+
+// stopShards waits for the shards to add the samples pending and stops them.
+func (w *worker) stopShards() {
+	for _, s := range w.shards {
+		close(s.c)
+		<-s.done
+	}
+	w.shards = nil
+	w.shardOf = nil
+	w.barrier = nil
+}
+
+// syncShards waits for the shards to add all the samples sent to them. The
+// shards are idle until the worker sends them new samples.
+func (w *worker) syncShards() {
+	if w.shards == nil {
+		return
+	}
+	for _, s := range w.shards {
+		s.c <- shardSample{barrier: w.barrier}
+	}
+	for range w.shards {
+		<-w.barrier
+	}
+}
+
+// addShardedView assigns the registered view v to a shard.
+func (w *worker) addShardedView(v View) {
+	if w.shards != nil {
+		w.shardOf[v] = shardIndex(v.Name(), len(w.shards))
+	}
+}
+
+// removeShardedView forgets the unregistered view v.
+func (w *worker) removeShardedView(v View) {
+	if w.shards == nil {
+		return
+	}
+	delete(w.shardOf, v)
+	for _, s := range w.shards {
+		s.tagSets.forgetView(v)
+	}
+}
+
+// addSample adds the sample val to views, directly or by sending it to the
+// shards owning them. sigs caches the signatures of ts for the views when
+// they are not sharded.
+func (w *worker) addSample(views map[View]bool, ts *tags.TagSet, sigs map[View]string, val interface{}, attachments map[string]string, span trace.SpanContext, at time.Time) {
+	if w.shards == nil {
+		for v := range views {
+			v.addSampleWithCache(ts, sigs, val, attachments, span, at)
+		}
+		return
+	}
+	var owners uint64
+	for v := range views {
+		owners |= 1 << uint(w.shardOf[v])
+	}
+	for i, s := range w.shards {
+		if owners&(1<<uint(i)) != 0 {
+			s.c <- shardSample{
+				views:       views,
+				ts:          ts,
+				val:         val,
+				attachments: attachments,
+				span:        span,
+				at:          at,
+			}
+		}
+	}
+}
@@ -18,57 +18,103 @@ package stats
 import (
 	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/census-instrumentation/opencensus-go/resource"
 	"github.com/census-instrumentation/opencensus-go/tags"
+	"github.com/census-instrumentation/opencensus-go/trace"
 	"golang.org/x/net/context"
 )
 
 func Test_Worker_MeasureCreation(t *testing.T) {
 	RestartWorker()
 
-	if _, err := NewMeasureFloat64("MF1", "desc MF1", "unit"); err != nil {
+	if _, err := NewMeasureFloat64("MF1", "desc MF1", "1"); err != nil {
 		t.Errorf("NewMeasureFloat64(\"MF1\", \"desc MF1\") got error %v, want no error", err)
 	}
 
-	if _, err := NewMeasureFloat64("MF1", "Duplicate measure with same name as MF1.", "unit"); err == nil {
+	if _, err := NewMeasureFloat64("MF1", "Duplicate measure with same name as MF1.", "1"); err == nil {
 		t.Error("NewMeasureFloat64(\"MF1\", \"Duplicate MeasureFloat64 with same name as MF1.\") got no error, want no error")
 	}
 
-	if _, err := NewMeasureInt64("MF1", "Duplicate measure with same name as MF1.", "unit"); err == nil {
+	if _, err := NewMeasureInt64("MF1", "Duplicate measure with same name as MF1.", "1"); err == nil {
 		t.Error("NewMeasureInt64(\"MF1\", \"Duplicate MeasureInt64 with same name as MF1.\") got no error, want no error")
 	}
 
-	if _, err := NewMeasureFloat64("MF2", "desc MF2", "unit"); err != nil {
+	if _, err := NewMeasureFloat64("MF2", "desc MF2", "1"); err != nil {
 		t.Errorf("NewMeasureFloat64(\"MF2\", \"desc MF2\") got error %v, want no error", err)
 	}
 
-	if _, err := NewMeasureInt64("MI1", "desc MI1", "unit"); err != nil {
+	if _, err := NewMeasureInt64("MI1", "desc MI1", "1"); err != nil {
 		t.Errorf("NewMeasureInt64(\"MI1\", \"desc MI1\") got error %v, want no error", err)
 	}
 
-	if _, err := NewMeasureInt64("MI1", "Duplicate measure with same name as MI1.", "unit"); err == nil {
+	if _, err := NewMeasureInt64("MI1", "Duplicate measure with same name as MI1.", "1"); err == nil {
 		t.Error("NewMeasureInt64(\"MI1\", \"Duplicate NewMeasureInt64 with same name as MI1.\") got no error, want no error")
 	}
 
-	if _, err := NewMeasureFloat64("MI1", "Duplicate measure with same name as MI1.", "unit"); err == nil {
+	if _, err := NewMeasureFloat64("MI1", "Duplicate measure with same name as MI1.", "1"); err == nil {
 		t.Error("NewMeasureFloat64(\"MI1\", \"Duplicate NewMeasureFloat64 with same name as MI1.\") got no error, want no error")
 	}
 }
 
+func Test_Worker_MeasureGetOrCreate(t *testing.T) {
+	RestartWorker()
+
+	mf1, err := GetOrCreateMeasureFloat64("MF1", "desc MF1", UnitMilliseconds)
+	if err != nil {
+		t.Fatalf("GetOrCreateMeasureFloat64(\"MF1\") got error %v, want no error", err)
+	}
+	mf2, err := GetOrCreateMeasureFloat64("MF1", "other desc MF1", UnitMilliseconds)
+	if err != nil {
+		t.Fatalf("GetOrCreateMeasureFloat64(\"MF1\") twice got error %v, want no error", err)
+	}
+	if mf1 != mf2 || mf2.Description() != "desc MF1" {
+		t.Errorf("GetOrCreateMeasureFloat64(\"MF1\") twice got %p (%v), want %p (desc MF1)", mf2, mf2.Description(), mf1)
+	}
+	if _, err := GetOrCreateMeasureFloat64("MF1", "desc MF1", UnitSeconds); !errors.Is(err, ErrDuplicateMeasure) {
+		t.Errorf("GetOrCreateMeasureFloat64(\"MF1\") with another unit got error %v, want %v", err, ErrDuplicateMeasure)
+	}
+	if _, err := GetOrCreateMeasureInt64("MF1", "desc MF1", UnitMilliseconds); !errors.Is(err, ErrDuplicateMeasure) {
+		t.Errorf("GetOrCreateMeasureInt64(\"MF1\") over a float64 measure got error %v, want %v", err, ErrDuplicateMeasure)
+	}
+
+	mi, err := NewMeasureInt64("MI1", "desc MI1", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64(\"MI1\") got error %v, want no error", err)
+	}
+	if got, err := GetOrCreateMeasureInt64("MI1", "desc MI1", "1"); err != nil || got != mi {
+		t.Errorf("GetOrCreateMeasureInt64(\"MI1\") got %p, %v, want %p, no error", got, err, mi)
+	}
+	md, err := GetOrCreateMeasureDuration("MD1", "desc MD1")
+	if err != nil {
+		t.Fatalf("GetOrCreateMeasureDuration(\"MD1\") got error %v, want no error", err)
+	}
+	if got, _ := GetOrCreateMeasureDuration("MD1", "desc MD1"); got != md {
+		t.Errorf("GetOrCreateMeasureDuration(\"MD1\") twice got %p, want %p", got, md)
+	}
+}
+
 func Test_Worker_MeasureByName(t *testing.T) {
 	RestartWorker()
 
 	someError := errors.New("some error")
-	mf1, err := NewMeasureFloat64("MF1", "desc MF1", "unit")
+	mf1, err := NewMeasureFloat64("MF1", "desc MF1", "1")
 	if err != nil {
 		t.Errorf("NewMeasureFloat64(\"MF1\", \"desc MF1\") got error %v, want no error", err)
 	}
-	mf2, err := NewMeasureFloat64("MF2", "desc MF2", "unit")
+	mf2, err := NewMeasureFloat64("MF2", "desc MF2", "1")
 	if err != nil {
 		t.Errorf("NewMeasureFloat64(\"MF2\", \"desc MF2\") got error %v, want no error", err)
 	}
-	mi1, err := NewMeasureInt64("MI1", "desc MI1", "unit")
+	mi1, err := NewMeasureInt64("MI1", "desc MI1", "1")
 	if err != nil {
 		t.Errorf("NewMeasureInt64(\"MI1\", \"desc MI1\") got error %v, want no error", err)
 	}
@@ -216,7 +262,7 @@ func Test_Worker_MeasureDelete(t *testing.T) {
 		RestartWorker()
 
 		for _, n := range tc.measureNames {
-			if _, err := NewMeasureInt64(n, "some desc", "unit"); err != nil {
+			if _, err := NewMeasureInt64(n, "some desc", "1"); err != nil {
 				t.Errorf("Creating measure got error '%v'. test case: '%v'", err, tc.label)
 			}
 		}
@@ -419,8 +465,8 @@ func Test_Worker_ViewRegistration(t *testing.T) {
 	for _, tc := range tcs {
 		RestartWorker()
 
-		mf1, _ := NewMeasureFloat64("MF1", "desc MF1", "unit")
-		mf2, _ := NewMeasureFloat64("MF2", "desc MF2", "unit")
+		mf1, _ := NewMeasureFloat64("MF1", "desc MF1", "1")
+		mf2, _ := NewMeasureFloat64("MF2", "desc MF2", "1")
 
 		views := make(map[string]View)
 		views["v1ID"] = NewView("VF1", "desc VF1", nil, mf1, nil, nil)
@@ -472,7 +518,7 @@ func Test_Worker_RecordFloat64(t *testing.T) {
 	RestartWorker()
 
 	someError := errors.New("some error")
-	m, err := NewMeasureFloat64("MF1", "desc MF1", "unit")
+	m, err := NewMeasureFloat64("MF1", "desc MF1", "1")
 	if err != nil {
 		t.Errorf("NewMeasureFloat64(\"MF1\", \"desc MF1\") got error '%v', want no error", err)
 	}
@@ -527,8 +573,8 @@ func Test_Worker_RecordFloat64(t *testing.T) {
 					v1,
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
-							newAggregationCountValue(2),
+							Tags:             []tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
+							AggregationValue: newAggregationCountValue(2),
 						},
 					},
 					nil,
@@ -547,8 +593,8 @@ func Test_Worker_RecordFloat64(t *testing.T) {
 					v1,
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
-							newAggregationCountValue(2),
+							Tags:             []tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
+							AggregationValue: newAggregationCountValue(2),
 						},
 					},
 					nil,
@@ -557,8 +603,8 @@ func Test_Worker_RecordFloat64(t *testing.T) {
 					v2,
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
-							newAggregationCountValue(2),
+							Tags:             []tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
+							AggregationValue: newAggregationCountValue(2),
 						},
 					},
 					nil,
@@ -576,8 +622,8 @@ func Test_Worker_RecordFloat64(t *testing.T) {
 					v1,
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
-							newAggregationCountValue(2),
+							Tags:             []tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
+							AggregationValue: newAggregationCountValue(2),
 						},
 					},
 					nil,
@@ -596,8 +642,8 @@ func Test_Worker_RecordFloat64(t *testing.T) {
 					v1,
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
-							newAggregationCountValue(2),
+							Tags:             []tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
+							AggregationValue: newAggregationCountValue(2),
 						},
 					},
 					nil,
@@ -606,8 +652,8 @@ func Test_Worker_RecordFloat64(t *testing.T) {
 					v2,
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
-							newAggregationCountValue(2),
+							Tags:             []tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
+							AggregationValue: newAggregationCountValue(2),
 						},
 					},
 					nil,
@@ -625,8 +671,8 @@ func Test_Worker_RecordFloat64(t *testing.T) {
 					v1,
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
-							newAggregationCountValue(3),
+							Tags:             []tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
+							AggregationValue: newAggregationCountValue(3),
 						},
 					},
 					nil,
@@ -635,8 +681,8 @@ func Test_Worker_RecordFloat64(t *testing.T) {
 					v2,
 					[]*Row{
 						{
-							[]tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
-							newAggregationCountValue(3),
+							Tags:             []tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}},
+							AggregationValue: newAggregationCountValue(3),
 						},
 					},
 					nil,
@@ -708,3 +754,2497 @@ func Test_Worker_RecordFloat64(t *testing.T) {
 		}
 	}
 }
+
+func Test_Worker_RecentSamples(t *testing.T) {
+	RestartWorker()
+
+	m, err := NewMeasureInt64("MI1", "desc MI1", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64(\"MI1\", \"desc MI1\") got error '%v', want no error", err)
+	}
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VI1", "desc VI1", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+
+	if err := KeepRecentSamples(v, 2); err == nil {
+		t.Error("KeepRecentSamples for unregistered view got no error, want error")
+	}
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection '%v' got error '%v', want no error", v.Name(), err)
+	}
+	if err := KeepRecentSamples(v, 2); err != nil {
+		t.Fatalf("KeepRecentSamples '%v' got error '%v', want no error", v.Name(), err)
+	}
+
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+	for i := int64(1); i <= 3; i++ {
+		RecordInt64(ctx, m, i)
+	}
+
+	samples, err := RetrieveRecentSamples(v)
+	if err != nil {
+		t.Fatalf("RetrieveRecentSamples '%v' got error '%v', want no error", v.Name(), err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("RetrieveRecentSamples '%v' got %v samples, want 2", v.Name(), len(samples))
+	}
+	for i, want := range []int64{2, 3} {
+		if got := samples[i].Value; got != want {
+			t.Errorf("RetrieveRecentSamples '%v' sample %v got value %v, want %v", v.Name(), i, got, want)
+		}
+		if got, _ := samples[i].Tags.ValueAsString(k1); got != "v1" {
+			t.Errorf("RetrieveRecentSamples '%v' sample %v got tag value %v, want v1", v.Name(), i, got)
+		}
+	}
+
+	if err := KeepRecentSamples(v, 0); err != nil {
+		t.Fatalf("KeepRecentSamples '%v' got error '%v', want no error", v.Name(), err)
+	}
+	if samples, _ := RetrieveRecentSamples(v); len(samples) != 0 {
+		t.Errorf("RetrieveRecentSamples '%v' got %v samples after disabling, want 0", v.Name(), len(samples))
+	}
+}
+
+func Test_Worker_DebugHandler(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	m, _ := NewMeasureInt64("MI40", "desc MI40", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VI52", "desc VI52", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection '%v' got error '%v', want no error", v.Name(), err)
+	}
+	if err := KeepRecentSamples(v, 2); err != nil {
+		t.Fatalf("KeepRecentSamples '%v' got error '%v', want no error", v.Name(), err)
+	}
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+	for i := int64(1); i <= 3; i++ {
+		RecordInt64(ctx, m, i)
+	}
+
+	h := NewDebugHandler()
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	rec := get("/?view=VI52")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET of the samples of VI52 got status %v, want %v", rec.Code, http.StatusOK)
+	}
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	if len(lines) != 3 || lines[0] != "view VI52: 2 recent sample(s)" {
+		t.Fatalf("GET of the samples of VI52 got %q, want a header and 2 samples", rec.Body.String())
+	}
+	for i, want := range []string{" 2}", " 3}"} {
+		if !strings.HasSuffix(lines[i+1], want) {
+			t.Errorf("GET of the samples of VI52 got sample %q, want the value%v", lines[i+1], strings.TrimSuffix(want, "}"))
+		}
+	}
+
+	type testCase struct {
+		label  string
+		target string
+		want   int
+	}
+	tcs := []testCase{
+		{"missing view parameter", "/", http.StatusBadRequest},
+		{"unknown view", "/?view=unknown", http.StatusNotFound},
+	}
+	for _, tc := range tcs {
+		if rec := get(tc.target); rec.Code != tc.want {
+			t.Errorf("%v: GET %v got status %v, want %v", tc.label, tc.target, rec.Code, tc.want)
+		}
+	}
+}
+
+func Test_Worker_History(t *testing.T) {
+	RestartWorker()
+
+	m, err := NewMeasureInt64("MI1", "desc MI1", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64(\"MI1\", \"desc MI1\") got error '%v', want no error", err)
+	}
+	v := NewView("VI1", "desc VI1", nil, m, NewAggregationCount(), NewWindowCumulative())
+
+	if err := KeepHistory(v, 2); err == nil {
+		t.Error("KeepHistory for unregistered view got no error, want error")
+	}
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection '%v' got error '%v', want no error", v.Name(), err)
+	}
+	if err := KeepHistory(v, 2); err != nil {
+		t.Fatalf("KeepHistory '%v' got error '%v', want no error", v.Name(), err)
+	}
+
+	for i := int64(1); i <= 3; i++ {
+		RecordInt64(context.Background(), m, i)
+		Flush()
+	}
+
+	history, err := RetrieveHistory(v, 0)
+	if err != nil {
+		t.Fatalf("RetrieveHistory '%v' got error '%v', want no error", v.Name(), err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("RetrieveHistory '%v' got %v ViewData, want 2", v.Name(), len(history))
+	}
+	for i, want := range []int64{2, 3} {
+		if got := int64(*history[i].Rows[0].AggregationValue.(*AggregationCountValue)); got != want {
+			t.Errorf("RetrieveHistory '%v' ViewData %v got count %v, want %v", v.Name(), i, got, want)
+		}
+	}
+	if last, _ := RetrieveHistory(v, 1); len(last) != 1 || last[0] != history[1] {
+		t.Errorf("RetrieveHistory '%v' of 1 ViewData got %v, want the most recent ViewData", v.Name(), last)
+	}
+
+	if err := KeepHistory(v, 0); err != nil {
+		t.Fatalf("KeepHistory '%v' got error '%v', want no error", v.Name(), err)
+	}
+	if history, _ := RetrieveHistory(v, 0); len(history) != 0 {
+		t.Errorf("RetrieveHistory '%v' got %v ViewData after disabling, want 0", v.Name(), len(history))
+	}
+}
+
+func Test_Worker_Watch(t *testing.T) {
+	RestartWorker()
+
+	m, _ := NewMeasureFloat64("MF25", "desc MF25", "ms")
+	k, _ := tags.CreateKeyString("watch_route")
+	v := NewView("VF34", "desc VF34", []tags.Key{k}, m, NewAggregationDistribution([]float64{100}), NewWindowCumulative())
+	slow := func(r *Row) bool { return r.AggregationValue.(*AggregationDistributionValue).Max() > 100 }
+	var fired []string
+	cb := func(r *Row) { fired = append(fired, k.ValueAsString(r.Tags[0].V)) }
+
+	if _, err := Watch(v, slow, cb); err == nil {
+		t.Error("Watch for unregistered view got no error, want error")
+	}
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection '%v' got error '%v', want no error", v.Name(), err)
+	}
+	wr, err := Watch(v, slow, cb)
+	if err != nil {
+		t.Fatalf("Watch '%v' got error '%v', want no error", v.Name(), err)
+	}
+
+	for route, latency := range map[string]float64{"/fast": 10, "/slow": 250} {
+		ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k, route).Build())
+		RecordFloat64(ctx, m, latency)
+	}
+	Flush()
+	if want := []string{"/slow"}; !reflect.DeepEqual(fired, want) {
+		t.Errorf("watcher fired for %v, want %v", fired, want)
+	}
+
+	if err := Unwatch(wr); err != nil {
+		t.Fatalf("Unwatch got error '%v', want no error", err)
+	}
+	Flush()
+	if len(fired) != 1 {
+		t.Errorf("watcher fired %v times after Unwatch, want 1", len(fired))
+	}
+	if err := Unwatch(wr); err == nil {
+		t.Error("Unwatch twice got no error, want error")
+	}
+}
+
+func Test_Worker_Reentrant(t *testing.T) {
+	r := NewRegistry()
+	defer r.w.stop()
+
+	m, _ := r.NewMeasureInt64("MI32", "desc MI32", "1")
+	k, _ := tags.CreateKeyString("reentrant_route")
+	v1 := NewView("VI38", "desc VI38", nil, m, NewAggregationCount(), NewWindowCumulative())
+	v2 := NewView("VI39", "desc VI39", nil, m, NewAggregationCount(), NewWindowCumulative())
+	detailed := NewView("VI40", "desc VI40", []tags.Key{k}, m, NewAggregationCount(), NewWindowCumulative())
+	watched := NewView("VI41", "desc VI41", []tags.Key{k}, m, NewAggregationCount(), NewWindowCumulative())
+
+	flush := func(label string) {
+		done := make(chan bool)
+		go func() {
+			r.Flush()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v: Flush deadlocked", label)
+		}
+	}
+
+	// the subscriber calls the registry while the worker waits to deliver
+	// it the data of the second view.
+	c := make(chan *ViewData)
+	for _, v := range []View{v1, v2} {
+		if err := r.SubscribeToView(v, c, WithBackpressure(BackpressureBlock)); err != nil {
+			t.Fatalf("SubscribeToView '%v' got error '%v', want no error", v.Name(), err)
+		}
+	}
+	RecordInt64(context.Background(), m, 1)
+	errs := make(chan error, 3)
+	received := make(chan int)
+	go func() {
+		// the deferred Flush reports both views again.
+		for i := 0; i < 4; i++ {
+			<-c
+			if i == 0 {
+				errs <- r.RegisterView(detailed)
+				_, err := r.RetrieveData(v1)
+				errs <- err
+				r.Flush()
+				errs <- nil
+			}
+		}
+		received <- 4
+	}()
+	flush("subscriber")
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscriber didn't receive the data of the deferred Flush")
+	}
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("registry call from the subscriber got error '%v', want no error", err)
+		}
+	}
+	if got, err := r.GetViewByName(detailed.Name()); err != nil || got != detailed {
+		t.Errorf("GetViewByName '%v' got %v, %v, want the view registered by the subscriber", detailed.Name(), got, err)
+	}
+	for _, v := range []View{v1, v2} {
+		if err := r.UnsubscribeFromView(v, c); err != nil {
+			t.Fatalf("UnsubscribeFromView '%v' got error '%v', want no error", v.Name(), err)
+		}
+	}
+
+	// the callback of the watcher forces the collection of a more detailed
+	// view, and stops watching.
+	if err := r.ForceCollection(watched); err != nil {
+		t.Fatalf("ForceCollection '%v' got error '%v', want no error", watched.Name(), err)
+	}
+	var (
+		wr     *Watcher
+		cbErrs []error
+	)
+	wr, err := r.Watch(watched, func(*Row) bool { return true }, func(*Row) {
+		cbErrs = append(cbErrs, r.ForceCollection(detailed), r.Unwatch(wr))
+	})
+	if err != nil {
+		t.Fatalf("Watch '%v' got error '%v', want no error", watched.Name(), err)
+	}
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k, "/a").Build())
+	RecordInt64(ctx, m, 1)
+	flush("watcher")
+	if len(cbErrs) != 2 {
+		t.Fatalf("watcher called %v times, want 1", len(cbErrs)/2)
+	}
+	for _, err := range cbErrs {
+		if err != nil {
+			t.Errorf("registry call from the watcher got error '%v', want no error", err)
+		}
+	}
+	RecordInt64(ctx, m, 1)
+	rows, err := r.RetrieveData(detailed)
+	if err != nil {
+		t.Fatalf("RetrieveData '%v' got error '%v', want no error", detailed.Name(), err)
+	}
+	// the view collects from the callback on.
+	if len(rows) != 1 || *rows[0].AggregationValue.(*AggregationCountValue) != 1 {
+		t.Errorf("RetrieveData '%v' got %v, want a row counting 1", detailed.Name(), rows)
+	}
+}
+
+func Test_Worker_UnsubscribeWhileDelivering(t *testing.T) {
+	r := NewRegistry()
+	defer r.w.stop()
+
+	m, _ := r.NewMeasureInt64("MI36", "desc MI36", "1")
+	v := NewView("VI45", "desc VI45", nil, m, NewAggregationCount(), NewWindowCumulative())
+	// nobody reads c, so the worker waits for the timeout to deliver it
+	// the data and handles the commands sent meanwhile.
+	c := make(chan *ViewData)
+	if err := r.SubscribeToView(v, c, WithSendTimeout(300*time.Millisecond)); err != nil {
+		t.Fatalf("SubscribeToView '%v' got error '%v', want no error", v.Name(), err)
+	}
+	done := make(chan bool)
+	go func() {
+		r.Flush()
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := r.UnsubscribeFromView(v, c); err != nil {
+		t.Fatalf("UnsubscribeFromView '%v' got error '%v', want no error", v.Name(), err)
+	}
+	<-done
+
+	// the dropped data doesn't subscribe c again.
+	if r.IsCollecting(v) {
+		t.Errorf("IsCollecting '%v' got true after UnsubscribeFromView, want false", v.Name())
+	}
+	if err := r.UnregisterView(v); err != nil {
+		t.Errorf("UnregisterView '%v' got error '%v', want no error", v.Name(), err)
+	}
+}
+
+func Test_Worker_PanicIsolation(t *testing.T) {
+	r := NewRegistry()
+	defer r.w.stop()
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	r.SetErrorHandler(func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	})
+	checkPanics := func(label string, want int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(errs) != want {
+			t.Errorf("%v: error handler got %v errors (%v), want %v", label, len(errs), errs, want)
+		}
+		for _, err := range errs {
+			if !errors.Is(err, ErrPanic) {
+				t.Errorf("%v: error handler got '%v', want an ErrPanic", label, err)
+			}
+		}
+		errs = nil
+	}
+
+	m, _ := r.NewMeasureInt64("MI33", "desc MI33", "1")
+	k, _ := tags.CreateKeyString("panic_route")
+	extracted := NewView("VI42", "desc VI42", nil, m, NewAggregationCount(), NewWindowCumulative(), WithTagExtractor(k, func(*tags.TagSet) []byte {
+		panic("extractor")
+	}))
+	if err := r.ForceCollection(extracted); err != nil {
+		t.Fatalf("ForceCollection '%v' got error '%v', want no error", extracted.Name(), err)
+	}
+	r.RegisterRecordHook(func(context.Context, Measure, interface{}) (context.Context, bool) {
+		panic("hook")
+	})
+	RecordInt64(context.Background(), m, 1)
+	rows, err := r.RetrieveData(extracted)
+	if err != nil {
+		t.Fatalf("RetrieveData '%v' got error '%v', want no error", extracted.Name(), err)
+	}
+	if len(rows) != 1 || len(rows[0].Tags) != 0 || *rows[0].AggregationValue.(*AggregationCountValue) != 1 {
+		t.Errorf("RetrieveData '%v' got %v, want a row without tags counting 1", extracted.Name(), rows)
+	}
+	checkPanics("hook and extractor", 2)
+
+	// the channel of the subscriber is closed by mistake.
+	c := make(chan *ViewData, 1)
+	if err := r.SubscribeToView(extracted, c); err != nil {
+		t.Fatalf("SubscribeToView '%v' got error '%v', want no error", extracted.Name(), err)
+	}
+	close(c)
+	calls := 0
+	if _, err := r.Watch(extracted, func(*Row) bool { panic("predicate") }, func(*Row) {}); err != nil {
+		t.Fatalf("Watch '%v' got error '%v', want no error", extracted.Name(), err)
+	}
+	if _, err := r.Watch(extracted, func(*Row) bool { return true }, func(*Row) {
+		calls++
+		panic("callback")
+	}); err != nil {
+		t.Fatalf("Watch '%v' got error '%v', want no error", extracted.Name(), err)
+	}
+	r.Flush()
+	checkPanics("subscriber and watchers", 3)
+	if calls != 1 {
+		t.Errorf("callback called %v times, want 1", calls)
+	}
+	info, err := r.GetViewInfo(extracted)
+	if err != nil {
+		t.Fatalf("GetViewInfo '%v' got error '%v', want no error", extracted.Name(), err)
+	}
+	if info.Subscriptions != 1 || info.DroppedViewData != 1 {
+		t.Errorf("GetViewInfo '%v' got %v subscriptions having dropped %v ViewData, want 1 and 1", extracted.Name(), info.Subscriptions, info.DroppedViewData)
+	}
+
+	// the worker still serves the calls.
+	RecordInt64(context.Background(), m, 1)
+	if rows, err := r.RetrieveData(extracted); err != nil || len(rows) != 1 || *rows[0].AggregationValue.(*AggregationCountValue) != 2 {
+		t.Errorf("RetrieveData '%v' after the panics got %v, %v, want a row counting 2", extracted.Name(), rows, err)
+	}
+}
+
+func Test_Worker_MultiWindowView(t *testing.T) {
+	RestartWorker()
+
+	m, err := NewMeasureInt64("MI1", "desc MI1", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64(\"MI1\", \"desc MI1\") got error '%v', want no error", err)
+	}
+	k1, _ := tags.CreateKeyString("k1")
+	wndCumulative := NewWindowCumulative()
+	wndCount := NewWindowSlidingCount(2, 2)
+	v := NewMultiWindowView("VI1", "desc VI1", []tags.Key{k1}, m, NewAggregationCount(), wndCumulative, wndCount)
+
+	if got := len(v.Windows()); got != 2 {
+		t.Errorf("Windows() got %v windows, want 2", got)
+	}
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection '%v' got error '%v', want no error", v.Name(), err)
+	}
+
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+	for i := int64(1); i <= 5; i++ {
+		RecordInt64(ctx, m, i)
+	}
+
+	type testCase struct {
+		label string
+		w     Window
+		want  int64
+	}
+	tcs := []testCase{
+		{"primary", nil, 5},
+		{"cumulative", wndCumulative, 5},
+		{"sliding count", wndCount, 2},
+		{"sliding count by parameters", NewWindowSlidingCount(2, 2), 2},
+	}
+	for _, tc := range tcs {
+		var rows []*Row
+		if tc.w == nil {
+			rows, err = RetrieveData(v)
+		} else {
+			rows, err = RetrieveDataForWindow(v, tc.w)
+		}
+		if err != nil {
+			t.Fatalf("Test case '%v': retrieving data got error '%v', want no error", tc.label, err)
+		}
+		want := []*Row{
+			{Tags: []tags.Tag{{k1, []byte("v1")}}, AggregationValue: newAggregationCountValue(tc.want)},
+		}
+		if ok, msg := EqualRows(rows, want); !ok {
+			t.Errorf("Test case '%v': got rows %v, want %v. %v", tc.label, rows, want, msg)
+		}
+	}
+
+	if _, err := RetrieveDataForWindow(v, NewWindowSlidingCount(3, 2)); err == nil {
+		t.Error("RetrieveDataForWindow with a window not part of the view got no error, want error")
+	}
+}
+
+func Test_Worker_RecordWithAttachments(t *testing.T) {
+	RestartWorker()
+
+	m, err := NewMeasureFloat64("MF1", "desc MF1", "1")
+	if err != nil {
+		t.Fatalf("NewMeasureFloat64(\"MF1\", \"desc MF1\") got error '%v', want no error", err)
+	}
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VF1", "desc VF1", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection '%v' got error '%v', want no error", v.Name(), err)
+	}
+
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+	RecordFloat64WithAttachments(ctx, m, 1, map[string]string{"trace_id": "t1"})
+	RecordFloat64WithAttachments(ctx, m, 2, map[string]string{"trace_id": "t2"})
+	RecordFloat64(ctx, m, 3)
+
+	exemplars, err := RetrieveExemplars(v)
+	if err != nil {
+		t.Fatalf("RetrieveExemplars '%v' got error '%v', want no error", v.Name(), err)
+	}
+	if len(exemplars) != 1 {
+		t.Fatalf("RetrieveExemplars '%v' got %v exemplars, want 1", v.Name(), len(exemplars))
+	}
+	e := exemplars[0]
+	if e.Value != float64(2) || e.Attachments["trace_id"] != "t2" {
+		t.Errorf("RetrieveExemplars '%v' got exemplar with value %v and attachments %v, want value 2 and trace_id t2", v.Name(), e.Value, e.Attachments)
+	}
+	if want := []tags.Tag{{k1, []byte("v1")}}; !reflect.DeepEqual(e.Tags, want) {
+		t.Errorf("RetrieveExemplars '%v' got exemplar with tags %v, want %v", v.Name(), e.Tags, want)
+	}
+}
+
+func Test_Worker_Timer(t *testing.T) {
+	RestartWorker()
+
+	mf, err := NewMeasureFloat64("MF1", "desc MF1", "ms")
+	if err != nil {
+		t.Fatalf("NewMeasureFloat64(\"MF1\", \"desc MF1\") got error '%v', want no error", err)
+	}
+	mi, err := NewMeasureInt64("MI1", "desc MI1", "ns")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64(\"MI1\", \"desc MI1\") got error '%v', want no error", err)
+	}
+	mu, err := NewMeasureInt64("MI2", "desc MI2", "By")
+	if err != nil {
+		t.Fatalf("NewMeasureInt64(\"MI2\", \"desc MI2\") got error '%v', want no error", err)
+	}
+
+	type testCase struct {
+		m    Measure
+		want int64
+	}
+	tcs := []testCase{{mf, 1}, {mi, 1}, {mu, 0}}
+	for _, tc := range tcs {
+		v := NewView(tc.m.Name()+"view", "desc", nil, tc.m, NewAggregationCount(), NewWindowCumulative())
+		if err := ForceCollection(v); err != nil {
+			t.Fatalf("ForceCollection '%v' got error '%v', want no error", v.Name(), err)
+		}
+
+		timer := StartTimer(context.Background(), tc.m)
+		if d := timer.Stop(); d <= 0 {
+			t.Errorf("Timer for measure '%v' Stop() got duration %v, want positive duration", tc.m.Name(), d)
+		}
+
+		rows, err := RetrieveData(v)
+		if err != nil {
+			t.Fatalf("RetrieveData '%v' got error '%v', want no error", v.Name(), err)
+		}
+		var got int64
+		if len(rows) == 1 {
+			got = int64(*rows[0].AggregationValue.(*AggregationCountValue))
+		}
+		if got != tc.want {
+			t.Errorf("Timer for measure '%v' recorded %v values, want %v", tc.m.Name(), got, tc.want)
+		}
+	}
+
+	if got, _ := durationToUnit(1500*time.Microsecond, "ms"); got != 1.5 {
+		t.Errorf("durationToUnit(1.5ms, \"ms\") got %v, want 1.5", got)
+	}
+}
+
+func Test_Worker_MeasureDuration(t *testing.T) {
+	RestartWorker()
+
+	md, err := NewMeasureDuration("MD1", "desc MD1")
+	if err != nil {
+		t.Fatalf("NewMeasureDuration(\"MD1\", \"desc MD1\") got error '%v', want no error", err)
+	}
+	agg := NewAggregationDistribution(nil)
+	vMillis := NewView("VD1", "desc VD1", nil, md, agg, NewWindowCumulative())
+	vSeconds := NewView("VD2", "desc VD2", nil, md, agg, NewWindowCumulative(), WithDurationUnit("s"))
+
+	for _, v := range []View{vMillis, vSeconds} {
+		if err := ForceCollection(v); err != nil {
+			t.Fatalf("ForceCollection '%v' got error '%v', want no error", v.Name(), err)
+		}
+	}
+
+	RecordDuration(context.Background(), md, 1500*time.Millisecond)
+	Record(context.Background(), md.Is(500*time.Millisecond))
+
+	type testCase struct {
+		v    View
+		want float64
+	}
+	for _, tc := range []testCase{{vMillis, 2000}, {vSeconds, 2}} {
+		rows, err := RetrieveData(tc.v)
+		if err != nil {
+			t.Fatalf("RetrieveData '%v' got error '%v', want no error", tc.v.Name(), err)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("RetrieveData '%v' got %v rows, want 1", tc.v.Name(), len(rows))
+		}
+		if got := rows[0].AggregationValue.(*AggregationDistributionValue).Sum(); got != tc.want {
+			t.Errorf("RetrieveData '%v' got sum %v, want %v", tc.v.Name(), got, tc.want)
+		}
+	}
+}
+
+func Test_Worker_ListAndReplaceViews(t *testing.T) {
+	RestartWorker()
+
+	m1, _ := NewMeasureInt64("MI2", "desc MI2", "1")
+	m2, _ := NewMeasureFloat64("MF1", "desc MF1", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	v1 := NewView("VI2", "desc VI2", []tags.Key{k1}, m1, NewAggregationCount(), NewWindowCumulative())
+	v2 := NewView("VF1", "desc VF1", []tags.Key{k1}, m2, NewAggregationCount(), NewWindowCumulative())
+	for _, v := range []View{v1, v2} {
+		if err := RegisterView(v); err != nil {
+			t.Fatalf("RegisterView '%v' got error '%v', want no error", v.Name(), err)
+		}
+	}
+
+	var gotMeasures []string
+	for _, m := range Measures() {
+		gotMeasures = append(gotMeasures, m.Name())
+	}
+	if want := []string{"MF1", "MI2"}; !reflect.DeepEqual(gotMeasures, want) {
+		t.Errorf("Measures() got %v, want %v", gotMeasures, want)
+	}
+	var gotViews []string
+	for _, v := range Views() {
+		gotViews = append(gotViews, v.Name())
+	}
+	if want := []string{"VF1", "VI2"}; !reflect.DeepEqual(gotViews, want) {
+		t.Errorf("Views() got %v, want %v", gotViews, want)
+	}
+
+	c := make(chan *ViewData, 1)
+	if err := SubscribeToView(v1, c); err != nil {
+		t.Fatalf("SubscribeToView '%v' got error '%v', want no error", v1.Name(), err)
+	}
+
+	sliding := NewView("VI2", "desc VI2", []tags.Key{k1}, m1, NewAggregationCount(), NewWindowSlidingCount(10, 2))
+	if err := ReplaceView(v2, sliding); err == nil {
+		t.Errorf("ReplaceView with a different name got no error, want error")
+	}
+	if err := ReplaceView(v1, sliding); err != nil {
+		t.Fatalf("ReplaceView got error '%v', want no error", err)
+	}
+	if got, _ := GetViewByName("VI2"); got != sliding {
+		t.Errorf("GetViewByName(\"VI2\") got %v, want the replacing view", got)
+	}
+	if !sliding.subscriptionExists(c) {
+		t.Errorf("subscription was not moved to the replacing view")
+	}
+	if v1.isCollecting() {
+		t.Errorf("replaced view is still collecting")
+	}
+	if err := ReplaceView(v1, sliding); err == nil {
+		t.Errorf("ReplaceView of a view no longer registered got no error, want error")
+	}
+
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+	RecordInt64(ctx, m1, 1)
+	rows, err := RetrieveData(sliding)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if len(rows) != 1 {
+		t.Errorf("RetrieveData got %v rows, want 1", len(rows))
+	}
+}
+
+func Test_Worker_ViewInfo(t *testing.T) {
+	RestartWorker()
+
+	m, _ := NewMeasureInt64("MI3", "desc MI3", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	k2, _ := tags.CreateKeyString("k2")
+	wnd := NewWindowSlidingTime(time.Minute, 6)
+	agg := NewAggregationDistribution([]float64{0, 10})
+	v := NewView("VI3", "desc VI3", []tags.Key{k1, k2}, m, agg, wnd)
+
+	if _, err := GetViewInfo(v); err == nil {
+		t.Errorf("GetViewInfo for unregistered view got no error, want error")
+	}
+	c := make(chan *ViewData, 1)
+	if err := SubscribeToView(v, c); err != nil {
+		t.Fatalf("SubscribeToView '%v' got error '%v', want no error", v.Name(), err)
+	}
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection '%v' got error '%v', want no error", v.Name(), err)
+	}
+	for _, val := range []string{"a", "b", "a"} {
+		ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, val).Build())
+		RecordInt64(ctx, m, 1)
+	}
+
+	vi, err := GetViewInfo(v)
+	if err != nil {
+		t.Fatalf("GetViewInfo got error '%v', want no error", err)
+	}
+	want := &ViewInfo{V: v, Subscriptions: 1, ForcedCollection: true, Rows: 2}
+	if !reflect.DeepEqual(vi, want) {
+		t.Errorf("GetViewInfo got %+v, want %+v", vi, want)
+	}
+
+	if got := v.TagKeys(); !reflect.DeepEqual(got, []tags.Key{k1, k2}) {
+		t.Errorf("TagKeys() got %v, want %v", got, []tags.Key{k1, k2})
+	}
+	if got := v.Aggregation().(*AggregationDistribution).Bounds(); !reflect.DeepEqual(got, []float64{0, 10}) {
+		t.Errorf("Bounds() got %v, want [0 10]", got)
+	}
+	if got := v.Window().(*WindowSlidingTime); got.Duration() != time.Minute || got.SubIntervals() != 6 {
+		t.Errorf("window got (%v, %v), want (1m0s, 6)", got.Duration(), got.SubIntervals())
+	}
+	if got := NewWindowSlidingCount(10, 2); got.Count() != 10 || got.SubSets() != 2 {
+		t.Errorf("window got (%v, %v), want (10, 2)", got.Count(), got.SubSets())
+	}
+}
+
+func Test_Worker_RegisterViewsAndUnregisterNamespace(t *testing.T) {
+	RestartWorker()
+
+	m1, _ := NewMeasureInt64("MI4", "desc MI4", "1")
+	m2 := &MeasureInt64{name: "MI5", views: make(map[View]bool)}
+	other := &MeasureInt64{name: "MI4", views: make(map[View]bool)}
+	k1, _ := tags.CreateKeyString("k1")
+	newView := func(name string, m Measure) View {
+		return NewView(name, "desc", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative(), WithNamespace("ns/"))
+	}
+
+	v1 := newView("v1", m1)
+	if got, want := v1.Name(), "ns/v1"; got != want {
+		t.Errorf("Name() got %v, want %v", got, want)
+	}
+
+	// other conflicts with m1, and the second "ns/v1" with v1: nothing must be
+	// registered and both errors must be reported.
+	err := RegisterViews(v1, newView("v2", m2), newView("v3", other), newView("v1", m1))
+	me, ok := err.(MultiError)
+	if !ok || len(me) != 2 {
+		t.Fatalf("RegisterViews got error %#v, want a MultiError with 2 errors", err)
+	}
+	if got := Views(); len(got) != 0 {
+		t.Errorf("Views() got %v views after failed RegisterViews, want 0", len(got))
+	}
+	if _, err := GetMeasureByName("MI5"); err == nil {
+		t.Errorf("GetMeasureByName(\"MI5\") got no error after failed RegisterViews, want error")
+	}
+
+	v2 := newView("v2", m2)
+	if err := RegisterViews(v1, v2); err != nil {
+		t.Fatalf("RegisterViews got error '%v', want no error", err)
+	}
+	outside := NewView("v4", "desc", []tags.Key{k1}, m1, NewAggregationCount(), NewWindowCumulative())
+	if err := RegisterView(outside); err != nil {
+		t.Fatalf("RegisterView got error '%v', want no error", err)
+	}
+
+	if err := ForceCollection(v2); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	if err := UnregisterNamespace("ns/"); err == nil {
+		t.Errorf("UnregisterNamespace with a collecting view got no error, want error")
+	}
+	if got := len(Views()); got != 3 {
+		t.Errorf("Views() got %v views after failed UnregisterNamespace, want 3", got)
+	}
+
+	if err := StopForcedCollection(v2); err != nil {
+		t.Fatalf("StopForcedCollection got error '%v', want no error", err)
+	}
+	if err := UnregisterNamespace("ns/"); err != nil {
+		t.Fatalf("UnregisterNamespace got error '%v', want no error", err)
+	}
+	if got := Views(); len(got) != 1 || got[0] != outside {
+		t.Errorf("Views() got %v after UnregisterNamespace, want only v4", got)
+	}
+}
+
+func Test_Worker_ResetOnCollect(t *testing.T) {
+	RestartWorker()
+
+	m, _ := NewMeasureInt64("MI6", "desc MI6", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VI6", "desc VI6", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative(), WithResetOnCollect())
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection '%v' got error '%v', want no error", v.Name(), err)
+	}
+
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+	for _, n := range []int{3, 0, 2} {
+		for i := 0; i < n; i++ {
+			RecordInt64(ctx, m, 1)
+		}
+		rows, err := RetrieveData(v)
+		if err != nil {
+			t.Fatalf("RetrieveData got error '%v', want no error", err)
+		}
+		var got AggregationCountValue
+		for _, r := range rows {
+			got += *(r.AggregationValue.(*AggregationCountValue))
+		}
+		if got != AggregationCountValue(n) {
+			t.Errorf("RetrieveData got count %v, want the delta %v", got, n)
+		}
+	}
+}
+
+func Test_Worker_ReportUsage(t *testing.T) {
+	w := newWorker()
+
+	m := &MeasureInt64{name: "MI7", views: make(map[View]bool)}
+	k1, _ := tags.CreateKeyString("k1")
+	delta := NewView("VI7", "desc VI7", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative(), WithResetOnCollect())
+	cumulative := NewView("VI8", "desc VI8", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	channels := make(map[View]chan *ViewData)
+	for _, v := range []View{delta, cumulative} {
+		if err := w.tryRegisterView(v); err != nil {
+			t.Fatalf("tryRegisterView '%v' got error '%v', want no error", v.Name(), err)
+		}
+		channels[v] = make(chan *ViewData, 2)
+		v.addSubscription(channels[v], subscription{})
+	}
+
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		for _, v := range []View{delta, cumulative} {
+			v.addSample(ts, int64(1), now)
+		}
+		w.reportUsage(now)
+	}
+
+	type testCase struct {
+		v    View
+		want []AggregationCountValue
+	}
+	tcs := []testCase{
+		{delta, []AggregationCountValue{1, 1}},
+		{cumulative, []AggregationCountValue{1, 2}},
+	}
+	for _, tc := range tcs {
+		for i, want := range tc.want {
+			select {
+			case vd := <-channels[tc.v]:
+				if len(vd.Rows) != 1 {
+					t.Fatalf("view '%v' report %v got %v rows, want 1", tc.v.Name(), i, len(vd.Rows))
+				}
+				if got := *(vd.Rows[0].AggregationValue.(*AggregationCountValue)); got != want {
+					t.Errorf("view '%v' report %v got count %v, want %v", tc.v.Name(), i, got, want)
+				}
+			default:
+				t.Errorf("view '%v' report %v not received", tc.v.Name(), i)
+			}
+		}
+	}
+}
+
+func Test_Worker_ReportAllViews(t *testing.T) {
+	w := newWorker()
+
+	m := &MeasureInt64{name: "MI37", views: make(map[View]bool)}
+	var channels []chan *ViewData
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("VI%v", 46+i)
+		v := NewView(name, "desc "+name, nil, m, NewAggregationCount(), NewWindowCumulative())
+		if err := w.tryRegisterView(v); err != nil {
+			t.Fatalf("tryRegisterView '%v' got error '%v', want no error", v.Name(), err)
+		}
+		// each view has several subscribers.
+		for j := 0; j < 2; j++ {
+			c := make(chan *ViewData, 1)
+			v.addSubscription(c, subscription{})
+			channels = append(channels, c)
+		}
+	}
+
+	w.reportUsage(time.Now())
+	for i, c := range channels {
+		select {
+		case <-c:
+		default:
+			t.Errorf("subscriber %v didn't receive the data of its view", i)
+		}
+	}
+}
+
+func Test_Worker_HandleBatch(t *testing.T) {
+	w := newWorker()
+
+	m := &MeasureInt64{name: "MI28", views: make(map[View]bool)}
+	k1, _ := tags.CreateKeyString("k1")
+	count := NewView("VI33", "desc VI33", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	dist := NewView("VI34", "desc VI34", []tags.Key{k1}, m, NewAggregationDistribution([]float64{2}), NewWindowCumulative())
+	channels := make(map[View]chan *ViewData)
+	for _, v := range []View{count, dist} {
+		if err := w.tryRegisterView(v); err != nil {
+			t.Fatalf("tryRegisterView '%v' got error '%v', want no error", v.Name(), err)
+		}
+		channels[v] = make(chan *ViewData, 1)
+		w.subscribe(v, channels[v], subscription{})
+	}
+
+	ts1 := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+	ts2 := tags.NewTagSetBuilder(nil).InsertString(k1, "v2").Build()
+	now := time.Now()
+	record := func(ts *tags.TagSet, v int64) command {
+		return &recordInt64Req{now: now, ts: ts, mi: m, v: v}
+	}
+	lookup := &getViewByNameReq{name: "VI33", c: make(chan *getViewByNameResp, 1)}
+	w.handleBatch([]command{record(ts1, 1), record(ts2, 5), lookup, record(ts1, 3), record(ts1, 1)})
+	if resp := <-lookup.c; resp.v != count {
+		t.Errorf("command in the batch got view %v, want %v", resp.v, count)
+	}
+	w.reportUsage(now)
+
+	vd := <-channels[count]
+	want := []*Row{
+		{Tags: []tags.Tag{{k1, []byte("v1")}}, AggregationValue: newAggregationCountValue(3)},
+		{Tags: []tags.Tag{{k1, []byte("v2")}}, AggregationValue: newAggregationCountValue(1)},
+	}
+	if ok, msg := EqualRows(vd.Rows, want); !ok {
+		t.Errorf("got unexpected rows for the count view. %v", msg)
+	}
+	vd = <-channels[dist]
+	for _, r := range vd.Rows {
+		dv := r.AggregationValue.(*AggregationDistributionValue)
+		switch string(r.Tags[0].V) {
+		case "v1":
+			if dv.Count() != 3 || dv.Sum() != 5 || dv.CountPerBucket()[0] != 2 {
+				t.Errorf("got distribution %v for v1, want 3 samples summing to 5 with 2 below 2", dv)
+			}
+		case "v2":
+			if dv.Count() != 1 || dv.Sum() != 5 {
+				t.Errorf("got distribution %v for v2, want 1 sample of 5", dv)
+			}
+		}
+	}
+}
+
+// blockReq blocks the worker until release is closed.
+type blockReq struct {
+	started, release chan struct{}
+}
+
+func (cmd *blockReq) handleCommand(w *worker) {
+	close(cmd.started)
+	<-cmd.release
+}
+
+func Test_Worker_CoalesceRecords(t *testing.T) {
+	r := NewRegistry()
+	defer r.w.stop()
+
+	m, _ := r.NewMeasureInt64("MI35", "desc MI35", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VI44", "desc VI44", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	if err := r.ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+
+	// the records sent while the worker is busy back up in its channel.
+	block := &blockReq{started: make(chan struct{}), release: make(chan struct{})}
+	go func() { r.w.ctl <- block }()
+	<-block.started
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+	const n = 100
+	for i := 0; i < n; i++ {
+		RecordInt64(ctx, m, 1)
+	}
+	close(block.release)
+
+	rows, err := r.RetrieveData(v)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	want := []*Row{{Tags: []tags.Tag{{k1, []byte("v1")}}, AggregationValue: newAggregationCountValue(n)}}
+	if ok, msg := EqualRows(rows, want); !ok {
+		t.Errorf("got unexpected rows. %v", msg)
+	}
+	// the first record is received alone, the others are drained in
+	// batches.
+	if r.w.coalesced == 0 || r.w.coalesced > n-1 {
+		t.Errorf("got %v records coalesced, want between 1 and %v", r.w.coalesced, n-1)
+	}
+}
+
+func Test_Worker_ViewDataStartEnd(t *testing.T) {
+	w := newWorker()
+
+	m := &MeasureInt64{name: "MI8", views: make(map[View]bool)}
+	k1, _ := tags.CreateKeyString("k1")
+	cumulative := NewView("VI9", "desc VI9", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	delta := NewView("VI10", "desc VI10", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative(), WithResetOnCollect())
+	sliding := NewView("VI11", "desc VI11", []tags.Key{k1}, m, NewAggregationCount(), NewWindowSlidingTime(time.Minute, 6))
+
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	channels := make(map[View]chan *ViewData)
+	for _, v := range []View{cumulative, delta, sliding} {
+		if err := w.tryRegisterView(v); err != nil {
+			t.Fatalf("tryRegisterView '%v' got error '%v', want no error", v.Name(), err)
+		}
+		channels[v] = make(chan *ViewData, 2)
+		v.startCollection(start)
+		v.addSubscription(channels[v], subscription{})
+	}
+
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+	report1 := start.Add(30 * time.Second)
+	report2 := start.Add(90 * time.Second)
+	for _, now := range []time.Time{report1, report2} {
+		for _, v := range []View{cumulative, delta, sliding} {
+			v.addSample(ts, int64(1), now)
+		}
+		w.reportUsage(now)
+	}
+
+	// the rows of the cumulative windows start with their first sample since
+	// the last reset. The rows of the sliding windows have no start.
+	type testCase struct {
+		v             View
+		wantStarts    []time.Time
+		wantRowStarts []time.Time
+	}
+	tcs := []testCase{
+		{cumulative, []time.Time{start, start}, []time.Time{report1, report1}},
+		{delta, []time.Time{start, report1}, []time.Time{report1, report2}},
+		{sliding, []time.Time{start, report2.Add(-time.Minute)}, []time.Time{{}, {}}},
+	}
+	for _, tc := range tcs {
+		for i, wantEnd := range []time.Time{report1, report2} {
+			vd := <-channels[tc.v]
+			if !vd.Start.Equal(tc.wantStarts[i]) || !vd.End.Equal(wantEnd) {
+				t.Errorf("view '%v' report %v got [%v, %v], want [%v, %v]", tc.v.Name(), i, vd.Start, vd.End, tc.wantStarts[i], wantEnd)
+			}
+			if len(vd.Rows) != 1 || !vd.Rows[0].Start.Equal(tc.wantRowStarts[i]) {
+				t.Errorf("view '%v' report %v got rows %v, want a row starting at %v", tc.v.Name(), i, vd.Rows, tc.wantRowStarts[i])
+			}
+		}
+	}
+}
+
+func Test_Worker_ResetMarker(t *testing.T) {
+	w := newWorker()
+
+	m := &MeasureInt64{name: "MI34", views: make(map[View]bool)}
+	v := NewView("VI43", "desc VI43", nil, m, NewAggregationCount(), NewWindowCumulative())
+	if err := w.tryRegisterView(v); err != nil {
+		t.Fatalf("tryRegisterView got error '%v', want no error", err)
+	}
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := make(chan *ViewData, 1)
+	v.startCollection(start)
+	v.addSubscription(c, subscription{})
+
+	ts := tags.NewTagSetBuilder(nil).Build()
+	v.addSample(ts, int64(1), start)
+	state, err := v.(*view).snapshotState()
+	if err != nil {
+		t.Fatalf("snapshotState got error '%v', want no error", err)
+	}
+
+	type testCase struct {
+		label     string
+		change    func(now time.Time)
+		wantReset bool
+	}
+	tcs := []testCase{
+		{"first report", func(time.Time) {}, true},
+		{"next report", func(time.Time) {}, false},
+		{"restored state", func(now time.Time) {
+			if err := v.(*view).restoreState(state, now); err != nil {
+				t.Fatalf("restoreState got error '%v', want no error", err)
+			}
+		}, true},
+		{"report after restore", func(time.Time) {}, false},
+		{"cleared rows", func(time.Time) { v.clearRows() }, true},
+		{"restarted collection", func(now time.Time) { v.startCollection(now) }, true},
+	}
+	for i, tc := range tcs {
+		now := start.Add(time.Duration(i+1) * time.Minute)
+		tc.change(now)
+		w.reportUsage(now)
+		if vd := <-c; vd.Reset != tc.wantReset {
+			t.Errorf("%v: got Reset %v, want %v", tc.label, vd.Reset, tc.wantReset)
+		}
+	}
+}
+
+func Test_Worker_ResetMarkerPerConsumer(t *testing.T) {
+	w := newWorker()
+
+	m := &MeasureInt64{name: "MI38", views: make(map[View]bool)}
+	v := NewView("VI49", "desc VI49", nil, m, NewAggregationCount(), NewWindowCumulative())
+	if err := w.tryRegisterView(v); err != nil {
+		t.Fatalf("tryRegisterView got error '%v', want no error", err)
+	}
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	newest := make(chan *ViewData, 1)
+	oldest := make(chan *ViewData, 1)
+	v.startCollection(start)
+	v.addSubscription(newest, subscription{})
+	v.addSubscription(oldest, subscription{policy: BackpressureDropOldest})
+
+	now := start
+	report := func() {
+		now = now.Add(time.Minute)
+		w.reportUsage(now)
+	}
+	readAll := func() bool {
+		now = now.Add(time.Minute)
+		req := &readAllReq{now: now, c: make(chan []*ViewData, 1)}
+		req.handleCommand(w)
+		vds := <-req.c
+		if len(vds) != 1 {
+			t.Fatalf("readAll got %v view data, want 1", len(vds))
+		}
+		return vds[0].Reset
+	}
+	check := func(label string, c chan *ViewData, want bool) {
+		if vd := <-c; vd.Reset != want {
+			t.Errorf("%v: got Reset %v, want %v", label, vd.Reset, want)
+		}
+	}
+
+	report()
+	check("first report", newest, true)
+	check("first report", oldest, true)
+	if got := readAll(); !got {
+		t.Errorf("first read: got Reset %v, want true", got)
+	}
+	report()
+	check("next report", newest, false)
+	check("next report", oldest, false)
+	if got := readAll(); got {
+		t.Errorf("next read: got Reset %v, want false", got)
+	}
+
+	// the report mustn't consume the marker of the reader, nor the reader
+	// the marker of the subscribers.
+	v.clearRows()
+	report()
+	check("report after clearing", newest, true)
+	check("report after clearing", oldest, true)
+	if got := readAll(); !got {
+		t.Errorf("read after report after clearing: got Reset %v, want true", got)
+	}
+	v.clearRows()
+	if got := readAll(); !got {
+		t.Errorf("read after clearing: got Reset %v, want true", got)
+	}
+	report()
+	check("report after read after clearing", newest, true)
+	check("report after read after clearing", oldest, true)
+
+	// the dropped markers are carried by the next ViewData delivered.
+	v.clearRows()
+	newest <- &ViewData{V: v}
+	report()
+	report()
+	check("dropped newest", newest, false)
+	check("dropped oldest", oldest, true)
+	report()
+	check("report after dropped newest", newest, true)
+	check("report after dropped oldest", oldest, false)
+}
+
+func Test_Worker_ReadAll(t *testing.T) {
+	RestartWorker()
+
+	m, _ := NewMeasureInt64("MI9", "desc MI9", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	subscribed := NewView("VI12", "desc VI12", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	forced := NewView("VI13", "desc VI13", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative(), WithResetOnCollect())
+	idle := NewView("VI14", "desc VI14", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	if err := SubscribeToView(subscribed, make(chan *ViewData, 1)); err != nil {
+		t.Fatalf("SubscribeToView got error '%v', want no error", err)
+	}
+	if err := ForceCollection(forced); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	if err := RegisterView(idle); err != nil {
+		t.Fatalf("RegisterView got error '%v', want no error", err)
+	}
+
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+	RecordInt64(ctx, m, 1)
+
+	for i, want := range []AggregationCountValue{1, 0} {
+		vds, err := ReadAll()
+		if err != nil {
+			t.Fatalf("ReadAll got error '%v', want no error", err)
+		}
+		if len(vds) != 2 || vds[0].V != subscribed || vds[1].V != forced {
+			t.Fatalf("ReadAll %v got %v view data, want the data of VI12 and VI13", i, len(vds))
+		}
+		if !vds[0].End.Equal(vds[1].End) {
+			t.Errorf("ReadAll %v got end times %v and %v, want equal", i, vds[0].End, vds[1].End)
+		}
+		var got AggregationCountValue
+		for _, r := range vds[1].Rows {
+			got += *(r.AggregationValue.(*AggregationCountValue))
+		}
+		if got != want {
+			t.Errorf("ReadAll %v got count %v for the view reset on collection, want %v", i, got, want)
+		}
+	}
+}
+
+func Test_Worker_FlushAndShutdown(t *testing.T) {
+	RestartWorker()
+
+	m, _ := NewMeasureInt64("MI10", "desc MI10", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VI15", "desc VI15", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	c := make(chan *ViewData, 3)
+	if err := SubscribeToView(v, c); err != nil {
+		t.Fatalf("SubscribeToView got error '%v', want no error", err)
+	}
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+
+	countReported := func() AggregationCountValue {
+		select {
+		case vd := <-c:
+			var got AggregationCountValue
+			for _, r := range vd.Rows {
+				got += *(r.AggregationValue.(*AggregationCountValue))
+			}
+			return got
+		default:
+			return -1
+		}
+	}
+
+	RecordInt64(ctx, m, 1)
+	Flush()
+	if got := countReported(); got != 1 {
+		t.Errorf("Flush reported count %v, want 1", got)
+	}
+
+	RecordInt64(ctx, m, 1)
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown got error '%v', want no error", err)
+	}
+	if got := countReported(); got != 2 {
+		t.Errorf("Shutdown reported count %v, want 2", got)
+	}
+
+	RecordInt64(ctx, m, 1)
+	SetReportingPeriod(time.Millisecond)
+	Flush()
+	if got := countReported(); got != -1 {
+		t.Errorf("Flush after Shutdown reported count %v, want no report", got)
+	}
+	rows, err := RetrieveData(v)
+	if err != nil {
+		t.Fatalf("RetrieveData after Shutdown got error '%v', want no error", err)
+	}
+	if got := *(rows[0].AggregationValue.(*AggregationCountValue)); got != 2 {
+		t.Errorf("RetrieveData after Shutdown got count %v, want 2 as the last measurement is dropped", got)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Shutdown(canceled); err != context.Canceled && err != nil {
+		t.Errorf("Shutdown with a canceled context got error '%v', want context.Canceled or no error", err)
+	}
+	RestartWorker()
+}
+
+func Test_Worker_RecordingDisabled(t *testing.T) {
+	RestartWorker()
+
+	m, _ := NewMeasureInt64("MI11", "desc MI11", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VI16", "desc VI16", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+	disabled := WithRecordingDisabled(ctx)
+	derived := tags.NewContextWithInsert(disabled, k1, "v2")
+
+	RecordInt64(ctx, m, 1)
+	RecordInt64(disabled, m, 1)
+	RecordInt64WithAttachments(derived, m, 1, map[string]string{"id": "1"})
+	Record(disabled, m.Is(1))
+	StartTimer(disabled, m).Stop()
+
+	rows, err := RetrieveData(v)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("RetrieveData got %v rows, want 1", len(rows))
+	}
+	if got := *(rows[0].AggregationValue.(*AggregationCountValue)); got != 1 {
+		t.Errorf("got count %v, want only the measurement recorded with recording enabled", got)
+	}
+}
+
+func Test_Worker_Recorder(t *testing.T) {
+	RestartWorker()
+
+	m, _ := NewMeasureInt64("MI12", "desc MI12", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	k2, _ := tags.CreateKeyString("k2")
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").InsertString(k2, "v2").Build()
+	rec := m.RecorderFor(ts)
+
+	v1 := NewView("VI17", "desc VI17", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	if err := ForceCollection(v1); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	rec.Record(1)
+	// a view registered after the creation of the recorder is recorded too.
+	v2 := NewView("VI18", "desc VI18", []tags.Key{k1, k2}, m, NewAggregationCount(), NewWindowCumulative())
+	if err := ForceCollection(v2); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	rec.Record(1)
+	RecordInt64(tags.NewContext(context.Background(), ts), m, 1)
+
+	type testCase struct {
+		v    View
+		want []*Row
+	}
+	tcs := []testCase{
+		{v1, []*Row{{Tags: []tags.Tag{{k1, []byte("v1")}}, AggregationValue: newAggregationCountValue(3)}}},
+		{v2, []*Row{{Tags: []tags.Tag{{k1, []byte("v1")}, {k2, []byte("v2")}}, AggregationValue: newAggregationCountValue(2)}}},
+	}
+	for _, tc := range tcs {
+		rows, err := RetrieveData(tc.v)
+		if err != nil {
+			t.Fatalf("RetrieveData '%v' got error '%v', want no error", tc.v.Name(), err)
+		}
+		if ok, msg := EqualRows(rows, tc.want); !ok {
+			t.Errorf("RetrieveData '%v' got unexpected rows. %v", tc.v.Name(), msg)
+		}
+	}
+}
+
+func Test_Worker_RecorderFastPath(t *testing.T) {
+	RestartWorker()
+
+	m, _ := NewMeasureFloat64("MF14", "desc MF14", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	ts := tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+
+	v1 := NewView("VF24", "desc VF24", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	if err := ForceCollection(v1); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	rec := m.RecorderFor(ts)
+	defer rec.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				rec.Record(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	rows, err := RetrieveData(v1)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	want := []*Row{{Tags: []tags.Tag{{k1, []byte("v1")}}, AggregationValue: newAggregationCountValue(1000)}}
+	if ok, msg := EqualRows(rows, want); !ok {
+		t.Errorf("RetrieveData got unexpected rows. %v", msg)
+	}
+
+	// the values matter to a distribution view, so the recorder sends them to
+	// the worker once the view is registered.
+	v2 := NewView("VF25", "desc VF25", []tags.Key{k1}, m, NewAggregationDistribution([]float64{2}), NewWindowCumulative())
+	if err := ForceCollection(v2); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	rec.Record(1)
+	rec.Record(3)
+
+	type testCase struct {
+		v    View
+		want []*Row
+	}
+	tcs := []testCase{
+		{v1, []*Row{{Tags: []tags.Tag{{k1, []byte("v1")}}, AggregationValue: newAggregationCountValue(1002)}}},
+		{v2, []*Row{{Tags: []tags.Tag{{k1, []byte("v1")}}, AggregationValue: NewDoNotUseTestingAggregationDistributionValue([]float64{2}, []int64{1, 1}, 2, 1, 3, 2, 2)}}},
+	}
+	for _, tc := range tcs {
+		rows, err := RetrieveData(tc.v)
+		if err != nil {
+			t.Fatalf("RetrieveData '%v' got error '%v', want no error", tc.v.Name(), err)
+		}
+		if ok, msg := EqualRows(rows, tc.want); !ok {
+			t.Errorf("RetrieveData '%v' got unexpected rows. %v", tc.v.Name(), msg)
+		}
+	}
+}
+
+func Test_Worker_SubscribeToViewBorrowed(t *testing.T) {
+	RestartWorker()
+
+	m, _ := NewMeasureInt64("MI13", "desc MI13", "1")
+	k1, _ := tags.CreateKeyString("k1")
+	v := NewView("VI19", "desc VI19", []tags.Key{k1}, m, NewAggregationCount(), NewWindowCumulative())
+	borrowed := make(chan *ViewData, 1)
+	shared := make(chan *ViewData, 1)
+	if err := SubscribeToViewBorrowed(v, borrowed); err != nil {
+		t.Fatalf("SubscribeToViewBorrowed got error '%v', want no error", err)
+	}
+	if err := SubscribeToView(v, shared); err != nil {
+		t.Fatalf("SubscribeToView got error '%v', want no error", err)
+	}
+	ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(k1, "v1").Build())
+
+	for i := 1; i <= 3; i++ {
+		RecordInt64(ctx, m, 1)
+		Flush()
+
+		want := []*Row{{Tags: []tags.Tag{{k1, []byte("v1")}}, AggregationValue: newAggregationCountValue(int64(i))}}
+		vd := <-borrowed
+		if ok, msg := EqualRows(vd.Rows, want); !ok {
+			t.Errorf("borrowed ViewData #%v got unexpected rows. %v", i, msg)
+		}
+		vd.Release()
+		vd = <-shared
+		if ok, msg := EqualRows(vd.Rows, want); !ok {
+			t.Errorf("shared ViewData #%v got unexpected rows. %v", i, msg)
+		}
+		// releasing a ViewData that isn't borrowed does nothing.
+		vd.Release()
+		if ok, msg := EqualRows(vd.Rows, want); !ok {
+			t.Errorf("shared ViewData #%v got unexpected rows after Release. %v", i, msg)
+		}
+	}
+}
+
+func Test_Worker_SubscribeWithProjection(t *testing.T) {
+	RestartWorker()
+
+	m, _ := NewMeasureInt64("MI25", "desc MI25", "1")
+	kMethod, _ := tags.CreateKeyString("method")
+	kUser, _ := tags.CreateKeyString("user")
+	kOther, _ := tags.CreateKeyString("other")
+	v := NewView("VI30", "desc VI30", []tags.Key{kMethod, kUser}, m, NewAggregationCount(), NewWindowCumulative())
+
+	if err := SubscribeToView(v, make(chan *ViewData, 1), WithProjection(kOther)); err == nil {
+		t.Error("SubscribeToView projecting onto a key of another view got no error, want error")
+	}
+
+	full := make(chan *ViewData, 1)
+	byMethod := make(chan *ViewData, 1)
+	none := make(chan *ViewData, 1)
+	if err := SubscribeToView(v, full); err != nil {
+		t.Fatalf("SubscribeToView got error '%v', want no error", err)
+	}
+	if err := SubscribeToViewBorrowed(v, byMethod, WithProjection(kMethod)); err != nil {
+		t.Fatalf("SubscribeToViewBorrowed got error '%v', want no error", err)
+	}
+	if err := SubscribeToView(v, none, WithProjection()); err != nil {
+		t.Fatalf("SubscribeToView got error '%v', want no error", err)
+	}
+
+	record := func(method, user string) {
+		ts := tags.NewTagSetBuilder(nil).InsertString(kMethod, method).InsertString(kUser, user).Build()
+		RecordInt64(tags.NewContext(context.Background(), ts), m, 1)
+	}
+	record("GET", "alice")
+	record("GET", "bob")
+	record("PUT", "bob")
+	Flush()
+
+	vd := <-full
+	if len(vd.Rows) != 3 {
+		t.Errorf("got %v rows for the full view, want 3", len(vd.Rows))
+	}
+	vd = <-byMethod
+	want := []*Row{
+		{Tags: []tags.Tag{{kMethod, []byte("GET")}}, AggregationValue: newAggregationCountValue(2)},
+		{Tags: []tags.Tag{{kMethod, []byte("PUT")}}, AggregationValue: newAggregationCountValue(1)},
+	}
+	if ok, msg := EqualRows(vd.Rows, want); !ok {
+		t.Errorf("got unexpected rows projected onto method. %v", msg)
+	}
+	vd.Release()
+	vd = <-none
+	want = []*Row{{Tags: nil, AggregationValue: newAggregationCountValue(3)}}
+	if ok, msg := EqualRows(vd.Rows, want); !ok {
+		t.Errorf("got unexpected rows projected onto no key. %v", msg)
+	}
+}
+
+func Test_Worker_ViewAliases(t *testing.T) {
+	RestartWorker()
+
+	m, _ := NewMeasureInt64("MI26", "desc MI26", "1")
+	old := NewView("VI31", "desc VI31", nil, m, NewAggregationCount(), NewWindowCumulative())
+	c := make(chan *ViewData, 2)
+	if err := SubscribeToView(old, c); err != nil {
+		t.Fatalf("SubscribeToView got error '%v', want no error", err)
+	}
+
+	renamed := NewView("VI32", "desc VI32", nil, m, NewAggregationCount(), NewWindowCumulative(), WithAliases("VI31"))
+	if err := RegisterView(renamed); !errors.Is(err, ErrDuplicateView) {
+		t.Errorf("RegisterView with the name of a registered view as alias got error %v, want %v", err, ErrDuplicateView)
+	}
+	if err := ReplaceView(old, renamed); err != nil {
+		t.Fatalf("ReplaceView by a view aliasing it got error '%v', want no error", err)
+	}
+	if got, err := GetViewByName("VI31"); err != nil || got != renamed {
+		t.Errorf("GetViewByName(alias) got %v, %v, want the renamed view", got, err)
+	}
+	if err := RegisterView(NewView("VI31", "desc VI31", nil, m, NewAggregationCount(), NewWindowCumulative())); !errors.Is(err, ErrDuplicateView) {
+		t.Errorf("RegisterView with the name of an alias got error %v, want %v", err, ErrDuplicateView)
+	}
+
+	RecordInt64(context.Background(), m, 1)
+	Flush()
+
+	names := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		vd := <-c
+		names[vd.V.Name()] = true
+		want := []*Row{{Tags: nil, AggregationValue: newAggregationCountValue(1)}}
+		if ok, msg := EqualRows(vd.Rows, want); !ok {
+			t.Errorf("got unexpected rows for %v. %v", vd.V.Name(), msg)
+		}
+	}
+	if !names["VI31"] || !names["VI32"] {
+		t.Errorf("got data reported for views %v, want VI31 and VI32", names)
+	}
+
+	if err := UnsubscribeFromView(renamed, c); err != nil {
+		t.Fatalf("UnsubscribeFromView got error '%v', want no error", err)
+	}
+	if err := UnregisterView(renamed); err != nil {
+		t.Fatalf("UnregisterView got error '%v', want no error", err)
+	}
+	if _, err := GetViewByName("VI31"); err == nil {
+		t.Error("GetViewByName(alias) of an unregistered view got no error, want error")
+	}
+}
+
+func Test_Worker_SubscribeMatching(t *testing.T) {
+	RestartWorker()
+
+	m, _ := NewMeasureInt64("MI27", "desc MI27", "1")
+	kMethod, _ := tags.CreateKeyString("method")
+	client := NewView("grpc.io/client/calls", "desc", []tags.Key{kMethod}, m, NewAggregationCount(), NewWindowCumulative())
+	other := NewView("http/calls", "desc", []tags.Key{kMethod}, m, NewAggregationCount(), NewWindowCumulative())
+	noKey := NewView("grpc.io/client/all", "desc", nil, m, NewAggregationCount(), NewWindowCumulative())
+	if err := RegisterViews(client, other, noKey); err != nil {
+		t.Fatalf("RegisterViews got error '%v', want no error", err)
+	}
+
+	if err := SubscribeMatching("grpc.io/[a-z]", make(chan *ViewData)); err == nil {
+		t.Error("SubscribeMatching with an invalid pattern got no error, want error")
+	}
+	c := make(chan *ViewData, 4)
+	if err := SubscribeMatching("grpc.io/*", c, WithTagEquals(kMethod, "GET")); err != nil {
+		t.Fatalf("SubscribeMatching got error '%v', want no error", err)
+	}
+	server := NewView("grpc.io/server/calls", "desc", []tags.Key{kMethod}, m, NewAggregationCount(), NewWindowCumulative())
+	if err := RegisterView(server); err != nil {
+		t.Fatalf("RegisterView got error '%v', want no error", err)
+	}
+
+	for _, method := range []string{"GET", "PUT"} {
+		ts := tags.NewTagSetBuilder(nil).InsertString(kMethod, method).Build()
+		RecordInt64(tags.NewContext(context.Background(), ts), m, 1)
+	}
+	Flush()
+
+	got := map[string]bool{}
+	for len(c) > 0 {
+		vd := <-c
+		got[vd.V.Name()] = true
+		want := []*Row{{Tags: []tags.Tag{{kMethod, []byte("GET")}}, AggregationValue: newAggregationCountValue(1)}}
+		if ok, msg := EqualRows(vd.Rows, want); !ok {
+			t.Errorf("got unexpected rows for %v. %v", vd.V.Name(), msg)
+		}
+	}
+	if len(got) != 2 || !got["grpc.io/client/calls"] || !got["grpc.io/server/calls"] {
+		t.Errorf("got data for views %v, want grpc.io/client/calls and grpc.io/server/calls", got)
+	}
+
+	if err := UnregisterView(server); err != nil {
+		t.Errorf("UnregisterView of a view subscribed by pattern got error '%v', want no error", err)
+	}
+	if err := UnsubscribeMatching("grpc.io/*", c); err != nil {
+		t.Fatalf("UnsubscribeMatching got error '%v', want no error", err)
+	}
+	if client.isCollecting() {
+		t.Error("view still collecting after UnsubscribeMatching, want not collecting")
+	}
+}
+
+func Test_MatchPattern(t *testing.T) {
+	type testCase struct {
+		pattern, name string
+		want          bool
+	}
+	tcs := []testCase{
+		{"grpc.io/*", "grpc.io/client/calls", true},
+		{"grpc.io/*", "grpc.io", false},
+		{"*/calls", "grpc.io/client/calls", true},
+		{"grpc.io/*/calls", "grpc.io/server/calls", true},
+		{"grpc.io/*/calls", "grpc.io/server/bytes", false},
+		{"v?", "v1", true},
+		{"v?", "v12", false},
+		{"*", "", true},
+		{"a*b*c", "aXbYbZc", true},
+		{"exact", "exact", true},
+	}
+	for _, tc := range tcs {
+		if got := matchPattern(tc.pattern, tc.name); got != tc.want {
+			t.Errorf("matchPattern(%q, %q) got %v, want %v", tc.pattern, tc.name, got, tc.want)
+		}
+	}
+}
+
+func Test_Worker_NonFinitePolicy(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	hv, err := EnableHealthViews()
+	if err != nil {
+		t.Fatalf("EnableHealthViews got error '%v', want no error", err)
+	}
+	if err := ForceCollection(hv.RecordsRejected); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+
+	type testCase struct {
+		label   string
+		policy  NonFinitePolicy
+		want    int64
+		wantMax float64
+	}
+	tcs := []testCase{
+		{"reject", NonFiniteReject, 1, 1},
+		{"clamp", NonFiniteClamp, 3, math.MaxFloat64},
+		{"allow", NonFiniteAllow, 4, math.Inf(1)},
+	}
+	ctx := context.Background()
+	for i, tc := range tcs {
+		name := fmt.Sprintf("MF%v", 20+i)
+		m, _ := NewMeasureFloat64(name, "desc "+name, "1")
+		m.SetNonFinitePolicy(tc.policy)
+		dist := NewView(fmt.Sprintf("VF%v", 26+2*i), "desc", nil, m, NewAggregationDistribution([]float64{0}), NewWindowCumulative())
+		count := NewView(fmt.Sprintf("VF%v", 27+2*i), "desc", nil, m, NewAggregationCount(), NewWindowCumulative())
+		for _, v := range []View{dist, count} {
+			if err := ForceCollection(v); err != nil {
+				t.Fatalf("%v: ForceCollection got error '%v', want no error", tc.label, err)
+			}
+		}
+		RecordFloat64(ctx, m, 1)
+		RecordFloat64(ctx, m, math.Inf(1))
+		Record(ctx, m.Is(math.Inf(-1)), m.Is(math.NaN()))
+		rows, err := RetrieveData(dist)
+		if err != nil {
+			t.Fatalf("%v: RetrieveData got error '%v', want no error", tc.label, err)
+		}
+		dv := rows[0].AggregationValue.(*AggregationDistributionValue)
+		if dv.Count() != tc.want || dv.Max() != tc.wantMax {
+			t.Errorf("%v: got %v samples with max %v, want %v samples with max %v", tc.label, dv.Count(), dv.Max(), tc.want, tc.wantMax)
+		}
+
+		// the recorders of counting views must apply the policy too.
+		if err := StopForcedCollection(dist); err != nil {
+			t.Fatalf("%v: StopForcedCollection got error '%v', want no error", tc.label, err)
+		}
+		if err := UnregisterView(dist); err != nil {
+			t.Fatalf("%v: UnregisterView got error '%v', want no error", tc.label, err)
+		}
+		rec := m.RecorderFor(nil)
+		rec.Record(math.NaN())
+		rec.Record(2)
+		rec.Close()
+		rows, err = RetrieveData(count)
+		if err != nil {
+			t.Fatalf("%v: RetrieveData got error '%v', want no error", tc.label, err)
+		}
+		want := tc.want + 1
+		if tc.policy == NonFiniteAllow {
+			want++
+		}
+		if got := int64(*rows[0].AggregationValue.(*AggregationCountValue)); got != want {
+			t.Errorf("%v: count view got %v samples, want %v", tc.label, got, want)
+		}
+	}
+
+	rows, err := RetrieveData(hv.RecordsRejected)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	SortRows(rows)
+	want := map[string]int64{"MF20": 4, "MF21": 2}
+	if len(rows) != len(want) {
+		t.Fatalf("RecordsRejected got rows %v, want rows for MF20 and MF21", rows)
+	}
+	for _, r := range rows {
+		if got := int64(*r.AggregationValue.(*AggregationCountValue)); got != want[string(r.Tags[0].V)] {
+			t.Errorf("RecordsRejected got %v for %s, want %v", got, r.Tags[0].V, want[string(r.Tags[0].V)])
+		}
+	}
+}
+
+func Test_Worker_ValueConstraint(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	hv, err := EnableHealthViews()
+	if err != nil {
+		t.Fatalf("EnableHealthViews got error '%v', want no error", err)
+	}
+	if err := ForceCollection(hv.RecordsRejected); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+
+	mi, _ := NewMeasureInt64("MI29", "desc MI29", "1")
+	mf, _ := NewMeasureFloat64("MF23", "desc MF23", "1")
+	md, _ := NewMeasureDuration("MD3", "desc MD3")
+	for _, m := range []interface {
+		SetConstraint(ValueConstraint)
+	}{mi, mf, md} {
+		m.SetConstraint(ConstraintNonNegative)
+	}
+	dist := NewView("VI35", "desc VI35", nil, mi, NewAggregationDistribution([]float64{0}), NewWindowCumulative())
+	count := NewView("VF32", "desc VF32", nil, mf, NewAggregationCount(), NewWindowCumulative())
+	durations := NewView("VD3", "desc VD3", nil, md, NewAggregationCount(), NewWindowCumulative())
+	for _, v := range []View{dist, count, durations} {
+		if err := ForceCollection(v); err != nil {
+			t.Fatalf("ForceCollection got error '%v', want no error", err)
+		}
+	}
+
+	ctx := context.Background()
+	RecordInt64(ctx, mi, -1)
+	RecordInt64(ctx, mi, 2)
+	RecordFloat64(ctx, mf, -0.5)
+	RecordFloat64(ctx, mf, 0)
+	RecordDuration(ctx, md, -time.Second)
+	Record(ctx, mi.Is(-3), mf.Is(-1), md.Is(time.Second))
+	recF := mf.RecorderFor(nil)
+	recF.Record(-2)
+	recF.Record(3)
+	recF.Close()
+	recD := md.RecorderFor(nil)
+	recD.Record(-time.Millisecond)
+	recD.Close()
+	err = RecordChecked(ctx, mi.Is(-4), mi.Is(4), mf.Is(-4))
+	if me, ok := err.(MultiError); !ok || len(me) != 2 {
+		t.Errorf("RecordChecked got error '%v', want a MultiError of 2 errors", err)
+	}
+	if err := RecordChecked(ctx, mi.Is(5)); err != nil {
+		t.Errorf("RecordChecked with a valid value got error '%v', want no error", err)
+	}
+
+	rows, err := RetrieveData(dist)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if dv := rows[0].AggregationValue.(*AggregationDistributionValue); dv.Count() != 3 || dv.Min() != 2 {
+		t.Errorf("got %v samples with min %v, want 3 samples with min 2", dv.Count(), dv.Min())
+	}
+	for v, want := range map[View]int64{count: 2, durations: 1} {
+		rows, err := RetrieveData(v)
+		if err != nil {
+			t.Fatalf("RetrieveData got error '%v', want no error", err)
+		}
+		if got := int64(*rows[0].AggregationValue.(*AggregationCountValue)); got != want {
+			t.Errorf("view %v got count %v, want %v", v.Name(), got, want)
+		}
+	}
+
+	rows, err = RetrieveData(hv.RecordsRejected)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	want := map[string]int64{"MI29": 2, "MF23": 3, "MD3": 2}
+	if len(rows) != len(want) {
+		t.Fatalf("RecordsRejected got rows %v, want a row per measure", rows)
+	}
+	for _, r := range rows {
+		if got := int64(*r.AggregationValue.(*AggregationCountValue)); got != want[string(r.Tags[0].V)] {
+			t.Errorf("RecordsRejected got %v for %s, want %v", got, r.Tags[0].V, want[string(r.Tags[0].V)])
+		}
+	}
+}
+
+func Test_Worker_HealthViews(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	hv, err := EnableHealthViews()
+	if err != nil {
+		t.Fatalf("EnableHealthViews got error '%v', want no error", err)
+	}
+	if again, err := EnableHealthViews(); err != nil || again != hv {
+		t.Errorf("EnableHealthViews called twice got %v, %v, want the same views and no error", again, err)
+	}
+	for _, v := range []View{hv.RecordsProcessed, hv.RecordsDropped, hv.ViewDataDropped, hv.QueueDelay, hv.CollectionLatency} {
+		if err := ForceCollection(v); err != nil {
+			t.Fatalf("ForceCollection(%v) got error '%v', want no error", v.Name(), err)
+		}
+	}
+
+	m, _ := NewMeasureInt64("MI11", "desc MI11", "1")
+	v := NewView("VI16", "desc VI16", nil, m, NewAggregationCount(), NewWindowCumulative())
+	// nobody receives from c, so the ViewData is dropped.
+	c := make(chan *ViewData)
+	if err := SubscribeToView(v, c); err != nil {
+		t.Fatalf("SubscribeToView got error '%v', want no error", err)
+	}
+	deleted, _ := NewMeasureInt64("MI12", "desc MI12", "1")
+	if err := DeleteMeasure(deleted); err != nil {
+		t.Fatalf("DeleteMeasure got error '%v', want no error", err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		RecordInt64(ctx, m, 1)
+	}
+	Record(ctx, m.Is(1), m.Is(2))
+	RecordInt64(ctx, deleted, 1)
+	Flush()
+
+	count := func(v View) int64 {
+		rows, err := RetrieveData(v)
+		if err != nil {
+			t.Fatalf("RetrieveData(%v) got error '%v', want no error", v.Name(), err)
+		}
+		var got int64
+		for _, r := range rows {
+			switch av := r.AggregationValue.(type) {
+			case *AggregationCountValue:
+				got += int64(*av)
+			case *AggregationDistributionValue:
+				got += av.Count()
+			}
+		}
+		return got
+	}
+	if got := count(hv.RecordsProcessed); got != 7 {
+		t.Errorf("RecordsProcessed got %v, want 7", got)
+	}
+	if got := count(hv.RecordsDropped); got != 1 {
+		t.Errorf("RecordsDropped got %v, want 1", got)
+	}
+	if got := count(hv.QueueDelay); got != 1 {
+		t.Errorf("QueueDelay got %v samples, want 1 as one record out of 64 is measured", got)
+	}
+
+	rows, err := RetrieveData(hv.ViewDataDropped)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if len(rows) != 1 || len(rows[0].Tags) != 1 || string(rows[0].Tags[0].V) != "VI16" {
+		t.Errorf("ViewDataDropped got rows %v, want one row for VI16", rows)
+	}
+	rows, err = RetrieveData(hv.CollectionLatency)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if len(rows) != 1 || string(rows[0].Tags[0].V) != "VI16" {
+		t.Errorf("CollectionLatency got rows %v, want one row for VI16", rows)
+	}
+}
+
+func Test_Worker_Backpressure(t *testing.T) {
+	type testCase struct {
+		label       string
+		opts        []SubscriptionOption
+		wantCount   AggregationCountValue
+		wantDropped uint64
+	}
+	tcs := []testCase{
+		{"drop newest", nil, 1, 2},
+		{"drop oldest", []SubscriptionOption{WithBackpressure(BackpressureDropOldest)}, 2, 2},
+		{"drop oldest after timeout", []SubscriptionOption{WithBackpressure(BackpressureDropOldest), WithSendTimeout(time.Millisecond)}, 2, 2},
+		{"block with timeout", []SubscriptionOption{WithBackpressure(BackpressureBlock), WithSendTimeout(time.Millisecond)}, 1, 2},
+	}
+
+	for _, tc := range tcs {
+		RestartWorker()
+		m, _ := NewMeasureInt64("MI13", "desc MI13", "1")
+		v := NewView("VI17", "desc VI17", nil, m, NewAggregationCount(), NewWindowCumulative())
+		c := make(chan *ViewData, 1)
+		if err := SubscribeToView(v, c, tc.opts...); err != nil {
+			t.Fatalf("%v: SubscribeToView got error '%v', want no error", tc.label, err)
+		}
+
+		RecordInt64(context.Background(), m, 1)
+		Flush()
+		RecordInt64(context.Background(), m, 1)
+		Flush()
+		Flush()
+
+		vd := <-c
+		if got := *(vd.Rows[0].AggregationValue.(*AggregationCountValue)); got != tc.wantCount {
+			t.Errorf("%v: got count %v, want %v", tc.label, got, tc.wantCount)
+		}
+		vi, err := GetViewInfo(v)
+		if err != nil {
+			t.Fatalf("%v: GetViewInfo got error '%v', want no error", tc.label, err)
+		}
+		if vi.DroppedViewData != tc.wantDropped {
+			t.Errorf("%v: DroppedViewData got %v, want %v", tc.label, vi.DroppedViewData, tc.wantDropped)
+		}
+	}
+	RestartWorker()
+}
+
+func Test_Worker_BackpressureBlock(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	m, _ := NewMeasureInt64("MI14", "desc MI14", "1")
+	v := NewView("VI18", "desc VI18", nil, m, NewAggregationCount(), NewWindowCumulative())
+	c := make(chan *ViewData)
+	if err := SubscribeToView(v, c, WithBackpressure(BackpressureBlock)); err != nil {
+		t.Fatalf("SubscribeToView got error '%v', want no error", err)
+	}
+	RecordInt64(context.Background(), m, 1)
+
+	received := make(chan *ViewData)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		received <- <-c
+	}()
+	// Flush returns once the subscriber received the ViewData.
+	Flush()
+	select {
+	case vd := <-received:
+		if len(vd.Rows) != 1 {
+			t.Errorf("got %v rows, want 1", len(vd.Rows))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the ViewData wasn't delivered")
+	}
+	if vi, _ := GetViewInfo(v); vi.DroppedViewData != 0 {
+		t.Errorf("DroppedViewData got %v, want 0", vi.DroppedViewData)
+	}
+}
+
+func Test_Worker_ForceCollectionFor(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	m, _ := NewMeasureInt64("MI15", "desc MI15", "1")
+	v := NewView("VI19", "desc VI19", nil, m, NewAggregationCount(), NewWindowCumulative())
+	if IsCollecting(v) {
+		t.Errorf("IsCollecting(v) got true before ForceCollectionFor, want false")
+	}
+	if err := ForceCollectionFor(v, 0); err == nil {
+		t.Errorf("ForceCollectionFor(v, 0) got no error, want error")
+	}
+	if err := ForceCollectionFor(v, 50*time.Millisecond); err != nil {
+		t.Fatalf("ForceCollectionFor got error '%v', want no error", err)
+	}
+	if !IsCollecting(v) {
+		t.Errorf("IsCollecting(v) got false after ForceCollectionFor, want true")
+	}
+	vi, err := GetViewInfo(v)
+	if err != nil {
+		t.Fatalf("GetViewInfo got error '%v', want no error", err)
+	}
+	if !vi.ForcedCollection || vi.ForcedCollectionExpiry.IsZero() {
+		t.Errorf("GetViewInfo got ForcedCollection %v and ForcedCollectionExpiry %v, want forced collection with expiry", vi.ForcedCollection, vi.ForcedCollectionExpiry)
+	}
+
+	RecordInt64(context.Background(), m, 1)
+	rows, err := RetrieveData(v)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if len(rows) != 1 {
+		t.Errorf("RetrieveData got %v rows before expiry, want 1", len(rows))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if IsCollecting(v) {
+		t.Errorf("IsCollecting(v) got true after expiry, want false")
+	}
+	if rows, _ := RetrieveData(v); len(rows) != 0 {
+		t.Errorf("RetrieveData got %v rows after expiry, want 0", len(rows))
+	}
+
+	// ForceCollection makes the collection never expire, and
+	// ForceCollectionFor doesn't shorten it.
+	if err := ForceCollectionFor(v, 50*time.Millisecond); err != nil {
+		t.Fatalf("ForceCollectionFor got error '%v', want no error", err)
+	}
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	if err := ForceCollectionFor(v, 50*time.Millisecond); err != nil {
+		t.Fatalf("ForceCollectionFor got error '%v', want no error", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !IsCollecting(v) {
+		t.Errorf("IsCollecting(v) got false after ForceCollection, want true")
+	}
+	if err := StopForcedCollection(v); err != nil {
+		t.Fatalf("StopForcedCollection got error '%v', want no error", err)
+	}
+	if IsCollecting(v) {
+		t.Errorf("IsCollecting(v) got true after StopForcedCollection, want false")
+	}
+}
+
+func Test_Worker_RetrieveDataFiltered(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	k1, _ := tags.CreateKeyString("k1")
+	k2, _ := tags.CreateKeyString("k2")
+	m, _ := NewMeasureInt64("MI16", "desc MI16", "1")
+	v := NewView("VI20", "desc VI20", []tags.Key{k1, k2}, m, NewAggregationCount(), NewWindowCumulative())
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	for _, vals := range [][2]string{{"GET", "a"}, {"GET", "b"}, {"POST", "a"}} {
+		ts := tags.NewTagSetBuilder(nil).InsertString(k1, vals[0]).InsertString(k2, vals[1]).Build()
+		RecordInt64(tags.NewContext(context.Background(), ts), m, 1)
+	}
+
+	rows, err := RetrieveDataFiltered(v, MatchTag(k1, "GET"))
+	if err != nil {
+		t.Fatalf("RetrieveDataFiltered got error '%v', want no error", err)
+	}
+	if len(rows) != 2 {
+		t.Errorf("RetrieveDataFiltered got %v rows, want 2", len(rows))
+	}
+	for _, r := range rows {
+		if !MatchTag(k1, "GET")(r.Tags) {
+			t.Errorf("RetrieveDataFiltered got row %v, want only rows with k1=GET", r)
+		}
+	}
+
+	rows, err = RetrieveDataFiltered(v, func(ts []tags.Tag) bool {
+		return MatchTag(k1, "POST")(ts) || MatchTag(k2, "b")(ts)
+	})
+	if err != nil {
+		t.Fatalf("RetrieveDataFiltered got error '%v', want no error", err)
+	}
+	if len(rows) != 2 {
+		t.Errorf("RetrieveDataFiltered with a custom predicate got %v rows, want 2", len(rows))
+	}
+
+	if _, err := RetrieveDataFiltered(v, nil); err == nil {
+		t.Errorf("RetrieveDataFiltered with nil predicate got no error, want error")
+	}
+}
+
+// typedKeys is the namespace of the keys of type int64 and bool of the tests,
+// which can only be created in a namespace, claimed once per process.
+var typedKeys, _ = tags.NewNamespace("stats.test.")
+
+func Test_Worker_RetrieveDataFilteredTypedKeys(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	k1, err := typedKeys.CreateKeyInt64("code")
+	if err != nil {
+		t.Fatalf("CreateKeyInt64 got error '%v', want no error", err)
+	}
+	k2, err := typedKeys.CreateKeyBool("cached")
+	if err != nil {
+		t.Fatalf("CreateKeyBool got error '%v', want no error", err)
+	}
+	m, _ := NewMeasureInt64("MI39", "desc MI39", "1")
+	v := NewView("VI51", "desc VI51", []tags.Key{k1, k2}, m, NewAggregationCount(), NewWindowCumulative())
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	for _, code := range []int64{200, 404, 500} {
+		ts := tags.NewTagSetBuilder(nil).InsertInt64(k1, code).InsertBool(k2, code == 200).Build()
+		RecordInt64(tags.NewContext(context.Background(), ts), m, 1)
+	}
+
+	type testCase struct {
+		label    string
+		pred     func([]tags.Tag) bool
+		wantRows int
+	}
+	tcs := []testCase{
+		{"int64 value", MatchTag(k1, "404"), 1},
+		{"missing int64 value", MatchTag(k1, "403"), 0},
+		{"bool value", MatchTag(k2, "false"), 2},
+		{"both values", func(ts []tags.Tag) bool { return MatchTag(k1, "200")(ts) && MatchTag(k2, "true")(ts) }, 1},
+	}
+	for _, tc := range tcs {
+		rows, err := RetrieveDataFiltered(v, tc.pred)
+		if err != nil {
+			t.Fatalf("%v: RetrieveDataFiltered got error '%v', want no error", tc.label, err)
+		}
+		if len(rows) != tc.wantRows {
+			t.Errorf("%v: RetrieveDataFiltered got %v rows, want %v", tc.label, len(rows), tc.wantRows)
+		}
+	}
+}
+
+func Test_Worker_RecordHooks(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	k1, _ := tags.CreateKeyString("k1")
+	m1, _ := NewMeasureInt64("MI17", "desc MI17", "1")
+	m2, _ := NewMeasureFloat64("MF17", "desc MF17", "1")
+	v1 := NewView("VI21", "desc VI21", []tags.Key{k1}, m1, NewAggregationCount(), NewWindowCumulative())
+	v2 := NewView("VF21", "desc VF21", []tags.Key{k1}, m2, NewAggregationCount(), NewWindowCumulative())
+	for _, v := range []View{v1, v2} {
+		if err := ForceCollection(v); err != nil {
+			t.Fatalf("ForceCollection got error '%v', want no error", err)
+		}
+	}
+
+	var intercepted []interface{}
+	RegisterRecordHook(func(ctx context.Context, m Measure, value interface{}) (context.Context, bool) {
+		intercepted = append(intercepted, value)
+		return ctx, true
+	})
+	// drops the negative values and tags the others with k1.
+	RegisterRecordHook(func(ctx context.Context, m Measure, value interface{}) (context.Context, bool) {
+		if v, ok := value.(int64); ok && v < 0 {
+			return ctx, false
+		}
+		return tags.NewContextWithInsert(ctx, k1, "injected"), true
+	})
+
+	ctx := context.Background()
+	RecordInt64(ctx, m1, 1)
+	RecordInt64(ctx, m1, -1)
+	Record(ctx, m1.Is(2), m1.Is(-2), m2.Is(3))
+
+	if want := []interface{}{int64(1), int64(-1), int64(2), int64(-2), float64(3)}; !reflect.DeepEqual(intercepted, want) {
+		t.Errorf("hook intercepted %v, want %v", intercepted, want)
+	}
+	want := []*Row{
+		{Tags: []tags.Tag{{K: k1, V: []byte("injected")}}, AggregationValue: newAggregationCountValue(2)},
+	}
+	rows, err := RetrieveData(v1)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if ok, msg := EqualRows(rows, want); !ok {
+		t.Errorf("RetrieveData(v1) got unexpected rows: %v", msg)
+	}
+	want[0].AggregationValue = newAggregationCountValue(1)
+	rows, err = RetrieveData(v2)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if ok, msg := EqualRows(rows, want); !ok {
+		t.Errorf("RetrieveData(v2) got unexpected rows: %v", msg)
+	}
+}
+
+func Test_Worker_GlobalTags(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	k1, _ := tags.CreateKeyString("k1")
+	k2, _ := tags.CreateKeyString("k2")
+	m, _ := NewMeasureInt64("MI18", "desc MI18", "1")
+	v := NewView("VI22", "desc VI22", []tags.Key{k1, k2}, m, NewAggregationCount(), NewWindowCumulative())
+	if err := ForceCollection(v); err != nil {
+		t.Fatalf("ForceCollection got error '%v', want no error", err)
+	}
+	rec := m.RecorderFor(tags.NewTagSetBuilder(nil).InsertString(k2, "rec").Build())
+	defer rec.Close()
+
+	SetGlobalTags(tags.NewTagSetBuilder(nil).InsertString(k1, "global").InsertString(k2, "global").Build())
+	ctx := context.Background()
+	RecordInt64(ctx, m, 1)
+	RecordInt64(tags.NewContextWithInsert(ctx, k2, "v2"), m, 1)
+	Record(tags.NewContextWithInsert(ctx, k2, "v2"), m.Is(1))
+	rec.Record(1)
+	SetGlobalTags(nil)
+	RecordInt64(ctx, m, 1)
+
+	want := []*Row{
+		{Tags: []tags.Tag{{K: k1, V: []byte("global")}, {K: k2, V: []byte("global")}}, AggregationValue: newAggregationCountValue(1)},
+		{Tags: []tags.Tag{{K: k1, V: []byte("global")}, {K: k2, V: []byte("v2")}}, AggregationValue: newAggregationCountValue(2)},
+		{Tags: []tags.Tag{{K: k1, V: []byte("global")}, {K: k2, V: []byte("rec")}}, AggregationValue: newAggregationCountValue(1)},
+		{Tags: nil, AggregationValue: newAggregationCountValue(1)},
+	}
+	rows, err := RetrieveData(v)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if ok, msg := EqualRows(rows, want); !ok {
+		t.Errorf("RetrieveData got unexpected rows: %v", msg)
+	}
+}
+
+func Test_Worker_Resource(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	m, _ := NewMeasureInt64("MI19", "desc MI19", "1")
+	v := NewView("VI23", "desc VI23", nil, m, NewAggregationCount(), NewWindowCumulative())
+	c := make(chan *ViewData, 1)
+	if err := SubscribeToView(v, c); err != nil {
+		t.Fatalf("SubscribeToView got error '%v', want no error", err)
+	}
+
+	res := &resource.Resource{Type: "host", Labels: map[string]string{"host.name": "h1"}}
+	SetResource(res)
+	vds, err := ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll got error '%v', want no error", err)
+	}
+	if len(vds) != 1 || vds[0].Resource != res {
+		t.Errorf("ReadAll got %v, want the data of VI23 in resource %v", vds, res)
+	}
+	Flush()
+	if vd := <-c; vd.Resource != res {
+		t.Errorf("reported ViewData got resource %v, want %v", vd.Resource, res)
+	}
+
+	SetResource(nil)
+	Flush()
+	if vd := <-c; vd.Resource != nil {
+		t.Errorf("reported ViewData got resource %v, want nil", vd.Resource)
+	}
+}
+
+func Test_Worker_ManualClock(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	start := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	SetClock(clock)
+	SetReportingPeriod(time.Minute)
+
+	m, _ := NewMeasureInt64("MI20", "desc MI20", "1")
+	v := NewView("VI24", "desc VI24", nil, m, NewAggregationCount(), NewWindowSlidingTime(time.Minute, 6))
+	c := make(chan *ViewData, 1)
+	if err := SubscribeToView(v, c); err != nil {
+		t.Fatalf("SubscribeToView got error '%v', want no error", err)
+	}
+
+	ctx := context.Background()
+	RecordInt64(ctx, m, 1)
+	clock.Advance(30 * time.Second)
+	RecordInt64(ctx, m, 1)
+
+	count := func(rows []*Row) AggregationCountValue {
+		var n AggregationCountValue
+		for _, r := range rows {
+			n += *(r.AggregationValue.(*AggregationCountValue))
+		}
+		return n
+	}
+	rows, err := RetrieveData(v)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if got := count(rows); got != 2 {
+		t.Errorf("RetrieveData after 30s got count %v, want 2", got)
+	}
+
+	// the reporting period elapses: the sample recorded at the start is still
+	// in the window.
+	clock.Advance(30 * time.Second)
+	vd := <-c
+	if !vd.End.Equal(start.Add(time.Minute)) {
+		t.Errorf("reported ViewData ends at %v, want %v", vd.End, start.Add(time.Minute))
+	}
+	if got := count(vd.Rows); got != 2 {
+		t.Errorf("reported ViewData got count %v, want 2", got)
+	}
+
+	// the reporting restarts the collection of the sliding window.
+	RecordInt64(ctx, m, 1)
+	clock.Advance(40 * time.Second)
+	rows, err = RetrieveData(v)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if got := count(rows); got != 1 {
+		t.Errorf("RetrieveData after 100s got count %v, want 1", got)
+	}
+}
+
+func Test_Worker_RateView(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	start := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	SetClock(clock)
+	SetReportingPeriod(time.Minute)
+
+	m, _ := NewMeasureInt64("MI30", "desc MI30", "1")
+	counter := NewView("VI36", "desc VI36", nil, m, NewAggregationCount(), NewWindowCumulative())
+	rate := NewRateView("VI36_rate", "desc VI36_rate", counter)
+	if _, ok := rate.Aggregation().(*AggregationRate); !ok {
+		t.Errorf("rate view got aggregation %T, want *AggregationRate", rate.Aggregation())
+	}
+	c := make(chan *ViewData, 1)
+	if err := SubscribeToView(rate, c); err != nil {
+		t.Fatalf("SubscribeToView got error '%v', want no error", err)
+	}
+
+	ctx := context.Background()
+	rateOf := func(rows []*Row) float64 {
+		if len(rows) != 1 {
+			t.Fatalf("got rows %v, want 1 row", rows)
+		}
+		return float64(*rows[0].AggregationValue.(*AggregationRateValue))
+	}
+	for i := 0; i < 30; i++ {
+		RecordInt64(ctx, m, 1)
+	}
+	clock.Advance(time.Minute)
+	if got := rateOf((<-c).Rows); got != 0.5 {
+		t.Errorf("reported rate after 30 samples in 1m got %v/s, want 0.5/s", got)
+	}
+
+	// the rate is computed since the previous collection.
+	for i := 0; i < 120; i++ {
+		RecordInt64(ctx, m, 1)
+	}
+	clock.Advance(time.Minute)
+	if got := rateOf((<-c).Rows); got != 2 {
+		t.Errorf("reported rate after 120 samples in 1m got %v/s, want 2/s", got)
+	}
+	rows, err := RetrieveData(rate)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	if got := rateOf(rows); got != 2 {
+		t.Errorf("RetrieveData at the time of the report got %v/s, want 2/s", got)
+	}
+
+	clock.Advance(time.Minute)
+	if got := rateOf((<-c).Rows); got != 0 {
+		t.Errorf("reported rate without samples got %v/s, want 0/s", got)
+	}
+}
+
+func Test_Worker_RatioView(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	kMethod, _ := tags.CreateKeyString("ratio_method")
+	kCode, _ := tags.CreateKeyString("ratio_code")
+	errs, _ := NewMeasureInt64("MI31", "desc MI31", "1")
+	latency, _ := NewMeasureFloat64("MF24", "desc MF24", "ms")
+	num := NewView("VI37", "desc VI37", []tags.Key{kMethod, kCode}, errs, NewAggregationCount(), NewWindowCumulative())
+	den := NewView("VF33", "desc VF33", []tags.Key{kMethod}, latency, NewAggregationDistribution([]float64{10}), NewWindowCumulative())
+	ratio := NewRatioView("VI37_ratio", "desc VI37_ratio", []tags.Key{kMethod}, num, den)
+	if _, ok := ratio.Aggregation().(*AggregationRatio); !ok {
+		t.Errorf("ratio view got aggregation %T, want *AggregationRatio", ratio.Aggregation())
+	}
+	for _, v := range []View{num, den, ratio} {
+		if err := ForceCollection(v); err != nil {
+			t.Fatalf("ForceCollection '%v' got error '%v', want no error", v.Name(), err)
+		}
+	}
+
+	record := func(method, code string, failed bool) {
+		ctx := tags.NewContext(context.Background(), tags.NewTagSetBuilder(nil).InsertString(kMethod, method).InsertString(kCode, code).Build())
+		RecordFloat64(ctx, latency, 5)
+		if failed {
+			RecordInt64(ctx, errs, 1)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		record("GET", "200", false)
+		record("PUT", "200", false)
+	}
+	record("GET", "500", true)
+	record("GET", "503", true)
+	record("POST", "200", false)
+
+	rows, err := RetrieveData(ratio)
+	if err != nil {
+		t.Fatalf("RetrieveData got error '%v', want no error", err)
+	}
+	want := map[string]float64{"GET": 2.0 / 6, "PUT": 0, "POST": 0}
+	if len(rows) != len(want) {
+		t.Fatalf("RetrieveData got rows %v, want a row per method", rows)
+	}
+	for _, r := range rows {
+		method := kMethod.ValueAsString(r.Tags[0].V)
+		if got := float64(*r.AggregationValue.(*AggregationRatioValue)); !floatsEqual(got, want[method], 1e-9) {
+			t.Errorf("ratio of %v got %v, want %v", method, got, want[method])
+		}
+	}
+
+	// the samples of the measure of the numerator aren't aggregated by the
+	// ratio view itself.
+	if err := StopForcedCollection(num); err != nil {
+		t.Fatalf("StopForcedCollection got error '%v', want no error", err)
+	}
+	record("GET", "500", true)
+	if rows, _ := RetrieveData(ratio); len(rows) != 3 || !floatsEqual(float64(*rows[0].AggregationValue.(*AggregationRatioValue)), 0, 1e-9) {
+		t.Errorf("RetrieveData without collecting the numerator got rows %v, want rows of 0", rows)
+	}
+}
+
+func Test_Worker_RecordAt(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	now := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(now)
+	SetClock(clock)
+
+	m, _ := NewMeasureFloat64("MF18", "desc MF18", "1")
+	sliding := NewView("VF22", "desc VF22", nil, m, NewAggregationCount(), NewWindowSlidingTime(time.Minute, 6))
+	cumulative := NewView("VF23", "desc VF23", nil, m, NewAggregationCount(), NewWindowCumulative())
+	for _, v := range []View{sliding, cumulative} {
+		if err := ForceCollection(v); err != nil {
+			t.Fatalf("ForceCollection got error '%v', want no error", err)
+		}
+	}
+
+	ctx := context.Background()
+	RecordFloat64(ctx, m, 1)
+	clock.Advance(5 * time.Minute)
+	now = clock.Now()
+	RecordFloat64At(ctx, m, 1, now.Add(-30*time.Second))
+	// before the oldest sub interval of the sliding window.
+	RecordFloat64At(ctx, m, 1, now.Add(-2*time.Minute))
+	// in the future, hence recorded now.
+	RecordFloat64At(ctx, m, 1, now.Add(time.Hour))
+
+	type testCase struct {
+		v    View
+		want AggregationCountValue
+	}
+	for _, tc := range []testCase{{sliding, 2}, {cumulative, 4}} {
+		rows, err := RetrieveData(tc.v)
+		if err != nil {
+			t.Fatalf("RetrieveData got error '%v', want no error", err)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("RetrieveData(%v) got %v rows, want 1", tc.v.Name(), len(rows))
+		}
+		if got := *(rows[0].AggregationValue.(*AggregationCountValue)); got != tc.want {
+			t.Errorf("RetrieveData(%v) got count %v, want %v", tc.v.Name(), got, tc.want)
+		}
+	}
+}
+
+func Test_Worker_SpanExemplars(t *testing.T) {
+	RestartWorker()
+	defer RestartWorker()
+
+	m, _ := NewMeasureFloat64("MF19", "desc MF19", "1")
+	v := NewView("VF24", "desc VF24", nil, m, NewAggregationDistribution([]float64{10, 100}), NewWindowCumulative(), WithSpanExemplars())
+	other := NewView("VF25", "desc VF25", nil, m, NewAggregationDistribution([]float64{10, 100}), NewWindowCumulative())
+	for _, v := range []View{v, other} {
+		if err := ForceCollection(v); err != nil {
+			t.Fatalf("ForceCollection got error '%v', want no error", err)
+		}
+	}
+
+	span := func(id byte, sampled bool) context.Context {
+		sc := trace.SpanContext{TraceID: trace.TraceID{id}, SpanID: trace.SpanID{id}}.WithSampled(sampled)
+		return trace.NewContext(context.Background(), sc)
+	}
+	RecordFloat64(span(1, true), m, 5)
+	RecordFloat64(span(2, true), m, 50)
+	RecordFloat64(span(3, true), m, 70)
+	RecordFloat64(span(4, false), m, 500)
+	RecordFloat64(context.Background(), m, 1000)
+
+	exemplars, err := RetrieveExemplars(v)
+	if err != nil {
+		t.Fatalf("RetrieveExemplars got error '%v', want no error", err)
+	}
+	got := make(map[float64]trace.SpanContext)
+	for _, e := range exemplars {
+		got[e.Value.(float64)] = e.SpanContext
+	}
+	// the last sample of each bucket recorded in a sampled span.
+	want := map[float64]trace.SpanContext{
+		5:  trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}}.WithSampled(true),
+		70: trace.SpanContext{TraceID: trace.TraceID{3}, SpanID: trace.SpanID{3}}.WithSampled(true),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RetrieveExemplars got %v, want %v", got, want)
+	}
+
+	if exemplars, _ := RetrieveExemplars(other); len(exemplars) != 0 {
+		t.Errorf("RetrieveExemplars of a view without WithSpanExemplars got %v, want none", exemplars)
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build censusaudit
+// +build censusaudit
+
+package tags
+
+// auditEnabled is true when the package is built with the censusaudit build
+// tag. In this mode checksums are maintained for the data shared between the
+// library and its callers and the library panics as soon as it detects that
+// the shared data was mutated. It is meant to be used in tests only:
+//
+//	go test -tags censusaudit ./...
+const auditEnabled = true
@@ -0,0 +1,43 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build censusaudit
+// +build censusaudit
+
+package tags
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func Test_Audit_MutatedTagSet(t *testing.T) {
+	k1, _ := CreateKeyString("k1")
+	ts := NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+
+	// Mutations performed by the library must not be reported.
+	ctx := NewContext(context.Background(), ts)
+	_ = FromContext(ctx)
+
+	ts.m[k1][0] = 'x'
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("NewContext() with a mutated TagSet didn't panic, want panic")
+		}
+	}()
+	NewContext(context.Background(), ts)
+}
@@ -0,0 +1,132 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tags
+
+import (
+	"fmt"
+	"testing"
+)
+
+// raceEnabled is set when the tests are built with the race detector.
+var raceEnabled = false
+
+// buildBudgets are the maximum numbers of allocations per TagSet built with
+// a TagSetBuilder once a TagSet with the same tags was built. The builders
+// are pooled and the TagSets are interned, so building TagSets of up to 8
+// tags doesn't allocate.
+var buildBudgets = []struct {
+	tags   int
+	allocs float64
+}{
+	{1, 0},
+	{4, 0},
+	{8, 0},
+}
+
+// setupBuildBenchmark returns nTags keys of each type and the string values
+// of the string keys.
+func setupBuildBenchmark(nTags int) ([]*KeyString, []*KeyInt64, []*KeyBool, []string) {
+	var ks []*KeyString
+	var ki []*KeyInt64
+	var kb []*KeyBool
+	var values []string
+	for i := 0; i < nTags; i++ {
+		k, _ := CreateKeyString(fmt.Sprintf("kbenchs%d", i))
+		ks = append(ks, k)
+		values = append(values, fmt.Sprintf("v%d", i))
+		k2, _ := CreateKeyInt64(fmt.Sprintf("kbenchi%d", i))
+		ki = append(ki, k2)
+		k3, _ := CreateKeyBool(fmt.Sprintf("kbenchb%d", i))
+		kb = append(kb, k3)
+	}
+	return ks, ki, kb, values
+}
+
+func buildStrings(ks []*KeyString, values []string) *TagSet {
+	tb := NewTagSetBuilder(nil)
+	for i, k := range ks {
+		tb.InsertString(k, values[i])
+	}
+	return tb.Build()
+}
+
+func Test_TagSetBuilder_AllocationBudget(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops objects randomly with the race detector")
+	}
+	if auditEnabled {
+		t.Skip("the builders are not reused with the censusaudit build tag")
+	}
+	for _, tc := range buildBudgets {
+		ks, ki, kb, values := setupBuildBenchmark(tc.tags)
+		buildStrings(ks, values)
+		got := testing.AllocsPerRun(1000, func() {
+			buildStrings(ks, values)
+		})
+		if got > tc.allocs {
+			t.Errorf("InsertString/Build with %v tags got %v allocations, want at most %v", tc.tags, got, tc.allocs)
+		}
+
+		build := func() *TagSet {
+			tb := NewTagSetBuilder(nil)
+			for i := range ki {
+				tb.UpsertInt64(ki[i], int64(i)).UpsertBool(kb[i], i%2 == 0)
+			}
+			return tb.Build()
+		}
+		build()
+		// the values of 2*tc.tags tags fit in the inline buffers.
+		if tc.tags > maxInlineTags/2 {
+			continue
+		}
+		got = testing.AllocsPerRun(1000, func() {
+			build()
+		})
+		if got > tc.allocs {
+			t.Errorf("UpsertInt64/UpsertBool/Build with %v tags got %v allocations, want at most %v", 2*tc.tags, got, tc.allocs)
+		}
+	}
+}
+
+func BenchmarkTagSetBuilder(b *testing.B) {
+	for _, nTags := range []int{1, 4, 8, 16} {
+		b.Run(fmt.Sprintf("tags=%d", nTags), func(b *testing.B) {
+			ks, _, _, values := setupBuildBenchmark(nTags)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				buildStrings(ks, values)
+			}
+		})
+	}
+}
+
+func BenchmarkTagSetBuilder_1000TagSets(b *testing.B) {
+	ks, _, _, _ := setupBuildBenchmark(8)
+	values := make([]string, 1000)
+	for i := range values {
+		values[i] = fmt.Sprintf("v%d", i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tb := NewTagSetBuilder(nil)
+		for j, k := range ks {
+			tb.InsertString(k, values[(i+j)%len(values)])
+		}
+		tb.Build()
+	}
+}
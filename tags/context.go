@@ -28,11 +28,45 @@ func FromContext(ctx context.Context) *TagSet {
 	if !ok {
 		ts = newTagSet(0)
 	}
+	ts.audit()
 	return ts
 }
 
 // NewContext creates a new context from the old one replacing any existing
 // TagSet with the new parameter TagSet ts.
 func NewContext(ctx context.Context, ts *TagSet) context.Context {
+	ts.audit()
 	return context.WithValue(ctx, ctxKey{}, ts)
 }
+
+// NewContextWithInsert returns a new context holding a copy of the TagSet of
+// ctx into which the string value v associated with the key k was inserted.
+// If a tag with the same key already exists the TagSet is left unchanged.
+func NewContextWithInsert(ctx context.Context, k *KeyString, v string) context.Context {
+	return NewContext(ctx, NewTagSetBuilder(FromContext(ctx)).InsertString(k, v).Build())
+}
+
+// UpdateInContext returns a new context holding a copy of the TagSet of ctx in
+// which the value associated with the key k was updated to the string value v.
+// If no tag with the same key exists the TagSet is left unchanged.
+func UpdateInContext(ctx context.Context, k *KeyString, v string) context.Context {
+	return NewContext(ctx, NewTagSetBuilder(FromContext(ctx)).UpdateString(k, v).Build())
+}
+
+// DeleteFromContext returns a new context holding a copy of the TagSet of ctx
+// from which the tag associated with the key k was deleted.
+func DeleteFromContext(ctx context.Context, k Key) context.Context {
+	return NewContext(ctx, NewTagSetBuilder(FromContext(ctx)).Delete(k).Build())
+}
+
+// Upsert returns a new context holding a copy of the TagSet of ctx into which
+// the tags given as pairs of a key and a value were upserted, e.g.
+// Upsert(ctx, method, "GET", code, 200). See NewTagSet for the types of the
+// values.
+func Upsert(ctx context.Context, pairs ...interface{}) (context.Context, error) {
+	ts, err := upsertPairs(NewTagSetBuilder(FromContext(ctx)), pairs)
+	if err != nil {
+		return ctx, err
+	}
+	return NewContext(ctx, ts), nil
+}
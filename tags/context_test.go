@@ -53,3 +53,63 @@ func Test_ContextWithNewTagSet_Add_Replace_Retrieve(t *testing.T) {
 		t.Errorf("got tag set %v, want tag set %v", got2, ts2)
 	}
 }
+
+func Test_Context_MutationHelpers(t *testing.T) {
+	km := newKeysManager()
+	k1, _ := km.createKeyString("k1")
+	k2, _ := km.createKeyString("k2")
+
+	ctx0 := context.Background()
+	ctx1 := NewContextWithInsert(ctx0, k1, "v1")
+	ctx2 := NewContextWithInsert(ctx1, k1, "v1 ignored")
+	ctx3 := UpdateInContext(ctx2, k1, "v1 updated")
+	ctx4 := UpdateInContext(ctx3, k2, "v2 ignored")
+	ctx5 := DeleteFromContext(ctx4, k1)
+
+	type want struct {
+		ctx  context.Context
+		k    Key
+		v    string
+		ok   bool
+		tags int
+	}
+	wants := []want{
+		{ctx0, k1, "", false, 0},
+		{ctx1, k1, "v1", true, 1},
+		{ctx2, k1, "v1", true, 1},
+		{ctx3, k1, "v1 updated", true, 1},
+		{ctx4, k2, "", false, 1},
+		{ctx5, k1, "", false, 0},
+	}
+	for i, w := range wants {
+		ts := FromContext(w.ctx)
+		if got, ok := ts.Value(w.k); got != w.v || ok != w.ok {
+			t.Errorf("context %v: Value(%v) got (%v, %v), want (%v, %v)", i, w.k, got, ok, w.v, w.ok)
+		}
+		if got := ts.Len(); got != w.tags {
+			t.Errorf("context %v: Len() got %v, want %v", i, got, w.tags)
+		}
+	}
+}
+
+func Test_Context_Upsert(t *testing.T) {
+	km := newKeysManager()
+	k1, _ := km.createKeyString("k1")
+	k2, _ := km.createKeyString("k2")
+
+	ctx := NewContextWithInsert(context.Background(), k1, "v1")
+	ctx, err := Upsert(ctx, k1, "v1 updated", k2, "v2")
+	if err != nil {
+		t.Fatalf("Upsert got error '%v', want no error", err)
+	}
+	ts := FromContext(ctx)
+	if v1, _ := ts.Value(k1); v1 != "v1 updated" {
+		t.Errorf("Value(k1) got %v, want v1 updated", v1)
+	}
+	if v2, _ := ts.Value(k2); v2 != "v2" {
+		t.Errorf("Value(k2) got %v, want v2", v2)
+	}
+	if got, err := Upsert(ctx, k1); err == nil || got != ctx {
+		t.Errorf("Upsert with an odd number of arguments got (%v, %v), want the context unchanged and an error", got, err)
+	}
+}
@@ -15,7 +15,11 @@
 
 package tags
 
-import "fmt"
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
 
 var keys []Key
 
@@ -52,5 +56,91 @@ func (k *KeyString) String() string {
 	return fmt.Sprintf("%v", k.Name())
 }
 
+// KeyInt64 implements the Key interface and is used to represent keys for
+// which the value type is an int64.
+type KeyInt64 struct {
+	name string
+	id   uint16
+}
+
+// Name returns the unique name of a key.
+func (k *KeyInt64) Name() string {
+	return k.name
+}
+
+// ID returns the id of a key inside the process.
+func (k *KeyInt64) ID() uint16 {
+	return k.id
+}
+
+// ValueAsString returns the value of the key as a string.
+func (k *KeyInt64) ValueAsString(b []byte) string {
+	return strconv.FormatInt(bytesToInt64(b), 10)
+}
+
+func (k *KeyInt64) String() string {
+	return fmt.Sprintf("%v", k.Name())
+}
+
+// KeyBool implements the Key interface and is used to represent keys for
+// which the value type is a bool.
+type KeyBool struct {
+	name string
+	id   uint16
+}
+
+// Name returns the unique name of a key.
+func (k *KeyBool) Name() string {
+	return k.name
+}
+
+// ID returns the id of a key inside the process.
+func (k *KeyBool) ID() uint16 {
+	return k.id
+}
+
+// ValueAsString returns the value of the key as a string.
+func (k *KeyBool) ValueAsString(b []byte) string {
+	return strconv.FormatBool(bytesToBool(b))
+}
+
+func (k *KeyBool) String() string {
+	return fmt.Sprintf("%v", k.Name())
+}
+
+// int64ToBytes and bytesToInt64 convert the values of keys of type *KeyInt64
+// to and from the []byte representation stored in a TagSet.
+func int64ToBytes(i int64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(i))
+	return b
+}
+
+func bytesToInt64(b []byte) int64 {
+	if len(b) != 8 {
+		return 0
+	}
+	return int64(binary.LittleEndian.Uint64(b))
+}
+
+// boolToBytes and bytesToBool convert the values of keys of type *KeyBool to
+// and from the []byte representation stored in a TagSet.
+func boolToBytes(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+func bytesToBool(b []byte) bool {
+	return len(b) == 1 && b[0] == 1
+}
+
 // CreateKeyString creates/retrieves the *KeyString identified by name.
 var CreateKeyString func(name string) (*KeyString, error)
+
+// CreateKeyInt64 creates/retrieves the *KeyInt64 identified by name.
+var CreateKeyInt64 func(name string) (*KeyInt64, error)
+
+// CreateKeyBool creates/retrieves the *KeyBool identified by name.
+var CreateKeyBool func(name string) (*KeyBool, error)
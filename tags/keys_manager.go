@@ -17,6 +17,7 @@ package tags
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 )
 
@@ -31,17 +32,48 @@ const (
 
 type keysManager struct {
 	*sync.Mutex
-	keys      map[string]Key
-	nextKeyID uint16
+	keys       map[string]Key
+	namespaces map[string]*Namespace
+	nextKeyID  uint16
 }
 
 func newKeysManager() *keysManager {
 	return &keysManager{
-		keys:  make(map[string]Key),
-		Mutex: &sync.Mutex{},
+		keys:       make(map[string]Key),
+		namespaces: make(map[string]*Namespace),
+		Mutex:      &sync.Mutex{},
 	}
 }
 
+// newNamespace claims prefix. Returns an error if prefix is invalid, if it
+// overlaps the prefix of an existing namespace, or if keys with that prefix
+// already exist.
+func (km *keysManager) newNamespace(prefix string) (*Namespace, error) {
+	if prefix == "" || !validateKeyName(prefix) {
+		return nil, fmt.Errorf("namespace prefix %q is invalid", prefix)
+	}
+	km.Lock()
+	defer km.Unlock()
+
+	for p := range km.namespaces {
+		if strings.HasPrefix(p, prefix) || strings.HasPrefix(prefix, p) {
+			return nil, fmt.Errorf("namespace prefix %q collides with namespace prefix %q already claimed", prefix, p)
+		}
+	}
+	for name := range km.keys {
+		if strings.HasPrefix(name, prefix) {
+			return nil, fmt.Errorf("namespace prefix %q collides with key %q already created outside a namespace", prefix, name)
+		}
+	}
+
+	ns := &Namespace{
+		km:     km,
+		prefix: prefix,
+	}
+	km.namespaces[prefix] = ns
+	return ns, nil
+}
+
 // CreateKeyString creates or retrieves a key of type keyString with name/ID
 // set to the input argument name. Returns an error if a key with the same name
 // exists and is of a different type.
@@ -70,6 +102,62 @@ func (km *keysManager) createKeyString(name string) (*KeyString, error) {
 	return ks, nil
 }
 
+// createKeyInt64 creates or retrieves a key of type *KeyInt64 with name/ID
+// set to the input argument name. Returns an error if a key with the same name
+// exists and is of a different type.
+func (km *keysManager) createKeyInt64(name string) (*KeyInt64, error) {
+	if !validateKeyName(name) {
+		return nil, fmt.Errorf("key name %v is invalid", name)
+	}
+	km.Lock()
+	defer km.Unlock()
+
+	k, ok := km.keys[name]
+	if ok {
+		ki, ok := k.(*KeyInt64)
+		if !ok {
+			return nil, fmt.Errorf("key with name %v cannot be created/retrieved as type *KeyInt64. It was already registered as type %T", name, k)
+		}
+		return ki, nil
+	}
+
+	ki := &KeyInt64{
+		name: name,
+		id:   km.nextKeyID,
+	}
+	km.nextKeyID++
+	km.keys[name] = ki
+	return ki, nil
+}
+
+// createKeyBool creates or retrieves a key of type *KeyBool with name/ID set
+// to the input argument name. Returns an error if a key with the same name
+// exists and is of a different type.
+func (km *keysManager) createKeyBool(name string) (*KeyBool, error) {
+	if !validateKeyName(name) {
+		return nil, fmt.Errorf("key name %v is invalid", name)
+	}
+	km.Lock()
+	defer km.Unlock()
+
+	k, ok := km.keys[name]
+	if ok {
+		kb, ok := k.(*KeyBool)
+		if !ok {
+			return nil, fmt.Errorf("key with name %v cannot be created/retrieved as type *KeyBool. It was already registered as type %T", name, k)
+		}
+		return kb, nil
+	}
+
+	kb := &KeyBool{
+		name: name,
+		id:   km.nextKeyID,
+	}
+	km.nextKeyID++
+	km.keys[name] = kb
+	return kb, nil
+}
+
 func (km *keysManager) count() int {
 	km.Lock()
 	defer km.Unlock()
@@ -82,12 +170,18 @@ func (km *keysManager) clear() {
 	for k := range km.keys {
 		delete(km.keys, k)
 	}
+	for p := range km.namespaces {
+		delete(km.namespaces, p)
+	}
 }
 
 func validateKeyName(name string) bool {
 	if len(name) >= maxKeyLength {
 		return false
 	}
+	if max := currentValidation().MaxKeyLength; max > 0 && len(name) > max {
+		return false
+	}
 	for _, c := range name {
 		if (c < validKeysMin) || (c > validKeysMax) {
 			return false
@@ -99,4 +193,7 @@ func validateKeyName(name string) bool {
 func init() {
 	km := newKeysManager()
 	CreateKeyString = km.createKeyString
+	CreateKeyInt64 = km.createKeyInt64
+	CreateKeyBool = km.createKeyBool
+	NewNamespace = km.newNamespace
 }
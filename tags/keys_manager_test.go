@@ -128,3 +128,73 @@ func Test_KeysManager_InvalidKeyErrors(t *testing.T) {
 		}
 	}
 }
+
+func Test_KeysManager_TypedKeys(t *testing.T) {
+	km := newKeysManager()
+
+	if _, err := km.createKeyInt64("ki"); err != nil {
+		t.Errorf("createKeyInt64(\"ki\") got error %v, want no error", err)
+	}
+	if _, err := km.createKeyBool("kb"); err != nil {
+		t.Errorf("createKeyBool(\"kb\") got error %v, want no error", err)
+	}
+	if _, err := km.createKeyString("ki"); err == nil {
+		t.Error("createKeyString(\"ki\") got no error, want error because it exists as *KeyInt64")
+	}
+	if _, err := km.createKeyInt64("kb"); err == nil {
+		t.Error("createKeyInt64(\"kb\") got no error, want error because it exists as *KeyBool")
+	}
+	if _, err := km.createKeyBool("ki"); err == nil {
+		t.Error("createKeyBool(\"ki\") got no error, want error because it exists as *KeyInt64")
+	}
+	if got := km.count(); got != 2 {
+		t.Errorf("got keys count %v, want 2", got)
+	}
+}
+
+func Test_KeysManager_Namespaces(t *testing.T) {
+	km := newKeysManager()
+
+	ns1, err := km.newNamespace("lib1/")
+	if err != nil {
+		t.Fatalf("newNamespace(\"lib1/\") got error %v, want no error", err)
+	}
+	ns2, err := km.newNamespace("lib2/")
+	if err != nil {
+		t.Fatalf("newNamespace(\"lib2/\") got error %v, want no error", err)
+	}
+
+	k1, err := ns1.CreateKeyString("method")
+	if err != nil {
+		t.Fatalf("ns1.CreateKeyString(\"method\") got error %v, want no error", err)
+	}
+	k2, err := ns2.CreateKeyInt64("method")
+	if err != nil {
+		t.Fatalf("ns2.CreateKeyInt64(\"method\") got error %v, want no error", err)
+	}
+	if k1.Name() != "lib1/method" || k2.Name() != "lib2/method" {
+		t.Errorf("got key names %q and %q, want \"lib1/method\" and \"lib2/method\"", k1.Name(), k2.Name())
+	}
+	if got, err := km.createKeyString("lib1/method"); err != nil || got != k1 {
+		t.Errorf("createKeyString(\"lib1/method\") got (%v, %v), want (%v, nil)", got, err, k1)
+	}
+
+	if _, err := km.newNamespace("lib1/"); err == nil {
+		t.Error("newNamespace(\"lib1/\") got no error, want error because it is already claimed")
+	}
+	if _, err := km.newNamespace("lib1/sub/"); err == nil {
+		t.Error("newNamespace(\"lib1/sub/\") got no error, want error because it overlaps \"lib1/\"")
+	}
+	if _, err := km.newNamespace("lib"); err == nil {
+		t.Error("newNamespace(\"lib\") got no error, want error because it overlaps \"lib1/\"")
+	}
+	if _, err := km.createKeyString("lib3/method"); err != nil {
+		t.Fatalf("createKeyString(\"lib3/method\") got error %v, want no error", err)
+	}
+	if _, err := km.newNamespace("lib3/"); err == nil {
+		t.Error("newNamespace(\"lib3/\") got no error, want error because key \"lib3/method\" already exists")
+	}
+	if _, err := km.newNamespace(""); err == nil {
+		t.Error("newNamespace(\"\") got no error, want error")
+	}
+}
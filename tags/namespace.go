@@ -0,0 +1,49 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tags
+
+// Namespace scopes the keys created by a library under a prefix owned by that
+// library, so that two libraries both defining a key "method" do not silently
+// share it and pollute each other's views.
+type Namespace struct {
+	km     *keysManager
+	prefix string
+}
+
+// NewNamespace claims prefix for the caller. It returns an error if prefix is
+// invalid, overlaps a prefix already claimed by another namespace, or if keys
+// under prefix were already created outside a namespace.
+var NewNamespace func(prefix string) (*Namespace, error)
+
+// Prefix returns the prefix owned by the namespace.
+func (ns *Namespace) Prefix() string {
+	return ns.prefix
+}
+
+// CreateKeyString creates/retrieves the *KeyString named prefix+name.
+func (ns *Namespace) CreateKeyString(name string) (*KeyString, error) {
+	return ns.km.createKeyString(ns.prefix + name)
+}
+
+// CreateKeyInt64 creates/retrieves the *KeyInt64 named prefix+name.
+func (ns *Namespace) CreateKeyInt64(name string) (*KeyInt64, error) {
+	return ns.km.createKeyInt64(ns.prefix + name)
+}
+
+// CreateKeyBool creates/retrieves the *KeyBool named prefix+name.
+func (ns *Namespace) CreateKeyBool(name string) (*KeyBool, error) {
+	return ns.km.createKeyBool(ns.prefix + name)
+}
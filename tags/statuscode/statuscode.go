@@ -0,0 +1,135 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package statuscode maps the gRPC codes and the HTTP status codes to
+// canonical tag values, e.g. "OK", "CANCELLED" or "5xx", so that the views of
+// all the services tag their data with the same values.
+package statuscode
+
+import (
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The canonical values of the gRPC codes.
+const (
+	OK                 = "OK"
+	Cancelled          = "CANCELLED"
+	Unknown            = "UNKNOWN"
+	InvalidArgument    = "INVALID_ARGUMENT"
+	DeadlineExceeded   = "DEADLINE_EXCEEDED"
+	NotFound           = "NOT_FOUND"
+	AlreadyExists      = "ALREADY_EXISTS"
+	PermissionDenied   = "PERMISSION_DENIED"
+	ResourceExhausted  = "RESOURCE_EXHAUSTED"
+	FailedPrecondition = "FAILED_PRECONDITION"
+	Aborted            = "ABORTED"
+	OutOfRange         = "OUT_OF_RANGE"
+	Unimplemented      = "UNIMPLEMENTED"
+	Internal           = "INTERNAL"
+	Unavailable        = "UNAVAILABLE"
+	DataLoss           = "DATA_LOSS"
+	Unauthenticated    = "UNAUTHENTICATED"
+)
+
+// The canonical values of the HTTP status classes. HTTPUnknown is the value
+// of the status codes outside of the classes.
+const (
+	HTTPInformational = "1xx"
+	HTTPSuccess       = "2xx"
+	HTTPRedirection   = "3xx"
+	HTTPClientError   = "4xx"
+	HTTPServerError   = "5xx"
+	HTTPUnknown       = "UNKNOWN"
+)
+
+var grpcCodes = map[codes.Code]string{
+	codes.OK:                 OK,
+	codes.Canceled:           Cancelled,
+	codes.Unknown:            Unknown,
+	codes.InvalidArgument:    InvalidArgument,
+	codes.DeadlineExceeded:   DeadlineExceeded,
+	codes.NotFound:           NotFound,
+	codes.AlreadyExists:      AlreadyExists,
+	codes.PermissionDenied:   PermissionDenied,
+	codes.ResourceExhausted:  ResourceExhausted,
+	codes.FailedPrecondition: FailedPrecondition,
+	codes.Aborted:            Aborted,
+	codes.OutOfRange:         OutOfRange,
+	codes.Unimplemented:      Unimplemented,
+	codes.Internal:           Internal,
+	codes.Unavailable:        Unavailable,
+	codes.DataLoss:           DataLoss,
+	codes.Unauthenticated:    Unauthenticated,
+}
+
+// FromGRPCCode returns the canonical value of c. The codes that aren't
+// defined by gRPC map to Unknown.
+func FromGRPCCode(c codes.Code) string {
+	if v, ok := grpcCodes[c]; ok {
+		return v
+	}
+	return Unknown
+}
+
+// FromError returns the canonical value of the gRPC code of err. A nil error
+// maps to OK, and the errors that aren't gRPC statuses map to Unknown.
+func FromError(err error) string {
+	if err == nil {
+		return OK
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return Unknown
+	}
+	return FromGRPCCode(s.Code())
+}
+
+// FromHTTPStatus returns the canonical value of the class of the HTTP status
+// code, e.g. "4xx" for 404.
+func FromHTTPStatus(code int) string {
+	switch {
+	case code >= 100 && code < 200:
+		return HTTPInformational
+	case code >= 200 && code < 300:
+		return HTTPSuccess
+	case code >= 300 && code < 400:
+		return HTTPRedirection
+	case code >= 400 && code < 500:
+		return HTTPClientError
+	case code >= 500 && code < 600:
+		return HTTPServerError
+	default:
+		return HTTPUnknown
+	}
+}
+
+// UpsertGRPCCode sets the canonical value of c for the key k in tsb.
+func UpsertGRPCCode(tsb tags.TagSetBuilder, k *tags.KeyString, c codes.Code) tags.TagSetBuilder {
+	return tsb.UpsertString(k, FromGRPCCode(c))
+}
+
+// UpsertError sets the canonical value of the gRPC code of err for the key k
+// in tsb.
+func UpsertError(tsb tags.TagSetBuilder, k *tags.KeyString, err error) tags.TagSetBuilder {
+	return tsb.UpsertString(k, FromError(err))
+}
+
+// UpsertHTTPStatus sets the canonical value of the class of the HTTP status
+// code for the key k in tsb.
+func UpsertHTTPStatus(tsb tags.TagSetBuilder, k *tags.KeyString, code int) tags.TagSetBuilder {
+	return tsb.UpsertString(k, FromHTTPStatus(code))
+}
@@ -0,0 +1,91 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package statuscode
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-go/tags"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_FromError(t *testing.T) {
+	type testCase struct {
+		label string
+		err   error
+		want  string
+	}
+	tcs := []testCase{
+		{"nil", nil, OK},
+		{"not found", status.Error(codes.NotFound, "missing"), NotFound},
+		{"cancelled", status.Error(codes.Canceled, "cancelled"), Cancelled},
+		{"not a status", errors.New("someError"), Unknown},
+		{"undefined code", status.Error(codes.Code(42), "undefined"), Unknown},
+	}
+	for _, tc := range tcs {
+		if got := FromError(tc.err); got != tc.want {
+			t.Errorf("%v: FromError() got %v, want %v", tc.label, got, tc.want)
+		}
+	}
+}
+
+func Test_FromGRPCCode_AllCodes(t *testing.T) {
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if got := FromGRPCCode(c); got == Unknown && c != codes.Unknown {
+			t.Errorf("FromGRPCCode(%v) got %v, want a canonical value", c, got)
+		}
+	}
+}
+
+func Test_FromHTTPStatus(t *testing.T) {
+	type testCase struct {
+		code int
+		want string
+	}
+	tcs := []testCase{
+		{100, HTTPInformational},
+		{200, HTTPSuccess},
+		{204, HTTPSuccess},
+		{301, HTTPRedirection},
+		{404, HTTPClientError},
+		{499, HTTPClientError},
+		{500, HTTPServerError},
+		{503, HTTPServerError},
+		{0, HTTPUnknown},
+		{600, HTTPUnknown},
+	}
+	for _, tc := range tcs {
+		if got := FromHTTPStatus(tc.code); got != tc.want {
+			t.Errorf("FromHTTPStatus(%v) got %v, want %v", tc.code, got, tc.want)
+		}
+	}
+}
+
+func Test_UpsertError(t *testing.T) {
+	k, err := tags.CreateKeyString("statuscode.test")
+	if err != nil {
+		t.Fatalf("CreateKeyString() got error %v, want no error", err)
+	}
+	tsb := tags.NewTagSetBuilder(nil)
+	tsb = UpsertHTTPStatus(tsb, k, 200)
+	ts := UpsertError(tsb, k, status.Error(codes.Unavailable, "down")).Build()
+	v, ok := ts.Value(k)
+	if !ok || v != Unavailable {
+		t.Errorf("got value %v, %v, want %v, true", v, ok, Unavailable)
+	}
+}
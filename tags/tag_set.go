@@ -17,7 +17,9 @@ package tags
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
 	"sort"
 )
 
@@ -26,6 +28,76 @@ import (
 // using the TagSetBuilder class.
 type TagSet struct {
 	m map[Key][]byte
+
+	// checksum is only maintained when the package is built with the
+	// censusaudit build tag. It detects the values of the TagSet being mutated
+	// by the callers.
+	checksum uint64
+}
+
+// Value returns the value associated with the key k formatted as a string, and
+// whether a value is associated with k.
+func (ts *TagSet) Value(k Key) (string, bool) {
+	b, ok := ts.m[k]
+	if !ok {
+		return "", false
+	}
+	return k.ValueAsString(b), true
+}
+
+// Merge returns a new TagSet holding the tags of base and of ts. The values of
+// ts take precedence over those of base for the keys present in both. base and
+// ts may be nil.
+func Merge(base, ts *TagSet) *TagSet {
+	tb := NewTagSetBuilder(base).(*tagSetBuilder)
+	if ts != nil {
+		ts.audit()
+		for k, b := range ts.m {
+			tb.upsertBytes(k, b)
+		}
+	}
+	return tb.Build()
+}
+
+// Len returns the number of tags in the TagSet.
+func (ts *TagSet) Len() int {
+	if ts == nil {
+		return 0
+	}
+	return len(ts.m)
+}
+
+// EncodedLength returns the length in bytes of the TagSet once encoded with
+// Encode, without encoding it.
+func (ts *TagSet) EncodedLength() int {
+	var scratch [binary.MaxVarintLen64]byte
+	n := 1 // version byte
+	if ts == nil {
+		return n
+	}
+	for k, v := range ts.m {
+		name := k.Name()
+		n += 1 + binary.PutUvarint(scratch[:], uint64(len(name))) + len(name)
+		switch k.(type) {
+		case *KeyInt64:
+			n += 8
+		case *KeyBool:
+		default:
+			n += binary.PutUvarint(scratch[:], uint64(len(v))) + len(v)
+		}
+	}
+	return n
+}
+
+// Foreach calls f for each tag of the TagSet in the order of the key names
+// until f returns false. The value passed to f is shared with the TagSet and
+// must not be modified.
+func (ts *TagSet) Foreach(f func(k Key, v []byte) bool) {
+	for _, k := range ts.sortedKeys() {
+		if !f(k, ts.m[k]) {
+			return
+		}
+	}
 }
 
 // ValueAsString returns the string associated with a specified key.
@@ -41,33 +113,100 @@ func (ts *TagSet) ValueAsString(k Key) (string, error) {
 	return string(b), nil
 }
 
+// ValueAsInt64 returns the int64 associated with a specified key.
+func (ts *TagSet) ValueAsInt64(k Key) (int64, error) {
+	if _, ok := k.(*KeyInt64); !ok {
+		return 0, fmt.Errorf("values of key '%v' are not of type int64", k.Name())
+	}
+
+	b, ok := ts.m[k]
+	if !ok {
+		return 0, fmt.Errorf("no value assigned to tag key '%v'", k.Name())
+	}
+	return bytesToInt64(b), nil
+}
+
+// ValueAsBool returns the bool associated with a specified key.
+func (ts *TagSet) ValueAsBool(k Key) (bool, error) {
+	if _, ok := k.(*KeyBool); !ok {
+		return false, fmt.Errorf("values of key '%v' are not of type bool", k.Name())
+	}
+
+	b, ok := ts.m[k]
+	if !ok {
+		return false, fmt.Errorf("no value assigned to tag key '%v'", k.Name())
+	}
+	return bytesToBool(b), nil
+}
+
 func newTagSet(sizeHint int) *TagSet {
 	return &TagSet{
 		m: make(map[Key][]byte, sizeHint),
 	}
 }
 
-func (ts *TagSet) String() string {
+func (ts *TagSet) sortedKeys() []Key {
 	var keys []Key
 	for k := range ts.m {
 		keys = append(keys, k)
 	}
 	sort.Slice(keys, func(i, j int) bool { return keys[i].Name() < keys[j].Name() })
+	return keys
+}
 
+// String returns the tags of the TagSet in the order of their key names, e.g.
+// "{ {method GET}{status 200} }". It is stable: equal TagSets have the same
+// string.
+func (ts *TagSet) String() string {
+	if ts == nil {
+		return "{  }"
+	}
 	var buffer bytes.Buffer
 	buffer.WriteString("{ ")
-	for _, k := range keys {
+	for _, k := range ts.sortedKeys() {
 		buffer.WriteString(fmt.Sprintf("{%v %v}", k.Name(), k.ValueAsString(ts.m[k])))
 	}
 	buffer.WriteString(" }")
 	return buffer.String()
 }
 
+// Equal returns true if ts and other hold the same tags. A nil TagSet is equal
+// to an empty TagSet.
+func (ts *TagSet) Equal(other *TagSet) bool {
+	if ts.Len() != other.Len() {
+		return false
+	}
+	if ts.Len() == 0 {
+		return true
+	}
+	for k, v := range ts.m {
+		ov, ok := other.m[k]
+		if !ok || !bytes.Equal(v, ov) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns a 64-bit FNV-1a hash of the encoding of the TagSet (see
+// Encode). Equal TagSets have the same hash, in all the processes, so it can
+// be used to index TagSets, e.g. as the key of a map holding the TagSets in
+// its values.
+func (ts *TagSet) Hash() uint64 {
+	h := fnv.New64a()
+	if ts == nil {
+		ts = newTagSet(0)
+	}
+	h.Write(encode(ts))
+	return h.Sum64()
+}
+
 func (ts *TagSet) insertBytes(k Key, b []byte) bool {
 	if _, ok := ts.m[k]; ok {
 		return false
 	}
 	ts.m[k] = b
+	ts.seal()
 	return true
 }
 
@@ -76,13 +215,51 @@ func (ts *TagSet) updateBytes(k Key, b []byte) bool {
 		return false
 	}
 	ts.m[k] = b
+	ts.seal()
 	return true
 }
 
 func (ts *TagSet) upsertBytes(k Key, b []byte) {
 	ts.m[k] = b
+	ts.seal()
 }
 
 func (ts *TagSet) delete(k Key) {
 	delete(ts.m, k)
+	ts.seal()
+}
+
+// seal records the checksum of the TagSet after it was modified by the
+// library. It is a no-op unless the censusaudit build tag is set.
+func (ts *TagSet) seal() {
+	if !auditEnabled {
+		return
+	}
+	ts.checksum = ts.computeChecksum()
+}
+
+// audit panics if the TagSet was modified since it was last sealed. It is a
+// no-op unless the censusaudit build tag is set.
+func (ts *TagSet) audit() {
+	if !auditEnabled || ts == nil || ts.checksum == 0 {
+		// checksum is 0 for TagSets that were never sealed by the library.
+		return
+	}
+	if ts.checksum != ts.computeChecksum() {
+		panic(fmt.Sprintf("tags: TagSet %v was mutated after it was built. TagSets and the values they hold must not be modified", ts))
+	}
+}
+
+// computeChecksum returns a checksum of the keys and values of the TagSet
+// which doesn't depend on the iteration order of the map.
+func (ts *TagSet) computeChecksum() uint64 {
+	var sum uint64
+	for k, v := range ts.m {
+		h := fnv.New64a()
+		h.Write([]byte(k.Name()))
+		h.Write([]byte{0})
+		h.Write(v)
+		sum += h.Sum64()
+	}
+	return sum
 }
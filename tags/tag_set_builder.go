@@ -15,6 +15,12 @@
 
 package tags
 
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
 // TagSetBuilder is the interface for the tagSet builder. Its purpose to ensure
 // a TagSet can be built from multiple pieces over time but that it is
 // immutable once built.
@@ -22,50 +28,160 @@ type TagSetBuilder interface {
 	InsertString(k *KeyString, s string) TagSetBuilder
 	UpdateString(k *KeyString, s string) TagSetBuilder
 	UpsertString(k *KeyString, s string) TagSetBuilder
+	InsertInt64(k *KeyInt64, i int64) TagSetBuilder
+	UpdateInt64(k *KeyInt64, i int64) TagSetBuilder
+	UpsertInt64(k *KeyInt64, i int64) TagSetBuilder
+	InsertBool(k *KeyBool, b bool) TagSetBuilder
+	UpdateBool(k *KeyBool, b bool) TagSetBuilder
+	UpsertBool(k *KeyBool, b bool) TagSetBuilder
 	Delete(k Key) TagSetBuilder
 	Build() *TagSet
+	BuildChecked() (*TagSet, error)
+}
+
+const (
+	// maxInlineTags is the number of tags a builder holds without
+	// allocating.
+	maxInlineTags = 8
+	// inlineValuesSize is the total length of the values a builder holds
+	// without allocating.
+	inlineValuesSize = 256
+	// maxPooledValuesSize is the capacity above which the values buffer of a
+	// builder is released rather than reused.
+	maxPooledValuesSize = 4096
+)
+
+// builderOp defines whether setting the value of a key in a builder inserts,
+// updates or upserts the tag.
+type builderOp int
+
+const (
+	opInsert builderOp = iota
+	opUpdate
+	opUpsert
+)
+
+// builderTag is a tag being built. Its value is buf[start:end] in the
+// builder.
+type builderTag struct {
+	k          Key
+	start, end int
 }
 
+// tagSetBuilder accumulates the tags in buffers reused across builders, so
+// that building a TagSet of up to maxInlineTags tags doesn't allocate once
+// the TagSet was interned, see Build.
 type tagSetBuilder struct {
-	ts *TagSet
+	tags  []builderTag
+	buf   []byte
+	built bool
+
+	inlineTags [maxInlineTags]builderTag
+	inlineBuf  [inlineValuesSize]byte
 }
 
-// NewTagSetBuilder starts building a new TagSet from an existing TagSet.
+var builderPool = sync.Pool{
+	New: func() interface{} {
+		tb := &tagSetBuilder{}
+		tb.tags = tb.inlineTags[:0]
+		tb.buf = tb.inlineBuf[:0]
+		return tb
+	},
+}
+
+// NewTagSetBuilder starts building a new TagSet from an existing TagSet. The
+// builders are reused once built, hence a builder must not be used after
+// Build or BuildChecked returned.
 func NewTagSetBuilder(ts *TagSet) TagSetBuilder {
-	tb := &tagSetBuilder{}
+	tb := builderPool.Get().(*tagSetBuilder)
+	tb.built = false
 
 	if ts == nil {
-		tb.ts = newTagSet(0)
 		return tb
 	}
 
-	tb.ts = newTagSet(len(ts.m))
+	ts.audit()
 	for k, b := range ts.m {
-		tb.ts.upsertBytes(k, b)
+		tb.upsertBytes(k, b)
 	}
 	return tb
 }
 
 // InsertString inserts a string value 's' associated with the the key 'k' in
 // the tags set being built. If a tag with the same key already exists in the
-// tags set being built then this is a no-op.
+// tags set being built then this is a no-op. The value is validated as
+// configured by SetValidation.
 func (tb *tagSetBuilder) InsertString(k *KeyString, s string) TagSetBuilder {
-	tb.insertBytes(k, []byte(s))
+	if v, ok := validateString(s); ok {
+		tb.putString(k, opInsert, v)
+	}
 	return tb
 }
 
 // UpdateString updates a string value 's' associated with the the key 'k' in
 // the tags set being built. If a no tag with the same key is already present
-// in the tags set being built then this is a no-op.
+// in the tags set being built then this is a no-op. The value is validated as
+// configured by SetValidation.
 func (tb *tagSetBuilder) UpdateString(k *KeyString, s string) TagSetBuilder {
-	tb.updateBytes(k, []byte(s))
+	if v, ok := validateString(s); ok {
+		tb.putString(k, opUpdate, v)
+	}
 	return tb
 }
 
 // UpsertString updates or insert a string value 's' associated with the key
-// 'k' in the tags set being built.
+// 'k' in the tags set being built. The value is validated as configured by
+// SetValidation.
 func (tb *tagSetBuilder) UpsertString(k *KeyString, s string) TagSetBuilder {
-	tb.upsertBytes(k, []byte(s))
+	if v, ok := validateString(s); ok {
+		tb.putString(k, opUpsert, v)
+	}
+	return tb
+}
+
+// InsertInt64 inserts an int64 value 'i' associated with the the key 'k' in
+// the tags set being built. If a tag with the same key already exists in the
+// tags set being built then this is a no-op.
+func (tb *tagSetBuilder) InsertInt64(k *KeyInt64, i int64) TagSetBuilder {
+	tb.putInt64(k, opInsert, i)
+	return tb
+}
+
+// UpdateInt64 updates an int64 value 'i' associated with the the key 'k' in
+// the tags set being built. If a no tag with the same key is already present
+// in the tags set being built then this is a no-op.
+func (tb *tagSetBuilder) UpdateInt64(k *KeyInt64, i int64) TagSetBuilder {
+	tb.putInt64(k, opUpdate, i)
+	return tb
+}
+
+// UpsertInt64 updates or insert an int64 value 'i' associated with the key
+// 'k' in the tags set being built.
+func (tb *tagSetBuilder) UpsertInt64(k *KeyInt64, i int64) TagSetBuilder {
+	tb.putInt64(k, opUpsert, i)
+	return tb
+}
+
+// InsertBool inserts a bool value 'b' associated with the the key 'k' in the
+// tags set being built. If a tag with the same key already exists in the tags
+// set being built then this is a no-op.
+func (tb *tagSetBuilder) InsertBool(k *KeyBool, b bool) TagSetBuilder {
+	tb.putBool(k, opInsert, b)
+	return tb
+}
+
+// UpdateBool updates a bool value 'b' associated with the the key 'k' in the
+// tags set being built. If a no tag with the same key is already present in
+// the tags set being built then this is a no-op.
+func (tb *tagSetBuilder) UpdateBool(k *KeyBool, b bool) TagSetBuilder {
+	tb.putBool(k, opUpdate, b)
+	return tb
+}
+
+// UpsertBool updates or insert a bool value 'b' associated with the key 'k'
+// in the tags set being built.
+func (tb *tagSetBuilder) UpsertBool(k *KeyBool, b bool) TagSetBuilder {
+	tb.putBool(k, opUpsert, b)
 	return tb
 }
 
@@ -73,28 +189,215 @@ func (tb *tagSetBuilder) UpsertString(k *KeyString, s string) TagSetBuilder {
 // built. If a no tag with the same key exists in the tags set being built then
 // this is a no-op.
 func (tb *tagSetBuilder) Delete(k Key) TagSetBuilder {
-	tb.ts.delete(k)
+	tb.checkNotBuilt()
+	if i := tb.index(k); i >= 0 {
+		last := len(tb.tags) - 1
+		tb.tags[i] = tb.tags[last]
+		tb.tags = tb.tags[:last]
+	}
 	return tb
 }
 
-// Build returns the built TagSet and clears the builder.
+// Build returns the built TagSet and releases the builder, which must not be
+// used anymore. It does not enforce the maximums configured with
+// SetValidation, see BuildChecked.
+//
+// The TagSets built recently are interned: Build returns the same TagSet for
+// builders holding the same tags, without allocating it again.
 func (tb *tagSetBuilder) Build() *TagSet {
-	ts := tb.ts
-	tb.ts = nil
+	tb.checkNotBuilt()
+	ts := interned.lookupOrAdd(tb)
+	tb.release()
 	return ts
 }
 
+// BuildChecked is like Build but returns a *LimitError if the TagSet holds
+// more tags or has a longer encoding than configured with SetValidation, so
+// that oversized TagSets are rejected when built rather than when propagated.
+// The builder is released in both cases.
+func (tb *tagSetBuilder) BuildChecked() (*TagSet, error) {
+	ts := tb.Build()
+	if err := checkLimits(ts); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
 func (tb *tagSetBuilder) insertBytes(k Key, bs []byte) *tagSetBuilder {
-	tb.ts.insertBytes(k, bs)
+	tb.putBytes(k, opInsert, bs)
 	return tb
 }
 
 func (tb *tagSetBuilder) updateBytes(k Key, bs []byte) *tagSetBuilder {
-	tb.ts.updateBytes(k, bs)
+	tb.putBytes(k, opUpdate, bs)
 	return tb
 }
 
 func (tb *tagSetBuilder) upsertBytes(k Key, bs []byte) *tagSetBuilder {
-	tb.ts.upsertBytes(k, bs)
+	tb.putBytes(k, opUpsert, bs)
 	return tb
 }
+
+// index returns the index of the tag of k in tb.tags, or -1 if there is none.
+func (tb *tagSetBuilder) index(k Key) int {
+	for i := range tb.tags {
+		if tb.tags[i].k == k {
+			return i
+		}
+	}
+	return -1
+}
+
+// slot returns the index of the tag of k in tb.tags, -1 if there is none, and
+// whether op sets its value.
+func (tb *tagSetBuilder) slot(k Key, op builderOp) (int, bool) {
+	tb.checkNotBuilt()
+	i := tb.index(k)
+	switch op {
+	case opInsert:
+		return i, i < 0
+	case opUpdate:
+		return i, i >= 0
+	default:
+		return i, true
+	}
+}
+
+// store sets the value of the tag at index i, or of a new tag of k if i is -1,
+// to the bytes appended to tb.buf from start.
+func (tb *tagSetBuilder) store(i int, k Key, start int) {
+	if i < 0 {
+		tb.tags = append(tb.tags, builderTag{k: k})
+		i = len(tb.tags) - 1
+	}
+	tb.tags[i].start, tb.tags[i].end = start, len(tb.buf)
+}
+
+func (tb *tagSetBuilder) putBytes(k Key, op builderOp, v []byte) {
+	i, ok := tb.slot(k, op)
+	if !ok {
+		return
+	}
+	start := len(tb.buf)
+	tb.buf = append(tb.buf, v...)
+	tb.store(i, k, start)
+}
+
+func (tb *tagSetBuilder) putString(k Key, op builderOp, v string) {
+	i, ok := tb.slot(k, op)
+	if !ok {
+		return
+	}
+	start := len(tb.buf)
+	tb.buf = append(tb.buf, v...)
+	tb.store(i, k, start)
+}
+
+func (tb *tagSetBuilder) putInt64(k Key, op builderOp, v int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(v))
+	tb.putBytes(k, op, b[:])
+}
+
+func (tb *tagSetBuilder) putBool(k Key, op builderOp, v bool) {
+	var b [1]byte
+	if v {
+		b[0] = 1
+	}
+	tb.putBytes(k, op, b[:])
+}
+
+// value returns the value of the tag t being built.
+func (tb *tagSetBuilder) value(t builderTag) []byte {
+	return tb.buf[t.start:t.end]
+}
+
+// newTagSet returns a new TagSet holding the tags being built. The values are
+// copied to a single buffer owned by the TagSet.
+func (tb *tagSetBuilder) newTagSet() *TagSet {
+	n := 0
+	for _, t := range tb.tags {
+		n += t.end - t.start
+	}
+	values := make([]byte, 0, n)
+	ts := newTagSet(len(tb.tags))
+	for _, t := range tb.tags {
+		start := len(values)
+		values = append(values, tb.value(t)...)
+		ts.m[t.k] = values[start:len(values):len(values)]
+	}
+	ts.seal()
+	return ts
+}
+
+// checkNotBuilt panics if the builder is used after Build. Misuses are only
+// detected until the builder is reused, unless the censusaudit build tag is
+// set, in which case the builders are never reused.
+func (tb *tagSetBuilder) checkNotBuilt() {
+	if tb.built {
+		panic("tags: TagSetBuilder used after Build")
+	}
+}
+
+// release clears the builder and returns it to the pool.
+func (tb *tagSetBuilder) release() {
+	tb.built = true
+	for i := range tb.tags {
+		tb.tags[i].k = nil
+	}
+	tb.tags = tb.tags[:0]
+	tb.buf = tb.buf[:0]
+	if cap(tb.buf) > maxPooledValuesSize {
+		tb.buf = tb.inlineBuf[:0]
+	}
+	if auditEnabled {
+		return
+	}
+	builderPool.Put(tb)
+}
+
+// NewTagSet returns a new TagSet holding the tags given as pairs of a key
+// and a value, e.g. NewTagSet(method, "GET", code, 200). The values must be
+// strings for the *KeyString, int or int64 for the *KeyInt64 and bool for the
+// *KeyBool. A later pair overrides an earlier one of the same key. The string
+// values are validated and the maximums are enforced as configured by
+// SetValidation.
+func NewTagSet(pairs ...interface{}) (*TagSet, error) {
+	return upsertPairs(NewTagSetBuilder(nil), pairs)
+}
+
+// upsertPairs upserts in tb the tags given as pairs of a key and a value. See
+// NewTagSet.
+func upsertPairs(tb TagSetBuilder, pairs []interface{}) (*TagSet, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("got %v arguments, want pairs of a key and a value", len(pairs))
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		switch k := pairs[i].(type) {
+		case *KeyString:
+			v, ok := pairs[i+1].(string)
+			if !ok {
+				return nil, fmt.Errorf("got value %v of type %T for key '%v', want a string", pairs[i+1], pairs[i+1], k.Name())
+			}
+			tb.UpsertString(k, v)
+		case *KeyInt64:
+			switch v := pairs[i+1].(type) {
+			case int64:
+				tb.UpsertInt64(k, v)
+			case int:
+				tb.UpsertInt64(k, int64(v))
+			default:
+				return nil, fmt.Errorf("got value %v of type %T for key '%v', want an int64", v, v, k.Name())
+			}
+		case *KeyBool:
+			v, ok := pairs[i+1].(bool)
+			if !ok {
+				return nil, fmt.Errorf("got value %v of type %T for key '%v', want a bool", pairs[i+1], pairs[i+1], k.Name())
+			}
+			tb.UpsertBool(k, v)
+		default:
+			return nil, fmt.Errorf("got %v of type %T as argument %v, want a key", k, k, i)
+		}
+	}
+	return tb.BuildChecked()
+}
@@ -20,8 +20,8 @@ import (
 	"fmt"
 )
 
-// KeyType defines the types of keys allowed. Currently only keyTypeString is
-// supported.
+// KeyType defines the types of keys allowed. It is encoded as the field ID of
+// each tag.
 type keyType byte
 
 const (
@@ -33,6 +33,51 @@ const (
 	tagsVersionID = byte(0)
 )
 
+// MaxEncodedLength is the maximum length in bytes of a TagSet encoded with
+// Encode. Encode fails for larger TagSets and Decode rejects larger inputs.
+const MaxEncodedLength = 8192
+
+// TruncatedError is returned by Decode when the input ends before the field
+// starting at Offset could be fully read.
+type TruncatedError struct {
+	Offset int
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("encoded tags truncated at offset %v", e.Offset)
+}
+
+// UnknownFieldError is returned by Decode when the field ID found at Offset
+// is not defined by the supported version of the format.
+type UnknownFieldError struct {
+	FieldID byte
+	Offset  int
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field ID %v at offset %v in encoded tags", e.FieldID, e.Offset)
+}
+
+// UnsupportedVersionError is returned by Decode when the version byte of the
+// input is higher than the version supported by this library.
+type UnsupportedVersionError struct {
+	Version byte
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("encoded tags version %v not supported. Supports only up to: %v", e.Version, tagsVersionID)
+}
+
+// SizeLimitError is returned by Encode and Decode when the encoded TagSet is
+// longer than MaxEncodedLength.
+type SizeLimitError struct {
+	Size int
+}
+
+func (e *SizeLimitError) Error() string {
+	return fmt.Sprintf("encoded tags length %v exceeds the maximum of %v bytes", e.Size, MaxEncodedLength)
+}
+
 type encoderGRPC struct {
 	buf               []byte
 	writeIdx, readIdx int
@@ -118,18 +163,18 @@ func (eg *encoderGRPC) readUint64() uint64 {
 
 func (eg *encoderGRPC) readBytesWithVarintLen() ([]byte, error) {
 	if eg.readEnded() {
-		return nil, fmt.Errorf("unexpected end while readBytesWithVarintLen '%x' starting at idx '%v'", eg.buf, eg.readIdx)
+		return nil, &TruncatedError{Offset: eg.readIdx}
 	}
 	length, valueStart := binary.Uvarint(eg.buf[eg.readIdx:])
 	if valueStart <= 0 {
-		return nil, fmt.Errorf("unexpected end while readBytesWithVarintLen '%x' starting at idx '%v'", eg.buf, eg.readIdx)
+		return nil, &TruncatedError{Offset: eg.readIdx}
 	}
 
 	valueStart += eg.readIdx
-	valueEnd := valueStart + int(length)
-	if valueEnd > len(eg.buf) || length < 0 {
-		return nil, fmt.Errorf("malformed encoding: length:%v, upper%v, maxLength:%v", length, valueEnd, len(eg.buf))
+	if length > uint64(len(eg.buf)-valueStart) {
+		return nil, &TruncatedError{Offset: eg.readIdx}
 	}
+	valueEnd := valueStart + int(length)
 
 	eg.readIdx = valueEnd
 	return eg.buf[valueStart:valueEnd], nil
@@ -159,24 +204,32 @@ func (eg *encoderGRPC) bytes() []byte {
 	return eg.buf[:eg.writeIdx]
 }
 
-// EncodeToFullSignature will encode the tagSet to []byte.
-func EncodeToFullSignature(ts *TagSet) []byte {
-	eg := &encoderGRPC{
-		buf: make([]byte, len(ts.m)),
+// Encode encodes the TagSet to the binary format used to propagate tags
+// across process boundaries (e.g. in the metadata of an RPC). The format is a
+// version byte (currently 0) followed, for each tag, by a field ID and the
+// varint-prefixed key name. The field ID is 0 for string tags followed by the
+// varint-prefixed value, 1 for int64 tags followed by the 8 bytes little
+// endian value, 2 for bool tags set to true and 3 for bool tags set to false.
+// It returns a *SizeLimitError if the result is longer than MaxEncodedLength.
+func Encode(ts *TagSet) ([]byte, error) {
+	b := encode(ts)
+	if len(b) > MaxEncodedLength {
+		return nil, &SizeLimitError{Size: len(b)}
 	}
+	return b, nil
+}
 
-	eg.writeByte(byte(tagsVersionID))
-	for k, v := range ts.m {
-		eg.writeByte(byte(keyTypeString))
-		eg.writeStringWithVarintLen(k.Name())
-		eg.writeBytesWithVarintLen(v)
+// Decode decodes a TagSet encoded by Encode. An empty input decodes to an
+// empty TagSet. Malformed inputs cause a *TruncatedError, *UnknownFieldError,
+// *UnsupportedVersionError or *SizeLimitError to be returned. Tags with key
+// names that are not valid key names are dropped. String values are validated
+// as configured by SetValidation, and a *LimitError is returned if the decoded
+// TagSet exceeds the maximums configured with it.
+func Decode(bytes []byte) (*TagSet, error) {
+	if len(bytes) > MaxEncodedLength {
+		return nil, &SizeLimitError{Size: len(bytes)}
 	}
 
-	return eg.bytes()
-}
-
-// DecodeFromFullSignature will decode the []byte encoded tagSet.
-func DecodeFromFullSignature(bytes []byte) (*TagSet, error) {
 	ts := newTagSet(0)
 
 	eg := &encoderGRPC{
@@ -188,17 +241,17 @@ func DecodeFromFullSignature(bytes []byte) (*TagSet, error) {
 
 	version := eg.readByte()
 	if version > tagsVersionID {
-		return nil, fmt.Errorf("DecodeFromFullSignature doesn't support version %v. Supports only up to: %v", version, tagsVersionID)
+		return nil, &UnsupportedVersionError{Version: version}
 	}
 
 	for !eg.readEnded() {
+		offset := eg.readIdx
 		typ := keyType(eg.readByte())
 
-		switch typ {
-		case keyTypeString:
-			break
-		default:
-			return nil, fmt.Errorf("DecodeFromFullSignature failed. Key type invalid %v", typ)
+		if typ > keyTypeFalse {
+			// The length of an unknown field cannot be known. Hence nothing
+			// after it can be decoded.
+			return nil, &UnknownFieldError{FieldID: byte(typ), Offset: offset}
 		}
 
 		k, err := eg.readBytesWithVarintLen()
@@ -206,12 +259,31 @@ func DecodeFromFullSignature(bytes []byte) (*TagSet, error) {
 			return nil, err
 		}
 
-		v, err := eg.readBytesWithVarintLen()
-		if err != nil {
-			return nil, err
+		var key Key
+		var v []byte
+		switch typ {
+		case keyTypeString:
+			if v, err = eg.readBytesWithVarintLen(); err != nil {
+				return nil, err
+			}
+			var ok bool
+			if v, ok = validateValue(v); !ok {
+				continue
+			}
+			key, err = CreateKeyString(string(k))
+		case keyTypeInt64:
+			if len(eg.buf)-eg.readIdx < 8 {
+				return nil, &TruncatedError{Offset: eg.readIdx}
+			}
+			v = int64ToBytes(int64(eg.readUint64()))
+			key, err = CreateKeyInt64(string(k))
+		case keyTypeTrue:
+			v = boolToBytes(true)
+			key, err = CreateKeyBool(string(k))
+		case keyTypeFalse:
+			v = boolToBytes(false)
+			key, err = CreateKeyBool(string(k))
 		}
-
-		key, err := CreateKeyString(string(k))
 		if err != nil {
 			// TODO(acetechnologist): log that key received on the wire and its value was ignored
 			continue
@@ -220,5 +292,54 @@ func DecodeFromFullSignature(bytes []byte) (*TagSet, error) {
 		ts.upsertBytes(key, v)
 	}
 
+	if err := checkLimits(ts); err != nil {
+		return nil, err
+	}
 	return ts, nil
 }
+
+// EncodeToFullSignature will encode the tagSet to []byte. Unlike Encode, it
+// doesn't enforce MaxEncodedLength. The tags are encoded in the order of their
+// key names, so that equal TagSets have the same encoding.
+//
+// Deprecated: use Encode instead.
+func EncodeToFullSignature(ts *TagSet) []byte {
+	return encode(ts)
+}
+
+// encode encodes ts in the format described by Encode, without enforcing
+// MaxEncodedLength. The tags are encoded in the order of their key names.
+func encode(ts *TagSet) []byte {
+	ts.audit()
+	eg := &encoderGRPC{
+		buf: make([]byte, len(ts.m)),
+	}
+
+	eg.writeByte(byte(tagsVersionID))
+	for _, k := range ts.sortedKeys() {
+		v := ts.m[k]
+		switch k.(type) {
+		case *KeyInt64:
+			eg.writeTagUint64(k.Name(), uint64(bytesToInt64(v)))
+		case *KeyBool:
+			if bytesToBool(v) {
+				eg.writeTagTrue(k.Name())
+			} else {
+				eg.writeTagFalse(k.Name())
+			}
+		default:
+			eg.writeByte(byte(keyTypeString))
+			eg.writeStringWithVarintLen(k.Name())
+			eg.writeBytesWithVarintLen(v)
+		}
+	}
+
+	return eg.bytes()
+}
+
+// DecodeFromFullSignature will decode the []byte encoded tagSet.
+//
+// Deprecated: use Decode instead.
+func DecodeFromFullSignature(bytes []byte) (*TagSet, error) {
+	return Decode(bytes)
+}
@@ -18,6 +18,7 @@ package tags
 import (
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"unsafe"
 )
@@ -82,8 +83,11 @@ func Test_EncodeDecode_TagSet(t *testing.T) {
 		}
 		ts := tsb.Build()
 
-		encoded := EncodeToFullSignature(ts)
-		decoded, err := DecodeFromFullSignature(encoded)
+		encoded, err := Encode(ts)
+		if err != nil {
+			t.Fatalf("Test case '%v'. Encoding tagSet failed. %v", tc.label, err)
+		}
+		decoded, err := Decode(encoded)
 
 		if err != nil {
 			t.Errorf("Test case '%v'. Decoding encoded tagSet failed. %v", tc.label, err)
@@ -108,3 +112,112 @@ func Test_EncodeDecode_TagSet(t *testing.T) {
 
 	}
 }
+
+func Test_Encode_SizeLimit(t *testing.T) {
+	k1, _ := CreateKeyString("k1")
+
+	ts := NewTagSetBuilder(nil).UpsertString(k1, strings.Repeat("v", MaxEncodedLength)).Build()
+	if _, err := Encode(ts); err == nil {
+		t.Fatal("Encode() got no error, want *SizeLimitError")
+	} else if _, ok := err.(*SizeLimitError); !ok {
+		t.Errorf("Encode() got error %T, want *SizeLimitError", err)
+	}
+
+	ts = NewTagSetBuilder(nil).UpsertString(k1, strings.Repeat("v", MaxEncodedLength/2)).Build()
+	if _, err := Encode(ts); err != nil {
+		t.Errorf("Encode() got error %v, want no error", err)
+	}
+}
+
+func Test_Decode_Errors(t *testing.T) {
+	type testCase struct {
+		label   string
+		encoded []byte
+		want    error
+	}
+
+	testCases := []testCase{
+		{
+			"unsupported version",
+			[]byte{1},
+			&UnsupportedVersionError{Version: 1},
+		},
+		{
+			"unknown field",
+			[]byte{0, 0, 2, 'k', '1', 2, 'v', '1', 5, 2, 'k', '2'},
+			&UnknownFieldError{FieldID: 5, Offset: 8},
+		},
+		{
+			"missing key",
+			[]byte{0, 0},
+			&TruncatedError{Offset: 2},
+		},
+		{
+			"key too short",
+			[]byte{0, 0, 3, 'k', '1'},
+			&TruncatedError{Offset: 2},
+		},
+		{
+			"missing value",
+			[]byte{0, 0, 2, 'k', '1'},
+			&TruncatedError{Offset: 5},
+		},
+		{
+			"value too short",
+			[]byte{0, 0, 2, 'k', '1', 4, 'v', '1'},
+			&TruncatedError{Offset: 5},
+		},
+		{
+			"too large",
+			make([]byte, MaxEncodedLength+1),
+			&SizeLimitError{Size: MaxEncodedLength + 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := Decode(tc.encoded)
+		if !reflect.DeepEqual(err, tc.want) {
+			t.Errorf("Test case '%v'. Decode() got error %v, want %v", tc.label, err, tc.want)
+		}
+	}
+}
+
+func Test_EncodeDecode_TypedKeys(t *testing.T) {
+	ks, _ := CreateKeyString("typed.string")
+	ki, _ := CreateKeyInt64("typed.int64")
+	kt, _ := CreateKeyBool("typed.true")
+	kf, _ := CreateKeyBool("typed.false")
+
+	ts := NewTagSetBuilder(nil).
+		UpsertString(ks, "v").
+		UpsertInt64(ki, -42).
+		UpsertBool(kt, true).
+		UpsertBool(kf, false).
+		Build()
+
+	encoded, err := Encode(ts)
+	if err != nil {
+		t.Fatalf("Encode() got error %v, want no error", err)
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode() got error %v, want no error", err)
+	}
+
+	if got, err := decoded.ValueAsString(ks); err != nil || got != "v" {
+		t.Errorf("ValueAsString(%v) got (%v, %v), want (v, <nil>)", ks, got, err)
+	}
+	if got, err := decoded.ValueAsInt64(ki); err != nil || got != -42 {
+		t.Errorf("ValueAsInt64(%v) got (%v, %v), want (-42, <nil>)", ki, got, err)
+	}
+	if got, err := decoded.ValueAsBool(kt); err != nil || got != true {
+		t.Errorf("ValueAsBool(%v) got (%v, %v), want (true, <nil>)", kt, got, err)
+	}
+	if got, err := decoded.ValueAsBool(kf); err != nil || got != false {
+		t.Errorf("ValueAsBool(%v) got (%v, %v), want (false, <nil>)", kf, got, err)
+	}
+
+	if _, err := Decode([]byte{0, 1, 2, 'k', '1', 0, 0}); err == nil {
+		t.Error("Decode() of truncated int64 tag got no error, want *TruncatedError")
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tags
+
+import (
+	"bytes"
+	"sync"
+)
+
+const (
+	// maxInternedTagSets is the number of TagSets interned before the
+	// interned TagSets are all released.
+	maxInternedTagSets = 4096
+
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// internCache holds the TagSets built recently, indexed by the hash of their
+// tags, so that building the same tags again returns the existing TagSet
+// rather than allocating a new one. TagSets are immutable, hence they can be
+// shared by all the callers building the same tags.
+type internCache struct {
+	mu sync.RWMutex
+	m  map[uint64]*TagSet
+}
+
+var interned = &internCache{
+	m: make(map[uint64]*TagSet),
+}
+
+// lookupOrAdd returns the interned TagSet holding the tags of tb, interning a
+// new TagSet if there is none.
+func (c *internCache) lookupOrAdd(tb *tagSetBuilder) *TagSet {
+	h := tb.hash()
+	c.mu.RLock()
+	ts, ok := c.m[h]
+	c.mu.RUnlock()
+	if ok && tb.equal(ts) {
+		return ts
+	}
+
+	ts = tb.newTagSet()
+	c.mu.Lock()
+	if len(c.m) >= maxInternedTagSets {
+		c.m = make(map[uint64]*TagSet)
+	}
+	// a TagSet with the same hash but other tags is replaced.
+	c.m[h] = ts
+	c.mu.Unlock()
+	return ts
+}
+
+// hash returns a hash of the tags being built, summing the 64-bit FNV-1a
+// hashes of the tags so that it doesn't depend on the order they were set in. It is computed inline since
+// fnv.New64a allocates.
+func (tb *tagSetBuilder) hash() uint64 {
+	var sum uint64
+	for _, t := range tb.tags {
+		h := uint64(fnvOffset64)
+		name := t.k.Name()
+		for i := 0; i < len(name); i++ {
+			h = (h ^ uint64(name[i])) * fnvPrime64
+		}
+		h *= fnvPrime64 // separator between the name and the value.
+		for _, b := range tb.value(t) {
+			h = (h ^ uint64(b)) * fnvPrime64
+		}
+		sum += mix64(h)
+	}
+	return sum
+}
+
+// mix64 is the finalizer of MurmurHash3. It spreads the bits of the hash of
+// each tag before they are summed, since the sums of FNV hashes of similar
+// tags (e.g. {k1 v2}{k2 v1} and {k1 v1}{k2 v2}) often collide.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// equal returns true if ts holds the tags being built.
+func (tb *tagSetBuilder) equal(ts *TagSet) bool {
+	if len(ts.m) != len(tb.tags) {
+		return false
+	}
+	for _, t := range tb.tags {
+		v, ok := ts.m[t.k]
+		if !ok || !bytes.Equal(v, tb.value(t)) {
+			return false
+		}
+	}
+	return true
+}
@@ -15,7 +15,10 @@
 
 package tags
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func Test_Tagset_Insert(t *testing.T) {
 	type want struct {
@@ -401,3 +404,175 @@ func Test_Tagset_Delete(t *testing.T) {
 		}
 	}
 }
+
+func Test_Tagset_Accessors(t *testing.T) {
+	km := newKeysManager()
+	k1, _ := km.createKeyString("k1")
+	k2, _ := km.createKeyInt64("k2")
+	k3, _ := km.createKeyString("k3")
+
+	ts := NewTagSetBuilder(nil).
+		InsertString(k3, "v3").
+		InsertString(k1, "v1").
+		InsertInt64(k2, 2).
+		Build()
+
+	if got := ts.Len(); got != 3 {
+		t.Errorf("Len() got %v, want 3", got)
+	}
+
+	type want struct {
+		k  Key
+		v  string
+		ok bool
+	}
+	k4, _ := km.createKeyString("k4")
+	for _, w := range []want{{k1, "v1", true}, {k2, "2", true}, {k4, "", false}} {
+		if got, ok := ts.Value(w.k); got != w.v || ok != w.ok {
+			t.Errorf("Value(%v) got (%v, %v), want (%v, %v)", w.k, got, ok, w.v, w.ok)
+		}
+	}
+
+	var got []string
+	ts.Foreach(func(k Key, v []byte) bool {
+		got = append(got, k.Name()+"="+k.ValueAsString(v))
+		return true
+	})
+	if want := []string{"k1=v1", "k2=2", "k3=v3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Foreach() visited %v, want %v", got, want)
+	}
+
+	got = nil
+	ts.Foreach(func(k Key, v []byte) bool {
+		got = append(got, k.Name())
+		return false
+	})
+	if want := []string{"k1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Foreach() stopping after the first tag visited %v, want %v", got, want)
+	}
+}
+
+func Test_Tagset_Merge(t *testing.T) {
+	km := newKeysManager()
+	k1, _ := km.createKeyString("k1")
+	k2, _ := km.createKeyString("k2")
+	k3, _ := km.createKeyString("k3")
+
+	base := NewTagSetBuilder(nil).InsertString(k1, "base1").InsertString(k2, "base2").Build()
+	ts := NewTagSetBuilder(nil).InsertString(k2, "v2").InsertString(k3, "v3").Build()
+
+	got := Merge(base, ts).String()
+	if want := NewTagSetBuilder(nil).InsertString(k1, "base1").InsertString(k2, "v2").InsertString(k3, "v3").Build().String(); got != want {
+		t.Errorf("Merge(base, ts) got %v, want %v", got, want)
+	}
+	if got, want := Merge(nil, ts).String(), ts.String(); got != want {
+		t.Errorf("Merge(nil, ts) got %v, want %v", got, want)
+	}
+	if got, want := Merge(base, nil).String(), base.String(); got != want {
+		t.Errorf("Merge(base, nil) got %v, want %v", got, want)
+	}
+	if v, _ := base.Value(k2); v != "base2" {
+		t.Errorf("Merge modified base: value of k2 got %v, want base2", v)
+	}
+}
+
+func Test_Tagset_NewTagSet(t *testing.T) {
+	km := newKeysManager()
+	k1, _ := km.createKeyString("k1")
+	k2, _ := km.createKeyInt64("k2")
+	k3, _ := km.createKeyBool("k3")
+
+	ts, err := NewTagSet(k1, "v1", k2, 2, k3, true, k1, "v1 overridden")
+	if err != nil {
+		t.Fatalf("NewTagSet got error '%v', want no error", err)
+	}
+	want := NewTagSetBuilder(nil).InsertString(k1, "v1 overridden").InsertInt64(k2, 2).InsertBool(k3, true).Build()
+	if ts.String() != want.String() {
+		t.Errorf("NewTagSet got %v, want %v", ts, want)
+	}
+
+	invalid := [][]interface{}{
+		{k1},
+		{k1, 1},
+		{k2, "2"},
+		{k3, 1},
+		{"k1", "v1"},
+	}
+	for _, pairs := range invalid {
+		if _, err := NewTagSet(pairs...); err == nil {
+			t.Errorf("NewTagSet(%v) got no error, want an error", pairs)
+		}
+	}
+}
+
+func Test_Tagset_EqualHashString(t *testing.T) {
+	km := newKeysManager()
+	k1, _ := km.createKeyString("k1")
+	k2, _ := km.createKeyInt64("k2")
+
+	ts1 := NewTagSetBuilder(nil).InsertString(k1, "v1").InsertInt64(k2, 2).Build()
+	ts2 := NewTagSetBuilder(nil).InsertInt64(k2, 2).InsertString(k1, "v1").Build()
+	ts3 := NewTagSetBuilder(nil).InsertString(k1, "v1").InsertInt64(k2, 3).Build()
+	ts4 := NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+	empty := NewTagSetBuilder(nil).Build()
+
+	type testCase struct {
+		label string
+		a, b  *TagSet
+		want  bool
+	}
+	tcs := []testCase{
+		{"same tags inserted in another order", ts1, ts2, true},
+		{"other value", ts1, ts3, false},
+		{"missing tag", ts1, ts4, false},
+		{"nil and empty", nil, empty, true},
+		{"nil and non empty", nil, ts4, false},
+	}
+	for _, tc := range tcs {
+		if got := tc.a.Equal(tc.b); got != tc.want {
+			t.Errorf("%v: Equal got %v, want %v", tc.label, got, tc.want)
+		}
+		if got := tc.a.Hash() == tc.b.Hash(); got != tc.want {
+			t.Errorf("%v: equal hashes got %v, want %v", tc.label, got, tc.want)
+		}
+		if got := tc.a.String() == tc.b.String(); got != tc.want {
+			t.Errorf("%v: equal strings got %v, want %v", tc.label, got, tc.want)
+		}
+	}
+	if got, want := ts1.String(), "{ {k1 v1}{k2 2} }"; got != want {
+		t.Errorf("String got %v, want %v", got, want)
+	}
+}
+
+func Test_TagSetBuilder_Interning(t *testing.T) {
+	km := newKeysManager()
+	k1, _ := km.createKeyString("k1")
+	k2, _ := km.createKeyInt64("k2")
+	k3, _ := km.createKeyBool("k3")
+
+	ts1 := NewTagSetBuilder(nil).InsertString(k1, "v1").InsertInt64(k2, 2).InsertBool(k3, true).Build()
+	ts2 := NewTagSetBuilder(nil).InsertBool(k3, true).UpsertString(k1, "v0").UpsertString(k1, "v1").InsertInt64(k2, 2).Build()
+	if ts1 != ts2 {
+		t.Errorf("Build with the same tags got distinct TagSets %v and %v, want the interned TagSet", ts1, ts2)
+	}
+
+	ts3 := NewTagSetBuilder(ts1).UpdateString(k1, "v2").Delete(k3).Build()
+	if ts3 == ts1 {
+		t.Fatalf("Build with other tags got the interned TagSet %v", ts1)
+	}
+	if got, want := ts1.String(), "{ {k1 v1}{k2 2}{k3 true} }"; got != want {
+		t.Errorf("building from a TagSet modified it: got %v, want %v", got, want)
+	}
+	if got, want := ts3.String(), "{ {k1 v2}{k2 2} }"; got != want {
+		t.Errorf("String got %v, want %v", got, want)
+	}
+
+	tb := NewTagSetBuilder(nil)
+	tb.Build()
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("InsertString after Build didn't panic, want panic")
+		}
+	}()
+	tb.InsertString(k1, "v1")
+}
@@ -0,0 +1,173 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tags
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ValidationPolicy defines how tag values failing the validation configured
+// with SetValidation are handled.
+type ValidationPolicy int
+
+const (
+	// PolicyReject drops the tags with invalid values.
+	PolicyReject ValidationPolicy = iota
+	// PolicyTruncate keeps the longest valid prefix of invalid values.
+	PolicyTruncate
+	// PolicySanitize replaces the characters that are not allowed with '_'
+	// and truncates the values that are too long.
+	PolicySanitize
+)
+
+// ValidationConfig is the configuration of the validation applied to the
+// string tag values inserted in a TagSet, either through a TagSetBuilder or
+// when decoding tags received from another process.
+type ValidationConfig struct {
+	// MaxKeyLength is the maximum length of the name of a key. Keys with
+	// longer names cannot be created. Zero means the default limit of 16383
+	// bytes.
+	MaxKeyLength int
+	// MaxValueLength is the maximum length of a tag value. Zero means no
+	// limit.
+	MaxValueLength int
+	// PrintableASCIIOnly restricts tag values to the US-ASCII printable
+	// characters (range 0x20 (' ') to 0x7e ('~')).
+	PrintableASCIIOnly bool
+	// Policy defines how the invalid values are handled.
+	Policy ValidationPolicy
+	// MaxTags is the maximum number of tags of a TagSet. Zero means no limit.
+	MaxTags int
+	// MaxEncodedLength is the maximum length in bytes of a TagSet once
+	// encoded with Encode. Zero means the default limit of MaxEncodedLength
+	// bytes set by the Census specification.
+	MaxEncodedLength int
+}
+
+// LimitError is returned when a TagSet being built or decoded has more tags
+// or a longer encoding than allowed by the configuration set with
+// SetValidation.
+type LimitError struct {
+	Tags, MaxTags                   int
+	EncodedLength, MaxEncodedLength int
+}
+
+func (e *LimitError) Error() string {
+	if e.MaxTags > 0 && e.Tags > e.MaxTags {
+		return fmt.Sprintf("tag set holds %v tags, exceeding the maximum of %v", e.Tags, e.MaxTags)
+	}
+	return fmt.Sprintf("tag set encoded length %v exceeds the maximum of %v bytes", e.EncodedLength, e.MaxEncodedLength)
+}
+
+var validation atomic.Value
+
+// SetValidation sets the validation applied to tag values from now on. By
+// default no validation is performed on tag values.
+func SetValidation(cfg ValidationConfig) {
+	validation.Store(&cfg)
+}
+
+func currentValidation() *ValidationConfig {
+	return validation.Load().(*ValidationConfig)
+}
+
+// validateValue applies the current validation to v. It returns the value to
+// store, and false if the tag must be dropped.
+func validateValue(v []byte) ([]byte, bool) {
+	cfg := currentValidation()
+
+	valid := len(v)
+	if cfg.MaxValueLength > 0 && valid > cfg.MaxValueLength {
+		valid = cfg.MaxValueLength
+	}
+	if cfg.PrintableASCIIOnly {
+		for i := 0; i < valid; i++ {
+			if v[i] < validKeysMin || v[i] > validKeysMax {
+				valid = i
+				break
+			}
+		}
+	}
+	if valid == len(v) {
+		return v, true
+	}
+
+	switch cfg.Policy {
+	case PolicyTruncate:
+		return v[:valid], true
+	case PolicySanitize:
+		n := len(v)
+		if cfg.MaxValueLength > 0 && n > cfg.MaxValueLength {
+			n = cfg.MaxValueLength
+		}
+		sanitized := make([]byte, n)
+		for i := 0; i < n; i++ {
+			if cfg.PrintableASCIIOnly && (v[i] < validKeysMin || v[i] > validKeysMax) {
+				sanitized[i] = '_'
+				continue
+			}
+			sanitized[i] = v[i]
+		}
+		return sanitized, true
+	default:
+		return nil, false
+	}
+}
+
+// validateString is like validateValue for string values. It doesn't
+// allocate unless s is invalid.
+func validateString(s string) (string, bool) {
+	cfg := currentValidation()
+	valid := cfg.MaxValueLength <= 0 || len(s) <= cfg.MaxValueLength
+	if valid && cfg.PrintableASCIIOnly {
+		for i := 0; i < len(s); i++ {
+			if s[i] < validKeysMin || s[i] > validKeysMax {
+				valid = false
+				break
+			}
+		}
+	}
+	if valid {
+		return s, true
+	}
+	v, ok := validateValue([]byte(s))
+	return string(v), ok
+}
+
+// checkLimits returns a *LimitError if ts exceeds the maximum number of tags
+// or encoded length currently configured.
+func checkLimits(ts *TagSet) error {
+	cfg := currentValidation()
+	maxLen := cfg.MaxEncodedLength
+	if maxLen <= 0 || maxLen > MaxEncodedLength {
+		maxLen = MaxEncodedLength
+	}
+	n, l := ts.Len(), ts.EncodedLength()
+	if (cfg.MaxTags > 0 && n > cfg.MaxTags) || l > maxLen {
+		return &LimitError{
+			Tags:             n,
+			MaxTags:          cfg.MaxTags,
+			EncodedLength:    l,
+			MaxEncodedLength: maxLen,
+		}
+	}
+	return nil
+}
+
+func init() {
+	SetValidation(ValidationConfig{})
+}
@@ -0,0 +1,124 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tags
+
+import "testing"
+
+func Test_Validation_Policies(t *testing.T) {
+	defer SetValidation(ValidationConfig{})
+
+	k1, _ := CreateKeyString("k1")
+
+	type testCase struct {
+		label  string
+		cfg    ValidationConfig
+		value  string
+		want   string
+		wantOk bool
+	}
+	tcs := []testCase{
+		{"no validation", ValidationConfig{}, "v\x01ery long", "v\x01ery long", true},
+		{"reject valid", ValidationConfig{MaxValueLength: 4, PrintableASCIIOnly: true, Policy: PolicyReject}, "v1", "v1", true},
+		{"reject too long", ValidationConfig{MaxValueLength: 4, Policy: PolicyReject}, "very long", "", false},
+		{"reject non printable", ValidationConfig{PrintableASCIIOnly: true, Policy: PolicyReject}, "v\x01", "", false},
+		{"truncate too long", ValidationConfig{MaxValueLength: 4, Policy: PolicyTruncate}, "very long", "very", true},
+		{"truncate non printable", ValidationConfig{MaxValueLength: 4, PrintableASCIIOnly: true, Policy: PolicyTruncate}, "v\x01ery long", "v", true},
+		{"sanitize too long", ValidationConfig{MaxValueLength: 4, Policy: PolicySanitize}, "very long", "very", true},
+		{"sanitize non printable", ValidationConfig{MaxValueLength: 4, PrintableASCIIOnly: true, Policy: PolicySanitize}, "v\x01ery long", "v_er", true},
+		{"sanitize utf8", ValidationConfig{PrintableASCIIOnly: true, Policy: PolicySanitize}, "vé", "v__", true},
+	}
+
+	for _, tc := range tcs {
+		SetValidation(tc.cfg)
+		ts := NewTagSetBuilder(nil).UpsertString(k1, tc.value).Build()
+		got, ok := ts.Value(k1)
+		if got != tc.want || ok != tc.wantOk {
+			t.Errorf("Test case '%v': got (%q, %v), want (%q, %v)", tc.label, got, ok, tc.want, tc.wantOk)
+		}
+
+		encoded := EncodeToFullSignature(NewTagSetBuilder(nil).Build())
+		encoded = append(encoded, 0, 2, 'k', '1', byte(len(tc.value)))
+		encoded = append(encoded, tc.value...)
+		decoded, err := Decode(encoded)
+		if err != nil {
+			t.Fatalf("Test case '%v': Decode() got error %v, want no error", tc.label, err)
+		}
+		got, ok = decoded.Value(k1)
+		if got != tc.want || ok != tc.wantOk {
+			t.Errorf("Test case '%v': decoded got (%q, %v), want (%q, %v)", tc.label, got, ok, tc.want, tc.wantOk)
+		}
+	}
+}
+
+func Test_Validation_MaxKeyLength(t *testing.T) {
+	defer SetValidation(ValidationConfig{})
+
+	SetValidation(ValidationConfig{MaxKeyLength: 3})
+	km := newKeysManager()
+	if _, err := km.createKeyString("k12"); err != nil {
+		t.Errorf("createKeyString(\"k12\") got error %v, want no error", err)
+	}
+	if _, err := km.createKeyString("k123"); err == nil {
+		t.Error("createKeyString(\"k123\") got no error, want error")
+	}
+}
+
+func Test_Validation_Limits(t *testing.T) {
+	defer SetValidation(ValidationConfig{})
+
+	k1, _ := CreateKeyString("limits.k1")
+	k2, _ := CreateKeyInt64("limits.k2")
+	k3, _ := CreateKeyBool("limits.k3")
+
+	ts := NewTagSetBuilder(nil).UpsertString(k1, "v1").UpsertInt64(k2, 2).UpsertBool(k3, true).Build()
+	if got, want := ts.EncodedLength(), len(EncodeToFullSignature(ts)); got != want {
+		t.Errorf("EncodedLength() got %v, want %v", got, want)
+	}
+
+	type testCase struct {
+		label   string
+		cfg     ValidationConfig
+		wantErr bool
+	}
+	tcs := []testCase{
+		{"no limits", ValidationConfig{}, false},
+		{"tags at limit", ValidationConfig{MaxTags: 3}, false},
+		{"too many tags", ValidationConfig{MaxTags: 2}, true},
+		{"length at limit", ValidationConfig{MaxEncodedLength: ts.EncodedLength()}, false},
+		{"too long", ValidationConfig{MaxEncodedLength: ts.EncodedLength() - 1}, true},
+	}
+
+	encoded := EncodeToFullSignature(ts)
+	for _, tc := range tcs {
+		SetValidation(tc.cfg)
+		_, err := NewTagSetBuilder(ts).BuildChecked()
+		if _, ok := err.(*LimitError); ok != tc.wantErr {
+			t.Errorf("Test case '%v': BuildChecked() got error %v, want *LimitError %v", tc.label, err, tc.wantErr)
+		}
+		_, err = Decode(encoded)
+		if _, ok := err.(*LimitError); ok != tc.wantErr {
+			t.Errorf("Test case '%v': Decode() got error %v, want *LimitError %v", tc.label, err, tc.wantErr)
+		}
+	}
+
+	SetValidation(ValidationConfig{MaxTags: 1})
+	if _, err := NewTagSet(k1, "v1", k2, 2); err == nil {
+		t.Error("NewTagSet() with 2 tags got no error, want *LimitError")
+	}
+	if ts := NewTagSetBuilder(nil).UpsertString(k1, "v1").UpsertInt64(k2, 2).Build(); ts.Len() != 2 {
+		t.Errorf("Build() got %v tags, want 2 because Build does not enforce limits", ts.Len())
+	}
+}
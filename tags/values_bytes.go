@@ -117,6 +117,7 @@ func toValuesBytes(ts *TagSet, ks []Key) *valuesBytes {
 // ToValuesString returns the values bytes resulting from projecting *TagSet
 // along the []Key.
 func ToValuesString(ts *TagSet, ks []Key) string {
+	ts.audit()
 	vb := &valuesBytes{
 		buf: make([]byte, len(ks)),
 	}
@@ -127,6 +128,46 @@ func ToValuesString(ts *TagSet, ks []Key) string {
 	return string(vb.bytes())
 }
 
+// SliceToValuesString is like ToValuesString for the tags ts, e.g. the tags of
+// a row received from another process. The tags whose keys are not in ks are
+// ignored.
+func SliceToValuesString(ts []Tag, ks []Key) string {
+	vb := &valuesBytes{
+		buf: make([]byte, len(ks)),
+	}
+	for _, k := range ks {
+		var v []byte
+		for _, t := range ts {
+			if t.K == k {
+				v = t.V
+				break
+			}
+		}
+		vb.writeValue(v)
+	}
+	return string(vb.bytes())
+}
+
+// AppendSignature appends a signature of ts to b and returns the extended
+// buffer. The signature doesn't depend on the order in which the tags were
+// inserted: TagSets holding the same tags have the same signature.
+func AppendSignature(b []byte, ts *TagSet) []byte {
+	ts.audit()
+	vb := &valuesBytes{
+		buf:  b[:cap(b)],
+		wIdx: len(b),
+	}
+	for _, k := range ts.sortedKeys() {
+		id := k.ID()
+		vb.growIfRequired(sizeOfUint16)
+		vb.buf[vb.wIdx] = byte(id)
+		vb.buf[vb.wIdx+1] = byte(id >> 8)
+		vb.wIdx += sizeOfUint16
+		vb.writeValue(ts.m[k])
+	}
+	return vb.bytes()
+}
+
 // ToOrderedTagsSlice returns the extracted and ordered tags from the argument s.
 func ToOrderedTagsSlice(s string, ks []Key) []Tag {
 	vb := &valuesBytes{
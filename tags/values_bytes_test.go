@@ -37,7 +37,7 @@ func Test_EncodeDecode_ValuesBytes(t *testing.T) {
 		{
 			0,
 			&TagSet{
-				map[Key][]byte{},
+				m: map[Key][]byte{},
 			},
 			[]Key{k1},
 			nil,
@@ -45,7 +45,7 @@ func Test_EncodeDecode_ValuesBytes(t *testing.T) {
 		{
 			1,
 			&TagSet{
-				map[Key][]byte{k2: []byte("v2")},
+				m: map[Key][]byte{k2: []byte("v2")},
 			},
 			[]Key{},
 			nil,
@@ -53,7 +53,7 @@ func Test_EncodeDecode_ValuesBytes(t *testing.T) {
 		{
 			3,
 			&TagSet{
-				map[Key][]byte{k2: []byte("v2")},
+				m: map[Key][]byte{k2: []byte("v2")},
 			},
 			[]Key{k1},
 			nil,
@@ -61,7 +61,7 @@ func Test_EncodeDecode_ValuesBytes(t *testing.T) {
 		{
 			4,
 			&TagSet{
-				map[Key][]byte{k2: []byte("v2")},
+				m: map[Key][]byte{k2: []byte("v2")},
 			},
 			[]Key{k2},
 			map[Key][]byte{
@@ -71,7 +71,7 @@ func Test_EncodeDecode_ValuesBytes(t *testing.T) {
 		{
 			5,
 			&TagSet{
-				map[Key][]byte{
+				m: map[Key][]byte{
 					k1: []byte("v1"),
 					k2: []byte("v2")},
 			},
@@ -83,7 +83,7 @@ func Test_EncodeDecode_ValuesBytes(t *testing.T) {
 		{
 			6,
 			&TagSet{
-				map[Key][]byte{
+				m: map[Key][]byte{
 					k2: []byte("v2"),
 					k1: []byte("v1")},
 			},
@@ -96,7 +96,7 @@ func Test_EncodeDecode_ValuesBytes(t *testing.T) {
 		{
 			7,
 			&TagSet{
-				map[Key][]byte{
+				m: map[Key][]byte{
 					k1: []byte("v1"),
 					k2: []byte("v2"),
 					k3: []byte("v3")},
@@ -130,3 +130,33 @@ func Test_EncodeDecode_ValuesBytes(t *testing.T) {
 		}
 	}
 }
+
+func Test_AppendSignature(t *testing.T) {
+	km := newKeysManager()
+	k1, _ := km.createKeyString("k1")
+	k2, _ := km.createKeyString("k2")
+
+	ts1 := NewTagSetBuilder(nil).InsertString(k1, "v1").InsertString(k2, "v2").Build()
+	ts2 := NewTagSetBuilder(nil).InsertString(k2, "v2").InsertString(k1, "v1").Build()
+	ts3 := NewTagSetBuilder(nil).InsertString(k1, "v2").InsertString(k2, "v1").Build()
+	ts4 := NewTagSetBuilder(nil).InsertString(k1, "v1").Build()
+
+	type testCase struct {
+		label string
+		a, b  *TagSet
+		want  bool
+	}
+	tcs := []testCase{
+		{"same tags inserted in another order", ts1, ts2, true},
+		{"values swapped between keys", ts1, ts3, false},
+		{"subset of the tags", ts1, ts4, false},
+		{"empty TagSets", newTagSet(0), newTagSet(0), true},
+	}
+	for _, tc := range tcs {
+		a := string(AppendSignature(nil, tc.a))
+		b := string(AppendSignature([]byte("prefix"), tc.b)[len("prefix"):])
+		if got := a == b; got != tc.want {
+			t.Errorf("%v: got equal signatures %v, want %v", tc.label, got, tc.want)
+		}
+	}
+}
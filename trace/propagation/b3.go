@@ -0,0 +1,139 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package propagation
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/census-instrumentation/opencensus-go/trace"
+)
+
+// The headers of the B3 formats.
+const (
+	b3TraceIDHeader = "x-b3-traceid"
+	b3SpanIDHeader  = "x-b3-spanid"
+	b3SampledHeader = "x-b3-sampled"
+	b3FlagsHeader   = "x-b3-flags"
+	b3SingleHeader  = "b3"
+)
+
+// B3Format is the format of Zipkin. The span context is propagated in the
+// X-B3-TraceId, X-B3-SpanId and X-B3-Sampled headers, or in the b3 header if
+// SingleHeader is set. Extract accepts both forms regardless of
+// SingleHeader, the b3 header taking precedence, and the 64 bits trace IDs.
+// The debug flag is interpreted as sampled.
+type B3Format struct {
+	SingleHeader bool
+}
+
+// Extract implements Format.
+func (f B3Format) Extract(c Carrier) (trace.SpanContext, bool) {
+	if h := c.Get(b3SingleHeader); h != "" {
+		return parseB3Single(h)
+	}
+	var sc trace.SpanContext
+	var ok bool
+	if sc.TraceID, ok = parseB3TraceID(c.Get(b3TraceIDHeader)); !ok {
+		return trace.SpanContext{}, false
+	}
+	if sc.SpanID, ok = parseSpanID(c.Get(b3SpanIDHeader)); !ok {
+		return trace.SpanContext{}, false
+	}
+	switch c.Get(b3SampledHeader) {
+	case "1", "true":
+		sc = sc.WithSampled(true)
+	}
+	if c.Get(b3FlagsHeader) == "1" {
+		sc = sc.WithSampled(true)
+	}
+	return sc, sc.IsValid()
+}
+
+// parseB3Single parses the b3 header: {TraceId}-{SpanId}-{SamplingState}
+// followed by an optional -{ParentSpanId}. A header holding only the sampling
+// state doesn't hold a valid span context.
+func parseB3Single(h string) (trace.SpanContext, bool) {
+	parts := strings.Split(h, "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		return trace.SpanContext{}, false
+	}
+	var sc trace.SpanContext
+	var ok bool
+	if sc.TraceID, ok = parseB3TraceID(parts[0]); !ok {
+		return trace.SpanContext{}, false
+	}
+	if sc.SpanID, ok = parseSpanID(parts[1]); !ok {
+		return trace.SpanContext{}, false
+	}
+	if len(parts) > 2 {
+		switch parts[2] {
+		case "1", "d":
+			sc = sc.WithSampled(true)
+		case "0":
+		default:
+			return trace.SpanContext{}, false
+		}
+	}
+	if len(parts) > 3 {
+		if _, ok := parseSpanID(parts[3]); !ok {
+			return trace.SpanContext{}, false
+		}
+	}
+	return sc, sc.IsValid()
+}
+
+// Inject implements Format.
+func (f B3Format) Inject(sc trace.SpanContext, c Carrier) {
+	if !sc.IsValid() {
+		return
+	}
+	sampled := "0"
+	if sc.IsSampled() {
+		sampled = "1"
+	}
+	if f.SingleHeader {
+		c.Set(b3SingleHeader, sc.TraceID.String()+"-"+sc.SpanID.String()+"-"+sampled)
+		return
+	}
+	c.Set(b3TraceIDHeader, sc.TraceID.String())
+	c.Set(b3SpanIDHeader, sc.SpanID.String())
+	c.Set(b3SampledHeader, sampled)
+}
+
+// parseB3TraceID parses a trace ID of 128 or 64 bits. The 64 bits IDs are
+// the low bits of the trace ID.
+func parseB3TraceID(s string) (trace.TraceID, bool) {
+	var id trace.TraceID
+	if len(s) != 32 && len(s) != 16 {
+		return id, false
+	}
+	if _, err := hex.Decode(id[16-len(s)/2:], []byte(s)); err != nil {
+		return trace.TraceID{}, false
+	}
+	return id, id != trace.TraceID{}
+}
+
+func parseSpanID(s string) (trace.SpanID, bool) {
+	var id trace.SpanID
+	if len(s) != 16 {
+		return id, false
+	}
+	if _, err := hex.Decode(id[:], []byte(s)); err != nil {
+		return trace.SpanID{}, false
+	}
+	return id, id != trace.SpanID{}
+}
@@ -0,0 +1,81 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package propagation encodes and decodes span contexts in the headers of
+// the requests, so that a trace continues across processes. It implements
+// the B3 formats of Zipkin, in multiple or single headers, and the W3C Trace
+// Context format, so that the plugins can interoperate with other tracers.
+package propagation
+
+import (
+	"net/http"
+
+	"github.com/census-instrumentation/opencensus-go/trace"
+)
+
+// Carrier holds the headers a span context is propagated in, e.g. the
+// headers of an HTTP request or the metadata of a gRPC call. The keys are
+// lowercase: the carriers of case-sensitive headers must convert them.
+type Carrier interface {
+	// Get returns the value of the header key, or "" if it is not set.
+	Get(key string) string
+	// Set sets the value of the header key.
+	Set(key, value string)
+}
+
+// HTTPHeaderCarrier is the Carrier of the headers of an HTTP request.
+type HTTPHeaderCarrier http.Header
+
+// Get implements Carrier.
+func (c HTTPHeaderCarrier) Get(key string) string {
+	return http.Header(c).Get(key)
+}
+
+// Set implements Carrier.
+func (c HTTPHeaderCarrier) Set(key, value string) {
+	http.Header(c).Set(key, value)
+}
+
+// Format is a format of the headers propagating a span context.
+type Format interface {
+	// Extract returns the span context propagated in c. It returns false if
+	// c doesn't hold a valid span context in this format.
+	Extract(c Carrier) (trace.SpanContext, bool)
+	// Inject sets the headers propagating sc in c.
+	Inject(sc trace.SpanContext, c Carrier)
+}
+
+// MultiFormat propagates the span contexts in several formats, e.g. to
+// migrate from one format to another. Extract returns the span context of the
+// first format holding a valid one, and Inject sets the headers of all the
+// formats.
+type MultiFormat []Format
+
+// Extract implements Format.
+func (m MultiFormat) Extract(c Carrier) (trace.SpanContext, bool) {
+	for _, f := range m {
+		if sc, ok := f.Extract(c); ok {
+			return sc, true
+		}
+	}
+	return trace.SpanContext{}, false
+}
+
+// Inject implements Format.
+func (m MultiFormat) Inject(sc trace.SpanContext, c Carrier) {
+	for _, f := range m {
+		f.Inject(sc, c)
+	}
+}
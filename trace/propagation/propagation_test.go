@@ -0,0 +1,206 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package propagation
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-go/trace"
+)
+
+var (
+	testTraceID = trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	testSpanID  = trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}
+	testSampled = trace.SpanContext{TraceID: testTraceID, SpanID: testSpanID}.WithSampled(true)
+)
+
+func TestExtract(t *testing.T) {
+	type testCase struct {
+		label   string
+		f       Format
+		headers map[string]string
+		want    trace.SpanContext
+		wantOK  bool
+	}
+	tcs := []testCase{
+		{
+			"b3 multi",
+			B3Format{},
+			map[string]string{"X-B3-TraceId": "4bf92f3577b34da6a3ce929d0e0e4736", "X-B3-SpanId": "00f067aa0ba902b7", "X-B3-Sampled": "1"},
+			testSampled,
+			true,
+		},
+		{
+			"b3 multi 64 bits trace id not sampled",
+			B3Format{},
+			map[string]string{"X-B3-TraceId": "a3ce929d0e0e4736", "X-B3-SpanId": "00f067aa0ba902b7"},
+			trace.SpanContext{TraceID: trace.TraceID{8: 0xa3, 9: 0xce, 10: 0x92, 11: 0x9d, 12: 0x0e, 13: 0x0e, 14: 0x47, 15: 0x36}, SpanID: testSpanID},
+			true,
+		},
+		{
+			"b3 multi debug",
+			B3Format{},
+			map[string]string{"X-B3-TraceId": "4bf92f3577b34da6a3ce929d0e0e4736", "X-B3-SpanId": "00f067aa0ba902b7", "X-B3-Flags": "1"},
+			testSampled,
+			true,
+		},
+		{
+			"b3 multi invalid span id",
+			B3Format{},
+			map[string]string{"X-B3-TraceId": "4bf92f3577b34da6a3ce929d0e0e4736", "X-B3-SpanId": "00f067aa0ba9"},
+			trace.SpanContext{},
+			false,
+		},
+		{
+			"b3 single",
+			B3Format{SingleHeader: true},
+			map[string]string{"b3": "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1-05e3ac9a4f6e3b90"},
+			testSampled,
+			true,
+		},
+		{
+			"b3 single without sampling state",
+			B3Format{},
+			map[string]string{"b3": "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"},
+			trace.SpanContext{TraceID: testTraceID, SpanID: testSpanID},
+			true,
+		},
+		{
+			"b3 single sampling state only",
+			B3Format{},
+			map[string]string{"b3": "0"},
+			trace.SpanContext{},
+			false,
+		},
+		{
+			"traceparent",
+			TraceContextFormat{},
+			map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "tracestate": "rojo=00f067aa0ba902b7, congo=t61rcWkgMzE"},
+			trace.SpanContext{TraceID: testTraceID, SpanID: testSpanID, TraceOptions: 1, Tracestate: "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE"},
+			true,
+		},
+		{
+			"traceparent of a future version",
+			TraceContextFormat{},
+			map[string]string{"traceparent": "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future-holds"},
+			testSampled,
+			true,
+		},
+		{
+			"traceparent with invalid tracestate",
+			TraceContextFormat{},
+			map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", "tracestate": "rojo=1,rojo=2"},
+			trace.SpanContext{TraceID: testTraceID, SpanID: testSpanID},
+			true,
+		},
+		{
+			"traceparent with trailing data",
+			TraceContextFormat{},
+			map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-00"},
+			trace.SpanContext{},
+			false,
+		},
+		{
+			"traceparent with zero trace id",
+			TraceContextFormat{},
+			map[string]string{"traceparent": "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+			trace.SpanContext{},
+			false,
+		},
+		{
+			"traceparent with uppercase hex",
+			TraceContextFormat{},
+			map[string]string{"traceparent": "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+			trace.SpanContext{},
+			false,
+		},
+		{
+			"multi format falls back",
+			MultiFormat{TraceContextFormat{}, B3Format{}},
+			map[string]string{"X-B3-TraceId": "4bf92f3577b34da6a3ce929d0e0e4736", "X-B3-SpanId": "00f067aa0ba902b7", "X-B3-Sampled": "1"},
+			testSampled,
+			true,
+		},
+	}
+	for _, tc := range tcs {
+		h := http.Header{}
+		for k, v := range tc.headers {
+			h.Set(k, v)
+		}
+		got, ok := tc.f.Extract(HTTPHeaderCarrier(h))
+		if ok != tc.wantOK || got != tc.want {
+			t.Errorf("%v: Extract got %+v, %v, want %+v, %v", tc.label, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestInject(t *testing.T) {
+	sc := testSampled
+	sc.Tracestate = "congo=t61rcWkgMzE"
+	type testCase struct {
+		label string
+		f     Format
+		want  http.Header
+		// extracted is the span context extracted from the injected headers:
+		// only the W3C format propagates the tracestate.
+		extracted trace.SpanContext
+	}
+	tcs := []testCase{
+		{
+			"b3 multi",
+			B3Format{},
+			http.Header{
+				"X-B3-Traceid": {"4bf92f3577b34da6a3ce929d0e0e4736"},
+				"X-B3-Spanid":  {"00f067aa0ba902b7"},
+				"X-B3-Sampled": {"1"},
+			},
+			testSampled,
+		},
+		{
+			"b3 single",
+			B3Format{SingleHeader: true},
+			http.Header{"B3": {"4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1"}},
+			testSampled,
+		},
+		{
+			"traceparent",
+			TraceContextFormat{},
+			http.Header{
+				"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+				"Tracestate":  {"congo=t61rcWkgMzE"},
+			},
+			sc,
+		},
+	}
+	for _, tc := range tcs {
+		h := http.Header{}
+		tc.f.Inject(sc, HTTPHeaderCarrier(h))
+		if !reflect.DeepEqual(h, tc.want) {
+			t.Errorf("%v: Inject got headers %v, want %v", tc.label, h, tc.want)
+		}
+		if got, ok := tc.f.Extract(HTTPHeaderCarrier(h)); !ok || got != tc.extracted {
+			t.Errorf("%v: Extract of the injected headers got %+v, %v, want %+v", tc.label, got, ok, tc.extracted)
+		}
+	}
+
+	h := http.Header{}
+	MultiFormat{B3Format{}, TraceContextFormat{}}.Inject(trace.SpanContext{}, HTTPHeaderCarrier(h))
+	if len(h) != 0 {
+		t.Errorf("Inject of an invalid span context got headers %v, want none", h)
+	}
+}
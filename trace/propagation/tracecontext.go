@@ -0,0 +1,126 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package propagation
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/census-instrumentation/opencensus-go/trace"
+)
+
+// The headers of the W3C Trace Context format.
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+)
+
+const (
+	traceparentVersion = "00"
+	// traceparentLen is the length of a traceparent header of version 00:
+	// version-traceid-spanid-flags.
+	traceparentLen = 2 + 1 + 32 + 1 + 16 + 1 + 2
+	// maxTracestateMembers is the maximum number of list members of a
+	// tracestate header.
+	maxTracestateMembers = 32
+)
+
+// TraceContextFormat is the W3C Trace Context format. The span context is
+// propagated in the traceparent header, and the state of the trace in the
+// tracestate header. Extract accepts the headers of future versions as long
+// as they start with the fields of version 00.
+type TraceContextFormat struct{}
+
+// Extract implements Format.
+func (f TraceContextFormat) Extract(c Carrier) (trace.SpanContext, bool) {
+	h := strings.TrimSpace(c.Get(traceparentHeader))
+	if len(h) < traceparentLen {
+		return trace.SpanContext{}, false
+	}
+	version := h[:2]
+	if !isLowerHex(version) || version == "ff" {
+		return trace.SpanContext{}, false
+	}
+	if len(h) > traceparentLen && (version == traceparentVersion || h[traceparentLen] != '-') {
+		return trace.SpanContext{}, false
+	}
+	if h[2] != '-' || h[35] != '-' || h[52] != '-' {
+		return trace.SpanContext{}, false
+	}
+	traceID, spanID, flags := h[3:35], h[36:52], h[53:55]
+	if !isLowerHex(traceID) || !isLowerHex(spanID) || !isLowerHex(flags) {
+		return trace.SpanContext{}, false
+	}
+	var sc trace.SpanContext
+	hex.Decode(sc.TraceID[:], []byte(traceID))
+	hex.Decode(sc.SpanID[:], []byte(spanID))
+	var opts [1]byte
+	hex.Decode(opts[:], []byte(flags))
+	sc = sc.WithSampled(opts[0]&1 != 0)
+	if !sc.IsValid() {
+		return trace.SpanContext{}, false
+	}
+	sc.Tracestate = parseTracestate(c.Get(tracestateHeader))
+	return sc, true
+}
+
+// parseTracestate returns the normalized tracestate header h, or "" if it is
+// invalid: the list members must be key=value pairs with distinct keys.
+func parseTracestate(h string) string {
+	var members []string
+	seen := make(map[string]bool)
+	for _, m := range strings.Split(h, ",") {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		i := strings.IndexByte(m, '=')
+		if i <= 0 || i == len(m)-1 || seen[m[:i]] {
+			return ""
+		}
+		seen[m[:i]] = true
+		members = append(members, m)
+	}
+	if len(members) > maxTracestateMembers {
+		return ""
+	}
+	return strings.Join(members, ",")
+}
+
+// Inject implements Format.
+func (f TraceContextFormat) Inject(sc trace.SpanContext, c Carrier) {
+	if !sc.IsValid() {
+		return
+	}
+	flags := "00"
+	if sc.IsSampled() {
+		flags = "01"
+	}
+	c.Set(traceparentHeader, traceparentVersion+"-"+sc.TraceID.String()+"-"+sc.SpanID.String()+"-"+flags)
+	if sc.Tracestate != "" {
+		c.Set(tracestateHeader, sc.Tracestate)
+	}
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package trace
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SamplingParameters are the parameters of the sampling decision of a new
+// span.
+type SamplingParameters struct {
+	// ParentContext is the span context of the parent of the span. It is
+	// invalid for the root spans.
+	ParentContext SpanContext
+	TraceID       TraceID
+	SpanID        SpanID
+	Name          string
+}
+
+// Sampler decides whether a new span is sampled, i.e. recorded and
+// propagated as sampled to its children.
+type Sampler interface {
+	ShouldSample(p SamplingParameters) bool
+}
+
+type alwaysSampler struct{}
+
+func (alwaysSampler) ShouldSample(p SamplingParameters) bool { return true }
+
+// AlwaysSample returns a Sampler sampling all the spans.
+func AlwaysSample() Sampler {
+	return alwaysSampler{}
+}
+
+type neverSampler struct{}
+
+func (neverSampler) ShouldSample(p SamplingParameters) bool { return false }
+
+// NeverSample returns a Sampler sampling none of the spans.
+func NeverSample() Sampler {
+	return neverSampler{}
+}
+
+type probabilitySampler struct {
+	// upperBound is compared to the upper 8 bytes of the trace IDs.
+	upperBound uint64
+}
+
+func (s probabilitySampler) ShouldSample(p SamplingParameters) bool {
+	return binary.BigEndian.Uint64(p.TraceID[:8])>>1 < s.upperBound
+}
+
+// ProbabilitySampler returns a Sampler sampling the fraction of the traces
+// given by probability. The decision only depends on the trace ID, so all
+// the processes sampling a trace with the same probability agree.
+// Probabilities outside of [0, 1] are clamped.
+func ProbabilitySampler(probability float64) Sampler {
+	if probability >= 1 {
+		return AlwaysSample()
+	}
+	if probability <= 0 {
+		return NeverSample()
+	}
+	return probabilitySampler{upperBound: uint64(probability * (1 << 63))}
+}
+
+// rateLimitingSampler is a token bucket holding up to one second of tokens.
+type rateLimitingSampler struct {
+	perSecond float64
+	now       func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// RateLimitingSampler returns a Sampler sampling at most perSecond spans
+// per second, with bursts of up to perSecond spans.
+func RateLimitingSampler(perSecond float64) Sampler {
+	return newRateLimitingSampler(perSecond, time.Now)
+}
+
+func newRateLimitingSampler(perSecond float64, now func() time.Time) *rateLimitingSampler {
+	return &rateLimitingSampler{
+		perSecond: perSecond,
+		now:       now,
+		tokens:    perSecond,
+		last:      now(),
+	}
+}
+
+func (s *rateLimitingSampler) ShouldSample(p SamplingParameters) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if elapsed := now.Sub(s.last); elapsed > 0 {
+		s.tokens += elapsed.Seconds() * s.perSecond
+		if s.tokens > s.perSecond {
+			s.tokens = s.perSecond
+		}
+	}
+	s.last = now
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+// defaultSamplerProbability is the probability of the default sampler.
+const defaultSamplerProbability = 1e-4
+
+// samplerHolder allows storing samplers of different types in an
+// atomic.Value.
+type samplerHolder struct {
+	s Sampler
+}
+
+var defaultSampler atomic.Value // samplerHolder
+
+func init() {
+	defaultSampler.Store(samplerHolder{ProbabilitySampler(defaultSamplerProbability)})
+}
+
+// SetDefaultSampler sets the sampler deciding whether the root spans, and the
+// spans started without a sampler in WithSampler, are sampled. The default
+// sampler samples 1 in 10,000 traces.
+func SetDefaultSampler(s Sampler) {
+	if s == nil {
+		s = ProbabilitySampler(defaultSamplerProbability)
+	}
+	defaultSampler.Store(samplerHolder{s})
+}
@@ -0,0 +1,103 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package trace
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestProbabilitySampler(t *testing.T) {
+	type testCase struct {
+		p    float64
+		want int
+	}
+	for _, tc := range []testCase{{0, 0}, {0.5, 5000}, {1, 10000}, {-1, 0}, {2, 10000}} {
+		s := ProbabilitySampler(tc.p)
+		got := 0
+		for i := 0; i < 10000; i++ {
+			if s.ShouldSample(SamplingParameters{TraceID: ids.newTraceID()}) {
+				got++
+			}
+		}
+		if got < tc.want-300 || got > tc.want+300 {
+			t.Errorf("ProbabilitySampler(%v) sampled %v spans out of 10000, want about %v", tc.p, got, tc.want)
+		}
+	}
+
+	// the decision only depends on the trace ID.
+	s := ProbabilitySampler(0.5)
+	id := ids.newTraceID()
+	want := s.ShouldSample(SamplingParameters{TraceID: id})
+	for i := 0; i < 10; i++ {
+		if got := s.ShouldSample(SamplingParameters{TraceID: id, SpanID: ids.newSpanID()}); got != want {
+			t.Fatalf("ProbabilitySampler got %v then %v for the same trace ID", want, got)
+		}
+	}
+}
+
+func TestRateLimitingSampler(t *testing.T) {
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newRateLimitingSampler(10, func() time.Time { return now })
+	count := func(n int) int {
+		sampled := 0
+		for i := 0; i < n; i++ {
+			if s.ShouldSample(SamplingParameters{}) {
+				sampled++
+			}
+		}
+		return sampled
+	}
+	if got := count(100); got != 10 {
+		t.Errorf("sampled %v spans of a burst, want 10", got)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if got := count(100); got != 5 {
+		t.Errorf("sampled %v spans after 500ms, want 5", got)
+	}
+	now = now.Add(time.Hour)
+	if got := count(100); got != 10 {
+		t.Errorf("sampled %v spans after an hour, want 10", got)
+	}
+}
+
+func TestStartSpan(t *testing.T) {
+	SetDefaultSampler(NeverSample())
+	defer SetDefaultSampler(nil)
+
+	ctx, root := StartSpan(context.Background(), "root")
+	if !root.SpanContext().IsValid() || root.IsSampled() {
+		t.Fatalf("got root span context %v, want a valid span context not sampled by the default sampler", root.SpanContext())
+	}
+	if sc, _ := FromContext(ctx); sc != root.SpanContext() {
+		t.Errorf("FromContext got %v, want the span context of the root span %v", sc, root.SpanContext())
+	}
+
+	ctx, child := StartSpan(ctx, "child", WithSampler(AlwaysSample()))
+	if child.SpanContext().TraceID != root.SpanContext().TraceID || child.SpanContext().SpanID == root.SpanContext().SpanID {
+		t.Errorf("got child span context %v, want a new span of the trace of %v", child.SpanContext(), root.SpanContext())
+	}
+	if !child.IsSampled() {
+		t.Errorf("got child span not sampled, want it sampled by the sampler of WithSampler")
+	}
+	if _, grandChild := StartSpan(ctx, "grand child"); !grandChild.IsSampled() {
+		t.Errorf("got grand child span not sampled, want it to inherit the decision of its parent")
+	}
+	child.End()
+	root.End()
+}
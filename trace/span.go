@@ -0,0 +1,156 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package trace
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Span is a span of a trace started by StartSpan. The spans are not
+// recorded nor exported yet: only their span context is propagated.
+type Span struct {
+	sc    SpanContext
+	name  string
+	start time.Time
+
+	mu  sync.Mutex
+	end time.Time
+}
+
+// SpanContext returns the span context of s.
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+// Name returns the name of s.
+func (s *Span) Name() string {
+	return s.name
+}
+
+// IsSampled returns true if s is sampled.
+func (s *Span) IsSampled() bool {
+	return s.sc.IsSampled()
+}
+
+// End ends s. Only the first call has an effect.
+func (s *Span) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.end.IsZero() {
+		s.end = time.Now()
+	}
+}
+
+// StartOption customizes a span started with StartSpan.
+type StartOption func(o *startOptions)
+
+type startOptions struct {
+	sampler Sampler
+}
+
+// WithSampler makes StartSpan decide with s whether the span is sampled,
+// instead of inheriting the decision of the parent span or using the default
+// sampler.
+func WithSampler(s Sampler) StartOption {
+	return func(o *startOptions) {
+		o.sampler = s
+	}
+}
+
+// StartSpan starts a span named name, child of the current span of ctx if
+// any, and returns a context carrying its span context. A child span is
+// sampled if its parent is sampled, unless a sampler is set with
+// WithSampler. The root spans are sampled by the default sampler, see
+// SetDefaultSampler.
+func StartSpan(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	var o startOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	parent, _ := FromContext(ctx)
+	sc := SpanContext{SpanID: ids.newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Tracestate = parent.Tracestate
+	} else {
+		parent = SpanContext{}
+		sc.TraceID = ids.newTraceID()
+	}
+
+	sampled := parent.IsSampled()
+	if s := o.sampler; s != nil || !parent.IsValid() {
+		if s == nil {
+			s = defaultSampler.Load().(samplerHolder).s
+		}
+		sampled = s.ShouldSample(SamplingParameters{
+			ParentContext: parent,
+			TraceID:       sc.TraceID,
+			SpanID:        sc.SpanID,
+			Name:          name,
+		})
+	}
+	sc = sc.WithSampled(sampled)
+
+	s := &Span{
+		sc:    sc,
+		name:  name,
+		start: time.Now(),
+	}
+	return NewContext(ctx, sc), s
+}
+
+// idGenerator generates random trace and span IDs.
+type idGenerator struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+var ids = newIDGenerator()
+
+func newIDGenerator() *idGenerator {
+	var seed int64
+	if err := binary.Read(crand.Reader, binary.LittleEndian, &seed); err != nil {
+		seed = time.Now().UnixNano()
+	}
+	return &idGenerator{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (g *idGenerator) newTraceID() TraceID {
+	var id TraceID
+	g.mu.Lock()
+	for id == (TraceID{}) {
+		binary.LittleEndian.PutUint64(id[:8], g.rng.Uint64())
+		binary.LittleEndian.PutUint64(id[8:], g.rng.Uint64())
+	}
+	g.mu.Unlock()
+	return id
+}
+
+func (g *idGenerator) newSpanID() SpanID {
+	var id SpanID
+	g.mu.Lock()
+	for id == (SpanID{}) {
+		binary.LittleEndian.PutUint64(id[:], g.rng.Uint64())
+	}
+	g.mu.Unlock()
+	return id
+}
@@ -0,0 +1,96 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package trace holds the identity of the spans of a trace. The SpanContext
+// of the current span is carried by a context.Context so that the data
+// recorded in the span, e.g. the stats, can be linked to its trace.
+package trace
+
+import (
+	"encoding/hex"
+
+	"golang.org/x/net/context"
+)
+
+// TraceID is the identifier of a trace. It is zero for an invalid trace.
+type TraceID [16]byte
+
+// String returns the lowercase hex encoding of t.
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID is the identifier of a span within its trace. It is zero for an
+// invalid span.
+type SpanID [8]byte
+
+// String returns the lowercase hex encoding of s.
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// TraceOptions are the options propagated with the span context. Only the
+// least significant bit is defined: it is set if the trace is sampled.
+type TraceOptions uint32
+
+const traceOptionsSampled TraceOptions = 1
+
+// SpanContext is the identity of a span, propagated to its children, e.g.
+// across the processes of a distributed trace.
+type SpanContext struct {
+	TraceID      TraceID
+	SpanID       SpanID
+	TraceOptions TraceOptions
+	// Tracestate is the vendor-specific state of the trace propagated with
+	// the W3C Trace Context format, e.g. "congo=t61rcWkgMzE". It is opaque to
+	// this package.
+	Tracestate string
+}
+
+// IsValid returns true if the trace ID and the span ID of sc are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// IsSampled returns true if sc is valid and the trace is sampled, i.e. its
+// spans are recorded.
+func (sc SpanContext) IsSampled() bool {
+	return sc.IsValid() && sc.TraceOptions&traceOptionsSampled != 0
+}
+
+// WithSampled returns sc with the sampled option set to sampled.
+func (sc SpanContext) WithSampled(sampled bool) SpanContext {
+	if sampled {
+		sc.TraceOptions |= traceOptionsSampled
+	} else {
+		sc.TraceOptions &^= traceOptionsSampled
+	}
+	return sc
+}
+
+type ctxKey struct{}
+
+// NewContext returns a new context carrying sc as the span context of the
+// current span.
+func NewContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, ctxKey{}, sc)
+}
+
+// FromContext returns the span context of the current span carried by ctx.
+// It returns false if ctx doesn't carry a span context.
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(ctxKey{}).(SpanContext)
+	return sc, ok
+}
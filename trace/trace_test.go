@@ -0,0 +1,51 @@
+// Copyright 2017, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package trace
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestSpanContext(t *testing.T) {
+	sc := SpanContext{
+		TraceID: TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	}
+	if got, want := sc.TraceID.String(), "4bf92f3577b34da6a3ce929d0e0e4736"; got != want {
+		t.Errorf("TraceID.String() = %v, want %v", got, want)
+	}
+	if got, want := sc.SpanID.String(), "00f067aa0ba902b7"; got != want {
+		t.Errorf("SpanID.String() = %v, want %v", got, want)
+	}
+	if !sc.IsValid() || sc.IsSampled() {
+		t.Errorf("got valid %v and sampled %v, want a valid span context not sampled", sc.IsValid(), sc.IsSampled())
+	}
+	if sampled := sc.WithSampled(true); !sampled.IsSampled() || sampled.WithSampled(false).IsSampled() {
+		t.Errorf("WithSampled doesn't toggle the sampled option of %v", sampled)
+	}
+	if (SpanContext{TraceOptions: 1}).IsSampled() {
+		t.Errorf("got an invalid span context sampled, want it not sampled")
+	}
+
+	if _, ok := FromContext(context.Background()); ok {
+		t.Errorf("FromContext of an empty context got a span context, want none")
+	}
+	if got, ok := FromContext(NewContext(context.Background(), sc)); !ok || got != sc {
+		t.Errorf("FromContext got %v, %v, want %v, true", got, ok, sc)
+	}
+}